@@ -0,0 +1,272 @@
+package hyperv
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/resource"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage/allocation"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+// BootDevice 第一代虚拟机的启动设备
+type BootDevice uint16
+
+const (
+	BootFloppy BootDevice = BootDevice(virtual_system.BootOrder_Floppy)
+	BootCD     BootDevice = BootDevice(virtual_system.BootOrder_CDROM)
+	// BootIDE IDE 控制器上的硬盘
+	BootIDE BootDevice = BootDevice(virtual_system.BootOrder_HardDrive)
+	// BootLegacyNetwork 旧版网络适配器的 PXE 启动
+	BootLegacyNetwork BootDevice = BootDevice(virtual_system.BootOrder_PXEBoot)
+)
+
+func (d BootDevice) String() string {
+	switch d {
+	case BootFloppy:
+		return "Floppy"
+	case BootCD:
+		return "CD"
+	case BootIDE:
+		return "IDE"
+	case BootLegacyNetwork:
+		return "LegacyNetwork"
+	}
+	return fmt.Sprintf("BootDevice(%d)", uint16(d))
+}
+
+// BootEntryKind 第二代虚拟机启动项的类型
+type BootEntryKind int
+
+const (
+	BootEntryUnknown BootEntryKind = iota
+	// BootEntryHardDrive 虚拟硬盘
+	BootEntryHardDrive
+	// BootEntryDVD DVD 驱动器
+	BootEntryDVD
+	// BootEntryNetwork 网络适配器的 PXE 启动
+	BootEntryNetwork
+	// BootEntryFile 文件, 如客户机安装的启动管理器
+	BootEntryFile
+)
+
+// BootEntry 第二代虚拟机的启动项
+type BootEntry struct {
+	Kind        BootEntryKind `json:"kind"`
+	Description string        `json:"description"`
+	// Path 虚拟硬盘或 ISO 文件的路径, 文件启动项为文件位置, 驱动器为空时为空
+	Path string `json:"path"`
+	// NetworkAdapter 网络启动项的网络适配器名称
+	NetworkAdapter string `json:"network_adapter"`
+	// FirmwareDevicePath UEFI 设备路径
+	FirmwareDevicePath string `json:"firmware_device_path"`
+
+	// source is the object path of the boot source setting of the entry
+	source string
+}
+
+// BootOrder 虚拟机启动顺序
+type BootOrder struct {
+	Generation Generation `json:"generation"`
+	// Devices 第一代虚拟机的启动设备
+	Devices []BootDevice `json:"devices,omitempty"`
+	// Entries 第二代虚拟机的启动项
+	Entries []BootEntry `json:"entries,omitempty"`
+}
+
+// GetBootOrder 获取虚拟机启动顺序
+//
+// 第一代虚拟机返回启动设备, 第二代虚拟机返回启动项, 启动项解析为具体的虚拟硬盘、DVD 驱动器或网络适配器
+func (vm *VirtualMachine) GetBootOrder() (*BootOrder, error) {
+	settings, err := vm.computerSystem.GetVirtualSystemSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer settings.Close()
+
+	if settings.VirtualSystemSubType != virtual_system.HyperVGeneration_V2 {
+		order := &BootOrder{Generation: Generation1}
+		for _, device := range settings.BootOrder {
+			order.Devices = append(order.Devices, BootDevice(device))
+		}
+		return order, nil
+	}
+
+	sources, err := settings.GetBootSourceSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, source := range sources {
+			source.Close()
+		}
+	}()
+	media, err := settings.GetStorageAllocationSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, m := range media {
+			m.Close()
+		}
+	}()
+
+	order := &BootOrder{Generation: Generation2}
+	for _, source := range sources {
+		entry, err := resolveBootEntry(settings.GetService(), source, media)
+		if err != nil {
+			return nil, err
+		}
+		order.Entries = append(order.Entries, entry)
+	}
+	return order, nil
+}
+
+// resolveBootEntry resolves the boot source to the drive or the network adapter it boots from, whose
+// settings are associated with it.
+func resolveBootEntry(session *wmiext.Service, source *virtual_system.BootSourceSettingData, media []*allocation.StorageAllocationSettingData) (BootEntry, error) {
+	entry := BootEntry{
+		Description:        source.BootSourceDescription,
+		FirmwareDevicePath: source.FirmwareDevicePath,
+		source:             source.Path(),
+	}
+
+	switch source.BootSourceType {
+	case virtual_system.BootSourceType_Drive:
+		entry.Kind = BootEntryHardDrive
+		drives, err := wmiext.Navigate[resource.ResourceAllocationSettingData](session,
+			virtual_system.Msvm_BootSourceSettingData+"/"+resource.Msvm_ResourceAllocationSettingData, source.Path())
+		if err != nil {
+			return entry, err
+		}
+		defer func() {
+			for _, drive := range drives {
+				drive.Close()
+			}
+		}()
+		if len(drives) == 0 {
+			break
+		}
+		if drives[0].ResourceType == uint16(resource.ResourceAllocationSettingData_ResourceType_DVD_drive) {
+			entry.Kind = BootEntryDVD
+		}
+		for _, m := range media {
+			if objectpath.Equal(m.Parent, drives[0].Path()) && len(m.HostResource) > 0 {
+				entry.Path = m.HostResource[0]
+			}
+		}
+	case virtual_system.BootSourceType_Network:
+		entry.Kind = BootEntryNetwork
+		adapters, err := wmiext.Navigate[resource.ResourceAllocationSettingData](session,
+			virtual_system.Msvm_BootSourceSettingData+"/"+networking.Msvm_SyntheticEthernetPortSettingData, source.Path())
+		if err != nil {
+			return entry, err
+		}
+		defer func() {
+			for _, adapter := range adapters {
+				adapter.Close()
+			}
+		}()
+		if len(adapters) > 0 {
+			entry.NetworkAdapter = adapters[0].ElementName
+		}
+	case virtual_system.BootSourceType_File:
+		entry.Kind = BootEntryFile
+		entry.Path = source.OtherLocation
+	}
+	return entry, nil
+}
+
+// SetBootOrder 设置虚拟机启动顺序
+//
+// 第一代虚拟机使用 BootOrder.Devices, 第二代虚拟机使用 BootOrder.Entries, 启动项需由 GetBootOrder 获取.
+// 列出的设备或启动项排在最前, 未列出的保持原顺序排在其后, 如仅列出网络启动项即可进行一次 PXE 启动,
+// 之后仅列出虚拟硬盘即可恢复从硬盘启动
+//
+// 参数:
+//
+//	order: 启动顺序
+func (vm *VirtualMachine) SetBootOrder(order *BootOrder) error {
+	vsms, err := virtualSystemManagementService(vm.client)
+	if err != nil {
+		return err
+	}
+	settings, err := vm.computerSystem.GetVirtualSystemSettingData()
+	if err != nil {
+		return err
+	}
+	defer settings.Close()
+
+	if settings.VirtualSystemSubType != virtual_system.HyperVGeneration_V2 {
+		if len(order.Entries) > 0 {
+			return errors.New("boot entries require a generation 2 virtual machine, use boot devices")
+		}
+		current := make([]BootDevice, len(settings.BootOrder))
+		for i, device := range settings.BootOrder {
+			current[i] = BootDevice(device)
+		}
+		devices, err := reorderBoot(current, order.Devices, func(a, b BootDevice) bool { return a == b })
+		if err != nil {
+			return err
+		}
+		bootOrder := make([]uint16, len(devices))
+		for i, device := range devices {
+			bootOrder[i] = uint16(device)
+		}
+		if err = settings.Put("BootOrder", bootOrder); err != nil {
+			return err
+		}
+	} else {
+		if len(order.Devices) > 0 {
+			return errors.New("boot devices require a generation 1 virtual machine, use boot entries")
+		}
+		first := make([]string, len(order.Entries))
+		for i, entry := range order.Entries {
+			if entry.source == "" {
+				return errors.Errorf("boot entry %q is not obtained from GetBootOrder", entry.Description)
+			}
+			first[i] = entry.source
+		}
+		sources, err := reorderBoot(settings.BootSourceOrder, first, objectpath.Equal)
+		if err != nil {
+			return err
+		}
+		if err = settings.Put("BootSourceOrder", sources); err != nil {
+			return err
+		}
+	}
+	return wmiext.AwaitJob(vsms.ModifySystemSettings(settings.GetCimText()))
+}
+
+// reorderBoot moves first to the front of current, keeping the order of the remaining items. Every item
+// of first must be in current once.
+func reorderBoot[T any](current []T, first []T, equal func(a, b T) bool) ([]T, error) {
+	moved := make([]bool, len(current))
+	ordered := make([]T, 0, len(current))
+	for _, item := range first {
+		index := -1
+		for i := range current {
+			if equal(current[i], item) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, errors.Wrapf(ErrNotFound, "boot item %v", item)
+		}
+		if moved[index] {
+			return nil, errors.Errorf("boot item %v is listed twice", item)
+		}
+		moved[index] = true
+		ordered = append(ordered, current[index])
+	}
+	for i, item := range current {
+		if !moved[i] {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered, nil
+}
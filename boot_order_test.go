@@ -0,0 +1,193 @@
+package hyperv
+
+import (
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBootOrderMachine adds a machine whose system settings are updated by ModifySystemSettings, and
+// returns it with the path of its system settings.
+func newBootOrderMachine(t *testing.T) (*VirtualMachine, *wmiext.MemoryRepository, string) {
+	c, repo := newTestClient(t)
+	defineSettingAssociations(repo)
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_VirtualSystemManagementService",
+		Keys: []string{"CreationClassName", "Name"},
+		Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+			"ModifySystemSettings": {"SystemSettings": wmiext.CIM_STRING, "Job": wmiext.CIM_REFERENCE},
+		},
+	})
+	addAssociatedMachine(t, repo, "vm-1", "A0B1")
+
+	vm, err := c.FirstVirtualMachineByName("vm-1", FieldsBasic)
+	require.NoError(t, err)
+	settings, err := vm.computerSystem.GetVirtualSystemSettingData()
+	require.NoError(t, err)
+	settingPath := settings.Path()
+	settings.Close()
+
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "ModifySystemSettings", func(call *wmiext.MethodCall) error {
+		settings, err := wmiext.DecodeCimXml(call.InString("SystemSettings"))
+		if err != nil {
+			return err
+		}
+		properties := map[string]interface{}{}
+		for _, name := range []string{"BootOrder", "BootSourceOrder"} {
+			if value, _, _, err := settings.Get(name); err == nil && value != nil {
+				properties[name] = value
+			}
+		}
+		call.Return(0)
+		return repo.Update(settingPath, properties)
+	})
+	return vm, repo, settingPath
+}
+
+func TestVirtualMachine_BootOrder_Generation1(t *testing.T) {
+	vm, repo, settingPath := newBootOrderMachine(t)
+	require.NoError(t, repo.Update(settingPath, map[string]interface{}{
+		"BootOrder": []uint16{uint16(BootCD), uint16(BootIDE), uint16(BootLegacyNetwork), uint16(BootFloppy)},
+	}))
+
+	order, err := vm.GetBootOrder()
+	require.NoError(t, err)
+	assert.Equal(t, Generation1, order.Generation)
+	assert.Equal(t, []BootDevice{BootCD, BootIDE, BootLegacyNetwork, BootFloppy}, order.Devices)
+	assert.Empty(t, order.Entries)
+
+	// The listed devices go first, the others keep their order
+	require.NoError(t, vm.SetBootOrder(&BootOrder{Devices: []BootDevice{BootLegacyNetwork, BootIDE}}))
+	order, err = vm.GetBootOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []BootDevice{BootLegacyNetwork, BootIDE, BootCD, BootFloppy}, order.Devices)
+
+	assert.Error(t, vm.SetBootOrder(&BootOrder{Entries: []BootEntry{{Kind: BootEntryNetwork}}}))
+}
+
+func TestVirtualMachine_BootOrder_Generation2(t *testing.T) {
+	vm, repo, settingPath := newBootOrderMachine(t)
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_LogicalIdentity",
+		Properties: map[string]wmiext.CIMTYPE_ENUMERATION{
+			"SystemElement": wmiext.CIM_REFERENCE,
+			"SameElement":   wmiext.CIM_REFERENCE,
+		},
+	})
+	add := func(class string, properties map[string]interface{}) string {
+		path, err := repo.AddInstance(class, properties)
+		require.NoError(t, err)
+		return path
+	}
+	component := func(part string) {
+		add("Msvm_VirtualSystemSettingDataComponent", map[string]interface{}{
+			"GroupComponent": wmiext.Reference(settingPath), "PartComponent": wmiext.Reference(part),
+		})
+	}
+	identity := func(source string, element string) {
+		add("Msvm_LogicalIdentity", map[string]interface{}{
+			"SystemElement": wmiext.Reference(source), "SameElement": wmiext.Reference(element),
+		})
+	}
+
+	disk := add("Msvm_ResourceAllocationSettingData", map[string]interface{}{
+		"InstanceID": `Microsoft:A0B1\disk`, "ResourceType": uint16(17), "ElementName": "Hard Drive",
+	})
+	dvd := add("Msvm_ResourceAllocationSettingData", map[string]interface{}{
+		"InstanceID": `Microsoft:A0B1\dvd`, "ResourceType": uint16(16), "ElementName": "DVD Drive",
+	})
+	// The parent of the disk is a relative path, which must still match the path of the drive
+	relativeDisk, err := objectpath.Parse(disk)
+	require.NoError(t, err)
+	for id, media := range map[string][]interface{}{"vhd": {relativeDisk.RelativePath(), `D:\vms\vm-1.vhdx`}, "iso": {dvd, `D:\iso\setup.iso`}} {
+		component(add("Msvm_StorageAllocationSettingData", map[string]interface{}{
+			"InstanceID": `Microsoft:A0B1\` + id, "ResourceType": uint16(31), "Parent": media[0], "HostResource": []string{media[1].(string)},
+		}))
+	}
+	adapter := add("Msvm_SyntheticEthernetPortSettingData", map[string]interface{}{
+		"InstanceID": `Microsoft:A0B1\eth0`, "ResourceType": uint16(10), "ElementName": "eth0",
+	})
+
+	source := func(id string, sourceType uint16, description string, element string) string {
+		path := add("Msvm_BootSourceSettingData", map[string]interface{}{
+			"InstanceID": `Microsoft:A0B1\boot\` + id, "BootSourceType": sourceType, "BootSourceDescription": description,
+			"FirmwareDevicePath": `\` + id, "OtherLocation": "",
+		})
+		if element != "" {
+			identity(path, element)
+		}
+		return path
+	}
+	sources := []string{
+		source("disk", 1, "SCSI Disk Device", disk),
+		source("dvd", 1, "SCSI DVD Device", dvd),
+		source("eth0", 2, "Network Adapter", adapter),
+		source("file", 3, "Windows Boot Manager", ""),
+	}
+	require.NoError(t, repo.Update(sources[3], map[string]interface{}{"OtherLocation": `\EFI\Microsoft\Boot\bootmgfw.efi`}))
+	require.NoError(t, repo.Update(settingPath, map[string]interface{}{
+		"VirtualSystemSubType": "Microsoft:Hyper-V:SubType:2", "BootSourceOrder": sources,
+	}))
+
+	order, err := vm.GetBootOrder()
+	require.NoError(t, err)
+	assert.Equal(t, Generation2, order.Generation)
+	assert.Empty(t, order.Devices)
+	require.Len(t, order.Entries, 4)
+	assert.Equal(t, BootEntryHardDrive, order.Entries[0].Kind)
+	assert.Equal(t, `D:\vms\vm-1.vhdx`, order.Entries[0].Path)
+	assert.Equal(t, "SCSI Disk Device", order.Entries[0].Description)
+	assert.Equal(t, `\disk`, order.Entries[0].FirmwareDevicePath)
+	assert.Equal(t, BootEntryDVD, order.Entries[1].Kind)
+	assert.Equal(t, `D:\iso\setup.iso`, order.Entries[1].Path)
+	assert.Equal(t, BootEntryNetwork, order.Entries[2].Kind)
+	assert.Equal(t, "eth0", order.Entries[2].NetworkAdapter)
+	assert.Equal(t, BootEntryFile, order.Entries[3].Kind)
+	assert.Equal(t, `\EFI\Microsoft\Boot\bootmgfw.efi`, order.Entries[3].Path)
+
+	// Boot once from the network, then switch back to the disk
+	network, hardDrive := order.Entries[2], order.Entries[0]
+	require.NoError(t, vm.SetBootOrder(&BootOrder{Entries: []BootEntry{network}}))
+	order, err = vm.GetBootOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []BootEntryKind{BootEntryNetwork, BootEntryHardDrive, BootEntryDVD, BootEntryFile}, bootEntryKinds(order.Entries))
+
+	require.NoError(t, vm.SetBootOrder(&BootOrder{Entries: []BootEntry{hardDrive}}))
+	order, err = vm.GetBootOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []BootEntryKind{BootEntryHardDrive, BootEntryNetwork, BootEntryDVD, BootEntryFile}, bootEntryKinds(order.Entries))
+
+	assert.Error(t, vm.SetBootOrder(&BootOrder{Devices: []BootDevice{BootIDE}}))
+	// Entries must come from GetBootOrder
+	assert.Error(t, vm.SetBootOrder(&BootOrder{Entries: []BootEntry{{Kind: BootEntryNetwork}}}))
+}
+
+func bootEntryKinds(entries []BootEntry) []BootEntryKind {
+	kinds := make([]BootEntryKind, len(entries))
+	for i, entry := range entries {
+		kinds[i] = entry.Kind
+	}
+	return kinds
+}
+
+func TestReorderBoot(t *testing.T) {
+	equal := func(a, b string) bool { return a == b }
+	current := []string{"a", "b", "c", "d"}
+
+	ordered, err := reorderBoot(current, []string{"c", "a"}, equal)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a", "b", "d"}, ordered)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, current)
+
+	ordered, err = reorderBoot(current, nil, equal)
+	require.NoError(t, err)
+	assert.Equal(t, current, ordered)
+
+	_, err = reorderBoot(current, []string{"e"}, equal)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = reorderBoot(current, []string{"b", "b"}, equal)
+	assert.ErrorContains(t, err, "listed twice")
+}
@@ -0,0 +1,8 @@
+//go:build hyperv_replay
+// +build hyperv_replay
+
+package hyperv
+
+func init() {
+	defaultCassetteMode = "replay"
+}
@@ -0,0 +1,78 @@
+package hyperv
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+const defaultCassette = "testdata/cassettes/integration.json"
+
+// defaultCassetteMode is the mode when HYPERV_CASSETTE is not set, replay under the hyperv_replay
+// build tag.
+var defaultCassetteMode string
+
+// TestMain records the WMI calls of the tests to a cassette when HYPERV_CASSETTE is "record", and
+// serves them from the cassette when it is "replay", so that the integration tests recorded on a
+// Hyper-V host run anywhere. The hyperv_replay build tag makes replay the default, e.g.
+//
+//	go test -tags hyperv_replay -run TestHyperVIntegration
+//
+// while a cassette is recorded on a Hyper-V host by
+//
+//	HYPERV_CASSETTE=record go test -run TestHyperVIntegration
+//
+// HYPERV_CASSETTE_FILE overrides the cassette file. A replay runs the tests the cassette was recorded
+// with, and fails when the cassette does not exist. The file system is not replayed, the tests must not
+// depend on it.
+func TestMain(m *testing.M) {
+	mode := os.Getenv("HYPERV_CASSETTE")
+	if mode == "" {
+		mode = defaultCassetteMode
+	}
+	if mode == "" {
+		os.Exit(m.Run())
+	}
+	path := os.Getenv("HYPERV_CASSETTE_FILE")
+	if path == "" {
+		path = defaultCassette
+	}
+
+	var cassette *wmiext.Cassette
+	switch mode {
+	case "record":
+		cassette = wmiext.NewCassette()
+		previous := wmiext.SetLocalBackendFactory(nil)
+		wmiext.SetLocalBackendFactory(cassette.RecordFactory(previous))
+	case "replay":
+		var err error
+		if cassette, err = wmiext.LoadCassette(path); os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "no cassette to replay at %s, record one on a Hyper-V host with HYPERV_CASSETTE=record\n", path)
+			os.Exit(1)
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		wmiext.SetLocalBackendFactory(cassette.ReplayFactory())
+	default:
+		fmt.Fprintf(os.Stderr, "HYPERV_CASSETTE must be record or replay, not %q\n", mode)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	switch mode {
+	case "record":
+		if err := cassette.Save(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	case "replay":
+		if pending := cassette.Pending(); len(pending) > 0 && code == 0 {
+			fmt.Fprintf(os.Stderr, "%d calls recorded in %s were not replayed, the first is %s\n", len(pending), path, pending[0])
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
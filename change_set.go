@@ -0,0 +1,416 @@
+package hyperv
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking/switch_extension"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system/host"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+// ErrChangeNotApplied is the error of the changes left out of ChangeSet.Apply, because another
+// change failed first.
+var ErrChangeNotApplied = errors.New("change not applied")
+
+// ChangeResult is the outcome of the changes to one setting of a virtual machine.
+type ChangeResult struct {
+	// Name describes the setting, such as "processor" or the name of a network adapter.
+	Name string
+	// Path is the path of the setting, the one returned by Hyper-V once applied.
+	Path string
+	// Err is nil once the changes are applied.
+	Err error
+}
+
+// ChangeSet gathers changes to the processor, memory, controllers, drives and network adapters of
+// a virtual machine, applied together by Apply: the resources in a single ModifyResourceSettings
+// call, the existing port features in a single ModifyFeatureSettings call, and the new ones in an
+// AddFeatureSettings call per port. Like VirtualMachineBuilder, the first invalid change is kept
+// in Err and the following ones are ignored.
+type ChangeSet struct {
+	Err error
+
+	vm      *VirtualMachine
+	changes []*settingChange
+}
+
+// settingChange is the changes to one setting, merged when the setting is changed more than once.
+type settingChange struct {
+	name string
+	// path is the path of the setting, empty for a feature added to parent.
+	path    string
+	feature bool
+	parent  string
+	// template returns the default setting of a feature added to parent.
+	template   func(session *wmiext.Service) (*wmiext.Instance, error)
+	properties []settingProperty
+	// applied updates the cached fields once the change is applied.
+	applied []func()
+	// done is set once the change is applied, so that applying the change set again skips it.
+	done bool
+}
+
+type settingProperty struct {
+	name  string
+	value interface{}
+}
+
+// Changes returns an empty change set of the virtual machine.
+func (vm *VirtualMachine) Changes() *ChangeSet {
+	return &ChangeSet{vm: vm}
+}
+
+// Len returns the number of settings changed.
+func (cs *ChangeSet) Len() int {
+	return len(cs.changes)
+}
+
+// SetCpuCoreCount changes the number of virtual processors, which Hyper-V only allows while the
+// virtual machine is stopped.
+func (cs *ChangeSet) SetCpuCoreCount(cpuCoreCount int) *ChangeSet {
+	if cs.Err != nil {
+		return cs
+	}
+	if cpuCoreCount <= 0 {
+		cs.Err = errors.Errorf("invalid cpu core count %d", cpuCoreCount)
+		return cs
+	}
+	processorSettingData, err := cs.vm.computerSystem.GetProcessorSettingData()
+	if err != nil {
+		cs.Err = err
+		return cs
+	}
+	cs.resource("processor", processorSettingData.Path()).
+		set("VirtualQuantity", uint64(cpuCoreCount)).
+		then(func() { cs.vm.CpuCoreCount = cpuCoreCount })
+	return cs
+}
+
+// SetMemorySizeMB changes the memory size of the virtual machine, in MB.
+func (cs *ChangeSet) SetMemorySizeMB(memorySizeMB int) *ChangeSet {
+	if cs.Err != nil {
+		return cs
+	}
+	if memorySizeMB <= 0 {
+		cs.Err = errors.Errorf("invalid memory size %d", memorySizeMB)
+		return cs
+	}
+	memorySettingData, err := cs.vm.computerSystem.GetMemorySettingData()
+	if err != nil {
+		cs.Err = err
+		return cs
+	}
+	cs.resource("memory", memorySettingData.Path()).
+		set("VirtualQuantity", uint64(memorySizeMB)).
+		then(func() { cs.vm.MemorySizeMB = memorySizeMB })
+	return cs
+}
+
+// SetResourceProperty changes a property of any resource setting of the virtual machine, such as
+// a controller or a drive.
+func (cs *ChangeSet) SetResourceProperty(settings *wmiext.Instance, property string, value interface{}) *ChangeSet {
+	if cs.Err != nil {
+		return cs
+	}
+	path, err := settings.Path()
+	if err != nil {
+		cs.Err = err
+		return cs
+	}
+	name, _ := settings.GetAsString("ElementName")
+	if name == "" {
+		name = path
+	}
+	cs.resource(name, path).set(property, value)
+	return cs
+}
+
+// SetNetworkAdapterMacAddress gives a static MAC address to the network adapter, with or without
+// separators.
+func (cs *ChangeSet) SetNetworkAdapterMacAddress(vna *VirtualNetworkAdapter, macAddress string) *ChangeSet {
+	if cs.Err != nil {
+		return cs
+	}
+	address := strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(macAddress))
+	if len(address) != 12 {
+		cs.Err = errors.Errorf("invalid mac address %q", macAddress)
+		return cs
+	}
+	cs.resource(vna.Name, vna.virtualNetworkAdapter.Path()).
+		set("StaticMacAddress", true).
+		set("Address", address).
+		then(func() {
+			vna.StaticMacAddress = true
+			vna.MacAddress = address
+		})
+	return cs
+}
+
+// ConnectNetworkAdapter connects the network adapter to the virtual switch, replacing its current
+// connection.
+func (cs *ChangeSet) ConnectNetworkAdapter(vna *VirtualNetworkAdapter, vsw *VirtualSwitch) *ChangeSet {
+	if cs.Err != nil {
+		return cs
+	}
+	ethernetPortAllocationSettingData, err := vna.virtualNetworkAdapter.GetEthernetPortAllocationSettingData()
+	if err != nil {
+		cs.Err = err
+		return cs
+	}
+	cs.resource(vna.Name+" connection", ethernetPortAllocationSettingData.Path()).
+		set("HostResource", []string{vsw.virtualEthernetSwitch.Path()})
+	return cs
+}
+
+// SetNetworkAdapterBandwidth limits the bandwidth of the network adapter like
+// VirtualNetworkAdapter.SetBandwidth, in Mbps.
+func (cs *ChangeSet) SetNetworkAdapterBandwidth(vna *VirtualNetworkAdapter, limitBandwidthMbps, reserveBandwidthMbps float64) *ChangeSet {
+	if cs.Err != nil {
+		return cs
+	}
+	limitBandwidthMbps = max(limitBandwidthMbps, 0)
+	reserveBandwidthMbps = max(reserveBandwidthMbps, 0)
+	if limitBandwidthMbps <= reserveBandwidthMbps {
+		cs.Err = wmiext.NotSupported
+		return cs
+	}
+	ethernetPortAllocationSettingData, err := vna.virtualNetworkAdapter.GetEthernetPortAllocationSettingData()
+	if err != nil {
+		cs.Err = err
+		return cs
+	}
+	var path string
+	bandwidthSettingData, err := ethernetPortAllocationSettingData.GetEthernetSwitchPortBandwidthSettingData()
+	switch {
+	case err == nil:
+		path = bandwidthSettingData.Path()
+	case !errors.Is(err, wmiext.NotFound):
+		cs.Err = err
+		return cs
+	}
+	cs.feature(vna.Name+" bandwidth", path, ethernetPortAllocationSettingData.Path(), func(session *wmiext.Service) (*wmiext.Instance, error) {
+		settings, err := host.DefaultEthernetSwitchPortBandwidthSettingDataWith(session)
+		if err != nil {
+			return nil, err
+		}
+		return settings.Instance, nil
+	}).
+		set("Limit", uint64(limitBandwidthMbps*1000000)).
+		set("Reservation", uint64(reserveBandwidthMbps*1000000)).
+		then(func() {
+			vna.IsEnableBandwidth = true
+			vna.MaxBandwidth = limitBandwidthMbps
+			vna.MinBandwidth = reserveBandwidthMbps
+		})
+	return cs
+}
+
+// SetNetworkAdapterVlan puts the network adapter in access mode on the VLAN.
+func (cs *ChangeSet) SetNetworkAdapterVlan(vna *VirtualNetworkAdapter, vlanId int) *ChangeSet {
+	if cs.Err != nil {
+		return cs
+	}
+	if vlanId < 1 || vlanId > 4094 {
+		cs.Err = errors.Errorf("invalid vlan id %d", vlanId)
+		return cs
+	}
+	ethernetPortAllocationSettingData, err := vna.virtualNetworkAdapter.GetEthernetPortAllocationSettingData()
+	if err != nil {
+		cs.Err = err
+		return cs
+	}
+	var path string
+	vlanSettingData, err := ethernetPortAllocationSettingData.GetEthernetSwitchPortVlanSettingData()
+	switch {
+	case err == nil:
+		path = vlanSettingData.Path()
+	case !errors.Is(err, wmiext.NotFound):
+		cs.Err = err
+		return cs
+	}
+	cs.feature(vna.Name+" vlan", path, ethernetPortAllocationSettingData.Path(), func(session *wmiext.Service) (*wmiext.Instance, error) {
+		settings, err := host.DefaultEthernetSwitchPortVlanSettingDataWith(session)
+		if err != nil {
+			return nil, err
+		}
+		return settings.Instance, nil
+	}).
+		set("OperationMode", switch_extension.VlanOperationModeAccess).
+		set("AccessVlanId", uint16(vlanId)).
+		then(func() {
+			vna.IsEnableVlan = true
+			vna.VlanId = vlanId
+		})
+	return cs
+}
+
+// Apply submits the changes, resources first, and returns the result of every changed setting in
+// the order they were first changed. When a call fails, its changes get its error and the changes
+// left get ErrChangeNotApplied; the calls made before remain applied. Apply can be called again,
+// the changes already applied are skipped, which keeps features from being added twice, and the
+// others read the current settings before changing them.
+func (cs *ChangeSet) Apply() ([]ChangeResult, error) {
+	if cs.Err != nil {
+		return nil, cs.Err
+	}
+	vsms, err := virtualSystemManagementService(cs.vm.client)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ChangeResult, len(cs.changes))
+	for i, change := range cs.changes {
+		results[i] = ChangeResult{Name: change.name, Path: change.path, Err: ErrChangeNotApplied}
+		if change.done {
+			results[i].Err = nil
+		}
+	}
+	err = vsms.Session.WithArena(func(session *wmiext.Service) error {
+		var (
+			texts               = make([]string, len(cs.changes))
+			resources, features []int
+			added               = make(map[string][]int)
+			parents             []string
+		)
+		for i, change := range cs.changes {
+			if change.done {
+				continue
+			}
+			var err error
+			if texts[i], err = change.text(session); err != nil {
+				results[i].Err = err
+				return err
+			}
+			switch {
+			case !change.feature:
+				resources = append(resources, i)
+			case change.path != "":
+				features = append(features, i)
+			default:
+				if _, ok := added[change.parent]; !ok {
+					parents = append(parents, change.parent)
+				}
+				added[change.parent] = append(added[change.parent], i)
+			}
+		}
+
+		if err := cs.submit(results, texts, resources, vsms.ModifyResourceSettings); err != nil {
+			return err
+		}
+		if err := cs.submit(results, texts, features, vsms.ModifyFeatureSettings); err != nil {
+			return err
+		}
+		for _, parent := range parents {
+			if err := cs.submit(results, texts, added[parent], func(featureSettings []string) ([]*wmiext.Instance, error) {
+				return vsms.AddFeatureSettings(parent, featureSettings)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return results, err
+}
+
+// submit applies the changes at indexes in a single call to modify.
+func (cs *ChangeSet) submit(results []ChangeResult, texts []string, indexes []int, modify func([]string) ([]*wmiext.Instance, error)) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	batch := make([]string, len(indexes))
+	for j, i := range indexes {
+		batch[j] = texts[i]
+	}
+	resulting, err := modify(batch)
+	defer wmiext.CloseInstances(resulting)
+	if err != nil {
+		for _, i := range indexes {
+			results[i].Err = err
+		}
+		return err
+	}
+	for j, i := range indexes {
+		if j < len(resulting) {
+			if path, err := resulting[j].Path(); err == nil {
+				results[i].Path = path
+				cs.changes[i].path = path
+			}
+		}
+		results[i].Err = nil
+		cs.changes[i].done = true
+		for _, applied := range cs.changes[i].applied {
+			applied()
+		}
+	}
+	return nil
+}
+
+// resource returns the change of the resource setting at path.
+func (cs *ChangeSet) resource(name string, path string) *settingChange {
+	for _, change := range cs.changes {
+		if !change.feature && strings.EqualFold(change.path, path) {
+			return change
+		}
+	}
+	change := &settingChange{name: name, path: path}
+	cs.changes = append(cs.changes, change)
+	return change
+}
+
+// feature returns the change of the feature setting at path, or of the feature named name to add
+// to parent when path is empty.
+func (cs *ChangeSet) feature(name string, path string, parent string, template func(*wmiext.Service) (*wmiext.Instance, error)) *settingChange {
+	for _, change := range cs.changes {
+		if change.feature && change.name == name && strings.EqualFold(change.parent, parent) {
+			return change
+		}
+	}
+	change := &settingChange{name: name, path: path, feature: true, parent: parent, template: template}
+	cs.changes = append(cs.changes, change)
+	return change
+}
+
+// set changes a property of the setting, which is applied again when the change set is.
+func (c *settingChange) set(name string, value interface{}) *settingChange {
+	c.done = false
+	for i := range c.properties {
+		if c.properties[i].name == name {
+			c.properties[i].value = value
+			return c
+		}
+	}
+	c.properties = append(c.properties, settingProperty{name: name, value: value})
+	return c
+}
+
+func (c *settingChange) then(applied func()) *settingChange {
+	c.applied = append(c.applied, applied)
+	return c
+}
+
+// text returns the embedded instance of the setting with the changes made.
+func (c *settingChange) text(session *wmiext.Service) (string, error) {
+	var (
+		settings *wmiext.Instance
+		err      error
+	)
+	if c.path != "" {
+		settings, err = session.GetObject(c.path)
+	} else {
+		settings, err = c.template(session)
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, property := range c.properties {
+		if err = settings.Put(property.name, property.value); err != nil {
+			return "", errors.Wrapf(err, "set %s of %s", property.name, c.name)
+		}
+	}
+	text := settings.GetCimText()
+	if text == "" {
+		return "", errors.Errorf("encode %s", c.name)
+	}
+	return text, nil
+}
@@ -0,0 +1,213 @@
+package hyperv
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeSet_Apply(t *testing.T) {
+	c, repo := newTestClient(t)
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_VirtualSystemManagementService",
+		Keys: []string{"CreationClassName", "Name"},
+		Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+			"ModifyResourceSettings": {
+				"ResourceSettings":          wmiext.CIM_STRING | wmiext.CIM_FLAG_ARRAY,
+				"ResultingResourceSettings": wmiext.CIM_REFERENCE | wmiext.CIM_FLAG_ARRAY,
+				"Job":                       wmiext.CIM_REFERENCE,
+			},
+		},
+	})
+	defineSettingAssociations(repo)
+	systemPath, processorPath := addAssociatedMachine(t, repo, "vm-1", "A0B1")
+	require.NoError(t, repo.Update(systemPath, map[string]interface{}{"EnabledState": uint16(StateStopped)}))
+
+	vm, err := c.FirstVirtualMachineByName("vm-1")
+	require.NoError(t, err)
+	memory, err := vm.computerSystem.GetMemorySettingData()
+	require.NoError(t, err)
+	paths := map[string]string{
+		"Microsoft:A0B1\\processor": processorPath,
+		"Microsoft:A0B1\\memory":    memory.Path(),
+	}
+
+	var (
+		calls      [][]string
+		returnCode uint32
+	)
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "ModifyResourceSettings", func(call *wmiext.MethodCall) error {
+		var modified []string
+		for _, text := range call.InStrings("ResourceSettings") {
+			settings, err := wmiext.DecodeCimXml(text)
+			if err != nil {
+				return err
+			}
+			id, _, _, err := settings.Get("InstanceID")
+			if err != nil {
+				return err
+			}
+			quantity, _, _, err := settings.Get("VirtualQuantity")
+			if err != nil {
+				return err
+			}
+			path := paths[id.(string)]
+			if returnCode == 0 {
+				if err = call.Repository.Update(path, map[string]interface{}{"VirtualQuantity": quantity}); err != nil {
+					return err
+				}
+			}
+			modified = append(modified, path)
+		}
+		calls = append(calls, modified)
+		if returnCode == 0 {
+			call.Out("ResultingResourceSettings", modified)
+		}
+		call.Return(returnCode)
+		return nil
+	})
+
+	// Processor and memory are modified by a single call
+	changes := vm.Changes().SetCpuCoreCount(4).SetMemorySizeMB(512).SetCpuCoreCount(8)
+	assert.Equal(t, 2, changes.Len())
+	results, err := changes.Apply()
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+	assert.ElementsMatch(t, []string{processorPath, memory.Path()}, calls[0])
+	assert.Equal(t, []ChangeResult{
+		{Name: "processor", Path: processorPath},
+		{Name: "memory", Path: memory.Path()},
+	}, results)
+	assert.Equal(t, 8, vm.CpuCoreCount)
+	assert.Equal(t, 512, vm.MemorySizeMB)
+	require.NoError(t, vm.Refresh())
+	assert.Equal(t, 8, vm.CpuCoreCount)
+	assert.Equal(t, 512, vm.MemorySizeMB)
+
+	// A failed call leaves every change unapplied, cached fields included
+	returnCode = 32773
+	results, err = vm.Changes().SetCpuCoreCount(2).SetMemorySizeMB(256).Apply()
+	require.Error(t, err)
+	require.Len(t, results, 2)
+	for _, result := range results {
+		assert.ErrorIs(t, result.Err, err)
+	}
+	assert.Equal(t, 8, vm.CpuCoreCount)
+	assert.Equal(t, 512, vm.MemorySizeMB)
+
+	// Invalid changes are reported before anything is submitted
+	calls = nil
+	changes = vm.Changes().SetCpuCoreCount(0).SetMemorySizeMB(256)
+	assert.Error(t, changes.Err)
+	_, err = changes.Apply()
+	assert.Equal(t, changes.Err, err)
+	assert.Empty(t, calls)
+
+	// Modify goes through a change set as well
+	returnCode = 0
+	ok, err := vm.Modify(WithCpuCoreCount(2), WithMemorySize(2048))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.Len(t, calls, 1)
+	assert.Len(t, calls[0], 2)
+	assert.Equal(t, 2, vm.CpuCoreCount)
+	assert.Equal(t, 2048, vm.MemorySizeMB)
+}
+
+func TestChangeSet_ApplyTwice(t *testing.T) {
+	c, repo := newTestClient(t)
+	settings := wmiext.CIM_STRING | wmiext.CIM_FLAG_ARRAY
+	resulting := wmiext.CIM_REFERENCE | wmiext.CIM_FLAG_ARRAY
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_VirtualSystemManagementService",
+		Keys: []string{"CreationClassName", "Name"},
+		Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+			"ModifyResourceSettings": {"ResourceSettings": settings, "ResultingResourceSettings": resulting, "Job": wmiext.CIM_REFERENCE},
+			"AddFeatureSettings": {
+				"AffectedConfiguration": wmiext.CIM_REFERENCE, "FeatureSettings": settings,
+				"ResultingFeatureSettings": resulting, "Job": wmiext.CIM_REFERENCE,
+			},
+			"ModifyFeatureSettings": {"FeatureSettings": settings, "ResultingFeatureSettings": resulting, "Job": wmiext.CIM_REFERENCE},
+		},
+	})
+	repo.DefineClass(wmiext.MemoryClass{Name: "Msvm_EthernetPortAllocationSettingData", Keys: []string{"InstanceID"}})
+	repo.DefineClass(wmiext.MemoryClass{Name: "Msvm_EthernetSwitchPortVlanSettingData", Keys: []string{"InstanceID"}})
+	defineSettingAssociations(repo)
+	_, processorPath := addAssociatedMachine(t, repo, "vm-1", "A0B1")
+	portPath, err := repo.AddInstance("Msvm_EthernetPortAllocationSettingData", map[string]interface{}{"InstanceID": `Microsoft:A0B1\port`})
+	require.NoError(t, err)
+	templatePath, err := repo.AddInstance("Msvm_EthernetSwitchPortVlanSettingData", map[string]interface{}{"InstanceID": `Microsoft:Definition\vlan`})
+	require.NoError(t, err)
+
+	calls := map[string]int{}
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "ModifyResourceSettings", func(call *wmiext.MethodCall) error {
+		calls["ModifyResourceSettings"]++
+		call.Out("ResultingResourceSettings", []string{processorPath})
+		call.Return(0)
+		return nil
+	})
+	var added []string
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "AddFeatureSettings", func(call *wmiext.MethodCall) error {
+		calls["AddFeatureSettings"]++
+		path, err := call.Repository.AddInstance("Msvm_EthernetSwitchPortVlanSettingData", map[string]interface{}{
+			"InstanceID": fmt.Sprintf(`Microsoft:A0B1\port\vlan%d`, len(added)),
+		})
+		if err != nil {
+			return err
+		}
+		added = append(added, path)
+		call.Out("ResultingFeatureSettings", []string{path})
+		call.Return(0)
+		return nil
+	})
+	var modified []string
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "ModifyFeatureSettings", func(call *wmiext.MethodCall) error {
+		calls["ModifyFeatureSettings"]++
+		for _, text := range call.InStrings("FeatureSettings") {
+			settings, err := wmiext.DecodeCimXml(text)
+			if err != nil {
+				return err
+			}
+			id, _, _, err := settings.Get("InstanceID")
+			if err != nil {
+				return err
+			}
+			modified = append(modified, id.(string))
+		}
+		call.Out("ResultingFeatureSettings", added)
+		call.Return(0)
+		return nil
+	})
+
+	vm, err := c.FirstVirtualMachineByName("vm-1")
+	require.NoError(t, err)
+	changes := vm.Changes().SetCpuCoreCount(4)
+	vlan := func(id uint16) {
+		changes.feature("lan vlan", "", portPath, func(session *wmiext.Service) (*wmiext.Instance, error) {
+			return session.GetObject(templatePath)
+		}).set("AccessVlanId", id)
+	}
+	vlan(12)
+	_, err = changes.Apply()
+	require.NoError(t, err)
+	require.Len(t, added, 1)
+
+	// Applying again skips the applied changes instead of adding the feature twice
+	results, err := changes.Apply()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"ModifyResourceSettings": 1, "AddFeatureSettings": 1}, calls)
+	assert.Equal(t, []ChangeResult{
+		{Name: "processor", Path: processorPath},
+		{Name: "lan vlan", Path: added[0]},
+	}, results)
+
+	// A feature changed after being added is modified
+	vlan(13)
+	_, err = changes.Apply()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"ModifyResourceSettings": 1, "AddFeatureSettings": 1, "ModifyFeatureSettings": 1}, calls)
+	assert.Equal(t, []string{`Microsoft:A0B1\port\vlan0`}, modified)
+}
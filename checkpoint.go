@@ -0,0 +1,275 @@
+package hyperv
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+// CheckpointType 检查点类型
+type CheckpointType = virtual_system.UserSnapshotType
+
+const (
+	// CheckpointStandard 标准检查点, 保存虚拟机的磁盘、内存与设备状态
+	CheckpointStandard CheckpointType = virtual_system.UserSnapshotType_Standard
+	// CheckpointProduction 生产检查点, 由来宾操作系统的备份技术创建, 无法创建时回退为标准检查点
+	CheckpointProduction CheckpointType = virtual_system.UserSnapshotType_Production
+	// CheckpointProductionOnly 仅生产检查点, 无法创建时返回错误
+	CheckpointProductionOnly CheckpointType = virtual_system.UserSnapshotType_ProductionOnly
+)
+
+// Checkpoint 虚拟机检查点 (快照)
+//
+// ListCheckpoints 返回的检查点组成树, 子检查点基于父检查点创建
+type Checkpoint struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	CreationTime time.Time `json:"creation_time"`
+	// Automatic 是否为自动检查点
+	Automatic bool `json:"automatic"`
+	// Current 虚拟机当前是否基于该检查点运行, 即新检查点的父检查点
+	Current  bool          `json:"current"`
+	Parent   *Checkpoint   `json:"-"`
+	Children []*Checkpoint `json:"children,omitempty"`
+
+	settings *virtual_system.VirtualSystemSettingData
+}
+
+func newCheckpoint(settings *virtual_system.VirtualSystemSettingData) *Checkpoint {
+	return &Checkpoint{
+		ID:           settings.InstanceID,
+		Name:         settings.ElementName,
+		CreationTime: settings.CreationTime,
+		Automatic:    settings.IsAutomaticSnapshot,
+		settings:     settings,
+	}
+}
+
+// checkpointSettings returns the settings of a checkpoint of the virtual machine.
+func (vm *VirtualMachine) checkpointSettings(checkpoint *Checkpoint) (*virtual_system.VirtualSystemSettingData, error) {
+	if checkpoint == nil || checkpoint.settings == nil {
+		return nil, errors.New("checkpoint not obtained from the virtual machine")
+	}
+	if checkpoint.settings.VirtualSystemIdentifier != vm.computerSystem.Name {
+		return nil, errors.Errorf("checkpoint [%s] does not belong to virtual machine [%s]", checkpoint.Name, vm.Name)
+	}
+	return checkpoint.settings, nil
+}
+
+// CreateCheckpoint 创建检查点
+//
+// 参数:
+//
+//	name: 检查点名称, 为空时使用 Hyper-V 生成的名称
+//	checkpointType: 检查点类型, 仅用于本次创建, 虚拟机的检查点类型设置保持不变
+//	opts: 任务选项, 如 wmiext.WithProgress 接收创建进度
+//
+// 返回:
+//
+//	*Checkpoint: 新建的检查点, 不包含父子关系, 需要时使用 ListCheckpoints
+//	error: 错误
+func (vm *VirtualMachine) CreateCheckpoint(name string, checkpointType CheckpointType, opts ...wmiext.JobOption) (checkpoint *Checkpoint, err error) {
+	vsss, err := virtualSystemSnapshotService(vm.client)
+	if err != nil {
+		return nil, err
+	}
+	vsms, err := virtualSystemManagementService(vm.client)
+	if err != nil {
+		return nil, err
+	}
+
+	// The type of the checkpoints taken is a setting of the virtual machine, restored afterward
+	settings, err := vm.computerSystem.GetVirtualSystemSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer settings.Close()
+	if previous := CheckpointType(settings.UserSnapshotType); previous != checkpointType {
+		if err = setCheckpointType(vsms, settings, checkpointType); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if restoreErr := setCheckpointType(vsms, settings, previous); err == nil {
+				err = restoreErr
+			}
+		}()
+	}
+
+	snapshot, err := wmiext.Await(vsss.CreateSnapshot(vm.computerSystem, virtual_system.SnapshotType_Full, opts...))
+	if err != nil {
+		return nil, err
+	}
+	checkpoint = newCheckpoint(snapshot)
+	if name != "" {
+		if err = vm.RenameCheckpoint(checkpoint, name); err != nil {
+			return checkpoint, err
+		}
+	}
+	vm.invalidate(FieldsDisks)
+	return checkpoint, nil
+}
+
+func setCheckpointType(
+	vsms *virtual_system.VirtualSystemManagementService,
+	settings *virtual_system.VirtualSystemSettingData,
+	checkpointType CheckpointType,
+) error {
+	if err := settings.Put("UserSnapshotType", uint16(checkpointType)); err != nil {
+		return err
+	}
+	if err := wmiext.AwaitJob(vsms.ModifySystemSettings(settings.GetCimText())); err != nil {
+		return err
+	}
+	settings.UserSnapshotType = uint16(checkpointType)
+	return nil
+}
+
+// ListCheckpoints 获取虚拟机的检查点树
+//
+// 返回:
+//
+//	[]*Checkpoint: 根检查点, 子检查点见 Checkpoint.Children, 同级检查点按创建时间排序
+//	error: 错误
+func (vm *VirtualMachine) ListCheckpoints() ([]*Checkpoint, error) {
+	snapshots, err := vm.computerSystem.GetSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := vm.computerSystem.GetVirtualSystemSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer settings.Close()
+
+	checkpoints := make(map[string]*Checkpoint, len(snapshots))
+	for _, snapshot := range snapshots {
+		checkpoints[objectpath.Normalize(snapshot.Path())] = newCheckpoint(snapshot)
+	}
+	var roots []*Checkpoint
+	for _, checkpoint := range checkpoints {
+		parent, ok := checkpoints[objectpath.Normalize(checkpoint.settings.Parent)]
+		if checkpoint.settings.Parent == "" || !ok {
+			roots = append(roots, checkpoint)
+			continue
+		}
+		checkpoint.Parent = parent
+		parent.Children = append(parent.Children, checkpoint)
+	}
+	if current, ok := checkpoints[objectpath.Normalize(settings.Parent)]; settings.Parent != "" && ok {
+		current.Current = true
+	}
+
+	for _, checkpoint := range checkpoints {
+		sortCheckpoints(checkpoint.Children)
+	}
+	sortCheckpoints(roots)
+	return roots, nil
+}
+
+func sortCheckpoints(checkpoints []*Checkpoint) {
+	sort.Slice(checkpoints, func(i, j int) bool {
+		if !checkpoints[i].CreationTime.Equal(checkpoints[j].CreationTime) {
+			return checkpoints[i].CreationTime.Before(checkpoints[j].CreationTime)
+		}
+		return checkpoints[i].ID < checkpoints[j].ID
+	})
+}
+
+// FindCheckpoint 根据名称获取检查点, 同名时返回最早创建的检查点
+func (vm *VirtualMachine) FindCheckpoint(name string) (*Checkpoint, error) {
+	roots, err := vm.ListCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	var found *Checkpoint
+	var walk func(checkpoints []*Checkpoint)
+	walk = func(checkpoints []*Checkpoint) {
+		for _, checkpoint := range checkpoints {
+			if checkpoint.Name == name && (found == nil || checkpoint.CreationTime.Before(found.CreationTime)) {
+				found = checkpoint
+			}
+			walk(checkpoint.Children)
+		}
+	}
+	walk(roots)
+	if found == nil {
+		return nil, errors.Wrapf(wmiext.NotFound, "checkpoint [%s] of virtual machine [%s]", name, vm.Name)
+	}
+	return found, nil
+}
+
+// ApplyCheckpoint 将虚拟机恢复到检查点, 虚拟机需要处于停止或已保存状态
+func (vm *VirtualMachine) ApplyCheckpoint(checkpoint *Checkpoint, opts ...wmiext.JobOption) error {
+	settings, err := vm.checkpointSettings(checkpoint)
+	if err != nil {
+		return err
+	}
+	vsss, err := virtualSystemSnapshotService(vm.client)
+	if err != nil {
+		return err
+	}
+	if err = wmiext.AwaitJob(vsss.ApplySnapshot(settings, opts...)); err != nil {
+		return err
+	}
+	vm.invalidate(FieldsAll)
+	return nil
+}
+
+// RenameCheckpoint 重命名检查点
+func (vm *VirtualMachine) RenameCheckpoint(checkpoint *Checkpoint, name string, opts ...wmiext.JobOption) error {
+	settings, err := vm.checkpointSettings(checkpoint)
+	if err != nil {
+		return err
+	}
+	vsms, err := virtualSystemManagementService(vm.client)
+	if err != nil {
+		return err
+	}
+	if err = settings.Put("ElementName", name); err != nil {
+		return err
+	}
+	if err = wmiext.AwaitJob(vsms.ModifySystemSettings(settings.GetCimText(), opts...)); err != nil {
+		return err
+	}
+	settings.ElementName = name
+	checkpoint.Name = name
+	return nil
+}
+
+// DeleteCheckpoint 删除检查点, 其子检查点成为其父检查点的子检查点, 磁盘差异在后台合并
+func (vm *VirtualMachine) DeleteCheckpoint(checkpoint *Checkpoint, opts ...wmiext.JobOption) error {
+	settings, err := vm.checkpointSettings(checkpoint)
+	if err != nil {
+		return err
+	}
+	vsss, err := virtualSystemSnapshotService(vm.client)
+	if err != nil {
+		return err
+	}
+	if err = wmiext.AwaitJob(vsss.DestroySnapshot(settings, opts...)); err != nil {
+		return err
+	}
+	vm.invalidate(FieldsDisks)
+	return nil
+}
+
+// DeleteCheckpointSubtree 删除检查点及其全部子检查点
+func (vm *VirtualMachine) DeleteCheckpointSubtree(checkpoint *Checkpoint, opts ...wmiext.JobOption) error {
+	settings, err := vm.checkpointSettings(checkpoint)
+	if err != nil {
+		return err
+	}
+	vsss, err := virtualSystemSnapshotService(vm.client)
+	if err != nil {
+		return err
+	}
+	if err = wmiext.AwaitJob(vsss.DestroySnapshotTree(settings, opts...)); err != nil {
+		return err
+	}
+	vm.invalidate(FieldsDisks)
+	return nil
+}
@@ -0,0 +1,309 @@
+package hyperv
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snapshotHost keeps the snapshots of the machines of a repository like Hyper-V does: each one is
+// the child of the snapshot the machine was running on, which it replaces.
+type snapshotHost struct {
+	t    *testing.T
+	repo *wmiext.MemoryRepository
+	// paths are the paths of the system settings, by InstanceID
+	paths map[string]string
+	// types are the UserSnapshotType of the machine when each snapshot was taken
+	types   []uint16
+	created int
+}
+
+func newSnapshotHost(t *testing.T, repo *wmiext.MemoryRepository) *snapshotHost {
+	host := &snapshotHost{t: t, repo: repo, paths: map[string]string{}}
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_VirtualSystemManagementService",
+		Keys: []string{"CreationClassName", "Name"},
+		Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+			"ModifySystemSettings": {"SystemSettings": wmiext.CIM_STRING, "Job": wmiext.CIM_REFERENCE},
+		},
+	})
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_VirtualSystemSnapshotService",
+		Keys: []string{"CreationClassName", "Name"},
+		Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+			"CreateSnapshot": {
+				"AffectedSystem": wmiext.CIM_REFERENCE, "SnapshotSettings": wmiext.CIM_STRING, "SnapshotType": wmiext.CIM_UINT16,
+				"ResultingSnapshot": wmiext.CIM_REFERENCE, "Job": wmiext.CIM_REFERENCE,
+			},
+			"ApplySnapshot":       {"Snapshot": wmiext.CIM_REFERENCE, "Job": wmiext.CIM_REFERENCE},
+			"DestroySnapshot":     {"AffectedSnapshot": wmiext.CIM_REFERENCE, "Job": wmiext.CIM_REFERENCE},
+			"DestroySnapshotTree": {"SnapshotSettingData": wmiext.CIM_REFERENCE, "Job": wmiext.CIM_REFERENCE},
+		},
+	})
+	_, err := repo.AddInstance("Msvm_VirtualSystemSnapshotService", map[string]interface{}{
+		"CreationClassName": "Msvm_VirtualSystemSnapshotService", "Name": "vssd",
+	})
+	require.NoError(t, err)
+
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "ModifySystemSettings", host.modify)
+	repo.HandleMethod("Msvm_VirtualSystemSnapshotService", "CreateSnapshot", host.create)
+	repo.HandleMethod("Msvm_VirtualSystemSnapshotService", "ApplySnapshot", func(call *wmiext.MethodCall) error {
+		call.Return(0)
+		return host.setParent(host.realized(), call.InString("Snapshot"))
+	})
+	repo.HandleMethod("Msvm_VirtualSystemSnapshotService", "DestroySnapshot", func(call *wmiext.MethodCall) error {
+		call.Return(0)
+		return host.destroy(call.InString("AffectedSnapshot"), false)
+	})
+	repo.HandleMethod("Msvm_VirtualSystemSnapshotService", "DestroySnapshotTree", func(call *wmiext.MethodCall) error {
+		call.Return(0)
+		return host.destroy(call.InString("SnapshotSettingData"), true)
+	})
+	return host
+}
+
+func (h *snapshotHost) get(path string, name string) interface{} {
+	object, err := h.repo.Get(path)
+	require.NoError(h.t, err)
+	value, _, _, err := object.Get(name)
+	require.NoError(h.t, err)
+	return value
+}
+
+func (h *snapshotHost) realized() string {
+	return h.paths["Microsoft:A0B1"]
+}
+
+func (h *snapshotHost) setParent(path string, parent string) error {
+	return h.repo.Update(path, map[string]interface{}{"Parent": parent})
+}
+
+func (h *snapshotHost) modify(call *wmiext.MethodCall) error {
+	settings, err := wmiext.DecodeCimXml(call.InString("SystemSettings"))
+	if err != nil {
+		return err
+	}
+	properties := map[string]interface{}{}
+	for _, name := range []string{"ElementName", "UserSnapshotType"} {
+		if value, _, _, err := settings.Get(name); err == nil && value != nil {
+			properties[name] = value
+		}
+	}
+	id, _, _, err := settings.Get("InstanceID")
+	if err != nil {
+		return err
+	}
+	call.Return(0)
+	return h.repo.Update(h.paths[id.(string)], properties)
+}
+
+func (h *snapshotHost) create(call *wmiext.MethodCall) error {
+	// The numbers are held as automation values, like by COM
+	snapshotType, _ := h.get(h.realized(), "UserSnapshotType").(int32)
+	h.types = append(h.types, uint16(snapshotType))
+	h.created++
+	id := fmt.Sprintf("Microsoft:SNAPSHOT-%d", h.created)
+	path, err := h.repo.AddInstance("Msvm_VirtualSystemSettingData", map[string]interface{}{
+		"InstanceID":              id,
+		"ElementName":             fmt.Sprintf("vm-1 - (%d)", h.created),
+		"VirtualSystemIdentifier": "A0B1",
+		"VirtualSystemType":       "Microsoft:Hyper-V:Snapshot:Realized",
+		"CreationTime":            time.Date(2026, 1, h.created, 0, 0, 0, 0, time.UTC),
+		"Parent":                  h.get(h.realized(), "Parent"),
+	})
+	if err != nil {
+		return err
+	}
+	h.paths[id] = path
+	call.Out("ResultingSnapshot", path)
+
+	// Taking a snapshot completes asynchronously
+	job, err := h.repo.AddInstance("Msvm_ConcreteJob", map[string]interface{}{
+		"InstanceID": fmt.Sprintf("job-%d", h.created), "JobState": uint16(wmiext.JobStateCompleted), "PercentComplete": uint16(100),
+	})
+	if err != nil {
+		return err
+	}
+	call.Out("Job", wmiext.Reference(job))
+	call.Return(4096)
+	return h.setParent(h.realized(), path)
+}
+
+func (h *snapshotHost) destroy(path string, tree bool) error {
+	parent, _ := h.get(path, "Parent").(string)
+	for id, child := range h.paths {
+		childParent, _ := h.get(child, "Parent").(string)
+		if childParent == "" || !objectpath.Equal(childParent, path) {
+			continue
+		}
+		if id == "Microsoft:A0B1" || !tree {
+			if err := h.setParent(child, parent); err != nil {
+				return err
+			}
+		} else if err := h.destroy(child, true); err != nil {
+			return err
+		}
+	}
+	for id, candidate := range h.paths {
+		if objectpath.Equal(candidate, path) {
+			delete(h.paths, id)
+		}
+	}
+	return h.repo.Delete(path)
+}
+
+func checkpointNames(checkpoints []*Checkpoint) []string {
+	var names []string
+	for _, checkpoint := range checkpoints {
+		names = append(names, checkpoint.Name)
+	}
+	return names
+}
+
+func TestVirtualMachine_Checkpoints(t *testing.T) {
+	c, repo := newTestClient(t)
+	defineSettingAssociations(repo)
+	host := newSnapshotHost(t, repo)
+	systemPath, _ := addAssociatedMachine(t, repo, "vm-1", "A0B1")
+	settings, err := c.Session().FindFirstRelatedInstance(systemPath, "Msvm_VirtualSystemSettingData")
+	require.NoError(t, err)
+	host.paths["Microsoft:A0B1"], err = settings.Path()
+	require.NoError(t, err)
+	require.NoError(t, repo.Update(host.realized(), map[string]interface{}{"UserSnapshotType": uint16(CheckpointProduction)}))
+
+	vm, err := c.FirstVirtualMachineByName("vm-1")
+	require.NoError(t, err)
+	checkpoints, err := vm.ListCheckpoints()
+	require.NoError(t, err)
+	assert.Empty(t, checkpoints)
+
+	// The type is only changed for the checkpoint being taken
+	var progress []uint16
+	base, err := vm.CreateCheckpoint("base", CheckpointStandard, wmiext.WithProgress(func(status wmiext.JobStatus) {
+		progress = append(progress, status.PercentComplete)
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "base", base.Name)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), base.CreationTime.UTC())
+	assert.Equal(t, int32(CheckpointProduction), host.get(host.realized(), "UserSnapshotType"))
+	assert.NotEmpty(t, progress)
+	_, err = vm.CreateCheckpoint("", CheckpointProduction)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{uint16(CheckpointStandard), uint16(CheckpointProduction)}, host.types)
+
+	// Checkpoints taken after applying an earlier one are its children as well
+	require.NoError(t, vm.ApplyCheckpoint(base))
+	_, err = vm.CreateCheckpoint("patched", CheckpointStandard)
+	require.NoError(t, err)
+
+	checkpoints, err = vm.ListCheckpoints()
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 1)
+	root := checkpoints[0]
+	assert.Equal(t, "base", root.Name)
+	assert.Nil(t, root.Parent)
+	assert.False(t, root.Current)
+	assert.Equal(t, []string{"vm-1 - (2)", "patched"}, checkpointNames(root.Children))
+	assert.Same(t, root, root.Children[1].Parent)
+	assert.True(t, root.Children[1].Current)
+
+	found, err := vm.FindCheckpoint("vm-1 - (2)")
+	require.NoError(t, err)
+	require.NoError(t, vm.RenameCheckpoint(found, "upgraded"))
+	assert.Equal(t, "upgraded", found.Name)
+	_, err = vm.FindCheckpoint("vm-1 - (2)")
+	assert.ErrorIs(t, err, wmiext.NotFound)
+
+	// Deleting a checkpoint attaches its children to its parent
+	found, err = vm.FindCheckpoint("base")
+	require.NoError(t, err)
+	require.NoError(t, vm.DeleteCheckpoint(found))
+	checkpoints, err = vm.ListCheckpoints()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"upgraded", "patched"}, checkpointNames(checkpoints))
+	assert.True(t, checkpoints[1].Current)
+
+	require.NoError(t, vm.DeleteCheckpointSubtree(checkpoints[0]))
+	checkpoints, err = vm.ListCheckpoints()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"patched"}, checkpointNames(checkpoints))
+
+	// Checkpoints only apply to their virtual machine
+	other := &Checkpoint{Name: "other"}
+	assert.Error(t, vm.ApplyCheckpoint(other))
+	checkpoints[0].settings.VirtualSystemIdentifier = "C2D3"
+	assert.Error(t, vm.DeleteCheckpoint(checkpoints[0]))
+}
+
+func TestVirtualHardDisk_ResizeChain(t *testing.T) {
+	c, repo := newTestClient(t)
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_ImageManagementService",
+		Keys: []string{"CreationClassName", "Name"},
+		Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+			"GetVirtualHardDiskSettingData": {"Path": wmiext.CIM_STRING, "SettingData": wmiext.CIM_STRING, "Job": wmiext.CIM_REFERENCE},
+			"ResizeVirtualHardDisk":         {"Path": wmiext.CIM_STRING, "MaxInternalSize": wmiext.CIM_UINT64, "Job": wmiext.CIM_REFERENCE},
+		},
+	})
+	parents := map[string]string{
+		`C:\vms\base.vhdx`:           "",
+		`C:\vms\base_6F1D3A0B.avhdx`: `C:\vms\base.vhdx`,
+		`C:\vms\base_9C2E4B1D.avhdx`: `C:\vms\base_6F1D3A0B.avhdx`,
+		`C:\vms\data.vhdx`:           "",
+	}
+	var read int
+	repo.HandleMethod("Msvm_ImageManagementService", "GetVirtualHardDiskSettingData", func(call *wmiext.MethodCall) error {
+		read++
+		path := call.InString("Path")
+		parent, ok := parents[path]
+		if !ok {
+			call.Return(32779)
+			return nil
+		}
+		call.Out("SettingData", fmt.Sprintf(`<INSTANCE CLASSNAME="Msvm_VirtualHardDiskSettingData">`+
+			`<PROPERTY NAME="Path" TYPE="string"><VALUE>%s</VALUE></PROPERTY>`+
+			`<PROPERTY NAME="ParentPath" TYPE="string"><VALUE>%s</VALUE></PROPERTY>`+
+			`</INSTANCE>`, path, parent))
+		call.Return(0)
+		return nil
+	})
+	var resized []string
+	repo.HandleMethod("Msvm_ImageManagementService", "ResizeVirtualHardDisk", func(call *wmiext.MethodCall) error {
+		resized = append(resized, call.InString("Path"))
+		call.Return(0)
+		return nil
+	})
+	// The machine runs on the second checkpoint, whose settings reference the first differencing disk
+	for i, path := range []string{`C:\vms\base_9C2E4B1D.avhdx`, `C:\vms\base_6F1D3A0B.avhdx`, `C:\vms\data.vhdx`} {
+		_, err := repo.AddInstance("Msvm_StorageAllocationSettingData", map[string]interface{}{
+			"InstanceID": fmt.Sprintf(`Microsoft:A0B1\disk-%d`, i), "ResourceType": uint16(31), "HostResource": []string{path},
+		})
+		require.NoError(t, err)
+	}
+
+	ok, err := (&VirtualHardDisk{Path: `C:\vms\base_9C2E4B1D.avhdx`, client: c}).Resize(20)
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, ErrVirtualHardDiskChain)
+	assert.Contains(t, err.Error(), `differencing disk of C:\vms\base_6F1D3A0B.avhdx`)
+
+	read = 0
+	_, err = (&VirtualHardDisk{Path: `C:\vms\base.vhdx`, client: c}).Resize(20)
+	assert.ErrorIs(t, err, ErrVirtualHardDiskChain)
+	assert.Contains(t, err.Error(), `parent of C:\vms\base_9C2E4B1D.avhdx`)
+	// The parents shared by the chains are read once
+	assert.Equal(t, 1+3, read)
+	assert.Empty(t, resized)
+
+	ok, err = (&VirtualHardDisk{Path: `C:\vms\data.vhdx`, client: c}).Resize(20)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{`C:\vms\data.vhdx`}, resized)
+
+	_, err = (&VirtualHardDisk{Path: `C:\vms\missing.vhdx`, client: c}).Resize(20)
+	assert.ErrorIs(t, err, ErrFileNotFound)
+}
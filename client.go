@@ -0,0 +1,246 @@
+package hyperv
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking/networking_service"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage"
+	utils "github.com/rokukoo/hyperv/pkg/hypervsdk/utils"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+// ErrClientClosed is returned by the operations of a closed Client.
+var ErrClientClosed = errors.New("hyperv client is closed")
+
+// Client performs Hyper-V operations over a single WMI session. The management services are
+// retrieved once on first use and shared by every operation until Close. A Client is safe for
+// concurrent use.
+type Client struct {
+	session *wmiext.Service
+	// owned is set when the session was opened by the client and is closed along with it.
+	owned bool
+
+	mu     sync.Mutex
+	closed bool
+	vsms   *virtual_system.VirtualSystemManagementService
+	vesms  *networking_service.VirtualEthernetSwitchManagementService
+	ims    *storage.ImageManagementService
+	vsss   *virtual_system.VirtualSystemSnapshotService
+}
+
+// NewClient connects to the Hyper-V namespace of the local host.
+func NewClient() (*Client, error) {
+	session, err := utils.NewLocalHyperVService()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{session: session, owned: true}, nil
+}
+
+// NewClientWithService returns a client using session, which remains owned by the caller and must
+// outlive the client.
+func NewClientWithService(session *wmiext.Service) *Client {
+	return &Client{session: session}
+}
+
+// WithArena runs fn with a client sharing the session of c, whose WMI instances are owned by an
+// arena and released together when fn returns. The objects obtained through the scoped client,
+// such as virtual machines, must not be used afterward. Long-running programs use it to bound the
+// memory held by each unit of work.
+func (c *Client) WithArena(fn func(*Client) error) error {
+	session, err := c.service()
+	if err != nil {
+		return err
+	}
+	scoped := &Client{session: session.Scope(wmiext.NewArena()), owned: true}
+	defer func() { _ = scoped.Close() }()
+	return fn(scoped)
+}
+
+// Session returns the WMI session of the client, which must not be used after Close.
+func (c *Client) Session() *wmiext.Service {
+	return c.session
+}
+
+// SetTelemetry sets the tracer, meter and logger receiving the WMI operations of the client.
+func (c *Client) SetTelemetry(telemetry *wmiext.Telemetry) {
+	c.session.SetTelemetry(telemetry)
+}
+
+// VirtualSystemManagementService returns the virtual system management service of the client.
+func (c *Client) VirtualSystemManagementService() (*virtual_system.VirtualSystemManagementService, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if c.vsms == nil {
+		vsms, err := virtual_system.NewVirtualSystemManagementService(c.session)
+		if err != nil {
+			return nil, err
+		}
+		c.vsms = vsms
+	}
+	return c.vsms, nil
+}
+
+// VirtualEthernetSwitchManagementService returns the virtual switch management service of the
+// client.
+func (c *Client) VirtualEthernetSwitchManagementService() (*networking_service.VirtualEthernetSwitchManagementService, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if c.vesms == nil {
+		vesms, err := networking_service.NewVirtualEthernetSwitchManagementService(c.session)
+		if err != nil {
+			return nil, err
+		}
+		c.vesms = vesms
+	}
+	return c.vesms, nil
+}
+
+// ImageManagementService returns the image management service of the client.
+func (c *Client) ImageManagementService() (*storage.ImageManagementService, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if c.ims == nil {
+		ims, err := storage.NewImageManagementService(c.session)
+		if err != nil {
+			return nil, err
+		}
+		c.ims = ims
+	}
+	return c.ims, nil
+}
+
+// VirtualSystemSnapshotService returns the checkpoint service of the client.
+func (c *Client) VirtualSystemSnapshotService() (*virtual_system.VirtualSystemSnapshotService, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if c.vsss == nil {
+		vsss, err := virtual_system.NewVirtualSystemSnapshotService(c.session)
+		if err != nil {
+			return nil, err
+		}
+		c.vsss = vsss
+	}
+	return c.vsss, nil
+}
+
+// Close releases the management services and the session opened by the client. The objects
+// returned by the client must not be used afterward.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.vsms != nil {
+		c.vsms.Close()
+		c.vsms = nil
+	}
+	if c.vesms != nil {
+		c.vesms.Close()
+		c.vesms = nil
+	}
+	if c.ims != nil {
+		c.ims.Close()
+		c.ims = nil
+	}
+	if c.vsss != nil {
+		c.vsss.Close()
+		c.vsss = nil
+	}
+	if c.owned {
+		c.session.Close()
+	}
+	return nil
+}
+
+var (
+	defaultClientMu sync.Mutex
+	defaultClient   *Client
+)
+
+// DefaultClient returns the client used by the package-level functions, connecting to the local
+// host on first use.
+func DefaultClient() (*Client, error) {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+	if defaultClient != nil {
+		defaultClient.mu.Lock()
+		closed := defaultClient.closed
+		defaultClient.mu.Unlock()
+		if !closed {
+			return defaultClient, nil
+		}
+	}
+	c, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	defaultClient = c
+	return c, nil
+}
+
+// clientOr returns c, or the default client for objects created without one.
+func clientOr(c *Client) (*Client, error) {
+	if c != nil {
+		return c, nil
+	}
+	return DefaultClient()
+}
+
+// service returns the session of the client, unless closed.
+func (c *Client) service() (*wmiext.Service, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	return c.session, nil
+}
+
+func virtualSystemManagementService(c *Client) (*virtual_system.VirtualSystemManagementService, error) {
+	c, err := clientOr(c)
+	if err != nil {
+		return nil, err
+	}
+	return c.VirtualSystemManagementService()
+}
+
+func virtualEthernetSwitchManagementService(c *Client) (*networking_service.VirtualEthernetSwitchManagementService, error) {
+	c, err := clientOr(c)
+	if err != nil {
+		return nil, err
+	}
+	return c.VirtualEthernetSwitchManagementService()
+}
+
+func imageManagementService(c *Client) (*storage.ImageManagementService, error) {
+	c, err := clientOr(c)
+	if err != nil {
+		return nil, err
+	}
+	return c.ImageManagementService()
+}
+
+func virtualSystemSnapshotService(c *Client) (*virtual_system.VirtualSystemSnapshotService, error) {
+	c, err := clientOr(c)
+	if err != nil {
+		return nil, err
+	}
+	return c.VirtualSystemSnapshotService()
+}
@@ -0,0 +1,121 @@
+package hyperv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/retry"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*Client, *wmiext.MemoryRepository) {
+	repo := wmiext.NewMemoryRepository(`root\virtualization\v2`)
+	for _, class := range []string{
+		"Msvm_VirtualSystemManagementService",
+		"Msvm_VirtualEthernetSwitchManagementService",
+		"Msvm_ImageManagementService",
+	} {
+		repo.DefineClass(wmiext.MemoryClass{
+			Name: class,
+			Keys: []string{"CreationClassName", "Name"},
+			Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+				"DestroySystem": {"AffectedSystem": wmiext.CIM_REFERENCE, "Job": wmiext.CIM_REFERENCE},
+			},
+		})
+		_, err := repo.AddInstance(class, map[string]interface{}{"CreationClassName": class, "Name": "vmms", "SystemName": "HOST"})
+		require.NoError(t, err)
+	}
+	repo.DefineClass(wmiext.MemoryClass{Name: "Msvm_VirtualEthernetSwitch", Keys: []string{"CreationClassName", "Name"}})
+
+	session := repo.Service()
+	t.Cleanup(session.Close)
+	c := NewClientWithService(session)
+	t.Cleanup(func() { _ = c.Close() })
+	return c, repo
+}
+
+func TestClient_Services(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	vsms, err := c.VirtualSystemManagementService()
+	require.NoError(t, err)
+	again, err := c.VirtualSystemManagementService()
+	require.NoError(t, err)
+	assert.Same(t, vsms, again)
+	assert.Same(t, c.Session(), vsms.Session)
+
+	vesms, err := c.VirtualEthernetSwitchManagementService()
+	require.NoError(t, err)
+	assert.Same(t, c.Session(), vesms.Con)
+
+	ims, err := c.ImageManagementService()
+	require.NoError(t, err)
+	assert.Same(t, c.Session(), ims.Session)
+
+	require.NoError(t, c.Close())
+	require.NoError(t, c.Close())
+	_, err = c.VirtualSystemManagementService()
+	assert.ErrorIs(t, err, ErrClientClosed)
+	_, err = c.FirstVirtualSwitchByName("external")
+	assert.ErrorIs(t, err, ErrClientClosed)
+	_, err = c.ListAvailablePhysicalNetworkAdapters()
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
+
+func TestClient_DeleteVirtualSwitchByName(t *testing.T) {
+	c, repo := newTestClient(t)
+	switchPath, err := repo.AddInstance("Msvm_VirtualEthernetSwitch", map[string]interface{}{
+		"CreationClassName": "Msvm_VirtualEthernetSwitch",
+		"Name":              "5C2E0C8A",
+		"ElementName":       "external",
+	})
+	require.NoError(t, err)
+
+	var destroyed []string
+	repo.HandleMethod("Msvm_VirtualEthernetSwitchManagementService", "DestroySystem", func(call *wmiext.MethodCall) error {
+		destroyed = append(destroyed, call.InString("AffectedSystem"))
+		if len(destroyed) == 1 {
+			// The switch is still in use by the first call
+			call.Return(32775)
+			return nil
+		}
+		call.Return(0)
+		return call.Repository.Delete(switchPath)
+	})
+
+	vesms, err := c.VirtualEthernetSwitchManagementService()
+	require.NoError(t, err)
+	vesms.Retry.Default = &retry.Policy{Backoff: wmiext.ConstantPoll(time.Millisecond), MaxAttempts: 3}
+
+	require.NoError(t, c.DeleteVirtualSwitchByName("external"))
+	require.Len(t, destroyed, 2)
+	assert.True(t, objectpath.Equal(switchPath, destroyed[1]))
+
+	_, err = c.FirstVirtualSwitchByName("external")
+	assert.ErrorIs(t, err, wmiext.NotFound)
+}
+
+func TestClient_WithArena(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	var arena *wmiext.Arena
+	err := c.WithArena(func(scoped *Client) error {
+		arena = scoped.Session().Arena()
+		_, err := scoped.VirtualSystemManagementService()
+		require.NoError(t, err)
+		assert.Equal(t, 1, arena.Len())
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Zero(t, arena.Len())
+	assert.Nil(t, c.Session().Arena())
+
+	// The session of the client outlives the scoped one
+	vsms, err := c.VirtualSystemManagementService()
+	require.NoError(t, err)
+	_, err = vsms.GetAsString("Name")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,742 @@
+package hyperv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/memory"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking/switch_extension"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/processor"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/resource"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system/host"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+// CloneDiskMode 克隆虚拟硬盘的方式
+type CloneDiskMode int
+
+const (
+	// CloneFullCopy 完整复制虚拟硬盘, 差异磁盘链合并为一个独立的动态磁盘
+	CloneFullCopy CloneDiskMode = iota
+	// CloneLinked 创建以源虚拟硬盘为父磁盘的差异磁盘, 之后源虚拟硬盘不能再被修改, 适用于模板虚拟机
+	CloneLinked
+)
+
+// CloneOptions 克隆选项
+type CloneOptions struct {
+	DiskMode CloneDiskMode
+	// Destination 克隆虚拟机的配置目录, 默认为源虚拟机的配置目录.
+	// 虚拟硬盘保存在其中的 "Virtual Hard Disks\<名称>" 目录
+	Destination string
+	// ProtectLinkedParents 链接克隆成功后将源虚拟硬盘设为只读, 防止修改源虚拟硬盘损坏克隆的差异磁盘, 默认关闭.
+	// 源虚拟机之后也无法写入这些虚拟硬盘, 仅适用于不再启动的模板虚拟机; 克隆失败时恢复原有权限
+	ProtectLinkedParents bool
+}
+
+// Clone 克隆已关闭的虚拟机
+//
+// 复制处理器、内存、控制器、驱动器、虚拟硬盘、网络适配器及其交换机连接、带宽与 VLAN 设置.
+// 克隆的虚拟机具有新的虚拟机 ID 与 BIOSGUID, 网络适配器使用动态 MAC 地址, 虚拟硬盘具有新的 VirtualDiskId
+//
+// 参数:
+//
+//	name: 克隆的虚拟机名称
+//	options: 克隆选项
+//	opts: 任务选项, 如 wmiext.WithProgress 接收复制虚拟硬盘的进度
+//
+// 返回:
+//
+//	克隆的虚拟机, 失败时已创建的虚拟机与虚拟硬盘会被删除
+func (vm *VirtualMachine) Clone(name string, options CloneOptions, opts ...wmiext.JobOption) (*VirtualMachine, error) {
+	state, err := vm.GetState()
+	if err != nil {
+		return nil, err
+	}
+	if state != StateStopped {
+		return nil, errors.Wrapf(ErrInvalidState, "virtual machine %s must be off to be cloned", vm.Name)
+	}
+
+	source, err := vm.cloneSource()
+	if err != nil {
+		return nil, err
+	}
+	plan, err := planClone(source, name, options, uuid.NewString)
+	source.Close()
+	if err != nil {
+		return nil, err
+	}
+	return vm.applyClonePlan(plan, opts...)
+}
+
+// cloneResourceKind is the kind of a cloned resource, ordered so that parents precede their children.
+type cloneResourceKind int
+
+const (
+	cloneController cloneResourceKind = iota
+	cloneDrive
+	cloneMedia
+	cloneAdapter
+	cloneConnection
+	cloneFeature
+)
+
+// Resource subtypes treated specially by the clone plan.
+const (
+	ideControllerSubType   = "Microsoft:Hyper-V:Emulated IDE Controller"
+	virtualHardDiskSubType = "Microsoft:Hyper-V:Virtual Hard Disk"
+)
+
+// cloneResource is a resource of the source virtual machine as read from its settings.
+type cloneResource struct {
+	Kind cloneResourceKind
+	// Class is the WMI class of the resource, which selects the defaults of feature settings.
+	Class string
+	// Path is the object path of the resource, Parent the one of the resource it belongs to.
+	Path   string
+	Parent string
+	// SubType is the resource subtype, which selects the defaults of resource settings.
+	SubType string
+	// Address is the address of controllers, matching the ones created with the system.
+	Address      string
+	HostResource []string
+	// Properties are copied to the clone, except for the ones identifying the source.
+	Properties map[string]interface{}
+}
+
+// cloneSource is the configuration of the virtual machine to clone.
+type cloneSource struct {
+	Generation                string
+	ConfigurationDataRoot     string
+	Notes                     []string
+	AutomaticSnapshotsEnabled bool
+	Version                   string
+	// Firmware is the UEFI firmware of generation 2 systems.
+	Firmware  FirmwareSettings
+	Processor *processor.ProcessorSettingData
+	Memory    *memory.MemorySettingsData
+	Resources []*cloneResource
+}
+
+// Close releases the processor and memory settings, once planClone copied their values.
+func (s *cloneSource) Close() {
+	if s.Processor != nil {
+		s.Processor.Close()
+	}
+	if s.Memory != nil {
+		s.Memory.Close()
+	}
+}
+
+// cloneDisk is a virtual hard disk of the clone, created from Source.
+type cloneDisk struct {
+	Source        string
+	Target        string
+	Linked        bool
+	VirtualDiskId string
+	// ProtectSource marks Source read-only once the clone is created, see CloneOptions.ProtectLinkedParents.
+	ProtectSource bool
+}
+
+// cloneStep adds a resource to the clone.
+type cloneStep struct {
+	Source *cloneResource
+	// Parent is the index of the step adding the parent of the resource, -1 when it has none.
+	Parent int
+	// Existing reuses the resource created with the system, matched by the address of Source.
+	Existing   bool
+	Properties map[string]interface{}
+}
+
+// clonePlan is what is created for a clone, in order: the disks, the system and its resources.
+type clonePlan struct {
+	System    *virtual_system.VirtualSystemSettingData
+	Processor *processor.ProcessorSettingData
+	Memory    *memory.MemorySettingsData
+	Disks     []cloneDisk
+	Steps     []cloneStep
+}
+
+// identityProperties identify a resource of the source and are never copied to the clone.
+var identityProperties = map[string]bool{
+	"InstanceID":               true,
+	"Parent":                   true,
+	"HostResource":             true,
+	"VirtualSystemIdentifiers": true,
+}
+
+// planClone plans the clone of source named name. newID generates the identifiers of the adapters and
+// disks of the clone, the system identifiers being generated by Hyper-V.
+func planClone(source *cloneSource, name string, options CloneOptions, newID func() string) (*clonePlan, error) {
+	if name == "" {
+		return nil, errors.New("clone name is empty")
+	}
+	destination := options.Destination
+	if destination == "" {
+		destination = source.ConfigurationDataRoot
+	}
+	if destination == "" {
+		return nil, errors.New("clone destination is empty")
+	}
+	switch options.DiskMode {
+	case CloneFullCopy, CloneLinked:
+	default:
+		return nil, errors.Errorf("unknown clone disk mode %d", options.DiskMode)
+	}
+
+	plan := &clonePlan{
+		System: &virtual_system.VirtualSystemSettingData{
+			ElementName:               name,
+			VirtualSystemSubType:      source.Generation,
+			ConfigurationDataRoot:     destination,
+			Notes:                     source.Notes,
+			AutomaticSnapshotsEnabled: source.AutomaticSnapshotsEnabled,
+			Version:                   source.Version,
+		},
+		Processor: &processor.ProcessorSettingData{
+			VirtualQuantity:                source.Processor.VirtualQuantity,
+			Reservation:                    source.Processor.Reservation,
+			Limit:                          source.Processor.Limit,
+			Weight:                         source.Processor.Weight,
+			LimitCPUID:                     source.Processor.LimitCPUID,
+			LimitProcessorFeatures:         source.Processor.LimitProcessorFeatures,
+			HwThreadsPerCore:               source.Processor.HwThreadsPerCore,
+			MaxProcessorsPerNumaNode:       source.Processor.MaxProcessorsPerNumaNode,
+			MaxNumaNodesPerSocket:          source.Processor.MaxNumaNodesPerSocket,
+			EnableHostResourceProtection:   source.Processor.EnableHostResourceProtection,
+			ExposeVirtualizationExtensions: source.Processor.ExposeVirtualizationExtensions,
+		},
+		Memory: &memory.MemorySettingsData{
+			VirtualQuantity:      source.Memory.VirtualQuantity,
+			Reservation:          source.Memory.Reservation,
+			Limit:                source.Memory.Limit,
+			Weight:               source.Memory.Weight,
+			DynamicMemoryEnabled: source.Memory.DynamicMemoryEnabled,
+			TargetMemoryBuffer:   source.Memory.TargetMemoryBuffer,
+		},
+	}
+
+	if source.Generation == virtual_system.HyperVGeneration_V2 {
+		plan.System.SecureBootEnabled = source.Firmware.SecureBoot
+		plan.System.SecureBootTemplateId = string(source.Firmware.SecureBootTemplate)
+		plan.System.PauseAfterBootFailure = source.Firmware.PauseAfterBootFailure
+		plan.System.NetworkBootPreferredProtocol = uint16(source.Firmware.NetworkBootProtocol)
+	}
+
+	resources := make([]*cloneResource, len(source.Resources))
+	copy(resources, source.Resources)
+	sort.SliceStable(resources, func(i, j int) bool { return resources[i].Kind < resources[j].Kind })
+
+	diskDir := filepath.Join(destination, "Virtual Hard Disks", name)
+	diskNames := make(map[string]bool)
+	steps := make(map[string]int, len(resources))
+	for _, r := range resources {
+		step := cloneStep{Source: r, Parent: -1, Properties: make(map[string]interface{})}
+		if r.Parent != "" {
+			parent, ok := steps[objectpath.Normalize(r.Parent)]
+			if !ok {
+				return nil, errors.Errorf("parent %s of resource %s is not cloned", r.Parent, r.Path)
+			}
+			step.Parent = parent
+		}
+		for property, value := range r.Properties {
+			if !identityProperties[property] {
+				step.Properties[property] = value
+			}
+		}
+
+		switch r.Kind {
+		case cloneController:
+			// Generation 1 systems are created with their IDE controllers
+			if r.SubType == ideControllerSubType {
+				step.Existing = true
+				step.Properties = nil
+			}
+		case cloneMedia:
+			if len(r.HostResource) == 0 {
+				return nil, errors.Errorf("media %s has no host resource", r.Path)
+			}
+			if r.SubType != virtualHardDiskSubType {
+				// ISO and floppy images are shared with the source
+				step.Properties["HostResource"] = r.HostResource
+				break
+			}
+			disk := cloneDisk{
+				Source:        r.HostResource[0],
+				Target:        filepath.Join(diskDir, uniqueFileName(fileName(r.HostResource[0]), diskNames)),
+				Linked:        options.DiskMode == CloneLinked,
+				VirtualDiskId: newID(),
+				ProtectSource: options.DiskMode == CloneLinked && options.ProtectLinkedParents,
+			}
+			plan.Disks = append(plan.Disks, disk)
+			step.Properties["HostResource"] = []string{disk.Target}
+		case cloneAdapter:
+			delete(step.Properties, "Address")
+			step.Properties["StaticMacAddress"] = false
+			step.Properties["VirtualSystemIdentifiers"] = []string{"{" + newID() + "}"}
+		case cloneConnection:
+			// The switch the adapter is connected to is shared with the source
+			if len(r.HostResource) > 0 {
+				step.Properties["HostResource"] = r.HostResource
+			}
+		}
+
+		steps[objectpath.Normalize(r.Path)] = len(plan.Steps)
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
+// uniqueFileName returns name, suffixed before its extension when already in names, and adds it to names.
+func uniqueFileName(name string, names map[string]bool) string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	unique := name
+	for i := 1; names[strings.ToLower(unique)]; i++ {
+		unique = stem + "-" + strconv.Itoa(i) + ext
+	}
+	names[strings.ToLower(unique)] = true
+	return unique
+}
+
+// cloneSource reads the configuration of the virtual machine to clone, which the caller must Close.
+func (vm *VirtualMachine) cloneSource() (_ *cloneSource, err error) {
+	settings, err := vm.computerSystem.GetVirtualSystemSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer settings.Close()
+	session := settings.GetService()
+
+	source := &cloneSource{
+		Generation:                settings.VirtualSystemSubType,
+		ConfigurationDataRoot:     settings.ConfigurationDataRoot,
+		Notes:                     settings.Notes,
+		AutomaticSnapshotsEnabled: settings.AutomaticSnapshotsEnabled,
+		Version:                   settings.Version,
+		Firmware: FirmwareSettings{
+			SecureBoot:            settings.SecureBootEnabled,
+			SecureBootTemplate:    SecureBootTemplate(settings.SecureBootTemplateId),
+			PauseAfterBootFailure: settings.PauseAfterBootFailure,
+			NetworkBootProtocol:   NetworkBootProtocol(settings.NetworkBootPreferredProtocol),
+		},
+	}
+	defer func() {
+		if err != nil {
+			source.Close()
+		}
+	}()
+	if source.Processor, err = settings.GetProcessorSettingData(); err != nil {
+		return nil, err
+	}
+	if source.Memory, err = settings.GetMemorySettingsData(); err != nil {
+		return nil, err
+	}
+
+	rasds, err := wmiext.Navigate[resource.ResourceAllocationSettingData](session,
+		virtual_system.Msvm_VirtualSystemSettingData+"/"+resource.Msvm_ResourceAllocationSettingData, settings.Path())
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(rasds)
+	for _, rasd := range rasds {
+		kind := cloneController
+		switch resource.ResourceAllocationSettingData_ResourceType(rasd.ResourceType) {
+		case resource.ResourceAllocationSettingData_ResourceType_IDE_Controller,
+			resource.ResourceAllocationSettingData_ResourceType_Parallel_SCSI_HBA:
+		case resource.ResourceAllocationSettingData_ResourceType_DVD_drive,
+			resource.ResourceAllocationSettingData_ResourceType_Disk_Drive:
+			kind = cloneDrive
+		default:
+			continue
+		}
+		source.Resources = append(source.Resources, &cloneResource{
+			Kind:       kind,
+			Class:      resource.Msvm_ResourceAllocationSettingData,
+			Path:       rasd.Path(),
+			Parent:     rasd.Parent,
+			SubType:    rasd.ResourceSubType,
+			Address:    rasd.Address,
+			Properties: map[string]interface{}{"AddressOnParent": rasd.AddressOnParent},
+		})
+	}
+
+	sasds, err := settings.GetStorageAllocationSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(sasds)
+	for _, sasd := range sasds {
+		source.Resources = append(source.Resources, &cloneResource{
+			Kind:         cloneMedia,
+			Class:        sasd.S__CLASS,
+			Path:         sasd.Path(),
+			Parent:       sasd.Parent,
+			SubType:      sasd.ResourceSubType,
+			HostResource: sasd.HostResource,
+		})
+	}
+
+	for _, class := range []string{networking.Msvm_SyntheticEthernetPortSettingData, "Msvm_EmulatedEthernetPortSettingData"} {
+		adapters, err := wmiext.Navigate[resource.ResourceAllocationSettingData](session,
+			virtual_system.Msvm_VirtualSystemSettingData+"/"+class, settings.Path())
+		if err != nil {
+			return nil, err
+		}
+		for _, adapter := range adapters {
+			source.Resources = append(source.Resources, &cloneResource{
+				Kind:    cloneAdapter,
+				Class:   class,
+				Path:    adapter.Path(),
+				SubType: adapter.ResourceSubType,
+				Address: adapter.Address,
+				Properties: map[string]interface{}{
+					"ElementName":              adapter.ElementName,
+					"Address":                  adapter.Address,
+					"VirtualSystemIdentifiers": adapter.VirtualSystemIdentifiers,
+				},
+			})
+		}
+		closeAll(adapters)
+	}
+
+	connections, err := settings.GetEthernetPortAllocationSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(connections)
+	for _, connection := range connections {
+		source.Resources = append(source.Resources, &cloneResource{
+			Kind:         cloneConnection,
+			Class:        networking.Msvm_EthernetPortAllocationSettingData,
+			Path:         connection.Path(),
+			Parent:       connection.Parent,
+			SubType:      connection.ResourceSubType,
+			HostResource: connection.HostResource,
+			Properties:   map[string]interface{}{"EnabledState": connection.EnabledState},
+		})
+		features, err := connectionFeatures(connection)
+		if err != nil {
+			return nil, err
+		}
+		source.Resources = append(source.Resources, features...)
+	}
+	return source, nil
+}
+
+// connectionFeatures reads the bandwidth and VLAN settings of connection.
+func connectionFeatures(connection *networking.EthernetPortAllocationSettingData) ([]*cloneResource, error) {
+	var features []*cloneResource
+
+	bandwidth, err := connection.GetEthernetSwitchPortBandwidthSettingData()
+	if err != nil && !isMissingObject(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer bandwidth.Close()
+		features = append(features, &cloneResource{
+			Kind:   cloneFeature,
+			Class:  switch_extension.Msvm_EthernetSwitchPortBandwidthSettingData,
+			Path:   bandwidth.Path(),
+			Parent: connection.Path(),
+			Properties: map[string]interface{}{
+				"Limit":       bandwidth.Limit,
+				"Reservation": bandwidth.Reservation,
+				"Weight":      bandwidth.Weight,
+				"BurstLimit":  bandwidth.BurstLimit,
+				"BurstSize":   bandwidth.BurstSize,
+			},
+		})
+	}
+
+	vlan, err := connection.GetEthernetSwitchPortVlanSettingData()
+	if err != nil && !isMissingObject(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer vlan.Close()
+		properties := map[string]interface{}{
+			"OperationMode":   uint32(vlan.OperationMode),
+			"AccessVlanId":    vlan.AccessVlanId,
+			"NativeVlanId":    vlan.NativeVlanId,
+			"PvlanMode":       uint32(vlan.PvlanMode),
+			"PrimaryVlanId":   vlan.PrimaryVlanId,
+			"SecondaryVlanId": vlan.SecondaryVlanId,
+		}
+		for property, ids := range map[string][]uint16{
+			"TrunkVlanIdArray":     vlan.TrunkVlanIdArray,
+			"PruneVlanIdArray":     vlan.PruneVlanIdArray,
+			"SecondaryVlanIdArray": vlan.SecondaryVlanIdArray,
+		} {
+			if len(ids) > 0 {
+				properties[property] = ids
+			}
+		}
+		features = append(features, &cloneResource{
+			Kind:       cloneFeature,
+			Class:      switch_extension.Msvm_EthernetSwitchPortVlanSettingData,
+			Path:       vlan.Path(),
+			Parent:     connection.Path(),
+			Properties: properties,
+		})
+	}
+	return features, nil
+}
+
+// applyClonePlan creates the disks, the system and the resources of plan, removing the ones created
+// when failing.
+func (vm *VirtualMachine) applyClonePlan(plan *clonePlan, opts ...wmiext.JobOption) (clone *VirtualMachine, err error) {
+	vsms, err := virtualSystemManagementService(vm.client)
+	if err != nil {
+		return nil, err
+	}
+	ims, err := imageManagementService(vm.client)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		created []string
+		system  *virtual_system.ComputerSystem
+		// restore undoes the protection of the sources, in reverse order
+		restore []func()
+	)
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(restore) - 1; i >= 0; i-- {
+			restore[i]()
+		}
+		if system != nil {
+			_ = vsms.DestroySystem(system)
+		}
+		for _, path := range created {
+			_ = os.Remove(path)
+		}
+	}()
+
+	for _, disk := range plan.Disks {
+		if err = os.MkdirAll(filepath.Dir(disk.Target), os.ModePerm); err != nil {
+			return nil, err
+		}
+		if err = createCloneDisk(ims, disk, opts...); err != nil {
+			return nil, err
+		}
+		created = append(created, disk.Target)
+		if err = wmiext.AwaitJob(ims.SetVirtualDiskId(disk.Target, disk.VirtualDiskId)); err != nil {
+			return nil, err
+		}
+	}
+
+	if system, err = wmiext.Await(vsms.DefineSystem(plan.System, plan.Processor, plan.Memory)); err != nil {
+		return nil, err
+	}
+	settings, err := system.GetVirtualSystemSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer settings.Close()
+
+	paths := make([]string, len(plan.Steps))
+	for i, step := range plan.Steps {
+		var parent string
+		if step.Parent >= 0 {
+			parent = paths[step.Parent]
+		}
+		switch {
+		case step.Existing:
+			paths[i], err = existingController(settings, step.Source)
+		case step.Source.Kind == cloneFeature:
+			paths[i], err = addCloneFeature(vsms, parent, step)
+		default:
+			paths[i], err = addCloneResource(vsms, settings, parent, step)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "clone resource %s", step.Source.Path)
+		}
+	}
+	for _, disk := range plan.Disks {
+		if !disk.ProtectSource {
+			continue
+		}
+		var undo func()
+		if undo, err = protectFile(disk.Source); err != nil {
+			return nil, err
+		}
+		restore = append(restore, undo)
+	}
+	return newVirtualMachine(vm.client, system, FieldsHardware)
+}
+
+// protectFile marks the file at path read-only, and returns the function restoring its mode.
+func protectFile(path string) (func(), error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	mode := info.Mode().Perm()
+	if err = os.Chmod(path, mode&^0o222); err != nil {
+		return nil, err
+	}
+	return func() { _ = os.Chmod(path, mode) }, nil
+}
+
+// createCloneDisk creates the virtual hard disk of the clone, a differencing child of the source when
+// linked or a merged copy of its chain otherwise.
+func createCloneDisk(ims *storage.ImageManagementService, disk cloneDisk, opts ...wmiext.JobOption) error {
+	source, err := ims.GetVirtualHardDiskSettingData(disk.Source)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	settings, err := ims.GetDefaultVirtualHardDiskSettingData()
+	if err != nil {
+		return err
+	}
+	defer settings.Close()
+
+	diskType := source.Type
+	if disk.Linked {
+		diskType = storage.VirtualHardDiskType_DIFFERENCING
+	} else if diskType == storage.VirtualHardDiskType_DIFFERENCING {
+		diskType = storage.VirtualHardDiskType_SPARSE
+	}
+	if err = settings.Put("Path", disk.Target); err != nil {
+		return err
+	}
+	if err = settings.Put("Type", diskType); err != nil {
+		return err
+	}
+	if err = settings.Put("Format", source.Format); err != nil {
+		return err
+	}
+
+	if disk.Linked {
+		if err = settings.Put("ParentPath", disk.Source); err != nil {
+			return err
+		}
+		return wmiext.AwaitJob(ims.CreateVirtualHardDisk(settings, opts...))
+	}
+	return wmiext.AwaitJob(ims.ConvertVirtualHardDisk(disk.Source, settings, opts...))
+}
+
+// existingController returns the path of the controller of settings created with the system at the
+// address of source.
+func existingController(settings *virtual_system.VirtualSystemSettingData, source *cloneResource) (string, error) {
+	controllers, err := wmiext.Navigate[resource.ResourceAllocationSettingData](settings.GetService(),
+		fmt.Sprintf("%s/%s[ResourceSubType=%q]", virtual_system.Msvm_VirtualSystemSettingData, resource.Msvm_ResourceAllocationSettingData, source.SubType),
+		settings.Path())
+	if err != nil {
+		return "", err
+	}
+	for _, controller := range controllers {
+		if controller.Address == source.Address {
+			return controller.Path(), nil
+		}
+	}
+	return "", errors.Wrapf(wmiext.NotFound, "controller %s at address %s", source.SubType, source.Address)
+}
+
+// addCloneResource adds the resource of step to settings from the defaults of its subtype.
+func addCloneResource(vsms *virtual_system.VirtualSystemManagementService, settings *virtual_system.VirtualSystemSettingData, parent string, step cloneStep) (string, error) {
+	ref, err := hypervsdk.FindResourceDefaults(vsms.Session, step.Source.SubType)
+	if err != nil {
+		return "", err
+	}
+	defaults, err := vsms.Session.GetObject(ref)
+	if err != nil {
+		return "", err
+	}
+	defer defaults.Close()
+	instance, err := defaults.CloneInstance()
+	if err != nil {
+		return "", err
+	}
+	defer instance.Close()
+
+	if err = putCloneProperties(instance, parent, step.Properties); err != nil {
+		return "", err
+	}
+	added, err := wmiext.Await(vsms.AddResourceSettings(settings, []string{instance.GetCimText()}))
+	if err != nil {
+		return "", err
+	}
+	return resultingPath(added)
+}
+
+// addCloneFeature adds the feature setting of step to the connection at parent from the defaults of
+// its class.
+func addCloneFeature(vsms *virtual_system.VirtualSystemManagementService, parent string, step cloneStep) (string, error) {
+	var instance *wmiext.Instance
+	switch step.Source.Class {
+	case switch_extension.Msvm_EthernetSwitchPortBandwidthSettingData:
+		defaults, err := host.DefaultEthernetSwitchPortBandwidthSettingDataWith(vsms.Session)
+		if err != nil {
+			return "", err
+		}
+		instance = defaults.Instance
+	case switch_extension.Msvm_EthernetSwitchPortVlanSettingData:
+		defaults, err := host.DefaultEthernetSwitchPortVlanSettingDataWith(vsms.Session)
+		if err != nil {
+			return "", err
+		}
+		instance = defaults.Instance
+	default:
+		return "", errors.Errorf("unknown feature setting class %s", step.Source.Class)
+	}
+	defer instance.Close()
+
+	if err := putCloneProperties(instance, "", step.Properties); err != nil {
+		return "", err
+	}
+	added, err := vsms.AddFeatureSettings(parent, []string{instance.GetCimText()})
+	if err != nil {
+		return "", err
+	}
+	return resultingPath(added)
+}
+
+// putCloneProperties puts parent, when not empty, and properties on instance in a stable order.
+func putCloneProperties(instance *wmiext.Instance, parent string, properties map[string]interface{}) error {
+	if parent != "" {
+		if err := instance.Put("Parent", parent); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := instance.Put(name, properties[name]); err != nil {
+			return errors.Wrapf(err, "put %s", name)
+		}
+	}
+	return nil
+}
+
+// resultingPath returns the path of the single resulting setting of an added resource, closing them.
+func resultingPath(instances []*wmiext.Instance) (string, error) {
+	defer func() {
+		for _, instance := range instances {
+			instance.Close()
+		}
+	}()
+	if len(instances) != 1 {
+		return "", errors.Errorf("expected one resulting setting, got %d", len(instances))
+	}
+	return instances[0].Path()
+}
@@ -0,0 +1,288 @@
+package hyperv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/memory"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking/switch_extension"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/processor"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sequentialIDs returns a generator of the identifiers id-1, id-2...
+func sequentialIDs() func() string {
+	var n int
+	return func() string {
+		n++
+		return fmt.Sprintf("id-%d", n)
+	}
+}
+
+// testCloneSource is a generation 1 machine with a disk on IDE, a disk and a DVD on SCSI and a
+// connected adapter with VLAN settings, listed children first.
+func testCloneSource() *cloneSource {
+	return &cloneSource{
+		Generation:            virtual_system.HyperVGeneration_V1,
+		ConfigurationDataRoot: `D:\VMs`,
+		Notes:                 []string{"template"},
+		Processor:             &processor.ProcessorSettingData{InstanceID: "Microsoft:SRC\\P", VirtualQuantity: 4, Limit: 100000, Weight: 100},
+		Memory:                &memory.MemorySettingsData{InstanceID: "Microsoft:SRC\\M", VirtualQuantity: 2048, DynamicMemoryEnabled: true},
+		Resources: []*cloneResource{
+			{Kind: cloneFeature, Class: switch_extension.Msvm_EthernetSwitchPortVlanSettingData, Path: "vlan", Parent: "conn",
+				Properties: map[string]interface{}{"InstanceID": "Microsoft:SRC\\V", "OperationMode": uint32(1), "AccessVlanId": uint16(12)}},
+			{Kind: cloneConnection, Path: "conn", Parent: "nic", SubType: "Microsoft:Hyper-V:Ethernet Connection",
+				HostResource: []string{"switch"}, Properties: map[string]interface{}{"EnabledState": uint16(2)}},
+			{Kind: cloneAdapter, Path: "nic", SubType: "Microsoft:Hyper-V:Synthetic Ethernet Port", Address: "00155D000001",
+				Properties: map[string]interface{}{"ElementName": "eth0", "Address": "00155D000001", "VirtualSystemIdentifiers": []string{"{src}"}}},
+			{Kind: cloneMedia, Path: "iso", Parent: "dvd", SubType: "Microsoft:Hyper-V:Virtual CD/DVD Disk", HostResource: []string{`D:\iso\setup.iso`}},
+			{Kind: cloneMedia, Path: "vhd-scsi", Parent: "disk-scsi", SubType: virtualHardDiskSubType, HostResource: []string{filepath.Join("E:", "data", "system.vhdx")}},
+			{Kind: cloneMedia, Path: "vhd-ide", Parent: "disk-ide", SubType: virtualHardDiskSubType, HostResource: []string{filepath.Join("D:", "VMs", "system.vhdx")}},
+			{Kind: cloneDrive, Path: "dvd", Parent: "scsi", SubType: "Microsoft:Hyper-V:Virtual CD/DVD Drive", Properties: map[string]interface{}{"AddressOnParent": "1"}},
+			{Kind: cloneDrive, Path: "disk-scsi", Parent: "scsi", SubType: "Microsoft:Hyper-V:Synthetic Disk Drive", Properties: map[string]interface{}{"AddressOnParent": "0"}},
+			{Kind: cloneDrive, Path: "disk-ide", Parent: "ide", SubType: "Microsoft:Hyper-V:Synthetic Disk Drive", Properties: map[string]interface{}{"AddressOnParent": "0"}},
+			{Kind: cloneController, Path: "scsi", SubType: "Microsoft:Hyper-V:Synthetic SCSI Controller"},
+			{Kind: cloneController, Path: "ide", SubType: ideControllerSubType, Address: "0"},
+		},
+	}
+}
+
+// stepOf returns the step of plan cloning the resource at path.
+func stepOf(t *testing.T, plan *clonePlan, path string) (int, cloneStep) {
+	for i, step := range plan.Steps {
+		if step.Source.Path == path {
+			return i, step
+		}
+	}
+	t.Fatalf("no step cloning %s", path)
+	return -1, cloneStep{}
+}
+
+func TestPlanClone_FreshIdentity(t *testing.T) {
+	plan, err := planClone(testCloneSource(), "clone", CloneOptions{}, sequentialIDs())
+	require.NoError(t, err)
+
+	// Hyper-V generates the system identifiers of systems defined without them
+	assert.Equal(t, "clone", plan.System.ElementName)
+	assert.Empty(t, plan.System.VirtualSystemIdentifier)
+	assert.Empty(t, plan.System.BIOSGUID)
+	assert.Equal(t, virtual_system.HyperVGeneration_V1, plan.System.VirtualSystemSubType)
+	assert.Equal(t, `D:\VMs`, plan.System.ConfigurationDataRoot)
+	assert.Equal(t, []string{"template"}, plan.System.Notes)
+	assert.Empty(t, plan.Processor.InstanceID)
+	assert.Equal(t, uint64(4), plan.Processor.VirtualQuantity)
+	assert.Empty(t, plan.Memory.InstanceID)
+	assert.Equal(t, uint64(2048), plan.Memory.VirtualQuantity)
+	assert.True(t, plan.Memory.DynamicMemoryEnabled)
+
+	_, nic := stepOf(t, plan, "nic")
+	assert.Equal(t, map[string]interface{}{
+		"ElementName":              "eth0",
+		"StaticMacAddress":         false,
+		"VirtualSystemIdentifiers": []string{"{id-3}"},
+	}, nic.Properties)
+
+	_, vlan := stepOf(t, plan, "vlan")
+	assert.Equal(t, map[string]interface{}{"OperationMode": uint32(1), "AccessVlanId": uint16(12)}, vlan.Properties)
+
+	require.Len(t, plan.Disks, 2)
+	for _, disk := range plan.Disks {
+		assert.NotEmpty(t, disk.VirtualDiskId)
+	}
+	assert.NotEqual(t, plan.Disks[0].VirtualDiskId, plan.Disks[1].VirtualDiskId)
+}
+
+func TestPlanClone_Order(t *testing.T) {
+	plan, err := planClone(testCloneSource(), "clone", CloneOptions{}, sequentialIDs())
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 11)
+
+	for i, step := range plan.Steps {
+		if step.Source.Parent == "" {
+			assert.Equal(t, -1, step.Parent, step.Source.Path)
+			continue
+		}
+		require.True(t, step.Parent >= 0 && step.Parent < i, "parent of %s is added before it", step.Source.Path)
+		assert.Equal(t, step.Source.Parent, plan.Steps[step.Parent].Source.Path)
+	}
+
+	_, ide := stepOf(t, plan, "ide")
+	assert.True(t, ide.Existing)
+	_, scsi := stepOf(t, plan, "scsi")
+	assert.False(t, scsi.Existing)
+
+	_, dvd := stepOf(t, plan, "dvd")
+	assert.Equal(t, map[string]interface{}{"AddressOnParent": "1"}, dvd.Properties)
+	_, conn := stepOf(t, plan, "conn")
+	assert.Equal(t, map[string]interface{}{"EnabledState": uint16(2), "HostResource": []string{"switch"}}, conn.Properties)
+}
+
+func TestPlanClone_ParentPaths(t *testing.T) {
+	source := testCloneSource()
+	source.Resources = []*cloneResource{
+		{Kind: cloneDrive, Path: `Msvm_ResourceAllocationSettingData.InstanceID="Microsoft:SRC\\D"`,
+			Parent: `msvm_resourceallocationsettingdata.InstanceID="Microsoft:SRC\\C"`, SubType: "Microsoft:Hyper-V:Synthetic Disk Drive"},
+		{Kind: cloneController, Path: `\\HOST\root\virtualization\v2:Msvm_ResourceAllocationSettingData.InstanceID="Microsoft:SRC\\C"`,
+			SubType: "Microsoft:Hyper-V:Synthetic SCSI Controller"},
+	}
+
+	// The parent is found even though its path is written with the server and namespace
+	plan, err := planClone(source, "clone", CloneOptions{}, sequentialIDs())
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 2)
+	assert.Equal(t, 0, plan.Steps[1].Parent)
+}
+
+func TestPlanClone_Disks(t *testing.T) {
+	dir := filepath.Join(`F:\clones`, "Virtual Hard Disks", "clone")
+
+	for _, tc := range []struct {
+		name    string
+		mode    CloneDiskMode
+		protect bool
+		linked  bool
+		// protected is set when the sources are marked read-only, only for linked disks when asked
+		protected bool
+	}{
+		{name: "full", mode: CloneFullCopy},
+		{name: "full protected", mode: CloneFullCopy, protect: true},
+		{name: "linked", mode: CloneLinked, linked: true},
+		{name: "linked protected", mode: CloneLinked, protect: true, linked: true, protected: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			options := CloneOptions{DiskMode: tc.mode, Destination: `F:\clones`, ProtectLinkedParents: tc.protect}
+			plan, err := planClone(testCloneSource(), "clone", options, sequentialIDs())
+			require.NoError(t, err)
+			assert.Equal(t, `F:\clones`, plan.System.ConfigurationDataRoot)
+
+			// Disks with the same file name are given distinct targets
+			assert.Equal(t, []cloneDisk{
+				{Source: filepath.Join("E:", "data", "system.vhdx"), Target: filepath.Join(dir, "system.vhdx"), Linked: tc.linked, VirtualDiskId: "id-1", ProtectSource: tc.protected},
+				{Source: filepath.Join("D:", "VMs", "system.vhdx"), Target: filepath.Join(dir, "system-1.vhdx"), Linked: tc.linked, VirtualDiskId: "id-2", ProtectSource: tc.protected},
+			}, plan.Disks)
+
+			_, scsi := stepOf(t, plan, "vhd-scsi")
+			assert.Equal(t, []string{filepath.Join(dir, "system.vhdx")}, scsi.Properties["HostResource"])
+			_, ide := stepOf(t, plan, "vhd-ide")
+			assert.Equal(t, []string{filepath.Join(dir, "system-1.vhdx")}, ide.Properties["HostResource"])
+
+			// Images are shared with the source
+			_, iso := stepOf(t, plan, "iso")
+			assert.Equal(t, []string{`D:\iso\setup.iso`}, iso.Properties["HostResource"])
+		})
+	}
+}
+
+func TestPlanClone_Errors(t *testing.T) {
+	_, err := planClone(testCloneSource(), "", CloneOptions{}, sequentialIDs())
+	assert.Error(t, err)
+
+	_, err = planClone(testCloneSource(), "clone", CloneOptions{DiskMode: CloneDiskMode(9)}, sequentialIDs())
+	assert.ErrorContains(t, err, "unknown clone disk mode")
+
+	source := testCloneSource()
+	source.ConfigurationDataRoot = ""
+	_, err = planClone(source, "clone", CloneOptions{}, sequentialIDs())
+	assert.ErrorContains(t, err, "destination is empty")
+
+	// A drive on a controller that is not cloned cannot be added
+	source = testCloneSource()
+	source.Resources = source.Resources[:len(source.Resources)-1]
+	_, err = planClone(source, "clone", CloneOptions{}, sequentialIDs())
+	assert.ErrorContains(t, err, "parent ide of resource disk-ide is not cloned")
+}
+
+func TestPlanClone_Firmware(t *testing.T) {
+	source := testCloneSource()
+	source.Version = "9.0"
+	source.Firmware = FirmwareSettings{SecureBoot: true, SecureBootTemplate: SecureBootTemplateMicrosoftUEFICertificateAuthority, NetworkBootProtocol: NetworkBootIPv6}
+
+	// Generation 1 systems have no firmware settings
+	plan, err := planClone(source, "clone", CloneOptions{}, sequentialIDs())
+	require.NoError(t, err)
+	assert.Equal(t, "9.0", plan.System.Version)
+	assert.False(t, plan.System.SecureBootEnabled)
+
+	source.Generation = virtual_system.HyperVGeneration_V2
+	plan, err = planClone(source, "clone", CloneOptions{}, sequentialIDs())
+	require.NoError(t, err)
+	assert.True(t, plan.System.SecureBootEnabled)
+	assert.Equal(t, virtual_system.SecureBootTemplate_MicrosoftUEFICertificateAuthority, plan.System.SecureBootTemplateId)
+	assert.Equal(t, virtual_system.NetworkBootPreferredProtocol_IPv6, plan.System.NetworkBootPreferredProtocol)
+}
+
+func TestProtectFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "parent.vhdx")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+
+	undo, err := protectFile(path)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Mode().Perm()&0o222, "the file is read-only")
+
+	// The rollback of a failed clone restores the mode
+	undo()
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	_, err = protectFile(filepath.Join(t.TempDir(), "missing.vhdx"))
+	assert.Error(t, err)
+}
+
+func TestCloneSource_Close(t *testing.T) {
+	c, repo := newTestClient(t)
+	defineSettingAssociations(repo)
+	addAssociatedMachine(t, repo, "vm-1", "A0B1")
+	for _, class := range []string{
+		"Msvm_ResourceAllocationSettingData", "Msvm_StorageAllocationSettingData", "Msvm_SyntheticEthernetPortSettingData",
+		"Msvm_EmulatedEthernetPortSettingData", "Msvm_EthernetPortAllocationSettingData",
+		"Msvm_EthernetSwitchPortBandwidthSettingData", "Msvm_EthernetSwitchPortVlanSettingData",
+	} {
+		repo.DefineClass(wmiext.MemoryClass{Name: class, Keys: []string{"InstanceID"}})
+	}
+	add := func(class string, properties map[string]interface{}) string {
+		path, err := repo.AddInstance(class, properties)
+		require.NoError(t, err)
+		return path
+	}
+	component := func(group, part string) {
+		add("Msvm_VirtualSystemSettingDataComponent", map[string]interface{}{
+			"GroupComponent": wmiext.Reference(group), "PartComponent": wmiext.Reference(part),
+		})
+	}
+	settingPath := `Msvm_VirtualSystemSettingData.InstanceID="Microsoft:A0B1"`
+	drive := add("Msvm_ResourceAllocationSettingData", map[string]interface{}{"InstanceID": `Microsoft:A0B1\drive`, "ResourceType": uint16(17)})
+	disk := add("Msvm_StorageAllocationSettingData", map[string]interface{}{
+		"InstanceID": `Microsoft:A0B1\disk`, "Parent": drive, "HostResource": []string{`D:\vm-1.vhdx`},
+	})
+	adapter := add("Msvm_SyntheticEthernetPortSettingData", map[string]interface{}{"InstanceID": `Microsoft:A0B1\adapter`})
+	port := add("Msvm_EthernetPortAllocationSettingData", map[string]interface{}{"InstanceID": `Microsoft:A0B1\port`, "Parent": adapter})
+	vlan := add("Msvm_EthernetSwitchPortVlanSettingData", map[string]interface{}{"InstanceID": `Microsoft:A0B1\port\vlan`, "AccessVlanId": uint16(12)})
+	for _, part := range []string{drive, disk, adapter, port} {
+		component(settingPath, part)
+	}
+	component(port, vlan)
+
+	require.NoError(t, c.WithArena(func(scoped *Client) error {
+		vm, err := scoped.FirstVirtualMachineByName("vm-1", FieldsBasic)
+		require.NoError(t, err)
+		arena := scoped.Session().Arena()
+		open := arena.Len()
+
+		source, err := vm.cloneSource()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), source.Processor.VirtualQuantity)
+		assert.Len(t, source.Resources, 5)
+		source.Close()
+		// Every instance read for the clone is released once planned
+		assert.Equal(t, open, arena.Len())
+		return nil
+	}))
+}
@@ -0,0 +1,87 @@
+// Command mofgen generates Go bindings for WMI classes from their MOF declarations. It is meant to
+// be run by go generate:
+//
+//	//go:generate go run github.com/rokukoo/hyperv/cmd/mofgen -package msvm -out zz_generated.go mof
+//
+// The arguments are MOF files, or directories whose .mof files are read in name order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext/mof"
+)
+
+func main() {
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "name of the generated package")
+	out := flag.String("out", "", "generated file, standard output when empty")
+	classes := flag.String("classes", "", "comma separated classes to generate, all when empty")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: mofgen [flags] file.mof|dir...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*pkg, *out, *classes, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "mofgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(pkg string, out string, classes string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no MOF file given")
+	}
+	files, err := mofFiles(args)
+	if err != nil {
+		return err
+	}
+	schema, err := mof.ParseFiles(files...)
+	if err != nil {
+		return err
+	}
+
+	config := mof.Config{Package: pkg, Sources: args}
+	if classes != "" {
+		config.Classes = strings.Split(classes, ",")
+	}
+	src, err := mof.Generate(schema, config)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
+
+// mofFiles expands the directories among args to the .mof files they contain.
+func mofFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, filepath.ToSlash(arg))
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.mof"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			files = append(files, filepath.ToSlash(match))
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,93 @@
+// Command wmischema captures the schemas of WMI classes on a host and compares the snapshots of
+// different hosts, e.g. to find the Hyper-V members a Windows build adds:
+//
+//	wmischema dump -source "Windows Server 2019" -out 2019.json Msvm_VirtualSystemSettingData
+//	wmischema diff 2019.json 2022.json
+//
+// dump captures every class of the namespace when no class is given. diff exits with status 1 when
+// the snapshots differ.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: wmischema dump [flags] [class...]\n       wmischema diff old.json new.json\n")
+	}
+	flag.Parse()
+
+	var err error
+	switch flag.Arg(0) {
+	case "dump":
+		err = dump(flag.Args()[1:])
+	case "diff":
+		var differ bool
+		if differ, err = diff(flag.Args()[1:]); err == nil && differ {
+			os.Exit(1)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wmischema: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func dump(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	namespace := flags.String("namespace", wmiext.Virtualization, "namespace of the classes")
+	source := flags.String("source", "", "description of the host, e.g. its Windows build")
+	out := flags.String("out", "", "snapshot file, standard output when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	service, err := wmiext.NewLocalService(*namespace)
+	if err != nil {
+		return err
+	}
+	defer service.Close()
+	snapshot, err := service.Schema().Snapshot(flags.Args()...)
+	if err != nil {
+		return err
+	}
+	snapshot.Source = *source
+
+	if *out != "" {
+		return snapshot.Save(*out)
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Printf("%s\n", data)
+	return err
+}
+
+func diff(args []string) (bool, error) {
+	if len(args) != 2 {
+		return false, fmt.Errorf("diff takes two snapshots")
+	}
+	old, err := wmiext.LoadSchemaSnapshot(args[0])
+	if err != nil {
+		return false, err
+	}
+	new, err := wmiext.LoadSchemaSnapshot(args[1])
+	if err != nil {
+		return false, err
+	}
+	differences := wmiext.CompareSchemas(old, new)
+	for _, difference := range differences {
+		fmt.Println(difference)
+	}
+	return len(differences) > 0, nil
+}
@@ -2,10 +2,31 @@ package hyperv
 
 import (
 	"github.com/pkg/errors"
+	hverrors "github.com/rokukoo/hyperv/pkg/hypervsdk/errors"
 )
 
 var (
 	ErrNotFound = errors.New("not found")
 
 	ErrorVirtualMachineAlreadyExists = errors.New("virtual machine already exists")
+
+	// ErrVirtualHardDiskChain 虚拟硬盘属于检查点的差异磁盘链
+	ErrVirtualHardDiskChain = errors.New("virtual hard disk is part of a differencing disk chain")
 )
+
+// Errors of failed Hyper-V methods and jobs, matched with errors.Is.
+var (
+	ErrFailed             = hverrors.ErrFailed
+	ErrTimeout            = hverrors.ErrTimeout
+	ErrInvalidState       = hverrors.ErrInvalidState
+	ErrAccessDenied       = hverrors.ErrAccessDenied
+	ErrNotSupported       = hverrors.ErrNotSupported
+	ErrSystemInUse        = hverrors.ErrSystemInUse
+	ErrSystemNotAvailable = hverrors.ErrSystemNotAvailable
+	ErrOutOfResources     = hverrors.ErrOutOfResources
+	ErrFileNotFound       = hverrors.ErrFileNotFound
+	ErrInvalidParameter   = hverrors.ErrInvalidParameter
+)
+
+// HyperVError is a failed Hyper-V method or job, retrieved with errors.As.
+type HyperVError = hverrors.HyperVError
@@ -0,0 +1,555 @@
+package hyperv
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	hverrors "github.com/rokukoo/hyperv/pkg/hypervsdk/errors"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/resource"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage/allocation"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+// ExportMode 导出模式
+type ExportMode int
+
+const (
+	// ExportCopyVirtualHardDisks 导出配置并复制虚拟硬盘, 不包含检查点
+	ExportCopyVirtualHardDisks ExportMode = iota
+	// ExportWithCheckpoints 导出配置、虚拟硬盘以及全部检查点
+	ExportWithCheckpoints
+	// ExportConfigurationOnly 仅导出配置, 导入时虚拟硬盘需要位于原路径或重新映射
+	ExportConfigurationOnly
+)
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	Mode ExportMode
+	// RuntimeState 同时导出已保存虚拟机的内存状态
+	RuntimeState bool
+	// NoSubdirectory 直接导出到目标目录, 默认导出到目标目录中以虚拟机名称命名的子目录
+	NoSubdirectory bool
+}
+
+// Export 导出虚拟机
+//
+// 参数:
+//
+//	dir: 导出目录
+//	options: 导出选项
+//	opts: 任务选项, 如 wmiext.WithProgress 接收导出进度
+func (vm *VirtualMachine) Export(dir string, options ExportOptions, opts ...wmiext.JobOption) error {
+	settings := &virtual_system.VirtualSystemExportSettingData{
+		CopySnapshotConfiguration:  virtual_system.SnapshotExport_None,
+		CopyVmStorage:              true,
+		CopyVmRuntimeInformation:   options.RuntimeState,
+		CreateVmExportSubdirectory: !options.NoSubdirectory,
+	}
+	switch options.Mode {
+	case ExportCopyVirtualHardDisks:
+	case ExportWithCheckpoints:
+		settings.CopySnapshotConfiguration = virtual_system.SnapshotExport_All
+	case ExportConfigurationOnly:
+		settings.CopyVmStorage = false
+	default:
+		return errors.Errorf("unknown export mode %d", options.Mode)
+	}
+
+	vsms, err := virtualSystemManagementService(vm.client)
+	if err != nil {
+		return err
+	}
+	return wmiext.AwaitJob(vsms.ExportSystemDefinition(vm.computerSystem, dir, settings, opts...))
+}
+
+// ImportMode 导入模式
+type ImportMode int
+
+const (
+	// ImportRegisterInPlace 原地注册, 使用导出目录中的文件并保留虚拟机 ID
+	ImportRegisterInPlace ImportMode = iota
+	// ImportCopy 复制虚拟硬盘到 ImportOptions.Destination 并保留虚拟机 ID
+	ImportCopy
+	// ImportGenerateNewID 复制虚拟硬盘到 ImportOptions.Destination 并生成新的虚拟机 ID, 同一导出可以多次导入
+	ImportGenerateNewID
+)
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	Mode ImportMode
+	// Destination 复制模式保存配置与检查点的目录, 虚拟硬盘复制到其 Virtual Hard Disks 子目录
+	Destination string
+}
+
+// PlannedVirtualMachine 已导入但尚未实现 (注册) 的计划虚拟机
+//
+// 实现前通过 Validate 检查与主机不兼容之处, 通过 RemapVirtualHardDisk 与 RemapVirtualSwitch 修正,
+// 再通过 Realize 得到虚拟机, 或通过 Discard 放弃导入
+type PlannedVirtualMachine struct {
+	Name    string `json:"name"`
+	planned *virtual_system.PlannedComputerSystem
+	client  *Client
+}
+
+// IncompatibilityKind 不兼容类型
+type IncompatibilityKind int
+
+const (
+	// IncompatibilityMissingVirtualHardDisk 虚拟硬盘文件不存在
+	IncompatibilityMissingVirtualHardDisk IncompatibilityKind = iota + 1
+	// IncompatibilityMissingVirtualSwitch 网络适配器连接的虚拟交换机不存在
+	IncompatibilityMissingVirtualSwitch
+	// IncompatibilityOther Hyper-V 校验计划虚拟机时报告的其它问题
+	IncompatibilityOther
+)
+
+// Incompatibility 计划虚拟机与主机的不兼容之处
+type Incompatibility struct {
+	Kind IncompatibilityKind `json:"kind"`
+	// Resource 缺失的虚拟硬盘路径, 或连接缺失交换机的网络适配器名称
+	Resource string `json:"resource"`
+	Message  string `json:"message"`
+}
+
+func (i Incompatibility) String() string {
+	return i.Message
+}
+
+// resolveSystemDefinition returns the definition file and the snapshot folder of an export, given
+// either the directory of the exported virtual machine or its definition file.
+func resolveSystemDefinition(path string) (definitionFile string, snapshotFolder string, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", "", err
+	}
+	definitionFile = path
+	if info.IsDir() {
+		var matches []string
+		for _, pattern := range []string{"*.vmcx", "*.xml"} {
+			found, err := filepath.Glob(filepath.Join(path, "Virtual Machines", pattern))
+			if err != nil {
+				return "", "", err
+			}
+			matches = append(matches, found...)
+		}
+		if len(matches) != 1 {
+			return "", "", errors.Errorf("%s holds %d virtual machine definitions instead of one", path, len(matches))
+		}
+		definitionFile = matches[0]
+	}
+
+	// Virtual Machines and Snapshots are siblings in an export
+	snapshotFolder = filepath.Join(filepath.Dir(filepath.Dir(definitionFile)), "Snapshots")
+	if info, err := os.Stat(snapshotFolder); err != nil || !info.IsDir() {
+		snapshotFolder = ""
+	}
+	return definitionFile, snapshotFolder, nil
+}
+
+// ImportVirtualMachine 导入虚拟机, 返回尚未实现的计划虚拟机
+//
+// 参数:
+//
+//	path: 导出的虚拟机目录, 或其 Virtual Machines 子目录中的配置文件
+//	options: 导入选项
+//	opts: 任务选项, 如 wmiext.WithProgress 接收导入进度
+//
+// 返回:
+//
+//	*PlannedVirtualMachine: 计划虚拟机, 检查并修正不兼容之处后调用 Realize
+//	error: 错误
+func (c *Client) ImportVirtualMachine(path string, options ImportOptions, opts ...wmiext.JobOption) (*PlannedVirtualMachine, error) {
+	copyFiles := options.Mode == ImportCopy || options.Mode == ImportGenerateNewID
+	if options.Mode < ImportRegisterInPlace || options.Mode > ImportGenerateNewID {
+		return nil, errors.Errorf("unknown import mode %d", options.Mode)
+	}
+	if copyFiles && options.Destination == "" {
+		return nil, errors.New("the destination of an import copying the files is required")
+	}
+
+	definitionFile, snapshotFolder, err := resolveSystemDefinition(path)
+	if err != nil {
+		return nil, err
+	}
+	vsms, err := c.VirtualSystemManagementService()
+	if err != nil {
+		return nil, err
+	}
+	planned, err := wmiext.Await(vsms.ImportSystemDefinition(definitionFile, snapshotFolder, options.Mode == ImportGenerateNewID, opts...))
+	if err != nil {
+		return nil, err
+	}
+
+	pvm := &PlannedVirtualMachine{Name: planned.ElementName, planned: planned, client: c}
+	if copyFiles {
+		if err = pvm.relocate(options.Destination); err != nil {
+			// The planned system is of no use once partly relocated
+			if discardErr := pvm.Discard(); discardErr != nil {
+				return nil, errors.Wrapf(err, "discarding the planned virtual machine: %v", discardErr)
+			}
+			return nil, err
+		}
+	}
+	return pvm, nil
+}
+
+// ImportVirtualMachine is Client.ImportVirtualMachine on the default client.
+func ImportVirtualMachine(path string, options ImportOptions, opts ...wmiext.JobOption) (*PlannedVirtualMachine, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.ImportVirtualMachine(path, options, opts...)
+}
+
+// virtualHardDisks returns the disks of the planned system and of its snapshots.
+func (p *PlannedVirtualMachine) virtualHardDisks() ([]*allocation.StorageAllocationSettingData, error) {
+	settings, err := p.planned.GetAllVirtualSystemSettingData()
+	if err != nil {
+		return nil, err
+	}
+	var disks []*allocation.StorageAllocationSettingData
+	for _, setting := range settings {
+		sasds, err := setting.GetStorageAllocationSettingData()
+		setting.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, sasd := range sasds {
+			if resource.ResourcePool_ResourceType(sasd.ResourceType) == resource.ResourcePool_ResourceType_Logical_Disk && len(sasd.HostResource) > 0 {
+				disks = append(disks, sasd)
+			} else {
+				sasd.Close()
+			}
+		}
+	}
+	return disks, nil
+}
+
+// relocate moves the configuration of the planned system to destination and copies its disks, along
+// with their parents, to the Virtual Hard Disks directory of destination.
+func (p *PlannedVirtualMachine) relocate(destination string) error {
+	vsms, err := virtualSystemManagementService(p.client)
+	if err != nil {
+		return err
+	}
+	ims, err := imageManagementService(p.client)
+	if err != nil {
+		return err
+	}
+
+	settings, err := p.planned.GetVirtualSystemSettingData()
+	if err != nil {
+		return err
+	}
+	defer settings.Close()
+	for _, name := range []string{"ConfigurationDataRoot", "SnapshotDataRoot", "SwapFileDataRoot"} {
+		if err = settings.Put(name, destination); err != nil {
+			return err
+		}
+	}
+	if err = wmiext.AwaitJob(vsms.ModifySystemSettings(settings.GetCimText())); err != nil {
+		return err
+	}
+
+	disks, err := p.virtualHardDisks()
+	if err != nil {
+		return err
+	}
+	defer closeAll(disks)
+	dir := filepath.Join(destination, "Virtual Hard Disks")
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// names are the lower case names of the copies, for disks of the same name in different folders
+	// not to collide
+	names := map[string]bool{}
+	// copies are the paths of the copied files, by lower case source path
+	copies := map[string]string{}
+	var modified []string
+	for _, disk := range disks {
+		copied, err := copyVirtualHardDiskChain(ims, disk.HostResource[0], dir, names, copies)
+		if errors.Is(err, hverrors.ErrFileNotFound) {
+			// Left to RemapVirtualHardDisk
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err = disk.SetHostResource([]string{copied}); err != nil {
+			return err
+		}
+		modified = append(modified, disk.GetCimText())
+	}
+	if len(modified) > 0 {
+		err = modifyResourceSettings(vsms, modified)
+	}
+	return err
+}
+
+// modifyResourceSettings modifies the resource settings, closing the resulting instances.
+func modifyResourceSettings(vsms *virtual_system.VirtualSystemManagementService, settings []string) error {
+	results, err := vsms.ModifyResourceSettings(settings)
+	closeAll(results)
+	return err
+}
+
+// closeAll closes the instances.
+func closeAll[T interface{ Close() }](instances []T) {
+	for _, instance := range instances {
+		instance.Close()
+	}
+}
+
+// copyVirtualHardDiskChain copies the disk at path and its parents to dir, unless already in copies,
+// and points the copied differencing disks to the copied parents. The copies are named uniquely
+// among names. It returns the path of the copy.
+func copyVirtualHardDiskChain(ims *storage.ImageManagementService, path string, dir string, names map[string]bool, copies map[string]string) (string, error) {
+	if copied, ok := copies[strings.ToLower(path)]; ok {
+		return copied, nil
+	}
+	chain, err := ims.GetVirtualHardDiskChain(path)
+	if err != nil {
+		return "", err
+	}
+	// From the base disk up, for the parents to be copied first
+	for i := len(chain) - 1; i >= 0; i-- {
+		source := chain[i].Path
+		if source == "" {
+			source = path
+		}
+		if _, ok := copies[strings.ToLower(source)]; ok {
+			continue
+		}
+		copied := filepath.Join(dir, uniqueFileName(fileName(source), names))
+		if err = copyFile(source, copied); err != nil {
+			return "", err
+		}
+		if parent := chain[i].ParentPath; parent != "" {
+			if err = wmiext.AwaitJob(ims.SetParentVirtualHardDisk(copied, copies[strings.ToLower(parent)])); err != nil {
+				return "", err
+			}
+		}
+		copies[strings.ToLower(source)] = copied
+	}
+	return copies[strings.ToLower(path)], nil
+}
+
+func copyFile(source string, target string) (err error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// Validate 检查计划虚拟机与主机的不兼容之处
+//
+// 先检查缺失的虚拟硬盘与虚拟交换机, 均存在时再由 Hyper-V 校验计划虚拟机, 校验失败时作为 IncompatibilityOther 返回
+func (p *PlannedVirtualMachine) Validate(opts ...wmiext.JobOption) ([]Incompatibility, error) {
+	ims, err := imageManagementService(p.client)
+	if err != nil {
+		return nil, err
+	}
+	var incompatibilities []Incompatibility
+
+	disks, err := p.virtualHardDisks()
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(disks)
+	checked := map[string]bool{}
+	for _, disk := range disks {
+		path := disk.HostResource[0]
+		if checked[strings.ToLower(path)] {
+			continue
+		}
+		checked[strings.ToLower(path)] = true
+		if _, err = ims.GetVirtualHardDiskChain(path); errors.Is(err, hverrors.ErrFileNotFound) {
+			incompatibilities = append(incompatibilities, Incompatibility{
+				Kind:     IncompatibilityMissingVirtualHardDisk,
+				Resource: path,
+				Message:  fmt.Sprintf("virtual hard disk %s, or one of its parents, is missing", path),
+			})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	settings, err := p.planned.GetVirtualSystemSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer settings.Close()
+	connections, err := settings.GetEthernetPortAllocationSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(connections)
+	for _, connection := range connections {
+		if len(connection.HostResource) == 0 || connection.HostResource[0] == "" {
+			continue
+		}
+		vswitch, err := p.planned.GetService().GetObject(connection.HostResource[0])
+		if isMissingObject(err) {
+			name, err := p.adapterName(connection.Parent)
+			if err != nil {
+				return nil, err
+			}
+			incompatibilities = append(incompatibilities, Incompatibility{
+				Kind:     IncompatibilityMissingVirtualSwitch,
+				Resource: name,
+				Message:  fmt.Sprintf("network adapter [%s] is connected to a missing virtual switch", name),
+			})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		vswitch.Close()
+	}
+	if len(incompatibilities) > 0 {
+		return incompatibilities, nil
+	}
+
+	vsms, err := virtualSystemManagementService(p.client)
+	if err != nil {
+		return nil, err
+	}
+	var hyperVErr *HyperVError
+	if err = wmiext.AwaitJob(vsms.ValidatePlannedSystem(p.planned, opts...)); errors.As(err, &hyperVErr) {
+		incompatibilities = append(incompatibilities, Incompatibility{Kind: IncompatibilityOther, Message: err.Error()})
+	} else if err != nil {
+		return nil, err
+	}
+	return incompatibilities, nil
+}
+
+// isMissingObject reports whether err tells that the object retrieved does not exist.
+func isMissingObject(err error) bool {
+	var wmiErr *wmiext.WmiError
+	if errors.As(err, &wmiErr) {
+		return wmiErr.Code() == uintptr(wmiext.WBEM_E_NOT_FOUND)
+	}
+	return errors.Is(err, wmiext.NotFound)
+}
+
+// adapterName returns the name of the network adapter at path.
+func (p *PlannedVirtualMachine) adapterName(path string) (string, error) {
+	adapter, err := p.planned.GetService().GetObject(path)
+	if err != nil {
+		return "", err
+	}
+	defer adapter.Close()
+	return adapter.GetAsString("ElementName")
+}
+
+// RemapVirtualHardDisk 将计划虚拟机及其检查点使用的虚拟硬盘 oldPath 替换为 newPath
+func (p *PlannedVirtualMachine) RemapVirtualHardDisk(oldPath string, newPath string) error {
+	vsms, err := virtualSystemManagementService(p.client)
+	if err != nil {
+		return err
+	}
+	disks, err := p.virtualHardDisks()
+	if err != nil {
+		return err
+	}
+	defer closeAll(disks)
+	var modified []string
+	for _, disk := range disks {
+		if !strings.EqualFold(disk.HostResource[0], oldPath) {
+			continue
+		}
+		if err = disk.SetHostResource([]string{newPath}); err != nil {
+			return err
+		}
+		modified = append(modified, disk.GetCimText())
+	}
+	if len(modified) == 0 {
+		return errors.Wrapf(wmiext.NotFound, "virtual hard disk [%s] of planned virtual machine [%s]", oldPath, p.Name)
+	}
+	return modifyResourceSettings(vsms, modified)
+}
+
+// RemapVirtualSwitch 将计划虚拟机的网络适配器 adapterName 连接到虚拟交换机 switchName
+func (p *PlannedVirtualMachine) RemapVirtualSwitch(adapterName string, switchName string) error {
+	vesms, err := virtualEthernetSwitchManagementService(p.client)
+	if err != nil {
+		return err
+	}
+	vsms, err := virtualSystemManagementService(p.client)
+	if err != nil {
+		return err
+	}
+	vswitch, err := vesms.FirstVirtualSwitchByName(switchName)
+	if err != nil {
+		return err
+	}
+	defer vswitch.Close()
+
+	settings, err := p.planned.GetVirtualSystemSettingData()
+	if err != nil {
+		return err
+	}
+	defer settings.Close()
+	connections, err := settings.GetEthernetPortAllocationSettingData()
+	if err != nil {
+		return err
+	}
+	defer closeAll(connections)
+	var modified []string
+	for _, connection := range connections {
+		name, err := p.adapterName(connection.Parent)
+		if err != nil {
+			return err
+		}
+		if name != adapterName {
+			continue
+		}
+		if err = connection.SetHostResource([]string{vswitch.Path()}); err != nil {
+			return err
+		}
+		modified = append(modified, connection.GetCimText())
+	}
+	if len(modified) == 0 {
+		return errors.Wrapf(wmiext.NotFound, "network adapter [%s] of planned virtual machine [%s]", adapterName, p.Name)
+	}
+	return modifyResourceSettings(vsms, modified)
+}
+
+// Realize 实现计划虚拟机, 返回注册到主机的虚拟机
+func (p *PlannedVirtualMachine) Realize(opts ...wmiext.JobOption) (*VirtualMachine, error) {
+	vsms, err := virtualSystemManagementService(p.client)
+	if err != nil {
+		return nil, err
+	}
+	system, err := wmiext.Await(vsms.RealizePlannedSystem(p.planned, opts...))
+	if err != nil {
+		return nil, err
+	}
+	return newVirtualMachine(p.client, system, FieldsHardware)
+}
+
+// Discard 放弃导入, 删除计划虚拟机, 导出目录与已复制的文件保持不变
+func (p *PlannedVirtualMachine) Discard() error {
+	vsms, err := virtualSystemManagementService(p.client)
+	if err != nil {
+		return err
+	}
+	return vsms.DestroyPlannedSystem(p.planned)
+}
@@ -0,0 +1,438 @@
+package hyperv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plannedHost imports, validates and realizes planned systems like Hyper-V does, from an export
+// whose disks are files of a temporary directory.
+type plannedHost struct {
+	t    *testing.T
+	repo *wmiext.MemoryRepository
+	// disks are the disks of the planned system, followed by those of its snapshot
+	disks, snapshotDisks []string
+	// parents are the parents of the differencing disks, by path
+	parents map[string]string
+	// paths are the paths of the settings, by InstanceID
+	paths map[string]string
+	// validation fails the validation of planned systems when set
+	validation string
+
+	imports   [][]interface{}
+	reparents [][]string
+	exports   []map[string]interface{}
+	destroyed []string
+}
+
+func newPlannedHost(t *testing.T, repo *wmiext.MemoryRepository) *plannedHost {
+	host := &plannedHost{t: t, repo: repo, parents: map[string]string{}, paths: map[string]string{}}
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_VirtualSystemManagementService",
+		Keys: []string{"CreationClassName", "Name"},
+		Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+			"ImportSystemDefinition": {
+				"SystemDefinitionFile": wmiext.CIM_STRING, "SnapshotFolder": wmiext.CIM_STRING, "GenerateNewSystemIdentifier": wmiext.CIM_BOOLEAN,
+				"ImportedSystem": wmiext.CIM_REFERENCE, "Job": wmiext.CIM_REFERENCE,
+			},
+			"ValidatePlannedSystem": {"PlannedSystem": wmiext.CIM_REFERENCE, "Job": wmiext.CIM_REFERENCE},
+			"RealizePlannedSystem":  {"PlannedSystem": wmiext.CIM_REFERENCE, "ResultingSystem": wmiext.CIM_REFERENCE, "Job": wmiext.CIM_REFERENCE},
+			"ExportSystemDefinition": {
+				"ComputerSystem": wmiext.CIM_REFERENCE, "ExportDirectory": wmiext.CIM_STRING, "ExportSettingData": wmiext.CIM_STRING,
+				"Job": wmiext.CIM_REFERENCE,
+			},
+			"ModifySystemSettings": {"SystemSettings": wmiext.CIM_STRING, "Job": wmiext.CIM_REFERENCE},
+			"ModifyResourceSettings": {
+				"ResourceSettings":          wmiext.CIM_STRING | wmiext.CIM_FLAG_ARRAY,
+				"ResultingResourceSettings": wmiext.CIM_REFERENCE | wmiext.CIM_FLAG_ARRAY,
+				"Job":                       wmiext.CIM_REFERENCE,
+			},
+			"DestroySystem": {"AffectedSystem": wmiext.CIM_REFERENCE, "Job": wmiext.CIM_REFERENCE},
+		},
+	})
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_ImageManagementService",
+		Keys: []string{"CreationClassName", "Name"},
+		Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+			"GetVirtualHardDiskSettingData": {"Path": wmiext.CIM_STRING, "SettingData": wmiext.CIM_STRING, "Job": wmiext.CIM_REFERENCE},
+			"SetVirtualHardDiskSettingData": {"VirtualDiskSettingData": wmiext.CIM_STRING, "Job": wmiext.CIM_REFERENCE},
+		},
+	})
+	repo.DefineClass(wmiext.MemoryClass{Name: "Msvm_PlannedComputerSystem", Keys: []string{"CreationClassName", "Name"}})
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_VirtualSystemExportSettingData",
+		Properties: map[string]wmiext.CIMTYPE_ENUMERATION{
+			"CopySnapshotConfiguration":  wmiext.CIM_UINT8,
+			"CopyVmRuntimeInformation":   wmiext.CIM_BOOLEAN,
+			"CopyVmStorage":              wmiext.CIM_BOOLEAN,
+			"CreateVmExportSubdirectory": wmiext.CIM_BOOLEAN,
+			"SnapshotVirtualSystem":      wmiext.CIM_STRING,
+		},
+	})
+	repo.DefineClass(wmiext.MemoryClass{
+		Name:       "Msvm_VirtualHardDiskSettingData",
+		Properties: map[string]wmiext.CIMTYPE_ENUMERATION{"Path": wmiext.CIM_STRING, "ParentPath": wmiext.CIM_STRING},
+	})
+	defineSettingAssociations(repo)
+
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "ImportSystemDefinition", host.importSystem)
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "ValidatePlannedSystem", host.validate)
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "RealizePlannedSystem", host.realize)
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "ExportSystemDefinition", host.export)
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "ModifySystemSettings", func(call *wmiext.MethodCall) error {
+		call.Return(0)
+		return host.modify(call.InString("SystemSettings"), "ConfigurationDataRoot", "SnapshotDataRoot", "SwapFileDataRoot")
+	})
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "ModifyResourceSettings", func(call *wmiext.MethodCall) error {
+		for _, text := range call.InStrings("ResourceSettings") {
+			if err := host.modify(text, "HostResource"); err != nil {
+				return err
+			}
+		}
+		call.Return(0)
+		return nil
+	})
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "DestroySystem", func(call *wmiext.MethodCall) error {
+		host.destroyed = append(host.destroyed, call.InString("AffectedSystem"))
+		call.Return(0)
+		return call.Repository.Delete(call.InString("AffectedSystem"))
+	})
+	repo.HandleMethod("Msvm_ImageManagementService", "GetVirtualHardDiskSettingData", func(call *wmiext.MethodCall) error {
+		path := call.InString("Path")
+		if _, err := os.Stat(path); err != nil {
+			call.Return(32779)
+			return nil
+		}
+		call.Out("SettingData", fmt.Sprintf(`<INSTANCE CLASSNAME="Msvm_VirtualHardDiskSettingData">`+
+			`<PROPERTY NAME="Path" TYPE="string"><VALUE>%s</VALUE></PROPERTY>`+
+			`<PROPERTY NAME="ParentPath" TYPE="string"><VALUE>%s</VALUE></PROPERTY>`+
+			`</INSTANCE>`, path, host.parents[path]))
+		call.Return(0)
+		return nil
+	})
+	repo.HandleMethod("Msvm_ImageManagementService", "SetVirtualHardDiskSettingData", func(call *wmiext.MethodCall) error {
+		settings, err := wmiext.DecodeCimXml(call.InString("VirtualDiskSettingData"))
+		if err != nil {
+			return err
+		}
+		path, _, _, err := settings.Get("Path")
+		if err != nil {
+			return err
+		}
+		parent, _, _, err := settings.Get("ParentPath")
+		if err != nil {
+			return err
+		}
+		host.parents[path.(string)] = parent.(string)
+		host.reparents = append(host.reparents, []string{path.(string), parent.(string)})
+		call.Return(0)
+		return nil
+	})
+	return host
+}
+
+func (h *plannedHost) add(class string, properties map[string]interface{}) string {
+	path, err := h.repo.AddInstance(class, properties)
+	require.NoError(h.t, err)
+	if id, ok := properties["InstanceID"].(string); ok {
+		h.paths[id] = path
+	}
+	return path
+}
+
+func (h *plannedHost) get(path string, name string) interface{} {
+	object, err := h.repo.Get(path)
+	require.NoError(h.t, err)
+	value, _, _, err := object.Get(name)
+	require.NoError(h.t, err)
+	return value
+}
+
+// addSettings adds planned settings of guid, along with their disks.
+func (h *plannedHost) addSettings(guid string, id string, systemType string, disks []string) string {
+	settingPath := h.add("Msvm_VirtualSystemSettingData", map[string]interface{}{
+		"InstanceID": id, "ElementName": "vm-1", "VirtualSystemIdentifier": guid, "VirtualSystemType": systemType,
+	})
+	for i, disk := range disks {
+		diskPath := h.add("Msvm_StorageAllocationSettingData", map[string]interface{}{
+			"InstanceID": fmt.Sprintf(`%s\disk-%d`, id, i), "ResourceType": uint16(31), "HostResource": []string{disk},
+		})
+		h.add("Msvm_VirtualSystemSettingDataComponent", map[string]interface{}{
+			"GroupComponent": wmiext.Reference(settingPath), "PartComponent": wmiext.Reference(diskPath),
+		})
+	}
+	return settingPath
+}
+
+func (h *plannedHost) importSystem(call *wmiext.MethodCall) error {
+	generate, _ := call.In("GenerateNewSystemIdentifier").(bool)
+	h.imports = append(h.imports, []interface{}{call.InString("SystemDefinitionFile"), call.InString("SnapshotFolder"), generate})
+	guid := "A0B1"
+	if generate {
+		guid = fmt.Sprintf("E4F%d", len(h.imports))
+	}
+
+	systemPath := h.add("Msvm_PlannedComputerSystem", map[string]interface{}{
+		"CreationClassName": "Msvm_PlannedComputerSystem", "Name": guid, "ElementName": "vm-1",
+	})
+	settingPath := h.addSettings(guid, "Microsoft:Planned:"+guid, "Microsoft:Hyper-V:System:Planned", h.disks)
+	h.add("Msvm_SettingsDefineState", map[string]interface{}{
+		"ManagedElement": wmiext.Reference(systemPath), "SettingData": wmiext.Reference(settingPath),
+	})
+	h.addSettings(guid, "Microsoft:PlannedSnapshot:"+guid, "Microsoft:Hyper-V:Snapshot:Planned", h.snapshotDisks)
+
+	// The adapter stays connected to the switch of the exporting host
+	adapterPath := h.add("Msvm_SyntheticEthernetPortSettingData", map[string]interface{}{
+		"InstanceID": `Microsoft:Planned:` + guid + `\adapter`, "ElementName": "Network Adapter",
+	})
+	connectionPath := h.add("Msvm_EthernetPortAllocationSettingData", map[string]interface{}{
+		"InstanceID": `Microsoft:Planned:` + guid + `\connection`, "Parent": adapterPath,
+		"HostResource": []string{`\\OTHER\root\virtualization\v2:Msvm_VirtualEthernetSwitch.CreationClassName="Msvm_VirtualEthernetSwitch",Name="9F8E"`},
+	})
+	for _, part := range []string{adapterPath, connectionPath} {
+		h.add("Msvm_VirtualSystemSettingDataComponent", map[string]interface{}{
+			"GroupComponent": wmiext.Reference(settingPath), "PartComponent": wmiext.Reference(part),
+		})
+	}
+
+	call.Out("ImportedSystem", systemPath)
+	call.Return(0)
+	return nil
+}
+
+func (h *plannedHost) validate(call *wmiext.MethodCall) error {
+	if h.validation == "" {
+		call.Return(0)
+		return nil
+	}
+	job, err := h.repo.AddInstance("Msvm_ConcreteJob", map[string]interface{}{
+		"InstanceID": "validation", "JobState": uint16(wmiext.JobStateException), "ErrorCode": uint16(32768),
+		"ErrorDescription": h.validation,
+	})
+	if err != nil {
+		return err
+	}
+	call.Out("Job", wmiext.Reference(job))
+	call.Return(4096)
+	return nil
+}
+
+func (h *plannedHost) realize(call *wmiext.MethodCall) error {
+	planned := call.InString("PlannedSystem")
+	guid, _ := h.get(planned, "Name").(string)
+	systemPath, _ := addAssociatedMachine(h.t, h.repo, "vm-1", guid)
+	call.Out("ResultingSystem", systemPath)
+	call.Return(0)
+	return h.repo.Delete(planned)
+}
+
+func (h *plannedHost) export(call *wmiext.MethodCall) error {
+	settings, err := wmiext.DecodeCimXml(call.InString("ExportSettingData"))
+	if err != nil {
+		return err
+	}
+	exported := map[string]interface{}{"ExportDirectory": call.InString("ExportDirectory")}
+	for _, name := range []string{"CopySnapshotConfiguration", "CopyVmRuntimeInformation", "CopyVmStorage", "CreateVmExportSubdirectory"} {
+		if exported[name], _, _, err = settings.Get(name); err != nil {
+			return err
+		}
+	}
+	h.exports = append(h.exports, exported)
+	call.Return(0)
+	return nil
+}
+
+// modify updates the properties of the settings whose embedded instance is text.
+func (h *plannedHost) modify(text string, names ...string) error {
+	settings, err := wmiext.DecodeCimXml(text)
+	if err != nil {
+		return err
+	}
+	id, _, _, err := settings.Get("InstanceID")
+	if err != nil {
+		return err
+	}
+	properties := map[string]interface{}{}
+	for _, name := range names {
+		if value, _, _, err := settings.Get(name); err == nil && value != nil {
+			properties[name] = value
+		}
+	}
+	return h.repo.Update(h.paths[id.(string)], properties)
+}
+
+// writeExport lays out an exported virtual machine in a temporary directory, whose disks are a
+// differencing disk of the running machine and its parent, used by the snapshot.
+func (h *plannedHost) writeExport() string {
+	root := filepath.Join(h.t.TempDir(), "vm-1")
+	for _, dir := range []string{"Virtual Machines", "Snapshots", "Virtual Hard Disks"} {
+		require.NoError(h.t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	require.NoError(h.t, os.WriteFile(filepath.Join(root, "Virtual Machines", "A0B1.vmcx"), []byte("definition"), 0o644))
+	base := filepath.Join(root, "Virtual Hard Disks", "vm-1.vhdx")
+	child := filepath.Join(root, "Virtual Hard Disks", "vm-1_6F1D.avhdx")
+	require.NoError(h.t, os.WriteFile(base, []byte("base"), 0o644))
+	require.NoError(h.t, os.WriteFile(child, []byte("child"), 0o644))
+	h.parents[child] = base
+	h.disks = []string{child}
+	h.snapshotDisks = []string{base}
+	return root
+}
+
+func TestVirtualMachine_Export(t *testing.T) {
+	c, repo := newTestClient(t)
+	host := newPlannedHost(t, repo)
+	addAssociatedMachine(t, repo, "vm-1", "A0B1")
+	vm, err := c.FirstVirtualMachineByName("vm-1")
+	require.NoError(t, err)
+
+	require.NoError(t, vm.Export(`D:\exports`, ExportOptions{Mode: ExportWithCheckpoints}))
+	require.NoError(t, vm.Export(`D:\exports`, ExportOptions{}))
+	require.NoError(t, vm.Export(`D:\exports\vm-1`, ExportOptions{Mode: ExportConfigurationOnly, RuntimeState: true, NoSubdirectory: true}))
+	require.Len(t, host.exports, 3)
+	assert.Equal(t, map[string]interface{}{
+		"ExportDirectory": `D:\exports`, "CopySnapshotConfiguration": uint8(0), "CopyVmRuntimeInformation": false,
+		"CopyVmStorage": true, "CreateVmExportSubdirectory": true,
+	}, host.exports[0])
+	assert.Equal(t, uint8(1), host.exports[1]["CopySnapshotConfiguration"])
+	assert.Equal(t, true, host.exports[1]["CopyVmStorage"])
+	assert.Equal(t, map[string]interface{}{
+		"ExportDirectory": `D:\exports\vm-1`, "CopySnapshotConfiguration": uint8(1), "CopyVmRuntimeInformation": true,
+		"CopyVmStorage": false, "CreateVmExportSubdirectory": false,
+	}, host.exports[2])
+
+	assert.Error(t, vm.Export(`D:\exports`, ExportOptions{Mode: ExportMode(7)}))
+	assert.Len(t, host.exports, 3)
+}
+
+func TestImportVirtualMachine_RegisterInPlace(t *testing.T) {
+	c, repo := newTestClient(t)
+	host := newPlannedHost(t, repo)
+	root := host.writeExport()
+	// The second disk was left behind by the export
+	missing := filepath.Join(root, "Virtual Hard Disks", "data.vhdx")
+	host.disks = append(host.disks, missing)
+	switchPath, err := repo.AddInstance("Msvm_VirtualEthernetSwitch", map[string]interface{}{
+		"CreationClassName": "Msvm_VirtualEthernetSwitch", "Name": "5C2E", "ElementName": "external",
+	})
+	require.NoError(t, err)
+
+	planned, err := c.ImportVirtualMachine(root, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "vm-1", planned.Name)
+	assert.Equal(t, [][]interface{}{{filepath.Join(root, "Virtual Machines", "A0B1.vmcx"), filepath.Join(root, "Snapshots"), false}}, host.imports)
+
+	incompatibilities, err := planned.Validate()
+	require.NoError(t, err)
+	assert.Equal(t, []Incompatibility{
+		{Kind: IncompatibilityMissingVirtualHardDisk, Resource: missing, Message: fmt.Sprintf("virtual hard disk %s, or one of its parents, is missing", missing)},
+		{Kind: IncompatibilityMissingVirtualSwitch, Resource: "Network Adapter", Message: "network adapter [Network Adapter] is connected to a missing virtual switch"},
+	}, incompatibilities)
+
+	// Remapping fixes the incompatibilities before Hyper-V validates the rest
+	data := filepath.Join(t.TempDir(), "data.vhdx")
+	require.NoError(t, os.WriteFile(data, []byte("data"), 0o644))
+	require.NoError(t, planned.RemapVirtualHardDisk(missing, data))
+	require.NoError(t, planned.RemapVirtualSwitch("Network Adapter", "external"))
+	assert.Equal(t, []interface{}{data}, host.get(host.paths[`Microsoft:Planned:A0B1\disk-1`], "HostResource"))
+	assert.Equal(t, []interface{}{switchPath}, host.get(host.paths[`Microsoft:Planned:A0B1\connection`], "HostResource"))
+	assert.ErrorIs(t, planned.RemapVirtualSwitch("Legacy Network Adapter", "external"), wmiext.NotFound)
+	assert.ErrorIs(t, planned.RemapVirtualHardDisk(missing, data), wmiext.NotFound)
+
+	host.validation = "The processor of the host is incompatible."
+	incompatibilities, err = planned.Validate()
+	require.NoError(t, err)
+	require.Len(t, incompatibilities, 1)
+	assert.Equal(t, IncompatibilityOther, incompatibilities[0].Kind)
+	assert.Contains(t, incompatibilities[0].Message, host.validation)
+
+	host.validation = ""
+	incompatibilities, err = planned.Validate()
+	require.NoError(t, err)
+	assert.Empty(t, incompatibilities)
+
+	vm, err := planned.Realize()
+	require.NoError(t, err)
+	assert.Equal(t, "vm-1", vm.Name)
+	assert.Equal(t, 2, vm.CpuCoreCount)
+	assert.Empty(t, host.destroyed)
+}
+
+func TestImportVirtualMachine_Copy(t *testing.T) {
+	c, repo := newTestClient(t)
+	host := newPlannedHost(t, repo)
+	root := host.writeExport()
+	definition := filepath.Join(root, "Virtual Machines", "A0B1.vmcx")
+	destination := filepath.Join(t.TempDir(), "vm-2")
+
+	_, err := c.ImportVirtualMachine(root, ImportOptions{Mode: ImportCopy})
+	assert.Error(t, err)
+	assert.Empty(t, host.imports)
+
+	planned, err := c.ImportVirtualMachine(definition, ImportOptions{Mode: ImportGenerateNewID, Destination: destination})
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{definition, filepath.Join(root, "Snapshots"), true}}, host.imports)
+	settingPath := host.paths["Microsoft:Planned:E4F1"]
+	for _, name := range []string{"ConfigurationDataRoot", "SnapshotDataRoot", "SwapFileDataRoot"} {
+		assert.Equal(t, destination, host.get(settingPath, name))
+	}
+
+	// The shared parent is copied once and the copied child points to the copy
+	base := filepath.Join(destination, "Virtual Hard Disks", "vm-1.vhdx")
+	child := filepath.Join(destination, "Virtual Hard Disks", "vm-1_6F1D.avhdx")
+	for path, content := range map[string]string{base: "base", child: "child"} {
+		copied, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(copied))
+	}
+	assert.Equal(t, [][]string{{child, base}}, host.reparents)
+	assert.Equal(t, []interface{}{child}, host.get(host.paths[`Microsoft:Planned:E4F1\disk-0`], "HostResource"))
+	assert.Equal(t, []interface{}{base}, host.get(host.paths[`Microsoft:PlannedSnapshot:E4F1\disk-0`], "HostResource"))
+
+	incompatibilities, err := planned.Validate()
+	require.NoError(t, err)
+	require.Len(t, incompatibilities, 1)
+	assert.Equal(t, IncompatibilityMissingVirtualSwitch, incompatibilities[0].Kind)
+
+	// Discarding leaves the files in place
+	require.NoError(t, planned.Discard())
+	require.Len(t, host.destroyed, 1)
+	_, err = repo.Get(host.destroyed[0])
+	assert.Error(t, err)
+	_, err = os.Stat(child)
+	assert.NoError(t, err)
+
+	// Copying over existing files fails and discards the planned system
+	_, err = c.ImportVirtualMachine(root, ImportOptions{Mode: ImportCopy, Destination: destination})
+	assert.ErrorIs(t, err, os.ErrExist)
+	assert.Len(t, host.destroyed, 2)
+}
+
+func TestImportVirtualMachine_CopySameFileNames(t *testing.T) {
+	c, repo := newTestClient(t)
+	host := newPlannedHost(t, repo)
+	root := host.writeExport()
+	// A disk of another folder named as the parent of the differencing disk
+	other := filepath.Join(t.TempDir(), "vm-1.vhdx")
+	require.NoError(t, os.WriteFile(other, []byte("other"), 0o644))
+	host.disks = append(host.disks, other)
+	destination := filepath.Join(t.TempDir(), "vm-2")
+
+	_, err := c.ImportVirtualMachine(root, ImportOptions{Mode: ImportCopy, Destination: destination})
+	require.NoError(t, err)
+	base := filepath.Join(destination, "Virtual Hard Disks", "vm-1.vhdx")
+	copied := filepath.Join(destination, "Virtual Hard Disks", "vm-1-1.vhdx")
+	for path, content := range map[string]string{base: "base", copied: "other"} {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+	assert.Equal(t, []interface{}{copied}, host.get(host.paths[`Microsoft:Planned:A0B1\disk-1`], "HostResource"))
+	assert.Equal(t, []interface{}{base}, host.get(host.paths[`Microsoft:PlannedSnapshot:A0B1\disk-0`], "HostResource"))
+}
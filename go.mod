@@ -13,7 +13,6 @@ require (
 
 require (
 	github.com/go-ole/go-ole v1.3.0
-	github.com/sirupsen/logrus v1.9.3
 	golang.org/x/sys v0.31.0
 )
 
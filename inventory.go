@@ -0,0 +1,446 @@
+package hyperv
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/memory"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/network_adapter"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking/switch_extension"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/processor"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/resource"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage/allocation"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage/disk"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+// Inventory 主机清单快照, 包含全部虚拟机及其虚拟硬盘、网络适配器, 以及全部虚拟交换机
+//
+// 清单中的对象与逐个获取的对象相同, 可以直接执行操作, 但其字段不会随主机状态更新
+type Inventory struct {
+	VirtualMachines []*VirtualMachine
+	VirtualSwitches []*VirtualSwitch
+
+	hardDisks       map[*VirtualMachine][]*VirtualHardDisk
+	networkAdapters map[*VirtualMachine][]*VirtualNetworkAdapter
+	switches        map[*VirtualNetworkAdapter]*VirtualSwitch
+}
+
+// VirtualHardDisks 返回虚拟机挂载的虚拟硬盘
+func (inv *Inventory) VirtualHardDisks(vm *VirtualMachine) []*VirtualHardDisk {
+	return inv.hardDisks[vm]
+}
+
+// VirtualNetworkAdapters 返回虚拟机的网络适配器
+func (inv *Inventory) VirtualNetworkAdapters(vm *VirtualMachine) []*VirtualNetworkAdapter {
+	return inv.networkAdapters[vm]
+}
+
+// VirtualSwitch 返回网络适配器连接的虚拟交换机, 未连接时返回 nil
+func (inv *Inventory) VirtualSwitch(vna *VirtualNetworkAdapter) *VirtualSwitch {
+	return inv.switches[vna]
+}
+
+// FirstVirtualMachineByName 根据名称获取清单中的第一个虚拟机, 不存在时返回 nil
+func (inv *Inventory) FirstVirtualMachineByName(name string) *VirtualMachine {
+	for _, vm := range inv.VirtualMachines {
+		if vm.Name == name {
+			return vm
+		}
+	}
+	return nil
+}
+
+// LoadInventory 加载主机清单快照
+//
+// 每个相关的 Msvm 类只查询一次, 再按 InstanceID、Parent 与 HostResource 在内存中关联, 查询次数与虚拟机数量无关.
+// 虚拟硬盘的最大容量仍需对每个硬盘调用一次 GetVirtualHardDiskSettingData, 文件不存在的硬盘容量为 0
+func (c *Client) LoadInventory() (*Inventory, error) {
+	session, err := c.service()
+	if err != nil {
+		return nil, err
+	}
+	loader := &inventoryLoader{client: c, session: session}
+
+	inv := &Inventory{
+		hardDisks:       make(map[*VirtualMachine][]*VirtualHardDisk),
+		networkAdapters: make(map[*VirtualMachine][]*VirtualNetworkAdapter),
+		switches:        make(map[*VirtualNetworkAdapter]*VirtualSwitch),
+	}
+	if inv.VirtualSwitches, err = loader.loadVirtualSwitches(); err != nil {
+		return nil, err
+	}
+	if inv.VirtualMachines, err = loader.loadVirtualMachines(); err != nil {
+		return nil, err
+	}
+	if err = loader.loadVirtualHardDisks(inv); err != nil {
+		return nil, err
+	}
+	if err = loader.loadVirtualNetworkAdapters(inv); err != nil {
+		return nil, err
+	}
+	// The machines are fully loaded, their disks and adapters are cached until refreshed
+	for _, vm := range inv.VirtualMachines {
+		vm.virtualHardDisks = inv.hardDisks[vm]
+		vm.virtualNetworkAdapters = inv.networkAdapters[vm]
+		vm.loaded = FieldsAll
+	}
+	return inv, nil
+}
+
+// LoadInventory is Client.LoadInventory on the default client.
+func LoadInventory() (*Inventory, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.LoadInventory()
+}
+
+// inventoryLoader fetches the instances of each class once and joins them in memory.
+type inventoryLoader struct {
+	client  *Client
+	session *wmiext.Service
+
+	// settings are the realized virtual system settings by owner, see settingOwner
+	settings map[string]*virtual_system.VirtualSystemSettingData
+	// machines are the virtual machines by owner
+	machines map[string]*VirtualMachine
+	// switchesByPath are the virtual switches by normalized path
+	switchesByPath map[string]*VirtualSwitch
+}
+
+// settingOwner returns the key of the virtual system owning a setting. The InstanceID of the
+// settings of a virtual system, or of one of its snapshots, starts with the InstanceID of its
+// virtual system settings, Microsoft:<GUID>, followed by a backslash.
+func settingOwner(instanceID string) string {
+	if i := strings.IndexByte(instanceID, '\\'); i >= 0 {
+		instanceID = instanceID[:i]
+	}
+	return strings.ToLower(instanceID)
+}
+
+// isSubsetting reports whether the setting with instanceID belongs to the one with ownerID.
+func isSubsetting(instanceID, ownerID string) bool {
+	return len(instanceID) > len(ownerID) && strings.EqualFold(instanceID[:len(ownerID)+1], ownerID+`\`)
+}
+
+// loadVirtualMachines loads the virtual machines along with their system, processor and memory
+// settings, in four queries.
+func (l *inventoryLoader) loadVirtualMachines() ([]*VirtualMachine, error) {
+	vsms, err := l.client.VirtualSystemManagementService()
+	if err != nil {
+		return nil, err
+	}
+	hostName, err := vsms.HostName()
+	if err != nil {
+		return nil, err
+	}
+	// Caption is localized, the host is told apart by its name
+	computerSystems, err := wmiext.Query[virtual_system.ComputerSystem](l.session,
+		wmiext.Select(virtual_system.Msvm_ComputerSystem).Where(wmiext.NotEqual("Name", hostName)))
+	if err != nil {
+		return nil, err
+	}
+	virtualSystemSettingData, err := wmiext.Query[virtual_system.VirtualSystemSettingData](l.session,
+		wmiext.Select(virtual_system.Msvm_VirtualSystemSettingData).
+			Where(wmiext.NotEqual("VirtualSystemType", virtual_system.VirtualSystemType_Snapshot)))
+	if err != nil {
+		return nil, err
+	}
+	processorSettingData, err := wmiext.Query[processor.ProcessorSettingData](l.session, wmiext.Select(processor.Msvm_ProcessorSettingData))
+	if err != nil {
+		return nil, err
+	}
+	memorySettingData, err := wmiext.Query[memory.MemorySettingsData](l.session, wmiext.Select(memory.Msvm_MemorySettingData))
+	if err != nil {
+		return nil, err
+	}
+
+	// The realized settings of a virtual machine are identified by its Name
+	settingsBySystem := make(map[string]*virtual_system.VirtualSystemSettingData, len(virtualSystemSettingData))
+	for _, settings := range virtualSystemSettingData {
+		settingsBySystem[strings.ToLower(settings.VirtualSystemIdentifier)] = settings
+	}
+	processors := make(map[string]*processor.ProcessorSettingData, len(processorSettingData))
+	for _, settings := range processorSettingData {
+		processors[settingOwner(settings.InstanceID)] = settings
+	}
+	memories := make(map[string]*memory.MemorySettingsData, len(memorySettingData))
+	for _, settings := range memorySettingData {
+		memories[settingOwner(settings.InstanceID)] = settings
+	}
+
+	l.settings = make(map[string]*virtual_system.VirtualSystemSettingData, len(computerSystems))
+	l.machines = make(map[string]*VirtualMachine, len(computerSystems))
+	vms := make([]*VirtualMachine, 0, len(computerSystems))
+	for _, cs := range computerSystems {
+		settings, ok := settingsBySystem[strings.ToLower(cs.Name)]
+		if !ok {
+			return nil, errors.Wrapf(wmiext.NotFound, "VirtualSystemSettingData not found for computerSystem [%s]", cs.ElementName)
+		}
+		owner := settingOwner(settings.InstanceID)
+		processorSettings, ok := processors[owner]
+		if !ok {
+			return nil, errors.Wrapf(wmiext.NotFound, "ProcessorSettingData not found for computerSystem [%s]", cs.ElementName)
+		}
+		memorySettings, ok := memories[owner]
+		if !ok {
+			return nil, errors.Wrapf(wmiext.NotFound, "MemorySettingData not found for computerSystem [%s]", cs.ElementName)
+		}
+
+		vm := &VirtualMachine{client: l.client}
+		vm.populate(cs, settings, processorSettings, memorySettings)
+		l.settings[owner] = settings
+		l.machines[owner] = vm
+		vms = append(vms, vm)
+	}
+	return vms, nil
+}
+
+// loadVirtualHardDisks loads the virtual hard disks of the virtual machines, typed by the
+// controller of their drive.
+func (l *inventoryLoader) loadVirtualHardDisks(inv *Inventory) error {
+	storageAllocationSettingData, err := wmiext.Query[allocation.StorageAllocationSettingData](l.session,
+		wmiext.Select(allocation.Msvm_StorageAllocationSettingData))
+	if err != nil {
+		return err
+	}
+	resourceAllocationSettingData, err := wmiext.Query[resource.ResourceAllocationSettingData](l.session,
+		wmiext.Select(resource.Msvm_ResourceAllocationSettingData))
+	if err != nil {
+		return err
+	}
+
+	resources := make(map[string]*resource.ResourceAllocationSettingData, len(resourceAllocationSettingData))
+	for _, settings := range resourceAllocationSettingData {
+		resources[objectpath.Normalize(settings.Path())] = settings
+	}
+
+	for _, settings := range storageAllocationSettingData {
+		vm, ok := l.machines[settingOwner(settings.InstanceID)]
+		if !ok || len(settings.HostResource) == 0 {
+			continue
+		}
+		drive, ok := resources[objectpath.Normalize(settings.Parent)]
+		if !ok {
+			return errors.Wrapf(wmiext.NotFound, "drive %s", settings.Parent)
+		}
+		controller, ok := resources[objectpath.Normalize(drive.Parent)]
+		if !ok {
+			return errors.Wrapf(wmiext.NotFound, "controller %s", drive.Parent)
+		}
+
+		path := settings.HostResource[0]
+		virtualHardDisk := &VirtualHardDisk{
+			Name:            fileName(path),
+			Path:            path,
+			Attached:        true,
+			VirtualHardDisk: &disk.VirtualHardDisk{StorageAllocationSettingData: settings},
+			client:          l.client,
+		}
+		if controller.ResourceType == uint16(resource.ResourcePool_ResourceType_IDE_Controller) {
+			virtualHardDisk.Type = VirtualHardDiskTypeSystem
+		} else if controller.ResourceType == uint16(resource.ResourcePool_ResourceType_Parallel_SCSI_HBA) {
+			virtualHardDisk.Type = VirtualHardDiskTypeData
+		} else {
+			return errors.New("unknown controller type")
+		}
+		if existsVirtualHardDiskByPath(path) {
+			if virtualHardDisk.UsedSizeGB, err = fileSizeGB(path); err != nil {
+				return err
+			}
+			maxSizeGB, err := getVirtualHardDiskMaxSize(l.client, path)
+			if err != nil {
+				return err
+			}
+			virtualHardDisk.TotalSizeGB = float64(maxSizeGB)
+		}
+		inv.hardDisks[vm] = append(inv.hardDisks[vm], virtualHardDisk)
+	}
+	return nil
+}
+
+// loadVirtualNetworkAdapters loads the synthetic network adapters of the virtual machines along
+// with their port allocation, bandwidth, VLAN and guest configuration.
+func (l *inventoryLoader) loadVirtualNetworkAdapters(inv *Inventory) error {
+	adapters, err := l.session.FindInstances(wmiext.Select(networking.Msvm_SyntheticEthernetPortSettingData).String())
+	if err != nil {
+		return err
+	}
+	portAllocationSettingData, err := wmiext.Query[networking.EthernetPortAllocationSettingData](l.session,
+		wmiext.Select(networking.Msvm_EthernetPortAllocationSettingData))
+	if err != nil {
+		return err
+	}
+	bandwidthSettingData, err := wmiext.Query[switch_extension.EthernetSwitchPortBandwidthSettingData](l.session,
+		wmiext.Select(switch_extension.Msvm_EthernetSwitchPortBandwidthSettingData))
+	if err != nil {
+		return err
+	}
+	vlanSettingData, err := wmiext.Query[switch_extension.EthernetSwitchPortVlanSettingData](l.session,
+		wmiext.Select(switch_extension.Msvm_EthernetSwitchPortVlanSettingData))
+	if err != nil {
+		return err
+	}
+	guestConfigurations, err := wmiext.Query[network_adapter.GuestNetworkAdapterConfiguration](l.session,
+		wmiext.Select(network_adapter.Msvm_GuestNetworkAdapterConfiguration))
+	if err != nil {
+		return err
+	}
+
+	// A port allocation refers to its adapter through Parent
+	portAllocations := make(map[string]*networking.EthernetPortAllocationSettingData, len(portAllocationSettingData))
+	for _, settings := range portAllocationSettingData {
+		if settings.Parent != "" {
+			portAllocations[objectpath.Normalize(settings.Parent)] = settings
+		}
+	}
+	bandwidths := make(map[string][]*switch_extension.EthernetSwitchPortBandwidthSettingData)
+	for _, settings := range bandwidthSettingData {
+		owner := settingOwner(settings.InstanceID)
+		bandwidths[owner] = append(bandwidths[owner], settings)
+	}
+	vlans := make(map[string][]*switch_extension.EthernetSwitchPortVlanSettingData)
+	for _, settings := range vlanSettingData {
+		owner := settingOwner(settings.InstanceID)
+		vlans[owner] = append(vlans[owner], settings)
+	}
+	// The guest configuration of the adapter Microsoft:<VM>\<Adapter> is Microsoft:GuestNetwork\<VM>\<Adapter>
+	configurations := make(map[string]*network_adapter.GuestNetworkAdapterConfiguration, len(guestConfigurations))
+	for _, configuration := range guestConfigurations {
+		configurations[strings.ToLower(configuration.InstanceID)] = configuration
+	}
+
+	for _, instance := range adapters {
+		virtualNetworkAdapter, err := network_adapter.NewVirtualNetworkAdapterFromInstance(instance)
+		if err != nil {
+			return err
+		}
+		owner := settingOwner(virtualNetworkAdapter.InstanceID)
+		vm, ok := l.machines[owner]
+		if !ok {
+			continue
+		}
+		syntheticNetworkAdapter, err := network_adapter.NewSyntheticNetworkAdapterFromInstance(instance)
+		if err != nil {
+			return err
+		}
+
+		var (
+			bandwidth *switch_extension.EthernetSwitchPortBandwidthSettingData
+			vlan      *switch_extension.EthernetSwitchPortVlanSettingData
+			vsw       *VirtualSwitch
+		)
+		portAllocation, ok := portAllocations[objectpath.Normalize(virtualNetworkAdapter.Path())]
+		if !ok {
+			return errors.Wrapf(wmiext.NotFound, "EthernetPortAllocationSettingData of network adapter [%s]", virtualNetworkAdapter.ElementName)
+		}
+		for _, settings := range bandwidths[owner] {
+			if isSubsetting(settings.InstanceID, portAllocation.InstanceID) {
+				bandwidth = settings
+				break
+			}
+		}
+		for _, settings := range vlans[owner] {
+			if isSubsetting(settings.InstanceID, portAllocation.InstanceID) {
+				vlan = settings
+				break
+			}
+		}
+		if len(portAllocation.HostResource) > 0 {
+			vsw = l.switchesByPath[objectpath.Normalize(portAllocation.HostResource[0])]
+		}
+		configuration := configurations[strings.ToLower(`Microsoft:GuestNetwork\`+
+			virtualNetworkAdapter.InstanceID[strings.IndexByte(virtualNetworkAdapter.InstanceID, ':')+1:])]
+
+		vna := &VirtualNetworkAdapter{client: l.client}
+		vna.populate(virtualNetworkAdapter, syntheticNetworkAdapter.SyntheticEthernetPortSettingData, bandwidth, vlan, configuration)
+		inv.networkAdapters[vm] = append(inv.networkAdapters[vm], vna)
+		if vsw != nil {
+			inv.switches[vna] = vsw
+		}
+	}
+	return nil
+}
+
+// loadVirtualSwitches loads the virtual switches along with their settings and the ports to the
+// host and the physical adapters.
+func (l *inventoryLoader) loadVirtualSwitches() ([]*VirtualSwitch, error) {
+	virtualEthernetSwitches, err := wmiext.Query[networking.VirtualEthernetSwitch](l.session,
+		wmiext.Select(networking.Msvm_VirtualEthernetSwitch))
+	if err != nil {
+		return nil, err
+	}
+	switchSettingData, err := wmiext.Query[networking.VirtualEthernetSwitchSettingData](l.session,
+		wmiext.Select(networking.Msvm_VirtualEthernetSwitchSettingData))
+	if err != nil {
+		return nil, err
+	}
+	portAllocationSettingData, err := wmiext.Query[networking.EthernetPortAllocationSettingData](l.session,
+		wmiext.Select(networking.Msvm_EthernetPortAllocationSettingData))
+	if err != nil {
+		return nil, err
+	}
+	externalEthernetPorts, err := wmiext.Query[networking.ExternalEthernetPort](l.session,
+		wmiext.Select(networking.Msvm_ExternalEthernetPort))
+	if err != nil {
+		return nil, err
+	}
+
+	settingsBySwitch := make(map[string]*networking.VirtualEthernetSwitchSettingData, len(switchSettingData))
+	for _, settings := range switchSettingData {
+		settingsBySwitch[strings.ToLower(settings.VirtualSystemIdentifier)] = settings
+	}
+	ports := make(map[string][]*networking.EthernetPortAllocationSettingData)
+	for _, settings := range portAllocationSettingData {
+		owner := settingOwner(settings.InstanceID)
+		ports[owner] = append(ports[owner], settings)
+	}
+	externalPorts := make(map[string]*networking.ExternalEthernetPort, len(externalEthernetPorts))
+	for _, port := range externalEthernetPorts {
+		externalPorts[objectpath.Normalize(port.S__PATH)] = port
+	}
+
+	l.switchesByPath = make(map[string]*VirtualSwitch, len(virtualEthernetSwitches))
+	vsws := make([]*VirtualSwitch, 0, len(virtualEthernetSwitches))
+	for _, virtualEthernetSwitch := range virtualEthernetSwitches {
+		settings, ok := settingsBySwitch[strings.ToLower(virtualEthernetSwitch.Name)]
+		if !ok {
+			return nil, errors.Wrapf(wmiext.NotFound, "VirtualEthernetSwitchSettingData of virtual switch [%s]", virtualEthernetSwitch.ElementName)
+		}
+
+		internal := false
+		var externalPort *networking.ExternalEthernetPort
+		for _, port := range ports[settingOwner(settings.InstanceID)] {
+			if !isSubsetting(port.InstanceID, settings.InstanceID) {
+				continue
+			}
+			className, err := port.HostResourceClass()
+			if err != nil {
+				continue
+			}
+			switch className {
+			case virtual_system.Msvm_ComputerSystem:
+				internal = true
+			case networking.Msvm_ExternalEthernetPort, networking.Msvm_WiFiPort:
+				if externalPort = externalPorts[objectpath.Normalize(port.HostResource[0])]; externalPort == nil {
+					// Wi-Fi ports are rare enough to be fetched one by one
+					externalPort = &networking.ExternalEthernetPort{}
+					if err = l.session.GetObjectAsObject(port.HostResource[0], externalPort); err != nil {
+						return nil, errors.Wrap(err, "failed to find external ethernet port")
+					}
+				}
+			}
+		}
+
+		vsw := &VirtualSwitch{client: l.client}
+		vsw.populate(virtualEthernetSwitch, settings, internal, externalPort)
+		l.switchesByPath[objectpath.Normalize(virtualEthernetSwitch.Path())] = vsw
+		vsws = append(vsws, vsw)
+	}
+	return vsws, nil
+}
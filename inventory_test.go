@@ -0,0 +1,229 @@
+package hyperv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend counts the queries sent to its Backend.
+type countingBackend struct {
+	wmiext.Backend
+	queries int
+}
+
+func (b *countingBackend) ExecQuery(wql string) (wmiext.ObjectIterator, error) {
+	b.queries++
+	return b.Backend.ExecQuery(wql)
+}
+
+// addInventoryMachine adds a virtual machine with a system disk on IDE, a data disk on SCSI and a
+// network adapter connected to switchPath.
+func addInventoryMachine(t *testing.T, repo *wmiext.MemoryRepository, name string, guid string, vhdPath string, switchPath string) {
+	add := func(class string, properties map[string]interface{}) string {
+		path, err := repo.AddInstance(class, properties)
+		require.NoError(t, err)
+		return path
+	}
+	id := "Microsoft:" + guid
+
+	add("Msvm_ComputerSystem", map[string]interface{}{
+		"CreationClassName": "Msvm_ComputerSystem", "Name": guid, "ElementName": name, "Caption": "Virtual Machine",
+	})
+	add("Msvm_VirtualSystemSettingData", map[string]interface{}{
+		"InstanceID": id, "VirtualSystemIdentifier": guid, "VirtualSystemType": "Microsoft:Hyper-V:System:Realized",
+		"Notes": []string{name + " notes"}, "ConfigurationDataRoot": `C:\vms\` + name,
+	})
+	// A snapshot of the machine must not be mistaken for it
+	add("Msvm_VirtualSystemSettingData", map[string]interface{}{
+		"InstanceID": "Microsoft:5E1F-" + guid, "VirtualSystemIdentifier": guid, "VirtualSystemType": "Microsoft:Hyper-V:Snapshot:Realized",
+	})
+	add("Msvm_ProcessorSettingData", map[string]interface{}{"InstanceID": id + `\b637f346-6a0e-4dec-af52-bd70cb80a21d\0`, "VirtualQuantity": uint64(4)})
+	add("Msvm_MemorySettingData", map[string]interface{}{"InstanceID": id + `\4764334d-e001-4176-82ee-5594ec9b530e`, "VirtualQuantity": uint64(2048)})
+
+	for i, controllerType := range []uint16{5, 6} {
+		suffix := string(rune('0' + i))
+		controller := add("Msvm_ResourceAllocationSettingData", map[string]interface{}{
+			"InstanceID": id + `\controller` + suffix, "ResourceType": controllerType,
+		})
+		drive := add("Msvm_ResourceAllocationSettingData", map[string]interface{}{
+			"InstanceID": id + `\drive` + suffix, "ResourceType": uint16(17), "Parent": controller,
+		})
+		path := vhdPath
+		if i == 1 {
+			path = filepath.Join(filepath.Dir(vhdPath), "missing.vhdx")
+		}
+		add("Msvm_StorageAllocationSettingData", map[string]interface{}{
+			"InstanceID": id + `\disk` + suffix, "ResourceType": uint16(31), "Parent": drive, "HostResource": []string{path},
+		})
+	}
+
+	adapter := add("Msvm_SyntheticEthernetPortSettingData", map[string]interface{}{
+		"InstanceID": id + `\adapter`, "ElementName": "Network Adapter", "StaticMacAddress": true, "Address": "00155D000001",
+	})
+	add("Msvm_EthernetPortAllocationSettingData", map[string]interface{}{
+		"InstanceID": id + `\port`, "Parent": adapter, "HostResource": []string{switchPath},
+	})
+	add("Msvm_EthernetSwitchPortVlanSettingData", map[string]interface{}{"InstanceID": id + `\port\vlan`, "AccessVlanId": uint16(42)})
+	add("Msvm_EthernetSwitchPortBandwidthSettingData", map[string]interface{}{
+		"InstanceID": id + `\port\bandwidth`, "Limit": uint64(100000000), "Reservation": uint64(10000000),
+	})
+	add("Msvm_GuestNetworkAdapterConfiguration", map[string]interface{}{
+		"InstanceID": `Microsoft:GuestNetwork\` + guid + `\adapter`, "IPAddresses": []string{"10.0.0.1"},
+	})
+}
+
+func TestClient_LoadInventory(t *testing.T) {
+	c, repo := newTestClient(t)
+	backend := &countingBackend{Backend: repo.Backend()}
+	session := wmiext.NewService(backend)
+	t.Cleanup(session.Close)
+	c = NewClientWithService(session)
+	t.Cleanup(func() { _ = c.Close() })
+
+	vhdPath := filepath.Join(t.TempDir(), "system.vhdx")
+	require.NoError(t, os.WriteFile(vhdPath, make([]byte, 1024), 0o644))
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_ImageManagementService",
+		Keys: []string{"CreationClassName", "Name"},
+		Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+			"GetVirtualHardDiskSettingData": {"Path": wmiext.CIM_STRING},
+		},
+	})
+	var inspected []string
+	repo.HandleMethod("Msvm_ImageManagementService", "GetVirtualHardDiskSettingData", func(call *wmiext.MethodCall) error {
+		inspected = append(inspected, call.InString("Path"))
+		text, err := wmiext.MarshalCimXml(storage.Msvm_VirtualHardDiskSettingData, &storage.VirtualHardDiskSettingData{
+			Path:            call.InString("Path"),
+			MaxInternalSize: 40 << 30,
+		})
+		if err != nil {
+			return err
+		}
+		call.Out("SettingData", text)
+		call.Return(0)
+		return nil
+	})
+
+	add := func(class string, properties map[string]interface{}) string {
+		path, err := repo.AddInstance(class, properties)
+		require.NoError(t, err)
+		return path
+	}
+	hostPath := add("Msvm_ComputerSystem", map[string]interface{}{
+		"CreationClassName": "Msvm_ComputerSystem", "Name": "HOST", "ElementName": "HOST", "Caption": "Hosting Computer System",
+	})
+	externalPortPath := add("Msvm_ExternalEthernetPort", map[string]interface{}{
+		"CreationClassName": "Msvm_ExternalEthernetPort", "DeviceID": "NIC1", "Name": "Intel Ethernet",
+	})
+	switchPaths := map[string]string{}
+	for _, vsw := range []struct {
+		name  string
+		ports []string
+		notes []string
+	}{
+		{name: "private"},
+		{name: "internal", ports: []string{hostPath}, notes: []string{"host only"}},
+		{name: "external", ports: []string{hostPath, externalPortPath}},
+	} {
+		switchPaths[vsw.name] = add("Msvm_VirtualEthernetSwitch", map[string]interface{}{
+			"CreationClassName": "Msvm_VirtualEthernetSwitch", "Name": "SW-" + vsw.name, "ElementName": vsw.name,
+		})
+		add("Msvm_VirtualEthernetSwitchSettingData", map[string]interface{}{
+			"InstanceID": "Microsoft:SW-" + vsw.name, "VirtualSystemIdentifier": "SW-" + vsw.name, "Notes": vsw.notes,
+		})
+		for i, port := range vsw.ports {
+			add("Msvm_EthernetPortAllocationSettingData", map[string]interface{}{
+				"InstanceID": "Microsoft:SW-" + vsw.name + `\port` + string(rune('0'+i)), "HostResource": []string{port},
+			})
+		}
+	}
+	addInventoryMachine(t, repo, "vm-1", "A0B1", vhdPath, switchPaths["external"])
+	addInventoryMachine(t, repo, "vm-2", "C2D3", vhdPath, switchPaths["internal"])
+	// The captions of a Chinese host
+	require.NoError(t, repo.Update(hostPath, map[string]interface{}{"Caption": "托管计算机系统"}))
+	require.NoError(t, repo.Update(`Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="C2D3"`,
+		map[string]interface{}{"Caption": "虚拟机"}))
+
+	// Services are looked up once per client
+	_, err := c.ImageManagementService()
+	require.NoError(t, err)
+	backend.queries = 0
+	inv, err := c.LoadInventory()
+	require.NoError(t, err)
+	queries := backend.queries
+
+	require.Len(t, inv.VirtualSwitches, 3)
+	types := map[string]VirtualSwitchType{}
+	for _, vsw := range inv.VirtualSwitches {
+		types[vsw.Name] = vsw.Type
+		if vsw.Name == "external" {
+			require.NotNil(t, vsw.PhysicalAdapter)
+			assert.Equal(t, "Intel Ethernet", *vsw.PhysicalAdapter)
+		} else {
+			assert.Nil(t, vsw.PhysicalAdapter)
+		}
+		if vsw.Name == "internal" {
+			assert.Equal(t, "host only", vsw.Description)
+		}
+	}
+	assert.Equal(t, map[string]VirtualSwitchType{
+		"private":  VirtualSwitchTypePrivate,
+		"internal": VirtualSwitchTypeInternal,
+		"external": VirtualSwitchTypeExternalBridge,
+	}, types)
+
+	require.Len(t, inv.VirtualMachines, 2)
+	vm := inv.FirstVirtualMachineByName("vm-1")
+	require.NotNil(t, vm)
+	assert.Equal(t, "vm-1 notes", vm.Description)
+	assert.Equal(t, `C:\vms\vm-1`, vm.SavePath)
+	assert.Equal(t, 4, vm.CpuCoreCount)
+	assert.Equal(t, 2048, vm.MemorySizeMB)
+	assert.Nil(t, inv.FirstVirtualMachineByName("HOST"))
+	// Inventory machines need no further queries
+	assert.True(t, vm.Loaded(FieldsAll))
+
+	disks := inv.VirtualHardDisks(vm)
+	require.Len(t, disks, 2)
+	assert.Equal(t, VirtualHardDiskTypeSystem, disks[0].Type)
+	assert.Equal(t, "system.vhdx", disks[0].Name)
+	assert.Equal(t, float64(40), disks[0].TotalSizeGB)
+	assert.True(t, disks[0].Attached)
+	assert.Equal(t, VirtualHardDiskTypeData, disks[1].Type)
+	assert.Zero(t, disks[1].TotalSizeGB)
+	// Only the existing disk of each machine is inspected
+	assert.Equal(t, []string{vhdPath, vhdPath}, inspected)
+
+	adapters := inv.VirtualNetworkAdapters(vm)
+	require.Len(t, adapters, 1)
+	cached, err := vm.VirtualNetworkAdapters()
+	require.NoError(t, err)
+	assert.Equal(t, adapters, cached)
+	adapter := adapters[0]
+	assert.Equal(t, "Network Adapter", adapter.Name)
+	assert.True(t, adapter.StaticMacAddress)
+	assert.Equal(t, "00155D000001", adapter.MacAddress)
+	assert.True(t, adapter.IsEnableVlan)
+	assert.Equal(t, 42, adapter.VlanId)
+	assert.True(t, adapter.IsEnableBandwidth)
+	assert.Equal(t, float64(100), adapter.MaxBandwidth)
+	assert.Equal(t, float64(10), adapter.MinBandwidth)
+	assert.Equal(t, []string{"10.0.0.1"}, adapter.IPAddress)
+	require.NotNil(t, inv.VirtualSwitch(adapter))
+	assert.Equal(t, "external", inv.VirtualSwitch(adapter).Name)
+	assert.Equal(t, "internal", inv.VirtualSwitch(inv.VirtualNetworkAdapters(inv.FirstVirtualMachineByName("vm-2"))[0]).Name)
+
+	// The number of queries does not depend on the number of machines
+	addInventoryMachine(t, repo, "vm-3", "E4F5", vhdPath, switchPaths["private"])
+	backend.queries = 0
+	inv, err = c.LoadInventory()
+	require.NoError(t, err)
+	assert.Len(t, inv.VirtualMachines, 3)
+	assert.Equal(t, queries, backend.queries)
+}
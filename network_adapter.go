@@ -2,13 +2,12 @@ package hyperv
 
 import (
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking"
-	utils "github.com/rokukoo/hyperv/pkg/hypervsdk/utils"
 )
 
 // ListAvailablePhysicalNetworkAdapters 列出所有可用的物理网络适配器
-func ListAvailablePhysicalNetworkAdapters() ([]string, error) {
+func (c *Client) ListAvailablePhysicalNetworkAdapters() ([]string, error) {
 	var nics []string
-	service, err := utils.NewLocalHyperVService()
+	service, err := c.service()
 	if err != nil {
 		return nil, err
 	}
@@ -30,3 +29,13 @@ func ListAvailablePhysicalNetworkAdapters() ([]string, error) {
 
 	return nics, nil
 }
+
+// ListAvailablePhysicalNetworkAdapters is Client.ListAvailablePhysicalNetworkAdapters on the default
+// client.
+func ListAvailablePhysicalNetworkAdapters() ([]string, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.ListAvailablePhysicalNetworkAdapters()
+}
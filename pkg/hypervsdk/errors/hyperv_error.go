@@ -0,0 +1,174 @@
+package errors
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+// ReturnCode is the ReturnValue of a Msvm method, or the ErrorCode of the job it started.
+type ReturnCode uint32
+
+const (
+	ReturnCompleted              ReturnCode = 0
+	ReturnNotSupported           ReturnCode = 1
+	ReturnFailed                 ReturnCode = 2
+	ReturnTimeout                ReturnCode = 3
+	ReturnInvalidParameter       ReturnCode = 4
+	ReturnInvalidState           ReturnCode = 5
+	ReturnIncompatibleParameter  ReturnCode = 6
+	ReturnJobStarted             ReturnCode = 4096
+	ReturnMethodFailed           ReturnCode = 32768
+	ReturnAccessDenied           ReturnCode = 32769
+	ReturnMethodNotSupported     ReturnCode = 32770
+	ReturnStatusUnknown          ReturnCode = 32771
+	ReturnMethodTimeout          ReturnCode = 32772
+	ReturnMethodInvalidParameter ReturnCode = 32773
+	ReturnSystemInUse            ReturnCode = 32774
+	ReturnMethodInvalidState     ReturnCode = 32775
+	ReturnIncorrectDataType      ReturnCode = 32776
+	ReturnSystemNotAvailable     ReturnCode = 32777
+	ReturnOutOfMemory            ReturnCode = 32778
+	ReturnFileNotFound           ReturnCode = 32779
+)
+
+var (
+	ErrFailed             = errors.New("failed")
+	ErrTimeout            = errors.New("timeout")
+	ErrInvalidState       = errors.New("invalid state for this operation")
+	ErrAccessDenied       = errors.New("access denied")
+	ErrNotSupported       = errors.New("not supported")
+	ErrSystemInUse        = errors.New("system is in use")
+	ErrSystemNotAvailable = errors.New("system is not available")
+	ErrOutOfResources     = errors.New("out of resources")
+	ErrFileNotFound       = errors.New("file not found")
+	ErrInvalidParameter   = errors.New("invalid parameter")
+	ErrStatusUnknown      = errors.New("status is unknown")
+)
+
+var returnCodes = map[ReturnCode]struct {
+	text     string
+	sentinel error
+}{
+	ReturnNotSupported:           {"Not Supported", ErrNotSupported},
+	ReturnFailed:                 {"Failed", ErrFailed},
+	ReturnTimeout:                {"Timeout", ErrTimeout},
+	ReturnInvalidParameter:       {"Invalid Parameter", ErrInvalidParameter},
+	ReturnInvalidState:           {"Invalid State", ErrInvalidState},
+	ReturnIncompatibleParameter:  {"Incompatible Parameters", ErrInvalidParameter},
+	ReturnMethodFailed:           {"Failed", ErrFailed},
+	ReturnAccessDenied:           {"Access Denied", ErrAccessDenied},
+	ReturnMethodNotSupported:     {"Not Supported", ErrNotSupported},
+	ReturnStatusUnknown:          {"Status is unknown", ErrStatusUnknown},
+	ReturnMethodTimeout:          {"Timeout", ErrTimeout},
+	ReturnMethodInvalidParameter: {"Invalid parameter", ErrInvalidParameter},
+	ReturnSystemInUse:            {"System is in use", ErrSystemInUse},
+	ReturnMethodInvalidState:     {"Invalid state for this operation", ErrInvalidState},
+	ReturnIncorrectDataType:      {"Incorrect data type", ErrInvalidParameter},
+	ReturnSystemNotAvailable:     {"System is not available", ErrSystemNotAvailable},
+	ReturnOutOfMemory:            {"Out of memory", ErrOutOfResources},
+	ReturnFileNotFound:           {"File not found", ErrFileNotFound},
+}
+
+func (c ReturnCode) String() string {
+	switch c {
+	case ReturnCompleted:
+		return "Completed with No Error"
+	case ReturnJobStarted:
+		return "Method Parameters Checked - Job Started"
+	}
+	if code, ok := returnCodes[c]; ok {
+		return code.text
+	}
+	return fmt.Sprintf("Unknown return code %d", uint32(c))
+}
+
+// Sentinel returns the sentinel error matching the code, ErrFailed for codes without a specific one.
+func (c ReturnCode) Sentinel() error {
+	if code, ok := returnCodes[c]; ok {
+		return code.sentinel
+	}
+	return ErrFailed
+}
+
+// HyperVError is a failed Msvm method, either from its return value or from the job it started. It
+// matches the sentinel error of its code with errors.Is.
+type HyperVError struct {
+	Method string
+	// Path is the object the method was invoked on.
+	Path string
+	Code ReturnCode
+	// JobPath is set when the method started a job that failed.
+	JobPath            string
+	Description        string
+	SummaryDescription string
+	// Cause is the underlying error, a *wmiext.JobError when the job failed.
+	Cause error
+}
+
+func (e *HyperVError) Error() string {
+	msg := fmt.Sprintf("%s failed with %d (%s)", e.Method, uint32(e.Code), e.Code)
+	switch {
+	case e.Description != "":
+		msg += ": " + e.Description
+	case e.SummaryDescription != "":
+		msg += ": " + e.SummaryDescription
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error of the code along with the cause.
+func (e *HyperVError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Code.Sentinel()}
+	}
+	return []error{e.Code.Sentinel(), e.Cause}
+}
+
+// NewReturnValueError returns the error of a method that failed synchronously, nil when the return
+// value reports a success or a started job.
+func NewReturnValueError(method string, path string, returnValue int32) error {
+	code := ReturnCode(returnValue)
+	if code == ReturnCompleted || code == ReturnJobStarted {
+		return nil
+	}
+	return &HyperVError{Method: method, Path: path, Code: code}
+}
+
+// NewJobError converts the error of a job started by a method. Errors other than a *wmiext.JobError
+// are returned unchanged.
+func NewJobError(method string, path string, err error) error {
+	var jobErr *wmiext.JobError
+	if !errors.As(err, &jobErr) {
+		return err
+	}
+	return &HyperVError{
+		Method:             method,
+		Path:               path,
+		Code:               ReturnCode(jobErr.ErrorCode),
+		JobPath:            jobErr.Path,
+		Description:        jobErr.Description,
+		SummaryDescription: jobErr.SummaryDescription,
+		Cause:              err,
+	}
+}
+
+// CodeOf returns the return code of the *HyperVError or *wmiext.JobError in the chain of err.
+func CodeOf(err error) (ReturnCode, bool) {
+	var hvErr *HyperVError
+	if errors.As(err, &hvErr) {
+		return hvErr.Code, true
+	}
+	var jobErr *wmiext.JobError
+	if errors.As(err, &jobErr) {
+		return ReturnCode(jobErr.ErrorCode), true
+	}
+	return 0, false
+}
+
+// HasCode reports whether err carries exactly the return code.
+func HasCode(err error, code ReturnCode) bool {
+	c, ok := CodeOf(err)
+	return ok && c == code
+}
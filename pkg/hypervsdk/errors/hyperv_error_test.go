@@ -0,0 +1,85 @@
+package errors
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReturnCode_Sentinel(t *testing.T) {
+	tests := []struct {
+		code     ReturnCode
+		sentinel error
+	}{
+		{ReturnMethodFailed, ErrFailed},
+		{ReturnAccessDenied, ErrAccessDenied},
+		{ReturnMethodNotSupported, ErrNotSupported},
+		{ReturnSystemInUse, ErrSystemInUse},
+		{ReturnMethodInvalidState, ErrInvalidState},
+		{ReturnInvalidState, ErrInvalidState},
+		{ReturnIncorrectDataType, ErrInvalidParameter},
+		{ReturnOutOfMemory, ErrOutOfResources},
+		{ReturnFileNotFound, ErrFileNotFound},
+		{ReturnCode(40000), ErrFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			assert.Equal(t, tt.sentinel, tt.code.Sentinel())
+		})
+	}
+}
+
+func TestNewReturnValueError(t *testing.T) {
+	assert.NoError(t, NewReturnValueError("DefineSystem", "", 0))
+	assert.NoError(t, NewReturnValueError("DefineSystem", "", 4096))
+
+	err := NewReturnValueError("DestroySystem", `Msvm_VirtualSystemManagementService.Name="vmms"`, 32775)
+	assert.ErrorIs(t, err, ErrInvalidState)
+	assert.NotErrorIs(t, err, ErrAccessDenied)
+	assert.Equal(t, "DestroySystem failed with 32775 (Invalid state for this operation)", err.Error())
+
+	var hvErr *HyperVError
+	require.True(t, errors.As(errors.Wrap(err, "failed to destroy"), &hvErr))
+	assert.Equal(t, ReturnMethodInvalidState, hvErr.Code)
+	assert.Equal(t, `Msvm_VirtualSystemManagementService.Name="vmms"`, hvErr.Path)
+}
+
+func TestNewJobError(t *testing.T) {
+	jobErr := &wmiext.JobError{
+		ErrorCode:          32769,
+		Description:        "The virtual machine already exists.",
+		SummaryDescription: "Failed to define the virtual machine.",
+		Path:               `Msvm_ConcreteJob.InstanceID="job-1"`,
+	}
+	err := NewJobError("DefineSystem", "vmms", errors.Wrap(jobErr, "Failed to define system"))
+	assert.ErrorIs(t, err, ErrAccessDenied)
+	assert.Equal(t, "DefineSystem failed with 32769 (Access Denied): The virtual machine already exists.", err.Error())
+
+	var hvErr *HyperVError
+	require.True(t, errors.As(err, &hvErr))
+	assert.Equal(t, "DefineSystem", hvErr.Method)
+	assert.Equal(t, jobErr.Path, hvErr.JobPath)
+	assert.Equal(t, "Failed to define the virtual machine.", hvErr.SummaryDescription)
+
+	var cause *wmiext.JobError
+	require.True(t, errors.As(err, &cause))
+	assert.Same(t, jobErr, cause)
+
+	other := errors.New("connection lost")
+	assert.Equal(t, other, NewJobError("DefineSystem", "vmms", other))
+}
+
+func TestHasCode(t *testing.T) {
+	err := errors.Wrap(NewReturnValueError("RequestStateChange", "vm", 32768), "failed to stop")
+	assert.True(t, HasCode(err, ReturnMethodFailed))
+	assert.False(t, HasCode(NewReturnValueError("RequestStateChange", "vm", 2), ReturnMethodFailed))
+	assert.False(t, HasCode(NewReturnValueError("RequestStateChange", "vm", 40000), ReturnMethodFailed))
+	assert.False(t, HasCode(errors.New("connection lost"), ReturnMethodFailed))
+
+	code, ok := CodeOf(errors.Wrap(&wmiext.JobError{ErrorCode: 32768}, "job failed"))
+	assert.True(t, ok)
+	assert.Equal(t, ReturnMethodFailed, code)
+}
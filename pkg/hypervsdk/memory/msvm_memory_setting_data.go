@@ -59,6 +59,12 @@ func GetDefaultMemorySettingsData() (*MemorySettingsData, error) {
 	return settings, hypervsdk.PopulateDefaults(MemoryResourceType, settings)
 }
 
+// GetDefaultMemorySettingsDataWith is GetDefaultMemorySettingsData over an existing session.
+func GetDefaultMemorySettingsDataWith(service *wmiext.Service) (*MemorySettingsData, error) {
+	settings := &MemorySettingsData{}
+	return settings, hypervsdk.PopulateDefaultsWith(service, MemoryResourceType, settings)
+}
+
 func CreateMemorySettings(settings *MemorySettingsData) (string, error) {
 	str, err := hypervsdk.CreateResourceSettingGeneric(settings, MemoryResourceType)
 	if err != nil {
@@ -66,3 +72,12 @@ func CreateMemorySettings(settings *MemorySettingsData) (string, error) {
 	}
 	return str, err
 }
+
+// CreateMemorySettingsWith is CreateMemorySettings over an existing session.
+func CreateMemorySettingsWith(service *wmiext.Service, settings *MemorySettingsData) (string, error) {
+	str, err := hypervsdk.CreateResourceSettingGenericWith(service, settings, MemoryResourceType)
+	if err != nil {
+		err = fmt.Errorf("could not create memory settings: %w", err)
+	}
+	return str, err
+}
@@ -0,0 +1,6 @@
+// Package msvm holds Go bindings of the Msvm_* classes of the Hyper-V WMI provider, generated from
+// their MOF declarations in the mof directory. Regenerate them with go generate after changing the
+// MOF files.
+package msvm
+
+//go:generate go run github.com/rokukoo/hyperv/cmd/mofgen -package msvm -out zz_generated.go mof
@@ -0,0 +1,103 @@
+package msvm
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/rokukoo/hyperv/pkg/wmiext/mof"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerated(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("mof", "*.mof"))
+	require.NoError(t, err)
+	sort.Strings(files)
+	schema, err := mof.ParseFiles(files...)
+	require.NoError(t, err)
+	src, err := mof.Generate(schema, mof.Config{Package: "msvm", Sources: []string{"mof"}})
+	require.NoError(t, err)
+
+	generated, err := os.ReadFile("zz_generated.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(src), string(generated), "zz_generated.go is out of date, run go generate")
+}
+
+func TestComputerSystem(t *testing.T) {
+	repo := wmiext.NewMemoryRepository(`root\virtualization\v2`)
+	repo.DefineClass(ComputerSystemMemoryClass())
+	repo.DefineClass(ConcreteJobMemoryClass())
+	path, err := repo.AddInstance(Msvm_ComputerSystem, map[string]interface{}{
+		"CreationClassName": Msvm_ComputerSystem,
+		"Name":              "A0B1",
+		"ElementName":       "vm-1",
+		"EnabledState":      uint16(ComputerSystemEnabledStateDisabled),
+		"OperationalStatus": []uint16{2, 32768},
+	})
+	require.NoError(t, err)
+
+	var requested []interface{}
+	repo.HandleMethod(Msvm_ComputerSystem, "RequestStateChange", func(call *wmiext.MethodCall) error {
+		requested = append(requested, call.In("RequestedState"))
+		job, err := call.Repository.AddInstance(Msvm_ConcreteJob, map[string]interface{}{
+			"InstanceID": "job-1", "JobState": uint16(ConcreteJobJobStateRunning),
+		})
+		if err != nil {
+			return err
+		}
+		call.Out("Job", wmiext.Reference(job))
+		call.Return(uint32(ComputerSystemRequestStateChangeReturnValueMethodParametersCheckedTransitionStarted))
+		return nil
+	})
+
+	session := repo.Service()
+	defer session.Close()
+	var systems []*ComputerSystem
+	require.NoError(t, session.FindObjects(wmiext.Select(Msvm_ComputerSystem).String(), &systems))
+	require.Len(t, systems, 1)
+	vm := systems[0]
+	assert.Equal(t, "vm-1", vm.ElementName)
+	assert.Equal(t, ComputerSystemEnabledStateDisabled, vm.EnabledState)
+	assert.Equal(t, "Disabled", vm.EnabledState.String())
+	assert.Equal(t, []ComputerSystemOperationalStatus{ComputerSystemOperationalStatusOK, ComputerSystemOperationalStatusCreatingSnapshot}, vm.OperationalStatus)
+	assert.Equal(t, "ComputerSystemHealthState(0)", vm.HealthState.String())
+	assert.True(t, objectpath.Equal(path, vm.Path()))
+
+	job, returnValue, err := vm.RequestStateChange(ComputerSystemRequestStateChangeRequestedStateRunning, "")
+	require.NoError(t, err)
+	assert.Equal(t, ComputerSystemRequestStateChangeReturnValueMethodParametersCheckedTransitionStarted, returnValue)
+	assert.Equal(t, "Method Parameters Checked - Transition Started", returnValue.String())
+	assert.EqualValues(t, []interface{}{int32(2)}, requested)
+
+	var jobs []*ConcreteJob
+	require.NoError(t, session.FindObjects(wmiext.Select(Msvm_ConcreteJob).String(), &jobs))
+	require.Len(t, jobs, 1)
+	assert.Contains(t, job, `Msvm_ConcreteJob.InstanceID="job-1"`)
+	assert.Equal(t, ConcreteJobJobStateRunning, jobs[0].JobState)
+}
+
+func TestEthernetSwitchPortVlanSettingData(t *testing.T) {
+	repo := wmiext.NewMemoryRepository(`root\virtualization\v2`)
+	repo.DefineClass(EthernetSwitchPortVlanSettingDataMemoryClass())
+	_, err := repo.AddInstance(Msvm_EthernetSwitchPortVlanSettingData, map[string]interface{}{
+		"InstanceID":       "Microsoft:A0B1\\V",
+		"OperationMode":    uint32(EthernetSwitchPortVlanSettingDataOperationModeTrunk),
+		"NativeVlanId":     uint16(1),
+		"TrunkVlanIdArray": []uint16{10, 20},
+	})
+	require.NoError(t, err)
+
+	session := repo.Service()
+	defer session.Close()
+	var settings []*EthernetSwitchPortVlanSettingData
+	require.NoError(t, session.FindObjects(wmiext.Select(Msvm_EthernetSwitchPortVlanSettingData).String(), &settings))
+	require.Len(t, settings, 1)
+	assert.Equal(t, EthernetSwitchPortVlanSettingDataOperationModeTrunk, settings[0].OperationMode)
+	assert.Equal(t, "Trunk", settings[0].OperationMode.String())
+	assert.Equal(t, []uint16{10, 20}, settings[0].TrunkVlanIdArray)
+	assert.Equal(t, EthernetSwitchPortVlanSettingDataPvlanModeUnknown, settings[0].PvlanMode)
+}
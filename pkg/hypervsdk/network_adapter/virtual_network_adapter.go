@@ -1,7 +1,6 @@
 package network_adapter
 
 import (
-	"fmt"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
 )
@@ -41,7 +40,7 @@ func (vna *VirtualNetworkAdapter) SetElementName(elementName string) error {
 }
 
 func FirstVirtualNetworkAdapterByName(session *wmiext.Service, name string) (*VirtualNetworkAdapter, error) {
-	wql := fmt.Sprintf("SELECT * FROM Msvm_SyntheticEthernetPortSettingData WHERE ElementName = '%s'", name)
+	wql := wmiext.Select(networking.Msvm_SyntheticEthernetPortSettingData).Where(wmiext.Equal("ElementName", name)).String()
 	ins, err := session.FindFirstInstance(wql)
 	if err != nil {
 		return nil, err
@@ -3,6 +3,7 @@ package networking
 import (
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking/switch_extension"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
 )
 
 const (
@@ -70,6 +71,29 @@ func (epasd *EthernetPortAllocationSettingData) SetHostResource(hostResource []s
 	return epasd.Put("HostResource", hostResource)
 }
 
+// HostResourceClass returns the class of the object the port is connected to, read from the
+// HostResource reference.
+func (epasd *EthernetPortAllocationSettingData) HostResourceClass() (string, error) {
+	if len(epasd.HostResource) == 0 {
+		return "", wmiext.NotFound
+	}
+	path, err := objectpath.Parse(epasd.HostResource[0])
+	if err != nil {
+		return "", err
+	}
+	return path.ClassName, nil
+}
+
+// IsConnectedTo returns whether HostResource refers to the object at path.
+func (epasd *EthernetPortAllocationSettingData) IsConnectedTo(path string) bool {
+	for _, hostResource := range epasd.HostResource {
+		if objectpath.Equal(hostResource, path) {
+			return true
+		}
+	}
+	return false
+}
+
 func (epasd *EthernetPortAllocationSettingData) GetEthernetSwitchPortBandwidthSettingData() (*switch_extension.EthernetSwitchPortBandwidthSettingData, error) {
 	inst, err := epasd.GetRelated(switch_extension.Msvm_EthernetSwitchPortBandwidthSettingData)
 	if err != nil {
@@ -1,8 +1,6 @@
 package networking
 
 import (
-	"fmt"
-
 	"github.com/rokukoo/hyperv/pkg/wmiext"
 )
 
@@ -84,12 +82,12 @@ func (eep *ExternalEthernetPort) Path() string {
 
 func GetExternalEthernetPort(con *wmiext.Service, ethernetName string) (*ExternalEthernetPort, error) {
 	extPort := &ExternalEthernetPort{}
-	wquery := fmt.Sprintf("SELECT * FROM Msvm_ExternalEthernetPort WHERE ElementName = '%s'", ethernetName)
+	wquery := wmiext.Select(Msvm_ExternalEthernetPort).Where(wmiext.Equal("ElementName", ethernetName)).String()
 	return extPort, con.FindFirstObject(wquery, extPort)
 }
 
 func ListEnabledExternalEthernetPort(session *wmiext.Service) ([]*ExternalEthernetPort, error) {
 	var extPorts []*ExternalEthernetPort
-	wquery := fmt.Sprintf("SELECT * FROM Msvm_ExternalEthernetPort WHERE EnabledState = 2")
+	wquery := wmiext.Select(Msvm_ExternalEthernetPort).Where(wmiext.Equal("EnabledState", 2)).String()
 	return extPorts, session.FindObjects(wquery, &extPorts)
 }
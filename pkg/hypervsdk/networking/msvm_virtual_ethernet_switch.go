@@ -1,7 +1,6 @@
 package networking
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/rokukoo/hyperv/pkg/wmiext"
@@ -59,12 +58,7 @@ func (vswitch *VirtualEthernetSwitch) GetInternalPortAllocSettings() (*EthernetP
 		return nil, err
 	}
 	for _, setting := range ethernetPortAllocSettings {
-		hostResPath := setting.HostResource[0]
-		object, err := vswitch.GetService().GetObject(hostResPath)
-		if err != nil {
-			return nil, err
-		}
-		className, err := object.GetClassName()
+		className, err := setting.HostResourceClass()
 		if err != nil {
 			return nil, err
 		}
@@ -82,12 +76,7 @@ func (vswitch *VirtualEthernetSwitch) GetExternalPortAllocSettings() (*EthernetP
 	}
 
 	for _, setting := range ethernetPortAllocSettings {
-		hostResPath := setting.HostResource[0]
-		object, err := vswitch.GetService().GetObject(hostResPath)
-		if err != nil {
-			return nil, err
-		}
-		className, err := object.GetClassName()
+		className, err := setting.HostResourceClass()
 		if err != nil {
 			return nil, err
 		}
@@ -116,6 +105,6 @@ func (vswitch *VirtualEthernetSwitch) ActiveVirtualEthernetSwitchSettingData() (
 
 func FirstVirtualEthernetSwitchByName(session *wmiext.Service, name string) (*VirtualEthernetSwitch, error) {
 	vswitch := &VirtualEthernetSwitch{}
-	wql := fmt.Sprintf("SELECT * FROM Msvm_VirtualEthernetSwitch WHERE ElementName = '%s'", name)
+	wql := wmiext.Select(Msvm_VirtualEthernetSwitch).Where(wmiext.Equal("ElementName", name)).String()
 	return vswitch, session.FindFirstObject(wql, vswitch)
 }
@@ -1,8 +1,6 @@
 package networking
 
 import (
-	"fmt"
-
 	"github.com/rokukoo/hyperv/pkg/wmiext"
 )
 
@@ -80,12 +78,12 @@ func (wp *WiFiPort) Path() string {
 
 func GetWiFiPort(con *wmiext.Service, ethernetName string) (*WiFiPort, error) {
 	wifiPort := &WiFiPort{}
-	wquery := fmt.Sprintf("SELECT * FROM Msvm_WiFiPort WHERE ElementName = '%s'", ethernetName)
+	wquery := wmiext.Select(Msvm_WiFiPort).Where(wmiext.Equal("ElementName", ethernetName)).String()
 	return wifiPort, con.FindFirstObject(wquery, wifiPort)
 }
 
 func ListEnabledWiFiPort(session *wmiext.Service) ([]*WiFiPort, error) {
 	var wiFiPorts []*WiFiPort
-	wquery := fmt.Sprintf("SELECT * FROM Msvm_WiFiPort WHERE EnabledState = 2")
+	wquery := wmiext.Select(Msvm_WiFiPort).Where(wmiext.Equal("EnabledState", 2)).String()
 	return wiFiPorts, session.FindObjects(wquery, &wiFiPorts)
 }
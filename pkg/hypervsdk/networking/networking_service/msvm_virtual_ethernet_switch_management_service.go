@@ -1,13 +1,13 @@
 package networking_service
 
 import (
+	"context"
 	"fmt"
-	"strings"
-	"time"
 
 	"github.com/duke-git/lancet/v2/slice"
 	"github.com/pkg/errors"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/retry"
 	utils "github.com/rokukoo/hyperv/pkg/hypervsdk/utils"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system/host"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
@@ -20,13 +20,13 @@ const (
 type VirtualEthernetSwitchManagementService struct {
 	Con *wmiext.Service
 	*wmiext.Instance
+	// Retry is the retry policy of each method, retry.DefaultPolicy for those not configured.
+	Retry *retry.Policies
 }
 
 func (vsms *VirtualEthernetSwitchManagementService) AddResourceSettings(settingsData *networking.VirtualEthernetSwitchSettingData, resourceSettings []*networking.EthernetPortAllocationSettingData) ([]*networking.EthernetPortAllocationSettingData, error) {
 	var (
 		err                       error
-		job                       *wmiext.Instance
-		returnValue               int32
 		settingsDataRef           string
 		resultingResourceSettings []string
 	)
@@ -36,17 +36,23 @@ func (vsms *VirtualEthernetSwitchManagementService) AddResourceSettings(settings
 		return rs.GetCimText()
 	})
 
-	if err = vsms.Method("AddResourceSettings").
-		In("AffectedConfiguration", settingsDataRef).
-		In("ResourceSettings", resourceSettingRefs).
-		Execute().
-		Out("Job", &job).
-		Out("ResultingResourceSettings", &resultingResourceSettings).
-		Out("ReturnValue", &returnValue).
-		End(); err != nil {
-		return nil, fmt.Errorf("failed to remove allocation settings: %w", err)
-	}
-	if err = utils.WaitResult(returnValue, vsms.Con, job, "failed to add allocation settings", nil); err != nil {
+	if err = vsms.Retry.Do(context.Background(), "AddResourceSettings", func() error {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+		if err := vsms.Method("AddResourceSettings").
+			In("AffectedConfiguration", settingsDataRef).
+			In("ResourceSettings", resourceSettingRefs).
+			Execute().
+			Out("Job", &job).
+			Out("ResultingResourceSettings", &resultingResourceSettings).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return fmt.Errorf("failed to remove allocation settings: %w", err)
+		}
+		return utils.WaitResult(vsms.Instance, "AddResourceSettings", returnValue, job)
+	}); err != nil {
 		return nil, err
 	}
 	// Get the allocation settings
@@ -62,19 +68,17 @@ func (vsms *VirtualEthernetSwitchManagementService) AddResourceSettings(settings
 }
 
 func (vsms *VirtualEthernetSwitchManagementService) RemoveResourceSettings(resourceSettings []*networking.EthernetPortAllocationSettingData) error {
-	var (
-		err         error
-		job         *wmiext.Instance
-		returnValue int32
-	)
-
 	// Get the system settings
 	resourceSettingPaths := slice.Map(resourceSettings, func(_ int, rs *networking.EthernetPortAllocationSettingData) string {
 		return rs.Path()
 	})
 
-	for {
-		if err = vsms.Method("RemoveResourceSettings").
+	return vsms.Retry.Do(context.Background(), "RemoveResourceSettings", func() error {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+		if err := vsms.Method("RemoveResourceSettings").
 			In("ResourceSettings", resourceSettingPaths).
 			Execute().
 			Out("Job", &job).
@@ -82,20 +86,8 @@ func (vsms *VirtualEthernetSwitchManagementService) RemoveResourceSettings(resou
 			End(); err != nil {
 			return fmt.Errorf("failed to remove allocation settings: %w", err)
 		}
-		switch returnValue {
-		case 32775:
-			// Method failed with 32775, retrying
-			time.Sleep(100 * time.Millisecond)
-			continue
-		case 0:
-			return nil
-		case 4096:
-			if err = utils.WaitResult(returnValue, vsms.Con, job, "failed to remove allocation settings", nil); err != nil {
-				return err
-			}
-			return nil
-		}
-	}
+		return utils.WaitResult(vsms.Instance, "RemoveResourceSettings", returnValue, job)
+	})
 }
 
 func (vsms *VirtualEthernetSwitchManagementService) DefineSystem(
@@ -108,10 +100,10 @@ func (vsms *VirtualEthernetSwitchManagementService) DefineSystem(
 
 		systemSettings string
 
-		job             *wmiext.Instance
 		res             int32
 		resultingSystem string
 
+		jobPath         string
 		path            string
 		affectedElement *wmiext.Instance
 	)
@@ -120,22 +112,31 @@ func (vsms *VirtualEthernetSwitchManagementService) DefineSystem(
 	resourceSettingsText := slice.Map(resourceSettings, func(_ int, rs *networking.EthernetPortAllocationSettingData) string {
 		return rs.GetCimText()
 	})
-	// Invoke the DefineSystem method
-	err = vsms.Method("DefineSystem").
-		In("SystemSettings", systemSettings).
-		In("ResourceSettings", resourceSettingsText).
-		In("ReferenceConfiguration", nil).
-		Execute().
-		Out("Job", &job).
-		Out("ResultingSystem", &resultingSystem).
-		Out("ReturnValue", &res).
-		End()
-	// Check for errors
-	if err != nil {
-		return nil, fmt.Errorf("failed to define system: %w", err)
-	}
-	// Wait for the job to complete
-	if err = utils.WaitResult(res, vsms.Con, job, "failed to define system", nil); err != nil {
+	if err = vsms.Retry.Do(context.Background(), "DefineSystem", func() error {
+		var job *wmiext.Instance
+		// Invoke the DefineSystem method
+		err := vsms.Method("DefineSystem").
+			In("SystemSettings", systemSettings).
+			In("ResourceSettings", resourceSettingsText).
+			In("ReferenceConfiguration", nil).
+			Execute().
+			Out("Job", &job).
+			Out("ResultingSystem", &resultingSystem).
+			Out("ReturnValue", &res).
+			End()
+		// Check for errors
+		if err != nil {
+			return fmt.Errorf("failed to define system: %w", err)
+		}
+		// Wait for the job to complete, it is released once done
+		started, err := utils.StartJob(vsms.Instance, "DefineSystem", res, job)
+		if err != nil {
+			return err
+		}
+		jobPath = started.Path()
+		_, err = started.Wait(context.Background())
+		return err
+	}); err != nil {
 		return nil, err
 	}
 	// Get the resulting system
@@ -151,12 +152,8 @@ func (vsms *VirtualEthernetSwitchManagementService) DefineSystem(
 		return vswitch, nil
 	// Job in progress
 	case 4096:
-		// Get the job path
-		if path, err = job.Path(); err != nil {
-			return nil, err
-		}
 		// Get the affected element
-		if affectedElement, err = vsms.Con.FindFirstRelatedInstanceThrough(path, "Msvm_VirtualEthernetSwitch", "Msvm_AffectedJobElement"); err != nil {
+		if affectedElement, err = vsms.Con.FindFirstRelatedInstanceThrough(jobPath, "Msvm_VirtualEthernetSwitch", "Msvm_AffectedJobElement"); err != nil {
 			return nil, err
 		}
 		// Get the path of the affected element
@@ -174,12 +171,12 @@ func (vsms *VirtualEthernetSwitchManagementService) DefineSystem(
 }
 
 func (vsms *VirtualEthernetSwitchManagementService) DestroySystem(vswitch *networking.VirtualEthernetSwitch) (err error) {
-	var (
-		job *wmiext.Instance
-		res int32
-	)
-	for {
-		err = vsms.Method("DestroySystem").
+	return vsms.Retry.Do(context.Background(), "DestroySystem", func() error {
+		var (
+			job *wmiext.Instance
+			res int32
+		)
+		err := vsms.Method("DestroySystem").
 			In("AffectedSystem", vswitch.Path()).
 			Execute().
 			Out("Job", &job).
@@ -188,22 +185,8 @@ func (vsms *VirtualEthernetSwitchManagementService) DestroySystem(vswitch *netwo
 		if err != nil {
 			return fmt.Errorf("failed to destroy system: %w", err)
 		}
-		switch res {
-		case 32775:
-			// Method failed with 32775, retrying
-			time.Sleep(100 * time.Millisecond)
-			continue
-		case 0:
-			return nil
-		case 4096:
-			if err = wmiext.WaitJob(vsms.Con, job); err != nil {
-				desc, _ := job.GetAsString("ErrorDescription")
-				desc = strings.Replace(desc, "\n", " ", -1)
-				return fmt.Errorf("failed to destroy system:%w (%s)", err, desc)
-			}
-			return nil
-		}
-	}
+		return utils.WaitResult(vsms.Instance, "DestroySystem", res, job)
+	})
 }
 
 func (vsms *VirtualEthernetSwitchManagementService) ModifyResourceSettings(elementName string) error {
@@ -213,7 +196,7 @@ func (vsms *VirtualEthernetSwitchManagementService) ModifyResourceSettings(eleme
 func (vsms *VirtualEthernetSwitchManagementService) FirstVirtualSwitchByName(name string) (*networking.VirtualEthernetSwitch, error) {
 	var err error
 	vswitch := &networking.VirtualEthernetSwitch{}
-	wQuery := fmt.Sprintf("SELECT * FROM Msvm_VirtualEthernetSwitch WHERE ElementName = '%s'", name)
+	wQuery := wmiext.Select(networking.Msvm_VirtualEthernetSwitch).Where(wmiext.Equal("ElementName", name)).String()
 
 	if err = vsms.Con.FindFirstObject(wQuery, vswitch); err != nil {
 		return nil, errors.Wrap(err, "failed to find virtual switch")
@@ -223,21 +206,35 @@ func (vsms *VirtualEthernetSwitchManagementService) FirstVirtualSwitchByName(nam
 
 func LocalVirtualEthernetSwitchManagementService() (*VirtualEthernetSwitchManagementService, error) {
 	var (
-		con *wmiext.Service
-		svc *wmiext.Instance
-		err error
+		con  *wmiext.Service
+		vsms *VirtualEthernetSwitchManagementService
+		err  error
 	)
 	// Get the WMI service
 	if con, err = utils.NewLocalHyperVService(); err != nil {
 		return nil, err
 	}
+	if vsms, err = NewVirtualEthernetSwitchManagementService(con); err != nil {
+		con.Close()
+		return nil, err
+	}
+	return vsms, nil
+}
+
+// NewVirtualEthernetSwitchManagementService returns the switch management service of the session,
+// which remains owned by the caller.
+func NewVirtualEthernetSwitchManagementService(con *wmiext.Service) (*VirtualEthernetSwitchManagementService, error) {
 	// Get the singleton instance
-	if svc, err = con.GetSingletonInstance(Msvm_VirtualEthernetSwitchManagementService); err != nil {
+	svc, err := con.GetSingletonInstance(Msvm_VirtualEthernetSwitchManagementService)
+	if err != nil {
 		return nil, err
 	}
-	return &VirtualEthernetSwitchManagementService{con, svc}, nil
+	return &VirtualEthernetSwitchManagementService{Con: con, Instance: svc, Retry: retry.NewPolicies(retry.DefaultPolicy())}, nil
 }
 
+// MustLocalVirtualEthernetSwitchManagementService is LocalVirtualEthernetSwitchManagementService panicking on error.
+//
+// Deprecated: use LocalVirtualEthernetSwitchManagementService, which returns the error.
 func MustLocalVirtualEthernetSwitchManagementService() *VirtualEthernetSwitchManagementService {
 	vsms, err := LocalVirtualEthernetSwitchManagementService()
 	if err != nil {
@@ -326,7 +323,7 @@ func (vsms *VirtualEthernetSwitchManagementService) GetDefaultEthernetPortAlloca
 func (vsms *VirtualEthernetSwitchManagementService) DefaultInternalPortAllocationSettingData(switchPortName string) (*networking.EthernetPortAllocationSettingData, error) {
 	epasd, err := vsms.GetDefaultEthernetPortAllocationSettingData()
 
-	hostCm, err := host.GetHostComputerSystem()
+	hostCm, err := host.GetHostComputerSystemWith(vsms.Con)
 	if err != nil {
 		return nil, err
 	}
@@ -61,6 +61,12 @@ func GetDefaultProcessorSettingData() (*ProcessorSettingData, error) {
 	return settings, hypervsdk.PopulateDefaults(ProcessorResourceType, settings)
 }
 
+// GetDefaultProcessorSettingDataWith is GetDefaultProcessorSettingData over an existing session.
+func GetDefaultProcessorSettingDataWith(service *wmiext.Service) (*ProcessorSettingData, error) {
+	settings := &ProcessorSettingData{}
+	return settings, hypervsdk.PopulateDefaultsWith(service, ProcessorResourceType, settings)
+}
+
 func CreateProcessorSettings(settings *ProcessorSettingData) (string, error) {
 	str, err := hypervsdk.CreateResourceSettingGeneric(settings, ProcessorResourceType)
 	if err != nil {
@@ -68,3 +74,12 @@ func CreateProcessorSettings(settings *ProcessorSettingData) (string, error) {
 	}
 	return str, err
 }
+
+// CreateProcessorSettingsWith is CreateProcessorSettings over an existing session.
+func CreateProcessorSettingsWith(service *wmiext.Service, settings *ProcessorSettingData) (string, error) {
+	str, err := hypervsdk.CreateResourceSettingGenericWith(service, settings, ProcessorResourceType)
+	if err != nil {
+		err = fmt.Errorf("could not create processor settings: %w", err)
+	}
+	return str, err
+}
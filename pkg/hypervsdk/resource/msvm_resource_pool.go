@@ -1,7 +1,6 @@
 package resource
 
 import (
-	"fmt"
 	"github.com/pkg/errors"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
 	"time"
@@ -187,30 +186,27 @@ func GetPrimordialResourcePool(session *wmiext.Service, rtype ResourcePool_Resou
 }
 
 func GetResourcePools[T any](session *wmiext.Service, isPrimordial bool, resType *ResourceTypeValue) (col []*wmiext.Instance, err error) {
-	wquery := "SELECT * FROM Msvm_ResourcePool"
+	query := wmiext.Select("Msvm_ResourcePool")
 	if isPrimordial {
-		wquery += " WHERE Primordial=True"
+		query.Where(wmiext.Equal("Primordial", true))
 	}
 	if resType != nil {
 		if resType.ResourceType > 0 {
-			if isPrimordial {
-				wquery += fmt.Sprintf(" AND ResourceType=%d", resType.ResourceType)
-			} else {
-				wquery += fmt.Sprintf(" WHERE ResourceType=%d", resType.ResourceType)
-			}
+			query.Where(wmiext.Equal("ResourceType", resType.ResourceType))
 		}
 		if len(resType.ResourceSubType) > 0 {
-			wquery += fmt.Sprintf(" AND ResourceSubType='%s'", resType.ResourceSubType)
+			query.Where(wmiext.Equal("ResourceSubType", resType.ResourceSubType))
 		}
 		if len(resType.OtherResourceType) > 0 {
-			wquery += fmt.Sprintf(" AND OtherResourceType='%s'", resType.OtherResourceType)
+			query.Where(wmiext.Equal("OtherResourceType", resType.OtherResourceType))
 		}
 	}
+	wquery := query.String()
 	if col, err = session.FindInstances(wquery); err != nil {
 		return nil, err
 	}
 	if len(col) == 0 {
-		err = errors.Wrapf(wmiext.NotFound, "Cim_ResourcePool Primordial[%s] Type[%s]", isPrimordial, resType.String())
+		err = errors.Wrapf(wmiext.NotFound, "Cim_ResourcePool Primordial[%t] Type[%s]", isPrimordial, resType.String())
 	}
 	return
 }
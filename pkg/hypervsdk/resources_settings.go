@@ -1,7 +1,6 @@
 package hypervsdk
 
 import (
-	"fmt"
 	"github.com/pkg/errors"
 	hypervsdk "github.com/rokukoo/hyperv/pkg/hypervsdk/utils"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
@@ -53,7 +52,13 @@ func CreateResourceSettingGeneric(settings interface{}, resourceType string) (st
 	if service, err = hypervsdk.NewLocalHyperVService(); err != nil {
 		return "", err
 	}
+	defer service.Close()
+
+	return CreateResourceSettingGenericWith(service, settings, resourceType)
+}
 
+// CreateResourceSettingGenericWith is CreateResourceSettingGeneric over an existing session.
+func CreateResourceSettingGenericWith(service *wmiext.Service, settings interface{}, resourceType string) (string, error) {
 	ref, err := FindResourceDefaults(service, resourceType)
 	if err != nil {
 		return "", err
@@ -86,6 +91,11 @@ func PopulateDefaults(subType string, settings interface{}) error {
 	}
 	defer service.Close()
 
+	return PopulateDefaultsWith(service, subType, settings)
+}
+
+// PopulateDefaultsWith is PopulateDefaults over an existing session.
+func PopulateDefaultsWith(service *wmiext.Service, subType string, settings interface{}) error {
 	ref, err := FindResourceDefaults(service, subType)
 	if err != nil {
 		return err
@@ -95,7 +105,7 @@ func PopulateDefaults(subType string, settings interface{}) error {
 }
 
 func FindResourceDefaults(service *wmiext.Service, subType string) (string, error) {
-	wql := fmt.Sprintf("SELECT * FROM Msvm_AllocationCapabilities WHERE ResourceSubType = '%s'", subType)
+	wql := wmiext.Select("Msvm_AllocationCapabilities").Where(wmiext.Equal("ResourceSubType", subType)).String()
 	instance, err := service.FindFirstInstance(wql)
 	if err != nil {
 		return "", err
@@ -107,7 +117,7 @@ func FindResourceDefaults(service *wmiext.Service, subType string) (string, erro
 		return "", err
 	}
 
-	enum, err := service.ExecQuery(fmt.Sprintf("references of {%s} where ResultClass = Msvm_SettingsDefineCapabilities", path))
+	enum, err := service.ExecQuery(wmiext.ReferencesOf(path).ResultClass("Msvm_SettingsDefineCapabilities").String())
 	if err != nil {
 		return "", err
 	}
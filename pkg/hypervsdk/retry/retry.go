@@ -0,0 +1,192 @@
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	hverrors "github.com/rokukoo/hyperv/pkg/hypervsdk/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+// Attempt describes a failed invocation of a method, reported to Policy.OnAttempt.
+type Attempt struct {
+	Method string
+	// Number is the attempt that failed, starting at 1.
+	Number  int
+	Err     error
+	Elapsed time.Duration
+	// Delay is the wait before the next attempt, zero when Retrying is false.
+	Delay    time.Duration
+	Retrying bool
+}
+
+// Policy decides whether and when a failed Hyper-V method is invoked again.
+type Policy struct {
+	// MaxAttempts bounds the number of invocations, 0 for no limit.
+	MaxAttempts int
+	// Deadline bounds the time spent retrying since the first invocation, 0 for no limit.
+	Deadline time.Duration
+	// Backoff returns the delay after the failed attempt, attempt starting at 0.
+	Backoff wmiext.PollStrategy
+	// Jitter randomizes each delay by up to this fraction in either direction, between 0 and 1.
+	Jitter float64
+	// Retryable classifies the errors worth retrying, IsTransient when nil.
+	Retryable func(err error) bool
+	// OnAttempt is called after each failed attempt.
+	OnAttempt func(attempt Attempt)
+	// Clock measures the deadline and waits between attempts, wmiext.SystemClock when nil.
+	Clock wmiext.Clock
+	// Rand returns a number in [0, 1) used for the jitter, math/rand when nil.
+	Rand func() float64
+	// Telemetry receives the failed attempts, wmiext.GetTelemetry when nil.
+	Telemetry *wmiext.Telemetry
+}
+
+// Never invokes methods once.
+var Never = &Policy{MaxAttempts: 1}
+
+// DefaultPolicy retries transient errors with an exponential backoff from 100ms to 2s for up to a
+// minute.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Deadline: time.Minute,
+		Backoff:  wmiext.ExponentialPoll(100*time.Millisecond, 2*time.Second),
+		Jitter:   0.2,
+	}
+}
+
+// IsTransient reports whether err is a Hyper-V error expected to go away by itself, the system
+// being in use or in a state that does not allow the operation, typically during a state transition.
+func IsTransient(err error) bool {
+	return errors.Is(err, hverrors.ErrInvalidState) || errors.Is(err, hverrors.ErrSystemInUse)
+}
+
+func (p *Policy) clock() wmiext.Clock {
+	if p.Clock == nil {
+		return wmiext.SystemClock
+	}
+	return p.Clock
+}
+
+func (p *Policy) telemetry() *wmiext.Telemetry {
+	if p.Telemetry == nil {
+		return wmiext.GetTelemetry()
+	}
+	return p.Telemetry
+}
+
+// report notifies OnAttempt and the telemetry of a failed attempt.
+func (p *Policy) report(ctx context.Context, attempt Attempt) {
+	if p.OnAttempt != nil {
+		p.OnAttempt(attempt)
+	}
+
+	telemetry := p.telemetry()
+	telemetry.Add(ctx, wmiext.MetricRetries, 1,
+		wmiext.Attr(wmiext.AttributeMethod, attempt.Method),
+		wmiext.Attr(wmiext.AttributeAttempt, attempt.Number),
+		wmiext.Attr(wmiext.AttributeRetrying, attempt.Retrying))
+	if attempt.Retrying {
+		telemetry.Log().Warn("Retrying Hyper-V method", "method", attempt.Method, "attempt", attempt.Number,
+			"delay", attempt.Delay, "error", attempt.Err)
+	}
+}
+
+func (p *Policy) retryable(err error) bool {
+	if p.Retryable == nil {
+		return IsTransient(err)
+	}
+	return p.Retryable(err)
+}
+
+func (p *Policy) delay(attempt int) time.Duration {
+	backoff := p.Backoff
+	if backoff == nil {
+		backoff = wmiext.DefaultPollStrategy
+	}
+	d := backoff(attempt)
+	if p.Jitter <= 0 {
+		return d
+	}
+	random := p.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	return time.Duration(float64(d) * (1 + p.Jitter*(2*random()-1)))
+}
+
+// Do invokes fn until it succeeds, fails with an error that is not retryable, or the attempts,
+// deadline or ctx run out. The last error of fn is returned, or the error of ctx.
+func (p *Policy) Do(ctx context.Context, method string, fn func() error) error {
+	clock := p.clock()
+	started := clock.Now()
+	for number := 1; ; number++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		attempt := Attempt{Method: method, Number: number, Err: err, Elapsed: clock.Now().Sub(started)}
+		if p.retryable(err) && (p.MaxAttempts == 0 || number < p.MaxAttempts) {
+			attempt.Delay = p.delay(number - 1)
+			attempt.Retrying = p.Deadline == 0 || attempt.Elapsed+attempt.Delay <= p.Deadline
+		}
+		if !attempt.Retrying {
+			attempt.Delay = 0
+		}
+		p.report(ctx, attempt)
+		if !attempt.Retrying {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(attempt.Delay):
+		}
+	}
+}
+
+// Policies holds the retry policy of each method of a management service.
+type Policies struct {
+	// Default applies to the methods without a policy of their own, DefaultPolicy when nil.
+	Default *Policy
+	methods map[string]*Policy
+}
+
+// NewPolicies returns policies applying def to every method.
+func NewPolicies(def *Policy) *Policies {
+	return &Policies{Default: def, methods: map[string]*Policy{}}
+}
+
+// Set sets the policy of method, Never to disable retries for it.
+func (p *Policies) Set(method string, policy *Policy) *Policies {
+	if p.methods == nil {
+		p.methods = map[string]*Policy{}
+	}
+	p.methods[method] = policy
+	return p
+}
+
+// For returns the policy of method.
+func (p *Policies) For(method string) *Policy {
+	if p != nil {
+		if policy, ok := p.methods[method]; ok {
+			return policy
+		}
+		if p.Default != nil {
+			return p.Default
+		}
+	}
+	return DefaultPolicy()
+}
+
+// Do invokes fn with the policy of method, see Policy.Do.
+func (p *Policies) Do(ctx context.Context, method string, fn func() error) error {
+	return p.For(method).Do(ctx, method, fn)
+}
@@ -0,0 +1,193 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	hverrors "github.com/rokukoo/hyperv/pkg/hypervsdk/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock advances its time by the requested delay on each After call.
+type fakeClock struct {
+	now    time.Time
+	delays []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// failing returns a function failing with the return values in order, then succeeding.
+func failing(calls *int, returnValues ...int32) func() error {
+	return func() error {
+		*calls++
+		if *calls > len(returnValues) {
+			return nil
+		}
+		return hverrors.NewReturnValueError("DestroySystem", "", returnValues[*calls-1])
+	}
+}
+
+func TestPolicy_Do(t *testing.T) {
+	clock := &fakeClock{}
+	var attempts []Attempt
+	policy := &Policy{
+		Backoff:   wmiext.ExponentialPoll(100*time.Millisecond, time.Second),
+		Clock:     clock,
+		OnAttempt: func(attempt Attempt) { attempts = append(attempts, attempt) },
+	}
+
+	calls := 0
+	require.NoError(t, policy.Do(context.Background(), "DestroySystem", failing(&calls, 32775, 32774, 32775)))
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}, clock.delays)
+
+	require.Len(t, attempts, 3)
+	assert.Equal(t, "DestroySystem", attempts[0].Method)
+	assert.Equal(t, 3, attempts[2].Number)
+	assert.Equal(t, 300*time.Millisecond, attempts[2].Elapsed)
+	assert.True(t, attempts[2].Retrying)
+	assert.ErrorIs(t, attempts[2].Err, hverrors.ErrInvalidState)
+}
+
+func TestPolicy_DoNotRetryable(t *testing.T) {
+	clock := &fakeClock{}
+	calls := 0
+	err := (&Policy{Clock: clock}).Do(context.Background(), "DestroySystem", failing(&calls, 32769))
+	assert.ErrorIs(t, err, hverrors.ErrAccessDenied)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, clock.delays)
+
+	wmiErr := errors.New("RPC server unavailable")
+	err = (&Policy{Clock: clock}).Do(context.Background(), "DestroySystem", func() error { return wmiErr })
+	assert.Equal(t, wmiErr, err)
+}
+
+func TestPolicy_DoMaxAttempts(t *testing.T) {
+	clock := &fakeClock{}
+	var last Attempt
+	policy := &Policy{
+		MaxAttempts: 3,
+		Backoff:     wmiext.ConstantPoll(time.Second),
+		Clock:       clock,
+		OnAttempt:   func(attempt Attempt) { last = attempt },
+	}
+
+	calls := 0
+	err := policy.Do(context.Background(), "DestroySystem", failing(&calls, 32775, 32775, 32775, 32775))
+	assert.ErrorIs(t, err, hverrors.ErrInvalidState)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 3, last.Number)
+	assert.False(t, last.Retrying)
+	assert.Zero(t, last.Delay)
+}
+
+func TestPolicy_DoDeadline(t *testing.T) {
+	clock := &fakeClock{}
+	policy := &Policy{
+		Deadline: 2500 * time.Millisecond,
+		Backoff:  wmiext.ConstantPoll(time.Second),
+		Clock:    clock,
+	}
+
+	calls := 0
+	err := policy.Do(context.Background(), "DestroySystem", failing(&calls, 32775, 32775, 32775, 32775))
+	assert.ErrorIs(t, err, hverrors.ErrInvalidState)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, clock.delays)
+}
+
+func TestPolicy_DoJitter(t *testing.T) {
+	clock := &fakeClock{}
+	random := []float64{0, 0.5, 0.999}
+	policy := &Policy{
+		Backoff: wmiext.ConstantPoll(time.Second),
+		Jitter:  0.2,
+		Clock:   clock,
+		Rand: func() float64 {
+			r := random[0]
+			random = random[1:]
+			return r
+		},
+	}
+
+	calls := 0
+	require.NoError(t, policy.Do(context.Background(), "DestroySystem", failing(&calls, 32775, 32775, 32775)))
+	require.Len(t, clock.delays, 3)
+	assert.Equal(t, 800*time.Millisecond, clock.delays[0])
+	assert.Equal(t, time.Second, clock.delays[1])
+	assert.InDelta(t, float64(1200*time.Millisecond), float64(clock.delays[2]), float64(time.Millisecond))
+}
+
+func TestPolicy_DoContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := (&Policy{Clock: &fakeClock{}}).Do(ctx, "DestroySystem", failing(&calls, 32775))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
+
+func TestPolicies_For(t *testing.T) {
+	def := &Policy{MaxAttempts: 5}
+	policies := NewPolicies(def).Set("DefineSystem", Never)
+	assert.Same(t, Never, policies.For("DefineSystem"))
+	assert.Same(t, def, policies.For("DestroySystem"))
+
+	var unset *Policies
+	assert.Equal(t, time.Minute, unset.For("DestroySystem").Deadline)
+
+	calls := 0
+	err := policies.Do(context.Background(), "DefineSystem", failing(&calls, 32775))
+	assert.ErrorIs(t, err, hverrors.ErrInvalidState)
+	assert.Equal(t, 1, calls)
+}
+
+// countingMeter counts the retries reported through telemetry.
+type countingMeter struct {
+	retrying, failed int64
+}
+
+func (m *countingMeter) RecordDuration(context.Context, string, time.Duration, ...wmiext.Attribute) {}
+
+func (m *countingMeter) Add(_ context.Context, name string, delta int64, attrs ...wmiext.Attribute) {
+	if name != wmiext.MetricRetries {
+		return
+	}
+	for _, attr := range attrs {
+		if attr.Key == wmiext.AttributeRetrying && attr.Value == true {
+			m.retrying += delta
+			return
+		}
+	}
+	m.failed += delta
+}
+
+func TestPolicy_DoTelemetry(t *testing.T) {
+	meter := &countingMeter{}
+	policy := &Policy{
+		MaxAttempts: 3,
+		Clock:       &fakeClock{},
+		Telemetry:   &wmiext.Telemetry{Meter: meter},
+	}
+
+	calls := 0
+	err := policy.Do(context.Background(), "DestroySystem", failing(&calls, 32775, 32775, 32775))
+	assert.ErrorIs(t, err, hverrors.ErrInvalidState)
+	assert.Equal(t, int64(2), meter.retrying)
+	assert.Equal(t, int64(1), meter.failed)
+}
@@ -6,7 +6,7 @@ import (
 )
 
 const (
-	Msvm_StorageAllocationSettingData = "Msvm_StorageAllocationSettingData "
+	Msvm_StorageAllocationSettingData = "Msvm_StorageAllocationSettingData"
 )
 
 type StorageAllocationSettingData struct {
@@ -1,7 +1,6 @@
 package disk
 
 import (
-	"fmt"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/resource"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage/allocation"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
@@ -33,7 +32,7 @@ func (vhd *VirtualHardDisk) GetDrive() (resourceAllocationSettingData *resource.
 }
 
 func GetVirtualHardDiskByPath(session *wmiext.Service, path string) (virtualHardDisk *VirtualHardDisk, err error) {
-	wquery := fmt.Sprintf("SELECT * FROM Msvm_StorageAllocationSettingData")
+	wquery := wmiext.Select("Msvm_StorageAllocationSettingData").String()
 	instances, err := session.FindInstances(wquery)
 	if err != nil {
 		return nil, err
@@ -1,6 +1,13 @@
 package storage
 
 import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/resource"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/retry"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage/allocation"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage/disk"
 	utils "github.com/rokukoo/hyperv/pkg/hypervsdk/utils"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
@@ -13,93 +20,274 @@ const (
 type ImageManagementService struct {
 	Session *wmiext.Service
 	*wmiext.Instance
+	// Retry is the retry policy of each method, retry.DefaultPolicy for those not configured.
+	Retry *retry.Policies
 }
 
 func LocalImageManagementService() (*ImageManagementService, error) {
 	var (
 		session *wmiext.Service
-		svc     *wmiext.Instance
+		ims     *ImageManagementService
 		err     error
 	)
 	// Get the WMI service
 	if session, err = utils.NewLocalHyperVService(); err != nil {
 		return nil, err
 	}
+	if ims, err = NewImageManagementService(session); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return ims, nil
+}
+
+// NewImageManagementService returns the image management service of the session, which remains
+// owned by the caller.
+func NewImageManagementService(session *wmiext.Service) (*ImageManagementService, error) {
 	// Get the singleton instance
-	if svc, err = session.GetSingletonInstance(Msvm_ImageManagementService); err != nil {
+	svc, err := session.GetSingletonInstance(Msvm_ImageManagementService)
+	if err != nil {
 		return nil, err
 	}
-	return &ImageManagementService{session, svc}, nil
+	return &ImageManagementService{Session: session, Instance: svc, Retry: retry.NewPolicies(retry.DefaultPolicy())}, nil
 }
 
-func (ims *ImageManagementService) ResizeVirtualHardDisk(path string, size uint64) (err error) {
-	var (
-		job         *wmiext.Instance
-		returnValue int32
-	)
+// ResizeVirtualHardDisk starts resizing the virtual hard disk at path to size bytes. The returned job
+// completes when the resize is done.
+func (ims *ImageManagementService) ResizeVirtualHardDisk(path string, size uint64, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	var started *wmiext.Job
+
+	if err := ims.Retry.Do(context.Background(), "ResizeVirtualHardDisk", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
 
-	if err = ims.Method("ResizeVirtualHardDisk").
-		In("Path", path).
-		In("MaxInternalSize", size).
-		Execute().
-		Out("Job", &job).
-		Out("ReturnValue", &returnValue).
-		End(); err != nil {
+		if err = ims.Method("ResizeVirtualHardDisk").
+			In("Path", path).
+			In("MaxInternalSize", size).
+			Execute().
+			Out("Job", &job).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
+
+		started, err = utils.StartJob(ims.Instance, "ResizeVirtualHardDisk", returnValue, job, opts...)
 		return err
+	}); err != nil {
+		return nil, err
 	}
+	return started, nil
+}
+
+// CreateVirtualHardDisk starts creating a virtual hard disk described by settings. The returned job
+// completes when the disk file is created.
+func (ims *ImageManagementService) CreateVirtualHardDisk(settings *VirtualHardDiskSettingData, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	var (
+		settingsObj string = settings.GetCimText()
+
+		started *wmiext.Job
+	)
+
+	if err := ims.Retry.Do(context.Background(), "CreateVirtualHardDisk", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err = ims.Method("CreateVirtualHardDisk").
+			In("VirtualDiskSettingData", settingsObj).
+			Execute().
+			Out("Job", &job).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
 
-	if err = utils.WaitResult(returnValue, ims.Session, job, "Failed to resize virtual hard disk", nil); err != nil {
+		started, err = utils.StartJob(ims.Instance, "CreateVirtualHardDisk", returnValue, job, opts...)
 		return err
+	}); err != nil {
+		return nil, err
 	}
-
-	return nil
+	return started, nil
 }
 
-func (ims *ImageManagementService) CreateVirtualHardDisk(settings *VirtualHardDiskSettingData) error {
+// ConvertVirtualHardDisk starts converting the virtual hard disk at sourcePath into a new disk described
+// by settings, merging its differencing chain. The returned job completes when the new disk is written.
+func (ims *ImageManagementService) ConvertVirtualHardDisk(sourcePath string, settings *VirtualHardDiskSettingData, opts ...wmiext.JobOption) (*wmiext.Job, error) {
 	var (
 		settingsObj string = settings.GetCimText()
 
-		err         error
-		job         *wmiext.Instance
-		returnValue int32
+		started *wmiext.Job
 	)
 
-	if err = ims.Method("CreateVirtualHardDisk").
-		In("VirtualDiskSettingData", settingsObj).
-		Execute().
-		Out("Job", &job).
-		Out("ReturnValue", &returnValue).
-		End(); err != nil {
+	if err := ims.Retry.Do(context.Background(), "ConvertVirtualHardDisk", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err = ims.Method("ConvertVirtualHardDisk").
+			In("SourcePath", sourcePath).
+			In("VirtualDiskSettingData", settingsObj).
+			Execute().
+			Out("Job", &job).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
+
+		started, err = utils.StartJob(ims.Instance, "ConvertVirtualHardDisk", returnValue, job, opts...)
 		return err
+	}); err != nil {
+		return nil, err
 	}
+	return started, nil
+}
 
-	if err = utils.WaitResult(returnValue, ims.Session, job, "Failed to create virtual hard disk", nil); err != nil {
-		return err
+// SetParentVirtualHardDisk starts changing the parent of the differencing disk at path to parentPath,
+// such as a copy of the parent. The returned job completes when the disk is updated.
+func (ims *ImageManagementService) SetParentVirtualHardDisk(path string, parentPath string, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	return ims.setVirtualHardDiskSettingData(path, "ParentPath", parentPath, opts...)
+}
+
+// SetVirtualDiskId starts changing the identifier of the virtual hard disk at path to id, so that a copy
+// of a disk can be attached next to the original. The returned job completes when the disk is updated.
+func (ims *ImageManagementService) SetVirtualDiskId(path string, id string, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	return ims.setVirtualHardDiskSettingData(path, "VirtualDiskId", id, opts...)
+}
+
+// setVirtualHardDiskSettingData starts setting the property of the virtual hard disk at path to value.
+func (ims *ImageManagementService) setVirtualHardDiskSettingData(path string, property string, value string, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	vhdsetting, err := ims.GetDefaultVirtualHardDiskSettingData()
+	if err != nil {
+		return nil, err
+	}
+	defer vhdsetting.Close()
+
+	if err = vhdsetting.Put("Path", path); err != nil {
+		return nil, err
 	}
+	if err = vhdsetting.Put(property, value); err != nil {
+		return nil, err
+	}
+	settingsObj := vhdsetting.GetCimText()
+
+	var started *wmiext.Job
+	if err = ims.Retry.Do(context.Background(), "SetVirtualHardDiskSettingData", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err = ims.Method("SetVirtualHardDiskSettingData").
+			In("VirtualDiskSettingData", settingsObj).
+			Execute().
+			Out("Job", &job).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
 
-	return nil
+		started, err = utils.StartJob(ims.Instance, "SetVirtualHardDiskSettingData", returnValue, job, opts...)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return started, nil
 }
 
+// GetSnapshotVirtualHardDisks returns the differencing disks created on top of virtualHardDisk by
+// the checkpoints of its virtual machine, see GetDescendantVirtualHardDisks.
 func (ims *ImageManagementService) GetSnapshotVirtualHardDisks(
 	virtualHardDisk *disk.VirtualHardDisk,
 ) (
 	snapshots []*disk.VirtualHardDisk,
 	err error,
 ) {
-	var virtualHardDiskSettingData *VirtualHardDiskSettingData
-	virtualHardDisks := []*disk.VirtualHardDisk{}
-	wquery := "SELECT * FROM Msvm_StorageAllocationSettingData"
-	if err = ims.Session.FindObjects(wquery, virtualHardDisks); err != nil {
-		return
-	}
-	for _, vhd := range virtualHardDisks {
-		if virtualHardDiskSettingData, err = ims.GetVirtualHardDiskSettingData(vhd.GetPath()); err != nil {
+	return ims.GetDescendantVirtualHardDisks(virtualHardDisk.GetPath())
+}
+
+// GetVirtualHardDiskChain returns the settings of the virtual hard disk at path followed by those of
+// its parents, up to the base disk. A disk which is not a differencing disk is its own chain.
+func (ims *ImageManagementService) GetVirtualHardDiskChain(path string) ([]*VirtualHardDiskSettingData, error) {
+	var chain []*VirtualHardDiskSettingData
+	visited := map[string]bool{}
+	for path != "" {
+		if visited[strings.ToLower(path)] {
+			return nil, errors.Errorf("virtual hard disk chain loops back to %s", path)
+		}
+		visited[strings.ToLower(path)] = true
+
+		settings, err := ims.GetVirtualHardDiskSettingData(path)
+		if err != nil {
 			return nil, err
 		}
-		if virtualHardDiskSettingData.ParentPath != virtualHardDisk.GetPath() {
+		chain = append(chain, settings)
+		path = settings.ParentPath
+	}
+	return chain, nil
+}
+
+// GetDescendantVirtualHardDisks returns the virtual hard disks, of the virtual machines and of their
+// snapshots, whose differencing chain goes through the disk at path. Resizing, moving or deleting
+// the disk at path breaks them.
+func (ims *ImageManagementService) GetDescendantVirtualHardDisks(path string) (descendants []*disk.VirtualHardDisk, err error) {
+	allocations, err := wmiext.Query[allocation.StorageAllocationSettingData](ims.Session,
+		wmiext.Select(allocation.Msvm_StorageAllocationSettingData).
+			Where(wmiext.Equal("ResourceType", resource.ResourcePool_ResourceType_Logical_Disk)))
+	if err != nil {
+		return nil, err
+	}
+
+	// parents caches the parent of the disks already read, the snapshots of a virtual machine sharing
+	// most of their chain
+	parents := map[string]string{}
+	descends := func(child string) (bool, error) {
+		for visited := map[string]bool{}; child != ""; {
+			key := strings.ToLower(child)
+			if visited[key] {
+				return false, errors.Errorf("virtual hard disk chain loops back to %s", child)
+			}
+			visited[key] = true
+
+			parent, ok := parents[key]
+			if !ok {
+				settings, err := ims.GetVirtualHardDiskSettingData(child)
+				if err != nil {
+					return false, err
+				}
+				parent = settings.ParentPath
+				parents[key] = parent
+			}
+			if strings.EqualFold(parent, path) {
+				return true, nil
+			}
+			child = parent
+		}
+		return false, nil
+	}
+
+	for i, sasd := range allocations {
+		var descendant bool
+		if len(sasd.HostResource) > 0 && !strings.EqualFold(sasd.HostResource[0], path) {
+			if descendant, err = descends(sasd.HostResource[0]); err != nil {
+				closeAllocations(allocations[i:])
+				return nil, err
+			}
+		}
+		if !descendant {
+			sasd.Close()
 			continue
 		}
-		snapshots = append(snapshots, vhd)
+		descendants = append(descendants, &disk.VirtualHardDisk{StorageAllocationSettingData: sasd})
+	}
+	return descendants, nil
+}
+
+func closeAllocations(allocations []*allocation.StorageAllocationSettingData) {
+	for _, sasd := range allocations {
+		sasd.Close()
 	}
-	return
 }
@@ -1,10 +1,8 @@
 package storage
 
 import (
-	"encoding/xml"
 	utils "github.com/rokukoo/hyperv/pkg/hypervsdk/utils"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
-	"strconv"
 	"time"
 )
 
@@ -15,10 +13,11 @@ const (
 type VirtualHardDiskType uint16
 
 const (
-	VirtualHardDiskType_NONE   = 0
-	VirtualHardDiskType_LEGACY = 1
-	VirtualHardDiskType_FLAT   = 2
-	VirtualHardDiskType_SPARSE = 3
+	VirtualHardDiskType_NONE         = 0
+	VirtualHardDiskType_LEGACY       = 1
+	VirtualHardDiskType_FLAT         = 2
+	VirtualHardDiskType_SPARSE       = 3
+	VirtualHardDiskType_DIFFERENCING = 4
 )
 
 // VHD, VHDX, VHDSet
@@ -125,19 +124,6 @@ func (ims *ImageManagementService) NewVirtualHardDiskSettingData(
 	return vhdsetting, nil
 }
 
-type INSTANCE struct {
-	XMLName   xml.Name `xml:"INSTANCE"`
-	Text      string   `xml:",chardata"`
-	CLASSNAME string   `xml:"CLASSNAME,attr"`
-	PROPERTY  []struct {
-		Text       string `xml:",chardata"`
-		NAME       string `xml:"NAME,attr"`
-		TYPE       string `xml:"TYPE,attr"`
-		PROPAGATED string `xml:"PROPAGATED,attr"`
-		VALUE      string `xml:"VALUE"`
-	} `xml:"PROPERTY"`
-}
-
 func getVirtualHardDiskSettingDataFromXml(
 	xmlInstance string,
 ) (
@@ -145,50 +131,10 @@ func getVirtualHardDiskSettingDataFromXml(
 	error,
 ) {
 	var virtualHardDiskSettingData = &VirtualHardDiskSettingData{}
-	var err error
-	var t uint64
-	var diskData INSTANCE
-
-	if err = xml.Unmarshal([]byte(xmlInstance), &diskData); err != nil {
+	if err := wmiext.UnmarshalCimXml(xmlInstance, virtualHardDiskSettingData); err != nil {
 		return nil, err
 	}
-
-	for _, property := range diskData.PROPERTY {
-		switch property.NAME {
-		case "MaxInternalSize":
-			virtualHardDiskSettingData.Size, err = strconv.ParseUint(property.VALUE, 10, 64)
-			if err != nil {
-				return nil, err
-			}
-		case "BlockSize":
-			t, err = strconv.ParseUint(property.VALUE, 10, 32)
-			if err != nil {
-				return nil, err
-			}
-			virtualHardDiskSettingData.BlockSize = uint32(t)
-		case "LogicalSectorSize":
-			t, err = strconv.ParseUint(property.VALUE, 10, 32)
-			if err != nil {
-				return nil, err
-			}
-			virtualHardDiskSettingData.LogicalSectorSize = uint32(t)
-		case "PhysicalSectorSize":
-			t, err = strconv.ParseUint(property.VALUE, 10, 32)
-			if err != nil {
-				return nil, err
-			}
-			virtualHardDiskSettingData.PhysicalSectorSize = uint32(t)
-		case "Format":
-			t, err = strconv.ParseUint(property.VALUE, 10, 16)
-			if err != nil {
-				return nil, err
-			}
-			virtualHardDiskSettingData.Format = uint16(t)
-		case "ParentPath":
-			virtualHardDiskSettingData.ParentPath = property.VALUE
-		}
-	}
-
+	virtualHardDiskSettingData.Size = virtualHardDiskSettingData.MaxInternalSize
 	return virtualHardDiskSettingData, nil
 }
 
@@ -210,7 +156,7 @@ func (ims *ImageManagementService) GetVirtualHardDiskSettingData(path string) (*
 		return nil, err
 	}
 
-	if err = utils.WaitResult(returnValue, ims.Session, job, "Failed to get setting data for disk", nil); err != nil {
+	if err = utils.WaitResult(ims.Instance, "GetVirtualHardDiskSettingData", returnValue, job); err != nil {
 		return nil, err
 	}
 
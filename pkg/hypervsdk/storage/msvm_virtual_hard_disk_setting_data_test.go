@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVirtualHardDiskSettingDataFromXml(t *testing.T) {
+	text, err := wmiext.MarshalCimXml(Msvm_VirtualHardDiskSettingData, &VirtualHardDiskSettingData{
+		Path:               `C:\vhd\child.avhdx`,
+		ParentPath:         `C:\vhd\parent.vhdx`,
+		Format:             VirtualHardDiskFormat_2,
+		Type:               VirtualHardDiskType_SPARSE,
+		MaxInternalSize:    64 << 30,
+		BlockSize:          32 << 20,
+		LogicalSectorSize:  512,
+		PhysicalSectorSize: 4096,
+	})
+	require.NoError(t, err)
+
+	settingData, err := getVirtualHardDiskSettingDataFromXml(text)
+	require.NoError(t, err)
+	assert.Equal(t, `C:\vhd\child.avhdx`, settingData.Path)
+	assert.Equal(t, `C:\vhd\parent.vhdx`, settingData.ParentPath)
+	assert.Equal(t, uint16(VirtualHardDiskFormat_2), settingData.Format)
+	assert.Equal(t, uint64(64<<30), settingData.Size)
+	assert.Equal(t, uint32(32<<20), settingData.BlockSize)
+	assert.Equal(t, uint32(512), settingData.LogicalSectorSize)
+	assert.Equal(t, uint32(4096), settingData.PhysicalSectorSize)
+}
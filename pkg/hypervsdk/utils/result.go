@@ -1,33 +1,36 @@
 package hypervsdk
 
 import (
-	"fmt"
+	"context"
+
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/errors"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
-	"strings"
 )
 
-func WaitResult(res int32, service *wmiext.Service, job *wmiext.Instance, errorMsg string, translate func(int) error) error {
-	var err error
-
-	switch res {
-	case 0:
-		return nil
-	case 4096:
-		err = wmiext.WaitJob(service, job)
-		//defer job.Close()
-	default:
-		if translate != nil {
-			return translate(int(res))
+// StartJob returns the job tracking the invocation of method on target from its return value, 0 when
+// the method completed synchronously and 4096 when a job was started. Other return values and failed
+// jobs are reported as an *errors.HyperVError.
+func StartJob(target *wmiext.Instance, method string, res int32, job *wmiext.Instance, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	path, _ := target.Path()
+	if err := errors.NewReturnValueError(method, path, res); err != nil {
+		if job != nil {
+			job.Close()
 		}
-
-		return fmt.Errorf("%s (result code %d)", errorMsg, res)
+		return nil, err
 	}
 
+	translate := wmiext.WithErrorTranslator(func(err error) error {
+		return errors.NewJobError(method, path, err)
+	})
+	return wmiext.NewMethodJob(target.GetService(), res, job, append([]wmiext.JobOption{translate}, opts...)...)
+}
+
+// WaitResult waits for the completion of method invoked on target, see StartJob.
+func WaitResult(target *wmiext.Instance, method string, res int32, job *wmiext.Instance) error {
+	j, err := StartJob(target, method, res, job)
 	if err != nil {
-		desc, _ := job.GetAsString("ErrorDescription")
-		desc = strings.Replace(desc, "\n", " ", -1)
-		return fmt.Errorf("%s: %w (%s)", errorMsg, err, desc)
+		return err
 	}
-
+	_, err = j.Wait(context.Background())
 	return err
 }
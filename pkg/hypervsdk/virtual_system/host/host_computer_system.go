@@ -1,8 +1,6 @@
 package host
 
 import (
-	"fmt"
-
 	"github.com/pkg/errors"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking/switch_extension"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
@@ -15,17 +13,22 @@ type HostComputerSystem struct {
 
 // GetHostComputerSystem gets an existing virtual machine
 func GetHostComputerSystem() (*HostComputerSystem, error) {
-	var (
-		vm  = &virtual_system.ComputerSystem{}
-		err error
-	)
-	// TODO: Add a filter for the host computer system
-	wquery := fmt.Sprintf("SELECT * FROM %s WHERE Description != 'Microsoft Virtual Machine' AND Description != 'Microsoft 虚拟机'", virtual_system.Msvm_ComputerSystem)
 	s, err := virtual_system.LocalVirtualSystemManagementService()
 	if err != nil {
 		return nil, err
 	}
-	if err = s.Session.FindFirstObject(wquery, vm); err != nil {
+	return GetHostComputerSystemWith(s.Session)
+}
+
+// GetHostComputerSystemWith is GetHostComputerSystem over an existing session.
+func GetHostComputerSystemWith(session *wmiext.Service) (*HostComputerSystem, error) {
+	vm := &virtual_system.ComputerSystem{}
+	// TODO: Add a filter for the host computer system
+	wquery := wmiext.Select(virtual_system.Msvm_ComputerSystem).
+		Where(wmiext.NotEqual("Description", "Microsoft Virtual Machine")).
+		Where(wmiext.NotEqual("Description", "Microsoft 虚拟机")).
+		String()
+	if err := session.FindFirstObject(wquery, vm); err != nil {
 		return nil, err
 	}
 	return &HostComputerSystem{vm}, nil
@@ -85,6 +88,21 @@ func DefaultEthernetSwitchPortBandwidthSettingData() (*switch_extension.Ethernet
 	if err != nil {
 		return nil, err
 	}
+	return defaultEthernetSwitchPortBandwidthSettingData(hc)
+}
+
+// DefaultEthernetSwitchPortBandwidthSettingDataWith is DefaultEthernetSwitchPortBandwidthSettingData
+// over an existing session.
+func DefaultEthernetSwitchPortBandwidthSettingDataWith(session *wmiext.Service) (*switch_extension.EthernetSwitchPortBandwidthSettingData, error) {
+	hc, err := GetHostComputerSystemWith(session)
+	if err != nil {
+		return nil, err
+	}
+	defer hc.Close()
+	return defaultEthernetSwitchPortBandwidthSettingData(hc)
+}
+
+func defaultEthernetSwitchPortBandwidthSettingData(hc *HostComputerSystem) (*switch_extension.EthernetSwitchPortBandwidthSettingData, error) {
 	inst, err := hc.GetDefaultPortSettingData("Ethernet Switch Port Bandwidth Settings", "Msvm_EthernetSwitchPortBandwidthSettingData")
 	if err != nil {
 		return nil, err
@@ -95,3 +113,18 @@ func DefaultEthernetSwitchPortBandwidthSettingData() (*switch_extension.Ethernet
 	}
 	return spbs, nil
 }
+
+// DefaultEthernetSwitchPortVlanSettingDataWith returns the default EthernetSwitchPortVlanSettingData
+// over an existing session.
+func DefaultEthernetSwitchPortVlanSettingDataWith(session *wmiext.Service) (*switch_extension.EthernetSwitchPortVlanSettingData, error) {
+	hc, err := GetHostComputerSystemWith(session)
+	if err != nil {
+		return nil, err
+	}
+	defer hc.Close()
+	inst, err := hc.GetDefaultPortSettingData("Ethernet Switch Port VLAN Settings", switch_extension.Msvm_EthernetSwitchPortVlanSettingData)
+	if err != nil {
+		return nil, err
+	}
+	return switch_extension.NewEthernetSwitchPortVlanSettingData(inst)
+}
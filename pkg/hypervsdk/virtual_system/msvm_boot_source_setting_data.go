@@ -0,0 +1,64 @@
+package virtual_system
+
+import (
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+const (
+	Msvm_BootSourceSettingData = "Msvm_BootSourceSettingData"
+)
+
+// BootOrder values of generation 1 systems
+const (
+	BootOrder_Floppy    uint16 = 0
+	BootOrder_CDROM     uint16 = 1
+	BootOrder_HardDrive uint16 = 2
+	BootOrder_PXEBoot   uint16 = 3
+)
+
+// BootSourceType values of BootSourceSettingData
+const (
+	BootSourceType_Unknown uint16 = 0
+	BootSourceType_Drive   uint16 = 1
+	BootSourceType_Network uint16 = 2
+	BootSourceType_File    uint16 = 3
+)
+
+// BootSourceSettingData is a boot entry of the UEFI firmware of a generation 2 system, ordered by the
+// BootSourceOrder of its VirtualSystemSettingData.
+//
+// https://learn.microsoft.com/en-us/windows/win32/hyperv_v2/msvm-bootsourcesettingdata
+type BootSourceSettingData struct {
+	S__PATH string `json:"-"`
+
+	InstanceID            string `json:"instance_id"`
+	Caption               string `json:"caption"`
+	Description           string `json:"description"`
+	ElementName           string `json:"element_name"`
+	BootSourceType        uint16 `json:"boot_source_type"`
+	OtherLocation         string `json:"other_location"`
+	FirmwareDevicePath    string `json:"firmware_device_path"`
+	BootSourceDescription string `json:"boot_source_description"`
+
+	*wmiext.Instance `json:"-"`
+}
+
+func (bssd *BootSourceSettingData) Path() string {
+	return bssd.S__PATH
+}
+
+// GetBootSourceSettingData returns the boot sources of the virtual system in their boot order.
+func (vssd *VirtualSystemSettingData) GetBootSourceSettingData() ([]*BootSourceSettingData, error) {
+	sources := make([]*BootSourceSettingData, 0, len(vssd.BootSourceOrder))
+	for _, path := range vssd.BootSourceOrder {
+		source := &BootSourceSettingData{}
+		if err := vssd.GetService().GetObjectAsObject(path, source); err != nil {
+			for _, s := range sources {
+				s.Close()
+			}
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
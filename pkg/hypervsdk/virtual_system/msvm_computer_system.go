@@ -1,8 +1,10 @@
 package virtual_system
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
+	hverrors "github.com/rokukoo/hyperv/pkg/hypervsdk/errors"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/memory"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/network_adapter"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking"
@@ -151,12 +153,16 @@ func (vm *ComputerSystem) GetProcessorSettingData() (*processor.ProcessorSetting
 	if err != nil {
 		return nil, err
 	}
+	defer setting.Close()
 	var processorSettingData = processor.ProcessorSettingData{
 		//service: vm.service,
 	}
 	return &processorSettingData, vm.GetService().FindFirstRelatedObject(setting.Path(), processor.Msvm_ProcessorSettingData, &processorSettingData)
 }
 
+// MustGetProcessorSettingData is GetProcessorSettingData panicking on error.
+//
+// Deprecated: use GetProcessorSettingData, which returns the error.
 func (vm *ComputerSystem) MustGetProcessorSettingData() *processor.ProcessorSettingData {
 	setting, err := vm.GetProcessorSettingData()
 	if err != nil {
@@ -171,12 +177,16 @@ func (vm *ComputerSystem) GetMemorySettingData() (*memory.MemorySettingsData, er
 	if err != nil {
 		return nil, err
 	}
+	defer setting.Close()
 	var memorySettingsData = memory.MemorySettingsData{
 		//service: vm.service,
 	}
 	return &memorySettingsData, vm.GetService().FindFirstRelatedObject(setting.Path(), memory.Msvm_MemorySettingData, &memorySettingsData)
 }
 
+// MustGetMemorySettingData is GetMemorySettingData panicking on error.
+//
+// Deprecated: use GetMemorySettingData, which returns the error.
 func (vm *ComputerSystem) MustGetMemorySettingData() *memory.MemorySettingsData {
 	setting, err := vm.GetMemorySettingData()
 	if err != nil {
@@ -195,15 +205,20 @@ func (vm *ComputerSystem) GetVirtualSystemSettingData() (*VirtualSystemSettingDa
 		return nil, err
 	}
 
+	// The instances other than the returned one are not used by anyone
 	var virtualSystemType string
-	for _, instance := range instances {
+	for i, instance := range instances {
 		if virtualSystemType, err = instance.GetAsString("VirtualSystemType"); err != nil {
+			wmiext.CloseInstances(instances[i:])
 			return nil, err
 		}
 		if virtualSystemType == VirtualSystemType_Snapshot {
+			instance.Close()
 			continue
 		}
+		wmiext.CloseInstances(instances[i+1:])
 		if err = instance.GetAll(&virtualSystemSettingData); err != nil {
+			instance.Close()
 			return nil, err
 		}
 		return &virtualSystemSettingData, nil
@@ -211,6 +226,9 @@ func (vm *ComputerSystem) GetVirtualSystemSettingData() (*VirtualSystemSettingDa
 	return nil, errors.Wrapf(wmiext.NotFound, "VirtualSystemSettingData not found for computerSystem [%s]", vm.ElementName)
 }
 
+// MustGetVirtualSystemSettingData is GetVirtualSystemSettingData panicking on error.
+//
+// Deprecated: use GetVirtualSystemSettingData, which returns the error.
 func (vm *ComputerSystem) MustGetVirtualSystemSettingData() *VirtualSystemSettingData {
 	setting, err := vm.GetVirtualSystemSettingData()
 	if err != nil {
@@ -274,7 +292,7 @@ func (vm *ComputerSystem) RequestStateChange(requestedState ComputerSystemState)
 		return errors.Wrapf(err, "Failed to request state change to %v", requestedState)
 	}
 
-	return utils.WaitResult(retValue, vm.GetService(), job, "Failed to request state change", nil)
+	return utils.WaitResult(vm.Instance, "RequestStateChange", retValue, job)
 }
 
 // ChangeState changes the state of the Virtual Machine
@@ -294,45 +312,79 @@ func (vm *ComputerSystem) ChangeState(state ComputerSystemState) (err error) {
 	return
 }
 
-// WaitForState waits for the Virtual Machine to reach the desired state
+// WaitForState waits for the Virtual Machine to reach the desired state. The state changes are
+// received from a WMI event subscription, falling back to polling when it cannot be established.
 func (vm *ComputerSystem) WaitForState(state ComputerSystemState, timeoutSeconds int32) (err error) {
-	var (
-		curState           ComputerSystemState
-		vmState            ComputerSystemState
-		status             string
-		statusDescriptions []string
-	)
-	start := time.Now()
-	// Run the loop, only if the job is actually running
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	query := wmiext.InstanceEvents(wmiext.InstanceModificationEvent, Msvm_ComputerSystem).
+		Within(time.Second).
+		Where(wmiext.Equal("TargetInstance.Name", vm.Name))
+	subscription, err := vm.GetService().Subscribe(ctx, query.String())
+	if err != nil {
+		return vm.pollForState(ctx, state)
+	}
+	defer subscription.Close()
+
+	// The state may have been reached before the subscription started
+	var curState ComputerSystemState
+	if curState, err = vm.GetState(); err != nil {
+		return
+	} else if curState == state {
+		return nil
+	}
+
+	for event := range subscription.Events() {
+		enabledState, err := event.Target.GetAsUint("EnabledState")
+		event.Close()
+		if err == nil && ComputerSystemState(enabledState) == state {
+			return vm.Refresh()
+		}
+	}
+	if err = subscription.Err(); err != nil {
+		return
+	}
+	return vm.stateTimedOut()
+}
+
+// pollForState is WaitForState polling the state of the Virtual Machine until ctx is done.
+func (vm *ComputerSystem) pollForState(ctx context.Context, state ComputerSystemState) (err error) {
+	var curState ComputerSystemState
 	for {
 		if curState, err = vm.GetState(); err != nil {
 			return
 		} else if curState == state {
-			// Break for any valid state
-			// TODO: WaitForSomeState
 			return nil
 		}
 
-		time.Sleep(100 * time.Millisecond)
-
-		// If we have waited enough time, break
-		if time.Since(start) > (time.Duration(timeoutSeconds) * time.Second) {
-			if vmState, err = vm.GetState(); err != nil {
-				vmState = Unknown
-			}
-			if status, err = vm.GetStatus(); err != nil {
-				status = fmt.Sprintf("Unknown (error retreiving the status [%+v])", err)
-			}
-
-			if statusDescriptions, err = vm.GetStatusDescriptions(); err != nil {
-				statusDescriptions = []string{fmt.Sprintf("Unknown (error retreiving the status descriptions [%+v])", err)}
-			}
-			err = errors.Wrapf(wmiext.TimedOut, "WaitForState timeout. Current state: [%v], status: [%v], status descriptions: [%v]", vmState, status, statusDescriptions)
-			return
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return vm.stateTimedOut()
 		}
 	}
 }
 
+func (vm *ComputerSystem) stateTimedOut() error {
+	var (
+		vmState            ComputerSystemState
+		status             string
+		statusDescriptions []string
+		err                error
+	)
+	if vmState, err = vm.GetState(); err != nil {
+		vmState = Unknown
+	}
+	if status, err = vm.GetStatus(); err != nil {
+		status = fmt.Sprintf("Unknown (error retreiving the status [%+v])", err)
+	}
+	if statusDescriptions, err = vm.GetStatusDescriptions(); err != nil {
+		statusDescriptions = []string{fmt.Sprintf("Unknown (error retreiving the status descriptions [%+v])", err)}
+	}
+	return errors.Wrapf(wmiext.TimedOut, "WaitForState timeout. Current state: [%v], status: [%v], status descriptions: [%v]", vmState, status, statusDescriptions)
+}
+
 func (vm *ComputerSystem) RequireState(state ...ComputerSystemState) (ok bool, err error) {
 	var curState ComputerSystemState
 	if curState, err = vm.GetState(); err != nil {
@@ -362,9 +414,10 @@ func (vm *ComputerSystem) Stop(force bool) (err error) {
 		}
 	} else {
 		if err = vm.ChangeState(Stopping); err != nil {
-			// the device is not usable
-			if errors.Unwrap(err).(*wmiext.JobError).ErrorCode == 32768 {
-				return vm.Stop(force)
+			// Error code 32768: the guest cannot shut down gracefully (the shutdown device is not usable),
+			// escalate once to turning the virtual machine off
+			if hverrors.HasCode(err, hverrors.ReturnMethodFailed) {
+				return vm.Stop(true)
 			}
 			return
 		}
@@ -458,6 +511,19 @@ const (
 	HyperVGeneration_V2 = "Microsoft:Hyper-V:SubType:2"
 )
 
+// SecureBootTemplateId values of generation 2 systems
+const (
+	SecureBootTemplate_MicrosoftWindows                  = "1734c6e8-3154-4dda-ba5f-a874cc483422"
+	SecureBootTemplate_MicrosoftUEFICertificateAuthority = "272e7447-90a4-4563-a4b9-8e4ab00526ce"
+	SecureBootTemplate_OpenSourceShieldedVM              = "4292ae2b-ee2c-42b5-a969-dd8f8689f6f3"
+)
+
+// NetworkBootPreferredProtocol values of generation 2 systems
+const (
+	NetworkBootPreferredProtocol_IPv4 uint16 = 4096
+	NetworkBootPreferredProtocol_IPv6 uint16 = 4097
+)
+
 func (vm *ComputerSystem) GetVirtualMachineGeneration() (HyperVGeneration, error) {
 	systemSetting, err := vm.GetVirtualSystemSettingData()
 	if err != nil {
@@ -529,7 +595,7 @@ func (vm *ComputerSystem) NewSyntheticDiskDrive(
 		err = errors.Wrapf(wmiext.NotFound, "VirtualMachine [%s] doesnt have [%s]", vm.ElementName, controllerType)
 		return
 	}
-	if int(controllerNumber) > len(controllers) {
+	if int(controllerNumber) >= len(controllers) {
 		err = errors.Wrapf(wmiext.NotFound,
 			"VirtualMachine [%s] doesnt have [%s] with bus location [%d]", vm.ElementName, controllerType, controllerNumber)
 		return
@@ -680,13 +746,13 @@ func (vm *ComputerSystem) NewSyntheticNetworkAdapter(name string) (adapter *netw
 
 // ListComputerSystems returns all the computer systems in the Hyper-V host
 func (vsms *VirtualSystemManagementService) ListComputerSystems() ([]*ComputerSystem, error) {
-	query := fmt.Sprintf("SELECT * FROM %s", Msvm_ComputerSystem)
+	query := wmiext.Select(Msvm_ComputerSystem).String()
 	return vsms.fetchComputerSystems(query)
 }
 
 // FindComputerSystemsByName returns the computer systems with the given name
 func (vsms *VirtualSystemManagementService) FindComputerSystemsByName(name string) ([]*ComputerSystem, error) {
-	query := fmt.Sprintf("SELECT * FROM %s WHERE ElementName='%s'", Msvm_ComputerSystem, name)
+	query := wmiext.Select(Msvm_ComputerSystem).Where(wmiext.Equal("ElementName", name)).String()
 	return vsms.fetchComputerSystems(query)
 }
 
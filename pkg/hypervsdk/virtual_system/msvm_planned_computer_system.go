@@ -0,0 +1,186 @@
+package virtual_system
+
+import (
+	"context"
+
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking"
+	utils "github.com/rokukoo/hyperv/pkg/hypervsdk/utils"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+const (
+	Msvm_PlannedComputerSystem = "Msvm_PlannedComputerSystem"
+
+	VirtualSystemType_Planned         = "Microsoft:Hyper-V:System:Planned"
+	VirtualSystemType_PlannedSnapshot = "Microsoft:Hyper-V:Snapshot:Planned"
+)
+
+// PlannedComputerSystem is a system imported but not yet realized, whose settings can still be
+// changed to fit the host.
+type PlannedComputerSystem struct {
+	S__PATH string `json:"-"`
+
+	InstanceID   string
+	Caption      string
+	Description  string
+	ElementName  string
+	Name         string
+	EnabledState uint16
+
+	*wmiext.Instance `json:"-"`
+}
+
+func (p *PlannedComputerSystem) Path() string {
+	return p.S__PATH
+}
+
+// GetVirtualSystemSettingData returns the settings of the planned system.
+func (p *PlannedComputerSystem) GetVirtualSystemSettingData() (*VirtualSystemSettingData, error) {
+	return wmiext.NavigateFirst[VirtualSystemSettingData](p.GetService(),
+		Msvm_PlannedComputerSystem+"/Msvm_SettingsDefineState/"+Msvm_VirtualSystemSettingData, p.Path())
+}
+
+// GetAllVirtualSystemSettingData returns the settings of the planned system followed by those of
+// its imported snapshots.
+func (p *PlannedComputerSystem) GetAllVirtualSystemSettingData() ([]*VirtualSystemSettingData, error) {
+	settings, err := wmiext.Query[VirtualSystemSettingData](p.GetService(), wmiext.Select(Msvm_VirtualSystemSettingData).Where(wmiext.And(
+		wmiext.Equal("VirtualSystemIdentifier", p.Name),
+		wmiext.Or(
+			wmiext.Equal("VirtualSystemType", VirtualSystemType_Planned),
+			wmiext.Equal("VirtualSystemType", VirtualSystemType_PlannedSnapshot),
+		),
+	)))
+	if err != nil {
+		return nil, err
+	}
+	for i, setting := range settings {
+		if setting.VirtualSystemType == VirtualSystemType_Planned {
+			settings[0], settings[i] = settings[i], settings[0]
+			break
+		}
+	}
+	return settings, nil
+}
+
+// GetEthernetPortAllocationSettingData returns the connections of the network adapters of the
+// virtual system.
+func (vssd *VirtualSystemSettingData) GetEthernetPortAllocationSettingData() ([]*networking.EthernetPortAllocationSettingData, error) {
+	return wmiext.Navigate[networking.EthernetPortAllocationSettingData](vssd.GetService(),
+		Msvm_VirtualSystemSettingData+"/"+networking.Msvm_EthernetPortAllocationSettingData, vssd.Path())
+}
+
+// ImportSystemDefinition starts importing the system defined by systemDefinitionFile, along with the
+// snapshots found in snapshotFolder when not empty. The returned job yields the planned system once
+// completed, which is realized by RealizePlannedSystem.
+//
+// Microsoft Docs: https://learn.microsoft.com/en-us/windows/win32/hyperv_v2/importsystemdefinition-msvm-virtualsystemmanagementservice
+func (vsms *VirtualSystemManagementService) ImportSystemDefinition(
+	systemDefinitionFile string,
+	snapshotFolder string,
+	generateNewSystemIdentifier bool,
+	opts ...wmiext.JobOption,
+) (*wmiext.ResultJob[*PlannedComputerSystem], error) {
+	var (
+		started        *wmiext.Job
+		importedSystem string
+	)
+
+	if err := vsms.Retry.Do(context.Background(), "ImportSystemDefinition", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err = vsms.Method("ImportSystemDefinition").
+			In("SystemDefinitionFile", systemDefinitionFile).
+			In("SnapshotFolder", snapshotFolder).
+			In("GenerateNewSystemIdentifier", generateNewSystemIdentifier).
+			Execute().
+			Out("Job", &job).
+			Out("ImportedSystem", &importedSystem).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
+
+		started, err = utils.StartJob(vsms.Instance, "ImportSystemDefinition", returnValue, job, opts...)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return wmiext.NewResultJob(started, func() (*PlannedComputerSystem, error) {
+		planned := &PlannedComputerSystem{}
+		return planned, vsms.Session.GetObjectAsObject(importedSystem, planned)
+	}), nil
+}
+
+// ValidatePlannedSystem starts checking that the planned system can be realized on the host. The
+// returned job fails with the first incompatibility found.
+func (vsms *VirtualSystemManagementService) ValidatePlannedSystem(planned *PlannedComputerSystem, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	var started *wmiext.Job
+
+	if err := vsms.Retry.Do(context.Background(), "ValidatePlannedSystem", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err = vsms.Method("ValidatePlannedSystem").
+			In("PlannedSystem", planned.Path()).
+			Execute().
+			Out("Job", &job).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
+
+		started, err = utils.StartJob(vsms.Instance, "ValidatePlannedSystem", returnValue, job, opts...)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return started, nil
+}
+
+// RealizePlannedSystem starts turning the planned system into a virtual machine. The returned job
+// yields the computer system once completed.
+func (vsms *VirtualSystemManagementService) RealizePlannedSystem(
+	planned *PlannedComputerSystem,
+	opts ...wmiext.JobOption,
+) (*wmiext.ResultJob[*ComputerSystem], error) {
+	var (
+		started         *wmiext.Job
+		resultingSystem string
+	)
+
+	if err := vsms.Retry.Do(context.Background(), "RealizePlannedSystem", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err = vsms.Method("RealizePlannedSystem").
+			In("PlannedSystem", planned.Path()).
+			Execute().
+			Out("Job", &job).
+			Out("ResultingSystem", &resultingSystem).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
+
+		started, err = utils.StartJob(vsms.Instance, "RealizePlannedSystem", returnValue, job, opts...)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return wmiext.NewResultJob(started, func() (*ComputerSystem, error) {
+		system := &ComputerSystem{}
+		return system, vsms.Session.GetObjectAsObject(resultingSystem, system)
+	}), nil
+}
+
+// DestroyPlannedSystem discards the planned system, leaving its files in place.
+func (vsms *VirtualSystemManagementService) DestroyPlannedSystem(planned *PlannedComputerSystem) error {
+	return vsms.destroySystem(planned.Path())
+}
@@ -0,0 +1,108 @@
+package virtual_system
+
+import (
+	"context"
+
+	utils "github.com/rokukoo/hyperv/pkg/hypervsdk/utils"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+const (
+	Msvm_VirtualSystemExportSettingData = "Msvm_VirtualSystemExportSettingData"
+)
+
+// SnapshotExport is the CopySnapshotConfiguration of the export settings, the snapshots exported
+// along with the system.
+type SnapshotExport uint8
+
+const (
+	SnapshotExport_All  SnapshotExport = 0
+	SnapshotExport_None SnapshotExport = 1
+	// SnapshotExport_One exports the system as of its SnapshotVirtualSystem.
+	SnapshotExport_One SnapshotExport = 2
+	// SnapshotExport_OneUseVmId is SnapshotExport_One keeping the identifier of the system.
+	SnapshotExport_OneUseVmId SnapshotExport = 3
+)
+
+type VirtualSystemExportSettingData struct {
+	CopySnapshotConfiguration  SnapshotExport
+	CopyVmRuntimeInformation   bool
+	CopyVmStorage              bool
+	CreateVmExportSubdirectory bool
+	// SnapshotVirtualSystem is the path of the snapshot exported by SnapshotExport_One.
+	SnapshotVirtualSystem string
+}
+
+// CreateExportSettings returns the embedded instance of the export settings.
+func (vsms *VirtualSystemManagementService) CreateExportSettings(settings *VirtualSystemExportSettingData) (string, error) {
+	exportSettingsInst, err := vsms.Session.SpawnInstance(Msvm_VirtualSystemExportSettingData)
+	if err != nil {
+		return "", err
+	}
+	defer exportSettingsInst.Close()
+
+	for _, property := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"CopySnapshotConfiguration", uint8(settings.CopySnapshotConfiguration)},
+		{"CopyVmRuntimeInformation", settings.CopyVmRuntimeInformation},
+		{"CopyVmStorage", settings.CopyVmStorage},
+		{"CreateVmExportSubdirectory", settings.CreateVmExportSubdirectory},
+	} {
+		if err = exportSettingsInst.Put(property.name, property.value); err != nil {
+			return "", err
+		}
+	}
+
+	if settings.SnapshotVirtualSystem != "" {
+		if err = exportSettingsInst.Put("SnapshotVirtualSystem", settings.SnapshotVirtualSystem); err != nil {
+			return "", err
+		}
+	}
+
+	return exportSettingsInst.GetCimText(), nil
+}
+
+// ExportSystemDefinition starts exporting the definition of the system, along with its storage and
+// snapshots as told by settings, to exportDirectory. The returned job completes when the files are
+// written.
+//
+// Microsoft Docs: https://learn.microsoft.com/en-us/windows/win32/hyperv_v2/exportsystemdefinition-msvm-virtualsystemmanagementservice
+func (vsms *VirtualSystemManagementService) ExportSystemDefinition(
+	system *ComputerSystem,
+	exportDirectory string,
+	settings *VirtualSystemExportSettingData,
+	opts ...wmiext.JobOption,
+) (*wmiext.Job, error) {
+	var started *wmiext.Job
+
+	exportSettingsObj, err := vsms.CreateExportSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = vsms.Retry.Do(context.Background(), "ExportSystemDefinition", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err = vsms.Method("ExportSystemDefinition").
+			In("ComputerSystem", system.Path()).
+			In("ExportDirectory", exportDirectory).
+			In("ExportSettingData", exportSettingsObj).
+			Execute().
+			Out("Job", &job).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
+
+		started, err = utils.StartJob(vsms.Instance, "ExportSystemDefinition", returnValue, job, opts...)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return started, nil
+}
@@ -1,18 +1,20 @@
 package virtual_system
 
 import (
+	"context"
+
 	"github.com/pkg/errors"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/memory"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/network_adapter"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/processor"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/resource"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/retry"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage/disk"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/storage/drive"
 	utils "github.com/rokukoo/hyperv/pkg/hypervsdk/utils"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
 	"strings"
-	"time"
 )
 
 const (
@@ -22,25 +24,47 @@ const (
 type VirtualSystemManagementService struct {
 	Session *wmiext.Service
 	*wmiext.Instance
+	// Retry is the retry policy of each method, retry.DefaultPolicy for those not configured.
+	Retry *retry.Policies
 }
 
 func LocalVirtualSystemManagementService() (*VirtualSystemManagementService, error) {
 	var (
 		session *wmiext.Service
-		svc     *wmiext.Instance
+		vsms    *VirtualSystemManagementService
 		err     error
 	)
 	// Get the WMI service
 	if session, err = utils.NewLocalHyperVService(); err != nil {
 		return nil, err
 	}
+	if vsms, err = NewVirtualSystemManagementService(session); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return vsms, nil
+}
+
+// NewVirtualSystemManagementService returns the management service of the session, which remains
+// owned by the caller.
+func NewVirtualSystemManagementService(session *wmiext.Service) (*VirtualSystemManagementService, error) {
 	// Get the singleton instance
-	if svc, err = session.GetSingletonInstance(Msvm_VirtualSystemManagementService); err != nil {
+	svc, err := session.GetSingletonInstance(Msvm_VirtualSystemManagementService)
+	if err != nil {
 		return nil, err
 	}
-	return &VirtualSystemManagementService{session, svc}, nil
+	return &VirtualSystemManagementService{Session: session, Instance: svc, Retry: retry.NewPolicies(retry.DefaultPolicy())}, nil
+}
+
+// HostName returns the name of the host, which is also the Name of its Msvm_ComputerSystem. Unlike
+// Caption and Description, it tells the host from the virtual machines regardless of the locale.
+func (vsms *VirtualSystemManagementService) HostName() (string, error) {
+	return vsms.GetAsString("SystemName")
 }
 
+// MustLocalVirtualSystemManagementService is LocalVirtualSystemManagementService panicking on error.
+//
+// Deprecated: use LocalVirtualSystemManagementService, which returns the error.
 func MustLocalVirtualSystemManagementService() *VirtualSystemManagementService {
 	vsms, err := LocalVirtualSystemManagementService()
 	if err != nil {
@@ -49,21 +73,21 @@ func MustLocalVirtualSystemManagementService() *VirtualSystemManagementService {
 	return vsms
 }
 
+// DefineSystem starts creating a virtual machine from its system, processor and memory settings. The
+// returned job yields the new computer system once completed.
 func (vsms *VirtualSystemManagementService) DefineSystem(
 	systemSettingsData *VirtualSystemSettingData,
 	processorSetting *processor.ProcessorSettingData,
 	memorySetting *memory.MemorySettingsData,
-) (*ComputerSystem, error) {
+	opts ...wmiext.JobOption,
+) (*wmiext.ResultJob[*ComputerSystem], error) {
 	var (
-		system = ComputerSystem{}
-
 		err                   error
 		systemSettingsDataObj string
 		memorySettingObj      string
 		processorSettingObj   string
 
-		job             *wmiext.Instance
-		returnValue     int32
+		started         *wmiext.Job
 		resultingSystem string
 	)
 
@@ -71,31 +95,41 @@ func (vsms *VirtualSystemManagementService) DefineSystem(
 		return nil, err
 	}
 
-	if memorySettingObj, err = memory.CreateMemorySettings(memorySetting); err != nil {
+	if memorySettingObj, err = memory.CreateMemorySettingsWith(vsms.Session, memorySetting); err != nil {
 		return nil, err
 	}
 
-	if processorSettingObj, err = processor.CreateProcessorSettings(processorSetting); err != nil {
+	if processorSettingObj, err = processor.CreateProcessorSettingsWith(vsms.Session, processorSetting); err != nil {
 		return nil, err
 	}
 
-	if err = vsms.Method("DefineSystem").
-		In("SystemSettings", systemSettingsDataObj).
-		In("ResourceSettings", []string{memorySettingObj, processorSettingObj}).
-		In("ReferenceConfiguration", nil).
-		Execute().
-		Out("Job", &job).
-		Out("ResultingSystem", &resultingSystem).
-		Out("ReturnValue", &returnValue).
-		End(); err != nil {
-		return nil, err
-	}
+	if err = vsms.Retry.Do(context.Background(), "DefineSystem", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
 
-	if err = utils.WaitResult(returnValue, vsms.Session, job, "Failed to define system", nil); err != nil {
+		if err = vsms.Method("DefineSystem").
+			In("SystemSettings", systemSettingsDataObj).
+			In("ResourceSettings", []string{memorySettingObj, processorSettingObj}).
+			In("ReferenceConfiguration", nil).
+			Execute().
+			Out("Job", &job).
+			Out("ResultingSystem", &resultingSystem).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
+
+		started, err = utils.StartJob(vsms.Instance, "DefineSystem", returnValue, job, opts...)
+		return err
+	}); err != nil {
 		return nil, err
 	}
-
-	return &system, vsms.Session.GetObjectAsObject(resultingSystem, &system)
+	return wmiext.NewResultJob(started, func() (*ComputerSystem, error) {
+		system := &ComputerSystem{}
+		return system, vsms.Session.GetObjectAsObject(resultingSystem, system)
+	}), nil
 }
 
 func (vsms *VirtualSystemManagementService) ModifyProcessorSettings(
@@ -115,16 +149,18 @@ func (vsms *VirtualSystemManagementService) ModifyMemorySettings(
 func (vsms *VirtualSystemManagementService) DestroySystem(
 	computerSystem *ComputerSystem,
 ) error {
-	var (
-		err error
+	return vsms.destroySystem(computerSystem.Path())
+}
 
-		job         *wmiext.Instance
-		returnValue int32
-	)
+func (vsms *VirtualSystemManagementService) destroySystem(path string) error {
+	return vsms.Retry.Do(context.Background(), "DestroySystem", func() error {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
 
-	for {
-		if err = vsms.Method("DestroySystem").
-			In("AffectedSystem", computerSystem.Path()).
+		if err := vsms.Method("DestroySystem").
+			In("AffectedSystem", path).
 			Execute().
 			Out("Job", &job).
 			Out("ReturnValue", &returnValue).
@@ -132,18 +168,8 @@ func (vsms *VirtualSystemManagementService) DestroySystem(
 			return err
 		}
 
-		if err = utils.WaitResult(returnValue, vsms.Session, job, "Failed to destroy system", nil); err != nil {
-			return err
-		}
-
-		if returnValue == 32775 {
-			//log.Printf("[WMI] Method [%s] failed with [%d]. Retrying ...", "DestroySystem", returnValue)
-			time.Sleep(100 * time.Millisecond)
-			continue
-		}
-
-		return nil
-	}
+		return utils.WaitResult(vsms.Instance, "DestroySystem", returnValue, job)
+	})
 }
 
 // AddResourceSettings - 将资源添加到虚拟机配置。
@@ -154,21 +180,23 @@ func (vsms *VirtualSystemManagementService) DestroySystem(
 func (vsms *VirtualSystemManagementService) AddResourceSettings(
 	affectedConfiguration *VirtualSystemSettingData,
 	resourceSettings []string,
+	opts ...wmiext.JobOption,
 ) (
-	[]*wmiext.Instance,
+	*wmiext.ResultJob[[]*wmiext.Instance],
 	error,
 ) {
 	var (
-		err error
-
-		job                       *wmiext.Instance
-		returnValue               int32
+		started                   *wmiext.Job
 		resultingResourceSettings []string
-
-		resultInstances []*wmiext.Instance
 	)
 
-	for {
+	// Only the invocation is retried, the failure of the started job is reported by its Wait.
+	if err := vsms.Retry.Do(context.Background(), "AddResourceSettings", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
 		if err = vsms.Method("AddResourceSettings").
 			In("AffectedConfiguration", affectedConfiguration.Path()).
 			In("ResourceSettings", resourceSettings).
@@ -177,29 +205,26 @@ func (vsms *VirtualSystemManagementService) AddResourceSettings(
 			Out("ResultingResourceSettings", &resultingResourceSettings).
 			Out("ReturnValue", &returnValue).
 			End(); err != nil {
-			return resultInstances, err
-		}
-
-		if err = utils.WaitResult(returnValue, vsms.Session, job, "Failed to add allocation settings", nil); err != nil {
-			return resultInstances, err
+			return err
 		}
 
-		if returnValue == 32775 {
-			//log.Printf("[WMI] Method [%s] failed with [%d]. Retrying ...", "DestroySystem", returnValue)
-			time.Sleep(100 * time.Millisecond)
-			continue
-		}
+		started, err = utils.StartJob(vsms.Instance, "AddResourceSettings", returnValue, job, opts...)
+		return err
+	}); err != nil {
+		return nil, err
+	}
 
+	return wmiext.NewResultJob(started, func() ([]*wmiext.Instance, error) {
+		var resultInstances []*wmiext.Instance
 		for _, resourceSetting := range resultingResourceSettings {
-			var instance *wmiext.Instance
-			if instance, err = vsms.Session.GetObject(resourceSetting); err != nil {
+			instance, err := vsms.Session.GetObject(resourceSetting)
+			if err != nil {
 				return resultInstances, err
 			}
 			resultInstances = append(resultInstances, instance)
 		}
-
 		return resultInstances, nil
-	}
+	}), nil
 }
 
 func (vsms *VirtualSystemManagementService) ModifyResourceSettings(
@@ -208,43 +233,68 @@ func (vsms *VirtualSystemManagementService) ModifyResourceSettings(
 	resultInstances []*wmiext.Instance,
 	err error,
 ) {
-	var (
-		job                       *wmiext.Instance
-		returnValue               int32
-		resultingResourceSettings []string
-	)
+	var resultingResourceSettings []string
+
+	if err = vsms.Retry.Do(context.Background(), "ModifyResourceSettings", func() error {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
 
-	for {
-		if err = vsms.Method("ModifyResourceSettings").
+		if err := vsms.Method("ModifyResourceSettings").
 			In("ResourceSettings", resourceSettings).
 			Execute().
 			Out("Job", &job).
 			Out("ResultingResourceSettings", &resultingResourceSettings).
 			Out("ReturnValue", &returnValue).
 			End(); err != nil {
-			return resultInstances, err
+			return err
 		}
 
-		if err = utils.WaitResult(returnValue, vsms.Session, job, "Failed to modify allocation settings", nil); err != nil {
+		return utils.WaitResult(vsms.Instance, "ModifyResourceSettings", returnValue, job)
+	}); err != nil {
+		return
+	}
+
+	for _, resourceSetting := range resultingResourceSettings {
+		var instance *wmiext.Instance
+		if instance, err = vsms.Session.GetObject(resourceSetting); err != nil {
 			return resultInstances, err
 		}
+		resultInstances = append(resultInstances, instance)
+	}
 
-		if returnValue == 32775 {
-			//log.Printf("[WMI] Method [%s] failed with [%d]. Retrying ...", "DestroySystem", returnValue)
-			time.Sleep(100 * time.Millisecond)
-			continue
-		}
+	return resultInstances, nil
+}
 
-		for _, resourceSetting := range resultingResourceSettings {
-			var instance *wmiext.Instance
-			if instance, err = vsms.Session.GetObject(resourceSetting); err != nil {
-				return resultInstances, err
-			}
-			resultInstances = append(resultInstances, instance)
+// ModifySystemSettings starts applying settings, the embedded instance of the modified settings of
+// a system or of one of its snapshots. The returned job completes when the settings are applied.
+//
+// Microsoft Docs: https://learn.microsoft.com/en-us/windows/win32/hyperv_v2/modifysystemsettings-msvm-virtualsystemmanagementservice
+func (vsms *VirtualSystemManagementService) ModifySystemSettings(settings string, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	var started *wmiext.Job
+
+	if err := vsms.Retry.Do(context.Background(), "ModifySystemSettings", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err = vsms.Method("ModifySystemSettings").
+			In("SystemSettings", settings).
+			Execute().
+			Out("Job", &job).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
 		}
 
-		return resultInstances, nil
+		started, err = utils.StartJob(vsms.Instance, "ModifySystemSettings", returnValue, job, opts...)
+		return err
+	}); err != nil {
+		return nil, err
 	}
+	return started, nil
 }
 
 func (vsms *VirtualSystemManagementService) AddSCSIController(
@@ -267,7 +317,7 @@ func (vsms *VirtualSystemManagementService) AddSCSIController(
 	}
 
 	// apply the settings
-	if result, err = vsms.AddResourceSettings(systemSetting, []string{scsiController.GetCimText()}); err != nil {
+	if result, err = wmiext.Await(vsms.AddResourceSettings(systemSetting, []string{scsiController.GetCimText()})); err != nil {
 		return
 	}
 
@@ -285,33 +335,23 @@ func (vsms *VirtualSystemManagementService) RemoveResourceSettings(
 ) (
 	err error,
 ) {
-	var (
-		job         *wmiext.Instance
-		returnValue int32
-	)
+	return vsms.Retry.Do(context.Background(), "RemoveResourceSettings", func() error {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
 
-	for {
-		if err = vsms.Method("RemoveResourceSettings").
+		if err := vsms.Method("RemoveResourceSettings").
 			In("ResourceSettings", resourceSettings).
 			Execute().
 			Out("Job", &job).
 			Out("ReturnValue", &returnValue).
 			End(); err != nil {
-			return
-		}
-
-		if err = utils.WaitResult(returnValue, vsms.Session, job, "Failed to remove allocation settings", nil); err != nil {
-			return
-		}
-
-		if returnValue == 32775 {
-			//log.Printf("[WMI] Method [%s] failed with [%d]. Retrying ...", "DestroySystem", returnValue)
-			time.Sleep(100 * time.Millisecond)
-			continue
+			return err
 		}
 
-		return nil
-	}
+		return utils.WaitResult(vsms.Instance, "RemoveResourceSettings", returnValue, job)
+	})
 }
 
 func (vsms *VirtualSystemManagementService) RemoveSyntheticDiskDrive(diskDrive *drive.SyntheticDiskDrive) error {
@@ -337,7 +377,7 @@ func (vsms *VirtualSystemManagementService) AddSyntheticDiskDrive(
 		return
 	}
 	defer syntheticDiskDrive.Close()
-	resultInstances, err := vsms.AddResourceSettings(systemSettingData, []string{syntheticDiskDrive.GetCimText()})
+	resultInstances, err := wmiext.Await(vsms.AddResourceSettings(systemSettingData, []string{syntheticDiskDrive.GetCimText()}))
 	if err != nil {
 		return
 	}
@@ -418,7 +458,7 @@ func (vsms *VirtualSystemManagementService) AttachVirtualHardDisk(
 	defer systemSettingData.Close()
 
 	// apply the settings
-	resultInstances, err := vsms.AddResourceSettings(systemSettingData, []string{virtualHardDisk.GetCimText()})
+	resultInstances, err := wmiext.Await(vsms.AddResourceSettings(systemSettingData, []string{virtualHardDisk.GetCimText()}))
 	if err != nil {
 		return
 	}
@@ -469,15 +509,15 @@ func (vsms *VirtualSystemManagementService) AddFeatureSettings(
 	resultInstances []*wmiext.Instance,
 	err error,
 ) {
-	var (
-		resultingFeatureSettings []string
+	var resultingFeatureSettings []string
 
-		job         *wmiext.Instance
-		returnValue int32
-	)
+	if err = vsms.Retry.Do(context.Background(), "AddFeatureSettings", func() error {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
 
-	for {
-		if err = vsms.Method("AddFeatureSettings").
+		if err := vsms.Method("AddFeatureSettings").
 			In("AffectedConfiguration", affectedConfiguration).
 			In("FeatureSettings", featureSettings).
 			Execute().
@@ -485,30 +525,23 @@ func (vsms *VirtualSystemManagementService) AddFeatureSettings(
 			Out("ResultingFeatureSettings", &resultingFeatureSettings).
 			Out("ReturnValue", &returnValue).
 			End(); err != nil {
-			return
-		}
-
-		if err = utils.WaitResult(returnValue, vsms.Session, job, "Failed to modify allocation settings", nil); err != nil {
-			return
+			return err
 		}
 
-		if returnValue == 32775 {
-			//log.Printf("[WMI] Method [%s] failed with [%d]. Retrying ...", "DestroySystem", returnValue)
-			time.Sleep(100 * time.Millisecond)
-			continue
-		}
+		return utils.WaitResult(vsms.Instance, "AddFeatureSettings", returnValue, job)
+	}); err != nil {
+		return
+	}
 
-		for _, resourceSetting := range resultingFeatureSettings {
-			var instance *wmiext.Instance
-			if instance, err = vsms.Session.GetObject(resourceSetting); err != nil {
-				return resultInstances, err
-			}
-			resultInstances = append(resultInstances, instance)
+	for _, resourceSetting := range resultingFeatureSettings {
+		var instance *wmiext.Instance
+		if instance, err = vsms.Session.GetObject(resourceSetting); err != nil {
+			return resultInstances, err
 		}
-
-		return resultInstances, nil
-
+		resultInstances = append(resultInstances, instance)
 	}
+
+	return resultInstances, nil
 }
 
 // ModifyFeatureSettings - 修改虚拟机以太网连接的当前功能设置。
@@ -520,45 +553,38 @@ func (vsms *VirtualSystemManagementService) ModifyFeatureSettings(
 	resultInstances []*wmiext.Instance,
 	err error,
 ) {
-	var (
-		resultingFeatureSettings []string
+	var resultingFeatureSettings []string
 
-		job         *wmiext.Instance
-		returnValue int32
-	)
+	if err = vsms.Retry.Do(context.Background(), "ModifyFeatureSettings", func() error {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
 
-	for {
-		if err = vsms.Method("ModifyFeatureSettings").
+		if err := vsms.Method("ModifyFeatureSettings").
 			In("FeatureSettings", featureSettings).
 			Execute().
 			Out("Job", &job).
 			Out("ResultingFeatureSettings", &resultingFeatureSettings).
 			Out("ReturnValue", &returnValue).
 			End(); err != nil {
-			return
-		}
-
-		if err = utils.WaitResult(returnValue, vsms.Session, job, "Failed to modify allocation settings", nil); err != nil {
-			return
+			return err
 		}
 
-		if returnValue == 32775 {
-			//log.Printf("[WMI] Method [%s] failed with [%d]. Retrying ...", "DestroySystem", returnValue)
-			time.Sleep(100 * time.Millisecond)
-			continue
-		}
+		return utils.WaitResult(vsms.Instance, "ModifyFeatureSettings", returnValue, job)
+	}); err != nil {
+		return
+	}
 
-		for _, resourceSetting := range resultingFeatureSettings {
-			var inst *wmiext.Instance
-			if inst, err = vsms.Session.GetObject(resourceSetting); err != nil {
-				return resultInstances, err
-			}
-			resultInstances = append(resultInstances, inst)
+	for _, resourceSetting := range resultingFeatureSettings {
+		var inst *wmiext.Instance
+		if inst, err = vsms.Session.GetObject(resourceSetting); err != nil {
+			return resultInstances, err
 		}
-
-		return resultInstances, nil
-
+		resultInstances = append(resultInstances, inst)
 	}
+
+	return resultInstances, nil
 }
 
 // RemoveFeatureSettings - 删除虚拟机以太网连接的当前功能设置
@@ -569,33 +595,23 @@ func (vsms *VirtualSystemManagementService) RemoveFeatureSettings(
 ) (
 	err error,
 ) {
-	var (
-		job         *wmiext.Instance
-		returnValue int32
-	)
+	return vsms.Retry.Do(context.Background(), "RemoveFeatureSettings", func() error {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
 
-	for {
-		if err = vsms.Method("RemoveFeatureSettings").
+		if err := vsms.Method("RemoveFeatureSettings").
 			In("FeatureSettings", featureSettings).
 			Execute().
 			Out("Job", &job).
 			Out("ReturnValue", &returnValue).
 			End(); err != nil {
-			return
-		}
-
-		if err = utils.WaitResult(returnValue, vsms.Session, job, "Failed to modify allocation settings", nil); err != nil {
-			return
-		}
-
-		if returnValue == 32775 {
-			//log.Printf("[WMI] Method [%s] failed with [%d]. Retrying ...", "DestroySystem", returnValue)
-			time.Sleep(100 * time.Millisecond)
-			continue
+			return err
 		}
 
-		return nil
-	}
+		return utils.WaitResult(vsms.Instance, "RemoveFeatureSettings", returnValue, job)
+	})
 }
 
 func (vsms *VirtualSystemManagementService) DisConnectAdapterToVirtualSwitch(vnaName string) (err error) {
@@ -655,24 +671,22 @@ func (vsms *VirtualSystemManagementService) SetGuestNetworkAdapterConfiguration(
 ) (
 	err error,
 ) {
-	var (
-		job         *wmiext.Instance
-		returnValue int32
-	)
-
-	if err = vsms.Method("SetGuestNetworkAdapterConfiguration").
-		In("computerSystem", computerSystem.Path()).
-		In("NetworkConfiguration", []string{networkConfiguration.GetCimText()}).
-		Execute().
-		Out("Job", &job).
-		Out("ReturnValue", &returnValue).
-		End(); err != nil {
-		return
-	}
-
-	if err = utils.WaitResult(returnValue, vsms.Session, job, "Failed to modify allocation settings", nil); err != nil {
-		return
-	}
+	return vsms.Retry.Do(context.Background(), "SetGuestNetworkAdapterConfiguration", func() error {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err := vsms.Method("SetGuestNetworkAdapterConfiguration").
+			In("computerSystem", computerSystem.Path()).
+			In("NetworkConfiguration", []string{networkConfiguration.GetCimText()}).
+			Execute().
+			Out("Job", &job).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
 
-	return
+		return utils.WaitResult(vsms.Instance, "SetGuestNetworkAdapterConfiguration", returnValue, job)
+	})
 }
@@ -1,6 +1,8 @@
 package virtual_system
 
 import (
+	"fmt"
+
 	"github.com/pkg/errors"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/memory"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/network_adapter"
@@ -86,42 +88,15 @@ func (vssd *VirtualSystemSettingData) Path() string {
 	return vssd.S__PATH
 }
 
-func (vssd *VirtualSystemSettingData) GetStorageAllocationSettingData() (col []*allocation.StorageAllocationSettingData, err error) {
-	var (
-		resourceAllocationSettingData *allocation.StorageAllocationSettingData
-	)
-	resourceAllocationSettingDatas, err := vssd.GetAllRelated("Msvm_StorageAllocationSettingData")
-	if err != nil {
-		return nil, err
-	}
-
-	for _, resourceAllocationSettingDataInst := range resourceAllocationSettingDatas {
-		resourceAllocationSettingData = &allocation.StorageAllocationSettingData{}
-		if err = resourceAllocationSettingDataInst.GetAll(resourceAllocationSettingData); err != nil {
-			return
-		}
-		col = append(col, resourceAllocationSettingData)
-	}
-	return
+// GetStorageAllocationSettingData returns the storage allocation settings of the virtual system.
+func (vssd *VirtualSystemSettingData) GetStorageAllocationSettingData() ([]*allocation.StorageAllocationSettingData, error) {
+	return wmiext.Navigate[allocation.StorageAllocationSettingData](vssd.GetService(),
+		Msvm_VirtualSystemSettingData+"/"+allocation.Msvm_StorageAllocationSettingData, vssd.Path())
 }
 
-func (vssd *VirtualSystemSettingData) getResourceAllocationSettingData(rtype resource.ResourceAllocationSettingData_ResourceType) (col []*resource.ResourceAllocationSettingData, err error) {
-	var (
-		resourceAllocationSettingData *resource.ResourceAllocationSettingData
-	)
-	resourceType := uint16(rtype)
-	resourceAllocationSettingDatas, err := vssd.GetAllRelated("Msvm_ResourceAllocationSettingData")
-	for _, resourceAllocationSettingDataInst := range resourceAllocationSettingDatas {
-		resourceAllocationSettingData = &resource.ResourceAllocationSettingData{}
-		if err = resourceAllocationSettingDataInst.GetAll(resourceAllocationSettingData); err != nil {
-			return
-		}
-
-		if resourceAllocationSettingData.ResourceType == resourceType {
-			col = append(col, resourceAllocationSettingData)
-		}
-	}
-	return
+func (vssd *VirtualSystemSettingData) getResourceAllocationSettingData(rtype resource.ResourceAllocationSettingData_ResourceType) ([]*resource.ResourceAllocationSettingData, error) {
+	return wmiext.Navigate[resource.ResourceAllocationSettingData](vssd.GetService(),
+		fmt.Sprintf("%s/%s[ResourceType=%d]", Msvm_VirtualSystemSettingData, resource.Msvm_ResourceAllocationSettingData, rtype), vssd.Path())
 }
 
 // GetComputerSystem returns the ComputerSystem instance that this VirtualSystemSettingData instance is associated with.
@@ -171,8 +146,11 @@ func (vsms *VirtualSystemManagementService) CreateSystemSettings(settings *Virtu
 		}
 	}
 
-	if err = systemSettingsInst.Put("AutomaticSnapshotsEnabled", settings.AutomaticSnapshotsEnabled); err != nil {
-		return "", err
+	// Automatic checkpoints only exist since Windows 10 1709 and Windows Server 2019
+	if vsms.Session.Schema().HasProperty(Msvm_VirtualSystemSettingData, "AutomaticSnapshotsEnabled") {
+		if err = systemSettingsInst.Put("AutomaticSnapshotsEnabled", settings.AutomaticSnapshotsEnabled); err != nil {
+			return "", err
+		}
 	}
 
 	if settings.Notes != nil {
@@ -181,9 +159,46 @@ func (vsms *VirtualSystemManagementService) CreateSystemSettings(settings *Virtu
 		}
 	}
 
+	if settings.Version != "" {
+		if err = systemSettingsInst.Put("Version", settings.Version); err != nil {
+			return "", err
+		}
+	}
+
+	// The UEFI firmware settings only apply to generation 2 systems
+	if settings.VirtualSystemSubType == HyperVGeneration_V2 {
+		if err = vsms.putFirmwareSettings(systemSettingsInst, settings); err != nil {
+			return "", err
+		}
+	}
+
 	return systemSettingsInst.GetCimText(), nil
 }
 
+// putFirmwareSettings puts the UEFI firmware settings of settings on instance, the template and the
+// protocol only when set. The settings missing from the schema of older hosts are skipped.
+func (vsms *VirtualSystemManagementService) putFirmwareSettings(instance *wmiext.Instance, settings *VirtualSystemSettingData) error {
+	firmware := []struct {
+		name  string
+		value interface{}
+		set   bool
+	}{
+		{"SecureBootEnabled", settings.SecureBootEnabled, true},
+		{"SecureBootTemplateId", settings.SecureBootTemplateId, settings.SecureBootTemplateId != ""},
+		{"PauseAfterBootFailure", settings.PauseAfterBootFailure, true},
+		{"NetworkBootPreferredProtocol", settings.NetworkBootPreferredProtocol, settings.NetworkBootPreferredProtocol != 0},
+	}
+	for _, property := range firmware {
+		if !property.set || !vsms.Session.Schema().HasProperty(Msvm_VirtualSystemSettingData, property.name) {
+			continue
+		}
+		if err := instance.Put(property.name, property.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (vsms *VirtualSystemManagementService) AddVirtualEthernetConnection(
 	computerSystem *ComputerSystem,
 	networkAdapter *network_adapter.VirtualNetworkAdapter,
@@ -204,7 +219,7 @@ func (vsms *VirtualSystemManagementService) AddVirtualEthernetConnection(
 	defer virtualSystemSettingData.Close()
 
 	// apply the settings
-	resultInstances, err := vsms.AddResourceSettings(virtualSystemSettingData, []string{ethernetPortAllocationSettingData.GetCimText()})
+	resultInstances, err := wmiext.Await(vsms.AddResourceSettings(virtualSystemSettingData, []string{ethernetPortAllocationSettingData.GetCimText()}))
 	if err != nil {
 		return
 	}
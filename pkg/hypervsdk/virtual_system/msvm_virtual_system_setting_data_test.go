@@ -0,0 +1,129 @@
+package virtual_system
+
+import (
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/resource"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVirtualSystemSettingData_getResourceAllocationSettingData(t *testing.T) {
+	repo := wmiext.NewMemoryRepository(`root\virtualization\v2`)
+	repo.DefineClass(wmiext.MemoryClass{Name: Msvm_VirtualSystemSettingData, Keys: []string{"InstanceID"}})
+	repo.DefineClass(wmiext.MemoryClass{Name: resource.Msvm_ResourceAllocationSettingData, Keys: []string{"InstanceID"}})
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_VirtualSystemSettingDataComponent",
+		Properties: map[string]wmiext.CIMTYPE_ENUMERATION{
+			"GroupComponent": wmiext.CIM_REFERENCE,
+			"PartComponent":  wmiext.CIM_REFERENCE,
+		},
+	})
+
+	settingPath, err := repo.AddInstance(Msvm_VirtualSystemSettingData, map[string]interface{}{"InstanceID": "Microsoft:A0B1"})
+	require.NoError(t, err)
+	for _, rasd := range []struct {
+		instanceID   string
+		resourceType resource.ResourceAllocationSettingData_ResourceType
+	}{
+		{`Microsoft:A0B1\0`, resource.ResourceAllocationSettingData_ResourceType_Parallel_SCSI_HBA},
+		{`Microsoft:A0B1\1`, resource.ResourceAllocationSettingData_ResourceType_Disk_Drive},
+		{`Microsoft:A0B1\2`, resource.ResourceAllocationSettingData_ResourceType_Disk_Drive},
+	} {
+		path, err := repo.AddInstance(resource.Msvm_ResourceAllocationSettingData, map[string]interface{}{
+			"InstanceID": rasd.instanceID, "ResourceType": uint16(rasd.resourceType),
+		})
+		require.NoError(t, err)
+		_, err = repo.AddInstance("Msvm_VirtualSystemSettingDataComponent", map[string]interface{}{
+			"GroupComponent": wmiext.Reference(settingPath), "PartComponent": wmiext.Reference(path),
+		})
+		require.NoError(t, err)
+	}
+
+	session := repo.Service()
+	t.Cleanup(session.Close)
+	vssd, err := wmiext.QueryFirst[VirtualSystemSettingData](session, wmiext.Select(Msvm_VirtualSystemSettingData))
+	require.NoError(t, err)
+
+	drives, err := vssd.getResourceAllocationSettingData(resource.ResourceAllocationSettingData_ResourceType_Disk_Drive)
+	require.NoError(t, err)
+	var ids []string
+	for _, drive := range drives {
+		ids = append(ids, drive.InstanceID)
+	}
+	assert.ElementsMatch(t, []string{`Microsoft:A0B1\1`, `Microsoft:A0B1\2`}, ids)
+
+	drives, err = vssd.getResourceAllocationSettingData(resource.ResourceAllocationSettingData_ResourceType_DVD_drive)
+	require.NoError(t, err)
+	assert.Empty(t, drives)
+}
+
+func TestVirtualSystemManagementService_CreateSystemSettings(t *testing.T) {
+	properties := map[string]wmiext.CIMTYPE_ENUMERATION{
+		"InstanceID":           wmiext.CIM_STRING,
+		"ElementName":          wmiext.CIM_STRING,
+		"VirtualSystemSubType": wmiext.CIM_STRING,
+	}
+	createSystemSettings := func() string {
+		repo := wmiext.NewMemoryRepository(`root\virtualization\v2`)
+		repo.DefineClass(wmiext.MemoryClass{Name: Msvm_VirtualSystemSettingData, Keys: []string{"InstanceID"}, Properties: properties})
+		session := repo.Service()
+		t.Cleanup(session.Close)
+		text, err := (&VirtualSystemManagementService{Session: session}).CreateSystemSettings(&VirtualSystemSettingData{
+			ElementName:          "vm-1",
+			VirtualSystemSubType: "Microsoft:Hyper-V:SubType:2",
+		})
+		require.NoError(t, err)
+		return text
+	}
+
+	// Hosts without automatic checkpoints are not sent the setting
+	assert.NotContains(t, createSystemSettings(), "AutomaticSnapshotsEnabled")
+	properties["AutomaticSnapshotsEnabled"] = wmiext.CIM_BOOLEAN
+	assert.Contains(t, createSystemSettings(), `<PROPERTY NAME="AutomaticSnapshotsEnabled" TYPE="boolean"><VALUE>FALSE</VALUE></PROPERTY>`)
+}
+
+func TestVirtualSystemManagementService_CreateSystemSettings_Firmware(t *testing.T) {
+	createSystemSettings := func(settings *VirtualSystemSettingData) string {
+		repo := wmiext.NewMemoryRepository(`root\virtualization\v2`)
+		repo.DefineClass(wmiext.MemoryClass{Name: Msvm_VirtualSystemSettingData, Keys: []string{"InstanceID"}, Properties: map[string]wmiext.CIMTYPE_ENUMERATION{
+			"InstanceID":                   wmiext.CIM_STRING,
+			"ElementName":                  wmiext.CIM_STRING,
+			"VirtualSystemSubType":         wmiext.CIM_STRING,
+			"Version":                      wmiext.CIM_STRING,
+			"SecureBootEnabled":            wmiext.CIM_BOOLEAN,
+			"SecureBootTemplateId":         wmiext.CIM_STRING,
+			"PauseAfterBootFailure":        wmiext.CIM_BOOLEAN,
+			"NetworkBootPreferredProtocol": wmiext.CIM_UINT16,
+		}})
+		session := repo.Service()
+		t.Cleanup(session.Close)
+		text, err := (&VirtualSystemManagementService{Session: session}).CreateSystemSettings(settings)
+		require.NoError(t, err)
+		return text
+	}
+
+	text := createSystemSettings(&VirtualSystemSettingData{
+		ElementName:                  "vm-1",
+		VirtualSystemSubType:         HyperVGeneration_V2,
+		Version:                      "10.0",
+		SecureBootEnabled:            true,
+		SecureBootTemplateId:         SecureBootTemplate_MicrosoftUEFICertificateAuthority,
+		NetworkBootPreferredProtocol: NetworkBootPreferredProtocol_IPv6,
+	})
+	assert.Contains(t, text, `<PROPERTY NAME="Version" TYPE="string"><VALUE>10.0</VALUE></PROPERTY>`)
+	assert.Contains(t, text, `<PROPERTY NAME="SecureBootEnabled" TYPE="boolean"><VALUE>TRUE</VALUE></PROPERTY>`)
+	assert.Contains(t, text, `<PROPERTY NAME="SecureBootTemplateId" TYPE="string"><VALUE>`+SecureBootTemplate_MicrosoftUEFICertificateAuthority+`</VALUE></PROPERTY>`)
+	assert.Contains(t, text, `<PROPERTY NAME="PauseAfterBootFailure" TYPE="boolean"><VALUE>FALSE</VALUE></PROPERTY>`)
+	assert.Contains(t, text, `<PROPERTY NAME="NetworkBootPreferredProtocol" TYPE="uint16"><VALUE>4097</VALUE></PROPERTY>`)
+
+	// Generation 1 systems have no UEFI firmware
+	text = createSystemSettings(&VirtualSystemSettingData{
+		ElementName:          "vm-1",
+		VirtualSystemSubType: HyperVGeneration_V1,
+		SecureBootEnabled:    true,
+	})
+	assert.Contains(t, text, `<PROPERTY NAME="SecureBootEnabled" TYPE="boolean"></PROPERTY>`)
+	assert.Contains(t, text, `<PROPERTY NAME="Version" TYPE="string"></PROPERTY>`)
+}
@@ -0,0 +1,148 @@
+package virtual_system
+
+import (
+	"context"
+
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/retry"
+	utils "github.com/rokukoo/hyperv/pkg/hypervsdk/utils"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+const (
+	Msvm_VirtualSystemSnapshotService = "Msvm_VirtualSystemSnapshotService"
+)
+
+// SnapshotType is the SnapshotType parameter of CreateSnapshot.
+type SnapshotType uint16
+
+const (
+	SnapshotType_Full     SnapshotType = 2
+	SnapshotType_Recovery SnapshotType = 32768
+)
+
+// UserSnapshotType is the kind of the snapshots taken by the user, set on the settings of a system.
+type UserSnapshotType uint16
+
+const (
+	UserSnapshotType_Standard UserSnapshotType = 2
+	UserSnapshotType_Disk     UserSnapshotType = 3
+	// UserSnapshotType_Production falls back to a standard snapshot when the guest cannot take one.
+	UserSnapshotType_Production     UserSnapshotType = 4
+	UserSnapshotType_ProductionOnly UserSnapshotType = 5
+)
+
+type VirtualSystemSnapshotService struct {
+	Session *wmiext.Service
+	*wmiext.Instance
+	// Retry is the retry policy of each method, retry.DefaultPolicy for those not configured.
+	Retry *retry.Policies
+}
+
+// NewVirtualSystemSnapshotService returns the snapshot service of the session, which remains owned
+// by the caller.
+func NewVirtualSystemSnapshotService(session *wmiext.Service) (*VirtualSystemSnapshotService, error) {
+	// Get the singleton instance
+	svc, err := session.GetSingletonInstance(Msvm_VirtualSystemSnapshotService)
+	if err != nil {
+		return nil, err
+	}
+	return &VirtualSystemSnapshotService{Session: session, Instance: svc, Retry: retry.NewPolicies(retry.DefaultPolicy())}, nil
+}
+
+// CreateSnapshot starts taking a snapshot of the system, of the kind set by its UserSnapshotType.
+// The returned job yields the settings of the snapshot once completed.
+//
+// Microsoft Docs: https://learn.microsoft.com/en-us/windows/win32/hyperv_v2/createsnapshot-msvm-virtualsystemsnapshotservice
+func (vsss *VirtualSystemSnapshotService) CreateSnapshot(
+	system *ComputerSystem,
+	snapshotType SnapshotType,
+	opts ...wmiext.JobOption,
+) (*wmiext.ResultJob[*VirtualSystemSettingData], error) {
+	var (
+		started           *wmiext.Job
+		resultingSnapshot string
+	)
+
+	if err := vsss.Retry.Do(context.Background(), "CreateSnapshot", func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err = vsss.Method("CreateSnapshot").
+			In("AffectedSystem", system.Path()).
+			In("SnapshotSettings", nil).
+			In("SnapshotType", uint16(snapshotType)).
+			Execute().
+			Out("Job", &job).
+			Out("ResultingSnapshot", &resultingSnapshot).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
+
+		started, err = utils.StartJob(vsss.Instance, "CreateSnapshot", returnValue, job, opts...)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return wmiext.NewResultJob(started, func() (*VirtualSystemSettingData, error) {
+		snapshot := &VirtualSystemSettingData{}
+		return snapshot, vsss.Session.GetObjectAsObject(resultingSnapshot, snapshot)
+	}), nil
+}
+
+// ApplySnapshot starts reverting the system of the snapshot to it. The system must be off or saved.
+func (vsss *VirtualSystemSnapshotService) ApplySnapshot(snapshot *VirtualSystemSettingData, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	return vsss.snapshotMethod("ApplySnapshot", "Snapshot", snapshot, opts...)
+}
+
+// DestroySnapshot starts deleting the snapshot, whose children are attached to its parent.
+func (vsss *VirtualSystemSnapshotService) DestroySnapshot(snapshot *VirtualSystemSettingData, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	return vsss.snapshotMethod("DestroySnapshot", "AffectedSnapshot", snapshot, opts...)
+}
+
+// DestroySnapshotTree starts deleting the snapshot along with its descendants.
+func (vsss *VirtualSystemSnapshotService) DestroySnapshotTree(snapshot *VirtualSystemSettingData, opts ...wmiext.JobOption) (*wmiext.Job, error) {
+	return vsss.snapshotMethod("DestroySnapshotTree", "SnapshotSettingData", snapshot, opts...)
+}
+
+// snapshotMethod starts the method taking the snapshot as its only parameter.
+func (vsss *VirtualSystemSnapshotService) snapshotMethod(
+	method string,
+	parameter string,
+	snapshot *VirtualSystemSettingData,
+	opts ...wmiext.JobOption,
+) (*wmiext.Job, error) {
+	var started *wmiext.Job
+
+	if err := vsss.Retry.Do(context.Background(), method, func() (err error) {
+		var (
+			job         *wmiext.Instance
+			returnValue int32
+		)
+
+		if err = vsss.Method(method).
+			In(parameter, snapshot.Path()).
+			Execute().
+			Out("Job", &job).
+			Out("ReturnValue", &returnValue).
+			End(); err != nil {
+			return err
+		}
+
+		started, err = utils.StartJob(vsss.Instance, method, returnValue, job, opts...)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return started, nil
+}
+
+// GetSnapshots returns the settings of the snapshots of the system, in no particular order.
+func (vm *ComputerSystem) GetSnapshots() ([]*VirtualSystemSettingData, error) {
+	return wmiext.Query[VirtualSystemSettingData](vm.GetService(), wmiext.Select(Msvm_VirtualSystemSettingData).Where(wmiext.And(
+		wmiext.Equal("VirtualSystemIdentifier", vm.Name),
+		wmiext.Equal("VirtualSystemType", VirtualSystemType_Snapshot),
+	)))
+}
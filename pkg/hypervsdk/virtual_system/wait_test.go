@@ -0,0 +1,49 @@
+package virtual_system
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestComputerSystem(t *testing.T) (*ComputerSystem, *wmiext.MemoryRepository, string) {
+	repo := wmiext.NewMemoryRepository(`root\virtualization\v2`)
+	repo.DefineClass(wmiext.MemoryClass{Name: Msvm_ComputerSystem, Keys: []string{"CreationClassName", "Name"}})
+	path, err := repo.AddInstance(Msvm_ComputerSystem, map[string]interface{}{
+		"CreationClassName": Msvm_ComputerSystem, "Name": "A0B1", "EnabledState": uint16(Off),
+	})
+	require.NoError(t, err)
+	_, err = repo.AddInstance(Msvm_ComputerSystem, map[string]interface{}{
+		"CreationClassName": Msvm_ComputerSystem, "Name": "C2D3", "EnabledState": uint16(Off),
+	})
+	require.NoError(t, err)
+
+	session := repo.Service()
+	t.Cleanup(session.Close)
+	var systems []*ComputerSystem
+	require.NoError(t, session.FindObjects(wmiext.Select(Msvm_ComputerSystem).Where(wmiext.Equal("Name", "A0B1")).String(), &systems))
+	require.Len(t, systems, 1)
+	return systems[0], repo, path
+}
+
+func TestComputerSystem_WaitForState(t *testing.T) {
+	vm, repo, path := newTestComputerSystem(t)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = repo.Update(`Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="C2D3"`, map[string]interface{}{"EnabledState": uint16(Running)})
+		_ = repo.Update(path, map[string]interface{}{"EnabledState": uint16(Starting)})
+		_ = repo.Update(path, map[string]interface{}{"EnabledState": uint16(Running)})
+	}()
+	require.NoError(t, vm.WaitForState(Running, 5))
+	assert.Equal(t, uint16(Running), vm.EnabledState)
+
+	require.NoError(t, vm.WaitForState(Running, 5))
+
+	err := vm.WaitForState(Off, 1)
+	assert.ErrorIs(t, err, wmiext.TimedOut)
+	assert.Contains(t, err.Error(), "Current state: [2]")
+}
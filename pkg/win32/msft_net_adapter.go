@@ -110,7 +110,7 @@ func (na *NetworkAdapter) Configure(
 	if err != nil {
 		return
 	}
-	wquery := fmt.Sprintf("SELECT * FROM Win32_NetworkAdapterConfiguration WHERE InterfaceIndex = %d", na.InterfaceIndex)
+	wquery := wmiext.Select("Win32_NetworkAdapterConfiguration").Where(wmiext.Equal("InterfaceIndex", na.InterfaceIndex)).String()
 	netAdapterConfiguration, err := con.FindFirstInstance(wquery)
 	if err != nil {
 		return
@@ -170,7 +170,7 @@ func ListPhysicalNetAdapter() (adapters []NetworkAdapter, err error) {
 	if err != nil {
 		return nil, err
 	}
-	wquery := "SELECT * FROM MSFT_NetAdapter WHERE Virtual = false"
+	wquery := wmiext.Select("MSFT_NetAdapter").Where(wmiext.Equal("Virtual", false)).String()
 	if err = con.FindObjects(wquery, &adapters); err != nil {
 		return nil, err
 	}
@@ -182,7 +182,7 @@ func ListNetworkAdapters() (adapters []NetworkAdapter, err error) {
 	if err != nil {
 		return nil, err
 	}
-	wquery := "SELECT * FROM MSFT_NetAdapter"
+	wquery := wmiext.Select("MSFT_NetAdapter").String()
 	if err = con.FindObjects(wquery, &adapters); err != nil {
 		return nil, err
 	}
@@ -195,7 +195,7 @@ func GetNetworkAdapterByName(name string) (adapter *NetworkAdapter, err error) {
 		return nil, err
 	}
 	netadapter := &NetworkAdapter{}
-	wquery := fmt.Sprintf("SELECT * FROM MSFT_NetAdapter WHERE Name = '%s'", name)
+	wquery := wmiext.Select("MSFT_NetAdapter").Where(wmiext.Equal("Name", name)).String()
 	return netadapter, con.FindFirstObject(wquery, netadapter)
 }
 
@@ -205,6 +205,6 @@ func GetNetworkAdapterByDescription(description string) (adapter *NetworkAdapter
 		return nil, err
 	}
 	netadapter := &NetworkAdapter{}
-	wquery := fmt.Sprintf("SELECT * FROM MSFT_NetAdapter WHERE InterfaceDescription = '%s'", description)
+	wquery := wmiext.Select("MSFT_NetAdapter").Where(wmiext.Equal("InterfaceDescription", description)).String()
 	return netadapter, con.FindFirstObject(wquery, netadapter)
 }
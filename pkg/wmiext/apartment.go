@@ -0,0 +1,328 @@
+package wmiext
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrDispatcherClosed is returned for calls made to, or still pending on, a closed Dispatcher.
+	ErrDispatcherClosed = errors.New("apartment dispatcher is closed")
+	// ErrWrongApartment is returned when an object is used by a worker other than the one it was
+	// created by.
+	ErrWrongApartment = errors.New("object belongs to another apartment")
+)
+
+// Executor prepares the OS thread of an apartment worker, such as initializing COM on it. Setup is
+// called on the locked thread before the worker accepts any call, and Teardown on the same thread
+// once the worker stops.
+type Executor interface {
+	Setup() error
+	Teardown()
+}
+
+// ExecutorFactory returns the Executor of the worker with the specified id.
+type ExecutorFactory func(id int) Executor
+
+// Dispatcher runs calls on a pool of workers, each pinned to its own OS thread for its whole
+// lifetime. Calls to a single worker run one at a time in the order they are accepted.
+type Dispatcher struct {
+	workers  []*Worker
+	next     uint32
+	executor ExecutorFactory
+
+	mu        sync.Mutex
+	dedicated map[*Worker]struct{}
+	lastID    int
+	closed    bool
+	closeOnce sync.Once
+}
+
+// NewDispatcher starts the specified number of workers, each set up by the Executor returned by
+// factory. No worker is left running when the setup of any of them fails.
+func NewDispatcher(workers int, factory ExecutorFactory) (*Dispatcher, error) {
+	if workers < 1 {
+		return nil, errors.Wrapf(InvalidInput, "%d apartment workers", workers)
+	}
+
+	d := &Dispatcher{executor: factory, lastID: workers - 1}
+	for id := 0; id < workers; id++ {
+		w, err := startWorker(d, id, factory(id))
+		if err != nil {
+			d.Close()
+			return nil, errors.Wrapf(err, "apartment worker %d", id)
+		}
+		d.workers = append(d.workers, w)
+	}
+	return d, nil
+}
+
+// Worker returns the worker with the fewest pending calls, rotating among the equally loaded ones.
+func (d *Dispatcher) Worker() *Worker {
+	start := int(atomic.AddUint32(&d.next, 1) - 1)
+	var best *Worker
+	for i := range d.workers {
+		w := d.workers[(start+i)%len(d.workers)]
+		if best == nil || w.Pending() < best.Pending() {
+			best = w
+		}
+	}
+	return best
+}
+
+// Workers returns all workers of the dispatcher.
+func (d *Dispatcher) Workers() []*Worker {
+	return append([]*Worker(nil), d.workers...)
+}
+
+// Do runs fn on the least loaded worker, see Worker.Do.
+func (d *Dispatcher) Do(ctx context.Context, fn func() error) error {
+	return d.Worker().Do(ctx, fn)
+}
+
+// Dedicated starts a worker outside the pool, for calls that block it for long, such as waiting for
+// events. Its id follows the ids of the pool workers. The worker runs until Worker.Close or Close.
+func (d *Dispatcher) Dedicated() (*Worker, error) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil, ErrDispatcherClosed
+	}
+	d.lastID++
+	id := d.lastID
+	d.mu.Unlock()
+
+	w, err := startWorker(d, id, d.executor(id))
+	if err != nil {
+		return nil, errors.Wrapf(err, "apartment worker %d", id)
+	}
+	w.dedicated = true
+
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		w.Close()
+		return nil, ErrDispatcherClosed
+	}
+	if d.dedicated == nil {
+		d.dedicated = make(map[*Worker]struct{})
+	}
+	d.dedicated[w] = struct{}{}
+	d.mu.Unlock()
+	return w, nil
+}
+
+// Close stops all workers, including the dedicated ones, after their running call. The calls still
+// pending fail with ErrDispatcherClosed.
+func (d *Dispatcher) Close() {
+	d.closeOnce.Do(func() {
+		d.mu.Lock()
+		d.closed = true
+		workers := append([]*Worker(nil), d.workers...)
+		for w := range d.dedicated {
+			workers = append(workers, w)
+		}
+		d.mu.Unlock()
+
+		for _, w := range workers {
+			w.stopOnce.Do(func() { close(w.stop) })
+		}
+		for _, w := range workers {
+			<-w.done
+		}
+	})
+}
+
+const (
+	callPending int32 = iota
+	callFinished
+	callAbandoned
+)
+
+type call struct {
+	ctx     context.Context
+	fn      func() error
+	abandon func()
+	state   int32
+	result  chan error
+}
+
+// Worker runs calls on a single OS thread. Objects created by a call must only be used by later
+// calls to the same worker.
+type Worker struct {
+	id         int
+	dispatcher *Dispatcher
+	dedicated  bool
+	// mu guards refs and closing, by which a dedicated worker outlives the objects bound to it.
+	mu       sync.Mutex
+	refs     int
+	closing  bool
+	calls    chan *call
+	pending  int32
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func startWorker(d *Dispatcher, id int, executor Executor) (*Worker, error) {
+	w := &Worker{
+		id:         id,
+		dispatcher: d,
+		calls:      make(chan *call),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	setup := make(chan error, 1)
+	go w.run(executor, setup)
+	if err := <-setup; err != nil {
+		<-w.done
+		return nil, err
+	}
+	return w, nil
+}
+
+// run is the loop of the worker. The thread is never unlocked, so it exits along with the goroutine
+// rather than being handed to other goroutines in whatever state the executor left it.
+func (w *Worker) run(executor Executor, setup chan<- error) {
+	defer close(w.done)
+	runtime.LockOSThread()
+
+	if err := executor.Setup(); err != nil {
+		setup <- err
+		return
+	}
+	defer executor.Teardown()
+	setup <- nil
+
+	for {
+		select {
+		case c := <-w.calls:
+			w.execute(c)
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *Worker) execute(c *call) {
+	// The caller may have given up while the call was being handed over
+	err := c.ctx.Err()
+	if err == nil {
+		err = protect(c.fn)
+	}
+
+	if atomic.CompareAndSwapInt32(&c.state, callPending, callFinished) {
+		c.result <- err
+		return
+	}
+	if c.abandon != nil {
+		_ = protect(func() error {
+			c.abandon()
+			return nil
+		})
+	}
+}
+
+// protect turns a panic of fn into an error, so a failing call does not take the worker down.
+func protect(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("apartment call panicked: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// ID returns the index of the worker within its dispatcher.
+func (w *Worker) ID() int {
+	return w.id
+}
+
+// Close stops a worker started by Dispatcher.Dedicated once the objects bound to it are released,
+// right away when there are none. The workers of the pool are only stopped by Dispatcher.Close.
+func (w *Worker) Close() {
+	if !w.dedicated {
+		return
+	}
+	w.mu.Lock()
+	w.closing = true
+	idle := w.refs == 0
+	w.mu.Unlock()
+	if idle {
+		w.shutdown()
+	}
+}
+
+func (w *Worker) acquire() {
+	w.mu.Lock()
+	w.refs++
+	w.mu.Unlock()
+}
+
+func (w *Worker) release() {
+	w.mu.Lock()
+	w.refs--
+	idle := w.closing && w.refs == 0
+	w.mu.Unlock()
+	if idle {
+		w.shutdown()
+	}
+}
+
+// shutdown stops the dedicated worker after its running call and waits until it exits.
+func (w *Worker) shutdown() {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+
+	w.dispatcher.mu.Lock()
+	delete(w.dispatcher.dedicated, w)
+	w.dispatcher.mu.Unlock()
+}
+
+// Pending returns the number of calls waiting for or running on the worker.
+func (w *Worker) Pending() int {
+	return int(atomic.LoadInt32(&w.pending))
+}
+
+// Do runs fn on the worker and returns its error. When ctx is done before the worker picks the call
+// up, fn is not run at all. When ctx is done while fn is running, Do returns the error of ctx right
+// away and fn completes on the worker, as COM calls can not be interrupted.
+func (w *Worker) Do(ctx context.Context, fn func() error) error {
+	return w.do(ctx, fn, nil)
+}
+
+// do is Do with abandon run on the worker after fn completes if the caller gave up on the call by
+// then, typically to release what fn created.
+func (w *Worker) do(ctx context.Context, fn func() error, abandon func()) error {
+	atomic.AddInt32(&w.pending, 1)
+	defer atomic.AddInt32(&w.pending, -1)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c := &call{ctx: ctx, fn: fn, abandon: abandon, result: make(chan error, 1)}
+	select {
+	case w.calls <- c:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.stop:
+		return ErrDispatcherClosed
+	}
+
+	select {
+	case err := <-c.result:
+		return err
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&c.state, callPending, callAbandoned) {
+			return ctx.Err()
+		}
+		return <-c.result
+	}
+}
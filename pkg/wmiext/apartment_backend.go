@@ -0,0 +1,339 @@
+package wmiext
+
+import (
+	"context"
+	"time"
+)
+
+// Connect opens a Backend through factory on a worker of the dispatcher. The returned Backend, and
+// every object and iterator obtained from it, are bound to that worker: their calls are dispatched
+// to it, and passing one of its objects to a Backend bound to another worker fails with
+// ErrWrongApartment.
+func (d *Dispatcher) Connect(ctx context.Context, factory BackendFactory, namespace string) (Backend, error) {
+	return d.Worker().Connect(ctx, factory, namespace)
+}
+
+// Connect opens a Backend through factory on the worker, see Dispatcher.Connect.
+func (w *Worker) Connect(ctx context.Context, factory BackendFactory, namespace string) (Backend, error) {
+	var backend Backend
+	err := w.do(ctx, func() (err error) {
+		backend, err = factory(namespace)
+		return err
+	}, func() {
+		if backend != nil {
+			backend.Close()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &apartmentBackend{worker: w, backend: backend, factory: factory, namespace: namespace}, nil
+}
+
+// apartmentBackend is the Backend dispatching the calls of a Backend to the worker it was created by.
+type apartmentBackend struct {
+	worker  *Worker
+	backend Backend
+	// factory and namespace open the connections of the notification queries.
+	factory   BackendFactory
+	namespace string
+	// ctx is the context of the caller the calls are dispatched with, see Service.WithContext.
+	ctx context.Context
+}
+
+// withContext returns the backend dispatching its calls, and the ones of its iterators, with ctx.
+// Closing it closes the shared backend, which only the Service owning it does.
+func (a *apartmentBackend) withContext(ctx context.Context) Backend {
+	bound := *a
+	bound.ctx = ctx
+	return &bound
+}
+
+// callContext returns the context the calls are dispatched with.
+func (a *apartmentBackend) callContext() context.Context {
+	if a.ctx == nil {
+		return context.Background()
+	}
+	return a.ctx
+}
+
+func (a *apartmentBackend) iterate(fn func() (ObjectIterator, error)) (ObjectIterator, error) {
+	var iterator ObjectIterator
+	err := a.worker.do(a.callContext(), func() (err error) {
+		iterator, err = fn()
+		return err
+	}, func() {
+		if iterator != nil {
+			iterator.Release()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &apartmentIterator{worker: a.worker, iterator: iterator, ctx: a.callContext()}, nil
+}
+
+func (a *apartmentBackend) ExecQuery(wql string) (ObjectIterator, error) {
+	return a.iterate(func() (ObjectIterator, error) {
+		return a.backend.ExecQuery(wql)
+	})
+}
+
+func (a *apartmentBackend) GetObject(path string) (Object, error) {
+	return callObject(a.callContext(), a.worker, func() (Object, error) {
+		return a.backend.GetObject(path)
+	})
+}
+
+func (a *apartmentBackend) CreateInstanceEnum(className string) (ObjectIterator, error) {
+	return a.iterate(func() (ObjectIterator, error) {
+		return a.backend.CreateInstanceEnum(className)
+	})
+}
+
+func (a *apartmentBackend) ExecMethod(path string, method string, inParams Object) (Object, error) {
+	in, err := a.worker.unwrap(inParams)
+	if err != nil {
+		return nil, err
+	}
+	inObject, _ := in.(Object)
+	return callObject(a.callContext(), a.worker, func() (Object, error) {
+		return a.backend.ExecMethod(path, method, inObject)
+	})
+}
+
+// ExecNotificationQuery runs the query on a dedicated worker with a connection of its own, as waiting
+// for an event blocks the worker it is made from.
+func (a *apartmentBackend) ExecNotificationQuery(wql string) (EventIterator, error) {
+	worker, err := a.worker.dispatcher.Dedicated()
+	if err != nil {
+		return nil, err
+	}
+
+	var backend Backend
+	var events EventIterator
+	err = worker.do(a.callContext(), func() (err error) {
+		if backend, err = a.factory(a.namespace); err != nil {
+			return err
+		}
+		if events, err = backend.ExecNotificationQuery(wql); err != nil {
+			backend.Close()
+		}
+		return err
+	}, func() {
+		if events != nil {
+			events.Release()
+		}
+		if backend != nil {
+			backend.Close()
+		}
+	})
+	if err != nil {
+		worker.Close()
+		return nil, err
+	}
+	return &apartmentEvents{worker: worker, backend: backend, events: events, ctx: a.callContext()}, nil
+}
+
+// Close closes the backend regardless of the context of the caller, as do the Release methods of
+// its iterators and objects.
+func (a *apartmentBackend) Close() {
+	_ = a.worker.Do(context.Background(), func() error {
+		a.backend.Close()
+		return nil
+	})
+}
+
+// apartmentIterator is the ObjectIterator dispatching its calls to the worker it was created by.
+type apartmentIterator struct {
+	worker   *Worker
+	iterator ObjectIterator
+	ctx      context.Context
+}
+
+func (i *apartmentIterator) Next() (Object, error) {
+	return callObject(i.ctx, i.worker, i.iterator.Next)
+}
+
+func (i *apartmentIterator) Release() {
+	_ = i.worker.Do(context.Background(), func() error {
+		i.iterator.Release()
+		return nil
+	})
+}
+
+// apartmentEvents is the EventIterator dispatching its calls to the dedicated worker it was created
+// by, which it owns along with the connection of the query.
+type apartmentEvents struct {
+	worker  *Worker
+	backend Backend
+	events  EventIterator
+	ctx     context.Context
+}
+
+func (e *apartmentEvents) Next(timeout time.Duration) (Object, error) {
+	return callObject(e.ctx, e.worker, func() (Object, error) {
+		return e.events.Next(timeout)
+	})
+}
+
+func (e *apartmentEvents) Release() {
+	_ = e.worker.Do(context.Background(), func() error {
+		e.events.Release()
+		e.backend.Close()
+		return nil
+	})
+	e.worker.Close()
+}
+
+// apartmentObject is the Object dispatching its calls to the worker it was created by. Its calls
+// do not depend on the context of the call that returned it, which only covers that call.
+type apartmentObject struct {
+	worker *Worker
+	object Object
+}
+
+// bind returns the object bound to the worker, which a dedicated worker outlives.
+func (w *Worker) bind(object Object) *apartmentObject {
+	w.acquire()
+	return &apartmentObject{worker: w, object: object}
+}
+
+// callObject runs fn on the worker with ctx and binds the object it returns to the worker. ctx only
+// applies to this call: the object is released when the caller gave up on it.
+func callObject(ctx context.Context, w *Worker, fn func() (Object, error)) (Object, error) {
+	var object Object
+	err := w.do(ctx, func() (err error) {
+		object, err = fn()
+		return err
+	}, func() {
+		if object != nil {
+			object.Release()
+		}
+	})
+	if err != nil || object == nil {
+		return nil, err
+	}
+	return w.bind(object), nil
+}
+
+// wrap binds the objects held by a property value to the worker.
+func (w *Worker) wrap(value interface{}) interface{} {
+	switch cast := value.(type) {
+	case Object:
+		return w.bind(cast)
+	case []interface{}:
+		values := make([]interface{}, len(cast))
+		for i, v := range cast {
+			values[i] = w.wrap(v)
+		}
+		return values
+	}
+	return value
+}
+
+// unwrap returns the objects held by a property value as the backend of the worker knows them,
+// failing for objects bound to another worker.
+func (w *Worker) unwrap(value interface{}) (interface{}, error) {
+	switch cast := value.(type) {
+	case *apartmentObject:
+		if cast == nil {
+			return nil, nil
+		}
+		if cast.worker != w {
+			return nil, ErrWrongApartment
+		}
+		return cast.object, nil
+	case []interface{}:
+		values := make([]interface{}, len(cast))
+		for i, v := range cast {
+			var err error
+			if values[i], err = w.unwrap(v); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case Object:
+		// An object of another backend, which the backend of the worker will reject if it must
+		return cast, nil
+	}
+	return value, nil
+}
+
+func (o *apartmentObject) Get(name string) (value interface{}, cimType CIMTYPE_ENUMERATION, flavor WBEM_FLAVOR_TYPE, err error) {
+	err = o.worker.Do(context.Background(), func() (err error) {
+		value, cimType, flavor, err = o.object.Get(name)
+		return err
+	})
+	if err != nil {
+		return nil, cimType, flavor, err
+	}
+	return o.worker.wrap(value), cimType, flavor, nil
+}
+
+func (o *apartmentObject) Put(name string, value interface{}) error {
+	value, err := o.worker.unwrap(value)
+	if err != nil {
+		return err
+	}
+	return o.worker.Do(context.Background(), func() error {
+		return o.object.Put(name, value)
+	})
+}
+
+func (o *apartmentObject) Properties() ([]Property, error) {
+	var properties []Property
+	err := o.worker.Do(context.Background(), func() (err error) {
+		properties, err = o.object.Properties()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range properties {
+		properties[i].Value = o.worker.wrap(properties[i].Value)
+	}
+	return properties, nil
+}
+
+func (o *apartmentObject) SpawnInstance() (Object, error) {
+	return callObject(context.Background(), o.worker, o.object.SpawnInstance)
+}
+
+func (o *apartmentObject) Clone() (Object, error) {
+	return callObject(context.Background(), o.worker, o.object.Clone)
+}
+
+func (o *apartmentObject) MethodParameters(method string) (Object, error) {
+	return callObject(context.Background(), o.worker, func() (Object, error) {
+		return o.object.MethodParameters(method)
+	})
+}
+
+func (o *apartmentObject) CimText() (text string, err error) {
+	err = o.worker.Do(context.Background(), func() (err error) {
+		text, err = o.object.CimText()
+		return err
+	})
+	return text, err
+}
+
+func (o *apartmentObject) Refresh() error {
+	return o.worker.Do(context.Background(), o.object.Refresh)
+}
+
+func (o *apartmentObject) describeClass() (schema *ClassSchema, err error) {
+	err = o.worker.Do(context.Background(), func() (err error) {
+		schema, err = describeClass(o.object)
+		return err
+	})
+	return schema, err
+}
+
+func (o *apartmentObject) Release() {
+	_ = o.worker.Do(context.Background(), func() error {
+		o.object.Release()
+		return nil
+	})
+	o.worker.release()
+}
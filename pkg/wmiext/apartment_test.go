@@ -0,0 +1,313 @@
+package wmiext
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExecutor counts the setups and teardowns of a worker instead of initializing COM.
+type fakeExecutor struct {
+	setups    int32
+	teardowns int32
+	err       error
+}
+
+func (e *fakeExecutor) Setup() error {
+	atomic.AddInt32(&e.setups, 1)
+	return e.err
+}
+
+func (e *fakeExecutor) Teardown() {
+	atomic.AddInt32(&e.teardowns, 1)
+}
+
+func newTestDispatcher(t *testing.T, workers int) (*Dispatcher, []*fakeExecutor) {
+	executors := make([]*fakeExecutor, workers)
+	d, err := NewDispatcher(workers, func(id int) Executor {
+		executors[id] = &fakeExecutor{}
+		return executors[id]
+	})
+	require.NoError(t, err)
+	t.Cleanup(d.Close)
+	return d, executors
+}
+
+// block occupies w with a call until the returned function is called.
+func block(t *testing.T, w *Worker) func() {
+	started, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		_ = w.Do(context.Background(), func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	return func() { close(release) }
+}
+
+func TestNewDispatcher(t *testing.T) {
+	d, executors := newTestDispatcher(t, 2)
+	assert.Len(t, d.Workers(), 2)
+	d.Close()
+	d.Close()
+	for _, e := range executors {
+		assert.Equal(t, int32(1), e.setups)
+		assert.Equal(t, int32(1), e.teardowns)
+	}
+
+	first := &fakeExecutor{}
+	_, err := NewDispatcher(2, func(id int) Executor {
+		if id == 0 {
+			return first
+		}
+		return &fakeExecutor{err: errors.New("CoInitializeEx failed")}
+	})
+	assert.EqualError(t, err, "apartment worker 1: CoInitializeEx failed")
+	assert.Equal(t, int32(1), first.teardowns)
+
+	_, err = NewDispatcher(0, nil)
+	assert.ErrorIs(t, err, InvalidInput)
+}
+
+func TestDispatcher_Worker(t *testing.T) {
+	d, _ := newTestDispatcher(t, 2)
+	workers := d.Workers()
+
+	release := block(t, workers[0])
+	defer release()
+	assert.Equal(t, 1, workers[0].Pending())
+	assert.Same(t, workers[1], d.Worker())
+	assert.Same(t, workers[1], d.Worker())
+
+	release2 := block(t, workers[1])
+	defer release2()
+	first, second := d.Worker(), d.Worker()
+	assert.NotSame(t, first, second)
+}
+
+func TestWorker_DoSequential(t *testing.T) {
+	d, _ := newTestDispatcher(t, 1)
+	w := d.Worker()
+
+	var running, overlaps int32
+	done := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		go func() {
+			_ = w.Do(context.Background(), func() error {
+				if atomic.AddInt32(&running, 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+	assert.Zero(t, overlaps)
+	assert.Zero(t, w.Pending())
+}
+
+func TestWorker_DoCanceledBeforeStart(t *testing.T) {
+	d, _ := newTestDispatcher(t, 1)
+	w := d.Worker()
+	release := block(t, w)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ran := false
+	err := w.Do(ctx, func() error {
+		ran = true
+		return nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	require.NoError(t, w.Do(context.Background(), func() error { return nil }))
+	assert.False(t, ran)
+
+	err = w.Do(ctx, func() error { return nil })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWorker_DoCanceledWhileRunning(t *testing.T) {
+	d, _ := newTestDispatcher(t, 1)
+	w := d.Worker()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started, release, abandoned := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		<-started
+		cancel()
+	}()
+	err := w.do(ctx, func() error {
+		close(started)
+		<-release
+		return nil
+	}, func() { close(abandoned) })
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(release)
+	<-abandoned
+	require.NoError(t, w.Do(context.Background(), func() error { return nil }))
+}
+
+func TestWorker_DoPanic(t *testing.T) {
+	d, _ := newTestDispatcher(t, 1)
+	w := d.Worker()
+
+	err := w.Do(context.Background(), func() error { panic("access violation") })
+	assert.EqualError(t, err, "apartment call panicked: access violation")
+
+	wmiErr := errors.New("RPC server unavailable")
+	assert.Equal(t, wmiErr, w.Do(context.Background(), func() error { return wmiErr }))
+}
+
+func TestDispatcher_Close(t *testing.T) {
+	d, executors := newTestDispatcher(t, 1)
+	w := d.Worker()
+	release := block(t, w)
+
+	pending := make(chan error)
+	go func() {
+		pending <- w.Do(context.Background(), func() error { return nil })
+	}()
+	closed := make(chan struct{})
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+
+	assert.ErrorIs(t, <-pending, ErrDispatcherClosed)
+	release()
+	<-closed
+	assert.Equal(t, int32(1), executors[0].teardowns)
+	assert.ErrorIs(t, d.Do(context.Background(), func() error { return nil }), ErrDispatcherClosed)
+}
+
+func TestDispatcher_Connect(t *testing.T) {
+	repo := newTestRepository(t)
+	d, _ := newTestDispatcher(t, 2)
+	workers := d.Workers()
+
+	backends := make([]Backend, len(workers))
+	for i, w := range workers {
+		var err error
+		backends[i], err = w.Connect(context.Background(), MemoryBackendFactory(repo), testNamespace)
+		require.NoError(t, err)
+	}
+	first, second := NewService(backends[0]), NewService(backends[1])
+	defer first.Close()
+	defer second.Close()
+
+	instance, err := first.GetObject(`Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B1"`)
+	require.NoError(t, err)
+	defer instance.Close()
+	var system testSystem
+	require.NoError(t, instance.GetAll(&system))
+	assert.Equal(t, "vm-1", system.ElementName)
+
+	systems, err := first.FindInstances(Select("Msvm_ComputerSystem").String())
+	require.NoError(t, err)
+	assert.Len(t, systems, 2)
+
+	other, err := second.GetObject(`Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="C2D3"`)
+	require.NoError(t, err)
+	defer other.Close()
+	assert.ErrorIs(t, instance.Put("ElementName", other), ErrWrongApartment)
+	_, err = backends[1].ExecMethod(`Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="C2D3"`, "RequestStateChange", instance.object)
+	assert.ErrorIs(t, err, ErrWrongApartment)
+
+	_, err = d.Connect(context.Background(), MemoryBackendFactory(repo), `root\cimv2`)
+	assert.Error(t, err)
+}
+
+func TestDispatcher_ConnectEvents(t *testing.T) {
+	repo := newTestRepository(t)
+	var executors []*fakeExecutor
+	d, err := NewDispatcher(1, func(id int) Executor {
+		executors = append(executors, &fakeExecutor{})
+		return executors[id]
+	})
+	require.NoError(t, err)
+	defer d.Close()
+
+	backend, err := d.Connect(context.Background(), MemoryBackendFactory(repo), testNamespace)
+	require.NoError(t, err)
+	service := NewService(backend)
+	defer service.Close()
+
+	sub, err := service.Subscribe(context.Background(), InstanceEvents(InstanceDeletionEvent, "Msvm_ComputerSystem").String())
+	require.NoError(t, err)
+	require.Len(t, executors, 2)
+
+	// The subscription waits on its own worker, leaving the one of the service free
+	ctx, cancel := context.WithTimeout(context.Background(), eventPollTimeout/5)
+	defer cancel()
+	require.NoError(t, d.Workers()[0].Do(ctx, func() error { return nil }))
+	systems, err := service.FindInstances(Select("Msvm_ComputerSystem").String())
+	require.NoError(t, err)
+	for _, system := range systems {
+		system.Close()
+	}
+
+	require.NoError(t, repo.Delete(testSystemPath))
+	event := <-sub.Events()
+	require.NotNil(t, event)
+	sub.Close()
+	// The worker of the subscription outlives the event bound to it
+	var system testSystem
+	require.NoError(t, event.Target.GetAll(&system))
+	assert.Equal(t, "A0B1", system.Name)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&executors[1].teardowns))
+	event.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&executors[1].teardowns))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&executors[0].teardowns))
+}
+
+func TestDispatcher_ConnectContext(t *testing.T) {
+	repo := newTestRepository(t)
+	d, _ := newTestDispatcher(t, 1)
+	backend, err := d.Connect(context.Background(), MemoryBackendFactory(repo), testNamespace)
+	require.NoError(t, err)
+	session := NewService(backend)
+	defer session.Close()
+	const path = `Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B1"`
+
+	// A call waiting for a busy worker gives up with the context of the caller
+	unblock := block(t, d.Workers()[0])
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	_, err = session.WithContext(ctx).GetObject(path)
+	cancel()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	unblock()
+
+	// The context covers the calls of the service, not the instances they returned
+	ctx, cancel = context.WithCancel(context.Background())
+	bound := session.WithContext(ctx)
+	instance, err := bound.GetObject(path)
+	require.NoError(t, err)
+	cancel()
+	name, err := instance.GetAsString("ElementName")
+	require.NoError(t, err)
+	assert.Equal(t, "vm-1", name)
+	_, err = bound.FindInstances(Select("Msvm_ComputerSystem").String())
+	assert.ErrorIs(t, err, context.Canceled)
+	instance.Close()
+
+	// Closing the bound service leaves the backend open
+	bound.Close()
+	instance, err = session.GetObject(path)
+	require.NoError(t, err)
+	instance.Close()
+}
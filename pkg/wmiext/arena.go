@@ -0,0 +1,180 @@
+package wmiext
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrReleased is returned by the operations of an Instance or Enum used after being released, by
+// Close or by the Arena owning it.
+var ErrReleased = errors.New("wmi object used after being released")
+
+// MetricLeaks counts the instances, enumerations and arenas garbage collected without being
+// released, when leak detection is enabled.
+const MetricLeaks = "wmi.leaks"
+
+// handle is the backend object of an Instance or Enum, released at most once. It is kept apart
+// from its owner so that an Arena can release it without keeping the owner reachable.
+type handle struct {
+	release  func()
+	released atomic.Bool
+	// stack is where the owner was created, recorded for leak reports.
+	stack []byte
+}
+
+// close releases the backend object, and reports whether this call did.
+func (h *handle) close() bool {
+	if h == nil || !h.released.CompareAndSwap(false, true) {
+		return false
+	}
+	h.release()
+	return true
+}
+
+// live returns ErrReleased once the backend object was released.
+func (h *handle) live() error {
+	if h != nil && h.released.Load() {
+		return ErrReleased
+	}
+	return nil
+}
+
+// Arena owns the instances and enumerations created through the Services it scopes, see
+// Service.Scope, and releases them together when closed. Instances derived from an owned one, such
+// as clones or method results, are owned by the same arena. An Arena is safe for concurrent use.
+type Arena struct {
+	mu      sync.Mutex
+	handles map[*handle]struct{}
+	closed  bool
+}
+
+// NewArena returns an empty arena.
+func NewArena() *Arena {
+	a := &Arena{handles: make(map[*handle]struct{})}
+	if leakDetection.Load() {
+		stack := debug.Stack()
+		runtime.SetFinalizer(a, func(a *Arena) {
+			if n := a.Len(); n > 0 {
+				reportLeak(GetTelemetry(), "Arena", stack, Attr("wmi.arena.objects", n))
+			}
+			a.Close()
+		})
+	}
+	return a
+}
+
+// add takes the ownership of h, which is released at once when the arena is already closed.
+func (a *Arena) add(h *handle) {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		h.close()
+		return
+	}
+	a.handles[h] = struct{}{}
+	a.mu.Unlock()
+}
+
+// remove gives up the ownership of h.
+func (a *Arena) remove(h *handle) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.handles, h)
+}
+
+// Len returns the number of objects owned by the arena and not yet released.
+func (a *Arena) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.handles)
+}
+
+// Close releases every object owned by the arena. The objects created through its Services
+// afterward are released at once, and fail with ErrReleased when used.
+func (a *Arena) Close() {
+	a.mu.Lock()
+	handles := a.handles
+	a.handles = nil
+	a.closed = true
+	a.mu.Unlock()
+
+	for h := range handles {
+		h.close()
+	}
+}
+
+// Scope returns a Service sharing the backend and telemetry of s, whose instances and enumerations
+// are owned by arena. Closing the returned Service closes the arena, the backend remains open.
+func (s *Service) Scope(arena *Arena) *Service {
+	root := s
+	if s.root != nil {
+		root = s.root
+	}
+	return &Service{backend: s.backend, arena: arena, root: root}
+}
+
+// Arena returns the arena owning the instances and enumerations created through s, or nil when
+// they are owned by the caller, who must Close them.
+func (s *Service) Arena() *Arena {
+	return s.arena
+}
+
+// WithArena runs fn with a Service scoped to a new arena, and releases every instance and
+// enumeration created through it when fn returns. Values decoded with GetAll remain usable
+// afterward, but their embedded Instance does not.
+func (s *Service) WithArena(fn func(*Service) error) error {
+	arena := NewArena()
+	defer arena.Close()
+	return fn(s.Scope(arena))
+}
+
+// track returns the handle of a new instance or enumeration created through s, owned by the arena
+// of s if any.
+func (s *Service) track(release func()) *handle {
+	h := &handle{release: release}
+	if s != nil && s.arena != nil {
+		s.arena.add(h)
+	}
+	return h
+}
+
+// watchLeak reports owner when garbage collected without being released, if leak detection is
+// enabled and owner is not owned by an arena.
+func watchLeak[T any](owner *T, kind string, h *handle, s *Service) {
+	if !leakDetection.Load() || (s != nil && s.arena != nil) {
+		return
+	}
+	h.stack = debug.Stack()
+	runtime.SetFinalizer(owner, func(*T) {
+		if h.close() {
+			reportLeak(s.Telemetry(), kind, h.stack)
+		}
+	})
+}
+
+func reportLeak(telemetry *Telemetry, kind string, stack []byte, attrs ...Attribute) {
+	args := []interface{}{"kind", kind, "stack", string(stack)}
+	for _, attr := range attrs {
+		args = append(args, attr.Key, attr.Value)
+	}
+	telemetry.Log().Warn("WMI object garbage collected without being released", args...)
+	if telemetry.Meter != nil {
+		telemetry.Meter.Add(context.Background(), MetricLeaks, 1, append(attrs, Attr("wmi.kind", kind))...)
+	}
+}
+
+var leakDetection atomic.Bool
+
+// SetLeakDetection enables or disables the reporting of the instances, enumerations and arenas
+// garbage collected without being released. Leaks are logged as warnings along with the stack that
+// created them, counted as MetricLeaks, and released. Detection records a stack for every object
+// and is meant for debugging; builds with the wmiext_debug tag enable it from the start. Only the
+// objects created while enabled are watched.
+func SetLeakDetection(enabled bool) {
+	leakDetection.Store(enabled)
+}
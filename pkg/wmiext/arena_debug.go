@@ -0,0 +1,8 @@
+//go:build wmiext_debug
+// +build wmiext_debug
+
+package wmiext
+
+func init() {
+	SetLeakDetection(true)
+}
@@ -0,0 +1,164 @@
+package wmiext
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArena(t *testing.T) {
+	service := newTestRepository(t).Service()
+	defer service.Close()
+
+	arena := NewArena()
+	scoped := service.Scope(arena)
+	assert.Same(t, arena, scoped.Arena())
+	assert.Nil(t, service.Arena())
+
+	system, err := scoped.GetObject(testSystemPath)
+	require.NoError(t, err)
+	clone, err := system.CloneInstance()
+	require.NoError(t, err)
+	enum, err := scoped.ExecQuery("SELECT * FROM Msvm_ComputerSystem")
+	require.NoError(t, err)
+	first, err := enum.Next()
+	require.NoError(t, err)
+	assert.Equal(t, 4, arena.Len())
+
+	// Closing an owned instance releases it at once, and only once
+	clone.Close()
+	clone.Close()
+	assert.Equal(t, 3, arena.Len())
+	_, err = clone.GetAsString("ElementName")
+	assert.ErrorIs(t, err, ErrReleased)
+
+	// Detached instances outlive the arena
+	system.Detach()
+	assert.Equal(t, 2, arena.Len())
+
+	// Closing the scoped service releases the arena, not the backend
+	scoped.Close()
+	assert.Zero(t, arena.Len())
+	_, err = first.GetAsString("ElementName")
+	assert.ErrorIs(t, err, ErrReleased)
+	_, err = enum.Next()
+	assert.ErrorIs(t, err, ErrReleased)
+	name, err := system.GetAsString("ElementName")
+	require.NoError(t, err)
+	assert.Equal(t, "vm-1", name)
+	system.Close()
+
+	// Objects created through a closed arena are released at once
+	late, err := scoped.GetObject(testSystemPath)
+	require.NoError(t, err)
+	assert.Zero(t, arena.Len())
+	err = late.Put("ElementName", "vm-2")
+	assert.ErrorIs(t, err, ErrReleased)
+
+	_, err = service.GetObject(testSystemPath)
+	assert.NoError(t, err)
+}
+
+func TestService_WithArena(t *testing.T) {
+	service := newTestRepository(t).Service()
+	defer service.Close()
+
+	var (
+		retained []*testSystemInstance
+		decoded  []*testSystem
+		scope    *Arena
+	)
+	err := service.WithArena(func(s *Service) (err error) {
+		scope = s.Arena()
+		// Decoding into a struct without *Instance releases the instances right away
+		if decoded, err = Query[testSystem](s, Select("Msvm_ComputerSystem")); err != nil {
+			return err
+		}
+		assert.Zero(t, scope.Len())
+		if retained, err = Query[testSystemInstance](s, Select("Msvm_ComputerSystem")); err != nil {
+			return err
+		}
+		assert.Equal(t, 2, scope.Len())
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Zero(t, scope.Len())
+
+	// Decoded values remain, their instances do not
+	require.Len(t, decoded, 2)
+	require.Len(t, retained, 2)
+	assert.NotEmpty(t, retained[0].ElementName)
+	_, err = retained[0].GetAsString("ElementName")
+	assert.ErrorIs(t, err, ErrReleased)
+}
+
+type testSystemInstance struct {
+	*Instance
+	ElementName string
+}
+
+// leakLogger keeps the warnings logged by finalizers, from any goroutine.
+type leakLogger struct {
+	nopLogger
+	mu    sync.Mutex
+	kinds []string
+}
+
+func (l *leakLogger) Warn(_ string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == "kind" {
+			l.kinds = append(l.kinds, args[i+1].(string))
+		}
+	}
+}
+
+func (l *leakLogger) reported() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.kinds...)
+}
+
+func TestLeakDetection(t *testing.T) {
+	service := newTestRepository(t).Service()
+	defer service.Close()
+	logger := &leakLogger{}
+	service.SetTelemetry(&Telemetry{Logger: logger})
+
+	SetLeakDetection(true)
+	defer SetLeakDetection(false)
+
+	leak := func() {
+		instance, err := service.GetObject(testSystemPath)
+		require.NoError(t, err)
+		_, err = instance.GetAsString("ElementName")
+		require.NoError(t, err)
+
+		// Closed and arena-owned objects are not leaks
+		closed, err := service.GetObject(testSystemPath)
+		require.NoError(t, err)
+		closed.Close()
+		require.NoError(t, service.WithArena(func(s *Service) error {
+			_, err := s.GetObject(testSystemPath)
+			return err
+		}))
+	}
+	leak()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for len(logger.reported()) == 0 && ctx.Err() == nil {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Give the other finalizers a chance to report wrongly
+	runtime.GC()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"Instance"}, logger.reported())
+}
@@ -0,0 +1,125 @@
+package wmiext
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Backend is the transport a Service talks to. The COM implementation speaks to the local WMI
+// service on Windows, while MemoryRepository provides an in-process CIM repository that can be
+// used anywhere, e.g. for unit tests.
+type Backend interface {
+	// ExecQuery executes a WQL query and returns an iterator over the result set.
+	ExecQuery(wql string) (ObjectIterator, error)
+	// GetObject obtains a single class or instance given its path.
+	GetObject(path string) (Object, error)
+	// CreateInstanceEnum iterates all instances of the specified class, excluding subclasses.
+	CreateInstanceEnum(className string) (ObjectIterator, error)
+	// ExecMethod executes a method on the object at path with the specified input parameters.
+	ExecMethod(path string, method string, inParams Object) (Object, error)
+	// ExecNotificationQuery subscribes to the events matching a WQL event query.
+	ExecNotificationQuery(wql string) (EventIterator, error)
+	// Close frees all resources held by the backend.
+	Close()
+}
+
+// Object is a single class or instance held by a Backend.
+type Object interface {
+	// Get obtains a property value as a Golang value along with its CIM type information.
+	// Embedded objects are returned as Object values.
+	Get(name string) (interface{}, CIMTYPE_ENUMERATION, WBEM_FLAVOR_TYPE, error)
+	// Put sets the specified property, converting the Golang value appropriately.
+	Put(name string, value interface{}) error
+	// Properties returns all properties, including system properties, of this object.
+	Properties() ([]Property, error)
+	// SpawnInstance creates a new zero-initialized instance from a class object.
+	SpawnInstance() (Object, error)
+	// Clone creates a copy of this object.
+	Clone() (Object, error)
+	// MethodParameters returns an object holding the [in] parameters of the specified method.
+	MethodParameters(method string) (Object, error)
+	// CimText returns the CIM-XML representation of this object.
+	CimText() (string, error)
+	// Refresh updates the object with the current state held by the backend.
+	Refresh() error
+	// Release frees all resources associated with this object.
+	Release()
+}
+
+// ObjectIterator iterates the result set of a query or enumeration.
+type ObjectIterator interface {
+	// Next returns the next object, or nil once the result set is exhausted.
+	Next() (Object, error)
+	// Release frees all resources associated with this iterator.
+	Release()
+}
+
+// EventIterator delivers the events of a notification query as they occur.
+type EventIterator interface {
+	// Next waits up to timeout for the next event, returning nil when none occurred in time.
+	Next(timeout time.Duration) (Object, error)
+	// Release cancels the subscription and frees all resources associated with this iterator.
+	Release()
+}
+
+// Property is a single named property value of an Object.
+type Property struct {
+	Name    string
+	Value   interface{}
+	CimType CIMTYPE_ENUMERATION
+	Flavor  WBEM_FLAVOR_TYPE
+}
+
+// BackendFactory opens a Backend connected to the specified namespace.
+type BackendFactory func(namespace string) (Backend, error)
+
+var (
+	localBackendMu      sync.RWMutex
+	localBackendFactory BackendFactory = connectLocalBackend
+)
+
+// SetLocalBackendFactory replaces the factory used by NewLocalService and returns the previous one,
+// so it can be restored afterward.
+func SetLocalBackendFactory(factory BackendFactory) BackendFactory {
+	localBackendMu.Lock()
+	defer localBackendMu.Unlock()
+
+	previous := localBackendFactory
+	if factory == nil {
+		factory = connectLocalBackend
+	}
+	localBackendFactory = factory
+	return previous
+}
+
+// NewLocalService creates a Service and connect it to the local system at the specified namespace
+func NewLocalService(namespace string) (*Service, error) {
+	localBackendMu.RLock()
+	factory := localBackendFactory
+	localBackendMu.RUnlock()
+
+	backend, err := factory(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return NewService(backend), nil
+}
+
+// MemoryBackendFactory returns a BackendFactory serving each repository at its namespace.
+func MemoryBackendFactory(repositories ...*MemoryRepository) BackendFactory {
+	return func(namespace string) (Backend, error) {
+		for _, repo := range repositories {
+			if normalizeNamespace(repo.Namespace()) == normalizeNamespace(namespace) {
+				return repo.Backend(), nil
+			}
+		}
+		return nil, errors.Wrapf(NewWmiError(WBEM_E_INVALID_NAMESPACE), "namespace %s", namespace)
+	}
+}
+
+func normalizeNamespace(namespace string) string {
+	return strings.ToLower(strings.Trim(strings.ReplaceAll(namespace, "\\", "/"), "/"))
+}
@@ -0,0 +1,714 @@
+package wmiext
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+// ErrCassetteMismatch is returned by a replaying Backend for a call that differs from the one
+// recorded next, or that was not recorded at all.
+var ErrCassetteMismatch = errors.New("call does not match the cassette")
+
+// Interaction is a Backend call recorded in a Cassette.
+type Interaction struct {
+	Namespace string `json:"namespace"`
+	// Operation is the Backend method called, e.g. ExecQuery or ExecMethod.
+	Operation string `json:"operation"`
+	// Target is the query, object path or class name the call applies to.
+	Target string `json:"target"`
+	Method string `json:"method,omitempty"`
+	// In is the CIM-XML of the input parameters of ExecMethod.
+	In string `json:"in,omitempty"`
+	// Objects are the objects returned, in order: the result of GetObject, the output parameters of
+	// ExecMethod, the objects iterated from a query or the events received.
+	Objects []RecordedObject `json:"objects,omitempty"`
+	Error   *RecordedError   `json:"error,omitempty"`
+}
+
+func (i *Interaction) String() string {
+	s := i.Operation + " " + i.Target
+	if i.Method != "" {
+		s += " " + i.Method
+	}
+	return s
+}
+
+// RecordedObject is an object returned by a recorded call.
+type RecordedObject struct {
+	Path string `json:"path,omitempty"`
+	// Xml is the CIM-XML of the object.
+	Xml string `json:"xml"`
+}
+
+// RecordedError is the error of a recorded call.
+type RecordedError struct {
+	// Code is the HRESULT of a WmiError, 0 for any other error.
+	Code    uint32 `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+func recordError(err error) *RecordedError {
+	recorded := &RecordedError{Message: err.Error()}
+	var wmiErr *WmiError
+	if errors.As(err, &wmiErr) {
+		recorded.Code = uint32(wmiErr.Code())
+	}
+	return recorded
+}
+
+func (e *RecordedError) err() error {
+	if e.Code != 0 {
+		return errors.Wrap(NewWmiError(uintptr(e.Code)), "replayed")
+	}
+	return errors.New(e.Message)
+}
+
+func recordObject(object Object) (RecordedObject, error) {
+	text, err := EncodeCimXml(object)
+	if err != nil {
+		return RecordedObject{}, errors.Wrap(err, "failed to record object")
+	}
+	path, _, _, _ := object.Get(WmiPathKey)
+	recorded := RecordedObject{Xml: text}
+	recorded.Path, _ = path.(string)
+	return recorded, nil
+}
+
+// Cassette holds the calls made to Backends, recorded on a live host by Record and served back by
+// Replay, for example to run tests written against Hyper-V without it. Replay is strict: the
+// calls must be made in the order recorded, with the same arguments, each namespace on its own.
+// Refreshing an object is recorded as a call of its own, everything done outside WMI is not. A
+// Cassette is safe for concurrent use.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+
+	mu sync.Mutex
+	// replayed is the number of interactions replayed per namespace.
+	replayed map[string]int
+}
+
+// NewCassette returns an empty cassette.
+func NewCassette() *Cassette {
+	return &Cassette{}
+}
+
+// LoadCassette reads a cassette saved by Save.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, errors.Wrapf(err, "invalid cassette %s", path)
+	}
+	return c, nil
+}
+
+// Save writes the cassette to path as JSON, creating its directory if needed.
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Record returns a Backend recording the calls made to backend, connected to namespace, into the
+// cassette.
+func (c *Cassette) Record(namespace string, backend Backend) Backend {
+	return &recordingBackend{cassette: c, namespace: normalizeNamespace(namespace), backend: backend}
+}
+
+// RecordFactory returns a BackendFactory recording the calls made to the Backends opened by
+// factory.
+func (c *Cassette) RecordFactory(factory BackendFactory) BackendFactory {
+	return func(namespace string) (Backend, error) {
+		backend, err := factory(namespace)
+		if err != nil {
+			return nil, err
+		}
+		return c.Record(namespace, backend), nil
+	}
+}
+
+// Replay returns a Backend serving the calls recorded for namespace.
+func (c *Cassette) Replay(namespace string) Backend {
+	return &replayBackend{cassette: c, namespace: normalizeNamespace(namespace)}
+}
+
+// ReplayFactory returns a BackendFactory serving the calls recorded for each namespace.
+func (c *Cassette) ReplayFactory() BackendFactory {
+	return func(namespace string) (Backend, error) {
+		return c.Replay(namespace), nil
+	}
+}
+
+// Pending returns the interactions not replayed yet.
+func (c *Cassette) Pending() []*Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seen := make(map[string]int)
+	var pending []*Interaction
+	for _, interaction := range c.Interactions {
+		seen[interaction.Namespace]++
+		if seen[interaction.Namespace] > c.replayed[interaction.Namespace] {
+			pending = append(pending, interaction)
+		}
+	}
+	return pending
+}
+
+func (c *Cassette) append(interaction *Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, interaction)
+}
+
+// addObject records an object returned by the call of interaction.
+func (c *Cassette) addObject(interaction *Interaction, object Object) error {
+	recorded, err := recordObject(object)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	interaction.Objects = append(interaction.Objects, recorded)
+	return nil
+}
+
+// replay returns the next interaction of namespace, once matched against the call made.
+func (c *Cassette) replay(call *Interaction) (*Interaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index := c.replayed[call.Namespace]
+	var next *Interaction
+	for i, seen := 0, 0; i < len(c.Interactions); i++ {
+		if c.Interactions[i].Namespace != call.Namespace {
+			continue
+		}
+		if seen == index {
+			next = c.Interactions[i]
+			break
+		}
+		seen++
+	}
+	if next == nil {
+		return nil, errors.Wrapf(ErrCassetteMismatch, "%s: call %d %s was not recorded", call.Namespace, index+1, call)
+	}
+	if err := matchInteraction(next, call); err != nil {
+		return nil, errors.Wrapf(ErrCassetteMismatch, "%s: call %d %s, recorded %s: %s", call.Namespace, index+1, call, next, err)
+	}
+	if c.replayed == nil {
+		c.replayed = make(map[string]int)
+	}
+	c.replayed[call.Namespace]++
+	return next, nil
+}
+
+// parameters returns the input parameters of method of className as recorded, or none when the
+// method was not recorded.
+func (c *Cassette) parameters(className string, method string) (Object, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, interaction := range c.Interactions {
+		if interaction.Operation != "ExecMethod" || !strings.EqualFold(interaction.Method, method) || interaction.In == "" ||
+			!strings.EqualFold(pathClass(interaction.Target), className) {
+			continue
+		}
+		in, err := DecodeCimXml(interaction.In)
+		if err != nil {
+			return nil, err
+		}
+		return in.SpawnInstance()
+	}
+	return NewCimInstance("__PARAMETERS", nil)
+}
+
+func matchInteraction(recorded *Interaction, call *Interaction) error {
+	if recorded.Operation != call.Operation || !strings.EqualFold(recorded.Method, call.Method) {
+		return errors.New("different call")
+	}
+	if call.Operation == "GetObject" || call.Operation == "ExecMethod" || call.Operation == "Refresh" {
+		if !objectpath.Equal(recorded.Target, call.Target) {
+			return errors.New("different path")
+		}
+	} else if recorded.Target != call.Target {
+		return errors.New("different target")
+	}
+	if recorded.In == call.In {
+		return nil
+	}
+	recordedIn, err := canonicalParameters(recorded.In)
+	if err != nil {
+		return err
+	}
+	callIn, err := canonicalParameters(call.In)
+	if err != nil {
+		return err
+	}
+	for _, name := range unionKeys(recordedIn, callIn) {
+		if recordedIn[name] != callIn[name] {
+			return errors.Errorf("parameter %s is %q, recorded %q", name, callIn[name], recordedIn[name])
+		}
+	}
+	return nil
+}
+
+// canonicalParameters returns the values of the non-null properties of a CIM-XML instance, in a
+// form independent of the provider that encoded it: embedded instances are compared by value, and
+// object paths once normalized.
+func canonicalParameters(text string) (map[string]string, error) {
+	values := make(map[string]string)
+	if text == "" {
+		return values, nil
+	}
+	instance, err := DecodeCimXml(text)
+	if err != nil {
+		return nil, err
+	}
+	properties, err := instance.Properties()
+	if err != nil {
+		return nil, err
+	}
+	for _, prop := range properties {
+		if prop.Value == nil || strings.HasPrefix(prop.Name, "__") {
+			continue
+		}
+		values[strings.ToLower(prop.Name)] = canonicalValue(prop.Value, prop.CimType&^CIM_FLAG_ARRAY)
+	}
+	return values, nil
+}
+
+func canonicalValue(value interface{}, cimType CIMTYPE_ENUMERATION) string {
+	switch cast := value.(type) {
+	case []interface{}:
+		elems := make([]string, len(cast))
+		for i, elem := range cast {
+			elems[i] = canonicalValue(elem, cimType)
+		}
+		data, _ := json.Marshal(elems)
+		return string(data)
+	case Object:
+		if text, err := EncodeCimXml(cast); err == nil {
+			return canonicalValue(text, CIM_STRING)
+		}
+	case string:
+		if cimType == CIM_REFERENCE {
+			return objectpath.Normalize(cast)
+		}
+		if strings.HasPrefix(strings.TrimSpace(cast), "<INSTANCE") {
+			if embedded, err := canonicalParameters(strings.TrimSpace(cast)); err == nil {
+				data, _ := json.Marshal(embedded)
+				return string(data)
+			}
+		}
+	}
+	return convertToString(value)
+}
+
+// recordingBackend records the calls made to backend into its cassette. The objects it returns are
+// wrapped to record their refreshes, and unwrapped before reaching backend.
+type recordingBackend struct {
+	cassette  *Cassette
+	namespace string
+	backend   Backend
+}
+
+func (r *recordingBackend) interaction(operation string, target string) *Interaction {
+	return &Interaction{Namespace: r.namespace, Operation: operation, Target: target}
+}
+
+func (r *recordingBackend) wrap(object Object) Object {
+	if object == nil {
+		return nil
+	}
+	return &recordingObject{Object: object, backend: r}
+}
+
+func (r *recordingBackend) object(interaction *Interaction, object Object, err error) (Object, error) {
+	if err != nil {
+		interaction.Error = recordError(err)
+	} else if object != nil {
+		recorded, recordErr := recordObject(object)
+		if recordErr != nil {
+			object.Release()
+			return nil, recordErr
+		}
+		interaction.Objects = append(interaction.Objects, recorded)
+	}
+	r.cassette.append(interaction)
+	return r.wrap(object), err
+}
+
+func (r *recordingBackend) iterate(interaction *Interaction, iterator ObjectIterator, err error) (ObjectIterator, error) {
+	if err != nil {
+		interaction.Error = recordError(err)
+	}
+	r.cassette.append(interaction)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingIterator{backend: r, interaction: interaction, iterator: iterator}, nil
+}
+
+func (r *recordingBackend) ExecQuery(wql string) (ObjectIterator, error) {
+	iterator, err := r.backend.ExecQuery(wql)
+	return r.iterate(r.interaction("ExecQuery", wql), iterator, err)
+}
+
+func (r *recordingBackend) GetObject(path string) (Object, error) {
+	object, err := r.backend.GetObject(path)
+	return r.object(r.interaction("GetObject", path), object, err)
+}
+
+func (r *recordingBackend) CreateInstanceEnum(className string) (ObjectIterator, error) {
+	iterator, err := r.backend.CreateInstanceEnum(className)
+	return r.iterate(r.interaction("CreateInstanceEnum", className), iterator, err)
+}
+
+func (r *recordingBackend) ExecMethod(path string, method string, inParams Object) (Object, error) {
+	interaction := r.interaction("ExecMethod", path)
+	interaction.Method = method
+	if inParams != nil {
+		inParams = unwrapRecorded(inParams).(Object)
+		in, err := EncodeCimXml(inParams)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to record input parameters")
+		}
+		interaction.In = in
+	}
+	out, err := r.backend.ExecMethod(path, method, inParams)
+	return r.object(interaction, out, err)
+}
+
+func (r *recordingBackend) ExecNotificationQuery(wql string) (EventIterator, error) {
+	interaction := r.interaction("ExecNotificationQuery", wql)
+	iterator, err := r.backend.ExecNotificationQuery(wql)
+	if err != nil {
+		interaction.Error = recordError(err)
+	}
+	r.cassette.append(interaction)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingEventIterator{backend: r, interaction: interaction, iterator: iterator}, nil
+}
+
+func (r *recordingBackend) Close() {
+	r.backend.Close()
+}
+
+// unwrapRecorded returns the objects held by a value as the recorded backend knows them.
+func unwrapRecorded(value interface{}) interface{} {
+	switch cast := value.(type) {
+	case *recordingObject:
+		if cast == nil {
+			return nil
+		}
+		return cast.Object
+	case *Instance:
+		if cast == nil {
+			return nil
+		}
+		if recorded, ok := cast.object.(*recordingObject); ok {
+			return recorded.Object
+		}
+	case []interface{}:
+		values := make([]interface{}, len(cast))
+		for i, v := range cast {
+			values[i] = unwrapRecorded(v)
+		}
+		return values
+	}
+	return value
+}
+
+type recordingIterator struct {
+	backend     *recordingBackend
+	interaction *Interaction
+	iterator    ObjectIterator
+}
+
+func (i *recordingIterator) Next() (Object, error) {
+	object, err := i.iterator.Next()
+	if err != nil || object == nil {
+		return object, err
+	}
+	if err = i.backend.cassette.addObject(i.interaction, object); err != nil {
+		object.Release()
+		return nil, err
+	}
+	return i.backend.wrap(object), nil
+}
+
+func (i *recordingIterator) Release() {
+	i.iterator.Release()
+}
+
+type recordingEventIterator struct {
+	backend     *recordingBackend
+	interaction *Interaction
+	iterator    EventIterator
+}
+
+func (i *recordingEventIterator) Next(timeout time.Duration) (Object, error) {
+	event, err := i.iterator.Next(timeout)
+	if err != nil || event == nil {
+		return event, err
+	}
+	if err = i.backend.cassette.addObject(i.interaction, event); err != nil {
+		event.Release()
+		return nil, err
+	}
+	return i.backend.wrap(event), nil
+}
+
+func (i *recordingEventIterator) Release() {
+	i.iterator.Release()
+}
+
+// recordingObject is an object returned by a recordingBackend, recording its refreshes.
+type recordingObject struct {
+	Object
+	backend *recordingBackend
+}
+
+func (o *recordingObject) Put(name string, value interface{}) error {
+	return o.Object.Put(name, unwrapRecorded(value))
+}
+
+func (o *recordingObject) SpawnInstance() (Object, error) {
+	spawned, err := o.Object.SpawnInstance()
+	return o.backend.wrap(spawned), err
+}
+
+func (o *recordingObject) Clone() (Object, error) {
+	cloned, err := o.Object.Clone()
+	return o.backend.wrap(cloned), err
+}
+
+func (o *recordingObject) MethodParameters(method string) (Object, error) {
+	in, err := o.Object.MethodParameters(method)
+	return o.backend.wrap(in), err
+}
+
+func (o *recordingObject) describeClass() (*ClassSchema, error) {
+	return describeClass(o.Object)
+}
+
+func (o *recordingObject) Refresh() error {
+	path, _, _, _ := o.Object.Get(WmiPathKey)
+	target, _ := path.(string)
+	if target == "" {
+		return o.Object.Refresh()
+	}
+	interaction := o.backend.interaction("Refresh", target)
+	if err := o.Object.Refresh(); err != nil {
+		interaction.Error = recordError(err)
+		o.backend.cassette.append(interaction)
+		return err
+	}
+	recorded, err := recordObject(o.Object)
+	if err != nil {
+		return err
+	}
+	interaction.Objects = []RecordedObject{recorded}
+	o.backend.cassette.append(interaction)
+	return nil
+}
+
+// replayBackend serves the calls recorded in its cassette.
+type replayBackend struct {
+	cassette  *Cassette
+	namespace string
+}
+
+func (r *replayBackend) replay(operation string, target string, method string, inParams Object) (*Interaction, error) {
+	call := &Interaction{Namespace: r.namespace, Operation: operation, Target: target, Method: method}
+	if inParams != nil {
+		in, err := EncodeCimXml(inParams)
+		if err != nil {
+			return nil, err
+		}
+		call.In = in
+	}
+	recorded, err := r.cassette.replay(call)
+	if err != nil {
+		return nil, err
+	}
+	if recorded.Error != nil {
+		return nil, recorded.Error.err()
+	}
+	return recorded, nil
+}
+
+func (r *replayBackend) object(recorded RecordedObject) (Object, error) {
+	instance, err := DecodeCimXml(recorded.Xml)
+	if err != nil {
+		return nil, err
+	}
+	return &replayObject{CimInstance: instance, path: recorded.Path, backend: r}, nil
+}
+
+func (r *replayBackend) single(recorded *Interaction) (Object, error) {
+	if len(recorded.Objects) == 0 {
+		return nil, nil
+	}
+	return r.object(recorded.Objects[0])
+}
+
+func (r *replayBackend) ExecQuery(wql string) (ObjectIterator, error) {
+	recorded, err := r.replay("ExecQuery", wql, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &replayIterator{backend: r, objects: recorded.Objects}, nil
+}
+
+func (r *replayBackend) GetObject(path string) (Object, error) {
+	recorded, err := r.replay("GetObject", path, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.single(recorded)
+}
+
+func (r *replayBackend) CreateInstanceEnum(className string) (ObjectIterator, error) {
+	recorded, err := r.replay("CreateInstanceEnum", className, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &replayIterator{backend: r, objects: recorded.Objects}, nil
+}
+
+func (r *replayBackend) ExecMethod(path string, method string, inParams Object) (Object, error) {
+	recorded, err := r.replay("ExecMethod", path, method, inParams)
+	if err != nil {
+		return nil, err
+	}
+	return r.single(recorded)
+}
+
+func (r *replayBackend) ExecNotificationQuery(wql string) (EventIterator, error) {
+	recorded, err := r.replay("ExecNotificationQuery", wql, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &replayEventIterator{replayIterator{backend: r, objects: recorded.Objects}}, nil
+}
+
+func (r *replayBackend) Close() {}
+
+type replayIterator struct {
+	backend *replayBackend
+	objects []RecordedObject
+}
+
+func (i *replayIterator) Next() (Object, error) {
+	if len(i.objects) == 0 {
+		return nil, nil
+	}
+	next := i.objects[0]
+	i.objects = i.objects[1:]
+	return i.backend.object(next)
+}
+
+func (i *replayIterator) Release() {}
+
+// replayEventIterator delivers the events recorded, then times out.
+type replayEventIterator struct {
+	replayIterator
+}
+
+func (i *replayEventIterator) Next(timeout time.Duration) (Object, error) {
+	if len(i.objects) == 0 {
+		time.Sleep(timeout)
+		return nil, nil
+	}
+	return i.replayIterator.Next()
+}
+
+// replayObject is an object served from a cassette, with the path it was recorded with.
+type replayObject struct {
+	*CimInstance
+	path    string
+	backend *replayBackend
+}
+
+func (o *replayObject) system() []Property {
+	if o.path == "" {
+		return nil
+	}
+	properties := []Property{{Name: WmiPathKey, Value: o.path, CimType: CIM_STRING, Flavor: WBEM_FLAVOR_ORIGIN_SYSTEM}}
+	if parsed, err := objectpath.Parse(o.path); err == nil {
+		properties = append(properties,
+			Property{Name: "__RELPATH", Value: parsed.RelativePath(), CimType: CIM_STRING, Flavor: WBEM_FLAVOR_ORIGIN_SYSTEM},
+			Property{Name: "__NAMESPACE", Value: parsed.Namespace, CimType: CIM_STRING, Flavor: WBEM_FLAVOR_ORIGIN_SYSTEM},
+			Property{Name: "__SERVER", Value: parsed.Server, CimType: CIM_STRING, Flavor: WBEM_FLAVOR_ORIGIN_SYSTEM},
+		)
+	}
+	return properties
+}
+
+func (o *replayObject) Get(name string) (interface{}, CIMTYPE_ENUMERATION, WBEM_FLAVOR_TYPE, error) {
+	for _, prop := range o.system() {
+		if strings.EqualFold(prop.Name, name) {
+			return prop.Value, prop.CimType, prop.Flavor, nil
+		}
+	}
+	return o.CimInstance.Get(name)
+}
+
+func (o *replayObject) Properties() ([]Property, error) {
+	properties, err := o.CimInstance.Properties()
+	if err != nil {
+		return nil, err
+	}
+	return append(properties, o.system()...), nil
+}
+
+func (o *replayObject) Clone() (Object, error) {
+	return &replayObject{CimInstance: o.CimInstance.clone(), path: o.path, backend: o.backend}, nil
+}
+
+func (o *replayObject) MethodParameters(method string) (Object, error) {
+	return o.backend.cassette.parameters(o.className, method)
+}
+
+func (o *replayObject) CimText() (string, error) {
+	return EncodeCimXml(o)
+}
+
+// Refresh replaces the object with the one recorded when it was refreshed.
+func (o *replayObject) Refresh() error {
+	if o.path == "" {
+		return nil
+	}
+	recorded, err := o.backend.replay("Refresh", o.path, "", nil)
+	if err != nil {
+		return err
+	}
+	if len(recorded.Objects) == 0 {
+		return nil
+	}
+	instance, err := DecodeCimXml(recorded.Objects[0].Xml)
+	if err != nil {
+		return err
+	}
+	o.CimInstance = instance
+	return nil
+}
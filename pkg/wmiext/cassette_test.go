@@ -0,0 +1,113 @@
+package wmiext
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cassetteScenario struct {
+	Systems     []testSystem
+	ReturnValue uint32
+	JobState    uint
+	State       uint
+	MissingCode uintptr
+}
+
+// runCassetteScenario queries, changes the state of a system through a job, and fetches a missing
+// system.
+func runCassetteScenario(t *testing.T, service *Service, state uint16) (cassetteScenario, error) {
+	var scenario cassetteScenario
+	require.NoError(t, service.FindObjects("SELECT * FROM Msvm_ComputerSystem", &scenario.Systems))
+
+	system, err := service.GetObject(testSystemPath)
+	require.NoError(t, err)
+	defer system.Close()
+	var job *Instance
+	err = system.Method("RequestStateChange").
+		In("RequestedState", state).
+		Execute().
+		Out("Job", &job).
+		Out("ReturnValue", &scenario.ReturnValue).
+		End()
+	if err != nil {
+		return scenario, err
+	}
+	defer job.Close()
+	scenario.JobState, err = job.GetAsUint("JobState")
+	require.NoError(t, err)
+	require.NoError(t, system.Refresh())
+	scenario.State, err = system.GetAsUint("EnabledState")
+	require.NoError(t, err)
+
+	_, err = service.GetObject(`Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="missing"`)
+	var wmiErr *WmiError
+	require.True(t, errors.As(err, &wmiErr))
+	scenario.MissingCode = wmiErr.Code()
+	return scenario, nil
+}
+
+func TestCassette(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.DefineClass(MemoryClass{
+		Name:       "Msvm_ComputerSystem",
+		Superclass: "CIM_ComputerSystem",
+		Methods: map[string]map[string]CIMTYPE_ENUMERATION{
+			"RequestStateChange": {"RequestedState": CIM_UINT16, "Job": CIM_REFERENCE},
+		},
+	})
+	repo.HandleMethod("Msvm_ComputerSystem", "RequestStateChange", func(call *MethodCall) error {
+		if err := call.Repository.Update(call.Path, map[string]interface{}{"EnabledState": call.In("RequestedState")}); err != nil {
+			return err
+		}
+		jobPath, err := call.Repository.AddInstance("Msvm_ConcreteJob", map[string]interface{}{
+			"InstanceID": "job-1",
+			"JobState":   uint16(JobStateCompleted),
+		})
+		if err != nil {
+			return err
+		}
+		call.Out("Job", Reference(jobPath))
+		call.Return(4096)
+		return nil
+	})
+
+	recording := NewCassette()
+	service := NewService(recording.Record(testNamespace, repo.Backend()))
+	recorded, err := runCassetteScenario(t, service, 3)
+	require.NoError(t, err)
+	service.Close()
+	assert.Equal(t, uint(3), recorded.State)
+	assert.Equal(t, uint32(4096), recorded.ReturnValue)
+
+	path := filepath.Join(t.TempDir(), "cassettes", "scenario.json")
+	require.NoError(t, recording.Save(path))
+	cassette, err := LoadCassette(path)
+	require.NoError(t, err)
+	require.Len(t, cassette.Interactions, len(recording.Interactions))
+
+	// The replay serves the same results without the repository
+	service = NewService(cassette.Replay(`root/virtualization/v2`))
+	replayed, err := runCassetteScenario(t, service, 3)
+	require.NoError(t, err)
+	service.Close()
+	assert.Equal(t, recorded, replayed)
+	assert.Empty(t, cassette.Pending())
+
+	// Calls differing from the recording are rejected
+	cassette, err = LoadCassette(path)
+	require.NoError(t, err)
+	service = NewService(cassette.Replay(testNamespace))
+	defer service.Close()
+	_, err = runCassetteScenario(t, service, 2)
+	assert.ErrorIs(t, err, ErrCassetteMismatch)
+	assert.ErrorContains(t, err, "requestedstate")
+	assert.NotEmpty(t, cassette.Pending())
+
+	// As are calls made to a namespace not recorded
+	_, err = NewService(cassette.Replay(`root\cimv2`)).GetObject(testSystemPath)
+	assert.ErrorIs(t, err, ErrCassetteMismatch)
+}
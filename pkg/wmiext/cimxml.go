@@ -0,0 +1,716 @@
+package wmiext
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+var cimTypeNames = map[CIMTYPE_ENUMERATION]string{
+	CIM_SINT8:     "sint8",
+	CIM_UINT8:     "uint8",
+	CIM_SINT16:    "sint16",
+	CIM_UINT16:    "uint16",
+	CIM_SINT32:    "sint32",
+	CIM_UINT32:    "uint32",
+	CIM_SINT64:    "sint64",
+	CIM_UINT64:    "uint64",
+	CIM_REAL32:    "real32",
+	CIM_REAL64:    "real64",
+	CIM_BOOLEAN:   "boolean",
+	CIM_STRING:    "string",
+	CIM_DATETIME:  "datetime",
+	CIM_REFERENCE: "reference",
+	CIM_CHAR16:    "char16",
+}
+
+// CimInstance is a standalone CIM instance, decoded from or encoded to the CIM-XML (DSP0201) INSTANCE
+// element that WMI methods use for embedded instance parameters, such as the ResourceSettings of
+// ModifyResourceSettings. It implements Object, so it can be accessed through an Instance without a
+// live WMI service.
+type CimInstance struct {
+	className  string
+	names      []string
+	properties map[string]*cimProperty
+}
+
+type cimProperty struct {
+	name    string
+	value   interface{}
+	cimType CIMTYPE_ENUMERATION
+}
+
+// NewCimInstance creates an instance of the specified class. The properties are taken from src, which
+// can be nil, a map of property values, or a struct following the conventions of Instance.GetAll.
+func NewCimInstance(className string, src interface{}) (*CimInstance, error) {
+	c := &CimInstance{className: className, properties: make(map[string]*cimProperty)}
+	if src == nil {
+		return c, nil
+	}
+
+	properties, ok := src.(map[string]interface{})
+	if !ok {
+		val := reflect.ValueOf(src)
+		if val.Kind() == reflect.Pointer {
+			val = val.Elem()
+		}
+		if val.Kind() != reflect.Struct {
+			return nil, errors.New("not a map, struct or pointer to struct")
+		}
+
+		properties = make(map[string]interface{})
+		collectStructProperties(val, properties)
+	}
+
+	for name, value := range properties {
+		if err := c.Put(name, value); err != nil {
+			return nil, errors.Wrapf(err, "property %s", name)
+		}
+	}
+	sort.Strings(c.names)
+	return c, nil
+}
+
+// DecodeCimXml decodes a CIM-XML INSTANCE element. Values follow the automation mapping of WMI, so
+// the resulting instance behaves the same as one obtained from a live service.
+func DecodeCimXml(text string) (*CimInstance, error) {
+	var decoded cimXmlInstance
+	if err := xml.Unmarshal([]byte(text), &decoded); err != nil {
+		return nil, errors.Wrap(err, "invalid CIM-XML instance")
+	}
+	if decoded.XMLName.Local != "INSTANCE" {
+		return nil, errors.Errorf("invalid CIM-XML instance, unexpected element %s", decoded.XMLName.Local)
+	}
+
+	c := &CimInstance{className: decoded.ClassName, properties: make(map[string]*cimProperty)}
+	for _, prop := range decoded.Elements {
+		var value interface{}
+		var cimType CIMTYPE_ENUMERATION
+		var err error
+
+		switch prop.XMLName.Local {
+		case "PROPERTY":
+			value, cimType, err = prop.decodeValue()
+		case "PROPERTY.ARRAY":
+			value, cimType, err = prop.decodeArray()
+		case "PROPERTY.REFERENCE":
+			cimType = CIM_REFERENCE
+			if prop.ValueReference != nil {
+				value, err = prop.ValueReference.path()
+			}
+		default:
+			// Qualifiers
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "property %s", prop.Name)
+		}
+		c.set(prop.Name, value, cimType)
+	}
+	return c, nil
+}
+
+// EncodeCimXml encodes an object as a CIM-XML INSTANCE element. System properties are omitted.
+func EncodeCimXml(object Object) (string, error) {
+	className, _, _, err := object.Get("__CLASS")
+	if err != nil {
+		return "", err
+	}
+
+	properties, err := object.Properties()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<INSTANCE CLASSNAME="`)
+	writeEscaped(&sb, convertToString(className))
+	sb.WriteString(`">`)
+	for _, prop := range properties {
+		if strings.HasPrefix(prop.Name, "__") {
+			continue
+		}
+		if err = writeCimXmlProperty(&sb, prop); err != nil {
+			return "", errors.Wrapf(err, "property %s", prop.Name)
+		}
+	}
+	sb.WriteString(`</INSTANCE>`)
+	return sb.String(), nil
+}
+
+// MarshalCimXml encodes the exported fields of src as a CIM-XML INSTANCE of the specified class.
+func MarshalCimXml(className string, src interface{}) (string, error) {
+	c, err := NewCimInstance(className, src)
+	if err != nil {
+		return "", err
+	}
+	return c.CimText()
+}
+
+// UnmarshalCimXml decodes a CIM-XML INSTANCE into target, following the conventions of Instance.GetAll.
+func UnmarshalCimXml(text string, target interface{}) error {
+	c, err := DecodeCimXml(text)
+	if err != nil {
+		return err
+	}
+	return c.Instance().GetAll(target)
+}
+
+// Instance returns an Instance giving access to this object. The instance is not connected to a
+// service, so methods and related instances are not available.
+func (c *CimInstance) Instance() *Instance {
+	return newInstance(c, nil)
+}
+
+// ClassName returns the name of the class of this instance.
+func (c *CimInstance) ClassName() string {
+	return c.className
+}
+
+func (c *CimInstance) set(name string, value interface{}, cimType CIMTYPE_ENUMERATION) {
+	key := strings.ToLower(name)
+	if prop, ok := c.properties[key]; ok {
+		prop.value = value
+		prop.cimType = cimType
+		return
+	}
+	c.names = append(c.names, key)
+	c.properties[key] = &cimProperty{name: name, value: value, cimType: cimType}
+}
+
+func (c *CimInstance) Get(name string) (interface{}, CIMTYPE_ENUMERATION, WBEM_FLAVOR_TYPE, error) {
+	switch strings.ToUpper(name) {
+	case "__CLASS":
+		return c.className, CIM_STRING, WBEM_FLAVOR_ORIGIN_SYSTEM, nil
+	case "__GENUS":
+		return int32(2), CIM_SINT32, WBEM_FLAVOR_ORIGIN_SYSTEM, nil
+	}
+	if strings.HasPrefix(name, "__") {
+		return nil, CIM_STRING, WBEM_FLAVOR_ORIGIN_SYSTEM, nil
+	}
+
+	prop, ok := c.properties[strings.ToLower(name)]
+	if !ok {
+		return nil, CIM_EMPTY, 0, errors.Wrapf(NewWmiError(WBEM_E_NOT_FOUND), "property %s", name)
+	}
+	return copyAutomationValue(prop.value), prop.cimType, WBEM_FLAVOR_ORIGIN_LOCAL, nil
+}
+
+func (c *CimInstance) Put(name string, value interface{}) error {
+	if strings.HasPrefix(name, "__") {
+		return errors.Wrapf(NewWmiError(WBEM_E_READ_ONLY), "property %s", name)
+	}
+
+	automation, cimType, err := toAutomationValue(value)
+	if err != nil {
+		return err
+	}
+
+	if prop, ok := c.properties[strings.ToLower(name)]; ok && prop.cimType != CIM_EMPTY {
+		cimType = prop.cimType
+	}
+	c.set(name, automation, cimType)
+	return nil
+}
+
+func (c *CimInstance) Properties() ([]Property, error) {
+	properties := []Property{
+		{Name: "__GENUS", Value: int32(2), CimType: CIM_SINT32, Flavor: WBEM_FLAVOR_ORIGIN_SYSTEM},
+		{Name: "__CLASS", Value: c.className, CimType: CIM_STRING, Flavor: WBEM_FLAVOR_ORIGIN_SYSTEM},
+	}
+	for _, key := range c.names {
+		prop := c.properties[key]
+		properties = append(properties, Property{Name: prop.name, Value: copyAutomationValue(prop.value), CimType: prop.cimType})
+	}
+	return properties, nil
+}
+
+func (c *CimInstance) SpawnInstance() (Object, error) {
+	spawned := &CimInstance{className: c.className, properties: make(map[string]*cimProperty)}
+	for _, key := range c.names {
+		prop := c.properties[key]
+		spawned.set(prop.name, nil, prop.cimType)
+	}
+	return spawned, nil
+}
+
+func (c *CimInstance) Clone() (Object, error) {
+	return c.clone(), nil
+}
+
+func (c *CimInstance) clone() *CimInstance {
+	cloned := &CimInstance{
+		className:  c.className,
+		names:      append([]string(nil), c.names...),
+		properties: make(map[string]*cimProperty, len(c.properties)),
+	}
+	for key, prop := range c.properties {
+		cloned.properties[key] = &cimProperty{name: prop.name, value: copyAutomationValue(prop.value), cimType: prop.cimType}
+	}
+	return cloned
+}
+
+func (c *CimInstance) MethodParameters(method string) (Object, error) {
+	return nil, errors.Wrapf(NotSupported, "method %s of a CIM-XML instance", method)
+}
+
+func (c *CimInstance) CimText() (string, error) {
+	return EncodeCimXml(c)
+}
+
+func (c *CimInstance) Refresh() error {
+	return nil
+}
+
+func (c *CimInstance) Release() {}
+
+func writeCimXmlProperty(sb *strings.Builder, prop Property) error {
+	cimType := prop.CimType &^ CIM_FLAG_ARRAY
+	_, isSlice := prop.Value.([]interface{})
+	isArray := prop.CimType&CIM_FLAG_ARRAY != 0 || isSlice
+
+	if cimType == CIM_REFERENCE && !isArray {
+		sb.WriteString(`<PROPERTY.REFERENCE NAME="`)
+		writeEscaped(sb, prop.Name)
+		sb.WriteString(`">`)
+		if path, ok := prop.Value.(string); ok {
+			if err := writeCimXmlReference(sb, path); err != nil {
+				return err
+			}
+		}
+		sb.WriteString(`</PROPERTY.REFERENCE>`)
+		return nil
+	}
+
+	// Embedded objects and untyped values are carried as strings
+	typeName, ok := cimTypeNames[cimType]
+	if !ok || cimType == CIM_REFERENCE {
+		typeName = "string"
+	}
+
+	element := "PROPERTY"
+	if isArray {
+		element = "PROPERTY.ARRAY"
+	}
+
+	sb.WriteString(`<` + element + ` NAME="`)
+	writeEscaped(sb, prop.Name)
+	sb.WriteString(`" TYPE="` + typeName + `"`)
+	if cimType == CIM_OBJECT {
+		sb.WriteString(` EmbeddedObject="object"`)
+	}
+	sb.WriteString(`>`)
+
+	switch value := prop.Value.(type) {
+	case nil:
+	case []interface{}:
+		sb.WriteString(`<VALUE.ARRAY>`)
+		for _, elem := range value {
+			if err := writeCimXmlValue(sb, elem, cimType); err != nil {
+				return err
+			}
+		}
+		sb.WriteString(`</VALUE.ARRAY>`)
+	default:
+		if err := writeCimXmlValue(sb, value, cimType); err != nil {
+			return err
+		}
+	}
+
+	sb.WriteString(`</` + element + `>`)
+	return nil
+}
+
+func writeCimXmlValue(sb *strings.Builder, value interface{}, cimType CIMTYPE_ENUMERATION) error {
+	if value == nil {
+		sb.WriteString(`<VALUE.NULL/>`)
+		return nil
+	}
+
+	var text string
+	switch cast := value.(type) {
+	case Object:
+		embedded, err := EncodeCimXml(cast)
+		if err != nil {
+			return err
+		}
+		text = embedded
+	case bool:
+		text = strings.ToUpper(strconv.FormatBool(cast))
+	case int32:
+		// Unsigned 32-bit values travel as VT_I4
+		if cimType == CIM_UINT32 {
+			text = strconv.FormatUint(uint64(uint32(cast)), 10)
+		} else {
+			text = strconv.FormatInt(int64(cast), 10)
+		}
+	case float32:
+		text = strconv.FormatFloat(float64(cast), 'g', -1, 32)
+	case float64:
+		text = strconv.FormatFloat(cast, 'g', -1, 64)
+	default:
+		text = convertToString(value)
+	}
+
+	sb.WriteString(`<VALUE>`)
+	writeEscaped(sb, text)
+	sb.WriteString(`</VALUE>`)
+	return nil
+}
+
+// writeCimXmlReference writes an object path as a VALUE.REFERENCE element, using the most specific
+// path form available.
+func writeCimXmlReference(sb *strings.Builder, path string) error {
+	parsed, err := parseObjectPath(path)
+	if err != nil {
+		return err
+	}
+
+	sb.WriteString(`<VALUE.REFERENCE>`)
+	kind := "INSTANCE"
+	if parsed.IsClass() {
+		kind = "CLASS"
+	}
+
+	switch {
+	case parsed.Server != "":
+		sb.WriteString(`<` + kind + `PATH><NAMESPACEPATH><HOST>`)
+		writeEscaped(sb, parsed.Server)
+		sb.WriteString(`</HOST>`)
+		writeCimXmlNamespace(sb, parsed.Namespace)
+		sb.WriteString(`</NAMESPACEPATH>`)
+		writeCimXmlObjectName(sb, parsed)
+		sb.WriteString(`</` + kind + `PATH>`)
+	case parsed.Namespace != "":
+		sb.WriteString(`<LOCAL` + kind + `PATH>`)
+		writeCimXmlNamespace(sb, parsed.Namespace)
+		writeCimXmlObjectName(sb, parsed)
+		sb.WriteString(`</LOCAL` + kind + `PATH>`)
+	default:
+		writeCimXmlObjectName(sb, parsed)
+	}
+	sb.WriteString(`</VALUE.REFERENCE>`)
+	return nil
+}
+
+func writeCimXmlNamespace(sb *strings.Builder, namespace string) {
+	sb.WriteString(`<LOCALNAMESPACEPATH>`)
+	for _, name := range strings.Split(namespace, `\`) {
+		sb.WriteString(`<NAMESPACE NAME="`)
+		writeEscaped(sb, name)
+		sb.WriteString(`"/>`)
+	}
+	sb.WriteString(`</LOCALNAMESPACEPATH>`)
+}
+
+func writeCimXmlObjectName(sb *strings.Builder, path *objectpath.Path) {
+	if path.IsClass() {
+		sb.WriteString(`<CLASSNAME NAME="`)
+		writeEscaped(sb, path.ClassName)
+		sb.WriteString(`"/>`)
+		return
+	}
+
+	sb.WriteString(`<INSTANCENAME CLASSNAME="`)
+	writeEscaped(sb, path.ClassName)
+	sb.WriteString(`">`)
+	for _, key := range path.Keys {
+		if key.Name != "" {
+			sb.WriteString(`<KEYBINDING NAME="`)
+			writeEscaped(sb, key.Name)
+			sb.WriteString(`">`)
+		}
+
+		if _, ok := key.Reference(); ok {
+			_ = writeCimXmlReference(sb, key.Value.(string))
+		} else {
+			switch cast := key.Value.(type) {
+			case string:
+				sb.WriteString(`<KEYVALUE VALUETYPE="string">`)
+				writeEscaped(sb, cast)
+				sb.WriteString(`</KEYVALUE>`)
+			case bool:
+				sb.WriteString(`<KEYVALUE VALUETYPE="boolean">` + objectpath.FormatValue(cast) + `</KEYVALUE>`)
+			default:
+				sb.WriteString(`<KEYVALUE VALUETYPE="numeric">` + objectpath.FormatValue(cast) + `</KEYVALUE>`)
+			}
+		}
+
+		if key.Name != "" {
+			sb.WriteString(`</KEYBINDING>`)
+		}
+	}
+	sb.WriteString(`</INSTANCENAME>`)
+}
+
+func writeEscaped(sb *strings.Builder, s string) {
+	_ = xml.EscapeText(sb, []byte(s))
+}
+
+type cimXmlInstance struct {
+	XMLName   xml.Name
+	ClassName string           `xml:"CLASSNAME,attr"`
+	Elements  []cimXmlProperty `xml:",any"`
+}
+
+type cimXmlProperty struct {
+	XMLName        xml.Name
+	Name           string                `xml:"NAME,attr"`
+	Type           string                `xml:"TYPE,attr"`
+	Attrs          []xml.Attr            `xml:",any,attr"`
+	Qualifiers     []cimXmlQualifier     `xml:"QUALIFIER"`
+	Value          *string               `xml:"VALUE"`
+	ValueArray     *cimXmlValueArray     `xml:"VALUE.ARRAY"`
+	ValueReference *cimXmlValueReference `xml:"VALUE.REFERENCE"`
+}
+
+type cimXmlQualifier struct {
+	Name string `xml:"NAME,attr"`
+}
+
+type cimXmlValueArray struct {
+	Values []struct {
+		XMLName xml.Name
+		Text    string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+type cimXmlValueReference struct {
+	ClassPath         *cimXmlPath         `xml:"CLASSPATH"`
+	LocalClassPath    *cimXmlPath         `xml:"LOCALCLASSPATH"`
+	ClassName         *cimXmlName         `xml:"CLASSNAME"`
+	InstancePath      *cimXmlPath         `xml:"INSTANCEPATH"`
+	LocalInstancePath *cimXmlPath         `xml:"LOCALINSTANCEPATH"`
+	InstanceName      *cimXmlInstanceName `xml:"INSTANCENAME"`
+}
+
+type cimXmlPath struct {
+	Host          string              `xml:"NAMESPACEPATH>HOST"`
+	HostNamespace []cimXmlName        `xml:"NAMESPACEPATH>LOCALNAMESPACEPATH>NAMESPACE"`
+	Namespace     []cimXmlName        `xml:"LOCALNAMESPACEPATH>NAMESPACE"`
+	ClassName     *cimXmlName         `xml:"CLASSNAME"`
+	InstanceName  *cimXmlInstanceName `xml:"INSTANCENAME"`
+}
+
+type cimXmlName struct {
+	Name string `xml:"NAME,attr"`
+}
+
+type cimXmlInstanceName struct {
+	ClassName      string                `xml:"CLASSNAME,attr"`
+	KeyBindings    []cimXmlKeyBinding    `xml:"KEYBINDING"`
+	KeyValue       *cimXmlKeyValue       `xml:"KEYVALUE"`
+	ValueReference *cimXmlValueReference `xml:"VALUE.REFERENCE"`
+}
+
+type cimXmlKeyBinding struct {
+	Name           string                `xml:"NAME,attr"`
+	KeyValue       *cimXmlKeyValue       `xml:"KEYVALUE"`
+	ValueReference *cimXmlValueReference `xml:"VALUE.REFERENCE"`
+}
+
+type cimXmlKeyValue struct {
+	ValueType string `xml:"VALUETYPE,attr"`
+	Text      string `xml:",chardata"`
+}
+
+func (p *cimXmlProperty) cimType() (CIMTYPE_ENUMERATION, error) {
+	for _, attr := range p.Attrs {
+		if strings.EqualFold(attr.Name.Local, "EmbeddedObject") {
+			return CIM_OBJECT, nil
+		}
+	}
+	for _, qualifier := range p.Qualifiers {
+		if strings.EqualFold(qualifier.Name, "EmbeddedObject") || strings.EqualFold(qualifier.Name, "EmbeddedInstance") {
+			return CIM_OBJECT, nil
+		}
+	}
+
+	for cimType, name := range cimTypeNames {
+		if strings.EqualFold(name, p.Type) {
+			return cimType, nil
+		}
+	}
+	return CIM_EMPTY, errors.Errorf("unknown type %q", p.Type)
+}
+
+func (p *cimXmlProperty) decodeValue() (interface{}, CIMTYPE_ENUMERATION, error) {
+	cimType, err := p.cimType()
+	if err != nil {
+		return nil, CIM_EMPTY, err
+	}
+	if p.Value == nil {
+		return nil, cimType, nil
+	}
+
+	value, err := parseCimXmlValue(*p.Value, cimType)
+	return value, cimType, err
+}
+
+func (p *cimXmlProperty) decodeArray() (interface{}, CIMTYPE_ENUMERATION, error) {
+	cimType, err := p.cimType()
+	if err != nil {
+		return nil, CIM_EMPTY, err
+	}
+	if p.ValueArray == nil {
+		return nil, cimType | CIM_FLAG_ARRAY, nil
+	}
+
+	values := make([]interface{}, len(p.ValueArray.Values))
+	for i, elem := range p.ValueArray.Values {
+		if elem.XMLName.Local == "VALUE.NULL" {
+			continue
+		}
+		if values[i], err = parseCimXmlValue(elem.Text, cimType); err != nil {
+			return nil, CIM_EMPTY, err
+		}
+	}
+	return values, cimType | CIM_FLAG_ARRAY, nil
+}
+
+// parseCimXmlValue converts the text of a VALUE element to the automation form of the CIM type.
+//
+//gocyclo:ignore
+func parseCimXmlValue(text string, cimType CIMTYPE_ENUMERATION) (interface{}, error) {
+	trimmed := strings.TrimSpace(text)
+	switch cimType {
+	case CIM_SINT8:
+		v, err := strconv.ParseInt(trimmed, 0, 8)
+		return int16(v), err
+	case CIM_UINT8:
+		v, err := strconv.ParseUint(trimmed, 0, 8)
+		return uint8(v), err
+	case CIM_SINT16:
+		v, err := strconv.ParseInt(trimmed, 0, 16)
+		return int16(v), err
+	case CIM_UINT16:
+		v, err := strconv.ParseUint(trimmed, 0, 16)
+		return int32(v), err
+	case CIM_SINT32:
+		v, err := strconv.ParseInt(trimmed, 0, 32)
+		return int32(v), err
+	case CIM_UINT32:
+		v, err := strconv.ParseUint(trimmed, 0, 32)
+		return int32(uint32(v)), err
+	case CIM_SINT64:
+		_, err := strconv.ParseInt(trimmed, 0, 64)
+		return trimmed, err
+	case CIM_UINT64:
+		_, err := strconv.ParseUint(trimmed, 0, 64)
+		return trimmed, err
+	case CIM_REAL32:
+		v, err := strconv.ParseFloat(trimmed, 32)
+		return float32(v), err
+	case CIM_REAL64:
+		v, err := strconv.ParseFloat(trimmed, 64)
+		return v, err
+	case CIM_BOOLEAN:
+		return strconv.ParseBool(strings.ToLower(trimmed))
+	case CIM_DATETIME:
+		return trimmed, nil
+	case CIM_OBJECT:
+		return DecodeCimXml(text)
+	default:
+		return text, nil
+	}
+}
+
+// path formats the reference as a WMI object path.
+func (r *cimXmlValueReference) path() (string, error) {
+	switch {
+	case r.InstancePath != nil:
+		return r.InstancePath.format()
+	case r.LocalInstancePath != nil:
+		return r.LocalInstancePath.format()
+	case r.InstanceName != nil:
+		return r.InstanceName.format()
+	case r.ClassPath != nil:
+		return r.ClassPath.format()
+	case r.LocalClassPath != nil:
+		return r.LocalClassPath.format()
+	case r.ClassName != nil:
+		return r.ClassName.Name, nil
+	default:
+		return "", errors.New("empty VALUE.REFERENCE")
+	}
+}
+
+func (p *cimXmlPath) format() (string, error) {
+	var rel string
+	switch {
+	case p.InstanceName != nil:
+		var err error
+		if rel, err = p.InstanceName.format(); err != nil {
+			return "", err
+		}
+	case p.ClassName != nil:
+		rel = p.ClassName.Name
+	default:
+		return "", errors.New("object path without class or instance name")
+	}
+
+	namespaces := p.Namespace
+	if p.Host != "" || len(p.HostNamespace) > 0 {
+		namespaces = p.HostNamespace
+	}
+	names := make([]string, len(namespaces))
+	for i, namespace := range namespaces {
+		names[i] = namespace.Name
+	}
+	namespace := strings.Join(names, `\`)
+
+	switch {
+	case p.Host != "":
+		return fmt.Sprintf(`\\%s\%s:%s`, p.Host, namespace, rel), nil
+	case namespace != "":
+		return namespace + ":" + rel, nil
+	default:
+		return rel, nil
+	}
+}
+
+func (n *cimXmlInstanceName) format() (string, error) {
+	switch {
+	case n.KeyValue != nil:
+		return n.ClassName + "=" + n.KeyValue.format(), nil
+	case n.ValueReference != nil:
+		path, err := n.ValueReference.path()
+		return n.ClassName + "=" + objectpath.FormatValue(path), err
+	case len(n.KeyBindings) == 0:
+		return n.ClassName + "=@", nil
+	}
+
+	parts := make([]string, len(n.KeyBindings))
+	for i, binding := range n.KeyBindings {
+		switch {
+		case binding.KeyValue != nil:
+			parts[i] = binding.Name + "=" + binding.KeyValue.format()
+		case binding.ValueReference != nil:
+			path, err := binding.ValueReference.path()
+			if err != nil {
+				return "", err
+			}
+			parts[i] = binding.Name + "=" + objectpath.FormatValue(path)
+		default:
+			return "", errors.Errorf("key binding %s without value", binding.Name)
+		}
+	}
+	return n.ClassName + "." + strings.Join(parts, ","), nil
+}
+
+func (v *cimXmlKeyValue) format() string {
+	switch strings.ToLower(v.ValueType) {
+	case "numeric":
+		return strings.TrimSpace(v.Text)
+	case "boolean":
+		return strings.ToUpper(strings.TrimSpace(v.Text))
+	default:
+		return objectpath.FormatValue(v.Text)
+	}
+}
@@ -0,0 +1,206 @@
+package wmiext
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testVirtualHardDiskSettingData = `<INSTANCE CLASSNAME="Msvm_VirtualHardDiskSettingData">` +
+	`<QUALIFIER NAME="dynamic" PROPAGATED="true" TYPE="boolean" TOSUBCLASS="false"><VALUE>TRUE</VALUE></QUALIFIER>` +
+	`<PROPERTY NAME="BlockSize" CLASSORIGIN="Msvm_VirtualHardDiskSettingData" TYPE="uint32"><VALUE>33554432</VALUE></PROPERTY>` +
+	`<PROPERTY NAME="Caption" CLASSORIGIN="CIM_ManagedElement" PROPAGATED="true" TYPE="string"><VALUE>Hard Disk Image Settings</VALUE></PROPERTY>` +
+	`<PROPERTY NAME="Format" CLASSORIGIN="Msvm_VirtualHardDiskSettingData" TYPE="uint16"><VALUE>3</VALUE></PROPERTY>` +
+	`<PROPERTY NAME="IsPmemCompatible" CLASSORIGIN="Msvm_VirtualHardDiskSettingData" TYPE="boolean"><VALUE>FALSE</VALUE></PROPERTY>` +
+	`<PROPERTY NAME="LogicalSectorSize" CLASSORIGIN="Msvm_VirtualHardDiskSettingData" TYPE="uint32"><VALUE>512</VALUE></PROPERTY>` +
+	`<PROPERTY NAME="MaxInternalSize" CLASSORIGIN="Msvm_VirtualHardDiskSettingData" TYPE="uint64"><VALUE>10737418240</VALUE></PROPERTY>` +
+	`<PROPERTY NAME="ParentPath" CLASSORIGIN="Msvm_VirtualHardDiskSettingData" TYPE="string"></PROPERTY>` +
+	`<PROPERTY NAME="ParentTimestamp" CLASSORIGIN="Msvm_VirtualHardDiskSettingData" TYPE="datetime"><VALUE>20240102030405.000000+000</VALUE></PROPERTY>` +
+	`<PROPERTY NAME="Path" CLASSORIGIN="Msvm_VirtualHardDiskSettingData" TYPE="string"><VALUE>C:\vhd\a &amp; b.vhdx</VALUE></PROPERTY>` +
+	`<PROPERTY NAME="PhysicalSectorSize" CLASSORIGIN="Msvm_VirtualHardDiskSettingData" TYPE="uint32"><VALUE>4096</VALUE></PROPERTY>` +
+	`<PROPERTY NAME="Type" CLASSORIGIN="Msvm_VirtualHardDiskSettingData" TYPE="uint16"><VALUE>3</VALUE></PROPERTY>` +
+	`</INSTANCE>`
+
+type testDiskSettingData struct {
+	Caption            string
+	Type               uint16
+	Format             uint16
+	Path               string
+	ParentPath         string
+	ParentTimestamp    time.Time
+	MaxInternalSize    uint64
+	BlockSize          uint32
+	LogicalSectorSize  uint32
+	PhysicalSectorSize uint32
+	IsPmemCompatible   bool
+	HostResource       []string
+	*Instance
+}
+
+func TestUnmarshalCimXml(t *testing.T) {
+	var data testDiskSettingData
+	require.NoError(t, UnmarshalCimXml(testVirtualHardDiskSettingData, &data))
+
+	assert.Equal(t, "Hard Disk Image Settings", data.Caption)
+	assert.Equal(t, uint16(3), data.Type)
+	assert.Equal(t, `C:\vhd\a & b.vhdx`, data.Path)
+	assert.Empty(t, data.ParentPath)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), data.ParentTimestamp.UTC())
+	assert.Equal(t, uint64(10737418240), data.MaxInternalSize)
+	assert.Equal(t, uint32(33554432), data.BlockSize)
+	assert.Equal(t, uint32(4096), data.PhysicalSectorSize)
+	assert.False(t, data.IsPmemCompatible)
+	require.NotNil(t, data.Instance)
+
+	className, err := data.GetClassName()
+	require.NoError(t, err)
+	assert.Equal(t, "Msvm_VirtualHardDiskSettingData", className)
+}
+
+func TestCimXmlRoundTrip(t *testing.T) {
+	src := testDiskSettingData{
+		Type:               2,
+		Format:             3,
+		Path:               `C:\vhd\"quoted" <disk>.vhdx`,
+		ParentTimestamp:    time.Date(2024, 5, 6, 7, 8, 9, 123000, time.UTC),
+		MaxInternalSize:    1 << 40,
+		BlockSize:          1 << 31,
+		LogicalSectorSize:  512,
+		PhysicalSectorSize: 4096,
+		IsPmemCompatible:   true,
+		HostResource:       []string{"a", "b"},
+	}
+
+	text, err := MarshalCimXml("Msvm_VirtualHardDiskSettingData", &src)
+	require.NoError(t, err)
+	assert.Contains(t, text, `<PROPERTY NAME="BlockSize" TYPE="uint32"><VALUE>2147483648</VALUE></PROPERTY>`)
+	assert.Contains(t, text, `<PROPERTY NAME="IsPmemCompatible" TYPE="boolean"><VALUE>TRUE</VALUE></PROPERTY>`)
+	assert.Contains(t, text, `<PROPERTY NAME="ParentPath" TYPE="string"><VALUE></VALUE></PROPERTY>`)
+	assert.Contains(t, text, `<PROPERTY.ARRAY NAME="HostResource" TYPE="string"><VALUE.ARRAY><VALUE>a</VALUE><VALUE>b</VALUE></VALUE.ARRAY></PROPERTY.ARRAY>`)
+
+	var dest testDiskSettingData
+	require.NoError(t, UnmarshalCimXml(text, &dest))
+	dest.Instance = nil
+	dest.ParentTimestamp = dest.ParentTimestamp.UTC()
+	assert.Equal(t, src, dest)
+
+	decoded, err := DecodeCimXml(text)
+	require.NoError(t, err)
+	again, err := decoded.CimText()
+	require.NoError(t, err)
+	assert.Equal(t, text, again)
+}
+
+func TestCimXmlNumericTypes(t *testing.T) {
+	src := map[string]interface{}{
+		"S8":  int8(-8),
+		"U8":  uint8(200),
+		"S16": int16(-1600),
+		"U16": uint16(65535),
+		"S32": int32(-320000),
+		"U32": uint32(4294967295),
+		"S64": int64(-1 << 62),
+		"U64": uint64(18446744073709551615),
+		"R32": float32(1.5),
+		"R64": 2.25,
+	}
+
+	c, err := NewCimInstance("Test_Numeric", src)
+	require.NoError(t, err)
+	text, err := c.CimText()
+	require.NoError(t, err)
+
+	decoded, err := DecodeCimXml(text)
+	require.NoError(t, err)
+	instance := decoded.Instance()
+	for name, value := range src {
+		_, cimType, _, err := instance.GetAsAny(name)
+		require.NoError(t, err)
+		_, wantType, _ := toAutomationValue(value)
+		assert.Equal(t, wantType, cimType, name)
+	}
+
+	var dest struct {
+		S8  int8
+		U8  uint8
+		S16 int16
+		U16 uint16
+		S32 int32
+		U32 uint32
+		S64 int64
+		U64 uint64
+		R32 float32
+		R64 float64
+	}
+	require.NoError(t, instance.GetAll(&dest))
+	assert.Equal(t, int8(-8), dest.S8)
+	assert.Equal(t, uint8(200), dest.U8)
+	assert.Equal(t, int16(-1600), dest.S16)
+	assert.Equal(t, uint16(65535), dest.U16)
+	assert.Equal(t, int32(-320000), dest.S32)
+	assert.Equal(t, uint32(4294967295), dest.U32)
+	assert.Equal(t, int64(-1<<62), dest.S64)
+	assert.Equal(t, uint64(18446744073709551615), dest.U64)
+	assert.Equal(t, float32(1.5), dest.R32)
+	assert.Equal(t, 2.25, dest.R64)
+}
+
+func TestCimXmlReferencesAndEmbeddedObjects(t *testing.T) {
+	const systemPath = `\\HOST\root\virtualization\v2:Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B1"`
+
+	embedded, err := NewCimInstance("Msvm_ProcessorSettingData", map[string]interface{}{"VirtualQuantity": uint64(4)})
+	require.NoError(t, err)
+
+	c, err := NewCimInstance("Test_Association", map[string]interface{}{
+		"Antecedent": Reference(systemPath),
+		"Dependent":  Reference(`Msvm_VirtualSystemSettingData.InstanceID="Microsoft:A0B1"`),
+		"Setting":    embedded,
+		"Nothing":    []string{},
+	})
+	require.NoError(t, err)
+
+	text, err := c.CimText()
+	require.NoError(t, err)
+	assert.Contains(t, text, `<PROPERTY.REFERENCE NAME="Antecedent"><VALUE.REFERENCE><INSTANCEPATH><NAMESPACEPATH><HOST>HOST</HOST>`+
+		`<LOCALNAMESPACEPATH><NAMESPACE NAME="root"/><NAMESPACE NAME="virtualization"/><NAMESPACE NAME="v2"/></LOCALNAMESPACEPATH></NAMESPACEPATH>`+
+		`<INSTANCENAME CLASSNAME="Msvm_ComputerSystem"><KEYBINDING NAME="CreationClassName"><KEYVALUE VALUETYPE="string">Msvm_ComputerSystem</KEYVALUE></KEYBINDING>`+
+		`<KEYBINDING NAME="Name"><KEYVALUE VALUETYPE="string">A0B1</KEYVALUE></KEYBINDING></INSTANCENAME></INSTANCEPATH></VALUE.REFERENCE></PROPERTY.REFERENCE>`)
+	assert.Contains(t, text, `<PROPERTY NAME="Setting" TYPE="string" EmbeddedObject="object"><VALUE>&lt;INSTANCE CLASSNAME=&#34;Msvm_ProcessorSettingData&#34;&gt;`)
+	assert.Contains(t, text, `<PROPERTY.ARRAY NAME="Nothing" TYPE="string"></PROPERTY.ARRAY>`)
+
+	decoded, err := DecodeCimXml(text)
+	require.NoError(t, err)
+	instance := decoded.Instance()
+
+	antecedent, cimType, _, err := instance.GetAsAny("Antecedent")
+	require.NoError(t, err)
+	assert.Equal(t, CIM_REFERENCE, cimType)
+	assert.Equal(t, systemPath, antecedent)
+
+	dependent, err := instance.GetAsString("Dependent")
+	require.NoError(t, err)
+	assert.Equal(t, `Msvm_VirtualSystemSettingData.InstanceID="Microsoft:A0B1"`, dependent)
+
+	var dest struct {
+		Setting *struct {
+			VirtualQuantity uint64
+		}
+	}
+	require.NoError(t, instance.GetAll(&dest))
+	require.NotNil(t, dest.Setting)
+	assert.Equal(t, uint64(4), dest.Setting.VirtualQuantity)
+}
+
+func TestDecodeCimXmlErrors(t *testing.T) {
+	for _, text := range []string{
+		`<INSTANCE CLASSNAME="A"><PROPERTY NAME="x" TYPE="uint8"><VALUE>256</VALUE></PROPERTY></INSTANCE>`,
+		`<INSTANCE CLASSNAME="A"><PROPERTY NAME="x" TYPE="bogus"><VALUE>1</VALUE></PROPERTY></INSTANCE>`,
+		`<CLASS NAME="A"></CLASS>`,
+		`<INSTANCE CLASSNAME="A">`,
+	} {
+		_, err := DecodeCimXml(text)
+		assert.Error(t, err, text)
+	}
+}
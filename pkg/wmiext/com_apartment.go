@@ -0,0 +1,116 @@
+//go:build windows
+// +build windows
+
+package wmiext
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-ole/go-ole"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// DefaultComWorkers is the number of apartment workers serving NewLocalService.
+const DefaultComWorkers = 4
+
+// comApartment is the Executor initializing a single-threaded COM apartment on its worker, along
+// with the WMI locators used by the calls made from it.
+type comApartment struct {
+	threadID   uint32
+	locator    *ole.IUnknown
+	textSource *ole.IUnknown
+}
+
+var (
+	securityOnce sync.Once
+
+	apartmentsMu sync.RWMutex
+	apartments   = map[uint32]*comApartment{}
+
+	defaultDispatcherMu sync.Mutex
+	defaultDispatcher   *Dispatcher
+)
+
+// NewComDispatcher starts the specified number of workers, each running in its own COM apartment.
+func NewComDispatcher(workers int) (*Dispatcher, error) {
+	return NewDispatcher(workers, func(int) Executor {
+		return &comApartment{}
+	})
+}
+
+// ComBackendFactory returns a BackendFactory connecting to the local WMI service from a worker of
+// d, to which all calls on the backend are then dispatched.
+func ComBackendFactory(d *Dispatcher) BackendFactory {
+	return func(namespace string) (Backend, error) {
+		return d.Connect(context.Background(), connectComBackend, namespace)
+	}
+}
+
+func connectLocalBackend(namespace string) (Backend, error) {
+	defaultDispatcherMu.Lock()
+	if defaultDispatcher == nil {
+		d, err := NewComDispatcher(DefaultComWorkers)
+		if err != nil {
+			defaultDispatcherMu.Unlock()
+			return nil, err
+		}
+		defaultDispatcher = d
+	}
+	d := defaultDispatcher
+	defaultDispatcherMu.Unlock()
+
+	return ComBackendFactory(d)(namespace)
+}
+
+func (a *comApartment) Setup() (err error) {
+	if err = ole.CoInitializeEx(0, ole.COINIT_APARTMENTTHREADED); err != nil {
+		// S_FALSE when COM was already initialized on this thread
+		if oleErr, ok := err.(*ole.OleError); !ok || oleErr.Code() != 1 {
+			return errors.Wrap(err, "unable to initialize COM")
+		}
+	}
+	securityOnce.Do(initSecurity)
+
+	if a.locator, err = ole.CreateInstance(clsidWbemLocator, iidIWbemLocator); err != nil {
+		a.Teardown()
+		return errors.Wrap(err, "unable to create the WMI locator")
+	}
+	if a.textSource, err = ole.CreateInstance(clsidWbemObjectTextSrc, iidIWbemObjectTextSrc); err != nil {
+		a.Teardown()
+		return errors.Wrap(err, "unable to create the WMI text source")
+	}
+
+	a.threadID = windows.GetCurrentThreadId()
+	apartmentsMu.Lock()
+	apartments[a.threadID] = a
+	apartmentsMu.Unlock()
+	return nil
+}
+
+func (a *comApartment) Teardown() {
+	apartmentsMu.Lock()
+	delete(apartments, a.threadID)
+	apartmentsMu.Unlock()
+
+	if a.textSource != nil {
+		a.textSource.Release()
+		a.textSource = nil
+	}
+	if a.locator != nil {
+		a.locator.Release()
+		a.locator = nil
+	}
+	ole.CoUninitialize()
+}
+
+// currentApartment returns the apartment of the calling thread, which must be an apartment worker.
+func currentApartment() (*comApartment, error) {
+	apartmentsMu.RLock()
+	defer apartmentsMu.RUnlock()
+	if a, ok := apartments[windows.GetCurrentThreadId()]; ok {
+		return a, nil
+	}
+	return nil, errors.New("COM calls must be made from an apartment worker")
+}
@@ -0,0 +1,87 @@
+//go:build windows
+// +build windows
+
+package wmiext
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+type IEnumWbemClassObjectVtbl struct {
+	QueryInterface uintptr
+	AddRef         uintptr
+	Release        uintptr
+	Reset          uintptr
+	Next           uintptr
+	NextAsync      uintptr
+	Clone          uintptr
+	Skip           uintptr
+}
+
+// comEnum is the ObjectIterator wrapping an IEnumWbemClassObject.
+type comEnum struct {
+	enum   *ole.IUnknown
+	vTable *IEnumWbemClassObjectVtbl
+}
+
+func (e *comEnum) Release() {
+	if e != nil && e.enum != nil {
+		e.enum.Release()
+	}
+}
+
+func newComEnum(enumerator *ole.IUnknown) *comEnum {
+	return &comEnum{
+		enum:   enumerator,
+		vTable: (*IEnumWbemClassObjectVtbl)(unsafe.Pointer(enumerator.RawVTable)),
+	}
+}
+
+// Next returns the next object instance in this iteration
+func (e *comEnum) Next() (object Object, err error) {
+	return e.next(WBEM_INFINITE)
+}
+
+func (e *comEnum) next(timeout uint32) (object Object, err error) {
+	var res uintptr
+	var apObjects *ole.IUnknown
+	var uReturned uint32
+
+	res, _, _ = syscall.SyscallN(
+		e.vTable.Next,                       // IEnumWbemClassObject::Next()
+		uintptr(unsafe.Pointer(e.enum)),     // IEnumWbemClassObject   ptr
+		uintptr(timeout),                    // [in]  long             lTimeout,
+		uintptr(1),                          // [in]  ULONG            uCount,
+		uintptr(unsafe.Pointer(&apObjects)), // [out] IWbemClassObject **apObjects,
+		uintptr(unsafe.Pointer(&uReturned))) // [out] ULONG            *puReturned)
+	if int(res) < 0 {
+		return nil, NewWmiError(res)
+	}
+
+	if uReturned < 1 {
+		switch res {
+		case WBEM_S_NO_ERROR, WBEM_S_FALSE, WBEM_S_TIMEDOUT:
+			// No more elements, or none yet
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("failure advancing enumeration (%d)", res)
+		}
+	}
+
+	return newComObject(apObjects), nil
+}
+
+// comEvents is the EventIterator wrapping the IEnumWbemClassObject of a notification query.
+type comEvents struct {
+	*comEnum
+}
+
+// Next waits up to timeout for the next event, returning nil when none occurred in time.
+func (e comEvents) Next(timeout time.Duration) (Object, error) {
+	return e.next(uint32(timeout / time.Millisecond))
+}
@@ -0,0 +1,347 @@
+//go:build windows
+// +build windows
+
+package wmiext
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+type IWbemRefresherVtbl struct {
+	QueryInterface uintptr
+	AddRef         uintptr
+	Release        uintptr
+	Refresh        uintptr
+}
+
+type IWbemClassObjectVtbl struct {
+	QueryInterface          uintptr
+	AddRef                  uintptr
+	Release                 uintptr
+	GetQualifierSet         uintptr
+	Get                     uintptr
+	Put                     uintptr
+	Delete                  uintptr
+	GetNames                uintptr
+	BeginEnumeration        uintptr
+	Next                    uintptr
+	EndEnumeration          uintptr
+	GetPropertyQualifierSet uintptr
+	Clone                   uintptr
+	GetObjectText           uintptr
+	SpawnDerivedClass       uintptr
+	SpawnInstance           uintptr
+	CompareTo               uintptr
+	GetPropertyOrigin       uintptr
+	InheritsFrom            uintptr
+	GetMethod               uintptr
+	PutMethod               uintptr
+	DeleteMethod            uintptr
+	BeginMethodEnumeration  uintptr
+	NextMethod              uintptr
+	EndMethodEnumeration    uintptr
+	GetMethodQualifierSet   uintptr
+	GetMethodOrigin         uintptr
+}
+
+// comObject is the Object wrapping an IWbemClassObject.
+type comObject struct {
+	object *ole.IUnknown
+	vTable *IWbemClassObjectVtbl
+}
+
+func newComObject(object *ole.IUnknown) *comObject {
+	return &comObject{
+		object: object,
+		vTable: (*IWbemClassObjectVtbl)(unsafe.Pointer(object.RawVTable)),
+	}
+}
+
+// Release cleans up all memory associated with this object.
+func (o *comObject) Release() {
+	if o != nil && o.object != nil {
+		o.object.Release()
+	}
+}
+
+// SpawnInstance create a new WMI object instance that is zero-initialized. The returned instance
+// will not respect expected default values, which must be populated by other means.
+func (o *comObject) SpawnInstance() (Object, error) {
+	var res uintptr
+	var newUnknown *ole.IUnknown
+
+	res, _, _ = syscall.SyscallN(
+		o.vTable.SpawnInstance,               // IWbemClassObject::SpawnInstance(
+		uintptr(unsafe.Pointer(o.object)),    // IWbemClassObject ptr
+		uintptr(0),                           // [in]  long             lFlags,
+		uintptr(unsafe.Pointer(&newUnknown))) // [out] IWbemClassObject **ppNewInstance)
+	if res != 0 {
+		return nil, NewWmiError(res)
+	}
+
+	return newComObject(newUnknown), nil
+}
+
+// Clone create a new cloned copy of this WMI object.
+func (o *comObject) Clone() (Object, error) {
+	var cloned *ole.IUnknown
+
+	ret, _, _ := syscall.SyscallN(
+		o.vTable.Clone,                    // IWbemClassObject::Clone(
+		uintptr(unsafe.Pointer(o.object)), // IWbemClassObject ptr
+		uintptr(unsafe.Pointer(&cloned)))  // [out] IWbemClassObject **ppCopy)
+	if ret != 0 {
+		return nil, NewWmiError(ret)
+	}
+
+	return newComObject(cloned), nil
+}
+
+// Put sets the specified property to the passed Golang value, converting appropriately.
+func (o *comObject) Put(name string, value interface{}) (err error) {
+	var variant ole.VARIANT
+
+	switch cast := value.(type) {
+	case ole.VARIANT:
+		variant = cast
+	case *ole.VARIANT:
+		variant = *cast
+	default:
+		variant, err = NewAutomationVariant(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	var wszName *uint16
+	if wszName, err = syscall.UTF16PtrFromString(name); err != nil {
+		return
+	}
+
+	res, _, _ := syscall.SyscallN(
+		o.vTable.Put,                      // IWbemClassObject::Put(
+		uintptr(unsafe.Pointer(o.object)), // IWbemClassObject ptr
+		uintptr(unsafe.Pointer(wszName)),  // [in] LPCWSTR wszName,
+		uintptr(0),                        // [in] long    lFlags,
+		uintptr(unsafe.Pointer(&variant)), // [in] VARIANT *pVal,
+		uintptr(0))                        // [in] CIMTYPE Type)
+	if res != 0 {
+		return NewWmiError(res)
+	}
+
+	_ = variant.Clear()
+	return
+}
+
+// CimText returns the CIM XML representation of this object.
+func (o *comObject) CimText() (string, error) {
+	type wmiWbemTxtSrcVtable struct {
+		QueryInterface uintptr
+		AddRef         uintptr
+		Release        uintptr
+		GetTxt         uintptr
+	}
+	const CIM_XML_FORMAT = 1
+
+	apartment, err := currentApartment()
+	if err != nil {
+		return "", err
+	}
+
+	vTable := (*wmiWbemTxtSrcVtable)(unsafe.Pointer(apartment.textSource.RawVTable))
+	var retString *uint16
+	res, _, _ := syscall.SyscallN(
+		vTable.GetTxt, // IWbemObjectTextSrc::GetText()
+		uintptr(unsafe.Pointer(apartment.textSource)), // IWbemObjectTextSrc ptr
+		uintptr(0),                          // [in]  long             lFlags
+		uintptr(unsafe.Pointer(o.object)),   // [in]  IWbemClassObject *pObj
+		uintptr(CIM_XML_FORMAT),             // [in]  ULONG            uObjTextFormat,
+		uintptr(0),                          // [in]  IWbemContext     *pCtx,
+		uintptr(unsafe.Pointer(&retString))) // [out] BSTR             *strText)
+	if res != 0 {
+		return "", NewWmiError(res)
+	}
+	return ole.BstrToString(retString), nil
+}
+
+// Get obtains a specified property value, if it exists, converted to a Golang value that matches the
+// internal variant automation type.
+func (o *comObject) Get(name string) (interface{}, CIMTYPE_ENUMERATION, WBEM_FLAVOR_TYPE, error) {
+	variant, cimType, flavor, err := o.getAsVariant(name)
+	if err != nil {
+		return nil, cimType, flavor, err
+	}
+
+	defer func() {
+		if err := variant.Clear(); err != nil {
+			GetTelemetry().Log().Error("Unable to clear variant", "error", err)
+		}
+	}()
+
+	return convertToGenericValue(variant), cimType, flavor, nil
+}
+
+func (o *comObject) getAsVariant(name string) (*ole.VARIANT, CIMTYPE_ENUMERATION, WBEM_FLAVOR_TYPE, error) {
+	var variant ole.VARIANT
+	var err error
+	var wszName *uint16
+	var cimType CIMTYPE_ENUMERATION
+	var flavor WBEM_FLAVOR_TYPE
+
+	if wszName, err = syscall.UTF16PtrFromString(name); err != nil {
+		return nil, 0, 0, err
+	}
+
+	res, _, _ := syscall.SyscallN(
+		o.vTable.Get,                      // IWbemClassObject::Get(
+		uintptr(unsafe.Pointer(o.object)), // IWbemClassObject ptr
+		uintptr(unsafe.Pointer(wszName)),  // [in]            LPCWSTR wszName,
+		uintptr(0),                        // [in]            long    lFlags,
+		uintptr(unsafe.Pointer(&variant)), // [out]           VARIANT *pVal,
+		uintptr(unsafe.Pointer(&cimType)), // [out, optional] CIMTYPE *pType,
+		uintptr(unsafe.Pointer(&flavor)))  // [out, optional] long    *plFlavor)
+	if res != 0 {
+		return nil, 0, 0, NewWmiError(res)
+	}
+
+	return &variant, cimType, flavor, nil
+}
+
+// Properties gets all properties on this object, including system properties.
+func (o *comObject) Properties() ([]Property, error) {
+	var err error
+	var properties []Property
+
+	if err = o.beginEnumeration(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := o.endEnumeration(); err != nil {
+			GetTelemetry().Log().Error("Unable to end property enumeration", "error", err)
+		}
+	}()
+
+	for {
+		var done bool
+		var property Property
+
+		if done, property, err = o.next(); err != nil || done {
+			return properties, err
+		}
+
+		properties = append(properties, property)
+	}
+}
+
+func (o *comObject) next() (bool, Property, error) {
+	var res uintptr
+	var strName *uint16
+	var variant ole.VARIANT
+	var cimType CIMTYPE_ENUMERATION
+	var flavor WBEM_FLAVOR_TYPE
+
+	res, _, _ = syscall.SyscallN(
+		o.vTable.Next,                     // IWbemClassObject::Next(
+		uintptr(unsafe.Pointer(o.object)), // IWbemClassObject ptr
+		uintptr(0),                        // [in]            long    lFlags,
+		uintptr(unsafe.Pointer(&strName)), // [out]           BSTR    *strName,
+		uintptr(unsafe.Pointer(&variant)), // [out]           VARIANT *pVal,
+		uintptr(unsafe.Pointer(&cimType)), // [out, optional] CIMTYPE *pType,
+		uintptr(unsafe.Pointer(&flavor)))  // [out, optional] long    *plFlavor
+	if int(res) < 0 {
+		return false, Property{}, NewWmiError(res)
+	}
+
+	if res == WBEM_S_NO_MORE_DATA {
+		return true, Property{}, nil
+	}
+
+	defer ole.SysFreeString((*int16)(unsafe.Pointer(strName))) //nolint:errcheck
+	defer func() {
+		if err := variant.Clear(); err != nil {
+			GetTelemetry().Log().Error("Unable to clear variant", "error", err)
+		}
+	}()
+
+	return false, Property{
+		Name:    ole.BstrToString(strName),
+		Value:   convertToGenericValue(&variant),
+		CimType: cimType,
+		Flavor:  flavor,
+	}, nil
+}
+
+// MethodParameters returns a WMI class object which represents the [in] method parameters for a method invocation.
+func (o *comObject) MethodParameters(method string) (Object, error) {
+	var err error
+	var res uintptr
+	var inSignature *ole.IUnknown
+
+	var wszName *uint16
+	if wszName, err = syscall.UTF16PtrFromString(method); err != nil {
+		return nil, err
+	}
+
+	res, _, _ = syscall.SyscallN(
+		o.vTable.GetMethod,                    // IWbemClassObject::GetMethod(
+		uintptr(unsafe.Pointer(o.object)),     // IWbemClassObject ptr
+		uintptr(unsafe.Pointer(wszName)),      // [in]  LPCWSTR          wszName
+		uintptr(0),                            // [in]  long             lFlags,
+		uintptr(unsafe.Pointer(&inSignature)), // [out] IWbemClassObject **ppInSignature,
+		uintptr(0))                            // [out] IWbemClassObject **ppOutSignature)
+	if res != 0 {
+		return nil, NewWmiError(res)
+	}
+
+	if inSignature == nil {
+		// Methods without input parameters have no signature object
+		return nil, nil
+	}
+
+	return newComObject(inSignature), nil
+}
+
+func (o *comObject) beginEnumeration() error {
+	result, _, _ := syscall.SyscallN(
+		o.vTable.BeginEnumeration,         // IWbemClassObject::BeginEnumeration(
+		uintptr(unsafe.Pointer(o.object)), // IWbemClassObject ptr,
+		uintptr(0))                        // [in] long lEnumFlags) // 0 = defaults
+	if result != 0 {
+		return NewWmiError(result)
+	}
+
+	return nil
+}
+
+func (o *comObject) endEnumeration() error {
+	res, _, _ := syscall.SyscallN(
+		o.vTable.EndEnumeration,           // IWbemClassObject::EndEnumeration(
+		uintptr(unsafe.Pointer(o.object))) // IWbemClassObject ptr)
+	if res != 0 {
+		return NewWmiError(res)
+	}
+
+	return nil
+}
+
+// Refresh refreshes the object data from the WMI provider.
+// See https://learn.microsoft.com/en-us/windows/win32/api/wbemcli/nf-wbemcli-iwbemrefresher-refresh
+func (o *comObject) Refresh() error {
+	var hr uintptr
+
+	vTable := (*IWbemRefresherVtbl)(unsafe.Pointer(o.object.RawVTable))
+
+	if hr, _, _ = syscall.SyscallN(
+		vTable.Refresh,                    // IWbemRefresher::Refresh(
+		uintptr(unsafe.Pointer(o.object)), // IWbemRefresher ptr
+		// [in]  long             lFlags)
+	); hr != 0 {
+		return NewWmiError(hr)
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+//go:build windows
+// +build windows
+
+package wmiext
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+	"github.com/rokukoo/hyperv/pkg/wmiext/mof"
+)
+
+type IWbemQualifierSetVtbl struct {
+	QueryInterface   uintptr
+	AddRef           uintptr
+	Release          uintptr
+	Get              uintptr
+	Put              uintptr
+	Delete           uintptr
+	GetNames         uintptr
+	BeginEnumeration uintptr
+	Next             uintptr
+	EndEnumeration   uintptr
+}
+
+// describeClass describes a class object from its qualifier sets and method signatures.
+func (o *comObject) describeClass() (*ClassSchema, error) {
+	properties, err := o.Properties()
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &ClassSchema{Properties: []*PropertySchema{}}
+	if schema.Qualifiers, err = o.qualifiers(o.vTable.GetQualifierSet); err != nil {
+		return nil, err
+	}
+	for _, prop := range properties {
+		switch {
+		case strings.EqualFold(prop.Name, "__CLASS"):
+			schema.Name, _ = prop.Value.(string)
+		case strings.EqualFold(prop.Name, "__SUPERCLASS"):
+			schema.Superclass, _ = prop.Value.(string)
+		case !strings.HasPrefix(prop.Name, "__"):
+			property := &PropertySchema{Name: prop.Name, Type: prop.CimType}
+			if property.Qualifiers, err = o.qualifiers(o.vTable.GetPropertyQualifierSet, prop.Name); err != nil {
+				return nil, err
+			}
+			if property.Origin, err = o.origin(o.vTable.GetPropertyOrigin, prop.Name); err != nil {
+				return nil, err
+			}
+			schema.Properties = append(schema.Properties, property)
+		}
+	}
+
+	if schema.Methods, err = o.methods(); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// qualifiers reads the qualifier set returned by getter, IWbemClassObject::GetQualifierSet or one
+// of the Get*QualifierSet methods taking the name of a member.
+func (o *comObject) qualifiers(getter uintptr, member ...string) (mof.Qualifiers, error) {
+	var set *ole.IUnknown
+	args := []uintptr{uintptr(unsafe.Pointer(o.object))}
+	for _, name := range member {
+		wszName, err := syscall.UTF16PtrFromString(name)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, uintptr(unsafe.Pointer(wszName)))
+	}
+	args = append(args, uintptr(unsafe.Pointer(&set)))
+	if res, _, _ := syscall.SyscallN(getter, args...); res != 0 {
+		return nil, NewWmiError(res)
+	}
+	defer set.Release()
+
+	vTable := (*IWbemQualifierSetVtbl)(unsafe.Pointer(set.RawVTable))
+	if res, _, _ := syscall.SyscallN(
+		vTable.BeginEnumeration,      // IWbemQualifierSet::BeginEnumeration(
+		uintptr(unsafe.Pointer(set)), // IWbemQualifierSet ptr
+		uintptr(0)); res != 0 {       // [in] long lFlags)
+		return nil, NewWmiError(res)
+	}
+	defer syscall.SyscallN(vTable.EndEnumeration, uintptr(unsafe.Pointer(set))) //nolint:errcheck
+
+	var qualifiers mof.Qualifiers
+	for {
+		var strName *uint16
+		var variant ole.VARIANT
+		var flavor WBEM_FLAVOR_TYPE
+		res, _, _ := syscall.SyscallN(
+			vTable.Next,                       // IWbemQualifierSet::Next(
+			uintptr(unsafe.Pointer(set)),      // IWbemQualifierSet ptr
+			uintptr(0),                        // [in]  long    lFlags,
+			uintptr(unsafe.Pointer(&strName)), // [out] BSTR    *pstrName,
+			uintptr(unsafe.Pointer(&variant)), // [out] VARIANT *pVal,
+			uintptr(unsafe.Pointer(&flavor)))  // [out] long    *plFlavor)
+		if int(res) < 0 {
+			return nil, NewWmiError(res)
+		}
+		if res == WBEM_S_NO_MORE_DATA {
+			return qualifiers, nil
+		}
+		qualifiers = append(qualifiers, mof.Qualifier{Name: ole.BstrToString(strName), Value: convertToGenericValue(&variant)})
+		ole.SysFreeString((*int16)(unsafe.Pointer(strName))) //nolint:errcheck
+		_ = variant.Clear()
+	}
+}
+
+// origin returns the class declaring a member, using GetPropertyOrigin or GetMethodOrigin.
+func (o *comObject) origin(getter uintptr, name string) (string, error) {
+	wszName, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return "", err
+	}
+	var strClassName *uint16
+	res, _, _ := syscall.SyscallN(
+		getter,                                 // IWbemClassObject::Get*Origin(
+		uintptr(unsafe.Pointer(o.object)),      // IWbemClassObject ptr
+		uintptr(unsafe.Pointer(wszName)),       // [in]  LPCWSTR wszName,
+		uintptr(unsafe.Pointer(&strClassName))) // [out] BSTR    *pstrClassName)
+	if res != 0 {
+		return "", NewWmiError(res)
+	}
+	defer ole.SysFreeString((*int16)(unsafe.Pointer(strClassName))) //nolint:errcheck
+	return ole.BstrToString(strClassName), nil
+}
+
+func (o *comObject) methods() ([]*MethodSchema, error) {
+	if res, _, _ := syscall.SyscallN(
+		o.vTable.BeginMethodEnumeration,   // IWbemClassObject::BeginMethodEnumeration(
+		uintptr(unsafe.Pointer(o.object)), // IWbemClassObject ptr
+		uintptr(0)); res != 0 {            // [in] long lEnumFlags)
+		return nil, NewWmiError(res)
+	}
+	defer syscall.SyscallN(o.vTable.EndMethodEnumeration, uintptr(unsafe.Pointer(o.object))) //nolint:errcheck
+
+	var methods []*MethodSchema
+	for {
+		var strName *uint16
+		var inSignature, outSignature *ole.IUnknown
+		res, _, _ := syscall.SyscallN(
+			o.vTable.NextMethod,                    // IWbemClassObject::NextMethod(
+			uintptr(unsafe.Pointer(o.object)),      // IWbemClassObject ptr
+			uintptr(0),                             // [in]  long             lFlags,
+			uintptr(unsafe.Pointer(&strName)),      // [out] BSTR             *pstrName,
+			uintptr(unsafe.Pointer(&inSignature)),  // [out] IWbemClassObject **ppInSignature,
+			uintptr(unsafe.Pointer(&outSignature))) // [out] IWbemClassObject **ppOutSignature)
+		if int(res) < 0 {
+			return nil, NewWmiError(res)
+		}
+		if res == WBEM_S_NO_MORE_DATA {
+			return methods, nil
+		}
+		name := ole.BstrToString(strName)
+		ole.SysFreeString((*int16)(unsafe.Pointer(strName))) //nolint:errcheck
+
+		method, err := o.method(name, inSignature, outSignature)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+}
+
+// method describes a method from its signature objects, which it releases.
+func (o *comObject) method(name string, inSignature *ole.IUnknown, outSignature *ole.IUnknown) (*MethodSchema, error) {
+	// The signatures of the inputs and of the outputs, in that order
+	signatures := make([]*comObject, 2)
+	for i, signature := range []*ole.IUnknown{inSignature, outSignature} {
+		if signature != nil {
+			signatures[i] = newComObject(signature)
+			defer signatures[i].Release()
+		}
+	}
+
+	method := &MethodSchema{Name: name}
+	var err error
+	if method.Qualifiers, err = o.qualifiers(o.vTable.GetMethodQualifierSet, name); err != nil {
+		return nil, err
+	}
+	if method.Origin, err = o.origin(o.vTable.GetMethodOrigin, name); err != nil {
+		return nil, err
+	}
+
+	parameters := make(map[string]*ParameterSchema)
+	for i, object := range signatures {
+		if object == nil {
+			continue
+		}
+		out := i == 1
+		properties, err := object.Properties()
+		if err != nil {
+			return nil, err
+		}
+		for _, prop := range properties {
+			if strings.HasPrefix(prop.Name, "__") {
+				continue
+			}
+			if out && strings.EqualFold(prop.Name, "ReturnValue") {
+				method.ReturnType = prop.CimType
+				continue
+			}
+			parameter, ok := parameters[strings.ToLower(prop.Name)]
+			if !ok {
+				parameter = &ParameterSchema{Name: prop.Name, Type: prop.CimType}
+				if parameter.Qualifiers, err = object.qualifiers(object.vTable.GetPropertyQualifierSet, prop.Name); err != nil {
+					return nil, err
+				}
+				parameters[strings.ToLower(prop.Name)] = parameter
+				method.Parameters = append(method.Parameters, parameter)
+			}
+			if out {
+				parameter.Out = true
+			} else {
+				parameter.In = true
+			}
+		}
+	}
+	sortParameters(method.Parameters)
+	return method, nil
+}
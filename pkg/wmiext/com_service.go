@@ -0,0 +1,312 @@
+//go:build windows
+// +build windows
+
+package wmiext
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+type IWbemLocatorVtbl struct {
+	QueryInterface uintptr
+	AddRef         uintptr
+	Release        uintptr
+	ConnectServer  uintptr
+}
+
+// comBackend is the Backend talking to the WMI service through COM.
+type comBackend struct {
+	service *ole.IUnknown
+	vTable  *IWbemServicesVtbl
+}
+
+type IWbemServicesVtbl struct {
+	QueryInterface             uintptr
+	AddRef                     uintptr
+	Release                    uintptr
+	OpenNamespace              uintptr
+	CancelAsyncCall            uintptr
+	QueryObjectSink            uintptr
+	GetObject                  uintptr
+	GetObjectAsync             uintptr
+	PutClass                   uintptr
+	PutClassAsync              uintptr
+	DeleteClass                uintptr
+	DeleteClassAsync           uintptr
+	CreateClassEnum            uintptr
+	CreateClassEnumAsync       uintptr
+	PutInstance                uintptr
+	PutInstanceAsync           uintptr
+	DeleteInstance             uintptr
+	DeleteInstanceAsync        uintptr
+	CreateInstanceEnum         uintptr
+	CreateInstanceEnumAsync    uintptr
+	ExecQuery                  uintptr
+	ExecQueryAsync             uintptr
+	ExecNotificationQuery      uintptr
+	ExecNotificationQueryAsync uintptr
+	ExecMethod                 uintptr
+	ExecMethodAsync            uintptr
+}
+
+// connectComBackend connects to the local WMI service, from the apartment of the calling worker.
+func connectComBackend(namespace string) (Backend, error) {
+	apartment, err := currentApartment()
+	if err != nil {
+		return nil, err
+	}
+
+	var res uintptr
+	var strResource *uint16
+	var strLocale *uint16
+	var service *ole.IUnknown
+
+	loc := fmt.Sprintf(`\\.\%s`, namespace)
+
+	if strResource, err = syscall.UTF16PtrFromString(loc); err != nil {
+		return nil, err
+	}
+
+	// ConnectByName with en_US LCID since we do pattern matching against English key values
+	if strLocale, err = syscall.UTF16PtrFromString("MS_409"); err != nil {
+		return nil, err
+	}
+
+	myVTable := (*IWbemLocatorVtbl)(unsafe.Pointer(apartment.locator.RawVTable))
+	res, _, _ = syscall.SyscallN(
+		myVTable.ConnectServer,                     // IWbemLocator::ConnectServer(
+		uintptr(unsafe.Pointer(apartment.locator)), // IWbemLocator ptr
+		uintptr(unsafe.Pointer(strResource)),       // [in]  const BSTR    strNetworkResource,
+		uintptr(0),                                 // [in]  const BSTR    strUser,
+		uintptr(0),                                 // [in]  const BSTR    strPassword,
+		uintptr(unsafe.Pointer(strLocale)),         // [in]  const BSTR    strLocale,
+		uintptr(WBEM_FLAG_CONNECT_USE_MAX_WAIT),    // [in]  long          lSecurityFlags,
+		uintptr(0),                                 // [in]  const BSTR    strAuthority,
+		uintptr(0),                                 // [in]  IWbemContext  *pCtx,
+		uintptr(unsafe.Pointer(&service)))          // [out] IWbemServices **ppNamespace)
+
+	if res != 0 {
+		return nil, NewWmiError(res)
+	}
+
+	if err = CoSetProxyBlanket(service); err != nil {
+		return nil, err
+	}
+
+	return &comBackend{
+		service: service,
+		vTable:  (*IWbemServicesVtbl)(unsafe.Pointer(service.RawVTable)),
+	}, nil
+}
+
+const (
+	WBEM_FLAG_CONNECT_USE_MAX_WAIT = 0x80
+)
+
+func CoSetProxyBlanket(service *ole.IUnknown) (err error) {
+	res, _, _ := procCoSetProxyBlanket.Call( //CoSetProxyBlanket(
+		uintptr(unsafe.Pointer(service)),     // [in]      IUnknown                 *pProxy,
+		uintptr(RPC_C_AUTHN_WINNT),           // [in]      DWORD                    dwAuthnSvc,
+		uintptr(RPC_C_AUTHZ_NONE),            // [in]      DWORD                    dwAuthzSvc,
+		uintptr(0),                           // [in, opt] OLECHAR                  *pServerPrincName,
+		uintptr(RPC_C_AUTHN_LEVEL_CALL),      // [in]      DWORD                    dwAuthnLevel,
+		uintptr(RPC_C_IMP_LEVEL_IMPERSONATE), // [in]      DWORD                    dwImpLevel,
+		uintptr(0),                           // [in, opt] RPC_AUTH_IDENTITY_HANDLE pAuthInfo,
+		uintptr(EOAC_NONE))                   // [in]      DWORD                    dwCapabilities)
+
+	if res != 0 {
+		return NewWmiError(res)
+	}
+
+	return nil
+}
+
+// Close releases the IWbemServices proxy
+func (s *comBackend) Close() {
+	if s != nil && s.service != nil {
+		s.service.Release()
+	}
+}
+
+// ExecQuery executes a WQL query and returns an enumeration to iterate the result set.
+// Queries are executed in a semi-synchronous fashion.
+func (s *comBackend) ExecQuery(wqlQuery string) (ObjectIterator, error) {
+	var err error
+	var pEnum *ole.IUnknown
+	var strQuery *uint16
+	var strQL *uint16
+
+	if strQL, err = syscall.UTF16PtrFromString("WQL"); err != nil {
+		return nil, err
+	}
+
+	if strQuery, err = syscall.UTF16PtrFromString(wqlQuery); err != nil {
+		return nil, err
+	}
+
+	// Semisynchronous mode = return immed + forward (for perf)
+	flags := WBEM_FLAG_FORWARD_ONLY | WBEM_FLAG_RETURN_IMMEDIATELY
+
+	hres, _, _ := syscall.SyscallN(
+		s.vTable.ExecQuery,                 // IWbemServices::ExecQuery(
+		uintptr(unsafe.Pointer(s.service)), // IWbemServices ptr
+		uintptr(unsafe.Pointer(strQL)),     // [in] const BSTR           strQueryLanguage,
+		uintptr(unsafe.Pointer(strQuery)),  // [in] const BSTR           strQuery,
+		uintptr(flags),                     // [in] long                 lFlags,
+		uintptr(0),                         // [in] IWbemContext         *pCtx,
+		uintptr(unsafe.Pointer(&pEnum)))    // [out] IEnumWbemClassObject **ppEnum)
+	if hres != 0 {
+		return nil, NewWmiError(hres)
+	}
+
+	if err = CoSetProxyBlanket(pEnum); err != nil {
+		return nil, err
+	}
+
+	return newComEnum(pEnum), nil
+}
+
+// GetObject obtains a single WMI class or instance given its path
+func (s *comBackend) GetObject(objectPath string) (object Object, err error) {
+	var pObject *ole.IUnknown
+	var strObjectPath *uint16
+
+	if strObjectPath, err = syscall.UTF16PtrFromString(objectPath); err != nil {
+		return
+	}
+
+	// Synchronous call
+	flags := WBEM_FLAG_RETURN_WBEM_COMPLETE
+
+	res, _, _ := syscall.SyscallN(
+		s.vTable.GetObject,                     // IWbemServices::GetObject(
+		uintptr(unsafe.Pointer(s.service)),     // IWbemServices ptr
+		uintptr(unsafe.Pointer(strObjectPath)), // [in]  const BSTR       strObjectPath,
+		uintptr(flags),                         // [in]  long             lFlags,
+		uintptr(0),                             // [in]  IWbemContext     *pCtx,
+		uintptr(unsafe.Pointer(&pObject)),      // [out] IWbemClassObject **ppObject,
+		uintptr(0))                             // [out] IWbemCallResult  **ppCallResult)
+	if int(res) < 0 {
+		// returns WBEM_E_PROVIDER_NOT_FOUND when no entry found
+		return nil, NewWmiError(res)
+	}
+
+	return newComObject(pObject), nil
+}
+
+// CreateInstanceEnum creates an enumerator that iterates all registered object instances for a given className.
+func (s *comBackend) CreateInstanceEnum(className string) (ObjectIterator, error) {
+	var err error
+	var pEnum *ole.IUnknown
+	var strFilter *uint16
+
+	if strFilter, err = syscall.UTF16PtrFromString(className); err != nil {
+		return nil, err
+	}
+
+	// No subclasses in result set
+	flags := WBEM_FLAG_SHALLOW
+
+	res, _, _ := syscall.SyscallN(
+		s.vTable.CreateInstanceEnum,        // IWbemServices::CreateInstanceEnum(
+		uintptr(unsafe.Pointer(s.service)), // IWbemServices ptr
+		uintptr(unsafe.Pointer(strFilter)), // [in]  const BSTR           strFilter,
+		uintptr(flags),                     // [in]  long                 lFlags,
+		uintptr(0),                         // [in]  IWbemContext         *pCtx,
+		uintptr(unsafe.Pointer(&pEnum)))    // [out] IEnumWbemClassObject **ppEnum)
+	if int(res) < 0 {
+		return nil, NewWmiError(res)
+	}
+
+	if err = CoSetProxyBlanket(pEnum); err != nil {
+		return nil, err
+	}
+
+	return newComEnum(pEnum), nil
+}
+
+// ExecMethod executes a method using the specified class and parameter payload instance. The parameter payload
+// instance can be constructed using Instance.GetMethodParameters(). This is an advanced method, it is
+// recommended to use Method() instead, where possible.
+func (s *comBackend) ExecMethod(className string, methodName string, inParams Object) (Object, error) {
+	var err error
+	var outParams *ole.IUnknown
+	var strObjectPath *uint16
+	var strMethodName *uint16
+
+	if strObjectPath, err = syscall.UTF16PtrFromString(className); err != nil {
+		return nil, err
+	}
+
+	if strMethodName, err = syscall.UTF16PtrFromString(methodName); err != nil {
+		return nil, err
+	}
+
+	var pInParams *ole.IUnknown
+	if inParams != nil {
+		in, ok := inParams.(*comObject)
+		if !ok {
+			return nil, fmt.Errorf("in parameters of type %T can not be sent through COM", inParams)
+		}
+		pInParams = in.object
+	}
+
+	res, _, _ := syscall.SyscallN(
+		s.vTable.ExecMethod,                    // IWbemServices::ExecMethod(
+		uintptr(unsafe.Pointer(s.service)),     // IWbemServices ptr
+		uintptr(unsafe.Pointer(strObjectPath)), // [in]  const BSTR       strObjectPath,
+		uintptr(unsafe.Pointer(strMethodName)), // [in]  const BSTR       strMethodName,
+		uintptr(0),                             // [in]  long             lFlags,
+		uintptr(0),                             // [in]  IWbemContext     *pCtx,
+		uintptr(unsafe.Pointer(pInParams)),     // [in]  IWbemClassObject *pInParams,
+		uintptr(unsafe.Pointer(&outParams)),    // [out] IWbemClassObject **ppOutParams,
+		uintptr(0))                             // [out] IWbemCallResult  **ppCallResult)
+	if int(res) < 0 {
+		return nil, NewWmiError(res)
+	}
+
+	return newComObject(outParams), nil
+}
+
+// ExecNotificationQuery subscribes to the events matching a WQL event query. Events are then
+// retrieved in a semi-synchronous fashion.
+func (s *comBackend) ExecNotificationQuery(wqlQuery string) (EventIterator, error) {
+	var err error
+	var pEnum *ole.IUnknown
+	var strQuery *uint16
+	var strQL *uint16
+
+	if strQL, err = syscall.UTF16PtrFromString("WQL"); err != nil {
+		return nil, err
+	}
+
+	if strQuery, err = syscall.UTF16PtrFromString(wqlQuery); err != nil {
+		return nil, err
+	}
+
+	// The only flags supported by notification queries
+	flags := WBEM_FLAG_FORWARD_ONLY | WBEM_FLAG_RETURN_IMMEDIATELY
+
+	hres, _, _ := syscall.SyscallN(
+		s.vTable.ExecNotificationQuery,     // IWbemServices::ExecNotificationQuery(
+		uintptr(unsafe.Pointer(s.service)), // IWbemServices ptr
+		uintptr(unsafe.Pointer(strQL)),     // [in] const BSTR           strQueryLanguage,
+		uintptr(unsafe.Pointer(strQuery)),  // [in] const BSTR           strQuery,
+		uintptr(flags),                     // [in] long                 lFlags,
+		uintptr(0),                         // [in] IWbemContext         *pCtx,
+		uintptr(unsafe.Pointer(&pEnum)))    // [out] IEnumWbemClassObject **ppEnum)
+	if hres != 0 {
+		return nil, NewWmiError(hres)
+	}
+
+	if err = CoSetProxyBlanket(pEnum); err != nil {
+		pEnum.Release()
+		return nil, err
+	}
+
+	return comEvents{newComEnum(pEnum)}, nil
+}
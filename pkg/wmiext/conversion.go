@@ -1,356 +1,239 @@
-//go:build windows
-// +build windows
-
 package wmiext
 
 import (
 	"errors"
 	"fmt"
-	"math"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
-	"unsafe"
-
-	"github.com/go-ole/go-ole"
 )
 
 var (
 	unixEpoch = time.Unix(0, 0)
 	zeroTime  = time.Time{}
-)
 
-// Automation variants do not follow the OLE rules, instead they use the following mapping:
-// sint8	VT_I2	Signed 8-bit integer.
-// sint16	VT_I2	Signed 16-bit integer.
-// sint32	VT_I4	Signed 32-bit integer.
-// sint64	VT_BSTR	Signed 64-bit integer in string form. This type follows hexadecimal or decimal format
-//
-//	according to the American National Standards Institute (ANSI) C rules.
-//
-// real32	VT_R4	4-byte floating-point value that follows the Institute of Electrical and Electronics
-//
-//	Engineers, Inc. (IEEE) standard.
-//
-// real64	VT_R8	8-byte floating-point value that follows the IEEE standard.
-// uint8	VT_UI1	Unsigned 8-bit integer.
-// uint16	VT_I4	Unsigned 16-bit integer.
-// uint32	VT_I4	Unsigned 32-bit integer.
-// uint64	VT_BSTR	Unsigned 64-bit integer in string form. This type follows hexadecimal or decimal format
-//
-//	according to ANSI C rules.
+	instanceType = reflect.TypeOf((*Instance)(nil))
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
 
-// NewAutomationVariant returns a new VARIANT com
+// convertToGoType converts a value obtained from an Object into the Golang type of the output value.
+// Values use the automation mapping of WMI, so 64-bit integers and datetime values arrive in string form.
 //
 //gocyclo:ignore
-func NewAutomationVariant(value interface{}) (ole.VARIANT, error) {
-	switch cast := value.(type) {
-	case bool:
-		if cast {
-			return ole.NewVariant(ole.VT_BOOL, 0xffff), nil
-		} else {
-			return ole.NewVariant(ole.VT_BOOL, 0), nil
-		}
-	case int8:
-		return ole.NewVariant(ole.VT_I2, int64(cast)), nil
-	case []int8:
-		return CreateNumericArrayVariant(cast, ole.VT_I2)
-	case int16:
-		return ole.NewVariant(ole.VT_I2, int64(cast)), nil
-	case []int16:
-		return CreateNumericArrayVariant(cast, ole.VT_I2)
-	case int32:
-		return ole.NewVariant(ole.VT_I4, int64(cast)), nil
-	case []int32:
-		return CreateNumericArrayVariant(cast, ole.VT_I4)
-	case int64:
-		s := fmt.Sprintf("%d", cast)
-		return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(s))))), nil
-	case []int64:
-		strs := make([]string, len(cast))
-		for i, num := range cast {
-			strs[i] = fmt.Sprintf("%d", num)
-		}
-		return CreateStringArrayVariant(strs)
-	case float32:
-		return ole.NewVariant(ole.VT_R4, int64(math.Float32bits(cast))), nil
-	case float64:
-		return ole.NewVariant(ole.VT_R8, int64(math.Float64bits(cast))), nil
-	case uint8:
-		return ole.NewVariant(ole.VT_UI1, int64(cast)), nil
-	case []uint8:
-		return CreateNumericArrayVariant(cast, ole.VT_UI1)
-	case uint16:
-		return ole.NewVariant(ole.VT_I4, int64(cast)), nil
-	case []uint16:
-		return CreateNumericArrayVariant(cast, ole.VT_I4)
-	case uint32:
-		return ole.NewVariant(ole.VT_I4, int64(cast)), nil
-	case []uint32:
-		return CreateNumericArrayVariant(cast, ole.VT_I4)
-	case uint64:
-		s := fmt.Sprintf("%d", cast)
-		return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(s))))), nil
-	case []uint64:
-		strs := make([]string, len(cast))
-		for i, num := range cast {
-			strs[i] = fmt.Sprintf("%d", num)
-		}
-		return CreateStringArrayVariant(strs)
-
-	// Assume 32 bit for generic (u)ints
-	case int:
-		return ole.NewVariant(ole.VT_I4, int64(cast)), nil
-	case uint:
-		return ole.NewVariant(ole.VT_I4, int64(cast)), nil
-	case []int:
-		return CreateNumericArrayVariant(cast, ole.VT_I4)
-	case []uint:
-		return CreateNumericArrayVariant(cast, ole.VT_I4)
-
-	case string:
-		return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(value.(string)))))), nil
-	case []string:
-		if len(cast) == 0 {
-			return ole.NewVariant(ole.VT_NULL, 0), nil
-		}
-		return CreateStringArrayVariant(cast)
-
-	case time.Time:
-		return convertTimeToDataTime(&cast), nil
-	case *time.Time:
-		return convertTimeToDataTime(cast), nil
-	case time.Duration:
-		return convertDurationToDateTime(cast), nil
-	case nil:
-		return ole.NewVariant(ole.VT_NULL, 0), nil
-	case *ole.IUnknown:
-		if cast == nil {
-			return ole.NewVariant(ole.VT_NULL, 0), nil
-		}
-		return ole.NewVariant(ole.VT_UNKNOWN, int64(uintptr(unsafe.Pointer(cast)))), nil
-	case *Instance:
-		if cast == nil {
-			return ole.NewVariant(ole.VT_NULL, 0), nil
-		}
-		return ole.NewVariant(ole.VT_UNKNOWN, int64(uintptr(unsafe.Pointer(cast.object)))), nil
-	default:
-		return ole.VARIANT{}, fmt.Errorf("unsupported type for automation variants %T", value)
+func convertToGoType(value interface{}, outputValue reflect.Value, outputType reflect.Type, service *Service) (interface{}, error) {
+	if value == nil {
+		return nil, nil
 	}
-}
 
-func convertToGoType(variant *ole.VARIANT, outputValue reflect.Value, outputType reflect.Type) (value interface{}, err error) {
-	if variant.VT&ole.VT_ARRAY == ole.VT_ARRAY {
-		return convertVariantToArray(variant, outputType)
+	if object, ok := value.(Object); ok {
+		return convertObjectToStruct(object, outputType, service)
 	}
 
-	if variant.VT == ole.VT_UNKNOWN {
-		return convertVariantToStruct(variant, outputType)
+	if outputType.Kind() == reflect.Slice {
+		return convertToArray(value, outputType, service)
 	}
 
-	switch cast := outputValue.Interface().(type) {
-	case bool:
-		return variant.Val != 0, nil
-	case time.Time:
-		return convertDataTimeToTime(variant)
-	case *time.Time:
-		x, err := convertDataTimeToTime(variant)
+	switch outputType {
+	case timeType:
+		return convertDataTimeToTime(value)
+	case reflect.PointerTo(timeType):
+		x, err := convertDataTimeToTime(value)
 		return &x, err
-	case time.Duration:
-		return convertIntervalToDuration(variant)
-	case uint, uint8, uint16, uint32, uint64, int, int8, int16, int32, int64:
-		return convertVariantToInt(variant, outputType)
-	case float32, float64:
-		return convertVariantToFloat(variant, outputType)
-	case string:
-		return variant.ToString(), nil
-	default:
-		if variant.VT == ole.VT_NULL {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("could not convert %d to %v", variant.VT, cast)
+	case durationType:
+		return convertIntervalToDuration(value)
 	}
-}
 
-func convertInt64ToInt(value int64, outputType reflect.Type) (interface{}, error) {
 	switch outputType.Kind() {
-	case reflect.Int:
-		return int(value), nil
-	case reflect.Int8:
-		return int8(value), nil
-	case reflect.Int16:
-		return int16(value), nil
-	case reflect.Int32:
-		return int32(value), nil
-	case reflect.Int64:
-		return int64(value), nil
-	case reflect.Uint:
-		return uint(value), nil
-	case reflect.Uint8:
-		return uint8(value), nil
-	case reflect.Uint16:
-		return uint16(value), nil
-	case reflect.Uint32:
-		return uint32(value), nil
-	case reflect.Uint64:
-		return uint64(value), nil
+	case reflect.Bool:
+		b, err := convertToBool(value)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(b).Convert(outputType).Interface(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return convertToInt(value, outputType)
+	case reflect.Float32, reflect.Float64:
+		return convertToFloat(value, outputType)
+	case reflect.String:
+		return reflect.ValueOf(convertToString(value)).Convert(outputType).Interface(), nil
+	case reflect.Interface:
+		return value, nil
 	default:
-		return 0, fmt.Errorf("could not convert int64 to %v", outputType)
+		return nil, fmt.Errorf("could not convert %T to %v", value, outputValue.Type())
 	}
 }
 
-func convertStringToInt64(str string, unsigned bool) (int64, error) {
-	if unsigned {
-		val, err := strconv.ParseUint(str, 0, 64)
-		return int64(val), err
+func convertObjectToStruct(object Object, outputType reflect.Type, service *Service) (interface{}, error) {
+	if outputType == instanceType {
+		return newInstance(object, service), nil
 	}
 
-	return strconv.ParseInt(str, 0, 64)
+	instance := newInstance(object, service)
+	switch {
+	case outputType.Kind() == reflect.Struct:
+		val := reflect.New(outputType)
+		err := instance.GetAll(val.Interface())
+		return val.Elem().Interface(), err
+	case outputType.Kind() == reflect.Pointer && outputType.Elem().Kind() == reflect.Struct:
+		val := reflect.New(outputType.Elem())
+		err := instance.GetAll(val.Interface())
+		return val.Interface(), err
+	case outputType.Kind() == reflect.String:
+		text, err := object.CimText()
+		return reflect.ValueOf(text).Convert(outputType).Interface(), err
+	default:
+		return nil, fmt.Errorf("could not convert embedded object to %v", outputType)
+	}
 }
 
-func convertVariantToInt(variant *ole.VARIANT, outputType reflect.Type) (interface{}, error) {
-	var value int64
-	switch variant.VT {
-	case ole.VT_NULL:
-		fallthrough
-	case ole.VT_BOOL:
-		fallthrough
-	case ole.VT_I1, ole.VT_I2, ole.VT_I4, ole.VT_I8, ole.VT_INT:
-		fallthrough
-	case ole.VT_UI1, ole.VT_UI2, ole.VT_UI4, ole.VT_UI8, ole.VT_UINT:
-		value = variant.Val
-	case ole.VT_R4:
-		// not necessarily a useful conversion but handle it anyway
-		value = int64(*(*float32)(unsafe.Pointer(&variant.Val)))
-	case ole.VT_R8:
-		value = int64(*(*float64)(unsafe.Pointer(&variant.Val)))
-	case ole.VT_BSTR:
-		var err error
-		value, err = convertStringToInt64(variant.ToString(), outputType.Kind() == reflect.Uint64)
-		if err != nil {
-			return value, err
-		}
-	default:
-		return nil, fmt.Errorf("could not convert variant type %d to %v", variant.VT, outputType)
+func convertToArray(value interface{}, outputType reflect.Type, service *Service) (interface{}, error) {
+	src := reflect.ValueOf(value)
+	if src.Kind() != reflect.Slice && src.Kind() != reflect.Array {
+		return nil, fmt.Errorf("could not convert non-array value %T to %v", value, outputType)
 	}
 
-	return convertInt64ToInt(value, outputType)
-}
+	if outputType.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("could not convert array value %T to %v", value, outputType)
+	}
 
-func convertVariantToFloat(variant *ole.VARIANT, outputType reflect.Type) (interface{}, error) {
-	var value float64
-	switch variant.VT {
-	case ole.VT_NULL:
-		fallthrough
-	case ole.VT_BOOL:
-		fallthrough
-	case ole.VT_I1, ole.VT_I2, ole.VT_I4, ole.VT_I8, ole.VT_INT:
-		fallthrough
-	case ole.VT_UI1, ole.VT_UI2, ole.VT_UI4, ole.VT_UI8, ole.VT_UINT:
-		value = float64(variant.Val)
-	case ole.VT_R4:
-		value = float64(*(*float32)(unsafe.Pointer(&variant.Val)))
-	case ole.VT_R8:
-		value = *(*float64)(unsafe.Pointer(&variant.Val))
-	case ole.VT_BSTR:
-		var err error
-		value, err = strconv.ParseFloat(variant.ToString(), 64)
+	slice := reflect.MakeSlice(outputType, src.Len(), src.Len())
+	for i := 0; i < src.Len(); i++ {
+		elemDest, err := convertToGoType(src.Index(i).Interface(), slice.Index(i), outputType.Elem(), service)
 		if err != nil {
-			return value, err
+			return nil, err
 		}
-	default:
-		return nil, fmt.Errorf("could not convert variant type %d to %v", variant.VT, outputType)
-	}
 
-	if outputType.Kind() == reflect.Float32 {
-		return float32(value), nil
+		if elemDest != nil {
+			slice.Index(i).Set(reflect.ValueOf(elemDest))
+		}
 	}
 
-	return value, nil
+	return slice.Interface(), nil
 }
 
-func convertVariantToStruct(variant *ole.VARIANT, outputType reflect.Type) (interface{}, error) {
-	if variant.VT != ole.VT_UNKNOWN {
-		return nil, fmt.Errorf("could not convert non-IUnknown variant type %d to %v", variant.VT, outputType)
+func convertToBool(value interface{}) (bool, error) {
+	switch cast := value.(type) {
+	case bool:
+		return cast, nil
+	case string:
+		return strconv.ParseBool(cast)
 	}
 
-	ptr := variant.ToIUnknown()
-
-	var rawInstance struct {
-		*ole.IUnknown
-		*IWbemClassObjectVtbl
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int() != 0, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return val.Uint() != 0, nil
+	default:
+		return false, fmt.Errorf("could not convert %T to bool", value)
 	}
+}
 
-	rawInstance.IUnknown = ptr
-	rawInstance.IWbemClassObjectVtbl = (*IWbemClassObjectVtbl)(unsafe.Pointer(ptr.RawVTable))
+func convertStringToInt64(str string, unsigned bool) (int64, error) {
+	if unsigned {
+		val, err := strconv.ParseUint(str, 0, 64)
+		return int64(val), err
+	}
 
-	instance := (*Instance)(unsafe.Pointer(&rawInstance))
-	val := reflect.New(outputType)
-	err := instance.GetAll(val.Interface())
-	return val.Elem().Interface(), err
+	return strconv.ParseInt(str, 0, 64)
 }
 
-func convertVariantToArray(variant *ole.VARIANT, outputType reflect.Type) (interface{}, error) {
-	if variant.VT&ole.VT_ARRAY != ole.VT_ARRAY {
-		return nil, fmt.Errorf("could not convert non-array variant type %d to %v", variant.VT, outputType)
+func convertToInt(value interface{}, outputType reflect.Type) (interface{}, error) {
+	var result int64
+
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Bool:
+		if val.Bool() {
+			result = 1
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result = val.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		result = int64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		// not necessarily a useful conversion but handle it anyway
+		result = int64(val.Float())
+	case reflect.String:
+		var err error
+		result, err = convertStringToInt64(strings.TrimSpace(val.String()), outputType.Kind() == reflect.Uint64)
+		if err != nil {
+			return result, err
+		}
+	default:
+		return nil, fmt.Errorf("could not convert %T to %v", value, outputType)
 	}
 
-	safeArrayConversion := ole.SafeArrayConversion{Array: *(**ole.SafeArray)(unsafe.Pointer(&variant.Val))}
+	return reflect.ValueOf(result).Convert(outputType).Interface(), nil
+}
 
-	arrayLen, err := safeArrayConversion.TotalElements(0)
-	if err != nil {
-		return nil, err
-	}
-	elemVT := (^ole.VT_ARRAY) & variant.VT
-	slice := reflect.MakeSlice(reflect.SliceOf(outputType.Elem()), int(arrayLen), int(arrayLen))
+func convertToFloat(value interface{}, outputType reflect.Type) (interface{}, error) {
+	var result float64
 
-	for i := 0; i < int(arrayLen); i++ {
-		elemVariant := ole.VARIANT{VT: elemVT}
-		elemSrc, err := safeArrayGetAsVariantVal(safeArrayConversion.Array, int64(i), elemVariant)
-		if err != nil {
-			return nil, err
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Bool:
+		if val.Bool() {
+			result = 1
 		}
-		elemVariant.Val = int64(elemSrc)
-		elemDest, err := convertToGoType(&elemVariant, slice.Index(i), outputType.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result = float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		result = float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		result = val.Float()
+	case reflect.String:
+		var err error
+		result, err = strconv.ParseFloat(val.String(), 64)
 		if err != nil {
-			return nil, err
+			return result, err
 		}
-
-		slice.Index(i).Set(reflect.ValueOf(elemDest))
+	default:
+		return nil, fmt.Errorf("could not convert %T to %v", value, outputType)
 	}
 
-	return slice.Interface(), nil
+	return reflect.ValueOf(result).Convert(outputType).Interface(), nil
 }
 
-func convertToGenericValue(variant *ole.VARIANT) interface{} {
-	var result interface{}
-	if variant.VT&ole.VT_ARRAY == ole.VT_ARRAY {
-		safeArrayConversion := ole.SafeArrayConversion{Array: *(**ole.SafeArray)(unsafe.Pointer(&variant.Val))}
-		result = safeArrayConversion.ToValueArray()
-	} else {
-		result = variant.Value()
+func convertToString(value interface{}) string {
+	switch cast := value.(type) {
+	case string:
+		return cast
+	case time.Time:
+		return formatDateTime(&cast)
+	case *time.Time:
+		return formatDateTime(cast)
+	case time.Duration:
+		return formatInterval(cast)
+	default:
+		return fmt.Sprintf("%v", value)
 	}
-	return result
 }
 
-func convertTimeToDataTime(time *time.Time) ole.VARIANT {
+// formatDateTime formats a time using the CIM datetime format (yyyymmddHHMMSS.mmmmmmsUUU). Times
+// before the Windows epoch are treated as null and return an empty string.
+func formatDateTime(time *time.Time) string {
 	if time == nil || !time.After(WindowsEpoch) {
-		return ole.NewVariant(ole.VT_NULL, 0)
+		return ""
 	}
 	_, offset := time.Zone()
 	// convert to minutes
 	offset /= 60
 	//yyyymmddHHMMSS.mmmmmmsUUU
-	s := fmt.Sprintf("%s%+04d", time.Format("20060102150405.000000"), offset)
-	return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(s)))))
+	return fmt.Sprintf("%s%+04d", time.Format("20060102150405.000000"), offset)
 }
 
-func convertDurationToDateTime(duration time.Duration) ole.VARIANT {
+// formatInterval formats a duration using the CIM interval format (ddddddddHHMMSS.mmmmmm:000). A
+// zero duration is treated as null and returns an empty string.
+func formatInterval(duration time.Duration) string {
 	const daySeconds = time.Second * 86400
 
 	if duration == 0 {
-		return ole.NewVariant(ole.VT_NULL, 0)
+		return ""
 	}
 
 	days := duration / daySeconds
@@ -367,27 +250,39 @@ func convertDurationToDateTime(duration time.Duration) ole.VARIANT {
 
 	micros := duration / time.Microsecond
 
-	s := fmt.Sprintf("%08d%02d%02d%02d.%06d:000", days, hours, mins, seconds, micros)
-	return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(s)))))
+	return fmt.Sprintf("%08d%02d%02d%02d.%06d:000", days, hours, mins, seconds, micros)
 }
 
-func extractDateTimeString(variant *ole.VARIANT) (string, error) {
-	switch variant.VT {
-	case ole.VT_BSTR:
-		return variant.ToString(), nil
-	case ole.VT_NULL:
+func extractDateTimeString(value interface{}) (string, error) {
+	switch cast := value.(type) {
+	case string:
+		return cast, nil
+	case nil:
 		return "", nil
 	default:
-		return "", errors.New("variant not compatible with dateTime field")
+		return "", errors.New("value not compatible with dateTime field")
 	}
 }
 
-func convertDataTimeToTime(variant *ole.VARIANT) (time.Time, error) {
-	var err error
-	dateTime, err := extractDateTimeString(variant)
-	if err != nil || len(dateTime) == 0 {
+func convertDataTimeToTime(value interface{}) (time.Time, error) {
+	if t, ok := value.(time.Time); ok {
+		return t, nil
+	}
+
+	dateTime, err := extractDateTimeString(value)
+	if err != nil {
 		return zeroTime, err
 	}
+	return parseDateTime(dateTime)
+}
+
+// parseDateTime parses a CIM datetime value, which can either be a timestamp or an interval. Intervals
+// are returned as an offset to Unix time.
+func parseDateTime(dateTime string) (time.Time, error) {
+	var err error
+	if len(dateTime) == 0 {
+		return zeroTime, nil
+	}
 
 	dLen := len(dateTime)
 	if dLen < 5 {
@@ -443,9 +338,12 @@ func parseIntervalTime(interval string) (time.Time, error) {
 	return time.Unix(int64(stamp), int64(micros*1000)), nil
 }
 
-func convertIntervalToDuration(variant *ole.VARIANT) (time.Duration, error) {
-	var err error
-	interval, err := extractDateTimeString(variant)
+func convertIntervalToDuration(value interface{}) (time.Duration, error) {
+	if d, ok := value.(time.Duration); ok {
+		return d, nil
+	}
+
+	interval, err := extractDateTimeString(value)
 	if err != nil || len(interval) == 0 {
 		return 0, err
 	}
@@ -472,3 +370,159 @@ func abs(num int) int {
 
 	return num
 }
+
+func collectStructProperties(val reflect.Value, properties map[string]interface{}) {
+	for j := 0; j < val.NumField(); j++ {
+		fieldVal := val.Field(j)
+		fieldType := val.Type().Field(j)
+
+		if fieldType.Type.Kind() == reflect.Struct && fieldType.Anonymous {
+			collectStructProperties(fieldVal, properties)
+			continue
+		}
+
+		if !fieldType.IsExported() || strings.HasPrefix(fieldType.Name, "S__") || fieldType.Type == instanceType {
+			continue
+		}
+
+		properties[fieldType.Name] = fieldVal.Interface()
+	}
+}
+
+// toAutomationValue converts a Golang value into the form WMI hands out through automation
+// variants, along with the CIM type matching the original value.
+//
+//gocyclo:ignore
+func toAutomationValue(value interface{}) (interface{}, CIMTYPE_ENUMERATION, error) {
+	switch cast := value.(type) {
+	case nil:
+		return nil, CIM_EMPTY, nil
+	case bool:
+		return cast, CIM_BOOLEAN, nil
+	case int8:
+		return int16(cast), CIM_SINT8, nil
+	case int16:
+		return cast, CIM_SINT16, nil
+	case int32:
+		return cast, CIM_SINT32, nil
+	case int:
+		return int32(cast), CIM_SINT32, nil
+	case int64:
+		return fmt.Sprintf("%d", cast), CIM_SINT64, nil
+	case uint8:
+		return cast, CIM_UINT8, nil
+	case uint16:
+		return int32(cast), CIM_UINT16, nil
+	case uint32:
+		return int32(cast), CIM_UINT32, nil
+	case uint:
+		return int32(cast), CIM_UINT32, nil
+	case uint64:
+		return fmt.Sprintf("%d", cast), CIM_UINT64, nil
+	case float32:
+		return cast, CIM_REAL32, nil
+	case float64:
+		return cast, CIM_REAL64, nil
+	case string:
+		return cast, CIM_STRING, nil
+	case Reference:
+		return string(cast), CIM_REFERENCE, nil
+	case time.Time:
+		return nullString(formatDateTime(&cast)), CIM_DATETIME, nil
+	case *time.Time:
+		return nullString(formatDateTime(cast)), CIM_DATETIME, nil
+	case time.Duration:
+		return nullString(formatInterval(cast)), CIM_DATETIME, nil
+	case *memoryObject:
+		if cast == nil {
+			return nil, CIM_OBJECT, nil
+		}
+		return cast.clone(), CIM_OBJECT, nil
+	case *CimInstance:
+		if cast == nil {
+			return nil, CIM_OBJECT, nil
+		}
+		return cast.clone(), CIM_OBJECT, nil
+	case Object:
+		return nil, CIM_EMPTY, fmt.Errorf("objects of type %T can not be stored in a memory repository", value)
+	}
+
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		if val.Kind() == reflect.Slice && val.IsNil() {
+			return nil, CIM_EMPTY, nil
+		}
+		if val.Len() == 0 {
+			// WMI does not distinguish empty arrays from null
+			_, cimType, _ := toAutomationValue(reflect.Zero(val.Type().Elem()).Interface())
+			return nil, cimType | CIM_FLAG_ARRAY, nil
+		}
+		values := make([]interface{}, val.Len())
+		var elemType CIMTYPE_ENUMERATION
+		for i := 0; i < val.Len(); i++ {
+			elem, cimType, err := toAutomationValue(val.Index(i).Interface())
+			if err != nil {
+				return nil, CIM_EMPTY, err
+			}
+			values[i] = elem
+			elemType = cimType
+		}
+		return values, elemType | CIM_FLAG_ARRAY, nil
+	case reflect.Pointer:
+		if val.IsNil() {
+			return nil, CIM_EMPTY, nil
+		}
+		return toAutomationValue(val.Elem().Interface())
+	case reflect.Bool:
+		return toAutomationValue(val.Bool())
+	case reflect.String:
+		return toAutomationValue(val.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		// Named numeric types convert through their underlying type
+		return toAutomationValue(val.Convert(basicTypes[val.Kind()]).Interface())
+	default:
+		return nil, CIM_EMPTY, fmt.Errorf("unsupported type for automation values %T", value)
+	}
+}
+
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func copyAutomationValue(value interface{}) interface{} {
+	switch cast := value.(type) {
+	case []interface{}:
+		values := make([]interface{}, len(cast))
+		for i, elem := range cast {
+			values[i] = copyAutomationValue(elem)
+		}
+		return values
+	case *memoryObject:
+		return cast.clone()
+	case *CimInstance:
+		return cast.clone()
+	default:
+		return value
+	}
+}
@@ -1,47 +1,51 @@
-//go:build windows
-// +build windows
-
 package wmiext
 
 import (
-	"fmt"
 	"github.com/pkg/errors"
 	"reflect"
-	"syscall"
-	"unsafe"
-
-	"github.com/go-ole/go-ole"
 )
 
-type IEnumWbemClassObjectVtbl struct {
-	QueryInterface uintptr
-	AddRef         uintptr
-	Release        uintptr
-	Reset          uintptr
-	Next           uintptr
-	NextAsync      uintptr
-	Clone          uintptr
-	Skip           uintptr
-}
-
+// Enum iterates the result set of a query. Like an Instance, it is owned by the caller, who
+// releases it with Close, unless created through a Service scoped to an Arena. The instances it
+// returns are owned separately.
 type Enum struct {
-	enum    *ole.IUnknown
-	vTable  *IEnumWbemClassObjectVtbl
-	service *Service
+	iterator ObjectIterator
+	service  *Service
+	handle   *handle
 }
 
+// Close releases the enumeration, including when owned by an Arena. Closing an enumeration more
+// than once has no effect, and Next returns ErrReleased afterward.
 func (e *Enum) Close() {
-	if e != nil && e.enum != nil {
-		e.enum.Release()
+	if e == nil || e.iterator == nil {
+		return
+	}
+	if e.handle.close() && e.service != nil && e.service.arena != nil {
+		e.service.arena.remove(e.handle)
 	}
 }
 
-func newEnum(enumerator *ole.IUnknown, service *Service) *Enum {
-	return &Enum{
-		enum:    enumerator,
-		vTable:  (*IEnumWbemClassObjectVtbl)(unsafe.Pointer(enumerator.RawVTable)),
-		service: service,
+func newEnum(iterator ObjectIterator, service *Service) *Enum {
+	enum := &Enum{
+		iterator: iterator,
+		service:  service,
+	}
+	enum.handle = service.track(iterator.Release)
+	watchLeak(enum, "Enum", enum.handle, service)
+	return enum
+}
+
+// Next returns the next object instance in this iteration
+func (e *Enum) Next() (instance *Instance, err error) {
+	var object Object
+	if err = e.handle.live(); err != nil {
+		return nil, err
+	}
+	if object, err = e.iterator.Next(); err != nil || object == nil {
+		return nil, err
 	}
+
+	return newInstance(object, e.service), nil
 }
 
 // NextObject obtains the next instance in an enumeration and sets all fields
@@ -60,7 +64,7 @@ func NextObject(enum *Enum, target interface{}) (bool, error) {
 		return true, nil
 	}
 
-	//defer instance.Close()
+	defer closeUnlessRetained(instance, target)
 
 	return false, instance.GetAll(target)
 }
@@ -93,13 +97,13 @@ func NextObjects(enum *Enum, targetSlice interface{}) error {
 		if instance == nil {
 			break
 		}
-		//defer instance.Close()
-
 		// 创建新的 struct 实例
 		newElem := reflect.New(structType).Elem()
 
-		// 填充数据
-		if err := instance.GetAll(newElem.Addr().Interface()); err != nil {
+		// 填充数据, 未被 struct 持有的实例随即释放
+		err = instance.GetAll(newElem.Addr().Interface())
+		closeUnlessRetained(instance, newElem.Addr().Interface())
+		if err != nil {
 			return err
 		}
 
@@ -113,33 +117,3 @@ func NextObjects(enum *Enum, targetSlice interface{}) error {
 
 	return nil
 }
-
-// Next returns the next object instance in this iteration
-func (e *Enum) Next() (instance *Instance, err error) {
-	var res uintptr
-	var apObjects *ole.IUnknown
-	var uReturned uint32
-
-	res, _, _ = syscall.SyscallN(
-		e.vTable.Next,                       // IEnumWbemClassObject::Next()
-		uintptr(unsafe.Pointer(e.enum)),     // IEnumWbemClassObject   ptr
-		uintptr(WBEM_INFINITE),              // [in]  long             lTimeout,
-		uintptr(1),                          // [in]  ULONG            uCount,
-		uintptr(unsafe.Pointer(&apObjects)), // [out] IWbemClassObject **apObjects,
-		uintptr(unsafe.Pointer(&uReturned))) // [out] ULONG            *puReturned)
-	if int(res) < 0 {
-		return nil, NewWmiError(res)
-	}
-
-	if uReturned < 1 {
-		switch res {
-		case WBEM_S_NO_ERROR, WBEM_S_FALSE:
-			// No more elements
-			return nil, nil
-		default:
-			return nil, fmt.Errorf("failure advancing enumeration (%d)", res)
-		}
-	}
-
-	return newInstance(apObjects, e.service), nil
-}
@@ -0,0 +1,188 @@
+package wmiext
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// eventPollTimeout bounds each wait for an event of a Subscription, and thus the time it takes to
+// notice its context is done.
+var eventPollTimeout = 500 * time.Millisecond
+
+// Event is an intrinsic event of an instance, such as an __InstanceModificationEvent.
+type Event struct {
+	// Class is the event class, e.g. InstanceModificationEvent.
+	Class string
+	// Target is the instance after its creation or modification, or the deleted instance.
+	Target *Instance
+	// Previous is the instance before its modification, nil for the other events.
+	Previous *Instance
+	// Time is the time the event occurred, zero when the provider did not report it.
+	Time time.Time
+}
+
+// DecodeEvent decodes an event instance. The event takes ownership of the instance, which is closed
+// by Event.Close.
+func DecodeEvent(instance *Instance) (*Event, error) {
+	defer instance.Close()
+
+	class, err := instance.GetClassName()
+	if err != nil {
+		return nil, err
+	}
+	event := &Event{Class: class}
+
+	if event.Target, err = embeddedInstance(instance, "TargetInstance"); err != nil {
+		return nil, err
+	}
+	if event.Target == nil {
+		return nil, errors.Errorf("event %s has no target instance", class)
+	}
+	if strings.EqualFold(class, InstanceModificationEvent) {
+		if event.Previous, err = embeddedInstance(instance, "PreviousInstance"); err != nil {
+			event.Close()
+			return nil, err
+		}
+	}
+
+	if created, _, _, err := instance.GetAsAny("TIME_CREATED"); err == nil && created != nil {
+		if ticks, err := strconv.ParseUint(convertToString(created), 10, 64); err == nil && ticks > fileTimeUnixEpoch {
+			event.Time = time.Unix(0, int64(ticks-fileTimeUnixEpoch)*100)
+		}
+	}
+	return event, nil
+}
+
+func embeddedInstance(instance *Instance, name string) (*Instance, error) {
+	value, _, _, err := instance.GetAsAny(name)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	object, ok := value.(Object)
+	if !ok {
+		return nil, errors.Errorf("property %s of type %T is not an embedded object", name, value)
+	}
+	return newInstance(object, instance.service), nil
+}
+
+// Close releases the instances of the event.
+func (e *Event) Close() {
+	if e.Target != nil {
+		e.Target.Close()
+	}
+	if e.Previous != nil {
+		e.Previous.Close()
+	}
+}
+
+// EventSource delivers the events of a notification query.
+type EventSource struct {
+	iterator EventIterator
+	service  *Service
+}
+
+// ExecNotificationQuery subscribes to the events matching a WQL event query, e.g. built with
+// InstanceEvents.
+func (s *Service) ExecNotificationQuery(wqlQuery string) (*EventSource, error) {
+	observation := s.Telemetry().observe(context.Background(), "ExecNotificationQuery", MetricQueryDuration, Attr(AttributeQuery, wqlQuery))
+	iterator, err := s.backend.ExecNotificationQuery(wqlQuery)
+	observation.end(err)
+	if err != nil {
+		return nil, err
+	}
+	return &EventSource{iterator: iterator, service: s}, nil
+}
+
+// Next waits up to timeout for the next event, returning nil when none occurred in time.
+func (e *EventSource) Next(timeout time.Duration) (*Event, error) {
+	object, err := e.iterator.Next(timeout)
+	if err != nil || object == nil {
+		return nil, err
+	}
+	return DecodeEvent(newInstance(object, e.service))
+}
+
+// Close cancels the subscription.
+func (e *EventSource) Close() {
+	e.iterator.Release()
+}
+
+// Subscription streams the events of a notification query on a channel until its context is done
+// or it is closed.
+type Subscription struct {
+	events chan *Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// Subscribe subscribes to the events matching the query and streams them until ctx is done. The
+// receiver of the events must close them.
+func (s *Service) Subscribe(ctx context.Context, query string) (*Subscription, error) {
+	source, err := s.ExecNotificationQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &Subscription{events: make(chan *Event), cancel: cancel, done: make(chan struct{})}
+	go sub.run(ctx, source)
+	return sub, nil
+}
+
+func (sub *Subscription) run(ctx context.Context, source *EventSource) {
+	defer close(sub.done)
+	defer close(sub.events)
+	defer source.Close()
+
+	for ctx.Err() == nil {
+		event, err := source.Next(eventPollTimeout)
+		if err != nil {
+			sub.fail(err)
+			return
+		}
+		if event == nil {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		case <-ctx.Done():
+			event.Close()
+		}
+	}
+}
+
+func (sub *Subscription) fail(err error) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	sub.err = err
+}
+
+// Events returns the channel of events, closed once the subscription ended.
+func (sub *Subscription) Events() <-chan *Event {
+	return sub.events
+}
+
+// Err returns the error that ended the subscription, nil when it ended with its context or Close.
+func (sub *Subscription) Err() error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.err
+}
+
+// Close ends the subscription and waits until its channel is closed, discarding the pending events.
+func (sub *Subscription) Close() {
+	sub.cancel()
+	for event := range sub.events {
+		event.Close()
+	}
+	<-sub.done
+}
@@ -0,0 +1,136 @@
+package wmiext
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSystemPath = `Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B1"`
+
+func nextEvent(t *testing.T, source *EventSource) *Event {
+	t.Helper()
+	event, err := source.Next(time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, event)
+	return event
+}
+
+func TestService_ExecNotificationQuery(t *testing.T) {
+	repo := newTestRepository(t)
+	service := repo.Service()
+	defer service.Close()
+
+	source, err := service.ExecNotificationQuery(InstanceEvents(InstanceOperationEvent, "Msvm_ComputerSystem").Within(time.Second).String())
+	require.NoError(t, err)
+	defer source.Close()
+
+	before := time.Now().Add(-time.Second)
+	_, err = repo.AddObject("Msvm_ComputerSystem", testSystem{CreationClassName: "Msvm_ComputerSystem", Name: "E4F5", ElementName: "vm-3"})
+	require.NoError(t, err)
+	require.NoError(t, repo.Update(testSystemPath, map[string]interface{}{"ElementName": "vm-1"}))
+	require.NoError(t, repo.Update(testSystemPath, map[string]interface{}{"EnabledState": uint16(3)}))
+	require.NoError(t, repo.Delete(`Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="C2D3"`))
+	_, err = repo.AddInstance("Msvm_ConcreteJob", map[string]interface{}{"InstanceID": "job"})
+	require.NoError(t, err)
+
+	var system testSystem
+	created := nextEvent(t, source)
+	defer created.Close()
+	assert.Equal(t, InstanceCreationEvent, created.Class)
+	require.NoError(t, created.Target.GetAll(&system))
+	assert.Equal(t, "vm-3", system.ElementName)
+	assert.Nil(t, created.Previous)
+	assert.True(t, created.Time.After(before))
+
+	modified := nextEvent(t, source)
+	defer modified.Close()
+	assert.Equal(t, InstanceModificationEvent, modified.Class)
+	require.NoError(t, modified.Target.GetAll(&system))
+	assert.Equal(t, uint16(3), system.EnabledState)
+	require.NotNil(t, modified.Previous)
+	require.NoError(t, modified.Previous.GetAll(&system))
+	assert.Equal(t, uint16(2), system.EnabledState)
+
+	deleted := nextEvent(t, source)
+	defer deleted.Close()
+	assert.Equal(t, InstanceDeletionEvent, deleted.Class)
+	require.NoError(t, deleted.Target.GetAll(&system))
+	assert.Equal(t, "C2D3", system.Name)
+
+	event, err := source.Next(10 * time.Millisecond)
+	require.NoError(t, err)
+	assert.Nil(t, event)
+
+	source.Close()
+	_, err = source.Next(time.Millisecond)
+	assert.Error(t, err)
+
+	_, err = service.ExecNotificationQuery(Select("Msvm_ComputerSystem").String())
+	var wmiErr *WmiError
+	require.ErrorAs(t, err, &wmiErr)
+	assert.Equal(t, uintptr(WBEM_E_NOT_EVENT_CLASS), wmiErr.Code())
+}
+
+func TestService_ExecNotificationQueryWhere(t *testing.T) {
+	repo := newTestRepository(t)
+	service := repo.Service()
+	defer service.Close()
+
+	query := InstanceEvents(InstanceModificationEvent, "Msvm_ComputerSystem").
+		Where(Equal("TargetInstance.Name", "A0B1")).Where(NotEqual("TargetInstance.EnabledState", uint16(2)))
+	source, err := service.ExecNotificationQuery(query.String())
+	require.NoError(t, err)
+	defer source.Close()
+
+	require.NoError(t, repo.Update(`Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="C2D3"`, map[string]interface{}{"EnabledState": uint16(2)}))
+	require.NoError(t, repo.Update(testSystemPath, map[string]interface{}{"ElementName": "renamed"}))
+	require.NoError(t, repo.Update(testSystemPath, map[string]interface{}{"EnabledState": uint16(32768)}))
+
+	event := nextEvent(t, source)
+	defer event.Close()
+	var system testSystem
+	require.NoError(t, event.Target.GetAll(&system))
+	assert.Equal(t, "renamed", system.ElementName)
+	assert.Equal(t, uint16(32768), system.EnabledState)
+
+	next, err := source.Next(10 * time.Millisecond)
+	require.NoError(t, err)
+	assert.Nil(t, next)
+}
+
+func TestService_Subscribe(t *testing.T) {
+	eventPollTimeout = 10 * time.Millisecond
+	defer func() { eventPollTimeout = 500 * time.Millisecond }()
+
+	repo := newTestRepository(t)
+	service := repo.Service()
+	defer service.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := service.Subscribe(ctx, InstanceEvents(InstanceDeletionEvent, "Msvm_ComputerSystem").String())
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Delete(testSystemPath))
+	event := <-sub.Events()
+	require.NotNil(t, event)
+	assert.Equal(t, InstanceDeletionEvent, event.Class)
+	event.Close()
+
+	cancel()
+	for range sub.Events() {
+	}
+	assert.NoError(t, sub.Err())
+	sub.Close()
+
+	sub, err = service.Subscribe(context.Background(), InstanceEvents(InstanceCreationEvent, "Msvm_ComputerSystem").String())
+	require.NoError(t, err)
+	_, err = repo.AddObject("Msvm_ComputerSystem", testSystem{CreationClassName: "Msvm_ComputerSystem", Name: "E4F5"})
+	require.NoError(t, err)
+	sub.Close()
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+}
@@ -0,0 +1,559 @@
+package wmiext
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+// ErrDisconnected is returned by the calls of a FaultBackend once a Fault disconnected it, until
+// Reconnect.
+var ErrDisconnected = errors.Wrap(NewWmiError(WBEM_E_TRANSPORT_FAILURE), "injected disconnection")
+
+// ErrFaultTimeout is returned by the calls a Fault times out.
+var ErrFaultTimeout = errors.Wrap(NewWmiError(WBEM_E_TIMED_OUT), "injected timeout")
+
+// FaultOperation is a Backend call a Fault applies to.
+type FaultOperation string
+
+const (
+	FaultExecQuery             FaultOperation = "ExecQuery"
+	FaultGetObject             FaultOperation = "GetObject"
+	FaultCreateInstanceEnum    FaultOperation = "CreateInstanceEnum"
+	FaultExecMethod            FaultOperation = "ExecMethod"
+	FaultExecNotificationQuery FaultOperation = "ExecNotificationQuery"
+)
+
+// FaultJobClass is the class of the jobs made up by a Fault with a Job.
+const FaultJobClass = "Wmiext_FaultJob"
+
+// Fault describes a fault injected by a FaultBackend and the calls it applies to. A call matches
+// when every matcher set matches, the first matching Fault applies and the others are skipped.
+type Fault struct {
+	// Operation matches the Backend call, any when empty.
+	Operation FaultOperation
+	// Class matches the class of the object, enumeration or query, case-insensitively, any when
+	// empty. The class of a query is the one it selects from, or its ResultClass or ISA class.
+	Class string
+	// Method matches the method executed, case-insensitively, any when empty.
+	Method string
+	// Call only injects the fault into the Nth matching call, counting from 1, any when 0.
+	Call int
+	// Times limits the number of injections, unlimited when 0.
+	Times int
+	// Probability injects the fault into a matching call with this probability, drawn from the
+	// seed of the FaultBackend, always when 0.
+	Probability float64
+
+	// Delay holds the call back before making it.
+	Delay time.Duration
+	// Timeout holds the call back and fails it with ErrFaultTimeout, without making it.
+	Timeout time.Duration
+	// Err fails the call without making it.
+	Err error
+	// Disconnect fails the call and every later one with ErrDisconnected, including the iterators
+	// already open, until Reconnect.
+	Disconnect bool
+	// ReturnValue rejects an ExecMethod call with this return value, without making it. The
+	// output parameters other than ReturnValue are null.
+	ReturnValue int32
+	// Job makes the ExecMethod call, then replaces its outcome with a job of FaultJobClass
+	// following Job, along with a return value of 4096.
+	Job *FaultJob
+}
+
+// FaultJob is the course of a job made up by a Fault.
+type FaultJob struct {
+	// Polls is the number of times the job is fetched running before reaching State, the
+	// fetch of the Job output parameter included.
+	Polls int
+	// PercentComplete is the completion reported while running.
+	PercentComplete uint16
+	// State is the state the job reaches, running forever when JobStateRunning or unset.
+	State            JobState
+	ErrorCode        uint16
+	ErrorDescription string
+}
+
+func (f *Fault) String() string {
+	var matchers []string
+	for _, m := range []struct{ name, value string }{
+		{"operation", string(f.Operation)}, {"class", f.Class}, {"method", f.Method},
+	} {
+		if m.value != "" {
+			matchers = append(matchers, m.name+"="+m.value)
+		}
+	}
+	if f.Call != 0 {
+		matchers = append(matchers, fmt.Sprintf("call=%d", f.Call))
+	}
+	return "fault{" + strings.Join(matchers, " ") + "}"
+}
+
+// matches reports whether the fault applies to the call.
+func (f *Fault) matches(operation FaultOperation, className string, method string) bool {
+	return (f.Operation == "" || f.Operation == operation) &&
+		(f.Class == "" || strings.EqualFold(f.Class, className)) &&
+		(f.Method == "" || strings.EqualFold(f.Method, method))
+}
+
+// InjectedFault records a fault injected by a FaultBackend.
+type InjectedFault struct {
+	// Call is the number of the call among all the calls of the FaultBackend, counting from 1.
+	Call      int
+	Operation FaultOperation
+	Class     string
+	Method    string
+	Fault     *Fault
+}
+
+// FaultBackend is a Backend decorator injecting faults into the calls of another Backend, such as
+// a MemoryRepository, to test the failure paths of its callers: return codes, failing and hanging
+// jobs, delays, timeouts and disconnections. Given the same seed and the same calls, it injects the
+// same faults. A FaultBackend is safe for concurrent use.
+type FaultBackend struct {
+	// Clock waits for delays and timeouts, SystemClock when nil.
+	Clock Clock
+
+	backend Backend
+
+	mu           sync.Mutex
+	random       *rand.Rand
+	faults       []*faultState
+	calls        int
+	injected     []InjectedFault
+	disconnected bool
+	jobs         map[string]*faultJobState
+}
+
+type faultState struct {
+	*Fault
+	matched  int
+	injected int
+}
+
+type faultJobState struct {
+	FaultJob
+	id    int
+	path  string
+	polls int
+}
+
+// NewFaultBackend returns a Backend injecting faults into the calls of backend, drawing
+// probabilities from seed.
+func NewFaultBackend(backend Backend, seed int64, faults ...*Fault) *FaultBackend {
+	f := &FaultBackend{
+		backend: backend,
+		random:  rand.New(rand.NewSource(seed)),
+		jobs:    make(map[string]*faultJobState),
+	}
+	f.Inject(faults...)
+	return f
+}
+
+// Inject adds faults, applying after the ones added before.
+func (f *FaultBackend) Inject(faults ...*Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, fault := range faults {
+		f.faults = append(f.faults, &faultState{Fault: fault})
+	}
+}
+
+// Clear removes every fault, the disconnection and jobs made up remain.
+func (f *FaultBackend) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = nil
+}
+
+// Reconnect ends a disconnection injected by a Fault.
+func (f *FaultBackend) Reconnect() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.disconnected = false
+}
+
+// Injected returns the faults injected so far, in order.
+func (f *FaultBackend) Injected() []InjectedFault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]InjectedFault(nil), f.injected...)
+}
+
+// inject returns the fault to inject into a call, if any.
+func (f *FaultBackend) inject(operation FaultOperation, className string, method string) (*Fault, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if f.disconnected {
+		return nil, ErrDisconnected
+	}
+	for _, state := range f.faults {
+		if !state.matches(operation, className, method) {
+			continue
+		}
+		state.matched++
+		if (state.Call != 0 && state.matched != state.Call) || (state.Times != 0 && state.injected >= state.Times) {
+			continue
+		}
+		if state.Probability > 0 && f.random.Float64() >= state.Probability {
+			continue
+		}
+		state.injected++
+		f.injected = append(f.injected, InjectedFault{
+			Call: f.calls, Operation: operation, Class: className, Method: method, Fault: state.Fault,
+		})
+		if state.Disconnect {
+			f.disconnected = true
+		}
+		return state.Fault, nil
+	}
+	return nil, nil
+}
+
+// before injects the fault into a call, returning the fault to apply once made, if any.
+func (f *FaultBackend) before(operation FaultOperation, className string, method string) (*Fault, error) {
+	fault, err := f.inject(operation, className, method)
+	if err != nil || fault == nil {
+		return nil, err
+	}
+	clock := f.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	switch {
+	case fault.Disconnect:
+		return nil, ErrDisconnected
+	case fault.Timeout > 0:
+		<-clock.After(fault.Timeout)
+		return nil, ErrFaultTimeout
+	}
+	if fault.Delay > 0 {
+		<-clock.After(fault.Delay)
+	}
+	if fault.Err != nil {
+		return nil, fault.Err
+	}
+	return fault, nil
+}
+
+func (f *FaultBackend) live() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.disconnected {
+		return ErrDisconnected
+	}
+	return nil
+}
+
+func (f *FaultBackend) ExecQuery(wql string) (ObjectIterator, error) {
+	if _, err := f.before(FaultExecQuery, queryClass(wql), ""); err != nil {
+		return nil, err
+	}
+	iterator, err := f.backend.ExecQuery(wql)
+	if err != nil {
+		return nil, err
+	}
+	return &faultIterator{backend: f, iterator: iterator}, nil
+}
+
+func (f *FaultBackend) GetObject(path string) (Object, error) {
+	if strings.EqualFold(path, FaultJobClass) {
+		return &faultObject{className: FaultJobClass}, nil
+	}
+	if _, err := f.before(FaultGetObject, pathClass(path), ""); err != nil {
+		return nil, err
+	}
+	if job := f.job(path); job != nil {
+		return job, nil
+	}
+	return f.backend.GetObject(path)
+}
+
+func (f *FaultBackend) CreateInstanceEnum(className string) (ObjectIterator, error) {
+	if _, err := f.before(FaultCreateInstanceEnum, className, ""); err != nil {
+		return nil, err
+	}
+	iterator, err := f.backend.CreateInstanceEnum(className)
+	if err != nil {
+		return nil, err
+	}
+	return &faultIterator{backend: f, iterator: iterator}, nil
+}
+
+func (f *FaultBackend) ExecMethod(path string, method string, inParams Object) (Object, error) {
+	fault, err := f.before(FaultExecMethod, pathClass(path), method)
+	if err != nil {
+		return nil, err
+	}
+	if f.terminate(path) {
+		return &faultObject{className: "__PARAMETERS", properties: []Property{returnValueProperty(0)}}, nil
+	}
+	if fault != nil && fault.ReturnValue != 0 {
+		return &faultObject{className: "__PARAMETERS", properties: []Property{returnValueProperty(fault.ReturnValue)}}, nil
+	}
+
+	out, err := f.backend.ExecMethod(path, method, inParams)
+	if err != nil || fault == nil || fault.Job == nil {
+		return out, err
+	}
+	return &faultObject{base: out, properties: []Property{
+		returnValueProperty(4096),
+		faultProperty("Job", Reference(f.startJob(fault.Job))),
+	}}, nil
+}
+
+func (f *FaultBackend) ExecNotificationQuery(wql string) (EventIterator, error) {
+	if _, err := f.before(FaultExecNotificationQuery, queryClass(wql), ""); err != nil {
+		return nil, err
+	}
+	iterator, err := f.backend.ExecNotificationQuery(wql)
+	if err != nil {
+		return nil, err
+	}
+	return &faultEventIterator{backend: f, iterator: iterator}, nil
+}
+
+func (f *FaultBackend) Close() {
+	f.backend.Close()
+}
+
+// startJob makes up a job following course, and returns its path.
+func (f *FaultBackend) startJob(course *FaultJob) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := len(f.jobs) + 1
+	job := &faultJobState{
+		FaultJob: *course,
+		id:       id,
+		path:     fmt.Sprintf(`\\.\root\wmiext:%s.InstanceID="%d"`, FaultJobClass, id),
+	}
+	f.jobs[strings.ToLower(job.path)] = job
+	return job.path
+}
+
+// job returns the current state of the job made up at path, nil for any other path.
+func (f *FaultBackend) job(path string) Object {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job, ok := f.jobs[strings.ToLower(path)]
+	if !ok {
+		return nil
+	}
+
+	state, percent := JobStateRunning, job.PercentComplete
+	if job.polls >= job.Polls && job.State != 0 {
+		state = job.State
+	}
+	job.polls++
+	var errorCode uint16
+	if state.Finished() {
+		errorCode = job.ErrorCode
+		if state == JobStateCompleted {
+			percent = 100
+		}
+	}
+	return &faultObject{className: FaultJobClass, properties: []Property{
+		faultProperty(WmiPathKey, job.path),
+		faultProperty("InstanceID", fmt.Sprint(job.id)),
+		faultProperty("JobState", uint16(state)),
+		faultProperty("PercentComplete", percent),
+		faultProperty("ErrorCode", errorCode),
+		faultProperty("ErrorDescription", job.ErrorDescription),
+		faultProperty("ErrorSummaryDescription", job.ErrorDescription),
+	}}
+}
+
+// terminate ends the job made up at path, reporting whether there is one.
+func (f *FaultBackend) terminate(path string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job, ok := f.jobs[strings.ToLower(path)]
+	if ok {
+		job.State, job.Polls = JobStateTerminated, 0
+	}
+	return ok
+}
+
+func faultProperty(name string, value interface{}) Property {
+	automation, cimType, _ := toAutomationValue(value)
+	return Property{Name: name, Value: automation, CimType: cimType}
+}
+
+func returnValueProperty(value int32) Property {
+	return faultProperty("ReturnValue", uint32(value))
+}
+
+var (
+	resultClassPattern = regexp.MustCompile(`(?i)\bResultClass\s*=\s*(\w+)`)
+	isaPattern         = regexp.MustCompile(`(?i)\bISA\s+['"](\w+)['"]`)
+	fromPattern        = regexp.MustCompile(`(?i)\bFROM\s+(\w+)`)
+)
+
+// queryClass returns the class a query selects, associates or is notified of.
+func queryClass(wql string) string {
+	for _, pattern := range []*regexp.Regexp{resultClassPattern, isaPattern, fromPattern} {
+		if match := pattern.FindStringSubmatch(wql); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// pathClass returns the class of an object path, or the path itself when it does not parse.
+func pathClass(path string) string {
+	parsed, err := objectpath.Parse(path)
+	if err != nil {
+		return path
+	}
+	return parsed.ClassName
+}
+
+// faultIterator fails once its FaultBackend is disconnected.
+type faultIterator struct {
+	backend  *FaultBackend
+	iterator ObjectIterator
+}
+
+func (i *faultIterator) Next() (Object, error) {
+	if err := i.backend.live(); err != nil {
+		return nil, err
+	}
+	return i.iterator.Next()
+}
+
+func (i *faultIterator) Release() {
+	i.iterator.Release()
+}
+
+// faultEventIterator fails once its FaultBackend is disconnected.
+type faultEventIterator struct {
+	backend  *FaultBackend
+	iterator EventIterator
+}
+
+func (i *faultEventIterator) Next(timeout time.Duration) (Object, error) {
+	if err := i.backend.live(); err != nil {
+		return nil, err
+	}
+	return i.iterator.Next(timeout)
+}
+
+func (i *faultEventIterator) Release() {
+	i.iterator.Release()
+}
+
+// faultObject overrides properties of a base object. Without a base, the other properties are
+// null: it stands for the output of a rejected method, or a job made up.
+type faultObject struct {
+	base       Object
+	className  string
+	properties []Property
+}
+
+func (o *faultObject) property(name string) *Property {
+	for i := range o.properties {
+		if strings.EqualFold(o.properties[i].Name, name) {
+			return &o.properties[i]
+		}
+	}
+	return nil
+}
+
+func (o *faultObject) Get(name string) (interface{}, CIMTYPE_ENUMERATION, WBEM_FLAVOR_TYPE, error) {
+	if prop := o.property(name); prop != nil {
+		return prop.Value, prop.CimType, prop.Flavor, nil
+	}
+	if o.base != nil {
+		return o.base.Get(name)
+	}
+	if strings.EqualFold(name, "__CLASS") {
+		return o.className, CIM_STRING, WBEM_FLAVOR_ORIGIN_SYSTEM, nil
+	}
+	return nil, CIM_EMPTY, 0, nil
+}
+
+func (o *faultObject) Put(name string, value interface{}) error {
+	if o.base != nil {
+		return o.base.Put(name, value)
+	}
+	automation, cimType, err := toAutomationValue(value)
+	if err != nil {
+		return err
+	}
+	if prop := o.property(name); prop != nil {
+		prop.Value, prop.CimType = automation, cimType
+		return nil
+	}
+	o.properties = append(o.properties, Property{Name: name, Value: automation, CimType: cimType})
+	return nil
+}
+
+func (o *faultObject) Properties() ([]Property, error) {
+	var properties []Property
+	if o.base != nil {
+		base, err := o.base.Properties()
+		if err != nil {
+			return nil, err
+		}
+		for _, prop := range base {
+			if o.property(prop.Name) == nil {
+				properties = append(properties, prop)
+			}
+		}
+	} else {
+		properties = append(properties, Property{Name: "__CLASS", Value: o.className, CimType: CIM_STRING, Flavor: WBEM_FLAVOR_ORIGIN_SYSTEM})
+	}
+	return append(properties, o.properties...), nil
+}
+
+func (o *faultObject) SpawnInstance() (Object, error) {
+	if o.base != nil {
+		return o.base.SpawnInstance()
+	}
+	return &faultObject{className: o.className}, nil
+}
+
+func (o *faultObject) Clone() (Object, error) {
+	clone := &faultObject{className: o.className, properties: append([]Property(nil), o.properties...)}
+	if o.base != nil {
+		base, err := o.base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		clone.base = base
+	}
+	return clone, nil
+}
+
+func (o *faultObject) MethodParameters(method string) (Object, error) {
+	if o.base != nil {
+		return o.base.MethodParameters(method)
+	}
+	return &faultObject{className: "__PARAMETERS"}, nil
+}
+
+func (o *faultObject) CimText() (string, error) {
+	return EncodeCimXml(o)
+}
+
+func (o *faultObject) Refresh() error {
+	if o.base != nil {
+		return o.base.Refresh()
+	}
+	return nil
+}
+
+func (o *faultObject) Release() {
+	if o.base != nil {
+		o.base.Release()
+	}
+}
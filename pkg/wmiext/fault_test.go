@@ -0,0 +1,207 @@
+package wmiext
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFaultService serves the test repository through a FaultBackend, and counts the calls of
+// Msvm_ComputerSystem.RequestStateChange reaching the repository.
+func newFaultService(t *testing.T, seed int64, faults ...*Fault) (*Service, *FaultBackend, *int) {
+	repo := newTestRepository(t)
+	repo.DefineClass(MemoryClass{
+		Name:       "Msvm_ComputerSystem",
+		Superclass: "CIM_ComputerSystem",
+		Methods: map[string]map[string]CIMTYPE_ENUMERATION{
+			"RequestStateChange": {"RequestedState": CIM_UINT16, "Job": CIM_REFERENCE},
+		},
+	})
+	calls := new(int)
+	repo.HandleMethod("Msvm_ComputerSystem", "RequestStateChange", func(call *MethodCall) error {
+		*calls++
+		call.Return(0)
+		return call.Repository.Update(call.Path, map[string]interface{}{"EnabledState": call.In("RequestedState")})
+	})
+
+	backend := NewFaultBackend(repo.Backend(), seed, faults...)
+	service := NewService(backend)
+	t.Cleanup(service.Close)
+	return service, backend, calls
+}
+
+func requestStateChange(t *testing.T, service *Service, state uint16) (int32, *Instance) {
+	system, err := service.GetObject(testSystemPath)
+	require.NoError(t, err)
+	defer system.Close()
+
+	var (
+		returnValue int32
+		job         *Instance
+	)
+	require.NoError(t, system.Method("RequestStateChange").
+		In("RequestedState", state).
+		Execute().
+		Out("Job", &job).
+		Out("ReturnValue", &returnValue).
+		End())
+	return returnValue, job
+}
+
+func TestFaultBackend_ReturnValue(t *testing.T) {
+	service, backend, calls := newFaultService(t, 1, &Fault{
+		Operation:   FaultExecMethod,
+		Class:       "msvm_computersystem",
+		Method:      "RequestStateChange",
+		Call:        2,
+		ReturnValue: 32775,
+	})
+
+	returnValue, _ := requestStateChange(t, service, 3)
+	assert.Zero(t, returnValue)
+	// The second call is rejected before reaching the repository
+	returnValue, job := requestStateChange(t, service, 2)
+	assert.Equal(t, int32(32775), returnValue)
+	assert.Nil(t, job)
+	assert.Equal(t, 1, *calls)
+	returnValue, _ = requestStateChange(t, service, 2)
+	assert.Zero(t, returnValue)
+	assert.Equal(t, 2, *calls)
+
+	injected := backend.Injected()
+	require.Len(t, injected, 1)
+	assert.Equal(t, FaultExecMethod, injected[0].Operation)
+	assert.Equal(t, "Msvm_ComputerSystem", injected[0].Class)
+	assert.Equal(t, "RequestStateChange", injected[0].Method)
+}
+
+func TestFaultBackend_Job(t *testing.T) {
+	service, backend, calls := newFaultService(t, 1, &Fault{
+		Method: "RequestStateChange",
+		Times:  1,
+		Job:    &FaultJob{Polls: 2, PercentComplete: 60, State: JobStateException, ErrorCode: 32768, ErrorDescription: "injected"},
+	})
+
+	// The method is made, its job fails after being fetched running twice: as the output
+	// parameter, then by the first poll
+	returnValue, instance := requestStateChange(t, service, 3)
+	assert.Equal(t, int32(4096), returnValue)
+	assert.Equal(t, 1, *calls)
+	var progress []JobStatus
+	job, err := NewMethodJob(service, returnValue, instance, WithClock(&scriptedClock{}),
+		WithProgress(func(status JobStatus) { progress = append(progress, status) }))
+	require.NoError(t, err)
+	result, err := job.Wait(context.Background())
+	var jobErr *JobError
+	require.True(t, errors.As(err, &jobErr))
+	assert.Equal(t, 32768, jobErr.ErrorCode)
+	assert.Equal(t, "injected", jobErr.Description)
+	assert.Equal(t, 2, result.Polls)
+	require.Len(t, progress, 2)
+	assert.Equal(t, JobStateRunning, progress[0].State)
+	assert.Equal(t, uint16(60), progress[0].PercentComplete)
+
+	// A job running forever is terminated once the wait times out
+	backend.Inject(&Fault{Method: "RequestStateChange", Job: &FaultJob{PercentComplete: 60}})
+	returnValue, instance = requestStateChange(t, service, 2)
+	path, err := instance.Path()
+	require.NoError(t, err)
+	job, err = NewMethodJob(service, returnValue, instance, WithPollStrategy(ConstantPoll(time.Millisecond)))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = job.Wait(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	terminated, err := service.GetObject(path)
+	require.NoError(t, err)
+	state, err := terminated.GetAsUint("JobState")
+	require.NoError(t, err)
+	assert.Equal(t, uint(JobStateTerminated), state)
+}
+
+func TestFaultBackend_Disconnect(t *testing.T) {
+	service, backend, _ := newFaultService(t, 1, &Fault{
+		Operation:  FaultGetObject,
+		Class:      "Msvm_ComputerSystem",
+		Times:      1,
+		Disconnect: true,
+	})
+	enum, err := service.ExecQuery("SELECT * FROM Msvm_ComputerSystem")
+	require.NoError(t, err)
+	defer enum.Close()
+
+	_, err = service.GetObject(testSystemPath)
+	assert.ErrorIs(t, err, ErrDisconnected)
+	// Every call fails until reconnected, the iterators already open included
+	_, err = enum.Next()
+	assert.ErrorIs(t, err, ErrDisconnected)
+	_, err = service.ExecQuery("SELECT * FROM Msvm_VirtualSystemSettingData")
+	assert.ErrorIs(t, err, ErrDisconnected)
+	var wmiErr *WmiError
+	require.True(t, errors.As(err, &wmiErr))
+	assert.Equal(t, uintptr(WBEM_E_TRANSPORT_FAILURE), wmiErr.Code())
+
+	backend.Reconnect()
+	system, err := service.GetObject(testSystemPath)
+	require.NoError(t, err)
+	system.Close()
+	assert.Len(t, backend.Injected(), 1)
+}
+
+func TestFaultBackend_Delays(t *testing.T) {
+	failure := errors.New("injected failure")
+	service, backend, calls := newFaultService(t, 1,
+		&Fault{Operation: FaultExecQuery, Class: "Msvm_VirtualSystemSettingData", Err: failure},
+		&Fault{Operation: FaultExecMethod, Timeout: time.Minute},
+		&Fault{Operation: FaultGetObject, Delay: time.Second},
+	)
+	clock := &scriptedClock{}
+	backend.Clock = clock
+
+	_, err := service.ExecQuery("SELECT * FROM Msvm_VirtualSystemSettingData WHERE InstanceID = 'x'")
+	assert.Equal(t, failure, err)
+	system, err := service.GetObject(testSystemPath)
+	require.NoError(t, err)
+	defer system.Close()
+	err = system.Method("RequestStateChange").In("RequestedState", uint16(3)).Execute().End()
+	assert.ErrorIs(t, err, ErrFaultTimeout)
+	assert.Zero(t, *calls)
+	// The class of the method is fetched with a delay as well
+	assert.Equal(t, []time.Duration{time.Second, time.Second, time.Minute}, clock.delays)
+}
+
+func TestFaultBackend_Seed(t *testing.T) {
+	run := func(seed int64) []int {
+		service, backend, _ := newFaultService(t, seed, &Fault{Operation: FaultGetObject, Probability: 0.5, Err: NotFound})
+		for i := 0; i < 40; i++ {
+			if system, err := service.GetObject(testSystemPath); err == nil {
+				system.Close()
+			}
+		}
+		var calls []int
+		for _, injected := range backend.Injected() {
+			calls = append(calls, injected.Call)
+		}
+		return calls
+	}
+
+	first := run(42)
+	assert.Equal(t, first, run(42))
+	assert.NotEqual(t, first, run(43))
+	assert.Greater(t, len(first), 5)
+	assert.Less(t, len(first), 35)
+}
+
+func TestQueryClass(t *testing.T) {
+	for wql, class := range map[string]string{
+		"SELECT * FROM Msvm_ComputerSystem WHERE Name = 'x'":                                             "Msvm_ComputerSystem",
+		`ASSOCIATORS OF {Msvm_ComputerSystem.Name="x"} WHERE ResultClass = Msvm_MemorySettingData`:       "Msvm_MemorySettingData",
+		"SELECT * FROM __InstanceModificationEvent WITHIN 1 WHERE TargetInstance ISA 'Msvm_ConcreteJob'": "Msvm_ConcreteJob",
+	} {
+		assert.Equal(t, class, queryClass(wql), wql)
+	}
+}
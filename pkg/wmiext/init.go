@@ -1,8 +1,10 @@
+//go:build windows
+// +build windows
+
 package wmiext
 
 import (
 	"github.com/go-ole/go-ole"
-	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/windows"
 )
 
@@ -20,9 +22,6 @@ var (
 	clsidWbemObjectTextSrc = ole.NewGUID("{8d1c559d-84f0-4bb3-a7d5-56a7435a9ba6}")
 	iidIWbemObjectTextSrc  = ole.NewGUID("{bfbf883a-cad7-11d3-a11b-00105a1f515a}")
 
-	wmiWbemTxtLocator *ole.IUnknown
-	wmiWbemLocator    *ole.IUnknown
-
 	clsidWbemLocator = ole.NewGUID("4590f811-1d3a-11d0-891f-00aa004b2e24")
 	iidIWbemLocator  = ole.NewGUID("dc12a687-737f-11cf-884d-00aa004b2e24")
 )
@@ -57,35 +56,6 @@ const (
 	RPC_C_IMP_LEVEL_IMPERSONATE = 3
 )
 
-func init() {
-	var err error
-
-	err = ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED)
-	if err != nil {
-		if oleCode, ok := err.(*ole.OleError); ok {
-			code := oleCode.Code()
-			// 1 = Already init
-			if code != 0 && code != 1 {
-				logrus.Errorf("Unable to initialize COM: %s", err.Error())
-				return
-			}
-		}
-	}
-
-	initSecurity()
-
-	wmiWbemLocator, err = ole.CreateInstance(clsidWbemLocator, iidIWbemLocator)
-	if err != nil {
-		logrus.Errorf("Could not initialize Wbem components, WMI operations will likely fail %s", err.Error())
-	}
-
-	// IID_IWbemObjectTextSrc Obtain the initial locator to WMI
-	wmiWbemTxtLocator, err = ole.CreateInstance(clsidWbemObjectTextSrc, iidIWbemObjectTextSrc)
-	if err != nil {
-		logrus.Errorf("Could not initialize Wbem components, WMI operations will likely fail %s", err.Error())
-	}
-}
-
 func initSecurity() {
 	var svc int32 = -1
 
@@ -100,6 +70,6 @@ func initSecurity() {
 		uintptr(EOAC_NONE),                   // [in]           DWORD                       dwCapabilities,
 		uintptr(0))                           // [in, optional] void                        *pReserved3
 	if int(res) < 0 {
-		logrus.Errorf("Unable to initialize COM security: %s", NewWmiError(res).Error())
+		GetTelemetry().Log().Error("Unable to initialize COM security", "error", NewWmiError(res))
 	}
 }
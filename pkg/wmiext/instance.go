@@ -2,15 +2,11 @@ package wmiext
 
 import (
 	"fmt"
-	"github.com/go-ole/go-ole"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 	"reflect"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
-	"unsafe"
 )
 
 const (
@@ -21,51 +17,24 @@ var (
 	WindowsEpoch = time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)
 )
 
+// Instance is a WMI class or instance held by a Backend, which must be released once no longer
+// used. The caller owns the instances it obtains, and releases them with Close, unless they were
+// created through a Service scoped to an Arena, which owns and releases them instead. Values decoded
+// with GetAll into a struct embedding *Instance share the instance, and are owned the same way.
 type Instance struct {
-	object  *ole.IUnknown
-	vTable  *IWbemClassObjectVtbl
+	object  Object
 	service *Service
+	handle  *handle
 }
 
 func (i *Instance) GetService() *Service {
 	return i.service
 }
 
-type IWbemRefresherVtbl struct {
-	QueryInterface uintptr
-	AddRef         uintptr
-	Release        uintptr
-	Refresh        uintptr
-}
-
-type IWbemClassObjectVtbl struct {
-	QueryInterface          uintptr
-	AddRef                  uintptr
-	Release                 uintptr
-	GetQualifierSet         uintptr
-	Get                     uintptr
-	Put                     uintptr
-	Delete                  uintptr
-	GetNames                uintptr
-	BeginEnumeration        uintptr
-	Next                    uintptr
-	EndEnumeration          uintptr
-	GetPropertyQualifierSet uintptr
-	Clone                   uintptr
-	GetObjectText           uintptr
-	SpawnDerivedClass       uintptr
-	SpawnInstance           uintptr
-	CompareTo               uintptr
-	GetPropertyOrigin       uintptr
-	InheritsFrom            uintptr
-	GetMethod               uintptr
-	PutMethod               uintptr
-	DeleteMethod            uintptr
-	BeginMethodEnumeration  uintptr
-	NextMethod              uintptr
-	EndMethodEnumeration    uintptr
-	GetMethodQualifierSet   uintptr
-	GetMethodOrigin         uintptr
+// Object returns the backend object held by this instance, which must not be used once the
+// instance is released.
+func (i *Instance) Object() Object {
+	return i.object
 }
 
 type CIMTYPE_ENUMERATION uint32
@@ -111,21 +80,69 @@ const (
 	WBEM_FLAVOR_MASK_AMENDED                    WBEM_FLAVOR_TYPE = 0x80
 )
 
-func newInstance(object *ole.IUnknown, service *Service) *Instance {
+func newInstance(object Object, service *Service) *Instance {
 	instance := &Instance{
 		object:  object,
-		vTable:  (*IWbemClassObjectVtbl)(unsafe.Pointer(object.RawVTable)),
 		service: service,
 	}
+	instance.handle = service.track(object.Release)
+	watchLeak(instance, "Instance", instance.handle, service)
 
 	return instance
 }
 
-// Close cleans up all memory associated with this instance.
+// Close cleans up all memory associated with this instance, including when owned by an Arena.
+// Closing an instance more than once has no effect, and its operations return ErrReleased
+// afterward.
 func (i *Instance) Close() {
-	if i != nil && i.object != nil {
+	if i == nil || i.object == nil {
+		return
+	}
+	if i.handle == nil {
 		i.object.Release()
+		return
+	}
+	if i.handle.close() && i.service != nil && i.service.arena != nil {
+		i.service.arena.remove(i.handle)
+	}
+}
+
+// CloseInstances closes every instance of a result set, such as the ones returned by FindInstances.
+func CloseInstances(instances []*Instance) {
+	for _, instance := range instances {
+		instance.Close()
+	}
+}
+
+// Detach transfers the ownership of the instance from the Arena of its service to the caller, who
+// must Close it. The instances derived from it afterward are owned by the caller as well.
+func (i *Instance) Detach() {
+	if i == nil || i.service == nil || i.service.arena == nil || i.handle == nil {
+		return
 	}
+	i.service.arena.remove(i.handle)
+	i.service = i.service.root
+	watchLeak(i, "Instance", i.handle, i.service)
+}
+
+// closeUnlessRetained closes an instance decoded into target with GetAll, unless target embeds it
+// and thereby owns it.
+func closeUnlessRetained(i *Instance, target interface{}) {
+	elem := reflect.ValueOf(target)
+	if elem.Kind() == reflect.Ptr && !elem.IsNil() && elem.Elem().Kind() == reflect.Struct {
+		if field := elem.Elem().FieldByName("Instance"); field.IsValid() && field.CanInterface() && field.Interface() == interface{}(i) {
+			return
+		}
+	}
+	i.Close()
+}
+
+// live returns the backend object, or ErrReleased once the instance was released.
+func (i *Instance) live() (Object, error) {
+	if err := i.handle.live(); err != nil {
+		return nil, err
+	}
+	return i.object, nil
 }
 
 // GetClassName Gets the WMI class name for this WMI object instance
@@ -136,7 +153,11 @@ func (i *Instance) GetClassName() (className string, err error) {
 // Path gets the WMI object path of this instance
 func (i *Instance) Path() (string, error) {
 	ref, _, _, err := i.GetAsAny(WmiPathKey)
-	return ref.(string), err
+	if err != nil {
+		return "", err
+	}
+	path, _ := ref.(string)
+	return path, nil
 }
 
 // IsReferenceProperty returns whether the property is of type CIM_REFERENCE, a string which points to
@@ -149,33 +170,27 @@ func (i *Instance) IsReferenceProperty(name string) (bool, error) {
 // SpawnInstance create a new WMI object instance that is zero-initialized. The returned instance
 // will not respect expected default values, which must be populated by other means.
 func (i *Instance) SpawnInstance() (instance *Instance, err error) {
-	var res uintptr
-	var newUnknown *ole.IUnknown
-
-	res, _, _ = syscall.SyscallN(
-		i.vTable.SpawnInstance,               // IWbemClassObject::SpawnInstance(
-		uintptr(unsafe.Pointer(i.object)),    // IWbemClassObject ptr
-		uintptr(0),                           // [in]  long             lFlags,
-		uintptr(unsafe.Pointer(&newUnknown))) // [out] IWbemClassObject **ppNewInstance)
-	if res != 0 {
-		return nil, NewWmiError(res)
+	live, err := i.live()
+	if err != nil {
+		return nil, err
+	}
+	object, err := live.SpawnInstance()
+	if err != nil {
+		return nil, err
 	}
 
-	return newInstance(newUnknown, i.service), nil
+	return newInstance(object, i.service), nil
 }
 
 // CloneInstance create a new cloned copy of this WMI instance.
 func (i *Instance) CloneInstance() (*Instance, error) {
-	classObj := i.object
-	vTable := (*IWbemClassObjectVtbl)(unsafe.Pointer(classObj.RawVTable))
-	var cloned *ole.IUnknown
-
-	ret, _, _ := syscall.SyscallN(
-		vTable.Clone,                      // IWbemClassObject::Clone(
-		uintptr(unsafe.Pointer(classObj)), // IWbemClassObject ptr
-		uintptr(unsafe.Pointer(&cloned)))  // [out] IWbemClassObject **ppCopy)
-	if ret != 0 {
-		return nil, NewWmiError(ret)
+	live, err := i.live()
+	if err != nil {
+		return nil, err
+	}
+	cloned, err := live.Clone()
+	if err != nil {
+		return nil, err
 	}
 
 	return newInstance(cloned, i.service), nil
@@ -238,71 +253,32 @@ func (i *Instance) instancePutAllTraverse(val reflect.Value, propMap map[string]
 
 // Put sets the specified property to the passed Golang value, converting appropriately.
 func (i *Instance) Put(name string, value interface{}) (err error) {
-	var variant ole.VARIANT
-
-	switch cast := value.(type) {
-	case ole.VARIANT:
-		variant = cast
-	case *ole.VARIANT:
-		variant = *cast
-	default:
-		variant, err = NewAutomationVariant(value)
-		if err != nil {
+	if instance, ok := value.(*Instance); ok && instance != nil {
+		if value, err = instance.live(); err != nil {
 			return err
 		}
 	}
 
-	var wszName *uint16
-	if wszName, err = syscall.UTF16PtrFromString(name); err != nil {
-		return
-	}
-
-	classObj := i.object
-	vTable := (*IWbemClassObjectVtbl)(unsafe.Pointer(classObj.RawVTable))
-	res, _, _ := syscall.SyscallN(
-		vTable.Put,                        // IWbemClassObject::Put(
-		uintptr(unsafe.Pointer(classObj)), // IWbemClassObject ptr
-		uintptr(unsafe.Pointer(wszName)),  // [in] LPCWSTR wszName,
-		uintptr(0),                        // [in] long    lFlags,
-		uintptr(unsafe.Pointer(&variant)), // [in] VARIANT *pVal,
-		uintptr(0))                        // [in] CIMTYPE Type)
-	if res != 0 {
-		return NewWmiError(res)
+	live, err := i.live()
+	if err != nil {
+		return err
 	}
-
-	_ = variant.Clear()
-	return
+	return live.Put(name, value)
 }
 
 // GetCimText returns the CIM XML representation of this instance. Some WMI methods use a string
 // parameter to represent a full complex object, and this method is used to generate
 // the expected format.
 func (i *Instance) GetCimText() string {
-	type wmiWbemTxtSrcVtable struct {
-		QueryInterface uintptr
-		AddRef         uintptr
-		Release        uintptr
-		GetTxt         uintptr
-	}
-	const CIM_XML_FORMAT = 1
-
-	classObj := i.object
-
-	vTable := (*wmiWbemTxtSrcVtable)(unsafe.Pointer(wmiWbemTxtLocator.RawVTable))
-	var retString *uint16
-	res, _, _ := syscall.SyscallN(
-		vTable.GetTxt,                           // IWbemObjectTextSrc::GetText()
-		uintptr(unsafe.Pointer(wmiWbemLocator)), // IWbemObjectTextSrc ptr
-		uintptr(0),                              // [in]  long             lFlags
-		uintptr(unsafe.Pointer(classObj)),       // [in]  IWbemClassObject *pObj
-		uintptr(CIM_XML_FORMAT),                 // [in]  ULONG            uObjTextFormat,
-		uintptr(0),                              // [in]  IWbemContext     *pCtx,
-		uintptr(unsafe.Pointer(&retString)))     // [out] BSTR             *strText)
-	if res != 0 {
+	live, err := i.live()
+	if err != nil {
 		return ""
 	}
-	itemStr := ole.BstrToString(retString)
-	return itemStr
+	text, err := live.CimText()
+	if err != nil {
+		return ""
+	}
+	return text
 }
 
 // GetAll gets all fields that map to a target struct and populates all struct fields according to
@@ -323,39 +299,21 @@ func (i *Instance) GetAll(target interface{}) error {
 		field.Set(reflect.ValueOf(i))
 	}
 
-	var err error
-
-	if err = i.BeginEnumeration(); err != nil {
+	live, err := i.live()
+	if err != nil {
 		return err
 	}
-
-	properties := make(map[string]*ole.VARIANT)
-
-	for {
-		var name string
-		var value *ole.VARIANT
-		var done bool
-
-		if done, name, value, _, _, err = i.NextAsVariant(); err != nil {
-			return err
-		}
-
-		if done {
-			break
-		}
-
-		if value != nil {
-			properties[name] = value
-		}
+	props, err := live.Properties()
+	if err != nil {
+		return err
 	}
 
-	defer func() {
-		for _, v := range properties {
-			_ = v.Clear()
+	properties := make(map[string]interface{}, len(props))
+	for _, prop := range props {
+		if prop.Value != nil {
+			properties[prop.Name] = prop.Value
 		}
-	}()
-
-	_ = i.EndEnumeration()
+	}
 
 	return i.instanceGetAllPopulate(elem, elem.Type(), properties)
 }
@@ -364,37 +322,21 @@ func (i *Instance) GetAll(target interface{}) error {
 // variant automation type passed back from WMI. For usage with predictable static
 // type mapping, use GetAsString(), GetAsUint(), or GetAll() instead of this method.
 func (i *Instance) GetAsAny(name string) (interface{}, CIMTYPE_ENUMERATION, WBEM_FLAVOR_TYPE, error) {
-	variant, cimType, flavor, err := i.GetAsVariant(name)
+	live, err := i.live()
 	if err != nil {
-		return nil, cimType, flavor, err
+		return nil, CIM_EMPTY, 0, err
 	}
-
-	defer func() {
-		if err := variant.Clear(); err != nil {
-			logrus.Error(err)
-		}
-	}()
-
-	// Since there is no type information only perform the stock conversion
-	result := convertToGenericValue(variant)
-
-	return result, cimType, flavor, err
+	return live.Get(name)
 }
 
 // GetAsString gets a property value as a string value, converting if necessary
 func (i *Instance) GetAsString(name string) (value string, err error) {
-	variant, _, _, err := i.GetAsVariant(name)
-	if err != nil || variant == nil {
+	val, _, _, err := i.GetAsAny(name)
+	if err != nil || val == nil {
 		return "", err
 	}
-	defer func() {
-		if err := variant.Clear(); err != nil {
-			logrus.Error(err)
-		}
-	}()
 
-	// TODO: replace with something better
-	return fmt.Sprintf("%v", convertToGenericValue(variant)), nil
+	return convertToString(val), nil
 }
 
 // GetAsUint gets a property value as a uint value, if conversion is possible. Otherwise,
@@ -406,6 +348,8 @@ func (i *Instance) GetAsUint(name string) (uint, error) {
 	}
 
 	switch ret := val.(type) {
+	case nil:
+		return 0, nil
 	case int:
 		return uint(ret), nil
 	case int8:
@@ -434,118 +378,25 @@ func (i *Instance) GetAsUint(name string) (uint, error) {
 	}
 }
 
-// GetAsVariant obtains a specified property value, if it exists.
-func (i *Instance) GetAsVariant(name string) (*ole.VARIANT, CIMTYPE_ENUMERATION, WBEM_FLAVOR_TYPE, error) {
-	var variant ole.VARIANT
-	var err error
-	var wszName *uint16
-	var cimType CIMTYPE_ENUMERATION
-	var flavor WBEM_FLAVOR_TYPE
-
-	if wszName, err = syscall.UTF16PtrFromString(name); err != nil {
-		return nil, 0, 0, err
-	}
-
-	classObj := i.object
-	vTable := (*IWbemClassObjectVtbl)(unsafe.Pointer(classObj.RawVTable))
-
-	res, _, _ := syscall.SyscallN(
-		vTable.Get,                        // IWbemClassObject::Get(
-		uintptr(unsafe.Pointer(classObj)), // IWbemClassObject ptr
-		uintptr(unsafe.Pointer(wszName)),  // [in]            LPCWSTR wszName,
-		uintptr(0),                        // [in]            long    lFlags,
-		uintptr(unsafe.Pointer(&variant)), // [out]           VARIANT *pVal,
-		uintptr(unsafe.Pointer(&cimType)), // [out, optional] CIMTYPE *pType,
-		uintptr(unsafe.Pointer(&flavor)))  // [out, optional] long    *plFlavor)
-	if res != 0 {
-		return nil, 0, 0, NewWmiError(res)
-	}
-
-	return &variant, cimType, flavor, nil
-}
-
-// Next retrieves the next property as a Golang type when iterating the properties using an enumerator
-// created by BeginEnumeration(). The returned value's type represents the internal automation type
-// used by WMI. It is usually preferred to use GetAsXXX(), GetAll(), or GetAll Properties() over this
-// method.
-func (i *Instance) Next() (done bool, name string, value interface{}, cimType CIMTYPE_ENUMERATION, flavor WBEM_FLAVOR_TYPE, err error) {
-	var variant *ole.VARIANT
-	done, name, variant, cimType, flavor, err = i.NextAsVariant()
-
-	if err == nil && !done {
-		defer func() {
-			if err := variant.Clear(); err != nil {
-				logrus.Error(err)
-			}
-		}()
-		value = convertToGenericValue(variant)
-	}
-
-	return
-}
-
-// NextAsVariant retrieves the next property as a VARIANT type when iterating the properties using an enumerator
-// created by BeginEnumeration(). The returned value's type represents the internal automation type
-// used by WMI. It is usually preferred to use GetAsXXX(), GetAll(), or GetAllProperties() over this
-// method. Callers are responsible for clearing the VARIANT, otherwise associated memory will leak.
-func (i *Instance) NextAsVariant() (bool, string, *ole.VARIANT, CIMTYPE_ENUMERATION, WBEM_FLAVOR_TYPE, error) {
-	var res uintptr
-	var strName *uint16
-	var variant ole.VARIANT
-	var cimType CIMTYPE_ENUMERATION
-	var flavor WBEM_FLAVOR_TYPE
-
-	res, _, _ = syscall.SyscallN(
-		i.vTable.Next,                     // IWbemClassObject::Next(
-		uintptr(unsafe.Pointer(i.object)), // IWbemClassObject ptr
-		uintptr(0),                        // [in]            long    lFlags,
-		uintptr(unsafe.Pointer(&strName)), // [out]           BSTR    *strName,
-		uintptr(unsafe.Pointer(&variant)), // [out]           VARIANT *pVal,
-		uintptr(unsafe.Pointer(&cimType)), // [out, optional] CIMTYPE *pType,
-		uintptr(unsafe.Pointer(&flavor)))  // [out, optional] long    *plFlavor
-	if int(res) < 0 {
-		return false, "", nil, cimType, flavor, NewWmiError(res)
-	}
-
-	if res == WBEM_S_NO_MORE_DATA {
-		return true, "", nil, cimType, flavor, nil
-	}
-
-	defer ole.SysFreeString((*int16)(unsafe.Pointer(strName))) //nolint:errcheck
-	name := ole.BstrToString(strName)
-
-	return false, name, &variant, cimType, flavor, nil
-}
-
 // GetAllProperties gets all properties on this instance. The returned map is keyed by the field name and the value
 // is a Golang type which matches the WMI internal implementation. For static type conversions,
 // it's recommended to use either GetAll(), which uses struct fields for type information, or
 // the GetAsXXX() methods.
 func (i *Instance) GetAllProperties() (map[string]interface{}, error) {
-	var err error
-	properties := make(map[string]interface{})
-
-	if err = i.BeginEnumeration(); err != nil {
+	live, err := i.live()
+	if err != nil {
+		return nil, err
+	}
+	props, err := live.Properties()
+	if err != nil {
 		return nil, err
 	}
 
-	defer func() {
-		if err := i.EndEnumeration(); err != nil {
-			logrus.Error(err)
-		}
-	}()
-
-	for {
-		var name string
-		var value interface{}
-		var done bool
-
-		if done, name, value, _, _, err = i.Next(); err != nil || done {
-			return properties, err
-		}
-
-		properties[name] = value
+	properties := make(map[string]interface{}, len(props))
+	for _, prop := range props {
+		properties[prop.Name] = prop.Value
 	}
+	return properties, nil
 }
 
 // GetMethodParameters returns a WMI class object which represents the [in] method parameters for a method invocation.
@@ -553,30 +404,19 @@ func (i *Instance) GetAllProperties() (map[string]interface{}, error) {
 // cases it is recommended to use Method() instead, which constructs the parameter payload
 // automatically.
 func (i *Instance) GetMethodParameters(method string) (*Instance, error) {
-	var err error
-	var res uintptr
-	var inSignature *ole.IUnknown
-
-	var wszName *uint16
-	if wszName, err = syscall.UTF16PtrFromString(method); err != nil {
+	live, err := i.live()
+	if err != nil {
 		return nil, err
 	}
-
-	res, _, _ = syscall.SyscallN(
-		i.vTable.GetMethod,                    // IWbemClassObject::GetMethod(
-		uintptr(unsafe.Pointer(i.object)),     // IWbemClassObject ptr
-		uintptr(unsafe.Pointer(wszName)),      // [in]  LPCWSTR          wszName
-		uintptr(0),                            // [in]  long             lFlags,
-		uintptr(unsafe.Pointer(&inSignature)), // [out] IWbemClassObject **ppInSignature,
-		uintptr(0))                            // [out] IWbemClassObject **ppOutSignature)
-	if res != 0 {
-		return nil, NewWmiError(res)
+	inSignature, err := live.MethodParameters(method)
+	if err != nil || inSignature == nil {
+		return nil, err
 	}
 
 	return newInstance(inSignature, i.service), nil
 }
 
-func (i *Instance) instanceGetAllPopulate(elem reflect.Value, elemType reflect.Type, properties map[string]*ole.VARIANT) error {
+func (i *Instance) instanceGetAllPopulate(elem reflect.Value, elemType reflect.Type, properties map[string]interface{}) error {
 	var err error
 
 	for j := 0; j < elemType.NumField(); j++ {
@@ -599,10 +439,10 @@ func (i *Instance) instanceGetAllPopulate(elem reflect.Value, elemType reflect.T
 		if strings.HasPrefix(fieldName, "S__") {
 			fieldName = fieldName[1:]
 		}
-		if variant, ok := properties[fieldName]; ok {
+		if value, ok := properties[fieldName]; ok {
 			var val interface{}
-			if val, err = convertToGoType(variant, fieldVal, fieldType.Type); err != nil {
-				return err
+			if val, err = convertToGoType(value, fieldVal, fieldType.Type, i.service); err != nil {
+				return errors.Wrapf(err, "property %s", fieldName)
 			}
 
 			if val != nil {
@@ -614,38 +454,6 @@ func (i *Instance) instanceGetAllPopulate(elem reflect.Value, elemType reflect.T
 	return nil
 }
 
-// BeginEnumeration begins iterating the property list on this instance. This is an advanced method.
-// In most cases, the GetAsXXX() methods, GetAll(), and GetAllProperties() methods should be
-// preferred.
-func (i *Instance) BeginEnumeration() error {
-	classObj := i.object
-	vTable := (*IWbemClassObjectVtbl)(unsafe.Pointer(classObj.RawVTable))
-
-	result, _, _ := syscall.SyscallN(
-		vTable.BeginEnumeration,           // IWbemClassObject::BeginEnumeration(
-		uintptr(unsafe.Pointer(classObj)), // IWbemClassObject ptr,
-		uintptr(0))                        // [in] long lEnumFlags) // 0 = defaults
-	if result != 0 {
-		return NewWmiError(result)
-	}
-
-	return nil
-}
-
-// EndEnumeration completes iterating a property list on this instance. This is an advanced method.
-// In most cases, the GetAsXXX() methods, GetAll(), and GetAllProperties() methods
-// should be preferred.
-func (i *Instance) EndEnumeration() error {
-	res, _, _ := syscall.SyscallN(
-		i.vTable.EndEnumeration,           // IWbemClassObject::EndEnumeration(
-		uintptr(unsafe.Pointer(i.object))) // IWbemClassObject ptr)
-	if res != 0 {
-		return NewWmiError(res)
-	}
-
-	return nil
-}
-
 // Method invokes a method on this Instance. Returns a MethodExecutor builder object
 // that is used to construct the input parameters (via calls to In()), perform the
 // invocation (using calls to Execute()), retrieve output parameters (via calls to
@@ -666,23 +474,16 @@ func (i *Instance) Method(method string) *MethodExecutor {
 }
 
 // Refresh refreshes the instance data from the WMI provider.
-// See https://learn.microsoft.com/en-us/windows/win32/api/wbemcli/nf-wbemcli-iwbemrefresher-refresh
 func (i *Instance) Refresh() error {
-	var hr uintptr
-
-	vTable := (*IWbemRefresherVtbl)(unsafe.Pointer(i.object.RawVTable))
-
-	if hr, _, _ = syscall.SyscallN(
-		vTable.Refresh,                    // IWbemRefresher::Refresh(
-		uintptr(unsafe.Pointer(i.object)), // IWbemRefresher ptr
-		// [in]  long             lFlags)
-	); hr != 0 {
-		return NewWmiError(hr)
+	live, err := i.live()
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return live.Refresh()
 }
 
+// GetRelated returns the first instance of className associated with this instance, owned like the
+// instances of its service.
 func (i *Instance) GetRelated(className string) (*Instance, error) {
 	path, err := i.Path()
 	if err != nil {
@@ -691,6 +492,8 @@ func (i *Instance) GetRelated(className string) (*Instance, error) {
 	return i.service.FindFirstRelatedInstance(path, className)
 }
 
+// GetAllRelated returns the instances of className associated with this instance, owned like the
+// instances of its service. Unscoped callers release them with CloseInstances.
 func (i *Instance) GetAllRelated(className string) ([]*Instance, error) {
 	path, err := i.Path()
 	if err != nil {
@@ -699,6 +502,8 @@ func (i *Instance) GetAllRelated(className string) ([]*Instance, error) {
 	return i.service.FindRelatedInstances(path, className)
 }
 
+// GetReferences returns the associations of className referring to this instance, owned like the
+// instances of its service.
 func (i *Instance) GetReferences(className string) ([]*Instance, error) {
 	path, err := i.Path()
 	if err != nil {
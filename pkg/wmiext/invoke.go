@@ -1,14 +1,8 @@
-//go:build windows
-// +build windows
-
 package wmiext
 
 import (
 	"fmt"
 	"reflect"
-
-	"github.com/go-ole/go-ole"
-	"github.com/sirupsen/logrus"
 )
 
 type MethodExecutor struct {
@@ -48,7 +42,7 @@ func (e *MethodExecutor) In(name string, value interface{}) *MethodExecutor {
 // The value parameter must be a reference to the field that should be set.
 func (e *MethodExecutor) Out(name string, value interface{}) *MethodExecutor {
 	if e.err == nil && e.outParam != nil {
-		var variant *ole.VARIANT
+		var raw interface{}
 		var cimType CIMTYPE_ENUMERATION
 		var result interface{}
 		dest := reflect.ValueOf(value)
@@ -58,26 +52,18 @@ func (e *MethodExecutor) Out(name string, value interface{}) *MethodExecutor {
 		}
 		dest = dest.Elem()
 
-		variant, cimType, _, e.err = e.outParam.GetAsVariant(name)
-		if e.err != nil || variant == nil {
+		raw, cimType, _, e.err = e.outParam.GetAsAny(name)
+		if e.err != nil {
 			return e
 		}
 
-		defer func() {
-			if err := variant.Clear(); err != nil {
-				logrus.Error(err)
-			}
-		}()
-
-		if _, ok := value.(**Instance); ok && cimType == CIM_REFERENCE {
-			path := variant.ToString()
+		if path, ok := raw.(string); ok && cimType == CIM_REFERENCE && dest.Type() == instanceType {
 			result, e.err = e.service.GetObject(path)
 			if e.err != nil {
 				return e
 			}
 		} else {
-			target := reflect.ValueOf(value).Elem()
-			result, e.err = convertToGoType(variant, target, target.Type())
+			result, e.err = convertToGoType(raw, dest, dest.Type(), e.service)
 			if e.err != nil {
 				return e
 			}
@@ -1,53 +1,462 @@
-//go:build windows
-// +build windows
-
 package wmiext
 
 import (
+	"context"
 	"fmt"
 	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JobState is the JobState property of CIM_ConcreteJob.
+type JobState uint16
+
+const (
+	JobStateNew          JobState = 2
+	JobStateStarting     JobState = 3
+	JobStateRunning      JobState = 4
+	JobStateSuspended    JobState = 5
+	JobStateShuttingDown JobState = 6
+	JobStateCompleted    JobState = 7
+	JobStateTerminated   JobState = 8
+	JobStateKilled       JobState = 9
+	JobStateException    JobState = 10
+	JobStateService      JobState = 11
 )
 
+// Finished returns whether the job reached a final state, successful or not.
+func (s JobState) Finished() bool {
+	return s >= JobStateCompleted
+}
+
+func (s JobState) String() string {
+	switch s {
+	case JobStateNew:
+		return "New"
+	case JobStateStarting:
+		return "Starting"
+	case JobStateRunning:
+		return "Running"
+	case JobStateSuspended:
+		return "Suspended"
+	case JobStateShuttingDown:
+		return "Shutting Down"
+	case JobStateCompleted:
+		return "Completed"
+	case JobStateTerminated:
+		return "Terminated"
+	case JobStateKilled:
+		return "Killed"
+	case JobStateException:
+		return "Exception"
+	case JobStateService:
+		return "Service"
+	default:
+		return fmt.Sprintf("JobState(%d)", uint16(s))
+	}
+}
+
+// Values of the RequestedState parameter of CIM_ConcreteJob.RequestStateChange
+const (
+	jobRequestTerminate = 4
+)
+
+// JobError is returned by Job.Wait when the job did not complete successfully.
 type JobError struct {
-	ErrorCode int
+	ErrorCode          int
+	Description        string
+	SummaryDescription string
+	State              JobState
+	Path               string
 }
 
 func (err *JobError) Error() string {
-	return fmt.Sprintf("Job failed with error code: %d", err.ErrorCode)
+	if err.Description == "" {
+		return fmt.Sprintf("Job failed with error code: %d", err.ErrorCode)
+	}
+	return fmt.Sprintf("Job failed with error code: %d (%s)", err.ErrorCode, err.Description)
 }
 
-// WaitJob waits on the specified job instance until it has completed and
-// returns a JobError containing the result code in the event of
-// a failure.
-func WaitJob(service *Service, job *Instance) error {
-	var jobs []*Instance
-	defer func() {
-		for _, job := range jobs {
-			job.Close()
+// JobStatus is a snapshot of the progress of a job.
+type JobStatus struct {
+	State                   JobState
+	PercentComplete         uint16
+	ErrorCode               uint16
+	ErrorDescription        string
+	ErrorSummaryDescription string
+	// Elapsed is the time since the job was observed first.
+	Elapsed time.Duration
+}
+
+// JobResult is the final status of a job, along with the number of polls it took to observe it.
+type JobResult struct {
+	JobStatus
+	Polls int
+}
+
+// ProgressFunc receives the status of a job each time its state or completion percentage changes.
+type ProgressFunc func(status JobStatus)
+
+// Clock abstracts the passing of time for waiting on jobs.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SystemClock is the Clock of the operating system.
+var SystemClock Clock = systemClock{}
+
+// PollStrategy returns the delay before the next poll of a job, attempt starting at 0.
+type PollStrategy func(attempt int) time.Duration
+
+// ConstantPoll polls jobs at a fixed interval.
+func ConstantPoll(interval time.Duration) PollStrategy {
+	return func(int) time.Duration {
+		return interval
+	}
+}
+
+// ExponentialPoll polls jobs at an interval doubling from initial up to max, so short jobs complete
+// quickly without flooding the provider during long ones.
+func ExponentialPoll(initial time.Duration, max time.Duration) PollStrategy {
+	return func(attempt int) time.Duration {
+		interval := initial
+		for i := 0; i < attempt && interval < max; i++ {
+			interval *= 2
 		}
-	}()
-	for {
-		state, _, _, err := job.GetAsAny("JobState")
-		if err != nil {
-			return err
+		if interval > max {
+			return max
 		}
-		time.Sleep(100 * time.Millisecond)
-		job, _ = service.RefetchObject(job)
-		jobs = append(jobs, job)
-		// 7+ = completed
-		if state.(int32) >= 7 {
-			break
+		return interval
+	}
+}
+
+// DefaultPollStrategy is the PollStrategy of jobs created without WithPollStrategy.
+var DefaultPollStrategy = ExponentialPoll(100*time.Millisecond, 2*time.Second)
+
+// JobOption configures a Job.
+type JobOption func(job *Job)
+
+// WithClock sets the clock used to wait between polls and to measure elapsed time.
+func WithClock(clock Clock) JobOption {
+	return func(job *Job) {
+		job.clock = clock
+	}
+}
+
+// WithPollStrategy sets the delay between polls of the job state.
+func WithPollStrategy(strategy PollStrategy) JobOption {
+	return func(job *Job) {
+		job.poll = strategy
+	}
+}
+
+// WithOperation describes the operation performed by the job, prefixing the errors returned by Wait.
+func WithOperation(operation string) JobOption {
+	return func(job *Job) {
+		job.operation = operation
+	}
+}
+
+// WithErrorTranslator converts the errors returned by Wait, such as a JobError, into domain errors. It
+// applies before WithOperation.
+func WithErrorTranslator(translate func(err error) error) JobOption {
+	return func(job *Job) {
+		job.translate = translate
+	}
+}
+
+// WithProgress registers a callback invoked when the state or completion percentage of the job changes.
+func WithProgress(progress ProgressFunc) JobOption {
+	return func(job *Job) {
+		job.progress = progress
+	}
+}
+
+// Job tracks a CIM_ConcreteJob returned by a long-running method, or the synchronous completion of
+// the method.
+type Job struct {
+	service   *Service
+	instance  *Instance
+	path      string
+	operation string
+	translate func(err error) error
+	clock     Clock
+	poll      PollStrategy
+	progress  ProgressFunc
+	started   time.Time
+	last      *JobStatus
+	final     *JobStatus
+	// owned is set when the instance must be closed by the job
+	owned bool
+}
+
+// NewJob tracks the job instance returned by a method. The job takes ownership of the instance, which
+// is released when Wait returns or by Close.
+func NewJob(service *Service, instance *Instance, opts ...JobOption) *Job {
+	job := &Job{service: service, instance: instance, clock: SystemClock, poll: DefaultPollStrategy, owned: true}
+	for _, opt := range opts {
+		opt(job)
+	}
+	job.started = job.clock.Now()
+	if instance != nil {
+		job.path, _ = instance.Path()
+	}
+	return job
+}
+
+// CompletedJob returns a job for a method that completed synchronously.
+func CompletedJob(opts ...JobOption) *Job {
+	job := NewJob(nil, nil, opts...)
+	job.final = &JobStatus{State: JobStateCompleted, PercentComplete: 100}
+	return job
+}
+
+// NewMethodJob returns the job of a method based on its return value, 0 for synchronous completion and
+// 4096 when the job instance tracks the operation. Other values are returned as a JobError.
+func NewMethodJob(service *Service, returnValue int32, instance *Instance, opts ...JobOption) (*Job, error) {
+	if returnValue == 4096 {
+		if instance == nil {
+			return nil, errors.New("method returned 4096 without a job")
+		}
+		return NewJob(service, instance, opts...), nil
+	}
+
+	if instance != nil {
+		instance.Close()
+	}
+	job := CompletedJob(opts...)
+	if returnValue != 0 {
+		return nil, job.wrap(&JobError{ErrorCode: int(returnValue)})
+	}
+	return job, nil
+}
+
+// Path returns the object path of the job instance, empty for a synchronously completed job.
+func (j *Job) Path() string {
+	return j.path
+}
+
+// Elapsed returns the time since the job was created.
+func (j *Job) Elapsed() time.Duration {
+	return j.clock.Now().Sub(j.started)
+}
+
+// Status fetches the current status of the job, or returns the final one once Wait observed it.
+func (j *Job) Status() (*JobStatus, error) {
+	if j.final != nil {
+		status := *j.final
+		if status.Elapsed == 0 {
+			status.Elapsed = j.Elapsed()
 		}
+		return &status, nil
+	}
+	if j.instance == nil {
+		return nil, errors.Errorf("job %s is closed", j.path)
 	}
 
-	result, _, _, err := job.GetAsAny("ErrorCode")
+	refreshed, err := j.service.RefetchObject(j.instance)
 	if err != nil {
+		return nil, errors.Wrapf(err, "failed to refresh job %s", j.Path())
+	}
+	if j.owned {
+		j.instance.Close()
+	}
+	j.instance = refreshed
+	j.owned = true
+
+	var job struct {
+		JobState                uint16
+		PercentComplete         uint16
+		ErrorCode               uint16
+		ErrorDescription        string
+		ErrorSummaryDescription string
+	}
+	if err = refreshed.GetAll(&job); err != nil {
+		return nil, err
+	}
+	return &JobStatus{
+		State:                   JobState(job.JobState),
+		PercentComplete:         job.PercentComplete,
+		ErrorCode:               job.ErrorCode,
+		ErrorDescription:        job.ErrorDescription,
+		ErrorSummaryDescription: job.ErrorSummaryDescription,
+		Elapsed:                 j.Elapsed(),
+	}, nil
+}
+
+// Wait polls the job until it finished, and returns a JobError when it did not complete successfully.
+// When ctx is done first, the job is terminated and the context error returned. The job instance is
+// released when Wait returns.
+func (j *Job) Wait(ctx context.Context) (*JobResult, error) {
+	if j.instance == nil {
+		result, err := j.wait(ctx)
+		return result, j.wrap(err)
+	}
+
+	observation := j.service.Telemetry().observe(ctx, "Job", MetricJobDuration, pathAttributes(j.path)...)
+	result, err := j.wait(observation.ctx)
+	err = j.wrap(err)
+
+	var outcome []Attribute
+	if result != nil {
+		outcome = []Attribute{Attr(AttributeJobState, result.State.String()), Attr(AttributeJobPolls, result.Polls)}
+	}
+	observation.endAfter(j.Elapsed(), err, outcome...)
+	return result, err
+}
+
+// wrap translates errors and prefixes them with the operation of the job.
+func (j *Job) wrap(err error) error {
+	if err != nil && j.translate != nil {
+		err = j.translate(err)
+	}
+	if err == nil || j.operation == "" {
 		return err
 	}
+	return fmt.Errorf("%s: %w", j.operation, err)
+}
+
+func (j *Job) wait(ctx context.Context) (*JobResult, error) {
+	defer j.Close()
+
+	for attempt := 0; ; attempt++ {
+		status, err := j.Status()
+		if err != nil {
+			return nil, err
+		}
+		j.notify(status)
+
+		if status.State.Finished() {
+			j.final = status
+			result := &JobResult{JobStatus: *status, Polls: attempt + 1}
+			if status.State != JobStateCompleted || status.ErrorCode != 0 {
+				return result, &JobError{
+					ErrorCode:          int(status.ErrorCode),
+					Description:        status.ErrorDescription,
+					SummaryDescription: status.ErrorSummaryDescription,
+					State:              status.State,
+					Path:               j.path,
+				}
+			}
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if cancelErr := j.Cancel(); cancelErr != nil {
+				return nil, errors.Wrapf(ctx.Err(), "failed to terminate job %s: %s", j.path, cancelErr)
+			}
+			return nil, ctx.Err()
+		case <-j.clock.After(j.poll(attempt)):
+		}
+	}
+}
 
-	if result.(int32) != 0 {
-		return &JobError{ErrorCode: int(result.(int32))}
+func (j *Job) notify(status *JobStatus) {
+	if j.progress != nil && (j.last == nil || j.last.State != status.State || j.last.PercentComplete != status.PercentComplete) {
+		j.progress(*status)
 	}
+	j.last = status
+}
 
+// Cancel requests the termination of the job, killing it when the provider does not support
+// RequestStateChange.
+func (j *Job) Cancel() error {
+	if j.instance == nil {
+		return nil
+	}
+
+	var returnValue int32
+	err := j.instance.Method("RequestStateChange").
+		In("RequestedState", uint16(jobRequestTerminate)).
+		Execute().
+		Out("ReturnValue", &returnValue).
+		End()
+	if err == nil && returnValue == 0 {
+		return nil
+	}
+
+	if killErr := j.instance.Method("KillJob").
+		In("DeleteOnCompletion", false).
+		Execute().
+		Out("ReturnValue", &returnValue).
+		End(); killErr != nil {
+		return killErr
+	}
+	if returnValue != 0 {
+		return &JobError{ErrorCode: int(returnValue), Description: "KillJob failed", Path: j.path}
+	}
 	return nil
 }
+
+// Close releases the job instance.
+func (j *Job) Close() {
+	if j.instance != nil && j.owned {
+		j.instance.Close()
+	}
+	j.instance = nil
+}
+
+// WaitJob waits on the specified job instance until it has completed and
+// returns a JobError containing the result code in the event of
+// a failure.
+//
+// Deprecated: use NewJob and Job.Wait, which support cancellation and progress reporting.
+func WaitJob(service *Service, job *Instance) error {
+	j := NewJob(service, job)
+	// The instance remains owned by the caller, the refetched ones are released along with the job
+	j.owned = false
+	defer j.Close()
+	_, err := j.Wait(context.Background())
+	return err
+}
+
+// ResultJob is a Job whose Wait returns the result of the method that started it, once the job
+// completed.
+type ResultJob[T any] struct {
+	*Job
+	result func() (T, error)
+}
+
+// NewResultJob couples a job with the function retrieving the result of the method.
+func NewResultJob[T any](job *Job, result func() (T, error)) *ResultJob[T] {
+	return &ResultJob[T]{Job: job, result: result}
+}
+
+// Wait waits for the job as Job.Wait does, and returns the result of the method.
+func (j *ResultJob[T]) Wait(ctx context.Context) (T, error) {
+	if _, err := j.Job.Wait(ctx); err != nil {
+		var zero T
+		return zero, err
+	}
+	return j.result()
+}
+
+// Await waits for the job returned by a method along with err, for callers without a context.
+func Await[T any](job *ResultJob[T], err error) (T, error) {
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return job.Wait(context.Background())
+}
+
+// AwaitJob waits for the job returned by a method along with err, for callers without a context.
+func AwaitJob(job *Job, err error) error {
+	if err != nil {
+		return err
+	}
+	_, err = job.Wait(context.Background())
+	return err
+}
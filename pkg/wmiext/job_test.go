@@ -0,0 +1,192 @@
+package wmiext
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedClock advances its time by the requested delay on each After call, and applies the next
+// step of a script beforehand, simulating the job progressing between polls.
+type scriptedClock struct {
+	now    time.Time
+	delays []time.Duration
+	steps  []func()
+}
+
+func (c *scriptedClock) Now() time.Time {
+	return c.now
+}
+
+func (c *scriptedClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+	c.now = c.now.Add(d)
+	if len(c.steps) > 0 {
+		c.steps[0]()
+		c.steps = c.steps[1:]
+	}
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+type jobFixture struct {
+	repo    *MemoryRepository
+	service *Service
+	path    string
+	calls   []string
+}
+
+func newJobFixture(t *testing.T, state JobState) *jobFixture {
+	f := &jobFixture{repo: NewMemoryRepository(testNamespace)}
+	f.repo.DefineClass(MemoryClass{
+		Name: "Msvm_ConcreteJob",
+		Keys: []string{"InstanceID"},
+		Methods: map[string]map[string]CIMTYPE_ENUMERATION{
+			"RequestStateChange": {"RequestedState": CIM_UINT16, "TimeoutPeriod": CIM_DATETIME},
+			"KillJob":            {"DeleteOnCompletion": CIM_BOOLEAN},
+		},
+	})
+
+	var err error
+	f.path, err = f.repo.AddInstance("Msvm_ConcreteJob", map[string]interface{}{
+		"InstanceID":       "job-1",
+		"JobState":         uint16(state),
+		"PercentComplete":  uint16(0),
+		"ErrorCode":        uint16(0),
+		"ErrorDescription": "",
+	})
+	require.NoError(t, err)
+
+	f.service = f.repo.Service()
+	t.Cleanup(f.service.Close)
+	return f
+}
+
+func (f *jobFixture) set(t *testing.T, state JobState, percent uint16) func() {
+	return func() {
+		require.NoError(t, f.repo.Update(f.path, map[string]interface{}{"JobState": uint16(state), "PercentComplete": percent}))
+	}
+}
+
+func (f *jobFixture) job(t *testing.T, opts ...JobOption) *Job {
+	instance, err := f.service.GetObject(f.path)
+	require.NoError(t, err)
+	return NewJob(f.service, instance, opts...)
+}
+
+func TestJob_WaitProgress(t *testing.T) {
+	f := newJobFixture(t, JobStateNew)
+	clock := &scriptedClock{now: time.Unix(1000, 0)}
+	clock.steps = []func(){
+		f.set(t, JobStateRunning, 10),
+		f.set(t, JobStateRunning, 10),
+		f.set(t, JobStateRunning, 60),
+		f.set(t, JobStateCompleted, 100),
+	}
+
+	var progress []JobStatus
+	job := f.job(t,
+		WithClock(clock),
+		WithPollStrategy(ExponentialPoll(time.Second, 3*time.Second)),
+		WithProgress(func(status JobStatus) { progress = append(progress, status) }),
+	)
+	assert.Equal(t, f.path, job.Path())
+
+	result, err := job.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, JobStateCompleted, result.State)
+	assert.Equal(t, 5, result.Polls)
+	assert.Equal(t, 9*time.Second, result.Elapsed)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}, clock.delays)
+
+	require.Len(t, progress, 4)
+	assert.Equal(t, JobStateNew, progress[0].State)
+	assert.Equal(t, uint16(10), progress[1].PercentComplete)
+	assert.Equal(t, uint16(60), progress[2].PercentComplete)
+	assert.Equal(t, JobStateCompleted, progress[3].State)
+
+	status, err := job.Status()
+	require.NoError(t, err)
+	assert.Equal(t, JobStateCompleted, status.State)
+}
+
+func TestJob_WaitFailure(t *testing.T) {
+	f := newJobFixture(t, JobStateRunning)
+	clock := &scriptedClock{steps: []func(){
+		func() {
+			require.NoError(t, f.repo.Update(f.path, map[string]interface{}{
+				"JobState":         uint16(JobStateException),
+				"ErrorCode":        uint16(32768),
+				"ErrorDescription": "The operation cannot be performed while the virtual machine is in its current state.",
+			}))
+		},
+	}}
+
+	_, err := f.job(t, WithClock(clock), WithOperation("Failed to modify resource settings")).Wait(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "Failed to modify resource settings: Job failed with error code: 32768 "+
+		"(The operation cannot be performed while the virtual machine is in its current state.)", err.Error())
+
+	jobErr, ok := errors.Unwrap(err).(*JobError)
+	require.True(t, ok)
+	assert.Equal(t, 32768, jobErr.ErrorCode)
+	assert.Equal(t, JobStateException, jobErr.State)
+	assert.Equal(t, f.path, jobErr.Path)
+}
+
+func TestJob_WaitCancel(t *testing.T) {
+	f := newJobFixture(t, JobStateRunning)
+	f.repo.HandleMethod("Msvm_ConcreteJob", "RequestStateChange", func(call *MethodCall) error {
+		f.calls = append(f.calls, "RequestStateChange")
+		call.Return(4097)
+		return nil
+	})
+	f.repo.HandleMethod("Msvm_ConcreteJob", "KillJob", func(call *MethodCall) error {
+		f.calls = append(f.calls, "KillJob")
+		return call.Repository.Update(call.Path, map[string]interface{}{"JobState": uint16(JobStateKilled)})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := &scriptedClock{steps: []func(){cancel}}
+	_, err := f.job(t, WithClock(clock), WithPollStrategy(ConstantPoll(time.Hour))).Wait(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"RequestStateChange", "KillJob"}, f.calls)
+
+	instance, err := f.service.GetObject(f.path)
+	require.NoError(t, err)
+	defer instance.Close()
+	state, err := instance.GetAsUint("JobState")
+	require.NoError(t, err)
+	assert.Equal(t, uint(JobStateKilled), state)
+}
+
+func TestNewMethodJob(t *testing.T) {
+	job, err := NewMethodJob(nil, 0, nil)
+	require.NoError(t, err)
+	result, err := job.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, JobStateCompleted, result.State)
+	assert.Empty(t, job.Path())
+
+	_, err = NewMethodJob(nil, 32775, nil, WithOperation("Failed to add resource settings"))
+	var jobErr *JobError
+	require.True(t, errors.As(err, &jobErr))
+	assert.Equal(t, 32775, jobErr.ErrorCode)
+
+	_, err = NewMethodJob(nil, 4096, nil)
+	assert.Error(t, err)
+
+	f := newJobFixture(t, JobStateCompleted)
+	instance, err := f.service.GetObject(f.path)
+	require.NoError(t, err)
+	job, err = NewMethodJob(f.service, 4096, instance)
+	require.NoError(t, err)
+	value, err := NewResultJob(job, func() (string, error) { return "resulting", nil }).Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "resulting", value)
+}
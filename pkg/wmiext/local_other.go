@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package wmiext
+
+import "github.com/pkg/errors"
+
+// connectLocalBackend fails on platforms without a local WMI service. A different transport can be
+// installed with SetLocalBackendFactory, such as one serving a MemoryRepository.
+func connectLocalBackend(namespace string) (Backend, error) {
+	return nil, errors.Wrapf(NotSupported, "no local WMI service to connect %s", namespace)
+}
@@ -0,0 +1,845 @@
+package wmiext
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext/mof"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+// Reference marks a string as a CIM_REFERENCE object path when it is stored in a MemoryRepository.
+type Reference string
+
+// MemoryClass declares a class of a MemoryRepository. Declaring a class is optional for instances
+// added with AddInstance, but it is required to spawn new instances, to derive paths from
+// specific key properties and to match subclasses in queries.
+type MemoryClass struct {
+	Name       string
+	Superclass string
+	// Keys lists the key properties used to build instance paths. It is inherited from the
+	// superclass when empty.
+	Keys []string
+	// Properties declares the properties, along with their CIM type, of instances of this class.
+	Properties map[string]CIMTYPE_ENUMERATION
+	// Methods declares the [in] parameters, along with their CIM type, of each method.
+	Methods map[string]map[string]CIMTYPE_ENUMERATION
+}
+
+// MethodHandler implements a method of a MemoryRepository class. Returning an error fails the
+// invocation itself, a failing return code should be set with MethodCall.Return instead.
+type MethodHandler func(call *MethodCall) error
+
+// MethodCall holds the parameters of a method invocation served by a MemoryRepository.
+type MethodCall struct {
+	Repository *MemoryRepository
+	// Path is the object path the method was invoked on.
+	Path   string
+	Method string
+
+	in  *memoryObject
+	out *memoryObject
+}
+
+// In returns the value of an [in] parameter, using the automation mapping of WMI.
+func (c *MethodCall) In(name string) interface{} {
+	if c.in == nil {
+		return nil
+	}
+	value, _, _, _ := c.in.Get(name)
+	return value
+}
+
+// InString returns the value of an [in] parameter as a string.
+func (c *MethodCall) InString(name string) string {
+	value := c.In(name)
+	if value == nil {
+		return ""
+	}
+	return convertToString(value)
+}
+
+// InStrings returns the value of an array [in] parameter as a string slice.
+func (c *MethodCall) InStrings(name string) []string {
+	values, ok := c.In(name).([]interface{})
+	if !ok {
+		return nil
+	}
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = convertToString(value)
+	}
+	return result
+}
+
+// Out sets an [out] parameter. Object paths should be passed as a Reference.
+func (c *MethodCall) Out(name string, value interface{}) {
+	_ = c.out.Put(name, value)
+}
+
+// Return sets the return value of the method.
+func (c *MethodCall) Return(code uint32) {
+	c.Out("ReturnValue", code)
+}
+
+// MemoryRepository is an in-process CIM repository for a single namespace. Instances are preloaded
+// with AddInstance or AddObject, and methods are served by handlers registered with HandleMethod.
+// All objects handed out are copies, changes only become visible to other objects through the
+// repository methods, similar to a live WMI service.
+type MemoryRepository struct {
+	mu        sync.RWMutex
+	host      string
+	namespace string
+	classes   map[string]*MemoryClass
+	instances []*memoryObject
+	index     map[string]*memoryObject
+	handlers  map[string]MethodHandler
+	// subscriptions receive the events of the changes to instances
+	subscriptions map[*memoryEvents]struct{}
+}
+
+// NewMemoryRepository creates an empty repository for the specified namespace.
+func NewMemoryRepository(namespace string) *MemoryRepository {
+	return &MemoryRepository{
+		host:      "localhost",
+		namespace: strings.ReplaceAll(namespace, "/", `\`),
+		classes:   make(map[string]*MemoryClass),
+		index:     make(map[string]*memoryObject),
+		handlers:  make(map[string]MethodHandler),
+	}
+}
+
+// Namespace returns the namespace served by this repository.
+func (r *MemoryRepository) Namespace() string {
+	return r.namespace
+}
+
+// Backend returns a Backend serving this repository.
+func (r *MemoryRepository) Backend() Backend {
+	return &memoryBackend{repo: r}
+}
+
+// Service returns a Service connected to this repository.
+func (r *MemoryRepository) Service() *Service {
+	return NewService(r.Backend())
+}
+
+// DefineClass declares a class, replacing any previous declaration of the same name.
+func (r *MemoryRepository) DefineClass(class MemoryClass) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.classes[strings.ToLower(class.Name)] = &class
+}
+
+// HandleMethod registers the handler serving a method of a class and its subclasses.
+func (r *MemoryRepository) HandleMethod(className string, method string, handler MethodHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[strings.ToLower(className+"."+method)] = handler
+}
+
+// AddInstance stores a new instance of the specified class and returns its path. Values follow the
+// same conversions as Instance.Put, references must be passed as a Reference.
+func (r *MemoryRepository) AddInstance(className string, properties map[string]interface{}) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	object := r.newObject(className, false)
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := object.Put(name, properties[name]); err != nil {
+			return "", errors.Wrapf(err, "property %s", name)
+		}
+	}
+
+	path, err := r.store(object)
+	if err != nil {
+		return "", err
+	}
+	r.emit(object, nil)
+	return path, nil
+}
+
+// AddObject stores a new instance of the specified class using the exported fields of the src struct,
+// following the conventions of Instance.GetAll, and returns its path.
+func (r *MemoryRepository) AddObject(className string, src interface{}) (string, error) {
+	val := reflect.ValueOf(src)
+	if val.Kind() == reflect.Pointer {
+		val = val.Elem()
+	}
+
+	if val.Kind() != reflect.Struct {
+		return "", errors.New("not a struct or pointer to struct")
+	}
+
+	properties := make(map[string]interface{})
+	collectStructProperties(val, properties)
+	return r.AddInstance(className, properties)
+}
+
+// Update sets properties of the stored instance at path.
+func (r *MemoryRepository) Update(path string, properties map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	object, err := r.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	previous := object.clone()
+	for name, value := range properties {
+		if err := object.Put(name, value); err != nil {
+			return errors.Wrapf(err, "property %s", name)
+		}
+	}
+	r.emit(object, previous)
+	return nil
+}
+
+// Delete removes the stored instance at path, along with all associations referencing it.
+func (r *MemoryRepository) Delete(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	object, err := r.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	key := object.key()
+	remaining := r.instances[:0]
+	var deleted []*memoryObject
+	for _, instance := range r.instances {
+		if instance == object || instance.references(key) {
+			delete(r.index, instance.key())
+			deleted = append(deleted, instance)
+			continue
+		}
+		remaining = append(remaining, instance)
+	}
+	r.instances = remaining
+	for _, instance := range deleted {
+		r.emit(nil, instance)
+	}
+	return nil
+}
+
+// Get returns a copy of the stored instance at path.
+func (r *MemoryRepository) Get(path string) (Object, error) {
+	return r.Backend().GetObject(path)
+}
+
+func (r *MemoryRepository) store(object *memoryObject) (string, error) {
+	object.stored = true
+	key := object.key()
+	if _, exists := r.index[key]; exists {
+		return "", errors.Wrapf(NewWmiError(WBEM_E_ALREADY_EXISTS), "instance %s", object.path())
+	}
+
+	r.instances = append(r.instances, object)
+	r.index[key] = object
+	return object.path(), nil
+}
+
+func (r *MemoryRepository) lookup(path string) (*memoryObject, error) {
+	parsed, err := parseObjectPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	object, ok := r.index[parsed.Normalized()]
+	if !ok {
+		return nil, errors.Wrapf(NewWmiError(WBEM_E_NOT_FOUND), "instance %s", path)
+	}
+	return object, nil
+}
+
+func (r *MemoryRepository) class(name string) *MemoryClass {
+	return r.classes[strings.ToLower(name)]
+}
+
+func (r *MemoryRepository) classExists(name string) bool {
+	if r.class(name) != nil {
+		return true
+	}
+
+	for _, instance := range r.instances {
+		if strings.EqualFold(instance.className, name) {
+			return true
+		}
+	}
+
+	prefix := strings.ToLower(name + ".")
+	for method := range r.handlers {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// superclasses returns the class followed by all its known superclasses.
+func (r *MemoryRepository) superclasses(name string) []string {
+	chain := []string{name}
+	for class := r.class(name); class != nil && class.Superclass != ""; class = r.class(class.Superclass) {
+		chain = append(chain, class.Superclass)
+		if len(chain) > 64 {
+			break
+		}
+	}
+	return chain
+}
+
+func (r *MemoryRepository) isa(className string, ancestor string) bool {
+	for _, name := range r.superclasses(className) {
+		if strings.EqualFold(name, ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MemoryRepository) declaredProperties(className string) map[string]CIMTYPE_ENUMERATION {
+	var declared map[string]CIMTYPE_ENUMERATION
+	chain := r.superclasses(className)
+	for i := len(chain) - 1; i >= 0; i-- {
+		class := r.class(chain[i])
+		if class == nil || len(class.Properties) == 0 {
+			continue
+		}
+		if declared == nil {
+			declared = make(map[string]CIMTYPE_ENUMERATION)
+		}
+		for name, cimType := range class.Properties {
+			declared[name] = cimType
+		}
+	}
+	return declared
+}
+
+func (r *MemoryRepository) classKeys(className string) []string {
+	for _, name := range r.superclasses(className) {
+		if class := r.class(name); class != nil && len(class.Keys) > 0 {
+			return class.Keys
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) handler(className string, method string) MethodHandler {
+	for _, name := range r.superclasses(className) {
+		if handler, ok := r.handlers[strings.ToLower(name+"."+method)]; ok {
+			return handler
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) methodParameters(className string, method string) map[string]CIMTYPE_ENUMERATION {
+	for _, name := range r.superclasses(className) {
+		class := r.class(name)
+		if class == nil {
+			continue
+		}
+		for methodName, params := range class.Methods {
+			if strings.EqualFold(methodName, method) {
+				return params
+			}
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) newObject(className string, isClass bool) *memoryObject {
+	object := &memoryObject{
+		repo:       r,
+		className:  className,
+		isClass:    isClass,
+		properties: make(map[string]*memoryProperty),
+	}
+
+	if declared := r.declaredProperties(className); declared != nil {
+		object.strict = true
+		for name, cimType := range declared {
+			object.set(name, nil, cimType)
+		}
+	}
+	return object
+}
+
+// memoryBackend is the Backend serving a MemoryRepository.
+type memoryBackend struct {
+	repo *MemoryRepository
+}
+
+func (b *memoryBackend) Close() {}
+
+func (b *memoryBackend) ExecQuery(wql string) (ObjectIterator, error) {
+	query, err := parseWQL(wql)
+	if err != nil {
+		return nil, err
+	}
+
+	b.repo.mu.RLock()
+	defer b.repo.mu.RUnlock()
+
+	objects, err := query.execute(b.repo)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryIterator{objects: objects}, nil
+}
+
+func (b *memoryBackend) GetObject(path string) (Object, error) {
+	b.repo.mu.RLock()
+	defer b.repo.mu.RUnlock()
+
+	parsed, err := parseObjectPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.IsClass() {
+		if !b.repo.classExists(parsed.ClassName) {
+			return nil, errors.Wrapf(NewWmiError(WBEM_E_NOT_FOUND), "class %s", parsed.ClassName)
+		}
+		return b.repo.newObject(parsed.ClassName, true), nil
+	}
+
+	object, ok := b.repo.index[parsed.Normalized()]
+	if !ok {
+		return nil, errors.Wrapf(NewWmiError(WBEM_E_NOT_FOUND), "instance %s", path)
+	}
+	return object.clone(), nil
+}
+
+func (b *memoryBackend) CreateInstanceEnum(className string) (ObjectIterator, error) {
+	b.repo.mu.RLock()
+	defer b.repo.mu.RUnlock()
+
+	var objects []Object
+	for _, instance := range b.repo.instances {
+		if strings.EqualFold(instance.className, className) {
+			objects = append(objects, instance.clone())
+		}
+	}
+	return &memoryIterator{objects: objects}, nil
+}
+
+func (b *memoryBackend) ExecMethod(path string, method string, inParams Object) (Object, error) {
+	var in *memoryObject
+	if inParams != nil {
+		var ok bool
+		if in, ok = inParams.(*memoryObject); !ok {
+			return nil, fmt.Errorf("in parameters of type %T can not be sent to a memory repository", inParams)
+		}
+	}
+
+	b.repo.mu.RLock()
+	parsed, err := parseObjectPath(path)
+	if err != nil {
+		b.repo.mu.RUnlock()
+		return nil, err
+	}
+
+	className := parsed.ClassName
+	if !parsed.IsClass() {
+		object, ok := b.repo.index[parsed.Normalized()]
+		if !ok {
+			b.repo.mu.RUnlock()
+			return nil, errors.Wrapf(NewWmiError(WBEM_E_NOT_FOUND), "instance %s", path)
+		}
+		className = object.className
+	}
+	handler := b.repo.handler(className, method)
+	out := &memoryObject{repo: b.repo, className: "__PARAMETERS", properties: make(map[string]*memoryProperty)}
+	b.repo.mu.RUnlock()
+
+	if handler == nil {
+		return nil, errors.Wrapf(NewWmiError(WBEM_E_METHOD_NOT_IMPLEMENTED), "method %s.%s", className, method)
+	}
+
+	out.set("ReturnValue", int32(0), CIM_UINT32)
+	call := &MethodCall{Repository: b.repo, Path: path, Method: method, in: in, out: out}
+	if err = handler(call); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type memoryIterator struct {
+	objects []Object
+}
+
+func (it *memoryIterator) Next() (Object, error) {
+	if len(it.objects) == 0 {
+		return nil, nil
+	}
+	object := it.objects[0]
+	it.objects = it.objects[1:]
+	return object, nil
+}
+
+func (it *memoryIterator) Release() {
+	it.objects = nil
+}
+
+type memoryProperty struct {
+	name    string
+	value   interface{}
+	cimType CIMTYPE_ENUMERATION
+}
+
+// memoryObject is the Object held by a MemoryRepository. Values are kept in the automation form WMI
+// uses, so callers observe the same Golang types as with the COM backend.
+type memoryObject struct {
+	repo       *MemoryRepository
+	className  string
+	isClass    bool
+	stored     bool
+	strict     bool
+	names      []string
+	properties map[string]*memoryProperty
+}
+
+func (o *memoryObject) set(name string, value interface{}, cimType CIMTYPE_ENUMERATION) {
+	key := strings.ToLower(name)
+	if prop, ok := o.properties[key]; ok {
+		prop.value = value
+		prop.cimType = cimType
+		return
+	}
+	o.names = append(o.names, key)
+	o.properties[key] = &memoryProperty{name: name, value: value, cimType: cimType}
+}
+
+func (o *memoryObject) Get(name string) (interface{}, CIMTYPE_ENUMERATION, WBEM_FLAVOR_TYPE, error) {
+	if strings.HasPrefix(name, "__") {
+		value, cimType := o.systemProperty(name)
+		return value, cimType, WBEM_FLAVOR_ORIGIN_SYSTEM, nil
+	}
+
+	prop, ok := o.properties[strings.ToLower(name)]
+	if !ok {
+		if o.strict {
+			return nil, CIM_EMPTY, 0, errors.Wrapf(NewWmiError(WBEM_E_NOT_FOUND), "property %s", name)
+		}
+		return nil, CIM_EMPTY, 0, nil
+	}
+	return copyAutomationValue(prop.value), prop.cimType, WBEM_FLAVOR_ORIGIN_LOCAL, nil
+}
+
+func (o *memoryObject) Put(name string, value interface{}) error {
+	if strings.HasPrefix(name, "__") {
+		return errors.Wrapf(NewWmiError(WBEM_E_READ_ONLY), "property %s", name)
+	}
+
+	key := strings.ToLower(name)
+	prop, exists := o.properties[key]
+	if !exists && o.strict {
+		return errors.Wrapf(NewWmiError(WBEM_E_NOT_FOUND), "property %s", name)
+	}
+
+	automation, cimType, err := toAutomationValue(value)
+	if err != nil {
+		return err
+	}
+
+	if exists && prop.cimType != CIM_EMPTY {
+		cimType = prop.cimType
+	}
+	o.set(name, automation, cimType)
+	return nil
+}
+
+func (o *memoryObject) Properties() ([]Property, error) {
+	properties := make([]Property, 0, len(o.names)+len(memorySystemProperties))
+	for _, name := range memorySystemProperties {
+		value, cimType := o.systemProperty(name)
+		properties = append(properties, Property{Name: name, Value: value, CimType: cimType, Flavor: WBEM_FLAVOR_ORIGIN_SYSTEM})
+	}
+	for _, key := range o.names {
+		prop := o.properties[key]
+		properties = append(properties, Property{Name: prop.name, Value: copyAutomationValue(prop.value), CimType: prop.cimType})
+	}
+	return properties, nil
+}
+
+var memorySystemProperties = []string{"__GENUS", "__CLASS", "__SUPERCLASS", "__SERVER", "__NAMESPACE", "__RELPATH", "__PATH"}
+
+func (o *memoryObject) systemProperty(name string) (interface{}, CIMTYPE_ENUMERATION) {
+	switch strings.ToUpper(name) {
+	case "__GENUS":
+		if o.isClass {
+			return int32(1), CIM_SINT32
+		}
+		return int32(2), CIM_SINT32
+	case "__CLASS":
+		return o.className, CIM_STRING
+	case "__SUPERCLASS":
+		if class := o.repo.class(o.className); class != nil && class.Superclass != "" {
+			return class.Superclass, CIM_STRING
+		}
+		return nil, CIM_STRING
+	case "__SERVER":
+		return o.repo.host, CIM_STRING
+	case "__NAMESPACE":
+		return o.repo.namespace, CIM_STRING
+	case "__RELPATH":
+		if o.isClass {
+			return o.className, CIM_STRING
+		}
+		if !o.stored {
+			return nil, CIM_STRING
+		}
+		return o.relativePath(), CIM_STRING
+	case "__PATH":
+		if !o.stored && !o.isClass {
+			return nil, CIM_STRING
+		}
+		return o.path(), CIM_STRING
+	default:
+		return nil, CIM_EMPTY
+	}
+}
+
+func (o *memoryObject) keyNames() []string {
+	if keys := o.repo.classKeys(o.className); keys != nil {
+		return keys
+	}
+
+	if _, ok := o.properties["instanceid"]; ok {
+		return []string{"InstanceID"}
+	}
+
+	var keys []string
+	for _, key := range o.names {
+		if o.properties[key].cimType == CIM_REFERENCE {
+			keys = append(keys, o.properties[key].name)
+		}
+	}
+	if len(keys) > 0 {
+		return keys
+	}
+
+	for _, name := range []string{"CreationClassName", "DeviceID", "Name", "SystemCreationClassName", "SystemName"} {
+		if _, ok := o.properties[strings.ToLower(name)]; ok {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+func (o *memoryObject) relativePath() string {
+	if o.isClass {
+		return o.className
+	}
+
+	keys := o.keyNames()
+	if len(keys) == 0 {
+		return o.className + "=@"
+	}
+
+	sorted := append([]string(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool { return strings.ToLower(sorted[i]) < strings.ToLower(sorted[j]) })
+
+	parts := make([]string, 0, len(sorted))
+	for _, key := range sorted {
+		var value interface{}
+		if prop, ok := o.properties[strings.ToLower(key)]; ok {
+			key = prop.name
+			value = prop.value
+		}
+		parts = append(parts, key+"="+objectpath.FormatValue(value))
+	}
+	return o.className + "." + strings.Join(parts, ",")
+}
+
+func (o *memoryObject) path() string {
+	return fmt.Sprintf(`\\%s\%s:%s`, o.repo.host, o.repo.namespace, o.relativePath())
+}
+
+func (o *memoryObject) key() string {
+	parsed, err := parseObjectPath(o.relativePath())
+	if err != nil {
+		return strings.ToLower(o.relativePath())
+	}
+	return parsed.Normalized()
+}
+
+// references returns whether any reference property points at the instance with the specified key.
+func (o *memoryObject) references(key string) bool {
+	for _, prop := range o.properties {
+		if prop.cimType != CIM_REFERENCE {
+			continue
+		}
+		if path, ok := prop.value.(string); ok && objectpath.Normalize(path) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *memoryObject) clone() *memoryObject {
+	cloned := &memoryObject{
+		repo:       o.repo,
+		className:  o.className,
+		isClass:    o.isClass,
+		stored:     o.stored,
+		strict:     o.strict,
+		names:      append([]string(nil), o.names...),
+		properties: make(map[string]*memoryProperty, len(o.properties)),
+	}
+	for key, prop := range o.properties {
+		cloned.properties[key] = &memoryProperty{name: prop.name, value: copyAutomationValue(prop.value), cimType: prop.cimType}
+	}
+	return cloned
+}
+
+func (o *memoryObject) SpawnInstance() (Object, error) {
+	o.repo.mu.RLock()
+	defer o.repo.mu.RUnlock()
+
+	return o.repo.newObject(o.className, false), nil
+}
+
+func (o *memoryObject) Clone() (Object, error) {
+	return o.clone(), nil
+}
+
+func (o *memoryObject) MethodParameters(method string) (Object, error) {
+	o.repo.mu.RLock()
+	defer o.repo.mu.RUnlock()
+
+	params := &memoryObject{repo: o.repo, className: "__PARAMETERS", properties: make(map[string]*memoryProperty)}
+	if declared := o.repo.methodParameters(o.className, method); declared != nil {
+		params.strict = true
+		for name, cimType := range declared {
+			params.set(name, nil, cimType)
+		}
+	}
+	return params, nil
+}
+
+func (o *memoryObject) CimText() (string, error) {
+	return EncodeCimXml(o)
+}
+
+func (o *memoryObject) Refresh() error {
+	if !o.stored {
+		return nil
+	}
+
+	o.repo.mu.RLock()
+	defer o.repo.mu.RUnlock()
+
+	current, ok := o.repo.index[o.key()]
+	if !ok {
+		return errors.Wrapf(NewWmiError(WBEM_E_NOT_FOUND), "instance %s", o.path())
+	}
+
+	refreshed := current.clone()
+	o.names = refreshed.names
+	o.properties = refreshed.properties
+	return nil
+}
+
+func (o *memoryObject) Release() {}
+
+// describeClass describes a class from its declaration and the ones of its superclasses. The
+// parameters of methods are all declared as inputs, and classes declaring no properties are
+// described from the properties of their instances.
+func (o *memoryObject) describeClass() (*ClassSchema, error) {
+	o.repo.mu.RLock()
+	defer o.repo.mu.RUnlock()
+
+	schema := &ClassSchema{Name: o.className, Properties: []*PropertySchema{}}
+	if class := o.repo.class(o.className); class != nil {
+		schema.Name = class.Name
+		schema.Superclass = class.Superclass
+	}
+	keys := make(map[string]bool)
+	for _, key := range o.repo.classKeys(o.className) {
+		keys[strings.ToLower(key)] = true
+	}
+	properties := make(map[string]*PropertySchema)
+	addProperty := func(name string, cimType CIMTYPE_ENUMERATION, origin string) {
+		property, ok := properties[strings.ToLower(name)]
+		if !ok {
+			property = &PropertySchema{Name: name}
+			if keys[strings.ToLower(name)] {
+				property.Qualifiers = mof.Qualifiers{{Name: "Key", Value: true}}
+			}
+			properties[strings.ToLower(name)] = property
+			schema.Properties = append(schema.Properties, property)
+		}
+		property.Type = cimType
+		property.Origin = origin
+	}
+	methods := make(map[string]*MethodSchema)
+
+	chain := o.repo.superclasses(o.className)
+	for i := len(chain) - 1; i >= 0; i-- {
+		class := o.repo.class(chain[i])
+		if class == nil {
+			continue
+		}
+		names := make([]string, 0, len(class.Properties))
+		for name := range class.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			addProperty(name, class.Properties[name], class.Name)
+		}
+
+		names = names[:0]
+		for name := range class.Methods {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			method := &MethodSchema{Name: name, Origin: class.Name, ReturnType: CIM_UINT32}
+			params := make([]string, 0, len(class.Methods[name]))
+			for param := range class.Methods[name] {
+				params = append(params, param)
+			}
+			sort.Strings(params)
+			for _, param := range params {
+				method.Parameters = append(method.Parameters, &ParameterSchema{Name: param, Type: class.Methods[name][param], In: true})
+			}
+			if previous, ok := methods[strings.ToLower(name)]; ok {
+				*previous = *method
+				continue
+			}
+			methods[strings.ToLower(name)] = method
+			schema.Methods = append(schema.Methods, method)
+		}
+	}
+
+	if len(schema.Properties) == 0 {
+		for _, instance := range o.repo.instances {
+			if !strings.EqualFold(instance.className, o.className) {
+				continue
+			}
+			for _, key := range instance.names {
+				prop := instance.properties[key]
+				if _, ok := properties[key]; !ok {
+					addProperty(prop.name, prop.cimType, schema.Name)
+				}
+			}
+		}
+	}
+	return schema, nil
+}
@@ -0,0 +1,141 @@
+package wmiext
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fileTimeUnixEpoch is the Unix epoch in 100ns intervals since 1601, the origin of the TIME_CREATED
+// property of events.
+const fileTimeUnixEpoch = 116444736000000000
+
+// memoryEvents is the EventIterator of a notification query on a MemoryRepository. Events are
+// queued as the repository changes, regardless of the polling interval of the query.
+type memoryEvents struct {
+	repo  *MemoryRepository
+	query *wqlQuery
+
+	mu       sync.Mutex
+	queue    []*memoryObject
+	signal   chan struct{}
+	released bool
+}
+
+func (b *memoryBackend) ExecNotificationQuery(wql string) (EventIterator, error) {
+	query, err := parseWQL(wql)
+	if err != nil {
+		return nil, err
+	}
+	if query.kind != wqlSelect || !isInstanceEvent(query.className) {
+		return nil, errors.Wrapf(NewWmiError(WBEM_E_NOT_EVENT_CLASS), "class %s", query.className)
+	}
+
+	events := &memoryEvents{repo: b.repo, query: query, signal: make(chan struct{}, 1)}
+	b.repo.mu.Lock()
+	defer b.repo.mu.Unlock()
+	if b.repo.subscriptions == nil {
+		b.repo.subscriptions = make(map[*memoryEvents]struct{})
+	}
+	b.repo.subscriptions[events] = struct{}{}
+	return events, nil
+}
+
+func isInstanceEvent(className string) bool {
+	for _, event := range []string{InstanceOperationEvent, InstanceCreationEvent, InstanceModificationEvent, InstanceDeletionEvent} {
+		if strings.EqualFold(className, event) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *memoryEvents) Next(timeout time.Duration) (Object, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		e.mu.Lock()
+		if e.released {
+			e.mu.Unlock()
+			return nil, errors.Wrap(NewWmiError(WBEM_E_CALL_CANCELLED), "subscription released")
+		}
+		if len(e.queue) > 0 {
+			event := e.queue[0]
+			e.queue = e.queue[1:]
+			e.mu.Unlock()
+			return event, nil
+		}
+		e.mu.Unlock()
+
+		select {
+		case <-e.signal:
+		case <-timer.C:
+			return nil, nil
+		}
+	}
+}
+
+func (e *memoryEvents) Release() {
+	e.repo.mu.Lock()
+	delete(e.repo.subscriptions, e)
+	e.repo.mu.Unlock()
+
+	e.mu.Lock()
+	e.released = true
+	e.queue = nil
+	e.mu.Unlock()
+}
+
+// deliver queues the event when it matches the query.
+func (e *memoryEvents) deliver(event *memoryObject) {
+	if !strings.EqualFold(e.query.className, InstanceOperationEvent) && !strings.EqualFold(e.query.className, event.className) {
+		return
+	}
+	if e.query.where != nil && !e.query.where.eval(e.repo, event) {
+		return
+	}
+
+	e.mu.Lock()
+	e.queue = append(e.queue, event.clone())
+	e.mu.Unlock()
+	select {
+	case e.signal <- struct{}{}:
+	default:
+	}
+}
+
+// emit notifies the subscriptions of a change of an instance. The previous instance is nil for a
+// creation and the target nil for a deletion. The caller must hold the lock of the repository.
+func (r *MemoryRepository) emit(target *memoryObject, previous *memoryObject) {
+	if len(r.subscriptions) == 0 {
+		return
+	}
+
+	eventClass := InstanceModificationEvent
+	switch {
+	case previous == nil:
+		eventClass = InstanceCreationEvent
+	case target == nil:
+		eventClass, target, previous = InstanceDeletionEvent, previous, nil
+	case reflect.DeepEqual(target.clone().properties, previous.properties):
+		// Nothing changed, as observed by polling WMI
+		return
+	}
+
+	event := &memoryObject{repo: r, className: eventClass, properties: make(map[string]*memoryProperty)}
+	event.set("TargetInstance", target.clone(), CIM_OBJECT)
+	if previous != nil {
+		event.set("PreviousInstance", previous, CIM_OBJECT)
+	}
+	created := uint64(time.Now().UnixNano()/100) + fileTimeUnixEpoch
+	event.set("TIME_CREATED", strconv.FormatUint(created, 10), CIM_UINT64)
+
+	for subscription := range r.subscriptions {
+		subscription.deliver(event)
+	}
+}
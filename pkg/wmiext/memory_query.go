@@ -0,0 +1,796 @@
+package wmiext
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+type wqlQueryKind int
+
+const (
+	wqlSelect wqlQueryKind = iota
+	wqlAssociators
+	wqlReferences
+)
+
+// wqlQuery is a parsed WQL data or schema query, as evaluated by MemoryRepository.
+type wqlQuery struct {
+	kind       wqlQueryKind
+	properties []string
+	className  string
+	where      wqlExpr
+	// within is the polling interval of an event query, in seconds.
+	within string
+
+	objectPath    string
+	assocClass    string
+	resultClass   string
+	role          string
+	resultRole    string
+	classDefsOnly bool
+	keysOnly      bool
+}
+
+type wqlTokenKind int
+
+const (
+	wqlEOF wqlTokenKind = iota
+	wqlIdent
+	wqlString
+	wqlNumber
+	wqlOperator
+	wqlPath
+)
+
+type wqlToken struct {
+	kind  wqlTokenKind
+	text  string
+	start int
+}
+
+func tokenizeWQL(wql string) ([]wqlToken, error) {
+	var tokens []wqlToken
+	runes := []rune(wql)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '{':
+			end := i + 1
+			for end < len(runes) && runes[end] != '}' {
+				if runes[end] == '"' {
+					// Quoted key values can contain braces
+					for end++; end < len(runes) && runes[end] != '"'; end++ {
+						if runes[end] == '\\' {
+							end++
+						}
+					}
+				}
+				end++
+			}
+			if end >= len(runes) {
+				return nil, errors.Wrapf(NewWmiError(WBEM_E_INVALID_QUERY), "unterminated object path in %q", wql)
+			}
+			tokens = append(tokens, wqlToken{kind: wqlPath, text: strings.TrimSpace(string(runes[i+1 : end])), start: i})
+			i = end + 1
+		case r == '\'' || r == '"':
+			var sb strings.Builder
+			end := i + 1
+			for ; end < len(runes) && runes[end] != r; end++ {
+				if runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				sb.WriteRune(runes[end])
+			}
+			if end >= len(runes) {
+				return nil, errors.Wrapf(NewWmiError(WBEM_E_INVALID_QUERY), "unterminated string in %q", wql)
+			}
+			tokens = append(tokens, wqlToken{kind: wqlString, text: sb.String(), start: i})
+			i = end + 1
+		case unicode.IsDigit(r) || r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			end := i + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.' || runes[end] == 'x' || runes[end] == 'X' ||
+				strings.ContainsRune("abcdefABCDEF", runes[end])) {
+				end++
+			}
+			tokens = append(tokens, wqlToken{kind: wqlNumber, text: string(runes[i:end]), start: i})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i + 1
+			// Dots qualify the properties of embedded objects, e.g. TargetInstance.Name
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_' || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, wqlToken{kind: wqlIdent, text: string(runes[i:end]), start: i})
+			i = end
+		case strings.ContainsRune("=<>!", r):
+			end := i + 1
+			if end < len(runes) && strings.ContainsRune("=>", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, wqlToken{kind: wqlOperator, text: string(runes[i:end]), start: i})
+			i = end
+		case strings.ContainsRune("(),*", r):
+			tokens = append(tokens, wqlToken{kind: wqlOperator, text: string(r), start: i})
+			i++
+		default:
+			return nil, errors.Wrapf(NewWmiError(WBEM_E_INVALID_QUERY), "unexpected %q in %q", r, wql)
+		}
+	}
+	return append(tokens, wqlToken{kind: wqlEOF, start: len(runes)}), nil
+}
+
+type wqlParser struct {
+	wql    string
+	tokens []wqlToken
+	pos    int
+}
+
+func parseWQL(wql string) (*wqlQuery, error) {
+	tokens, err := tokenizeWQL(wql)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &wqlParser{wql: wql, tokens: tokens}
+	var query *wqlQuery
+	switch {
+	case p.acceptKeyword("SELECT"):
+		query, err = p.parseSelect()
+	case p.acceptKeyword("ASSOCIATORS"):
+		query, err = p.parseAssociation(wqlAssociators)
+	case p.acceptKeyword("REFERENCES"):
+		query, err = p.parseAssociation(wqlReferences)
+	default:
+		err = p.errorf("expected SELECT, ASSOCIATORS OF or REFERENCES OF")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != wqlEOF {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	return query, nil
+}
+
+func (p *wqlParser) peek() wqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *wqlParser) next() wqlToken {
+	token := p.tokens[p.pos]
+	if token.kind != wqlEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *wqlParser) acceptKeyword(keyword string) bool {
+	if token := p.peek(); token.kind == wqlIdent && strings.EqualFold(token.text, keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *wqlParser) acceptOperator(operator string) bool {
+	if token := p.peek(); token.kind == wqlOperator && token.text == operator {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *wqlParser) errorf(format string, args ...interface{}) error {
+	return errors.Wrapf(NewWmiError(WBEM_E_INVALID_QUERY), "%s at offset %d in %q",
+		errors.Errorf(format, args...).Error(), p.peek().start, p.wql)
+}
+
+func (p *wqlParser) expectIdent() (string, error) {
+	token := p.next()
+	if token.kind != wqlIdent {
+		return "", p.errorf("expected identifier")
+	}
+	return token.text, nil
+}
+
+func (p *wqlParser) parseSelect() (*wqlQuery, error) {
+	query := &wqlQuery{kind: wqlSelect}
+	if !p.acceptOperator("*") {
+		for {
+			name, err := p.expectIdent()
+			if err != nil {
+				return nil, err
+			}
+			query.properties = append(query.properties, name)
+			if !p.acceptOperator(",") {
+				break
+			}
+		}
+	}
+
+	if !p.acceptKeyword("FROM") {
+		return nil, p.errorf("expected FROM")
+	}
+
+	var err error
+	if query.className, err = p.expectIdent(); err != nil {
+		return nil, err
+	}
+
+	if p.acceptKeyword("WITHIN") {
+		token := p.next()
+		if token.kind != wqlNumber {
+			return nil, p.errorf("expected polling interval")
+		}
+		query.within = token.text
+	}
+
+	if p.acceptKeyword("WHERE") {
+		if query.where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	return query, nil
+}
+
+func (p *wqlParser) parseAssociation(kind wqlQueryKind) (*wqlQuery, error) {
+	query := &wqlQuery{kind: kind}
+	if !p.acceptKeyword("OF") {
+		return nil, p.errorf("expected OF")
+	}
+
+	token := p.next()
+	if token.kind != wqlPath {
+		return nil, p.errorf("expected {object path}")
+	}
+	query.objectPath = token.text
+
+	if !p.acceptKeyword("WHERE") {
+		return query, nil
+	}
+
+	for p.peek().kind != wqlEOF {
+		keyword, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+
+		switch strings.ToLower(keyword) {
+		case "classdefsonly":
+			query.classDefsOnly = true
+			continue
+		case "keysonly":
+			query.keysOnly = true
+			continue
+		case "schemaonly", "requiredqualifier", "requiredassocqualifier":
+			return nil, errors.Wrapf(NewWmiError(WBEM_E_NOT_SUPPORTED), "%s in %q", keyword, p.wql)
+		}
+
+		if !p.acceptOperator("=") {
+			return nil, p.errorf("expected = after %s", keyword)
+		}
+		value := p.next()
+		if value.kind != wqlIdent && value.kind != wqlString {
+			return nil, p.errorf("expected value for %s", keyword)
+		}
+
+		switch strings.ToLower(keyword) {
+		case "assocclass":
+			query.assocClass = value.text
+		case "resultclass":
+			query.resultClass = value.text
+		case "role":
+			query.role = value.text
+		case "resultrole":
+			if kind == wqlReferences {
+				return nil, p.errorf("ResultRole is not valid for REFERENCES OF")
+			}
+			query.resultRole = value.text
+		default:
+			return nil, p.errorf("unknown keyword %s", keyword)
+		}
+	}
+	return query, nil
+}
+
+// wqlExpr is a node of a WHERE clause.
+type wqlExpr interface {
+	eval(r *MemoryRepository, o *memoryObject) bool
+}
+
+type wqlAnd struct{ left, right wqlExpr }
+type wqlOr struct{ left, right wqlExpr }
+type wqlNot struct{ expr wqlExpr }
+
+type wqlComparison struct {
+	property string
+	operator string
+	literal  wqlToken
+}
+
+type wqlIsNull struct {
+	property string
+	not      bool
+}
+
+func (e *wqlAnd) eval(r *MemoryRepository, o *memoryObject) bool {
+	return e.left.eval(r, o) && e.right.eval(r, o)
+}
+
+func (e *wqlOr) eval(r *MemoryRepository, o *memoryObject) bool {
+	return e.left.eval(r, o) || e.right.eval(r, o)
+}
+
+func (e *wqlNot) eval(r *MemoryRepository, o *memoryObject) bool {
+	return !e.expr.eval(r, o)
+}
+
+func (e *wqlIsNull) eval(_ *MemoryRepository, o *memoryObject) bool {
+	value := wqlProperty(o, e.property)
+	return (value == nil) != e.not
+}
+
+// wqlProperty returns the value of a property, following the dots through embedded objects.
+func wqlProperty(o *memoryObject, property string) interface{} {
+	names := strings.Split(property, ".")
+	for _, name := range names[:len(names)-1] {
+		value, _, _, err := o.Get(name)
+		embedded, ok := value.(*memoryObject)
+		if err != nil || !ok {
+			return nil
+		}
+		o = embedded
+	}
+	value, _, _, err := o.Get(names[len(names)-1])
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+func (p *wqlParser) parseOr() (wqlExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &wqlOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *wqlParser) parseAnd() (wqlExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &wqlAnd{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *wqlParser) parseUnary() (wqlExpr, error) {
+	if p.acceptKeyword("NOT") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &wqlNot{expr: expr}, nil
+	}
+
+	if p.acceptOperator("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptOperator(")") {
+			return nil, p.errorf("expected )")
+		}
+		return expr, nil
+	}
+
+	return p.parseComparison()
+}
+
+var wqlInverseOperators = map[string]string{"=": "=", "!=": "!=", "<>": "<>", "<": ">", ">": "<", "<=": ">=", ">=": "<="}
+
+func (p *wqlParser) parseComparison() (wqlExpr, error) {
+	left := p.next()
+	switch left.kind {
+	case wqlIdent:
+		if isWQLLiteralKeyword(left.text) {
+			break
+		}
+
+		if p.acceptKeyword("IS") {
+			not := p.acceptKeyword("NOT")
+			if !p.acceptKeyword("NULL") {
+				return nil, p.errorf("expected NULL")
+			}
+			return &wqlIsNull{property: left.text, not: not}, nil
+		}
+
+		var operator string
+		switch {
+		case p.acceptKeyword("LIKE"):
+			operator = "LIKE"
+		case p.acceptKeyword("ISA"):
+			operator = "ISA"
+		default:
+			token := p.next()
+			if token.kind != wqlOperator || wqlInverseOperators[token.text] == "" {
+				return nil, p.errorf("expected comparison operator")
+			}
+			operator = token.text
+		}
+
+		literal := p.next()
+		if !isWQLLiteral(literal) {
+			return nil, p.errorf("expected literal")
+		}
+		return newWQLComparison(left.text, operator, literal), nil
+	case wqlString, wqlNumber:
+	default:
+		return nil, p.errorf("expected property")
+	}
+
+	// Literal on the left hand side
+	token := p.next()
+	if token.kind != wqlOperator || wqlInverseOperators[token.text] == "" {
+		return nil, p.errorf("expected comparison operator")
+	}
+	property, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	return newWQLComparison(property, wqlInverseOperators[token.text], left), nil
+}
+
+func newWQLComparison(property string, operator string, literal wqlToken) wqlExpr {
+	if literal.kind == wqlIdent && strings.EqualFold(literal.text, "NULL") {
+		return &wqlIsNull{property: property, not: operator != "="}
+	}
+	return &wqlComparison{property: property, operator: operator, literal: literal}
+}
+
+func isWQLLiteralKeyword(text string) bool {
+	return strings.EqualFold(text, "TRUE") || strings.EqualFold(text, "FALSE") || strings.EqualFold(text, "NULL")
+}
+
+func isWQLLiteral(token wqlToken) bool {
+	return token.kind == wqlString || token.kind == wqlNumber || token.kind == wqlIdent && isWQLLiteralKeyword(token.text)
+}
+
+func (e *wqlComparison) eval(r *MemoryRepository, o *memoryObject) bool {
+	value := wqlProperty(o, e.property)
+	if value == nil {
+		return false
+	}
+
+	switch e.operator {
+	case "LIKE":
+		return matchWQLLike(convertToString(value), e.literal.text)
+	case "ISA":
+		switch cast := value.(type) {
+		case *memoryObject:
+			return r.isa(cast.className, e.literal.text)
+		case string:
+			className := cast
+			if parsed, err := parseObjectPath(cast); err == nil {
+				className = parsed.ClassName
+			}
+			return r.isa(className, e.literal.text)
+		default:
+			return false
+		}
+	}
+
+	var cmp int
+	switch {
+	case e.literal.kind == wqlIdent:
+		// TRUE or FALSE
+		b, err := convertToBool(value)
+		if err != nil {
+			return false
+		}
+		if b == strings.EqualFold(e.literal.text, "TRUE") {
+			cmp = 0
+		} else {
+			cmp = 1
+		}
+		if e.operator != "=" && e.operator != "!=" && e.operator != "<>" {
+			return false
+		}
+	case e.literal.kind == wqlNumber:
+		var ok bool
+		if cmp, ok = compareWQLNumber(value, e.literal.text); !ok {
+			return false
+		}
+	default:
+		if _, isSlice := value.([]interface{}); isSlice {
+			return false
+		}
+		cmp = strings.Compare(strings.ToLower(convertToString(value)), strings.ToLower(e.literal.text))
+	}
+
+	switch e.operator {
+	case "=":
+		return cmp == 0
+	case "!=", "<>":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return false
+	}
+}
+
+func compareWQLNumber(value interface{}, literal string) (int, bool) {
+	want, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		parsed, perr := strconv.ParseInt(literal, 0, 64)
+		if perr != nil {
+			return 0, false
+		}
+		want = float64(parsed)
+	}
+
+	converted, err := convertToFloat(value, reflect.TypeOf(want))
+	if err != nil {
+		return 0, false
+	}
+	got := converted.(float64)
+
+	switch {
+	case got < want:
+		return -1, true
+	case got > want:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+// matchWQLLike matches a value against a LIKE pattern supporting %, _, [set] and [^set], ignoring case.
+func matchWQLLike(value string, pattern string) bool {
+	return matchLike([]rune(strings.ToLower(value)), []rune(strings.ToLower(pattern)))
+}
+
+func matchLike(value []rune, pattern []rune) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '%':
+			for i := 0; i <= len(value); i++ {
+				if matchLike(value[i:], pattern[1:]) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if len(value) == 0 {
+				return false
+			}
+		case '[':
+			end := 1
+			for end < len(pattern) && pattern[end] != ']' {
+				end++
+			}
+			if len(value) == 0 || end >= len(pattern) || !matchLikeSet(value[0], pattern[1:end]) {
+				return false
+			}
+			value, pattern = value[1:], pattern[end+1:]
+			continue
+		default:
+			if len(value) == 0 || value[0] != pattern[0] {
+				return false
+			}
+		}
+		value, pattern = value[1:], pattern[1:]
+	}
+	return len(value) == 0
+}
+
+func matchLikeSet(r rune, set []rune) bool {
+	negate := len(set) > 0 && set[0] == '^'
+	if negate {
+		set = set[1:]
+	}
+
+	matched := false
+	for i := 0; i < len(set); i++ {
+		if i+2 < len(set) && set[i+1] == '-' {
+			if r >= set[i] && r <= set[i+2] {
+				matched = true
+			}
+			i += 2
+			continue
+		}
+		if r == set[i] {
+			matched = true
+		}
+	}
+	return matched != negate
+}
+
+func (q *wqlQuery) execute(r *MemoryRepository) ([]Object, error) {
+	switch q.kind {
+	case wqlAssociators:
+		return q.executeAssociators(r)
+	case wqlReferences:
+		return q.executeReferences(r)
+	default:
+		return q.executeSelect(r)
+	}
+}
+
+func (q *wqlQuery) executeSelect(r *MemoryRepository) ([]Object, error) {
+	if !r.classExists(q.className) {
+		return nil, errors.Wrapf(NewWmiError(WBEM_E_INVALID_CLASS), "class %s", q.className)
+	}
+
+	var objects []Object
+	for _, instance := range r.instances {
+		if !r.isa(instance.className, q.className) {
+			continue
+		}
+		if q.where != nil && !q.where.eval(r, instance) {
+			continue
+		}
+		objects = append(objects, q.project(instance))
+	}
+	return objects, nil
+}
+
+// project returns a copy of the instance restricted to the selected and key properties.
+func (q *wqlQuery) project(instance *memoryObject) *memoryObject {
+	projected := instance.clone()
+	if len(q.properties) == 0 && !q.keysOnly {
+		return projected
+	}
+
+	keep := make(map[string]bool)
+	for _, name := range q.properties {
+		keep[strings.ToLower(name)] = true
+	}
+	for _, name := range instance.keyNames() {
+		keep[strings.ToLower(name)] = true
+	}
+
+	names := projected.names[:0]
+	for _, name := range projected.names {
+		if keep[name] {
+			names = append(names, name)
+		} else {
+			delete(projected.properties, name)
+		}
+	}
+	projected.names = names
+	projected.strict = false
+	return projected
+}
+
+// associations returns the stored associations referencing the source path through the expected role.
+func (q *wqlQuery) associations(r *MemoryRepository) ([]*memoryObject, string, error) {
+	source, err := parseObjectPath(q.objectPath)
+	if err != nil {
+		return nil, "", err
+	}
+	sourceKey := source.Normalized()
+	if _, ok := r.index[sourceKey]; !ok {
+		return nil, "", errors.Wrapf(NewWmiError(WBEM_E_NOT_FOUND), "instance %s", q.objectPath)
+	}
+
+	var associations []*memoryObject
+	for _, instance := range r.instances {
+		for _, key := range instance.names {
+			prop := instance.properties[key]
+			if prop.cimType != CIM_REFERENCE {
+				continue
+			}
+			if q.role != "" && !strings.EqualFold(prop.name, q.role) {
+				continue
+			}
+			if path, ok := prop.value.(string); ok && objectpath.Normalize(path) == sourceKey {
+				associations = append(associations, instance)
+				break
+			}
+		}
+	}
+	return associations, sourceKey, nil
+}
+
+func (q *wqlQuery) executeReferences(r *MemoryRepository) ([]Object, error) {
+	associations, _, err := q.associations(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	for _, association := range associations {
+		if q.resultClass != "" && !r.isa(association.className, q.resultClass) {
+			continue
+		}
+		if q.classDefsOnly {
+			objects = append(objects, r.newObject(association.className, true))
+			continue
+		}
+		objects = append(objects, q.project(association))
+	}
+	return objects, nil
+}
+
+func (q *wqlQuery) executeAssociators(r *MemoryRepository) ([]Object, error) {
+	associations, sourceKey, err := q.associations(r)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var objects []Object
+	for _, association := range associations {
+		if q.assocClass != "" && !r.isa(association.className, q.assocClass) {
+			continue
+		}
+
+		for _, key := range association.names {
+			prop := association.properties[key]
+			if prop.cimType != CIM_REFERENCE {
+				continue
+			}
+			if q.resultRole != "" && !strings.EqualFold(prop.name, q.resultRole) {
+				continue
+			}
+			if q.role != "" && strings.EqualFold(prop.name, q.role) {
+				continue
+			}
+
+			path, ok := prop.value.(string)
+			if !ok {
+				continue
+			}
+			targetKey := objectpath.Normalize(path)
+			if targetKey == sourceKey || seen[targetKey] {
+				continue
+			}
+
+			target, ok := r.index[targetKey]
+			if !ok {
+				continue
+			}
+			if q.resultClass != "" && !r.isa(target.className, q.resultClass) {
+				continue
+			}
+
+			seen[targetKey] = true
+			if q.classDefsOnly {
+				objects = append(objects, r.newObject(target.className, true))
+				continue
+			}
+			objects = append(objects, q.project(target))
+		}
+	}
+	return objects, nil
+}
@@ -0,0 +1,249 @@
+package wmiext
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNamespace = `root\virtualization\v2`
+
+type testSystem struct {
+	CreationClassName    string
+	Name                 string
+	ElementName          string
+	EnabledState         uint16
+	OnTimeInMilliseconds uint64
+}
+
+func newTestRepository(t *testing.T) *MemoryRepository {
+	repo := NewMemoryRepository(testNamespace)
+	repo.DefineClass(MemoryClass{Name: "CIM_ComputerSystem", Keys: []string{"CreationClassName", "Name"}})
+	repo.DefineClass(MemoryClass{Name: "Msvm_ComputerSystem", Superclass: "CIM_ComputerSystem"})
+	repo.DefineClass(MemoryClass{Name: "Msvm_VirtualSystemSettingData", Keys: []string{"InstanceID"}})
+	repo.DefineClass(MemoryClass{Name: "Msvm_SettingsDefineState"})
+	repo.DefineClass(MemoryClass{Name: "Msvm_ConcreteJob", Keys: []string{"InstanceID"}})
+
+	for _, system := range []testSystem{
+		{CreationClassName: "Msvm_ComputerSystem", Name: "A0B1", ElementName: "vm-1", EnabledState: 2, OnTimeInMilliseconds: 1 << 40},
+		{CreationClassName: "Msvm_ComputerSystem", Name: "C2D3", ElementName: "vm's \"two\"", EnabledState: 3},
+	} {
+		_, err := repo.AddObject("Msvm_ComputerSystem", system)
+		require.NoError(t, err)
+	}
+	return repo
+}
+
+func TestMemoryRepository_GetObject(t *testing.T) {
+	service := newTestRepository(t).Service()
+	defer service.Close()
+
+	instance, err := service.GetObject(`Msvm_ComputerSystem.Name="a0b1",CreationClassName="Msvm_ComputerSystem"`)
+	require.NoError(t, err)
+	defer instance.Close()
+
+	var system testSystem
+	require.NoError(t, instance.GetAll(&system))
+	assert.Equal(t, "vm-1", system.ElementName)
+	assert.Equal(t, uint16(2), system.EnabledState)
+	assert.Equal(t, uint64(1<<40), system.OnTimeInMilliseconds)
+
+	path, err := instance.Path()
+	require.NoError(t, err)
+	assert.Equal(t, `\\localhost\root\virtualization\v2:Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B1"`, path)
+
+	_, err = service.GetObject(`Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="missing"`)
+	var wmiErr *WmiError
+	require.True(t, errors.As(err, &wmiErr))
+	assert.Equal(t, uintptr(WBEM_E_NOT_FOUND), wmiErr.Code())
+}
+
+func TestMemoryRepository_ExecQuery(t *testing.T) {
+	service := newTestRepository(t).Service()
+	defer service.Close()
+
+	tests := []struct {
+		wql   string
+		names []string
+	}{
+		{`SELECT * FROM Msvm_ComputerSystem`, []string{"vm-1", `vm's "two"`}},
+		{`SELECT * FROM CIM_ComputerSystem WHERE EnabledState = 2`, []string{"vm-1"}},
+		{`select ElementName from Msvm_ComputerSystem where ElementName = 'vm\'s "two"'`, []string{`vm's "two"`}},
+		{`SELECT * FROM Msvm_ComputerSystem WHERE ElementName LIKE 'VM-%' OR EnabledState > 2`, []string{"vm-1", `vm's "two"`}},
+		{`SELECT * FROM Msvm_ComputerSystem WHERE NOT (EnabledState <> 3) AND Name IS NOT NULL`, []string{`vm's "two"`}},
+		{`SELECT * FROM Msvm_ComputerSystem WHERE OnTimeInMilliseconds >= 1099511627776`, []string{"vm-1"}},
+		{`SELECT * FROM Msvm_ComputerSystem WHERE Description IS NULL AND ElementName LIKE '[^v]%'`, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.wql, func(t *testing.T) {
+			instances, err := service.FindInstances(test.wql)
+			require.NoError(t, err)
+
+			var names []string
+			for _, instance := range instances {
+				name, err := instance.GetAsString("ElementName")
+				require.NoError(t, err)
+				names = append(names, name)
+				instance.Close()
+			}
+			assert.Equal(t, test.names, names)
+		})
+	}
+
+	_, err := service.FindInstances(`SELECT * FROM Msvm_ComputerSystem WHERE`)
+	var wmiErr *WmiError
+	require.True(t, errors.As(err, &wmiErr))
+	assert.Equal(t, uintptr(WBEM_E_INVALID_QUERY), wmiErr.Code())
+}
+
+func TestMemoryRepository_Associators(t *testing.T) {
+	repo := newTestRepository(t)
+	service := repo.Service()
+	defer service.Close()
+
+	system, err := service.FindFirstInstance(`SELECT * FROM Msvm_ComputerSystem WHERE Name = 'A0B1'`)
+	require.NoError(t, err)
+	defer system.Close()
+	systemPath, err := system.Path()
+	require.NoError(t, err)
+
+	settingPath, err := repo.AddInstance("Msvm_VirtualSystemSettingData", map[string]interface{}{
+		"InstanceID":  `Microsoft:A0B1`,
+		"ElementName": "vm-1",
+	})
+	require.NoError(t, err)
+	_, err = repo.AddInstance("Msvm_SettingsDefineState", map[string]interface{}{
+		"ManagedElement":  Reference(systemPath),
+		"SettingData":     Reference(settingPath),
+		"ConfigurationID": "current",
+	})
+	require.NoError(t, err)
+
+	setting, err := system.GetRelated("Msvm_VirtualSystemSettingData")
+	require.NoError(t, err)
+	defer setting.Close()
+	instanceID, err := setting.GetAsString("InstanceID")
+	require.NoError(t, err)
+	assert.Equal(t, "Microsoft:A0B1", instanceID)
+
+	references, err := service.FindReferenceInstances(systemPath, "Msvm_SettingsDefineState")
+	require.NoError(t, err)
+	require.Len(t, references, 1)
+	configurationID, err := references[0].GetAsString("ConfigurationID")
+	require.NoError(t, err)
+	assert.Equal(t, "current", configurationID)
+	references[0].Close()
+
+	// Deleting an endpoint removes its associations
+	require.NoError(t, repo.Delete(settingPath))
+	references, err = service.FindReferenceInstances(systemPath, "Msvm_SettingsDefineState")
+	require.NoError(t, err)
+	assert.Empty(t, references)
+}
+
+func TestMemoryRepository_SpawnInstance(t *testing.T) {
+	repo := NewMemoryRepository(testNamespace)
+	repo.DefineClass(MemoryClass{
+		Name: "Msvm_VirtualSystemSettingData",
+		Properties: map[string]CIMTYPE_ENUMERATION{
+			"ElementName":          CIM_STRING,
+			"VirtualSystemSubType": CIM_STRING,
+			"Notes":                CIM_STRING | CIM_FLAG_ARRAY,
+		},
+	})
+	service := repo.Service()
+	defer service.Close()
+
+	instance, err := service.SpawnInstance("Msvm_VirtualSystemSettingData")
+	require.NoError(t, err)
+	defer instance.Close()
+
+	require.NoError(t, instance.Put("ElementName", "a<b"))
+	require.NoError(t, instance.Put("Notes", []string{"first", "second"}))
+	assert.Error(t, instance.Put("Undeclared", 1))
+
+	text := instance.GetCimText()
+	assert.Contains(t, text, `<INSTANCE CLASSNAME="Msvm_VirtualSystemSettingData">`)
+	assert.Contains(t, text, `<PROPERTY NAME="ElementName" TYPE="string"><VALUE>a&lt;b</VALUE></PROPERTY>`)
+	assert.Contains(t, text, `<PROPERTY.ARRAY NAME="Notes" TYPE="string"><VALUE.ARRAY><VALUE>first</VALUE><VALUE>second</VALUE></VALUE.ARRAY></PROPERTY.ARRAY>`)
+}
+
+func TestMemoryRepository_Method(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.DefineClass(MemoryClass{
+		Name:       "Msvm_ComputerSystem",
+		Superclass: "CIM_ComputerSystem",
+		Methods: map[string]map[string]CIMTYPE_ENUMERATION{
+			"RequestStateChange": {"RequestedState": CIM_UINT16},
+		},
+	})
+	repo.HandleMethod("Msvm_ComputerSystem", "RequestStateChange", func(call *MethodCall) error {
+		if err := call.Repository.Update(call.Path, map[string]interface{}{"EnabledState": call.In("RequestedState")}); err != nil {
+			return err
+		}
+		jobPath, err := call.Repository.AddInstance("Msvm_ConcreteJob", map[string]interface{}{
+			"InstanceID": "job-1",
+			"JobState":   uint16(7),
+			"ErrorCode":  uint16(0),
+		})
+		if err != nil {
+			return err
+		}
+		call.Out("Job", Reference(jobPath))
+		call.Return(4096)
+		return nil
+	})
+
+	service := repo.Service()
+	defer service.Close()
+
+	system, err := service.FindFirstInstance(`SELECT * FROM Msvm_ComputerSystem WHERE Name = 'C2D3'`)
+	require.NoError(t, err)
+	defer system.Close()
+
+	var job *Instance
+	var returnValue uint32
+	err = system.Method("RequestStateChange").
+		In("RequestedState", uint16(2)).
+		Execute().
+		Out("Job", &job).
+		Out("ReturnValue", &returnValue).
+		End()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(4096), returnValue)
+	require.NotNil(t, job)
+	defer job.Close()
+	require.NoError(t, WaitJob(service, job))
+
+	require.NoError(t, system.Refresh())
+	state, err := system.GetAsUint("EnabledState")
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), state)
+
+	err = system.Method("Undefined").Execute().End()
+	var wmiErr *WmiError
+	require.True(t, errors.As(err, &wmiErr))
+	assert.Equal(t, uintptr(WBEM_E_METHOD_NOT_IMPLEMENTED), wmiErr.Code())
+}
+
+func TestSetLocalBackendFactory(t *testing.T) {
+	repo := newTestRepository(t)
+	previous := SetLocalBackendFactory(MemoryBackendFactory(repo))
+	defer SetLocalBackendFactory(previous)
+
+	service, err := NewLocalService("root/virtualization/v2")
+	require.NoError(t, err)
+	defer service.Close()
+
+	var systems []testSystem
+	require.NoError(t, service.FindObjects("SELECT * FROM Msvm_ComputerSystem", &systems))
+	assert.Len(t, systems, 2)
+
+	_, err = NewLocalService(`root\cimv2`)
+	var wmiErr *WmiError
+	require.True(t, errors.As(err, &wmiErr))
+	assert.Equal(t, uintptr(WBEM_E_INVALID_NAMESPACE), wmiErr.Code())
+}
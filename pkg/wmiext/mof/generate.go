@@ -0,0 +1,533 @@
+package mof
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	gotoken "go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Config configures the bindings generated from a schema.
+type Config struct {
+	// Package is the name of the generated package.
+	Package string
+	// Classes lists the classes to generate, every class of the schema when empty.
+	Classes []string
+	// Sources names the MOF files in the header of the generated file.
+	Sources []string
+}
+
+// goTypes maps the intrinsic data types to Go. Datetimes are kept as strings since they hold either
+// a timestamp or an interval, and references hold object paths.
+var goTypes = map[string]string{
+	"boolean":  "bool",
+	"string":   "string",
+	"char16":   "uint16",
+	"datetime": "string",
+	"ref":      "string",
+	"object":   "*wmiext.Instance",
+	"uint8":    "uint8",
+	"sint8":    "int8",
+	"uint16":   "uint16",
+	"sint16":   "int16",
+	"uint32":   "uint32",
+	"sint32":   "int32",
+	"uint64":   "uint64",
+	"sint64":   "int64",
+	"real32":   "float32",
+	"real64":   "float64",
+}
+
+// cimTypes maps the intrinsic data types to the wmiext CIM types.
+var cimTypes = map[string]string{
+	"boolean":  "CIM_BOOLEAN",
+	"string":   "CIM_STRING",
+	"char16":   "CIM_CHAR16",
+	"datetime": "CIM_DATETIME",
+	"ref":      "CIM_REFERENCE",
+	"object":   "CIM_OBJECT",
+	"uint8":    "CIM_UINT8",
+	"sint8":    "CIM_SINT8",
+	"uint16":   "CIM_UINT16",
+	"sint16":   "CIM_SINT16",
+	"uint32":   "CIM_UINT32",
+	"sint32":   "CIM_SINT32",
+	"uint64":   "CIM_UINT64",
+	"sint64":   "CIM_SINT64",
+	"real32":   "CIM_REAL32",
+	"real64":   "CIM_REAL64",
+}
+
+// instanceMembers are the members promoted from the embedded *wmiext.Instance that generated
+// fields would hide.
+var instanceMembers = map[string]bool{
+	"Instance": true, "Method": true, "Close": true, "Put": true, "GetAll": true, "Refresh": true,
+}
+
+// EnumValue is a value of a ValueMap qualifier along with its name from the Values qualifier.
+type EnumValue struct {
+	Name  string
+	Value int64
+}
+
+// Enum returns the values named by the ValueMap and Values qualifiers, skipping ranges and
+// reserved values without a number.
+func Enum(qualifiers Qualifiers) []EnumValue {
+	names := qualifiers.Strings("Values")
+	if len(names) == 0 {
+		return nil
+	}
+	valueMap := qualifiers.Strings("ValueMap")
+	if len(valueMap) == 0 {
+		for i := range names {
+			valueMap = append(valueMap, strconv.Itoa(i))
+		}
+	}
+
+	var values []EnumValue
+	seen := map[int64]bool{}
+	for i, text := range valueMap {
+		if i >= len(names) {
+			break
+		}
+		value, err := strconv.ParseInt(text, 0, 64)
+		if err != nil || seen[value] {
+			continue
+		}
+		seen[value] = true
+		values = append(values, EnumValue{Name: names[i], Value: value})
+	}
+	return values
+}
+
+type generator struct {
+	schema  *Schema
+	config  Config
+	buf     bytes.Buffer
+	names   map[*Class]string
+	imports map[string]bool
+}
+
+// Generate generates Go bindings for the classes of the schema: a struct per class populated by
+// wmiext.Instance.GetAll, an enum type per integer property or parameter qualified by ValueMap and
+// Values, a method per class method invoking it through wmiext.MethodExecutor, and a declaration
+// of the class for wmiext.MemoryRepository.
+func Generate(schema *Schema, config Config) ([]byte, error) {
+	if !gotoken.IsIdentifier(config.Package) {
+		return nil, fmt.Errorf("invalid package name %q", config.Package)
+	}
+
+	classes := schema.Classes()
+	if len(config.Classes) > 0 {
+		classes = nil
+		for _, name := range config.Classes {
+			class := schema.Class(name)
+			if class == nil {
+				return nil, fmt.Errorf("class %s not found", name)
+			}
+			classes = append(classes, class)
+		}
+	}
+
+	g := &generator{schema: schema, config: config, names: map[*Class]string{}, imports: map[string]bool{}}
+	if err := g.nameClasses(classes); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	for _, class := range classes {
+		g.buf.Reset()
+		if err := g.class(class); err != nil {
+			return nil, err
+		}
+		body.Write(g.buf.Bytes())
+	}
+
+	var out bytes.Buffer
+	sources := "MOF"
+	if len(config.Sources) > 0 {
+		sources = strings.Join(config.Sources, ", ")
+	}
+	fmt.Fprintf(&out, "// Code generated by mofgen from %s. DO NOT EDIT.\n\n", sources)
+	fmt.Fprintf(&out, "package %s\n\n", config.Package)
+	if len(g.imports) > 0 {
+		// Standard library imports come first, in their own group
+		var std, others []string
+		for path := range g.imports {
+			if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+				others = append(others, strconv.Quote(path))
+			} else {
+				std = append(std, strconv.Quote(path))
+			}
+		}
+		sort.Strings(std)
+		sort.Strings(others)
+		groups := []string{strings.Join(std, "\n"), strings.Join(others, "\n")}
+		fmt.Fprintf(&out, "import (\n%s\n)\n\n", strings.TrimSpace(strings.Join(groups, "\n\n")))
+	}
+	out.Write(body.Bytes())
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return formatted, nil
+}
+
+// nameClasses names the Go types of the classes after the class name without its schema prefix,
+// e.g. ComputerSystem for Msvm_ComputerSystem, unless two classes would share the name.
+func (g *generator) nameClasses(classes []*Class) error {
+	short := map[string]int{}
+	for _, class := range classes {
+		short[shortName(class.Name)]++
+	}
+	taken := map[string]*Class{}
+	for _, class := range classes {
+		name := shortName(class.Name)
+		if short[name] > 1 {
+			name = exportedName(strings.ReplaceAll(class.Name, "_", ""))
+		}
+		if other, ok := taken[name]; ok {
+			return fmt.Errorf("classes %s and %s both map to type %s", other.Name, class.Name, name)
+		}
+		taken[name] = class
+		g.names[class] = name
+	}
+	return nil
+}
+
+func shortName(className string) string {
+	if i := strings.Index(className, "_"); i >= 0 && i < len(className)-1 {
+		return exportedName(strings.ReplaceAll(className[i+1:], "_", ""))
+	}
+	return exportedName(className)
+}
+
+// exportedName returns name with its first letter in upper case.
+func exportedName(name string) string {
+	runes := []rune(name)
+	if len(runes) == 0 {
+		return name
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// localName returns name in lower camel case, e.g. vmName for VMName, avoiding Go keywords.
+func localName(name string) string {
+	runes := []rune(name)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	if upper > 1 && upper < len(runes) {
+		upper--
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	local := string(runes)
+	if gotoken.IsKeyword(local) {
+		local += "Value"
+	}
+	return local
+}
+
+// identifier turns a Values entry into an identifier, e.g. NotApplicable for "Not Applicable".
+func identifier(text string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "Value" + name
+	}
+	return name
+}
+
+// receiverName returns the initials of a type name, e.g. cs for ComputerSystem.
+func receiverName(typeName string) string {
+	var b strings.Builder
+	for i, r := range typeName {
+		if i == 0 || unicode.IsUpper(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	name := b.String()
+	if gotoken.IsKeyword(name) {
+		name = name[:1]
+	}
+	return name
+}
+
+// summary returns the first sentence of a description.
+func summary(description string) string {
+	description = strings.Join(strings.Fields(description), " ")
+	if i := strings.Index(description, ". "); i >= 0 {
+		description = description[:i+1]
+	}
+	return description
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// comment writes text as a comment wrapped at 100 columns.
+func (g *generator) comment(indent string, text string) {
+	line := indent + "//"
+	for _, word := range strings.Fields(text) {
+		if len(line)+1+len(word) > 100 && line != indent+"//" {
+			g.printf("%s\n", line)
+			line = indent + "//"
+		}
+		line += " " + word
+	}
+	g.printf("%s\n", line)
+}
+
+// goType returns the Go type of a value of type t, or of enum when set.
+func goType(t Type, enum string) string {
+	name := goTypes[t.Name]
+	if enum != "" {
+		name = enum
+	}
+	if t.Array {
+		return "[]" + name
+	}
+	return name
+}
+
+// enum writes the enum type named by the ValueMap and Values qualifiers of an integer type and
+// returns its name, or returns an empty string when there is none.
+func (g *generator) enum(typeName string, t Type, qualifiers Qualifiers, of string) string {
+	values := Enum(qualifiers)
+	if !t.IsInteger() || len(values) == 0 {
+		return ""
+	}
+
+	g.comment("", fmt.Sprintf("%s is the %s.", typeName, of))
+	g.printf("type %s %s\n\n", typeName, goTypes[t.Name])
+	g.printf("const (\n")
+	names := make([]string, len(values))
+	used := map[string]bool{}
+	for i, value := range values {
+		name := typeName + identifier(value.Name)
+		if used[name] {
+			name += strconv.FormatInt(value.Value, 10)
+		}
+		used[name] = true
+		names[i] = name
+		g.printf("\t%s %s = %d\n", name, typeName, value.Value)
+	}
+	g.printf(")\n\n")
+
+	g.imports["fmt"] = true
+	g.printf("func (v %s) String() string {\n\tswitch v {\n", typeName)
+	for i, value := range values {
+		g.printf("\tcase %s:\n\t\treturn %s\n", names[i], strconv.Quote(value.Name))
+	}
+	g.printf("\t}\n\treturn fmt.Sprintf(\"%s(%%d)\", %s(v))\n}\n\n", typeName, goTypes[t.Name])
+	return typeName
+}
+
+func (g *generator) class(class *Class) error {
+	name := g.names[class]
+	receiver := receiverName(name)
+	properties := g.schema.Properties(class)
+	methods := g.schema.Methods(class)
+	g.imports["github.com/rokukoo/hyperv/pkg/wmiext"] = true
+
+	// Class name constant, named after the class like the hand-written bindings
+	g.printf("const %s = %s\n\n", class.Name, strconv.Quote(class.Name))
+
+	fields := map[string]bool{"S__PATH": true, "S__CLASS": true}
+	enums := map[*Property]string{}
+	for _, property := range properties {
+		field := exportedName(property.Name)
+		if !gotoken.IsIdentifier(field) || strings.HasPrefix(property.Name, "__") {
+			return fmt.Errorf("%s: property %s.%s cannot be mapped to a field", property.Pos, class.Name, property.Name)
+		}
+		if instanceMembers[field] || fields[field] {
+			return fmt.Errorf("%s: property %s.%s conflicts with another member of %s", property.Pos, class.Name, property.Name, name)
+		}
+		fields[field] = true
+		enums[property] = g.enum(name+field, property.Type, property.Qualifiers, fmt.Sprintf("%s property of %s", property.Name, class.Name))
+	}
+
+	g.printf("// %s is the %s class.", name, class.Name)
+	if description := summary(class.Qualifiers.String("Description")); description != "" {
+		g.printf("\n//\n")
+		g.comment("", description)
+	} else {
+		g.printf("\n")
+	}
+	g.printf("type %s struct {\n", name)
+	g.printf("\tS__PATH  string `json:\"-\"`\n\tS__CLASS string `json:\"-\"`\n\n")
+	for _, property := range properties {
+		g.printf("\t%s %s", exportedName(property.Name), goType(property.Type, enums[property]))
+		if property.Qualifiers.Has("Key") {
+			g.printf(" // Key")
+		}
+		g.printf("\n")
+	}
+	g.printf("\n\t*wmiext.Instance `json:\"-\"`\n}\n\n")
+
+	g.printf("// Path returns the object path of the instance.\n")
+	g.printf("func (%s *%s) Path() string {\n\treturn %s.S__PATH\n}\n\n", receiver, name, receiver)
+
+	for _, method := range methods {
+		if fields[method.Name] || instanceMembers[method.Name] || method.Name == "Path" {
+			return fmt.Errorf("%s: method %s.%s conflicts with another member of %s", method.Pos, class.Name, method.Name, name)
+		}
+		if err := g.method(class, name, receiver, method); err != nil {
+			return err
+		}
+	}
+
+	g.memoryClass(class, name, properties, methods)
+	return nil
+}
+
+type argument struct {
+	param *Parameter
+	name  string
+	enum  string
+}
+
+func (g *generator) method(class *Class, typeName string, receiver string, method *Method) error {
+	var ins, outs []argument
+	used := map[string]bool{receiver: true, "err": true, "returnValue": true}
+	unique := func(name string) string {
+		for used[name] {
+			name += "Value"
+		}
+		used[name] = true
+		return name
+	}
+
+	for _, param := range method.Parameters {
+		if !gotoken.IsIdentifier(param.Name) {
+			return fmt.Errorf("%s: parameter %s of %s.%s cannot be mapped to a variable", method.Pos, param.Name, class.Name, method.Name)
+		}
+		var enum string
+		if !param.Type.Array {
+			enum = g.enum(typeName+method.Name+exportedName(param.Name), param.Type, param.Qualifiers,
+				fmt.Sprintf("%s parameter of %s.%s", param.Name, class.Name, method.Name))
+		}
+		if param.In() {
+			ins = append(ins, argument{param: param, name: unique(localName(param.Name)), enum: enum})
+		}
+		if param.Out() {
+			name := localName(param.Name)
+			if param.In() {
+				name += "Out"
+			}
+			outs = append(outs, argument{param: param, name: unique(name), enum: enum})
+		}
+	}
+
+	var returnType string
+	if method.ReturnType.Name != "" && goTypes[method.ReturnType.Name] != "" {
+		enum := g.enum(typeName+method.Name+"ReturnValue", method.ReturnType, method.Qualifiers,
+			fmt.Sprintf("return value of %s.%s", class.Name, method.Name))
+		returnType = goType(method.ReturnType, enum)
+	}
+
+	doc := fmt.Sprintf("%s invokes %s.%s.", method.Name, class.Name, method.Name)
+	if description := summary(method.Qualifiers.String("Description")); description != "" {
+		doc += " " + description
+	}
+	g.comment("", doc)
+
+	var params, results []string
+	for _, in := range ins {
+		params = append(params, in.name+" "+goType(in.param.Type, in.enum))
+	}
+	for _, out := range outs {
+		results = append(results, out.name+" "+goType(out.param.Type, out.enum))
+	}
+	if returnType != "" {
+		results = append(results, "returnValue "+returnType)
+	}
+	results = append(results, "err error")
+	g.printf("func (%s *%s) %s(%s) (%s) {\n", receiver, typeName, method.Name, strings.Join(params, ", "), strings.Join(results, ", "))
+
+	g.printf("\terr = %s.Method(%s).\n", receiver, strconv.Quote(method.Name))
+	for _, in := range ins {
+		value := in.name
+		if in.enum != "" {
+			value = fmt.Sprintf("%s(%s)", goTypes[in.param.Type.Name], in.name)
+		}
+		g.printf("\t\tIn(%s, %s).\n", strconv.Quote(in.param.Name), value)
+	}
+	g.printf("\t\tExecute().\n")
+	for _, out := range outs {
+		g.printf("\t\tOut(%s, &%s).\n", strconv.Quote(out.param.Name), out.name)
+	}
+	if returnType != "" {
+		g.printf("\t\tOut(\"ReturnValue\", &returnValue).\n")
+	}
+	g.printf("\t\tEnd()\n\treturn\n}\n\n")
+	return nil
+}
+
+func cimType(t Type) string {
+	name := "wmiext." + cimTypes[t.Name]
+	if t.Array {
+		name += " | wmiext.CIM_FLAG_ARRAY"
+	}
+	return name
+}
+
+func (g *generator) memoryClass(class *Class, typeName string, properties []*Property, methods []*Method) {
+	g.printf("// %sMemoryClass declares %s in a wmiext.MemoryRepository.\n", typeName, class.Name)
+	g.printf("func %sMemoryClass() wmiext.MemoryClass {\n\treturn wmiext.MemoryClass{\n", typeName)
+	g.printf("\t\tName: %s,\n", class.Name)
+	if superclass := g.schema.Class(class.Superclass); superclass != nil && g.names[superclass] != "" {
+		g.printf("\t\tSuperclass: %s,\n", superclass.Name)
+	}
+	if keys := g.schema.Keys(class); len(keys) > 0 {
+		quoted := make([]string, len(keys))
+		for i, key := range keys {
+			quoted[i] = strconv.Quote(key)
+		}
+		g.printf("\t\tKeys: []string{%s},\n", strings.Join(quoted, ", "))
+	}
+	if len(properties) > 0 {
+		g.printf("\t\tProperties: map[string]wmiext.CIMTYPE_ENUMERATION{\n")
+		for _, property := range properties {
+			g.printf("\t\t\t%s: %s,\n", strconv.Quote(property.Name), cimType(property.Type))
+		}
+		g.printf("\t\t},\n")
+	}
+	if len(methods) > 0 {
+		g.printf("\t\tMethods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{\n")
+		for _, method := range methods {
+			g.printf("\t\t\t%s: {", strconv.Quote(method.Name))
+			var params []string
+			for _, param := range method.Parameters {
+				if param.In() {
+					params = append(params, fmt.Sprintf("%s: %s", strconv.Quote(param.Name), cimType(param.Type)))
+				}
+			}
+			g.printf("%s},\n", strings.Join(params, ", "))
+		}
+		g.printf("\t\t},\n")
+	}
+	g.printf("\t}\n}\n\n")
+}
@@ -0,0 +1,107 @@
+package mof
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateTestMOF(t *testing.T, config Config) string {
+	classes, err := Parse("test.mof", []byte(testMOF))
+	require.NoError(t, err)
+	schema, err := NewSchema(classes...)
+	require.NoError(t, err)
+	src, err := Generate(schema, config)
+	require.NoError(t, err)
+	return string(src)
+}
+
+func TestGenerate(t *testing.T) {
+	src := generateTestMOF(t, Config{Package: "bindings", Sources: []string{"test.mof"}})
+
+	for _, expected := range []string{
+		"// Code generated by mofgen from test.mof. DO NOT EDIT.\n\npackage bindings\n",
+		"import (\n\t\"fmt\"\n\n\t\"github.com/rokukoo/hyperv/pkg/wmiext\"\n)\n",
+		"const CIM_Base = \"CIM_Base\"\n",
+		"// Base is the CIM_Base class.\n//\n// Base class\ntype Base struct {\n\tS__PATH  string `json:\"-\"`\n",
+		"\tInstanceID   string // Key\n",
+		"\tDedicated    []uint16\n",
+		"\tEnabledState BaseEnabledState\n",
+		"\tLetter       uint16\n",
+		"type BaseEnabledState uint16\n",
+		"\tBaseEnabledStateUnknown  BaseEnabledState = 0\n",
+		"\tcase BaseEnabledStateDisabled:\n\t\treturn \"Disabled\"\n",
+		"return fmt.Sprintf(\"BaseEnabledState(%d)\", uint16(v))",
+		"func (b *Base) Path() string {\n\treturn b.S__PATH\n}",
+		"\tEnabledState DerivedEnabledState\n",
+		"\tParent       string\n",
+		"\tNotes        []string\n",
+		"\tInstallDate  string\n",
+		"type DerivedDefineReturnValue uint32\n",
+		"// Define invokes Msvm_Derived.Define.\n" +
+			"func (d *Derived) Define(settings string, reference string, flags []uint16, implicit string) " +
+			"(result string, flagsOut []uint16, returnValue DerivedDefineReturnValue, err error) {\n" +
+			"\terr = d.Method(\"Define\").\n" +
+			"\t\tIn(\"Settings\", settings).\n" +
+			"\t\tIn(\"Reference\", reference).\n" +
+			"\t\tIn(\"Flags\", flags).\n" +
+			"\t\tIn(\"Implicit\", implicit).\n" +
+			"\t\tExecute().\n" +
+			"\t\tOut(\"Result\", &result).\n" +
+			"\t\tOut(\"Flags\", &flagsOut).\n" +
+			"\t\tOut(\"ReturnValue\", &returnValue).\n" +
+			"\t\tEnd()\n\treturn\n}",
+		"func DerivedMemoryClass() wmiext.MemoryClass {\n\treturn wmiext.MemoryClass{\n" +
+			"\t\tName:       Msvm_Derived,\n\t\tSuperclass: CIM_Base,\n\t\tKeys:       []string{\"InstanceID\"},\n",
+		"\t\t\t\"Dedicated\":    wmiext.CIM_UINT16 | wmiext.CIM_FLAG_ARRAY,\n",
+		"\t\t\t\"Parent\":       wmiext.CIM_REFERENCE,\n",
+		"\t\t\t\"Define\": {\"Settings\": wmiext.CIM_STRING, \"Reference\": wmiext.CIM_REFERENCE, " +
+			"\"Flags\": wmiext.CIM_UINT16 | wmiext.CIM_FLAG_ARRAY, \"Implicit\": wmiext.CIM_STRING},\n",
+	} {
+		assert.Contains(t, src, expected)
+	}
+
+	only := generateTestMOF(t, Config{Package: "bindings", Classes: []string{"msvm_derived"}})
+	assert.NotContains(t, only, "type Base struct")
+	assert.NotContains(t, only, "Superclass:")
+	assert.Contains(t, only, "// Code generated by mofgen from MOF. DO NOT EDIT.")
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	parse := func(src string) *Schema {
+		classes, err := Parse("test.mof", []byte(src))
+		require.NoError(t, err)
+		schema, err := NewSchema(classes...)
+		require.NoError(t, err)
+		return schema
+	}
+
+	_, err := Generate(parse(testMOF), Config{Package: "not a package"})
+	assert.EqualError(t, err, `invalid package name "not a package"`)
+	_, err = Generate(parse(testMOF), Config{Package: "bindings", Classes: []string{"CIM_Missing"}})
+	assert.EqualError(t, err, "class CIM_Missing not found")
+	_, err = Generate(parse(`class A { string Instance; };`), Config{Package: "bindings"})
+	assert.EqualError(t, err, "test.mof:1:11: property A.Instance conflicts with another member of A")
+	_, err = Generate(parse(`class A { string Run; uint32 Run(); };`), Config{Package: "bindings"})
+	assert.EqualError(t, err, "test.mof:1:23: method A.Run conflicts with another member of A")
+
+	src, err := Generate(parse(`class CIM_System {}; class Msvm_System {};`), Config{Package: "bindings"})
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(src), "type CIMSystem struct") && strings.Contains(string(src), "type MsvmSystem struct"))
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, "ComputerSystem", shortName("Msvm_ComputerSystem"))
+	assert.Equal(t, "Setting", shortName("_Setting"))
+	assert.Equal(t, "vmName", localName("VMName"))
+	assert.Equal(t, "id", localName("ID"))
+	assert.Equal(t, "requestedState", localName("RequestedState"))
+	assert.Equal(t, "typeValue", localName("Type"))
+	assert.Equal(t, "NotApplicable", identifier("Not Applicable"))
+	assert.Equal(t, "MethodParametersCheckedJobStarted", identifier("Method Parameters Checked - Job Started"))
+	assert.Equal(t, "Value1394Controller", identifier("1394 controller"))
+	assert.Equal(t, "cs", receiverName("ComputerSystem"))
+	assert.Equal(t, "Requests a change.", summary("Requests a change. The change\n  may run as a job."))
+}
@@ -0,0 +1,268 @@
+package mof
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenInteger
+	tokenReal
+	tokenChar
+	tokenPunct
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   Position
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of file"
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// Position is a location in a MOF file.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Error is a syntax error of a MOF file.
+type Error struct {
+	Pos Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+type lexer struct {
+	src  []rune
+	off  int
+	line int
+	col  int
+	file string
+}
+
+func newLexer(file string, src []byte) *lexer {
+	return &lexer{src: []rune(string(src)), line: 1, col: 1, file: file}
+}
+
+func (l *lexer) pos() Position {
+	return Position{File: l.file, Line: l.line, Column: l.col}
+}
+
+func (l *lexer) peekRune(ahead int) rune {
+	if l.off+ahead >= len(l.src) {
+		return 0
+	}
+	return l.src[l.off+ahead]
+}
+
+func (l *lexer) advance() rune {
+	r := l.src[l.off]
+	l.off++
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return r
+}
+
+func (l *lexer) errorf(pos Position, format string, args ...interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// skipSpace skips white space and comments.
+func (l *lexer) skipSpace() error {
+	for l.off < len(l.src) {
+		r := l.peekRune(0)
+		switch {
+		case unicode.IsSpace(r) || r == '\uFEFF':
+			l.advance()
+		case r == '/' && l.peekRune(1) == '/':
+			for l.off < len(l.src) && l.peekRune(0) != '\n' {
+				l.advance()
+			}
+		case r == '/' && l.peekRune(1) == '*':
+			start := l.pos()
+			l.advance()
+			l.advance()
+			for {
+				if l.off >= len(l.src) {
+					return l.errorf(start, "unterminated comment")
+				}
+				if l.peekRune(0) == '*' && l.peekRune(1) == '/' {
+					l.advance()
+					l.advance()
+					break
+				}
+				l.advance()
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	pos := l.pos()
+	if l.off >= len(l.src) {
+		return token{kind: tokenEOF, pos: pos}, nil
+	}
+
+	r := l.peekRune(0)
+	switch {
+	case r == '_' || unicode.IsLetter(r):
+		start := l.off
+		for l.off < len(l.src) && (l.peekRune(0) == '_' || unicode.IsLetter(l.peekRune(0)) || unicode.IsDigit(l.peekRune(0))) {
+			l.advance()
+		}
+		return token{kind: tokenIdent, text: string(l.src[start:l.off]), pos: pos}, nil
+	case r == '"':
+		return l.lexString(pos)
+	case r == '\'':
+		return l.lexChar(pos)
+	case unicode.IsDigit(r) || ((r == '-' || r == '+') && unicode.IsDigit(l.peekRune(1))) || (r == '.' && unicode.IsDigit(l.peekRune(1))):
+		return l.lexNumber(pos)
+	case strings.ContainsRune("[](){},;:=#", r):
+		l.advance()
+		return token{kind: tokenPunct, text: string(r), pos: pos}, nil
+	default:
+		return token{}, l.errorf(pos, "unexpected character %q", r)
+	}
+}
+
+func (l *lexer) lexEscape(pos Position) (rune, error) {
+	if l.off >= len(l.src) {
+		return 0, l.errorf(pos, "unterminated escape sequence")
+	}
+	switch r := l.advance(); r {
+	case 'b':
+		return '\b', nil
+	case 't':
+		return '\t', nil
+	case 'n':
+		return '\n', nil
+	case 'f':
+		return '\f', nil
+	case 'r':
+		return '\r', nil
+	case '"', '\'', '\\':
+		return r, nil
+	case 'x', 'X':
+		var digits []rune
+		for len(digits) < 4 && l.off < len(l.src) && strings.ContainsRune("0123456789abcdefABCDEF", l.peekRune(0)) {
+			digits = append(digits, l.advance())
+		}
+		value, err := strconv.ParseUint(string(digits), 16, 32)
+		if err != nil {
+			return 0, l.errorf(pos, "invalid escape sequence \\x%s", string(digits))
+		}
+		return rune(value), nil
+	default:
+		return 0, l.errorf(pos, "invalid escape sequence \\%c", r)
+	}
+}
+
+func (l *lexer) lexString(pos Position) (token, error) {
+	l.advance()
+	var b strings.Builder
+	for {
+		if l.off >= len(l.src) || l.peekRune(0) == '\n' {
+			return token{}, l.errorf(pos, "unterminated string")
+		}
+		r := l.advance()
+		switch r {
+		case '"':
+			return token{kind: tokenString, text: b.String(), value: b.String(), pos: pos}, nil
+		case '\\':
+			escaped, err := l.lexEscape(l.pos())
+			if err != nil {
+				return token{}, err
+			}
+			b.WriteRune(escaped)
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+func (l *lexer) lexChar(pos Position) (token, error) {
+	l.advance()
+	if l.off >= len(l.src) {
+		return token{}, l.errorf(pos, "unterminated character")
+	}
+	r := l.advance()
+	if r == '\\' {
+		var err error
+		if r, err = l.lexEscape(l.pos()); err != nil {
+			return token{}, err
+		}
+	}
+	if l.off >= len(l.src) || l.advance() != '\'' {
+		return token{}, l.errorf(pos, "unterminated character")
+	}
+	return token{kind: tokenChar, text: string(r), value: int64(r), pos: pos}, nil
+}
+
+func (l *lexer) lexNumber(pos Position) (token, error) {
+	start := l.off
+	if r := l.peekRune(0); r == '-' || r == '+' {
+		l.advance()
+	}
+	for l.off < len(l.src) {
+		r := l.peekRune(0)
+		if !(unicode.IsDigit(r) || unicode.IsLetter(r) || r == '.' ||
+			((r == '-' || r == '+') && (l.src[l.off-1] == 'e' || l.src[l.off-1] == 'E'))) {
+			break
+		}
+		l.advance()
+	}
+	text := string(l.src[start:l.off])
+
+	if value, err := strconv.ParseInt(text, 0, 64); err == nil {
+		return token{kind: tokenInteger, text: text, value: value, pos: pos}, nil
+	}
+	if value, err := strconv.ParseUint(text, 0, 64); err == nil {
+		return token{kind: tokenInteger, text: text, value: value, pos: pos}, nil
+	}
+	// Binary literals are written with a trailing B
+	if strings.HasSuffix(text, "b") || strings.HasSuffix(text, "B") {
+		if value, err := strconv.ParseInt(text[:len(text)-1], 2, 64); err == nil {
+			return token{kind: tokenInteger, text: text, value: value, pos: pos}, nil
+		}
+	}
+	if value, err := strconv.ParseFloat(text, 64); err == nil {
+		return token{kind: tokenReal, text: text, value: value, pos: pos}, nil
+	}
+	return token{}, l.errorf(pos, "invalid number %s", text)
+}
@@ -0,0 +1,284 @@
+// Package mof parses the class declarations of Managed Object Format files, such as the ones
+// describing the Msvm_* classes of the Hyper-V WMI provider, and generates Go bindings from them.
+package mof
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Qualifier is a qualifier of a class, property, method or parameter. Its value is a string,
+// int64, uint64, float64, bool, []interface{} of those, or nil.
+type Qualifier struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// Qualifiers is an ordered list of qualifiers, looked up by case-insensitive name.
+type Qualifiers []Qualifier
+
+// Get returns the qualifier with the given name.
+func (q Qualifiers) Get(name string) (Qualifier, bool) {
+	for _, qualifier := range q {
+		if strings.EqualFold(qualifier.Name, name) {
+			return qualifier, true
+		}
+	}
+	return Qualifier{}, false
+}
+
+// Has reports whether a boolean qualifier, such as Key or In, is present and not set to false.
+func (q Qualifiers) Has(name string) bool {
+	qualifier, ok := q.Get(name)
+	if !ok {
+		return false
+	}
+	value, isBool := qualifier.Value.(bool)
+	return !isBool || value
+}
+
+// String returns the value of a string qualifier, such as Description.
+func (q Qualifiers) String(name string) string {
+	qualifier, ok := q.Get(name)
+	if !ok {
+		return ""
+	}
+	if value, ok := qualifier.Value.(string); ok {
+		return value
+	}
+	return ""
+}
+
+// Strings returns the values of an array qualifier, such as ValueMap.
+func (q Qualifiers) Strings(name string) []string {
+	qualifier, ok := q.Get(name)
+	if !ok {
+		return nil
+	}
+	switch value := qualifier.Value.(type) {
+	case []interface{}:
+		values := make([]string, len(value))
+		for i, v := range value {
+			values[i] = fmt.Sprint(v)
+		}
+		return values
+	case nil:
+		return nil
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
+
+// Type is the data type of a property, parameter or method.
+type Type struct {
+	// Name is the intrinsic data type in lower case, e.g. uint16 or datetime, or "ref" for
+	// references.
+	Name string
+	// Ref is the class of a reference.
+	Ref string
+	// Array is set for arrays.
+	Array bool
+}
+
+func (t Type) String() string {
+	name := t.Name
+	if t.Ref != "" {
+		name = t.Ref + " ref"
+	}
+	if t.Array {
+		name += "[]"
+	}
+	return name
+}
+
+// IsInteger reports whether the type is an integer, to which a ValueMap applies.
+func (t Type) IsInteger() bool {
+	switch t.Name {
+	case "uint8", "sint8", "uint16", "sint16", "uint32", "sint32", "uint64", "sint64":
+		return true
+	}
+	return false
+}
+
+// Property is a property of a class.
+type Property struct {
+	Name       string
+	Type       Type
+	Qualifiers Qualifiers
+	// Default is the default value of the property, nil when unset.
+	Default interface{}
+	Pos     Position
+}
+
+// Parameter is a parameter of a method.
+type Parameter struct {
+	Name       string
+	Type       Type
+	Qualifiers Qualifiers
+}
+
+// In reports whether the parameter is an input, which parameters are unless only qualified Out.
+func (p *Parameter) In() bool {
+	return p.Qualifiers.Has("In") || !p.Qualifiers.Has("Out")
+}
+
+// Out reports whether the parameter is an output.
+func (p *Parameter) Out() bool {
+	return p.Qualifiers.Has("Out")
+}
+
+// Method is a method of a class.
+type Method struct {
+	Name       string
+	ReturnType Type
+	Parameters []*Parameter
+	Qualifiers Qualifiers
+	Pos        Position
+}
+
+// Class is a class declaration.
+type Class struct {
+	Name       string
+	Superclass string
+	Qualifiers Qualifiers
+	Properties []*Property
+	Methods    []*Method
+	Pos        Position
+}
+
+// Property returns the property declared by the class with the given name.
+func (c *Class) Property(name string) *Property {
+	for _, property := range c.Properties {
+		if strings.EqualFold(property.Name, name) {
+			return property
+		}
+	}
+	return nil
+}
+
+// Method returns the method declared by the class with the given name.
+func (c *Class) Method(name string) *Method {
+	for _, method := range c.Methods {
+		if strings.EqualFold(method.Name, name) {
+			return method
+		}
+	}
+	return nil
+}
+
+// Schema is a set of classes, resolving the members they inherit from one another.
+type Schema struct {
+	classes []*Class
+	byName  map[string]*Class
+}
+
+// NewSchema returns a schema of classes, failing on duplicate or cyclic declarations.
+func NewSchema(classes ...*Class) (*Schema, error) {
+	s := &Schema{byName: make(map[string]*Class)}
+	for _, class := range classes {
+		key := strings.ToLower(class.Name)
+		if previous, ok := s.byName[key]; ok {
+			return nil, &Error{Pos: class.Pos, Msg: fmt.Sprintf("class %s already declared at %s", class.Name, previous.Pos)}
+		}
+		s.byName[key] = class
+		s.classes = append(s.classes, class)
+	}
+	for _, class := range s.classes {
+		seen := map[*Class]bool{}
+		for c := class; c != nil; c = s.Class(c.Superclass) {
+			if seen[c] {
+				return nil, &Error{Pos: class.Pos, Msg: fmt.Sprintf("class %s inherits from itself", class.Name)}
+			}
+			seen[c] = true
+		}
+	}
+	return s, nil
+}
+
+// Classes returns the classes of the schema in declaration order.
+func (s *Schema) Classes() []*Class {
+	return s.classes
+}
+
+// Class returns the class with the given name, nil when not in the schema.
+func (s *Schema) Class(name string) *Class {
+	if name == "" {
+		return nil
+	}
+	return s.byName[strings.ToLower(name)]
+}
+
+// lineage returns the class followed by its superclasses in the schema.
+func (s *Schema) lineage(class *Class) []*Class {
+	var lineage []*Class
+	for c := class; c != nil; c = s.Class(c.Superclass) {
+		lineage = append(lineage, c)
+	}
+	return lineage
+}
+
+// Properties returns the properties of the class along with the ones inherited from its
+// superclasses in the schema, the inherited ones first. Overridden properties take the
+// qualifiers of the superclass, unless redeclared.
+func (s *Schema) Properties(class *Class) []*Property {
+	var properties []*Property
+	index := map[string]int{}
+	lineage := s.lineage(class)
+	for i := len(lineage) - 1; i >= 0; i-- {
+		for _, property := range lineage[i].Properties {
+			key := strings.ToLower(property.Name)
+			if j, ok := index[key]; ok {
+				properties[j] = override(properties[j], property)
+				continue
+			}
+			index[key] = len(properties)
+			properties = append(properties, property)
+		}
+	}
+	return properties
+}
+
+func override(inherited *Property, property *Property) *Property {
+	merged := *property
+	merged.Qualifiers = append(Qualifiers(nil), property.Qualifiers...)
+	for _, qualifier := range inherited.Qualifiers {
+		if _, ok := merged.Qualifiers.Get(qualifier.Name); !ok {
+			merged.Qualifiers = append(merged.Qualifiers, qualifier)
+		}
+	}
+	if merged.Default == nil {
+		merged.Default = inherited.Default
+	}
+	return &merged
+}
+
+// Methods returns the methods of the class along with the ones inherited from its superclasses in
+// the schema, the inherited ones first.
+func (s *Schema) Methods(class *Class) []*Method {
+	var methods []*Method
+	index := map[string]int{}
+	lineage := s.lineage(class)
+	for i := len(lineage) - 1; i >= 0; i-- {
+		for _, method := range lineage[i].Methods {
+			key := strings.ToLower(method.Name)
+			if j, ok := index[key]; ok {
+				methods[j] = method
+				continue
+			}
+			index[key] = len(methods)
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// Keys returns the names of the key properties of the class, including inherited ones.
+func (s *Schema) Keys(class *Class) []string {
+	var keys []string
+	for _, property := range s.Properties(class) {
+		if property.Qualifiers.Has("Key") {
+			keys = append(keys, property.Name)
+		}
+	}
+	return keys
+}
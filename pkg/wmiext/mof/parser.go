@@ -0,0 +1,435 @@
+package mof
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// intrinsicTypes are the data types of MOF.
+var intrinsicTypes = map[string]bool{
+	"boolean": true, "string": true, "char16": true, "datetime": true, "object": true,
+	"uint8": true, "sint8": true, "uint16": true, "sint16": true,
+	"uint32": true, "sint32": true, "uint64": true, "sint64": true,
+	"real32": true, "real64": true,
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// Parse parses the class declarations of a MOF file. Pragmas, qualifier declarations and instance
+// declarations are skipped.
+func Parse(file string, src []byte) ([]*Class, error) {
+	p := &parser{lex: newLexer(file, src)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var classes []*Class
+	for p.tok.kind != tokenEOF {
+		class, err := p.parseProduction()
+		if err != nil {
+			return nil, err
+		}
+		if class != nil {
+			classes = append(classes, class)
+		}
+	}
+	return classes, nil
+}
+
+// ParseFiles parses MOF files into a schema.
+func ParseFiles(paths ...string) (*Schema, error) {
+	var classes []*Class
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := Parse(path, src)
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, parsed...)
+	}
+	return NewSchema(classes...)
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) isPunct(text string) bool {
+	return p.tok.kind == tokenPunct && p.tok.text == text
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	return p.tok.kind == tokenIdent && strings.EqualFold(p.tok.text, keyword)
+}
+
+func (p *parser) expectPunct(text string) error {
+	if !p.isPunct(text) {
+		return p.errorf("expected %q, found %s", text, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *parser) expectIdent(what string) (string, error) {
+	if p.tok.kind != tokenIdent {
+		return "", p.errorf("expected %s, found %s", what, p.tok)
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+func (p *parser) parseProduction() (*Class, error) {
+	if p.isPunct("#") {
+		return nil, p.skipPragma()
+	}
+
+	var qualifiers Qualifiers
+	if p.isPunct("[") {
+		var err error
+		if qualifiers, err = p.parseQualifiers(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case p.isKeyword("class"):
+		return p.parseClass(qualifiers)
+	case p.isKeyword("instance"), p.isKeyword("qualifier"):
+		return nil, p.skipStatement()
+	default:
+		return nil, p.errorf("expected class declaration, found %s", p.tok)
+	}
+}
+
+func (p *parser) skipPragma() error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if !p.isKeyword("pragma") {
+		return p.errorf("expected pragma, found %s", p.tok)
+	}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if _, err := p.expectIdent("pragma name"); err != nil {
+		return err
+	}
+	if !p.isPunct("(") {
+		return nil
+	}
+	for !p.isPunct(")") {
+		if p.tok.kind == tokenEOF {
+			return p.errorf("unterminated pragma")
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	return p.advance()
+}
+
+// skipStatement skips a declaration up to its terminating semicolon.
+func (p *parser) skipStatement() error {
+	depth := 0
+	for {
+		switch {
+		case p.tok.kind == tokenEOF:
+			return p.errorf("unexpected end of file")
+		case p.isPunct("{"):
+			depth++
+		case p.isPunct("}"):
+			depth--
+		case p.isPunct(";") && depth == 0:
+			return p.advance()
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) parseQualifiers() (Qualifiers, error) {
+	if err := p.expectPunct("["); err != nil {
+		return nil, err
+	}
+
+	var qualifiers Qualifiers
+	for {
+		name, err := p.expectIdent("qualifier name")
+		if err != nil {
+			return nil, err
+		}
+		qualifier := Qualifier{Name: name, Value: true}
+
+		switch {
+		case p.isPunct("("):
+			if err = p.advance(); err != nil {
+				return nil, err
+			}
+			if qualifier.Value, err = p.parseValue(); err != nil {
+				return nil, err
+			}
+			if err = p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+		case p.isPunct("{"):
+			if qualifier.Value, err = p.parseArray(); err != nil {
+				return nil, err
+			}
+		}
+
+		// Flavors, such as ToSubclass or Amended, do not change the value
+		if p.isPunct(":") {
+			if err = p.advance(); err != nil {
+				return nil, err
+			}
+			for p.tok.kind == tokenIdent {
+				if err = p.advance(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		qualifiers = append(qualifiers, qualifier)
+
+		if p.isPunct("]") {
+			return qualifiers, p.advance()
+		}
+		if err = p.expectPunct(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseValue parses a constant or an array of constants.
+func (p *parser) parseValue() (interface{}, error) {
+	if p.isPunct("{") {
+		return p.parseArray()
+	}
+
+	switch p.tok.kind {
+	case tokenString:
+		// Adjacent string literals are concatenated
+		var b strings.Builder
+		for p.tok.kind == tokenString {
+			b.WriteString(p.tok.text)
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		return b.String(), nil
+	case tokenInteger, tokenReal, tokenChar:
+		value := p.tok.value
+		return value, p.advance()
+	case tokenIdent:
+		var value interface{}
+		switch strings.ToLower(p.tok.text) {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			return nil, p.errorf("expected constant, found %s", p.tok)
+		}
+		return value, p.advance()
+	default:
+		return nil, p.errorf("expected constant, found %s", p.tok)
+	}
+}
+
+func (p *parser) parseArray() ([]interface{}, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	values := []interface{}{}
+	for !p.isPunct("}") {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if !p.isPunct("}") {
+			if err = p.expectPunct(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, p.advance()
+}
+
+func (p *parser) parseClass(qualifiers Qualifiers) (*Class, error) {
+	class := &Class{Qualifiers: qualifiers, Pos: p.tok.pos}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if class.Name, err = p.expectIdent("class name"); err != nil {
+		return nil, err
+	}
+	if p.isPunct(":") {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		if class.Superclass, err = p.expectIdent("superclass name"); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+
+	for !p.isPunct("}") {
+		if p.tok.kind == tokenEOF {
+			return nil, p.errorf("unterminated class %s", class.Name)
+		}
+		if err = p.parseFeature(class); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.advance(); err != nil {
+		return nil, err
+	}
+	return class, p.expectPunct(";")
+}
+
+// parseType parses a data type, or the class of a reference followed by REF.
+func (p *parser) parseType() (Type, error) {
+	name, err := p.expectIdent("data type")
+	if err != nil {
+		return Type{}, err
+	}
+	if p.isKeyword("ref") {
+		return Type{Name: "ref", Ref: name}, p.advance()
+	}
+	if !intrinsicTypes[strings.ToLower(name)] {
+		// An embedded instance of a class
+		return Type{Name: "object"}, nil
+	}
+	return Type{Name: strings.ToLower(name)}, nil
+}
+
+// parseArraySuffix parses the brackets following the name of an array.
+func (p *parser) parseArraySuffix(t *Type) error {
+	if !p.isPunct("[") {
+		return nil
+	}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if p.tok.kind == tokenInteger {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	t.Array = true
+	return p.expectPunct("]")
+}
+
+func (p *parser) parseFeature(class *Class) error {
+	var qualifiers Qualifiers
+	if p.isPunct("[") {
+		var err error
+		if qualifiers, err = p.parseQualifiers(); err != nil {
+			return err
+		}
+	}
+
+	pos := p.tok.pos
+	dataType, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	name, err := p.expectIdent("property or method name")
+	if err != nil {
+		return err
+	}
+
+	if p.isPunct("(") {
+		method := &Method{Name: name, ReturnType: dataType, Qualifiers: qualifiers, Pos: pos}
+		if method.Parameters, err = p.parseParameters(); err != nil {
+			return err
+		}
+		if class.Method(name) != nil {
+			return &Error{Pos: pos, Msg: fmt.Sprintf("method %s.%s already declared", class.Name, name)}
+		}
+		class.Methods = append(class.Methods, method)
+		return p.expectPunct(";")
+	}
+
+	property := &Property{Name: name, Type: dataType, Qualifiers: qualifiers, Pos: pos}
+	if err = p.parseArraySuffix(&property.Type); err != nil {
+		return err
+	}
+	if p.isPunct("=") {
+		if err = p.advance(); err != nil {
+			return err
+		}
+		if property.Default, err = p.parseValue(); err != nil {
+			return err
+		}
+	}
+	if class.Property(name) != nil {
+		return &Error{Pos: pos, Msg: fmt.Sprintf("property %s.%s already declared", class.Name, name)}
+	}
+	class.Properties = append(class.Properties, property)
+	return p.expectPunct(";")
+}
+
+func (p *parser) parseParameters() ([]*Parameter, error) {
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+
+	var parameters []*Parameter
+	for !p.isPunct(")") {
+		parameter := &Parameter{}
+		var err error
+		if p.isPunct("[") {
+			if parameter.Qualifiers, err = p.parseQualifiers(); err != nil {
+				return nil, err
+			}
+		}
+		if parameter.Type, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		if parameter.Name, err = p.expectIdent("parameter name"); err != nil {
+			return nil, err
+		}
+		if err = p.parseArraySuffix(&parameter.Type); err != nil {
+			return nil, err
+		}
+		if p.isPunct("=") {
+			if err = p.advance(); err != nil {
+				return nil, err
+			}
+			if _, err = p.parseValue(); err != nil {
+				return nil, err
+			}
+		}
+		parameters = append(parameters, parameter)
+
+		if !p.isPunct(")") {
+			if err = p.expectPunct(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return parameters, p.advance()
+}
@@ -0,0 +1,173 @@
+package mof
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMOF = `
+#pragma namespace("\\\\.\\root\\virtualization\\v2")
+#pragma autorecover
+
+qualifier Description : string = null, scope(any), flavor(amended);
+
+/* A base class
+   spanning lines */
+[Abstract, Description("Base " "class"): ToSubclass Amended, Version("2.1.0")]
+class CIM_Base
+{
+  [Key, MaxLen(256)] string InstanceID;
+  string Caption = "default";
+  uint16 Dedicated[] = {2, 3};
+  [ValueMap{"0", "2", "3", "..", "0x8000..0xFFFF"},
+   Values{"Unknown", "Enabled", "Disabled", "DMTF Reserved", "Vendor Reserved"}]
+  uint16 EnabledState = 0x5;
+  sint32 Offset = -12;
+  real64 Ratio = 1.5e3;
+  boolean Started = TRUE;
+  char16 Letter = 'C';
+  uint8 Bits = 101B;
+};
+
+// Msvm_Derived overrides the state of CIM_Base
+class Msvm_Derived : CIM_Base
+{
+  [Description("Overridden")] uint16 EnabledState;
+  CIM_Base REF Parent;
+  string Notes[8];
+  datetime InstallDate;
+
+  [ValueMap{"0", "4096"}, Values{"Completed", "Job Started"}]
+  uint32 Define(
+    [In, EmbeddedInstance("CIM_Base")] string Settings,
+    [In] CIM_Base REF Reference,
+    [Out] CIM_Base REF Result,
+    [In, Out] uint16 Flags[],
+    string Implicit);
+};
+
+instance of CIM_Base
+{
+  InstanceID = "x";
+  Dedicated = {1};
+};
+`
+
+func TestParse(t *testing.T) {
+	classes, err := Parse("test.mof", []byte(testMOF))
+	require.NoError(t, err)
+	require.Len(t, classes, 2)
+
+	base := classes[0]
+	assert.Equal(t, "CIM_Base", base.Name)
+	assert.Empty(t, base.Superclass)
+	assert.True(t, base.Qualifiers.Has("Abstract"))
+	assert.Equal(t, "Base class", base.Qualifiers.String("Description"))
+	assert.Equal(t, "2.1.0", base.Qualifiers.String("version"))
+	assert.Equal(t, Position{File: "test.mof", Line: 10, Column: 1}, base.Pos)
+
+	id := base.Property("InstanceID")
+	require.NotNil(t, id)
+	assert.True(t, id.Qualifiers.Has("Key"))
+	assert.Equal(t, int64(256), id.Qualifiers[1].Value)
+	assert.Equal(t, Type{Name: "string"}, id.Type)
+
+	assert.Equal(t, "default", base.Property("Caption").Default)
+	assert.Equal(t, Type{Name: "uint16", Array: true}, base.Property("Dedicated").Type)
+	assert.Equal(t, []interface{}{int64(2), int64(3)}, base.Property("Dedicated").Default)
+	assert.Equal(t, int64(5), base.Property("EnabledState").Default)
+	assert.Equal(t, []string{"0", "2", "3", "..", "0x8000..0xFFFF"}, base.Property("EnabledState").Qualifiers.Strings("ValueMap"))
+	assert.Equal(t, int64(-12), base.Property("Offset").Default)
+	assert.Equal(t, 1500.0, base.Property("Ratio").Default)
+	assert.Equal(t, true, base.Property("Started").Default)
+	assert.Equal(t, int64('C'), base.Property("Letter").Default)
+	assert.Equal(t, int64(5), base.Property("Bits").Default)
+
+	derived := classes[1]
+	assert.Equal(t, "CIM_Base", derived.Superclass)
+	assert.Equal(t, Type{Name: "ref", Ref: "CIM_Base"}, derived.Property("Parent").Type)
+	assert.Equal(t, "CIM_Base ref", derived.Property("Parent").Type.String())
+	assert.True(t, derived.Property("Notes").Type.Array)
+	assert.Equal(t, "datetime", derived.Property("installdate").Type.Name)
+
+	define := derived.Method("Define")
+	require.NotNil(t, define)
+	assert.Equal(t, Type{Name: "uint32"}, define.ReturnType)
+	require.Len(t, define.Parameters, 5)
+	settings, reference, result, flags, implicit := define.Parameters[0], define.Parameters[1], define.Parameters[2], define.Parameters[3], define.Parameters[4]
+	assert.True(t, settings.In())
+	assert.False(t, settings.Out())
+	assert.Equal(t, "CIM_Base", settings.Qualifiers.String("EmbeddedInstance"))
+	assert.Equal(t, Type{Name: "ref", Ref: "CIM_Base"}, reference.Type)
+	assert.False(t, result.In())
+	assert.True(t, result.Out())
+	assert.True(t, flags.In())
+	assert.True(t, flags.Out())
+	assert.Equal(t, Type{Name: "uint16", Array: true}, flags.Type)
+	assert.True(t, implicit.In())
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{`class A { string Name }`, `test.mof:1:23: expected ";", found "}"`},
+		{"class A {\n  string Name;\n  uint16 Name;\n};", `test.mof:3:3: property A.Name already declared`},
+		{`class A { string Name = "unterminated; };`, `test.mof:1:25: unterminated string`},
+		{`class A { [Key string Name; };`, `test.mof:1:16: expected ",", found "string"`},
+		{`/* class A {};`, `test.mof:1:1: unterminated comment`},
+		{`class A { uint32 Run(string a };`, `test.mof:1:31: expected ",", found "}"`},
+		{`property A;`, `test.mof:1:1: expected class declaration, found "property"`},
+		{`class A { string Name; }`, `test.mof:1:25: expected ";", found end of file`},
+		{`class A { string Name = 1x2; };`, `test.mof:1:25: invalid number 1x2`},
+	}
+	for _, test := range tests {
+		_, err := Parse("test.mof", []byte(test.src))
+		var mofErr *Error
+		if assert.ErrorAs(t, err, &mofErr, test.src) {
+			assert.Equal(t, test.err, err.Error(), test.src)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	classes, err := Parse("test.mof", []byte(testMOF))
+	require.NoError(t, err)
+	schema, err := NewSchema(classes...)
+	require.NoError(t, err)
+
+	derived := schema.Class("msvm_derived")
+	require.NotNil(t, derived)
+	assert.Same(t, classes[0], schema.Class(derived.Superclass))
+	assert.Nil(t, schema.Class("CIM_Missing"))
+
+	var names []string
+	for _, property := range schema.Properties(derived) {
+		names = append(names, property.Name)
+	}
+	assert.Equal(t, []string{"InstanceID", "Caption", "Dedicated", "EnabledState", "Offset", "Ratio", "Started", "Letter", "Bits", "Parent", "Notes", "InstallDate"}, names)
+
+	state := schema.Properties(derived)[3]
+	assert.Equal(t, "Overridden", state.Qualifiers.String("Description"))
+	assert.Len(t, state.Qualifiers.Strings("Values"), 5)
+	assert.Equal(t, int64(5), state.Default)
+	assert.Equal(t, []string{"InstanceID"}, schema.Keys(derived))
+	assert.Len(t, schema.Methods(derived), 1)
+
+	_, err = NewSchema(&Class{Name: "A"}, &Class{Name: "a"})
+	assert.EqualError(t, err, ":0:0: class a already declared at :0:0")
+	_, err = NewSchema(&Class{Name: "A", Superclass: "B"}, &Class{Name: "B", Superclass: "A"})
+	assert.EqualError(t, err, ":0:0: class A inherits from itself")
+}
+
+func TestEnum(t *testing.T) {
+	classes, err := Parse("test.mof", []byte(testMOF))
+	require.NoError(t, err)
+
+	assert.Equal(t, []EnumValue{{"Unknown", 0}, {"Enabled", 2}, {"Disabled", 3}}, Enum(classes[0].Property("EnabledState").Qualifiers))
+	assert.Equal(t, []EnumValue{{"Off", 0}, {"On", 1}}, Enum(Qualifiers{{Name: "Values", Value: []interface{}{"Off", "On"}}}))
+	assert.Nil(t, Enum(Qualifiers{{Name: "ValueMap", Value: []interface{}{"1"}}}))
+}
@@ -0,0 +1,498 @@
+package wmiext
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+// A navigation path walks the associations of an object graph, e.g.
+//
+//	Msvm_ComputerSystem[ElementName='vm']/Msvm_SettingsDefineState/Msvm_VirtualSystemSettingData/Msvm_ResourceAllocationSettingData[ResourceType=6]
+//
+// The first segment is the class of the start objects. Every following segment is either an
+// association class, which restricts the next hop to that association, or the result class of a
+// hop. Segments may be filtered by comma separated Property=value or Property!=value conditions,
+// where values are integers, booleans or quoted strings. Association segments are told apart from
+// result classes by the reference properties of their class definition when compiled.
+
+// NavigationSegment is a class of a navigation path along with its filter.
+type NavigationSegment struct {
+	Class   string
+	Filters []NavigationFilter
+}
+
+// NavigationFilter is a condition of a navigation segment on a property of the objects.
+type NavigationFilter struct {
+	Property string
+	// Negated is set for Property!=value
+	Negated bool
+	// Value is a string, int64, uint64 or bool.
+	Value interface{}
+}
+
+// Condition returns the filter as a WQL condition.
+func (f NavigationFilter) Condition() Condition {
+	if f.Negated {
+		return NotEqual(f.Property, f.Value)
+	}
+	return Equal(f.Property, f.Value)
+}
+
+// matches reports whether the filter accepts the value of the property. Arrays match when any
+// of their elements does, and strings compare case-insensitively, like WQL.
+func (f NavigationFilter) matches(value interface{}) bool {
+	return f.equals(value) != f.Negated
+}
+
+func (f NavigationFilter) equals(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < rv.Len(); i++ {
+			if f.equals(rv.Index(i).Interface()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch expected := f.Value.(type) {
+	case string:
+		return strings.EqualFold(fmt.Sprint(value), expected)
+	case bool:
+		actual, ok := value.(bool)
+		return ok && actual == expected
+	case int64, uint64:
+		// WMI returns 64 bit integers as strings, so both sides are compared as decimal text
+		return fmt.Sprint(value) == fmt.Sprint(expected)
+	}
+	return false
+}
+
+// ParseNavigation parses the syntax of a navigation path, without resolving its classes.
+func ParseNavigation(path string) ([]NavigationSegment, error) {
+	p := &navigationParser{path: path}
+	var segments []NavigationSegment
+	for {
+		segment, err := p.parseSegment()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+
+		if p.off == len(p.path) {
+			return segments, nil
+		}
+		if p.path[p.off] != '/' {
+			return nil, p.errorf("expected '/'")
+		}
+		p.off++
+	}
+}
+
+type navigationParser struct {
+	path string
+	off  int
+}
+
+func (p *navigationParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("navigation path %q at offset %d: %s", p.path, p.off, fmt.Sprintf(format, args...))
+}
+
+func (p *navigationParser) skipSpace() {
+	for p.off < len(p.path) && p.path[p.off] == ' ' {
+		p.off++
+	}
+}
+
+func (p *navigationParser) parseName(what string) (string, error) {
+	p.skipSpace()
+	start := p.off
+	for p.off < len(p.path) {
+		c := p.path[p.off]
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !(p.off > start && '0' <= c && c <= '9') {
+			break
+		}
+		p.off++
+	}
+	if start == p.off {
+		return "", p.errorf("expected %s", what)
+	}
+	name := p.path[start:p.off]
+	p.skipSpace()
+	return name, nil
+}
+
+func (p *navigationParser) parseSegment() (NavigationSegment, error) {
+	var segment NavigationSegment
+	var err error
+	if segment.Class, err = p.parseName("class name"); err != nil {
+		return segment, err
+	}
+	if p.off == len(p.path) || p.path[p.off] != '[' {
+		return segment, nil
+	}
+
+	p.off++
+	for {
+		var filter NavigationFilter
+		if filter.Property, err = p.parseName("property name"); err != nil {
+			return segment, err
+		}
+		switch {
+		case strings.HasPrefix(p.path[p.off:], "!="):
+			filter.Negated = true
+			p.off += 2
+		case strings.HasPrefix(p.path[p.off:], "<>"):
+			filter.Negated = true
+			p.off += 2
+		case strings.HasPrefix(p.path[p.off:], "="):
+			p.off++
+		default:
+			return segment, p.errorf("expected '=' or '!='")
+		}
+		if filter.Value, err = p.parseValue(); err != nil {
+			return segment, err
+		}
+		segment.Filters = append(segment.Filters, filter)
+
+		p.skipSpace()
+		if p.off == len(p.path) {
+			return segment, p.errorf("unterminated filter")
+		}
+		switch p.path[p.off] {
+		case ']':
+			p.off++
+			p.skipSpace()
+			return segment, nil
+		case ',':
+			p.off++
+		default:
+			return segment, p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+func (p *navigationParser) parseValue() (interface{}, error) {
+	p.skipSpace()
+	if p.off == len(p.path) {
+		return nil, p.errorf("expected value")
+	}
+
+	if quote := p.path[p.off]; quote == '\'' || quote == '"' {
+		var sb strings.Builder
+		for p.off++; p.off < len(p.path); p.off++ {
+			c := p.path[p.off]
+			if c == '\\' && p.off+1 < len(p.path) {
+				p.off++
+				sb.WriteByte(p.path[p.off])
+				continue
+			}
+			if c == quote {
+				p.off++
+				return sb.String(), nil
+			}
+			sb.WriteByte(c)
+		}
+		return nil, p.errorf("unterminated string")
+	}
+
+	start := p.off
+	for p.off < len(p.path) && !strings.ContainsRune(",] ", rune(p.path[p.off])) {
+		p.off++
+	}
+	text := p.path[start:p.off]
+	switch strings.ToLower(text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if value, err := strconv.ParseInt(text, 0, 64); err == nil {
+		return value, nil
+	}
+	if value, err := strconv.ParseUint(text, 0, 64); err == nil {
+		return value, nil
+	}
+	p.off = start
+	return nil, p.errorf("invalid value %q", text)
+}
+
+// Navigation is a navigation path compiled to a chain of ASSOCIATORS OF queries. It holds no
+// reference to the service it was compiled with, and can be reused.
+type Navigation struct {
+	path  string
+	start NavigationSegment
+	hops  []navigationHop
+}
+
+type navigationHop struct {
+	assocClass  string
+	resultClass string
+	filters     []NavigationFilter
+}
+
+// CompileNavigation parses a navigation path and resolves which of its segments are association
+// classes against the schema of the service.
+func (s *Service) CompileNavigation(path string) (*Navigation, error) {
+	segments, err := ParseNavigation(path)
+	if err != nil {
+		return nil, err
+	}
+
+	associations := make([]bool, len(segments))
+	for i, segment := range segments {
+		if associations[i], err = s.isAssociationClass(segment.Class); err != nil {
+			return nil, errors.Wrapf(err, "navigation path %q", path)
+		}
+	}
+
+	n := &Navigation{path: path, start: segments[0]}
+	if associations[0] {
+		return nil, errors.Errorf("navigation path %q starts with association %s", path, segments[0].Class)
+	}
+	var hop navigationHop
+	for i, segment := range segments[1:] {
+		if associations[i+1] {
+			if hop.assocClass != "" {
+				return nil, errors.Errorf("navigation path %q follows association %s with association %s", path, hop.assocClass, segment.Class)
+			}
+			if len(segment.Filters) > 0 {
+				return nil, errors.Errorf("navigation path %q filters association %s", path, segment.Class)
+			}
+			hop.assocClass = segment.Class
+			continue
+		}
+		hop.resultClass = segment.Class
+		hop.filters = segment.Filters
+		n.hops = append(n.hops, hop)
+		hop = navigationHop{}
+	}
+	if hop.assocClass != "" {
+		return nil, errors.Errorf("navigation path %q ends with association %s", path, hop.assocClass)
+	}
+	return n, nil
+}
+
+// isAssociationClass reports whether the class definition holds reference properties. Answers are
+// cached, as the schema does not change for the lifetime of the service, and shared with the
+// services scoped from it.
+func (s *Service) isAssociationClass(className string) (bool, error) {
+	cache := &s.associationClasses
+	if s.root != nil {
+		cache = &s.root.associationClasses
+	}
+	key := strings.ToLower(className)
+	if association, ok := cache.Load(key); ok {
+		return association.(bool), nil
+	}
+
+	class, err := s.GetObject(className)
+	if err != nil {
+		return false, err
+	}
+	defer class.Close()
+
+	properties, err := class.Object().Properties()
+	if err != nil {
+		return false, err
+	}
+	association := false
+	for _, property := range properties {
+		if property.CimType == CIM_REFERENCE {
+			association = true
+			break
+		}
+	}
+	cache.Store(key, association)
+	return association, nil
+}
+
+// String returns the navigation path.
+func (n *Navigation) String() string {
+	return n.path
+}
+
+// Hops returns the number of associations followed by the navigation.
+func (n *Navigation) Hops() int {
+	return len(n.hops)
+}
+
+// Start returns the query selecting the start objects when none are passed to Run.
+func (n *Navigation) Start() *SelectQuery {
+	query := Select(n.start.Class)
+	if len(n.start.Filters) > 0 {
+		conditions := make([]Condition, len(n.start.Filters))
+		for i, filter := range n.start.Filters {
+			conditions[i] = filter.Condition()
+		}
+		query.Where(And(conditions...))
+	}
+	return query
+}
+
+// Query returns the query of a hop from the object at objectPath. Hops without filters, except
+// the last, only fetch the keys, which are all that is needed to follow them.
+func (n *Navigation) Query(hop int, objectPath string) *AssociationQuery {
+	h := n.hops[hop]
+	query := AssociatorsOf(objectPath).ResultClass(h.resultClass)
+	if h.assocClass != "" {
+		query.AssocClass(h.assocClass)
+	}
+	if hop < len(n.hops)-1 && len(h.filters) == 0 {
+		query.KeysOnly()
+	}
+	return query
+}
+
+// Run follows the navigation from the objects at objectPaths, or from the instances selected by
+// Start when none are passed. The first segment only filters the start objects passed in, their
+// class is not checked. Every hop is issued once per distinct object reached by the previous one,
+// and the results are distinct.
+func (n *Navigation) Run(s *Service, objectPaths ...string) ([]*Instance, error) {
+	var current []*Instance
+	var err error
+	if len(objectPaths) == 0 {
+		if current, err = s.FindInstances(n.Start().String()); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, objectPath := range objectPaths {
+			instance, err := s.GetObject(objectPath)
+			if err != nil {
+				CloseInstances(current)
+				return nil, err
+			}
+			current = append(current, instance)
+		}
+		if current, err = filterInstances(current, n.start.Filters); err != nil {
+			return nil, err
+		}
+	}
+	current = distinctInstances(current)
+
+	for hop := range n.hops {
+		var next []*Instance
+		for _, instance := range current {
+			path, err := instance.Path()
+			if err == nil {
+				var related []*Instance
+				if related, err = s.FindInstances(n.Query(hop, path).String()); err == nil {
+					next = append(next, related...)
+				}
+			}
+			if err != nil {
+				CloseInstances(current)
+				CloseInstances(next)
+				return nil, errors.Wrapf(err, "navigation path %q", n.path)
+			}
+		}
+		CloseInstances(current)
+
+		if next, err = filterInstances(next, n.hops[hop].filters); err != nil {
+			return nil, err
+		}
+		current = distinctInstances(next)
+	}
+	return current, nil
+}
+
+// filterInstances keeps the instances matching all filters, closing the others.
+func filterInstances(instances []*Instance, filters []NavigationFilter) ([]*Instance, error) {
+	if len(filters) == 0 {
+		return instances, nil
+	}
+
+	var kept []*Instance
+	for i, instance := range instances {
+		match := true
+		for _, filter := range filters {
+			value, _, _, err := instance.GetAsAny(filter.Property)
+			if err != nil {
+				CloseInstances(kept)
+				CloseInstances(instances[i:])
+				return nil, errors.Wrapf(err, "property %s", filter.Property)
+			}
+			if !filter.matches(value) {
+				match = false
+				break
+			}
+		}
+		if match {
+			kept = append(kept, instance)
+		} else {
+			instance.Close()
+		}
+	}
+	return kept, nil
+}
+
+// distinctInstances drops the instances whose path was seen before, closing them.
+func distinctInstances(instances []*Instance) []*Instance {
+	seen := make(map[string]bool, len(instances))
+	distinct := instances[:0]
+	for _, instance := range instances {
+		path, err := instance.Path()
+		if err == nil {
+			key := objectpath.Normalize(path)
+			if seen[key] {
+				instance.Close()
+				continue
+			}
+			seen[key] = true
+		}
+		distinct = append(distinct, instance)
+	}
+	return distinct
+}
+
+// NavigateInstances compiles and runs a navigation path, see Navigation.Run.
+func (s *Service) NavigateInstances(path string, objectPaths ...string) ([]*Instance, error) {
+	n, err := s.CompileNavigation(path)
+	if err != nil {
+		return nil, err
+	}
+	return n.Run(s, objectPaths...)
+}
+
+// Navigate runs a navigation path and decodes every resulting instance into a new T, following
+// the conventions of Instance.GetAll.
+func Navigate[T any](s *Service, path string, objectPaths ...string) ([]*T, error) {
+	instances, err := s.NavigateInstances(path, objectPaths...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*T, 0, len(instances))
+	for _, instance := range instances {
+		result := new(T)
+		err = instance.GetAll(result)
+		closeUnlessRetained(instance, result)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// NavigateFirst runs a navigation path and decodes the first resulting instance into a new T. It
+// returns NotFound when the navigation reaches no instance.
+func NavigateFirst[T any](s *Service, path string, objectPaths ...string) (*T, error) {
+	results, err := Navigate[T](s, path, objectPaths...)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, errors.Wrapf(NotFound, "navigation path %q", path)
+	}
+	return results[0], nil
+}
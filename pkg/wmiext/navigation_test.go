@@ -0,0 +1,159 @@
+package wmiext
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newNavigationRepository builds two virtual machines, each defined by a setting with a SCSI
+// controller and a disk drive.
+func newNavigationRepository(t *testing.T) *MemoryRepository {
+	repo := newTestRepository(t)
+	repo.DefineClass(MemoryClass{
+		Name: "Msvm_SettingsDefineState",
+		Properties: map[string]CIMTYPE_ENUMERATION{
+			"ManagedElement": CIM_REFERENCE,
+			"SettingData":    CIM_REFERENCE,
+		},
+	})
+	repo.DefineClass(MemoryClass{
+		Name: "Msvm_VirtualSystemSettingDataComponent",
+		Properties: map[string]CIMTYPE_ENUMERATION{
+			"GroupComponent": CIM_REFERENCE,
+			"PartComponent":  CIM_REFERENCE,
+		},
+	})
+	repo.DefineClass(MemoryClass{Name: "Msvm_ResourceAllocationSettingData", Keys: []string{"InstanceID"}})
+
+	for _, name := range []string{"A0B1", "C2D3"} {
+		settingPath, err := repo.AddInstance("Msvm_VirtualSystemSettingData", map[string]interface{}{
+			"InstanceID": "Microsoft:" + name,
+		})
+		require.NoError(t, err)
+		_, err = repo.AddInstance("Msvm_SettingsDefineState", map[string]interface{}{
+			"ManagedElement": Reference(`Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="` + name + `"`),
+			"SettingData":    Reference(settingPath),
+		})
+		require.NoError(t, err)
+
+		for i, resourceType := range []uint16{6, 17} {
+			resourcePath, err := repo.AddInstance("Msvm_ResourceAllocationSettingData", map[string]interface{}{
+				"InstanceID":   "Microsoft:" + name + "\\" + string(rune('0'+i)),
+				"ResourceType": resourceType,
+				"HostResource": []string{`\\?\` + name},
+			})
+			require.NoError(t, err)
+			_, err = repo.AddInstance("Msvm_VirtualSystemSettingDataComponent", map[string]interface{}{
+				"GroupComponent": Reference(settingPath),
+				"PartComponent":  Reference(resourcePath),
+			})
+			require.NoError(t, err)
+		}
+	}
+	return repo
+}
+
+type testResourceAllocationSettingData struct {
+	InstanceID   string
+	ResourceType uint16
+}
+
+func TestParseNavigation(t *testing.T) {
+	segments, err := ParseNavigation(`Msvm_ComputerSystem[ElementName = 'vm\'s', EnabledState!=3]/Msvm_SettingsDefineState/Msvm_ResourceAllocationSettingData[ResourceType=6,Bootable=true]`)
+	require.NoError(t, err)
+	assert.Equal(t, []NavigationSegment{
+		{Class: "Msvm_ComputerSystem", Filters: []NavigationFilter{
+			{Property: "ElementName", Value: "vm's"},
+			{Property: "EnabledState", Negated: true, Value: int64(3)},
+		}},
+		{Class: "Msvm_SettingsDefineState"},
+		{Class: "Msvm_ResourceAllocationSettingData", Filters: []NavigationFilter{
+			{Property: "ResourceType", Value: int64(6)},
+			{Property: "Bootable", Value: true},
+		}},
+	}, segments)
+
+	for _, path := range []string{
+		"",
+		"Msvm_ComputerSystem/",
+		"Msvm_ComputerSystem[Name]",
+		"Msvm_ComputerSystem[Name='x'",
+		"Msvm_ComputerSystem[Name=x]",
+		"Msvm_ComputerSystem Msvm_SettingsDefineState",
+	} {
+		_, err = ParseNavigation(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestCompileNavigation(t *testing.T) {
+	service := newNavigationRepository(t).Service()
+	defer service.Close()
+
+	n, err := service.CompileNavigation("Msvm_ComputerSystem[ElementName='vm-1']/Msvm_SettingsDefineState/Msvm_VirtualSystemSettingData/Msvm_ResourceAllocationSettingData[ResourceType=6]")
+	require.NoError(t, err)
+	require.Equal(t, 2, n.Hops())
+	assert.Equal(t, `SELECT * FROM Msvm_ComputerSystem WHERE ElementName = 'vm-1'`, n.Start().String())
+	assert.Equal(t, `ASSOCIATORS OF {Msvm_ComputerSystem.Name="A0B1"} WHERE AssocClass = Msvm_SettingsDefineState ResultClass = Msvm_VirtualSystemSettingData KeysOnly`,
+		n.Query(0, `Msvm_ComputerSystem.Name="A0B1"`).String())
+	assert.Equal(t, `ASSOCIATORS OF {Msvm_VirtualSystemSettingData.InstanceID="x"} WHERE ResultClass = Msvm_ResourceAllocationSettingData`,
+		n.Query(1, `Msvm_VirtualSystemSettingData.InstanceID="x"`).String())
+
+	for _, path := range []string{
+		"Msvm_SettingsDefineState/Msvm_VirtualSystemSettingData",
+		"Msvm_ComputerSystem/Msvm_SettingsDefineState",
+		"Msvm_ComputerSystem/Msvm_SettingsDefineState/Msvm_VirtualSystemSettingDataComponent/Msvm_ResourceAllocationSettingData",
+		"Msvm_ComputerSystem/Msvm_SettingsDefineState[SettingData='x']/Msvm_VirtualSystemSettingData",
+		"Msvm_ComputerSystem/Msvm_Missing",
+	} {
+		_, err = service.CompileNavigation(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestNavigate(t *testing.T) {
+	service := newNavigationRepository(t).Service()
+	defer service.Close()
+
+	const controllers = "Msvm_ComputerSystem/Msvm_SettingsDefineState/Msvm_VirtualSystemSettingData/Msvm_ResourceAllocationSettingData[ResourceType=6]"
+
+	// Without start objects, every instance of the first class is navigated
+	results, err := Navigate[testResourceAllocationSettingData](service, controllers)
+	require.NoError(t, err)
+	var ids []string
+	for _, result := range results {
+		assert.Equal(t, uint16(6), result.ResourceType)
+		ids = append(ids, result.InstanceID)
+	}
+	assert.ElementsMatch(t, []string{`Microsoft:A0B1\0`, `Microsoft:C2D3\0`}, ids)
+
+	results, err = Navigate[testResourceAllocationSettingData](service, "Msvm_ComputerSystem[ElementName='VM-1']/Msvm_VirtualSystemSettingData/Msvm_ResourceAllocationSettingData[ResourceType!=6]")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, `Microsoft:A0B1\1`, results[0].InstanceID)
+
+	// Start objects are deduplicated and filtered by the first segment
+	const system = `Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="C2D3"`
+	results, err = Navigate[testResourceAllocationSettingData](service, controllers, system, system)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, `Microsoft:C2D3\0`, results[0].InstanceID)
+
+	results, err = Navigate[testResourceAllocationSettingData](service, "Msvm_ComputerSystem[EnabledState=2]/Msvm_VirtualSystemSettingData", system)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Array properties match when any element does
+	result, err := NavigateFirst[testResourceAllocationSettingData](service, `Msvm_VirtualSystemSettingData/Msvm_ResourceAllocationSettingData[HostResource='\\\\?\\a0b1', ResourceType=17]`)
+	require.NoError(t, err)
+	assert.Equal(t, `Microsoft:A0B1\1`, result.InstanceID)
+
+	_, err = NavigateFirst[testResourceAllocationSettingData](service, "Msvm_ComputerSystem/Msvm_ResourceAllocationSettingData")
+	assert.True(t, errors.Is(err, NotFound))
+
+	_, err = service.NavigateInstances(controllers, `Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="missing"`)
+	assert.Error(t, err)
+}
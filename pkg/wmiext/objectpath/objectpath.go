@@ -0,0 +1,446 @@
+// Package objectpath parses and formats WMI object paths, such as the __PATH of an instance or the
+// references held by HostResource and association properties:
+//
+//	\\HOST\root\virtualization\v2:Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="GUID"
+//
+// Paths can be compared without a round-trip to the WMI service, ignoring case and key order.
+package objectpath
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidPath is returned, wrapped, when a string is not a valid object path.
+var ErrInvalidPath = errors.New("invalid object path")
+
+// Path is a parsed object path. Names and values keep their original case.
+type Path struct {
+	// Server is the host name of the \\server\namespace: prefix, empty for a relative path.
+	Server string
+	// Namespace uses backslash separators, e.g. root\virtualization\v2, empty for a relative path.
+	Namespace string
+	ClassName string
+	// Singleton is set for the Class=@ form of singleton instances.
+	Singleton bool
+	Keys      []KeyBinding
+}
+
+// KeyBinding is a key property of an instance path. Value is a string, int64, uint64 or bool. The name
+// is empty for the single unnamed key form, Class="value".
+type KeyBinding struct {
+	Name  string
+	Value interface{}
+}
+
+// Reference returns the parsed value of a key that refers to another object, as the keys of
+// association instances do.
+func (k KeyBinding) Reference() (*Path, bool) {
+	value, ok := k.Value.(string)
+	if !ok || !looksLikePath(value) {
+		return nil, false
+	}
+	path, err := Parse(value)
+	if err != nil {
+		return nil, false
+	}
+	return path, true
+}
+
+// New returns a relative path to the instance of a class identified by keys, given as name and value
+// pairs.
+func New(className string, keys ...interface{}) (*Path, error) {
+	if len(keys)%2 != 0 {
+		return nil, errors.Errorf("odd number of key arguments for class %s", className)
+	}
+
+	path := &Path{ClassName: className}
+	for i := 0; i < len(keys); i += 2 {
+		name, ok := keys[i].(string)
+		if !ok {
+			return nil, errors.Errorf("key name %v of class %s is not a string", keys[i], className)
+		}
+		value, err := keyValue(keys[i+1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "key %s of class %s", name, className)
+		}
+		path.Keys = append(path.Keys, KeyBinding{Name: name, Value: value})
+	}
+	return path, nil
+}
+
+// FromStruct builds a relative path to an instance of a class, taking the values of the key
+// properties from the fields of the same name in src, a struct or a pointer to one.
+func FromStruct(className string, src interface{}, keys ...string) (*Path, error) {
+	val := reflect.ValueOf(src)
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return nil, errors.Errorf("nil %T for class %s", src, className)
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, errors.Errorf("%T is not a struct", src)
+	}
+	if len(keys) == 0 {
+		return nil, errors.Errorf("no key properties for class %s", className)
+	}
+
+	path := &Path{ClassName: className}
+	for _, key := range keys {
+		field := val.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, key) })
+		if !field.IsValid() {
+			return nil, errors.Errorf("%T has no field for key %s of class %s", src, key, className)
+		}
+		value, err := keyValue(field.Interface())
+		if err != nil {
+			return nil, errors.Wrapf(err, "key %s of class %s", key, className)
+		}
+		path.Keys = append(path.Keys, KeyBinding{Name: key, Value: value})
+	}
+	return path, nil
+}
+
+// keyValue converts a Golang value to one of the key value types.
+func keyValue(value interface{}) (interface{}, error) {
+	if path, ok := value.(*Path); ok {
+		return path.String(), nil
+	}
+
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.String:
+		return val.String(), nil
+	case reflect.Bool:
+		return val.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return val.Uint(), nil
+	default:
+		return nil, errors.Errorf("unsupported key value type %T", value)
+	}
+}
+
+// Parse parses an absolute or relative object path.
+func Parse(path string) (*Path, error) {
+	rel := strings.TrimSpace(path)
+	parsed := &Path{}
+
+	// Strip the \\server\namespace: prefix, the namespace can not contain any of the key delimiters
+	if colon := strings.IndexByte(rel, ':'); colon >= 0 {
+		if delim := strings.IndexAny(rel, `."=`); delim < 0 || colon < delim || strings.HasPrefix(rel, `\\`) {
+			prefix := strings.ReplaceAll(rel[:colon], "/", `\`)
+			if strings.HasPrefix(prefix, `\\`) {
+				server, namespace, _ := strings.Cut(prefix[2:], `\`)
+				parsed.Server = server
+				parsed.Namespace = namespace
+			} else {
+				parsed.Namespace = prefix
+			}
+			rel = rel[colon+1:]
+		}
+	}
+
+	if rel == "" {
+		return nil, errors.Wrapf(ErrInvalidPath, "path %q", path)
+	}
+
+	if strings.HasSuffix(rel, "=@") {
+		parsed.ClassName = rel[:len(rel)-2]
+		parsed.Singleton = true
+		return parsed, nil
+	}
+
+	delim := strings.IndexAny(rel, ".=")
+	if delim < 0 {
+		parsed.ClassName = rel
+		return parsed, nil
+	}
+
+	parsed.ClassName = rel[:delim]
+	rest := rel[delim:]
+	if rest[0] == '=' {
+		// Single unnamed key
+		value, remaining, err := parseKeyValue(rest[1:])
+		if err != nil || remaining != "" {
+			return nil, errors.Wrapf(ErrInvalidPath, "path %q", path)
+		}
+		parsed.Keys = append(parsed.Keys, KeyBinding{Value: value})
+		return parsed, nil
+	}
+
+	rest = rest[1:]
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, errors.Wrapf(ErrInvalidPath, "path %q", path)
+		}
+
+		value, remaining, err := parseKeyValue(rest[eq+1:])
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidPath, "path %q: %s", path, err)
+		}
+		parsed.Keys = append(parsed.Keys, KeyBinding{Name: strings.TrimSpace(rest[:eq]), Value: value})
+
+		if remaining != "" {
+			if remaining[0] != ',' {
+				return nil, errors.Wrapf(ErrInvalidPath, "path %q", path)
+			}
+			remaining = remaining[1:]
+		}
+		rest = remaining
+	}
+	return parsed, nil
+}
+
+// parseKeyValue parses a quoted, numeric or boolean key value, and returns it along with the remaining input.
+func parseKeyValue(input string) (interface{}, string, error) {
+	if input == "" {
+		return nil, "", errors.New("missing key value")
+	}
+
+	if input[0] != '"' {
+		end := strings.IndexByte(input, ',')
+		if end < 0 {
+			end = len(input)
+		}
+		text := strings.TrimSpace(input[:end])
+		if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return value, input[end:], nil
+		}
+		if value, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return value, input[end:], nil
+		}
+		switch {
+		case strings.EqualFold(text, "TRUE"):
+			return true, input[end:], nil
+		case strings.EqualFold(text, "FALSE"):
+			return false, input[end:], nil
+		}
+		return nil, "", errors.Errorf("invalid key value %q", text)
+	}
+
+	var sb strings.Builder
+	for i := 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			if i+1 < len(input) {
+				i++
+			}
+			sb.WriteByte(input[i])
+		case '"':
+			return sb.String(), input[i+1:], nil
+		default:
+			sb.WriteByte(input[i])
+		}
+	}
+	return nil, "", errors.New("unterminated key value")
+}
+
+// IsClass returns whether the path designates a class rather than an instance.
+func (p *Path) IsClass() bool {
+	return !p.Singleton && len(p.Keys) == 0
+}
+
+// Get returns the value of a key, matching its name without regard to case.
+func (p *Path) Get(name string) (interface{}, bool) {
+	for _, key := range p.Keys {
+		if strings.EqualFold(key.Name, name) {
+			return key.Value, true
+		}
+	}
+	return nil, false
+}
+
+// RelativePath returns the canonical path without the server and namespace prefix, with the keys
+// sorted by name as WMI does in __RELPATH.
+func (p *Path) RelativePath() string {
+	if p.Singleton {
+		return p.ClassName + "=@"
+	}
+	if len(p.Keys) == 0 {
+		return p.ClassName
+	}
+	if len(p.Keys) == 1 && p.Keys[0].Name == "" {
+		return p.ClassName + "=" + FormatValue(p.Keys[0].Value)
+	}
+
+	keys := p.sortedKeys()
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.Name + "=" + FormatValue(key.Value)
+	}
+	return p.ClassName + "." + strings.Join(parts, ",")
+}
+
+// String returns the canonical form of the path, with the \\server\namespace: prefix when the path has
+// one.
+func (p *Path) String() string {
+	switch {
+	case p.Server != "":
+		return `\\` + p.Server + `\` + p.Namespace + ":" + p.RelativePath()
+	case p.Namespace != "":
+		return p.Namespace + ":" + p.RelativePath()
+	default:
+		return p.RelativePath()
+	}
+}
+
+// Normalized returns a case folded form of the relative path, identical for paths designating the
+// same object in a namespace. It is suitable as a map key.
+func (p *Path) Normalized() string {
+	if p.Singleton {
+		return strings.ToLower(p.ClassName) + "=@"
+	}
+
+	keys := p.sortedKeys()
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = strings.ToLower(key.Name) + "=" + normalizedValue(key.Value)
+	}
+	return strings.ToLower(p.ClassName) + "." + strings.Join(parts, ",")
+}
+
+// Equal returns whether both paths designate the same object, ignoring case and key order. The server
+// and namespace are only compared when both paths have them, a local server matching any server.
+func (p *Path) Equal(other *Path) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	if !strings.EqualFold(p.ClassName, other.ClassName) || p.Singleton != other.Singleton || len(p.Keys) != len(other.Keys) {
+		return false
+	}
+	if p.Server != "" && other.Server != "" && !isLocalServer(p.Server) && !isLocalServer(other.Server) &&
+		!strings.EqualFold(p.Server, other.Server) {
+		return false
+	}
+	if p.Namespace != "" && other.Namespace != "" && !strings.EqualFold(p.Namespace, other.Namespace) {
+		return false
+	}
+
+	for _, key := range p.Keys {
+		value, ok := other.Get(key.Name)
+		if !ok && len(p.Keys) == 1 && (key.Name == "" || other.Keys[0].Name == "") {
+			value, ok = other.Keys[0].Value, true
+		}
+		if !ok || normalizedValue(key.Value) != normalizedValue(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Path) sortedKeys() []KeyBinding {
+	keys := append([]KeyBinding(nil), p.Keys...)
+	sort.SliceStable(keys, func(i, j int) bool { return strings.ToLower(keys[i].Name) < strings.ToLower(keys[j].Name) })
+	return keys
+}
+
+// Equal parses two paths and returns whether they designate the same object. Paths that can not be
+// parsed are compared as strings, without regard to case.
+func Equal(a string, b string) bool {
+	pa, err := Parse(a)
+	if err != nil {
+		return strings.EqualFold(a, b)
+	}
+	pb, err := Parse(b)
+	if err != nil {
+		return false
+	}
+	return pa.Equal(pb)
+}
+
+// Normalize returns the Normalized form of a path, or the path in lower case when it can not be parsed.
+func Normalize(path string) string {
+	parsed, err := Parse(path)
+	if err != nil {
+		return strings.ToLower(path)
+	}
+	return parsed.Normalized()
+}
+
+// FormatValue formats a key value as it appears in an object path, quoting and escaping strings.
+func FormatValue(value interface{}) string {
+	switch cast := value.(type) {
+	case nil:
+		return `""`
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(cast) + `"`
+	case bool:
+		if cast {
+			return "TRUE"
+		}
+		return "FALSE"
+	case *Path:
+		return FormatValue(cast.String())
+	}
+
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(val.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(val.Uint(), 10)
+	case reflect.String:
+		return FormatValue(val.String())
+	case reflect.Bool:
+		return FormatValue(val.Bool())
+	default:
+		return FormatValue(fmt.Sprintf("%v", value))
+	}
+}
+
+// normalizedValue returns the case folded text of a key value, nested references being normalized
+// as paths. Numbers compare equal to their quoted form, as WMI accepts both.
+func normalizedValue(value interface{}) string {
+	switch cast := value.(type) {
+	case string:
+		if looksLikePath(cast) {
+			return Normalize(cast)
+		}
+		return strings.ToLower(cast)
+	case bool:
+		return strings.ToLower(FormatValue(cast))
+	case nil:
+		return ""
+	default:
+		return FormatValue(cast)
+	}
+}
+
+// looksLikePath returns whether a string key value is an object path, a class name followed by its
+// keys, with an optional namespace prefix.
+func looksLikePath(value string) bool {
+	if strings.HasPrefix(value, `\\`) {
+		return true
+	}
+
+	delim := strings.IndexAny(value, ".=")
+	if delim <= 0 || !strings.Contains(value[delim:], "=") {
+		return false
+	}
+	className := value[:delim]
+	if colon := strings.LastIndexByte(className, ':'); colon >= 0 {
+		className = className[colon+1:]
+	}
+	if className == "" {
+		return false
+	}
+	for i, c := range className {
+		if c != '_' && !unicode.IsLetter(c) && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLocalServer(server string) bool {
+	return server == "." || strings.EqualFold(server, "localhost")
+}
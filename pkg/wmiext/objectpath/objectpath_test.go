@@ -0,0 +1,122 @@
+package objectpath
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const systemPath = `\\HOST\root\virtualization\v2:Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B1"`
+
+func TestParse(t *testing.T) {
+	path, err := Parse(systemPath)
+	require.NoError(t, err)
+	assert.Equal(t, "HOST", path.Server)
+	assert.Equal(t, `root\virtualization\v2`, path.Namespace)
+	assert.Equal(t, "Msvm_ComputerSystem", path.ClassName)
+	assert.Equal(t, []KeyBinding{{Name: "CreationClassName", Value: "Msvm_ComputerSystem"}, {Name: "Name", Value: "A0B1"}}, path.Keys)
+	assert.Equal(t, systemPath, path.String())
+
+	tests := []struct {
+		path      string
+		namespace string
+		className string
+		keys      []KeyBinding
+		singleton bool
+		canonical string
+	}{
+		{path: `Msvm_ComputerSystem`, className: "Msvm_ComputerSystem", canonical: `Msvm_ComputerSystem`},
+		{path: `root/cimv2:Win32_OperatingSystem=@`, namespace: `root\cimv2`, className: "Win32_OperatingSystem", singleton: true, canonical: `root\cimv2:Win32_OperatingSystem=@`},
+		{
+			path:      `Msvm_Test.Name="a \"quoted\" \\ value",Index=-3,Big=18446744073709551615,Enabled=true`,
+			className: "Msvm_Test",
+			keys: []KeyBinding{
+				{Name: "Name", Value: `a "quoted" \ value`},
+				{Name: "Index", Value: int64(-3)},
+				{Name: "Big", Value: uint64(18446744073709551615)},
+				{Name: "Enabled", Value: true},
+			},
+			canonical: `Msvm_Test.Big=18446744073709551615,Enabled=TRUE,Index=-3,Name="a \"quoted\" \\ value"`,
+		},
+		{path: `Win32_Directory="C:\\Windows"`, className: "Win32_Directory", keys: []KeyBinding{{Value: `C:\Windows`}}, canonical: `Win32_Directory="C:\\Windows"`},
+	}
+
+	for _, test := range tests {
+		path, err := Parse(test.path)
+		require.NoError(t, err, test.path)
+		assert.Equal(t, test.namespace, path.Namespace, test.path)
+		assert.Equal(t, test.className, path.ClassName, test.path)
+		assert.Equal(t, test.keys, path.Keys, test.path)
+		assert.Equal(t, test.singleton, path.Singleton, test.path)
+		assert.Equal(t, test.canonical, path.String(), test.path)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, path := range []string{``, `root\cimv2:`, `Msvm_Test.Name="unterminated`, `Msvm_Test.Name=bare`, `Msvm_Test.="x"`, `Msvm_Test.A="x"B="y"`} {
+		_, err := Parse(path)
+		assert.True(t, errors.Is(err, ErrInvalidPath), path)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	assoc := `\\HOST\root\virtualization\v2:Msvm_SettingsDefineState.ManagedElement="\\\\HOST\\root\\virtualization\\v2:Msvm_ComputerSystem.CreationClassName=\"Msvm_ComputerSystem\",Name=\"A0B1\"",` +
+		`SettingData="\\\\HOST\\root\\virtualization\\v2:Msvm_VirtualSystemSettingData.InstanceID=\"Microsoft:A0B1\""`
+
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{systemPath, `msvm_computersystem.name="a0b1",creationclassname="MSVM_COMPUTERSYSTEM"`, true},
+		{systemPath, `\\.\ROOT\Virtualization\V2:Msvm_ComputerSystem.Name="A0B1",CreationClassName="Msvm_ComputerSystem"`, true},
+		{systemPath, `\\OTHER\root\virtualization\v2:Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B1"`, false},
+		{systemPath, `root\cimv2:Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B1"`, false},
+		{systemPath, `Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B2"`, false},
+		{systemPath, `Msvm_ComputerSystem.Name="A0B1"`, false},
+		{`Msvm_Test.Index=3`, `Msvm_Test.Index="3"`, true},
+		{`Win32_Directory="C:\\Windows"`, `Win32_Directory.Name="c:\\windows"`, true},
+		{
+			assoc,
+			`Msvm_SettingsDefineState.SettingData="Msvm_VirtualSystemSettingData.InstanceID=\"microsoft:a0b1\"",` +
+				`ManagedElement="Msvm_ComputerSystem.Name=\"A0B1\",CreationClassName=\"Msvm_ComputerSystem\""`,
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.equal, Equal(test.a, test.b), "%s == %s", test.a, test.b)
+		assert.Equal(t, test.equal, Equal(test.b, test.a), "%s == %s", test.b, test.a)
+	}
+
+	assert.Equal(t, Normalize(systemPath), Normalize(`Msvm_ComputerSystem.Name="a0b1",CreationClassName="Msvm_ComputerSystem"`))
+	assert.NotEqual(t, Normalize(systemPath), Normalize(`Msvm_ComputerSystem.Name="a0b2",CreationClassName="Msvm_ComputerSystem"`))
+}
+
+func TestFromStruct(t *testing.T) {
+	type system struct {
+		CreationClassName string
+		Name              string
+		ElementName       string
+	}
+
+	path, err := FromStruct("Msvm_ComputerSystem", &system{CreationClassName: "Msvm_ComputerSystem", Name: "A0B1", ElementName: "vm"}, "Name", "CreationClassName")
+	require.NoError(t, err)
+	assert.Equal(t, `Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B1"`, path.String())
+	assert.True(t, Equal(systemPath, path.String()))
+
+	_, err = FromStruct("Msvm_ComputerSystem", system{}, "InstanceID")
+	assert.Error(t, err)
+	_, err = FromStruct("Msvm_ComputerSystem", system{})
+	assert.Error(t, err)
+
+	computerSystem, err := Parse(systemPath)
+	require.NoError(t, err)
+	path, err = New("Msvm_Test", "Index", uint16(3), "Owner", computerSystem)
+	require.NoError(t, err)
+	assert.Equal(t, `Msvm_Test.Index=3,Owner="\\\\HOST\\root\\virtualization\\v2:Msvm_ComputerSystem.CreationClassName=\"Msvm_ComputerSystem\",Name=\"A0B1\""`, path.String())
+	owner, ok := path.Keys[1].Reference()
+	require.True(t, ok)
+	assert.Equal(t, "A0B1", owner.Keys[1].Value)
+}
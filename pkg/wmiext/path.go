@@ -0,0 +1,15 @@
+package wmiext
+
+import (
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+// parseObjectPath parses an object path, failing with WBEM_E_INVALID_OBJECT_PATH as WMI does.
+func parseObjectPath(path string) (*objectpath.Path, error) {
+	parsed, err := objectpath.Parse(path)
+	if err != nil {
+		return nil, errors.Wrapf(NewWmiError(WBEM_E_INVALID_OBJECT_PATH), "path %q", path)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,567 @@
+package wmiext
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext/mof"
+)
+
+// ClassSchema describes a class as the host defines it: its properties and methods, inherited ones
+// included, with their qualifiers. Amended qualifiers, such as Description and Values, are
+// localized by WMI and not included.
+type ClassSchema struct {
+	Name       string            `json:"name"`
+	Superclass string            `json:"superclass,omitempty"`
+	Qualifiers mof.Qualifiers    `json:"qualifiers,omitempty"`
+	Properties []*PropertySchema `json:"properties"`
+	Methods    []*MethodSchema   `json:"methods,omitempty"`
+}
+
+// Property returns the property with the given name, or nil.
+func (c *ClassSchema) Property(name string) *PropertySchema {
+	for _, property := range c.Properties {
+		if strings.EqualFold(property.Name, name) {
+			return property
+		}
+	}
+	return nil
+}
+
+// Method returns the method with the given name, or nil.
+func (c *ClassSchema) Method(name string) *MethodSchema {
+	for _, method := range c.Methods {
+		if strings.EqualFold(method.Name, name) {
+			return method
+		}
+	}
+	return nil
+}
+
+// Keys returns the names of the key properties.
+func (c *ClassSchema) Keys() []string {
+	var keys []string
+	for _, property := range c.Properties {
+		if property.IsKey() {
+			keys = append(keys, property.Name)
+		}
+	}
+	return keys
+}
+
+// PropertySchema describes a property of a class.
+type PropertySchema struct {
+	Name string              `json:"name"`
+	Type CIMTYPE_ENUMERATION `json:"type"`
+	// Origin is the class declaring the property, empty when unknown.
+	Origin     string         `json:"origin,omitempty"`
+	Qualifiers mof.Qualifiers `json:"qualifiers,omitempty"`
+}
+
+// IsKey reports whether the property is a key of the class.
+func (p *PropertySchema) IsKey() bool {
+	return p.Qualifiers.Has("Key")
+}
+
+// Writable reports whether the property is qualified Write, i.e. can be modified by clients.
+func (p *PropertySchema) Writable() bool {
+	return p.Qualifiers.Has("Write")
+}
+
+// Units returns the units of the property, e.g. "MegaBytes", empty when unqualified.
+func (p *PropertySchema) Units() string {
+	return p.Qualifiers.String("Units")
+}
+
+// ValueMap returns the values the property is restricted to.
+func (p *PropertySchema) ValueMap() []string {
+	return p.Qualifiers.Strings("ValueMap")
+}
+
+// ReferenceClass returns the class a reference property refers to, empty for other properties.
+func (p *PropertySchema) ReferenceClass() string {
+	return referenceClass(p.Qualifiers)
+}
+
+// MethodSchema describes a method of a class.
+type MethodSchema struct {
+	Name string `json:"name"`
+	// Origin is the class declaring the method, empty when unknown.
+	Origin     string              `json:"origin,omitempty"`
+	ReturnType CIMTYPE_ENUMERATION `json:"returnType"`
+	Parameters []*ParameterSchema  `json:"parameters,omitempty"`
+	Qualifiers mof.Qualifiers      `json:"qualifiers,omitempty"`
+}
+
+// Parameter returns the parameter with the given name, or nil.
+func (m *MethodSchema) Parameter(name string) *ParameterSchema {
+	for _, parameter := range m.Parameters {
+		if strings.EqualFold(parameter.Name, name) {
+			return parameter
+		}
+	}
+	return nil
+}
+
+// Signature returns the method in MOF syntax, e.g. "uint32 RequestStateChange([in] uint16
+// RequestedState, [out] reference Job)".
+func (m *MethodSchema) Signature() string {
+	params := make([]string, len(m.Parameters))
+	for i, parameter := range m.Parameters {
+		var direction []string
+		if parameter.In {
+			direction = append(direction, "in")
+		}
+		if parameter.Out {
+			direction = append(direction, "out")
+		}
+		params[i] = fmt.Sprintf("[%s] %s %s", strings.Join(direction, ", "), parameter.Type, parameter.Name)
+	}
+	return fmt.Sprintf("%s %s(%s)", m.ReturnType, m.Name, strings.Join(params, ", "))
+}
+
+// ParameterSchema describes a parameter of a method.
+type ParameterSchema struct {
+	Name       string              `json:"name"`
+	Type       CIMTYPE_ENUMERATION `json:"type"`
+	In         bool                `json:"in,omitempty"`
+	Out        bool                `json:"out,omitempty"`
+	Qualifiers mof.Qualifiers      `json:"qualifiers,omitempty"`
+}
+
+// ReferenceClass returns the class a reference parameter refers to, empty for other parameters.
+func (p *ParameterSchema) ReferenceClass() string {
+	return referenceClass(p.Qualifiers)
+}
+
+// referenceClass returns the class of the CIMTYPE qualifier WMI sets on references, "ref:Class".
+func referenceClass(qualifiers mof.Qualifiers) string {
+	cimType := qualifiers.String("CIMTYPE")
+	if !strings.HasPrefix(strings.ToLower(cimType), "ref:") {
+		return ""
+	}
+	return cimType[len("ref:"):]
+}
+
+// String returns the MOF name of the type, e.g. "uint16" or "string[]".
+func (t CIMTYPE_ENUMERATION) String() string {
+	name, ok := cimTypeNames[t&^CIM_FLAG_ARRAY]
+	switch {
+	case ok:
+	case t&^CIM_FLAG_ARRAY == CIM_OBJECT:
+		name = "object"
+	case t == CIM_EMPTY:
+		return "empty"
+	default:
+		return "CIMTYPE(" + strconv.Itoa(int(t)) + ")"
+	}
+	if t&CIM_FLAG_ARRAY != 0 {
+		name += "[]"
+	}
+	return name
+}
+
+// MarshalText encodes the type as its MOF name, for schema snapshots.
+func (t CIMTYPE_ENUMERATION) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes a type encoded by MarshalText.
+func (t *CIMTYPE_ENUMERATION) UnmarshalText(text []byte) error {
+	name := string(text)
+	array := strings.HasSuffix(name, "[]")
+	name = strings.TrimSuffix(name, "[]")
+	var cimType CIMTYPE_ENUMERATION
+	switch name {
+	case "object":
+		cimType = CIM_OBJECT
+	case "empty":
+		cimType = CIM_EMPTY
+	default:
+		found := false
+		for candidate, candidateName := range cimTypeNames {
+			if candidateName == name {
+				cimType, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("unknown CIM type %q", text)
+		}
+	}
+	if array {
+		cimType |= CIM_FLAG_ARRAY
+	}
+	*t = cimType
+	return nil
+}
+
+// classDescriber is implemented by the class objects of the backends able to describe their
+// schema, qualifiers and methods included.
+type classDescriber interface {
+	describeClass() (*ClassSchema, error)
+}
+
+// describeClass returns the schema of a class object. Without support from its backend, it is
+// derived from the properties of the object, without qualifiers nor methods.
+func describeClass(class Object) (*ClassSchema, error) {
+	if describer, ok := class.(classDescriber); ok {
+		return describer.describeClass()
+	}
+
+	properties, err := class.Properties()
+	if err != nil {
+		return nil, err
+	}
+	schema := &ClassSchema{Properties: []*PropertySchema{}}
+	for _, prop := range properties {
+		switch {
+		case strings.EqualFold(prop.Name, "__CLASS"):
+			schema.Name, _ = prop.Value.(string)
+		case strings.EqualFold(prop.Name, "__SUPERCLASS"):
+			schema.Superclass, _ = prop.Value.(string)
+		case !strings.HasPrefix(prop.Name, "__"):
+			schema.Properties = append(schema.Properties, &PropertySchema{Name: prop.Name, Type: prop.CimType})
+		}
+	}
+	return schema, nil
+}
+
+// sortParameters orders parameters by their ID qualifier, as WMI declares them.
+func sortParameters(parameters []*ParameterSchema) {
+	sort.SliceStable(parameters, func(i, j int) bool {
+		return parameterID(parameters[i]) < parameterID(parameters[j])
+	})
+}
+
+func parameterID(parameter *ParameterSchema) int64 {
+	qualifier, ok := parameter.Qualifiers.Get("ID")
+	if !ok {
+		return 1<<63 - 1
+	}
+	id, err := strconv.ParseInt(convertToString(qualifier.Value), 10, 64)
+	if err != nil {
+		return 1<<63 - 1
+	}
+	return id
+}
+
+// Schema describes the classes of the namespace of a Service, fetching each class once. It lets
+// callers check whether the host supports a property or method before using it, since the Hyper-V
+// classes gain members with every Windows release. A Schema is safe for concurrent use.
+type Schema struct {
+	service *Service
+
+	mu      sync.Mutex
+	classes map[string]*ClassSchema
+	// missing caches the classes the host does not define.
+	missing map[string]error
+}
+
+// Schema returns the schema of the namespace of the service, shared by its scoped services.
+func (s *Service) Schema() *Schema {
+	root := s
+	if s.root != nil {
+		root = s.root
+	}
+	root.schemaOnce.Do(func() {
+		root.schema = &Schema{service: root, classes: make(map[string]*ClassSchema), missing: make(map[string]error)}
+	})
+	return root.schema
+}
+
+// Class returns the schema of a class, failing with a WmiError WBEM_E_NOT_FOUND or
+// WBEM_E_INVALID_CLASS when the host does not define it.
+func (s *Schema) Class(name string) (*ClassSchema, error) {
+	key := strings.ToLower(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if class, ok := s.classes[key]; ok {
+		return class, nil
+	}
+	if err, ok := s.missing[key]; ok {
+		return nil, err
+	}
+
+	object, err := s.service.backend.GetObject(name)
+	if err != nil {
+		err = errors.Wrapf(err, "class %s", name)
+		if isMissingClass(err) {
+			s.missing[key] = err
+		}
+		return nil, err
+	}
+	defer object.Release()
+	class, err := describeClass(object)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe class %s", name)
+	}
+	if class.Name == "" {
+		class.Name = name
+	}
+	s.classes[key] = class
+	return class, nil
+}
+
+func isMissingClass(err error) bool {
+	var wmiErr *WmiError
+	if !errors.As(err, &wmiErr) {
+		return false
+	}
+	return wmiErr.Code() == uintptr(WBEM_E_NOT_FOUND) || wmiErr.Code() == uintptr(WBEM_E_INVALID_CLASS)
+}
+
+// HasClass reports whether the host defines a class. Failures to fetch the class are reported as
+// missing.
+func (s *Schema) HasClass(className string) bool {
+	_, err := s.Class(className)
+	return err == nil
+}
+
+// HasProperty reports whether a class defines a property, e.g. to leave out the newer
+// Msvm_VirtualSystemSettingData properties on older hosts.
+func (s *Schema) HasProperty(className string, property string) bool {
+	class, err := s.Class(className)
+	return err == nil && class.Property(property) != nil
+}
+
+// HasMethod reports whether a class defines a method.
+func (s *Schema) HasMethod(className string, method string) bool {
+	class, err := s.Class(className)
+	return err == nil && class.Method(method) != nil
+}
+
+// Snapshot returns the schemas of classes, or of every class of the namespace when none is given.
+func (s *Schema) Snapshot(classNames ...string) (*SchemaSnapshot, error) {
+	snapshot := &SchemaSnapshot{}
+	if len(classNames) > 0 {
+		for _, name := range classNames {
+			class, err := s.Class(name)
+			if err != nil {
+				return nil, err
+			}
+			snapshot.Classes = append(snapshot.Classes, class)
+		}
+		return snapshot, nil
+	}
+
+	iterator, err := s.service.backend.ExecQuery("SELECT * FROM meta_class")
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Release()
+	for {
+		object, err := iterator.Next()
+		if err != nil || object == nil {
+			return snapshot, err
+		}
+		class, err := describeClass(object)
+		object.Release()
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Classes = append(snapshot.Classes, class)
+	}
+}
+
+// SchemaSnapshot holds class schemas captured from a host, saved as JSON so that the schemas of
+// different Windows builds can be compared offline with CompareSchemas.
+type SchemaSnapshot struct {
+	// Source describes where the schemas were captured, e.g. the Windows build of the host.
+	Source  string         `json:"source,omitempty"`
+	Classes []*ClassSchema `json:"classes"`
+}
+
+// LoadSchemaSnapshot reads a snapshot saved by Save.
+func LoadSchemaSnapshot(path string) (*SchemaSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &SchemaSnapshot{}
+	if err = json.Unmarshal(data, snapshot); err != nil {
+		return nil, errors.Wrapf(err, "invalid schema snapshot %s", path)
+	}
+	return snapshot, nil
+}
+
+// Save writes the snapshot to path as JSON, creating its directory if needed.
+func (s *SchemaSnapshot) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Class returns the schema of the class with the given name, or nil.
+func (s *SchemaSnapshot) Class(name string) *ClassSchema {
+	for _, class := range s.Classes {
+		if strings.EqualFold(class.Name, name) {
+			return class
+		}
+	}
+	return nil
+}
+
+// SchemaDifference is a difference between two snapshots.
+type SchemaDifference struct {
+	Class string
+	// Member is the property, or the method followed by "()", that differs. It is empty when the
+	// class itself was added or removed.
+	Member string
+	// Old and New describe the member, or the class, in each snapshot, empty where it is missing.
+	Old string
+	New string
+}
+
+func (d SchemaDifference) String() string {
+	name := d.Class
+	if d.Member != "" {
+		name += "." + d.Member
+	}
+	switch {
+	case d.Old == "":
+		return fmt.Sprintf("+ %s: %s", name, d.New)
+	case d.New == "":
+		return fmt.Sprintf("- %s: %s", name, d.Old)
+	default:
+		return fmt.Sprintf("~ %s: %s => %s", name, d.Old, d.New)
+	}
+}
+
+// CompareSchemas returns the classes, properties and methods added, removed or changed from old
+// to new, by class and member name.
+func CompareSchemas(old *SchemaSnapshot, new *SchemaSnapshot) []SchemaDifference {
+	var differences []SchemaDifference
+	oldClasses, newClasses := schemaClasses(old), schemaClasses(new)
+	for _, key := range unionKeys(oldClasses, newClasses) {
+		oldClass, newClass := oldClasses[key], newClasses[key]
+		switch {
+		case oldClass == nil:
+			differences = append(differences, SchemaDifference{Class: newClass.Name, New: describeClassSchema(newClass)})
+			continue
+		case newClass == nil:
+			differences = append(differences, SchemaDifference{Class: oldClass.Name, Old: describeClassSchema(oldClass)})
+			continue
+		}
+		if oldText, newText := describeClassSchema(oldClass), describeClassSchema(newClass); oldText != newText {
+			differences = append(differences, SchemaDifference{Class: newClass.Name, Old: oldText, New: newText})
+		}
+		differences = appendMemberDifferences(differences, newClass.Name, classProperties(oldClass), classProperties(newClass))
+		differences = appendMemberDifferences(differences, newClass.Name, classMethods(oldClass), classMethods(newClass))
+	}
+	return differences
+}
+
+func appendMemberDifferences(differences []SchemaDifference, className string, old map[string][2]string, new map[string][2]string) []SchemaDifference {
+	for _, key := range unionKeys(old, new) {
+		oldMember, oldOk := old[key]
+		newMember, newOk := new[key]
+		if oldOk && newOk && oldMember[1] == newMember[1] {
+			continue
+		}
+		name := newMember[0]
+		if !newOk {
+			name = oldMember[0]
+		}
+		differences = append(differences, SchemaDifference{Class: className, Member: name, Old: oldMember[1], New: newMember[1]})
+	}
+	return differences
+}
+
+func schemaClasses(snapshot *SchemaSnapshot) map[string]*ClassSchema {
+	classes := make(map[string]*ClassSchema)
+	if snapshot != nil {
+		for _, class := range snapshot.Classes {
+			classes[strings.ToLower(class.Name)] = class
+		}
+	}
+	return classes
+}
+
+// classProperties returns the name and description of each property, by lower case name.
+func classProperties(class *ClassSchema) map[string][2]string {
+	properties := make(map[string][2]string)
+	for _, property := range class.Properties {
+		properties[strings.ToLower(property.Name)] = [2]string{property.Name, property.Type.String() + describeQualifiers(property.Qualifiers)}
+	}
+	return properties
+}
+
+// classMethods returns the name and description of each method, by lower case name.
+func classMethods(class *ClassSchema) map[string][2]string {
+	methods := make(map[string][2]string)
+	for _, method := range class.Methods {
+		description := method.Signature() + describeQualifiers(method.Qualifiers)
+		for _, parameter := range method.Parameters {
+			if qualifiers := describeQualifiers(parameter.Qualifiers); qualifiers != "" {
+				description += ", " + parameter.Name + qualifiers
+			}
+		}
+		methods[strings.ToLower(method.Name)] = [2]string{method.Name + "()", description}
+	}
+	return methods
+}
+
+func describeClassSchema(class *ClassSchema) string {
+	description := "class " + class.Name
+	if class.Superclass != "" {
+		description += " : " + class.Superclass
+	}
+	return description + describeQualifiers(class.Qualifiers)
+}
+
+// describeQualifiers formats qualifiers independently of their order and of the JSON decoding of
+// their values, e.g. ` [Key, ValueMap(["2","3"])]`.
+func describeQualifiers(qualifiers mof.Qualifiers) string {
+	if len(qualifiers) == 0 {
+		return ""
+	}
+	descriptions := make([]string, 0, len(qualifiers))
+	for _, qualifier := range qualifiers {
+		if strings.EqualFold(qualifier.Name, "CIMTYPE") || strings.EqualFold(qualifier.Name, "ID") {
+			// Implied by the types and the order of the parameters
+			continue
+		}
+		value, err := json.Marshal(qualifier.Value)
+		switch {
+		case err != nil:
+			descriptions = append(descriptions, fmt.Sprintf("%s(%v)", qualifier.Name, qualifier.Value))
+		case string(value) == "true":
+			descriptions = append(descriptions, qualifier.Name)
+		default:
+			descriptions = append(descriptions, qualifier.Name+"("+string(value)+")")
+		}
+	}
+	if len(descriptions) == 0 {
+		return ""
+	}
+	sort.Strings(descriptions)
+	return " [" + strings.Join(descriptions, ", ") + "]"
+}
+
+func unionKeys[V any](maps ...map[string]V) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
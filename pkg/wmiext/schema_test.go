@@ -0,0 +1,151 @@
+package wmiext
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rokukoo/hyperv/pkg/wmiext/mof"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend counts the calls of GetObject.
+type countingBackend struct {
+	Backend
+	gets int
+}
+
+func (b *countingBackend) GetObject(path string) (Object, error) {
+	b.gets++
+	return b.Backend.GetObject(path)
+}
+
+func TestSchema(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.DefineClass(MemoryClass{
+		Name:       "CIM_ComputerSystem",
+		Keys:       []string{"CreationClassName", "Name"},
+		Properties: map[string]CIMTYPE_ENUMERATION{"CreationClassName": CIM_STRING, "Name": CIM_STRING, "EnabledState": CIM_UINT16},
+		Methods:    map[string]map[string]CIMTYPE_ENUMERATION{"RequestStateChange": {"RequestedState": CIM_UINT16}},
+	})
+	repo.DefineClass(MemoryClass{
+		Name:       "Msvm_ComputerSystem",
+		Superclass: "CIM_ComputerSystem",
+		Properties: map[string]CIMTYPE_ENUMERATION{"EnabledState": CIM_UINT16, "OperationalStatus": CIM_UINT16 | CIM_FLAG_ARRAY},
+		Methods:    map[string]map[string]CIMTYPE_ENUMERATION{"RequestStateChange": {"RequestedState": CIM_UINT16, "Job": CIM_REFERENCE}},
+	})
+	backend := &countingBackend{Backend: repo.Backend()}
+	service := NewService(backend)
+	defer service.Close()
+
+	class, err := service.Schema().Class("msvm_computersystem")
+	require.NoError(t, err)
+	assert.Equal(t, "Msvm_ComputerSystem", class.Name)
+	assert.Equal(t, "CIM_ComputerSystem", class.Superclass)
+	assert.Equal(t, []string{"CreationClassName", "Name"}, class.Keys())
+	require.NotNil(t, class.Property("EnabledState"))
+	assert.Equal(t, "Msvm_ComputerSystem", class.Property("EnabledState").Origin)
+	assert.Equal(t, "CIM_ComputerSystem", class.Property("name").Origin)
+	assert.Equal(t, CIM_UINT16|CIM_FLAG_ARRAY, class.Property("OperationalStatus").Type)
+	method := class.Method("RequestStateChange")
+	require.NotNil(t, method)
+	assert.Equal(t, "Msvm_ComputerSystem", method.Origin)
+	assert.Equal(t, "uint32 RequestStateChange([in] reference Job, [in] uint16 RequestedState)", method.Signature())
+
+	// Classes are fetched once, the missing ones included, by the scoped services as well
+	schema := service.Scope(NewArena()).Schema()
+	assert.True(t, schema.HasProperty("Msvm_ComputerSystem", "OperationalStatus"))
+	assert.False(t, schema.HasProperty("Msvm_ComputerSystem", "HwThreadsPerCore"))
+	assert.True(t, schema.HasMethod("Msvm_ComputerSystem", "requeststatechange"))
+	assert.False(t, schema.HasMethod("Msvm_ComputerSystem", "Upgrade"))
+	assert.False(t, schema.HasClass("Msvm_SecurityService"))
+	_, err = schema.Class("Msvm_SecurityService")
+	var wmiErr *WmiError
+	require.True(t, errors.As(err, &wmiErr))
+	assert.Equal(t, uintptr(WBEM_E_NOT_FOUND), wmiErr.Code())
+	assert.Equal(t, 2, backend.gets)
+
+	// Classes declaring no properties are described from their instances
+	class, err = schema.Class("Msvm_VirtualSystemSettingData")
+	require.NoError(t, err)
+	assert.Empty(t, class.Properties)
+	_, err = repo.AddInstance("Msvm_ConcreteJob", map[string]interface{}{"InstanceID": "job-1", "JobState": uint16(7)})
+	require.NoError(t, err)
+	class, err = schema.Class("Msvm_ConcreteJob")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"InstanceID"}, class.Keys())
+	assert.Equal(t, CIM_UINT16, class.Property("JobState").Type)
+}
+
+func TestSchema_DescribeObject(t *testing.T) {
+	instance, err := DecodeCimXml(`<INSTANCE CLASSNAME="Msvm_MemorySettingData"><PROPERTY NAME="VirtualQuantity" TYPE="uint64"></PROPERTY></INSTANCE>`)
+	require.NoError(t, err)
+	// Without qualifiers nor methods
+	class, err := describeClass(instance)
+	require.NoError(t, err)
+	assert.Equal(t, &ClassSchema{
+		Name:       "Msvm_MemorySettingData",
+		Properties: []*PropertySchema{{Name: "VirtualQuantity", Type: CIM_UINT64}},
+	}, class)
+}
+
+func TestSchemaSnapshot(t *testing.T) {
+	old := &SchemaSnapshot{Source: "10.0.17763", Classes: []*ClassSchema{
+		{
+			Name: "Msvm_VirtualSystemSettingData",
+			Properties: []*PropertySchema{
+				{Name: "InstanceID", Type: CIM_STRING, Qualifiers: mof.Qualifiers{{Name: "Key", Value: true}}},
+				{Name: "Notes", Type: CIM_STRING | CIM_FLAG_ARRAY, Qualifiers: mof.Qualifiers{{Name: "Write", Value: true}}},
+				{Name: "ConsoleMode", Type: CIM_UINT16, Qualifiers: mof.Qualifiers{{Name: "ValueMap", Value: []interface{}{"0", "1"}}}},
+			},
+		},
+		{Name: "Msvm_Removed", Properties: []*PropertySchema{}},
+	}}
+
+	path := filepath.Join(t.TempDir(), "schema.json")
+	require.NoError(t, old.Save(path))
+	loaded, err := LoadSchemaSnapshot(path)
+	require.NoError(t, err)
+	assert.Equal(t, old.Source, loaded.Source)
+	assert.Equal(t, CIM_STRING|CIM_FLAG_ARRAY, loaded.Class("msvm_virtualsystemsettingdata").Property("Notes").Type)
+	assert.Empty(t, CompareSchemas(old, loaded))
+
+	var new SchemaSnapshot
+	data, err := json.Marshal(loaded)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &new))
+	settings := new.Class("Msvm_VirtualSystemSettingData")
+	settings.Properties[1].Type = CIM_STRING
+	settings.Properties[2].Qualifiers = mof.Qualifiers{{Name: "ValueMap", Value: []interface{}{"0", "1", "2"}}}
+	settings.Properties = append(settings.Properties, &PropertySchema{Name: "GuestStateIsolationType", Type: CIM_UINT16})
+	settings.Methods = []*MethodSchema{{Name: "Upgrade", ReturnType: CIM_UINT32}}
+	new.Classes = append(new.Classes[:1], &ClassSchema{Name: "Msvm_Added"})
+
+	var differences []string
+	for _, difference := range CompareSchemas(old, &new) {
+		differences = append(differences, difference.String())
+	}
+	assert.Equal(t, []string{
+		"+ Msvm_Added: class Msvm_Added",
+		"- Msvm_Removed: class Msvm_Removed",
+		`~ Msvm_VirtualSystemSettingData.ConsoleMode: uint16 [ValueMap(["0","1"])] => uint16 [ValueMap(["0","1","2"])]`,
+		"+ Msvm_VirtualSystemSettingData.GuestStateIsolationType: uint16",
+		"~ Msvm_VirtualSystemSettingData.Notes: string[] [Write] => string [Write]",
+		"+ Msvm_VirtualSystemSettingData.Upgrade(): uint32 Upgrade()",
+	}, differences)
+}
+
+func TestCimType_Text(t *testing.T) {
+	for _, cimType := range []CIMTYPE_ENUMERATION{CIM_UINT16, CIM_STRING | CIM_FLAG_ARRAY, CIM_REFERENCE, CIM_OBJECT | CIM_FLAG_ARRAY, CIM_EMPTY} {
+		text, err := cimType.MarshalText()
+		require.NoError(t, err)
+		var decoded CIMTYPE_ENUMERATION
+		require.NoError(t, decoded.UnmarshalText(text))
+		assert.Equal(t, cimType, decoded, string(text))
+	}
+	assert.Equal(t, "datetime[]", (CIM_DATETIME | CIM_FLAG_ARRAY).String())
+	var decoded CIMTYPE_ENUMERATION
+	assert.Error(t, decoded.UnmarshalText([]byte("uint128")))
+}
@@ -1,210 +1,118 @@
-//go:build windows
-// +build windows
-
 package wmiext
 
 import (
-	"errors"
-	"fmt"
-	"syscall"
-	"unsafe"
-
-	"github.com/go-ole/go-ole"
+	"context"
+	"sync"
+	"sync/atomic"
 )
 
-type IWbemLocatorVtbl struct {
-	QueryInterface uintptr
-	AddRef         uintptr
-	Release        uintptr
-	ConnectServer  uintptr
-}
-
 type Service struct {
-	service *ole.IUnknown
-	vTable  *IWbemServicesVtbl
+	backend Backend
+	// telemetry is set by SetTelemetry, which may race with the operations in progress
+	telemetry atomic.Pointer[Telemetry]
+	// associationClasses caches which classes are associations, for CompileNavigation
+	associationClasses sync.Map
+	// arena owns the instances and enumerations created through the service, see Scope
+	arena *Arena
+	// root is the service a scoped service was derived from
+	root *Service
+	// borrowed is set on the services returned by WithContext, which own nothing
+	borrowed bool
+	// schema describes the classes of the namespace, see Schema
+	schemaOnce sync.Once
+	schema     *Schema
 }
 
-type IWbemServicesVtbl struct {
-	QueryInterface             uintptr
-	AddRef                     uintptr
-	Release                    uintptr
-	OpenNamespace              uintptr
-	CancelAsyncCall            uintptr
-	QueryObjectSink            uintptr
-	GetObject                  uintptr
-	GetObjectAsync             uintptr
-	PutClass                   uintptr
-	PutClassAsync              uintptr
-	DeleteClass                uintptr
-	DeleteClassAsync           uintptr
-	CreateClassEnum            uintptr
-	CreateClassEnumAsync       uintptr
-	PutInstance                uintptr
-	PutInstanceAsync           uintptr
-	DeleteInstance             uintptr
-	DeleteInstanceAsync        uintptr
-	CreateInstanceEnum         uintptr
-	CreateInstanceEnumAsync    uintptr
-	ExecQuery                  uintptr
-	ExecQueryAsync             uintptr
-	ExecNotificationQuery      uintptr
-	ExecNotificationQueryAsync uintptr
-	ExecMethod                 uintptr
-	ExecMethodAsync            uintptr
+// NewService creates a Service that talks to the specified Backend.
+func NewService(backend Backend) *Service {
+	return &Service{backend: backend}
 }
 
-func connectService(namespace string) (*Service, error) {
-
-	if wmiWbemLocator == nil {
-		return nil, errors.New("WMI failed initialization, service calls can not proceed")
-	}
-
-	var err error
-	var res uintptr
-	var strResource *uint16
-	var strLocale *uint16
-	var service *ole.IUnknown
-
-	loc := fmt.Sprintf(`\\.\%s`, namespace)
+// Backend returns the transport used by this Service.
+func (s *Service) Backend() Backend {
+	return s.backend
+}
 
-	if strResource, err = syscall.UTF16PtrFromString(loc); err != nil {
-		return nil, err
-	}
+// SetTelemetry sets the telemetry receiving the operations of this Service, instead of the one set
+// by the package-level SetTelemetry.
+func (s *Service) SetTelemetry(telemetry *Telemetry) {
+	s.telemetry.Store(telemetry)
+}
 
-	// ConnectByName with en_US LCID since we do pattern matching against English key values
-	if strLocale, err = syscall.UTF16PtrFromString("MS_409"); err != nil {
-		return nil, err
+// Telemetry returns the telemetry receiving the operations of this Service.
+func (s *Service) Telemetry() *Telemetry {
+	if s == nil {
+		return GetTelemetry()
 	}
-
-	myVTable := (*IWbemLocatorVtbl)(unsafe.Pointer(wmiWbemLocator.RawVTable))
-	res, _, _ = syscall.SyscallN(
-		myVTable.ConnectServer,                  // IWbemLocator::ConnectServer(
-		uintptr(unsafe.Pointer(wmiWbemLocator)), // IWbemLocator ptr
-		uintptr(unsafe.Pointer(strResource)),    // [in]  const BSTR    strNetworkResource,
-		uintptr(0),                              // [in]  const BSTR    strUser,
-		uintptr(0),                              // [in]  const BSTR    strPassword,
-		uintptr(unsafe.Pointer(strLocale)),      // [in]  const BSTR    strLocale,
-		uintptr(WBEM_FLAG_CONNECT_USE_MAX_WAIT), // [in]  long          lSecurityFlags,
-		uintptr(0),                              // [in]  const BSTR    strAuthority,
-		uintptr(0),                              // [in]  IWbemContext  *pCtx,
-		uintptr(unsafe.Pointer(&service)))       // [out] IWbemServices **ppNamespace)
-
-	if res != 0 {
-		return nil, NewWmiError(res)
+	if telemetry := s.telemetry.Load(); telemetry != nil {
+		return telemetry
 	}
-
-	if err = CoSetProxyBlanket(service); err != nil {
-		return nil, err
+	if s.root != nil {
+		return s.root.Telemetry()
 	}
-
-	return newService(service), nil
+	return GetTelemetry()
 }
 
-func newService(service *ole.IUnknown) *Service {
-	return &Service{
-		service: service,
-		vTable:  (*IWbemServicesVtbl)(unsafe.Pointer(service.RawVTable)),
-	}
+// contextBackend is implemented by the backends able to dispatch their calls with the context of
+// the caller, such as the ones of a Dispatcher.
+type contextBackend interface {
+	withContext(ctx context.Context) Backend
 }
 
-const (
-	WBEM_FLAG_CONNECT_USE_MAX_WAIT = 0x80
-)
-
-func CoSetProxyBlanket(service *ole.IUnknown) (err error) {
-	res, _, _ := procCoSetProxyBlanket.Call( //CoSetProxyBlanket(
-		uintptr(unsafe.Pointer(service)),     // [in]      IUnknown                 *pProxy,
-		uintptr(RPC_C_AUTHN_WINNT),           // [in]      DWORD                    dwAuthnSvc,
-		uintptr(RPC_C_AUTHZ_NONE),            // [in]      DWORD                    dwAuthzSvc,
-		uintptr(0),                           // [in, opt] OLECHAR                  *pServerPrincName,
-		uintptr(RPC_C_AUTHN_LEVEL_CALL),      // [in]      DWORD                    dwAuthnLevel,
-		uintptr(RPC_C_IMP_LEVEL_IMPERSONATE), // [in]      DWORD                    dwImpLevel,
-		uintptr(0),                           // [in, opt] RPC_AUTH_IDENTITY_HANDLE pAuthInfo,
-		uintptr(EOAC_NONE))                   // [in]      DWORD                    dwCapabilities)
-
-	if res != 0 {
-		return NewWmiError(res)
+// WithContext returns a Service sharing the backend, arena and telemetry of s, whose calls give up
+// once ctx is done when the backend supports it, as the backends of a Dispatcher do. ctx only
+// covers the calls made through the returned Service and its iterators: the instances they return
+// outlive it. Closing the returned Service has no effect.
+func (s *Service) WithContext(ctx context.Context) *Service {
+	backend := s.backend
+	if bound, ok := backend.(contextBackend); ok {
+		backend = bound.withContext(ctx)
 	}
-
-	return nil
-}
-
-// NewLocalService creates a Service and connect it to the local system at the specified namespace
-func NewLocalService(namespace string) (s *Service, err error) {
-	return connectService(namespace)
+	root := s
+	if s.root != nil {
+		root = s.root
+	}
+	return &Service{backend: backend, arena: s.arena, root: root, borrowed: true}
 }
 
-// Close frees all associated memory with this Service
+// Close frees all associated memory with this Service. Closing a scoped Service closes its Arena
+// instead, leaving the backend open.
 func (s *Service) Close() {
-	if s != nil && s.service != nil {
-		s.service.Release()
+	if s == nil || s.borrowed {
+		return
+	}
+	if s.arena != nil {
+		s.arena.Close()
+		return
+	}
+	if s.backend != nil {
+		s.backend.Close()
 	}
 }
 
 // ExecQuery executes a WQL query and returns an enumeration to iterate the result set.
 // Queries are executed in a semi-synchronous fashion.
 func (s *Service) ExecQuery(wqlQuery string) (*Enum, error) {
-	var err error
-	var pEnum *ole.IUnknown
-	var strQuery *uint16
-	var strQL *uint16
-
-	if strQL, err = syscall.UTF16PtrFromString("WQL"); err != nil {
-		return nil, err
-	}
-
-	if strQuery, err = syscall.UTF16PtrFromString(wqlQuery); err != nil {
-		return nil, err
-	}
-
-	// Semisynchronous mode = return immed + forward (for perf)
-	flags := WBEM_FLAG_FORWARD_ONLY | WBEM_FLAG_RETURN_IMMEDIATELY
-
-	hres, _, _ := syscall.SyscallN(
-		s.vTable.ExecQuery,                 // IWbemServices::ExecQuery(
-		uintptr(unsafe.Pointer(s.service)), // IWbemServices ptr
-		uintptr(unsafe.Pointer(strQL)),     // [in] const BSTR           strQueryLanguage,
-		uintptr(unsafe.Pointer(strQuery)),  // [in] const BSTR           strQuery,
-		uintptr(flags),                     // [in] long                 lFlags,
-		uintptr(0),                         // [in] IWbemContext         *pCtx,
-		uintptr(unsafe.Pointer(&pEnum)))    // [out] IEnumWbemClassObject **ppEnum)
-	if hres != 0 {
-		return nil, NewWmiError(hres)
-	}
-
-	if err = CoSetProxyBlanket(pEnum); err != nil {
+	observation := s.Telemetry().observe(context.Background(), "ExecQuery", MetricQueryDuration, Attr(AttributeQuery, wqlQuery))
+	iterator, err := s.backend.ExecQuery(wqlQuery)
+	observation.end(err)
+	if err != nil {
 		return nil, err
 	}
 
-	return newEnum(pEnum, s), nil
+	return newEnum(iterator, s), nil
 }
 
 // GetObject obtains a single WMI class or instance given its path
 func (s *Service) GetObject(objectPath string) (instance *Instance, err error) {
-	var pObject *ole.IUnknown
-	var strObjectPath *uint16
-
-	if strObjectPath, err = syscall.UTF16PtrFromString(objectPath); err != nil {
-		return
-	}
-
-	// Synchronous call
-	flags := WBEM_FLAG_RETURN_WBEM_COMPLETE
-
-	res, _, _ := syscall.SyscallN(
-		s.vTable.GetObject,                     // IWbemServices::GetObject(
-		uintptr(unsafe.Pointer(s.service)),     // IWbemServices ptr
-		uintptr(unsafe.Pointer(strObjectPath)), // [in]  const BSTR       strObjectPath,
-		uintptr(flags),                         // [in]  long             lFlags,
-		uintptr(0),                             // [in]  IWbemContext     *pCtx,
-		uintptr(unsafe.Pointer(&pObject)),      // [out] IWbemClassObject **ppObject,
-		uintptr(0))                             // [out] IWbemCallResult  **ppCallResult)
-	if int(res) < 0 {
-		// returns WBEM_E_PROVIDER_NOT_FOUND when no entry found
-		return nil, NewWmiError(res)
+	observation := s.Telemetry().observe(context.Background(), "GetObject", MetricQueryDuration, pathAttributes(objectPath)...)
+	object, err := s.backend.GetObject(objectPath)
+	observation.end(err)
+	if err != nil {
+		return nil, err
 	}
 
-	return newInstance(pObject, s), nil
+	return newInstance(object, s), nil
 }
 
 // GetObjectAsObject gets an object by its path and set all fields of the passed in target to match the instance's
@@ -214,71 +122,43 @@ func (s *Service) GetObjectAsObject(objPath string, target interface{}) error {
 	if err != nil {
 		return err
 	}
-	//defer instance.Close()
+	defer closeUnlessRetained(instance, target)
 
 	return instance.GetAll(target)
 }
 
 // CreateInstanceEnum creates an enumerator that iterates all registered object instances for a given className.
 func (s *Service) CreateInstanceEnum(className string) (*Enum, error) {
-	var err error
-	var pEnum *ole.IUnknown
-	var strFilter *uint16
-
-	if strFilter, err = syscall.UTF16PtrFromString(className); err != nil {
-		return nil, err
-	}
-
-	// No subclasses in result set
-	flags := WBEM_FLAG_SHALLOW
-
-	res, _, _ := syscall.SyscallN(
-		s.vTable.CreateInstanceEnum,        // IWbemServices::CreateInstanceEnum(
-		uintptr(unsafe.Pointer(s.service)), // IWbemServices ptr
-		uintptr(unsafe.Pointer(strFilter)), // [in]  const BSTR           strFilter,
-		uintptr(flags),                     // [in]  long                 lFlags,
-		uintptr(0),                         // [in]  IWbemContext         *pCtx,
-		uintptr(unsafe.Pointer(&pEnum)))    // [out] IEnumWbemClassObject **ppEnum)
-	if int(res) < 0 {
-		return nil, NewWmiError(res)
-	}
-
-	if err = CoSetProxyBlanket(pEnum); err != nil {
+	observation := s.Telemetry().observe(context.Background(), "CreateInstanceEnum", MetricQueryDuration, Attr(AttributeClass, className))
+	iterator, err := s.backend.CreateInstanceEnum(className)
+	observation.end(err)
+	if err != nil {
 		return nil, err
 	}
 
-	return newEnum(pEnum, s), nil
+	return newEnum(iterator, s), nil
 }
 
 // ExecMethod executes a method using the specified class and parameter payload instance. The parameter payload
 // instance can be constructed using Instance.GetMethodParameters(). This is an advanced method, it is
 // recommended to use Method() instead, where possible.
 func (s *Service) ExecMethod(className string, methodName string, inParams *Instance) (*Instance, error) {
-	var err error
-	var outParams *ole.IUnknown
-	var strObjectPath *uint16
-	var strMethodName *uint16
-
-	if strObjectPath, err = syscall.UTF16PtrFromString(className); err != nil {
-		return nil, err
+	var in Object
+	if inParams != nil {
+		in = inParams.object
 	}
 
-	if strMethodName, err = syscall.UTF16PtrFromString(methodName); err != nil {
+	observation := s.Telemetry().observe(context.Background(), "ExecMethod", MetricMethodDuration,
+		append(pathAttributes(className), Attr(AttributeMethod, methodName))...)
+	outParams, err := s.backend.ExecMethod(className, methodName, in)
+	if err != nil {
+		observation.end(err)
 		return nil, err
 	}
+	observation.end(nil, returnValueAttributes(outParams)...)
 
-	res, _, _ := syscall.SyscallN(
-		s.vTable.ExecMethod,                      // IWbemServices::ExecMethod(
-		uintptr(unsafe.Pointer(s.service)),       // IWbemServices ptr
-		uintptr(unsafe.Pointer(strObjectPath)),   // [in]  const BSTR       strObjectPath,
-		uintptr(unsafe.Pointer(strMethodName)),   // [in]  const BSTR       strMethodName,
-		uintptr(0),                               // [in]  long             lFlags,
-		uintptr(0),                               // [in]  IWbemContext     *pCtx,
-		uintptr(unsafe.Pointer(inParams.object)), // [in]  IWbemClassObject *pInParams,
-		uintptr(unsafe.Pointer(&outParams)),      // [out] IWbemClassObject **ppOutParams,
-		uintptr(0))                               // [out] IWbemCallResult  **ppCallResult)
-	if int(res) < 0 {
-		return nil, NewWmiError(res)
+	if outParams == nil {
+		return nil, nil
 	}
 
 	return newInstance(outParams, s), nil
@@ -330,28 +210,28 @@ func (s *Service) FindInstances(wql string) ([]*Instance, error) {
 
 // FindRelatedInstances finds and returns all WMI Instances in the result set for a WQL query, and
 func (s *Service) FindRelatedInstances(objPath string, className string) ([]*Instance, error) {
-	wql := fmt.Sprintf("ASSOCIATORS OF {%s} WHERE ResultClass = %s", objPath, className)
+	wql := AssociatorsOf(objPath).ResultClass(className).String()
 	return s.FindInstances(wql)
 }
 
 // FindFirstRelatedInstance finds and returns a related associator of the specified WMI object path of the
 // expected className type.
 func (s *Service) FindFirstRelatedInstance(objPath string, className string) (*Instance, error) {
-	wql := fmt.Sprintf("ASSOCIATORS OF {%s} WHERE ResultClass = %s", objPath, className)
+	wql := AssociatorsOf(objPath).ResultClass(className).String()
 	return s.FindFirstInstance(wql)
 }
 
 // FindFirstRelatedInstanceThrough finds and returns a related associator of the specified WMI object path of the
 // expected className type, and only through the expected association type.
 func (s *Service) FindFirstRelatedInstanceThrough(objPath string, resultClass string, assocClass string) (*Instance, error) {
-	wql := fmt.Sprintf("ASSOCIATORS OF {%s} WHERE AssocClass = %s ResultClass = %s ", objPath, assocClass, resultClass)
+	wql := AssociatorsOf(objPath).AssocClass(assocClass).ResultClass(resultClass).String()
 	return s.FindFirstInstance(wql)
 }
 
 // FindFirstRelatedObject finds and returns a related associator of the specified WMI object path of the
 // expected className type, and populates the passed in struct with its fields
 func (s *Service) FindFirstRelatedObject(objPath string, className string, target interface{}) error {
-	wql := fmt.Sprintf("ASSOCIATORS OF {%s} WHERE ResultClass = %s", objPath, className)
+	wql := AssociatorsOf(objPath).ResultClass(className).String()
 	return s.FindFirstObject(wql, target)
 }
 
@@ -379,25 +259,25 @@ func (s *Service) FindFirstObject(wql string, target interface{}) error {
 
 // FindRelatedObjectsThrough finds and returns all WMI Instances in the result set for a WQL query, and
 func (s *Service) FindRelatedObjectsThrough(objPath, resultClass, assocClass string, targetSlice interface{}) error {
-	wql := fmt.Sprintf("ASSOCIATORS OF {%s} WHERE AssocClass = %s ResultClass = %s ", objPath, assocClass, resultClass)
+	wql := AssociatorsOf(objPath).AssocClass(assocClass).ResultClass(resultClass).String()
 	return s.FindObjects(wql, targetSlice)
 }
 
 // FindRelatedObjects finds and returns all WMI Instances in the result set for a WQL query, and
 func (s *Service) FindRelatedObjects(objPath, className string, targetSlice interface{}) error {
-	wql := fmt.Sprintf("ASSOCIATORS OF {%s} WHERE ResultClass = %s", objPath, className)
+	wql := AssociatorsOf(objPath).ResultClass(className).String()
 	return s.FindObjects(wql, targetSlice)
 }
 
 // FindReferenceInstances finds and returns all WMI Instances in the result set for a WQL query
 func (s *Service) FindReferenceInstances(objPath string, className string) ([]*Instance, error) {
-	wql := fmt.Sprintf("REFERENCES OF {%s} WHERE ResultClass = %s", objPath, className)
+	wql := ReferencesOf(objPath).ResultClass(className).String()
 	return s.FindInstances(wql)
 }
 
 // FindReferenceObjects finds and returns all WMI Instances in the result set for a WQL query
 func (s *Service) FindReferenceObjects(objPath, className string, targetSlice interface{}) error {
-	wql := fmt.Sprintf("REFERENCES OF {%s} WHERE ResultClass = %s", objPath, className)
+	wql := ReferencesOf(objPath).ResultClass(className).String()
 	return s.FindObjects(wql, targetSlice)
 }
 
@@ -432,6 +312,10 @@ func (s *Service) GetSingletonInstance(className string) (*Instance, error) {
 		return nil, err
 	}
 
+	if instance == nil {
+		return nil, NotFound
+	}
+
 	return instance, nil
 }
 
@@ -0,0 +1,217 @@
+package wmiext
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext/objectpath"
+)
+
+// Attribute keys attached to the spans and metrics of WMI operations.
+const (
+	AttributeOperation   = "wmi.operation"
+	AttributeMethod      = "wmi.method"
+	AttributeClass       = "wmi.class"
+	AttributePath        = "wmi.path"
+	AttributeQuery       = "wmi.query"
+	AttributeReturnValue = "wmi.return_value"
+	AttributeJobState    = "wmi.job.state"
+	AttributeJobPolls    = "wmi.job.polls"
+	AttributeAttempt     = "wmi.retry.attempt"
+	AttributeRetrying    = "wmi.retry.retrying"
+)
+
+// Metric names recorded for WMI operations.
+const (
+	// MetricMethodDuration is the duration of method invocations.
+	MetricMethodDuration = "wmi.method.duration"
+	// MetricQueryDuration is the duration of queries, object retrievals and enumerations.
+	MetricQueryDuration = "wmi.query.duration"
+	// MetricJobDuration is the duration of jobs, from their creation until they finished.
+	MetricJobDuration = "wmi.job.duration"
+	// MetricRetries counts the failed attempts of methods, retried or not.
+	MetricRetries = "wmi.retry.attempts"
+)
+
+// Attribute is a key-value pair describing a span or a measurement.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Attr returns an attribute.
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Tracer starts spans, and can be backed by an OpenTelemetry tracer.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Meter records measurements, and can be backed by OpenTelemetry instruments named after the
+// metric.
+type Meter interface {
+	RecordDuration(ctx context.Context, name string, d time.Duration, attrs ...Attribute)
+	Add(ctx context.Context, name string, delta int64, attrs ...Attribute)
+}
+
+// Logger is a structured logger taking alternating keys and values, which *slog.Logger satisfies.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Telemetry gathers the instrumentation of WMI operations. Nil fields discard what they would
+// receive.
+type Telemetry struct {
+	Tracer Tracer
+	Meter  Meter
+	Logger Logger
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+var (
+	telemetryMu      sync.RWMutex
+	defaultTelemetry = &Telemetry{}
+)
+
+// SetTelemetry replaces the telemetry of the services without one of their own and returns the
+// previous one, so it can be restored afterward.
+func SetTelemetry(telemetry *Telemetry) *Telemetry {
+	telemetryMu.Lock()
+	defer telemetryMu.Unlock()
+
+	previous := defaultTelemetry
+	if telemetry == nil {
+		telemetry = &Telemetry{}
+	}
+	defaultTelemetry = telemetry
+	return previous
+}
+
+// GetTelemetry returns the telemetry of the services without one of their own.
+func GetTelemetry() *Telemetry {
+	telemetryMu.RLock()
+	defer telemetryMu.RUnlock()
+	return defaultTelemetry
+}
+
+// Log returns the logger of the telemetry, discarding everything when unset.
+func (t *Telemetry) Log() Logger {
+	if t == nil || t.Logger == nil {
+		return nopLogger{}
+	}
+	return t.Logger
+}
+
+// Start starts a span, or returns one discarding everything when no tracer is set.
+func (t *Telemetry) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	if t == nil || t.Tracer == nil {
+		return ctx, nopSpan{}
+	}
+	return t.Tracer.Start(ctx, name, attrs...)
+}
+
+// RecordDuration records a duration when a meter is set.
+func (t *Telemetry) RecordDuration(ctx context.Context, name string, d time.Duration, attrs ...Attribute) {
+	if t != nil && t.Meter != nil {
+		t.Meter.RecordDuration(ctx, name, d, attrs...)
+	}
+}
+
+// Add adds to a counter when a meter is set.
+func (t *Telemetry) Add(ctx context.Context, name string, delta int64, attrs ...Attribute) {
+	if t != nil && t.Meter != nil {
+		t.Meter.Add(ctx, name, delta, attrs...)
+	}
+}
+
+// observation is a traced and measured operation of a service.
+type observation struct {
+	telemetry *Telemetry
+	ctx       context.Context
+	span      Span
+	metric    string
+	attrs     []Attribute
+	started   time.Time
+}
+
+func (t *Telemetry) observe(ctx context.Context, operation string, metric string, attrs ...Attribute) *observation {
+	attrs = append([]Attribute{Attr(AttributeOperation, operation)}, attrs...)
+	ctx, span := t.Start(ctx, "wmi."+operation, attrs...)
+	return &observation{telemetry: t, ctx: ctx, span: span, metric: metric, attrs: attrs, started: time.Now()}
+}
+
+// end ends the span and records the duration of the operation along with its outcome.
+func (o *observation) end(err error, attrs ...Attribute) {
+	o.endAfter(time.Since(o.started), err, attrs...)
+}
+
+// endAfter is end for operations measured by another clock.
+func (o *observation) endAfter(elapsed time.Duration, err error, attrs ...Attribute) {
+	o.span.SetAttributes(attrs...)
+	if err != nil {
+		o.span.RecordError(err)
+	}
+	o.span.End()
+
+	all := append(append([]Attribute(nil), o.attrs...), attrs...)
+	o.telemetry.RecordDuration(o.ctx, o.metric, elapsed, all...)
+	if err != nil {
+		o.telemetry.Log().Debug("WMI operation failed", append(attributeArgs(all), "error", err, "elapsed", elapsed)...)
+	} else {
+		o.telemetry.Log().Debug("WMI operation completed", append(attributeArgs(all), "elapsed", elapsed)...)
+	}
+}
+
+func attributeArgs(attrs []Attribute) []interface{} {
+	args := make([]interface{}, 0, 2*len(attrs))
+	for _, attr := range attrs {
+		args = append(args, attr.Key, attr.Value)
+	}
+	return args
+}
+
+// pathAttributes describes the object or class at path.
+func pathAttributes(path string) []Attribute {
+	attrs := []Attribute{Attr(AttributePath, path)}
+	if parsed, err := objectpath.Parse(path); err == nil {
+		attrs = append(attrs, Attr(AttributeClass, parsed.ClassName))
+	}
+	return attrs
+}
+
+// returnValueAttributes describes the return value of a method from its output parameters.
+func returnValueAttributes(outParams Object) []Attribute {
+	if outParams == nil {
+		return nil
+	}
+	value, _, _, err := outParams.Get("ReturnValue")
+	if err != nil || value == nil {
+		return nil
+	}
+	return []Attribute{Attr(AttributeReturnValue, value)}
+}
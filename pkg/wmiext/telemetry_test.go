@@ -0,0 +1,198 @@
+package wmiext
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ Logger = (*slog.Logger)(nil)
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+type recordedMeasurement struct {
+	name     string
+	duration time.Duration
+	delta    int64
+	attrs    []Attribute
+}
+
+// recorder is a Tracer and Meter keeping everything it receives.
+type recorder struct {
+	spans        []*recordedSpan
+	measurements []recordedMeasurement
+}
+
+func (r *recorder) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	span := &recordedSpan{name: name, attrs: map[string]interface{}{}}
+	span.SetAttributes(attrs...)
+	r.spans = append(r.spans, span)
+	return ctx, span
+}
+
+func (r *recorder) RecordDuration(_ context.Context, name string, d time.Duration, attrs ...Attribute) {
+	r.measurements = append(r.measurements, recordedMeasurement{name: name, duration: d, attrs: attrs})
+}
+
+func (r *recorder) Add(_ context.Context, name string, delta int64, attrs ...Attribute) {
+	r.measurements = append(r.measurements, recordedMeasurement{name: name, delta: delta, attrs: attrs})
+}
+
+func (r *recorder) span(name string) *recordedSpan {
+	for _, span := range r.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestService_Telemetry(t *testing.T) {
+	f := newJobFixture(t, JobStateRunning)
+	f.repo.HandleMethod("Msvm_ConcreteJob", "RequestStateChange", func(call *MethodCall) error {
+		call.Return(32775)
+		return nil
+	})
+	rec := &recorder{}
+	f.service.SetTelemetry(&Telemetry{Tracer: rec, Meter: rec})
+
+	job, err := f.service.GetObject(f.path)
+	require.NoError(t, err)
+	defer job.Close()
+	_, err = f.service.FindInstances(Select("Msvm_ConcreteJob").String())
+	require.NoError(t, err)
+	_, err = f.service.GetObject(`Msvm_ConcreteJob.InstanceID="missing"`)
+	require.Error(t, err)
+
+	var returnValue int32
+	require.NoError(t, job.Method("RequestStateChange").In("RequestedState", uint16(4)).Execute().Out("ReturnValue", &returnValue).End())
+	assert.Equal(t, int32(32775), returnValue)
+
+	get := rec.span("wmi.GetObject")
+	require.NotNil(t, get)
+	assert.True(t, get.ended)
+	assert.Equal(t, f.path, get.attrs[AttributePath])
+	assert.Equal(t, "Msvm_ConcreteJob", get.attrs[AttributeClass])
+
+	query := rec.span("wmi.ExecQuery")
+	require.NotNil(t, query)
+	assert.Equal(t, "SELECT * FROM Msvm_ConcreteJob", query.attrs[AttributeQuery])
+
+	var failed *recordedSpan
+	for _, span := range rec.spans {
+		if span.err != nil {
+			failed = span
+		}
+	}
+	require.NotNil(t, failed)
+	assert.Equal(t, `Msvm_ConcreteJob.InstanceID="missing"`, failed.attrs[AttributePath])
+
+	method := rec.span("wmi.ExecMethod")
+	require.NotNil(t, method)
+	assert.True(t, method.ended)
+	assert.Equal(t, "RequestStateChange", method.attrs[AttributeMethod])
+	assert.Equal(t, "Msvm_ConcreteJob", method.attrs[AttributeClass])
+	assert.EqualValues(t, 32775, method.attrs[AttributeReturnValue])
+
+	var names []string
+	for _, m := range rec.measurements {
+		names = append(names, m.name)
+	}
+	assert.Contains(t, names, MetricQueryDuration)
+	assert.Contains(t, names, MetricMethodDuration)
+}
+
+// TestService_SetTelemetryConcurrent is meant for the race detector, the telemetry of a service
+// being replaced while its operations run.
+func TestService_SetTelemetryConcurrent(t *testing.T) {
+	f := newJobFixture(t, JobStateRunning)
+	scoped := f.service.Scope(NewArena())
+	defer scoped.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			f.service.SetTelemetry(&Telemetry{})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_, err := scoped.GetObject(f.path)
+		require.NoError(t, err)
+	}
+	wg.Wait()
+
+	telemetry := &Telemetry{}
+	f.service.SetTelemetry(telemetry)
+	assert.Same(t, telemetry, scoped.Telemetry())
+	f.service.SetTelemetry(nil)
+	assert.Same(t, GetTelemetry(), f.service.Telemetry())
+}
+
+func TestJob_WaitTelemetry(t *testing.T) {
+	f := newJobFixture(t, JobStateRunning)
+	rec := &recorder{}
+	f.service.SetTelemetry(&Telemetry{Tracer: rec, Meter: rec})
+	clock := &scriptedClock{now: time.Unix(1000, 0), steps: []func(){f.set(t, JobStateCompleted, 100)}}
+
+	_, err := f.job(t, WithClock(clock), WithPollStrategy(ConstantPoll(3*time.Second))).Wait(context.Background())
+	require.NoError(t, err)
+
+	span := rec.span("wmi.Job")
+	require.NotNil(t, span)
+	assert.True(t, span.ended)
+	assert.Equal(t, f.path, span.attrs[AttributePath])
+	assert.Equal(t, "Completed", span.attrs[AttributeJobState])
+	assert.Equal(t, 2, span.attrs[AttributeJobPolls])
+
+	var duration *recordedMeasurement
+	for i := range rec.measurements {
+		if rec.measurements[i].name == MetricJobDuration {
+			duration = &rec.measurements[i]
+		}
+	}
+	require.NotNil(t, duration)
+	assert.Equal(t, 3*time.Second, duration.duration)
+}
+
+func TestSetTelemetry(t *testing.T) {
+	rec := &recorder{}
+	previous := SetTelemetry(&Telemetry{Tracer: rec})
+	defer SetTelemetry(previous)
+
+	service := newTestRepository(t).Service()
+	defer service.Close()
+	_, err := service.FindInstances(Select("Msvm_ComputerSystem").String())
+	require.NoError(t, err)
+	assert.NotNil(t, rec.span("wmi.ExecQuery"))
+
+	var unset *Telemetry
+	unset.Log().Error("discarded")
+	_, span := unset.Start(context.Background(), "wmi.ExecQuery")
+	span.End()
+}
@@ -0,0 +1,213 @@
+//go:build windows
+// +build windows
+
+package wmiext
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+// Automation variants do not follow the OLE rules, instead they use the following mapping:
+// sint8	VT_I2	Signed 8-bit integer.
+// sint16	VT_I2	Signed 16-bit integer.
+// sint32	VT_I4	Signed 32-bit integer.
+// sint64	VT_BSTR	Signed 64-bit integer in string form. This type follows hexadecimal or decimal format
+//
+//	according to the American National Standards Institute (ANSI) C rules.
+//
+// real32	VT_R4	4-byte floating-point value that follows the Institute of Electrical and Electronics
+//
+//	Engineers, Inc. (IEEE) standard.
+//
+// real64	VT_R8	8-byte floating-point value that follows the IEEE standard.
+// uint8	VT_UI1	Unsigned 8-bit integer.
+// uint16	VT_I4	Unsigned 16-bit integer.
+// uint32	VT_I4	Unsigned 32-bit integer.
+// uint64	VT_BSTR	Unsigned 64-bit integer in string form. This type follows hexadecimal or decimal format
+//
+//	according to ANSI C rules.
+
+// NewAutomationVariant returns a new VARIANT com
+//
+//gocyclo:ignore
+func NewAutomationVariant(value interface{}) (ole.VARIANT, error) {
+	switch cast := value.(type) {
+	case bool:
+		if cast {
+			return ole.NewVariant(ole.VT_BOOL, 0xffff), nil
+		} else {
+			return ole.NewVariant(ole.VT_BOOL, 0), nil
+		}
+	case int8:
+		return ole.NewVariant(ole.VT_I2, int64(cast)), nil
+	case []int8:
+		return CreateNumericArrayVariant(cast, ole.VT_I2)
+	case int16:
+		return ole.NewVariant(ole.VT_I2, int64(cast)), nil
+	case []int16:
+		return CreateNumericArrayVariant(cast, ole.VT_I2)
+	case int32:
+		return ole.NewVariant(ole.VT_I4, int64(cast)), nil
+	case []int32:
+		return CreateNumericArrayVariant(cast, ole.VT_I4)
+	case int64:
+		s := fmt.Sprintf("%d", cast)
+		return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(s))))), nil
+	case []int64:
+		strs := make([]string, len(cast))
+		for i, num := range cast {
+			strs[i] = fmt.Sprintf("%d", num)
+		}
+		return CreateStringArrayVariant(strs)
+	case float32:
+		return ole.NewVariant(ole.VT_R4, int64(math.Float32bits(cast))), nil
+	case float64:
+		return ole.NewVariant(ole.VT_R8, int64(math.Float64bits(cast))), nil
+	case uint8:
+		return ole.NewVariant(ole.VT_UI1, int64(cast)), nil
+	case []uint8:
+		return CreateNumericArrayVariant(cast, ole.VT_UI1)
+	case uint16:
+		return ole.NewVariant(ole.VT_I4, int64(cast)), nil
+	case []uint16:
+		return CreateNumericArrayVariant(cast, ole.VT_I4)
+	case uint32:
+		return ole.NewVariant(ole.VT_I4, int64(cast)), nil
+	case []uint32:
+		return CreateNumericArrayVariant(cast, ole.VT_I4)
+	case uint64:
+		s := fmt.Sprintf("%d", cast)
+		return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(s))))), nil
+	case []uint64:
+		strs := make([]string, len(cast))
+		for i, num := range cast {
+			strs[i] = fmt.Sprintf("%d", num)
+		}
+		return CreateStringArrayVariant(strs)
+
+	// Assume 32 bit for generic (u)ints
+	case int:
+		return ole.NewVariant(ole.VT_I4, int64(cast)), nil
+	case uint:
+		return ole.NewVariant(ole.VT_I4, int64(cast)), nil
+	case []int:
+		return CreateNumericArrayVariant(cast, ole.VT_I4)
+	case []uint:
+		return CreateNumericArrayVariant(cast, ole.VT_I4)
+
+	case string:
+		return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(value.(string)))))), nil
+	case []string:
+		if len(cast) == 0 {
+			return ole.NewVariant(ole.VT_NULL, 0), nil
+		}
+		return CreateStringArrayVariant(cast)
+	case Reference:
+		return NewAutomationVariant(string(cast))
+
+	case time.Time:
+		return convertTimeToDataTime(&cast), nil
+	case *time.Time:
+		return convertTimeToDataTime(cast), nil
+	case time.Duration:
+		return convertDurationToDateTime(cast), nil
+	case nil:
+		return ole.NewVariant(ole.VT_NULL, 0), nil
+	case *ole.IUnknown:
+		if cast == nil {
+			return ole.NewVariant(ole.VT_NULL, 0), nil
+		}
+		return ole.NewVariant(ole.VT_UNKNOWN, int64(uintptr(unsafe.Pointer(cast)))), nil
+	case *Instance:
+		if cast == nil {
+			return ole.NewVariant(ole.VT_NULL, 0), nil
+		}
+		return NewAutomationVariant(cast.object)
+	case *CimInstance:
+		if cast == nil {
+			return ole.NewVariant(ole.VT_NULL, 0), nil
+		}
+		// Embedded instance parameters are accepted in their CIM-XML form
+		text, err := cast.CimText()
+		if err != nil {
+			return ole.VARIANT{}, err
+		}
+		return NewAutomationVariant(text)
+	case *comObject:
+		if cast == nil {
+			return ole.NewVariant(ole.VT_NULL, 0), nil
+		}
+		return ole.NewVariant(ole.VT_UNKNOWN, int64(uintptr(unsafe.Pointer(cast.object)))), nil
+	default:
+		return ole.VARIANT{}, fmt.Errorf("unsupported type for automation variants %T", value)
+	}
+}
+
+func convertToGenericValue(variant *ole.VARIANT) interface{} {
+	if variant.VT&ole.VT_ARRAY == ole.VT_ARRAY {
+		return convertVariantArrayToGeneric(variant)
+	}
+
+	if variant.VT == ole.VT_UNKNOWN {
+		return convertUnknownToObject(variant.ToIUnknown())
+	}
+
+	return variant.Value()
+}
+
+func convertVariantArrayToGeneric(variant *ole.VARIANT) interface{} {
+	safeArrayConversion := ole.SafeArrayConversion{Array: *(**ole.SafeArray)(unsafe.Pointer(&variant.Val))}
+	elemVT := (^ole.VT_ARRAY) & variant.VT
+	if elemVT != ole.VT_UNKNOWN {
+		return safeArrayConversion.ToValueArray()
+	}
+
+	arrayLen, err := safeArrayConversion.TotalElements(0)
+	if err != nil {
+		return nil
+	}
+
+	values := make([]interface{}, arrayLen)
+	for i := 0; i < int(arrayLen); i++ {
+		var elem *ole.IUnknown
+		if err := safeArrayGetElement(safeArrayConversion.Array, int64(i), unsafe.Pointer(&elem)); err != nil {
+			return values
+		}
+		// SafeArrayGetElement already holds a reference on the element
+		if elem != nil {
+			values[i] = newComObject(elem)
+		}
+	}
+	return values
+}
+
+// convertUnknownToObject wraps an embedded object. The reference is retained so the object outlives
+// the variant it was read from.
+func convertUnknownToObject(unknown *ole.IUnknown) interface{} {
+	if unknown == nil {
+		return nil
+	}
+	unknown.AddRef()
+	return newComObject(unknown)
+}
+
+func convertTimeToDataTime(time *time.Time) ole.VARIANT {
+	s := formatDateTime(time)
+	if s == "" {
+		return ole.NewVariant(ole.VT_NULL, 0)
+	}
+	return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(s)))))
+}
+
+func convertDurationToDateTime(duration time.Duration) ole.VARIANT {
+	s := formatInterval(duration)
+	if s == "" {
+		return ole.NewVariant(ole.VT_NULL, 0)
+	}
+	return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(s)))))
+}
@@ -0,0 +1,39 @@
+//go:build windows
+// +build windows
+
+package wmiext
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	wmiModule syscall.Handle
+)
+
+func init() {
+	file := os.ExpandEnv("${windir}\\system32\\wbem\\wmiutils.dll")
+	wmiModule, _ = syscall.LoadLibrary(file)
+}
+
+func (w *WmiError) Error() string {
+	// ask windows for the remaining errors
+	var flags uint32 = syscall.FORMAT_MESSAGE_FROM_SYSTEM |
+		syscall.FORMAT_MESSAGE_FROM_HMODULE |
+		syscall.FORMAT_MESSAGE_ARGUMENT_ARRAY |
+		syscall.FORMAT_MESSAGE_IGNORE_INSERTS
+
+	buf := make([]uint16, 300)
+	n, err := windows.FormatMessage(flags, uintptr(wmiModule), uint32(w.hres), 0, buf, nil)
+	if err != nil {
+		return fmt.Sprintf("WMI error [%d]: FormatMessage failed with: %v", w.hres, err)
+	}
+
+	return fmt.Sprintf("WMI error [%d]: %s", w.hres, strings.TrimRight(string(utf16.Decode(buf[:n])), "\r\n"))
+}
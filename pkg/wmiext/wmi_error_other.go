@@ -0,0 +1,37 @@
+//go:build !windows
+// +build !windows
+
+package wmiext
+
+import "fmt"
+
+var wmiErrorDescriptions = map[uintptr]string{
+	WBEM_E_FAILED:                 "Generic failure",
+	WBEM_E_NOT_FOUND:              "Not found",
+	WBEM_E_ACCESS_DENIED:          "Access denied",
+	WBEM_E_TYPE_MISMATCH:          "Type mismatch",
+	WBEM_E_INVALID_PARAMETER:      "Invalid parameter",
+	WBEM_E_NOT_SUPPORTED:          "Not supported",
+	WBEM_E_INVALID_NAMESPACE:      "Invalid namespace",
+	WBEM_E_INVALID_CLASS:          "Invalid class",
+	WBEM_E_INVALID_QUERY:          "Invalid query",
+	WBEM_E_INVALID_METHOD:         "Invalid method",
+	WBEM_E_INVALID_OBJECT_PATH:    "Invalid object path",
+	WBEM_E_INVALID_SYNTAX:         "Invalid syntax",
+	WBEM_E_NOT_AVAILABLE:          "Not available",
+	WBEM_E_ALREADY_EXISTS:         "Already exists",
+	WBEM_E_METHOD_NOT_IMPLEMENTED: "Method not implemented",
+	WBEM_E_TIMED_OUT:              "Timed out",
+	WBEM_E_CALL_CANCELLED:         "Call cancelled",
+	WBEM_E_TRANSPORT_FAILURE:      "Transport failure",
+	WBEM_E_CONNECTION_FAILED:      "Connection failed",
+	WBEM_E_PROVIDER_NOT_CAPABLE:   "Provider not capable",
+	WBEM_E_UNPARSABLE_QUERY:       "Unparsable query",
+}
+
+func (w *WmiError) Error() string {
+	if description, ok := wmiErrorDescriptions[w.hres]; ok {
+		return fmt.Sprintf("WMI error [%d]: %s", w.hres, description)
+	}
+	return fmt.Sprintf("WMI error [%d]", w.hres)
+}
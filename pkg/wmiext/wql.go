@@ -0,0 +1,391 @@
+package wmiext
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Condition is a WQL WHERE clause expression, built with Equal, Like, And and the other helpers
+// of this file, which take care of quoting and escaping values.
+type Condition interface {
+	fmt.Stringer
+}
+
+type wqlCondition string
+
+func (c wqlCondition) String() string {
+	return string(c)
+}
+
+type wqlCompound struct {
+	operator   string
+	conditions []Condition
+}
+
+func (c *wqlCompound) String() string {
+	parts := make([]string, 0, len(c.conditions))
+	for _, condition := range c.conditions {
+		if condition == nil {
+			continue
+		}
+		if compound, ok := condition.(*wqlCompound); ok && compound.operator != c.operator && len(compound.conditions) > 1 {
+			parts = append(parts, "("+condition.String()+")")
+		} else {
+			parts = append(parts, condition.String())
+		}
+	}
+	return strings.Join(parts, " "+c.operator+" ")
+}
+
+// Equal matches instances whose property equals value.
+func Equal(property string, value interface{}) Condition {
+	if value == nil {
+		return IsNull(property)
+	}
+	return compare(property, "=", value)
+}
+
+// NotEqual matches instances whose property differs from value.
+func NotEqual(property string, value interface{}) Condition {
+	if value == nil {
+		return IsNotNull(property)
+	}
+	return compare(property, "<>", value)
+}
+
+// Less matches instances whose property is lower than value.
+func Less(property string, value interface{}) Condition {
+	return compare(property, "<", value)
+}
+
+// LessOrEqual matches instances whose property is lower than or equal to value.
+func LessOrEqual(property string, value interface{}) Condition {
+	return compare(property, "<=", value)
+}
+
+// Greater matches instances whose property is greater than value.
+func Greater(property string, value interface{}) Condition {
+	return compare(property, ">", value)
+}
+
+// GreaterOrEqual matches instances whose property is greater than or equal to value.
+func GreaterOrEqual(property string, value interface{}) Condition {
+	return compare(property, ">=", value)
+}
+
+// Like matches string properties against a pattern, where % matches any sequence, _ any single
+// character and [ ] a character set. Use EscapeLike to match a literal value.
+func Like(property string, pattern string) Condition {
+	return wqlCondition(property + " LIKE " + QuoteWQL(pattern))
+}
+
+// IsA matches instances whose embedded object or reference property is of the class or a subclass.
+func IsA(property string, className string) Condition {
+	return wqlCondition(property + " ISA " + QuoteWQL(className))
+}
+
+// IsNull matches instances whose property has no value.
+func IsNull(property string) Condition {
+	return wqlCondition(property + " IS NULL")
+}
+
+// IsNotNull matches instances whose property has a value.
+func IsNotNull(property string) Condition {
+	return wqlCondition(property + " IS NOT NULL")
+}
+
+// And matches instances satisfying all the conditions. Nil conditions are ignored.
+func And(conditions ...Condition) Condition {
+	return combine("AND", conditions)
+}
+
+// Or matches instances satisfying any of the conditions. Nil conditions are ignored.
+func Or(conditions ...Condition) Condition {
+	return combine("OR", conditions)
+}
+
+// Not negates a condition.
+func Not(condition Condition) Condition {
+	return wqlCondition("NOT (" + condition.String() + ")")
+}
+
+func compare(property string, operator string, value interface{}) Condition {
+	return wqlCondition(property + " " + operator + " " + FormatWQLValue(value))
+}
+
+func combine(operator string, conditions []Condition) Condition {
+	var filtered []Condition
+	for _, condition := range conditions {
+		if condition != nil {
+			filtered = append(filtered, condition)
+		}
+	}
+
+	switch len(filtered) {
+	case 0:
+		return nil
+	case 1:
+		return filtered[0]
+	default:
+		return &wqlCompound{operator: operator, conditions: filtered}
+	}
+}
+
+// QuoteWQL returns s as a WQL string literal, escaping quotes and backslashes.
+func QuoteWQL(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// EscapeLike escapes the LIKE wildcards of s, so it matches literally in a Like pattern.
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`[`, `[[]`, `%`, `[%]`, `_`, `[_]`).Replace(s)
+}
+
+// FormatWQLValue formats a Golang value as a WQL literal.
+func FormatWQLValue(value interface{}) string {
+	switch cast := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return QuoteWQL(cast)
+	case Reference:
+		return QuoteWQL(string(cast))
+	case bool:
+		if cast {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		return QuoteWQL(formatDateTime(&cast))
+	case *time.Time:
+		return QuoteWQL(formatDateTime(cast))
+	case time.Duration:
+		return QuoteWQL(formatInterval(cast))
+	}
+
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(val.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(val.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(val.Float(), 'g', -1, 64)
+	case reflect.Bool:
+		return FormatWQLValue(val.Bool())
+	case reflect.String:
+		return QuoteWQL(val.String())
+	case reflect.Pointer:
+		if val.IsNil() {
+			return "NULL"
+		}
+		return FormatWQLValue(val.Elem().Interface())
+	default:
+		return QuoteWQL(fmt.Sprintf("%v", value))
+	}
+}
+
+// SelectQuery is a WQL data query, SELECT ... FROM ... [WHERE ...].
+type SelectQuery struct {
+	className  string
+	properties []string
+	where      Condition
+}
+
+// Select starts a query for instances of a class, retrieving the listed properties or all of them when
+// none are specified.
+func Select(className string, properties ...string) *SelectQuery {
+	return &SelectQuery{className: className, properties: properties}
+}
+
+// Where restricts the query to the instances matching the condition. Calling Where multiple times
+// requires all conditions to match.
+func (q *SelectQuery) Where(condition Condition) *SelectQuery {
+	q.where = And(q.where, condition)
+	return q
+}
+
+// String returns the WQL text of the query.
+func (q *SelectQuery) String() string {
+	properties := "*"
+	if len(q.properties) > 0 {
+		properties = strings.Join(q.properties, ", ")
+	}
+
+	wql := "SELECT " + properties + " FROM " + q.className
+	if q.where != nil {
+		wql += " WHERE " + q.where.String()
+	}
+	return wql
+}
+
+// AssociationQuery is a WQL schema query, ASSOCIATORS OF {path} or REFERENCES OF {path}.
+type AssociationQuery struct {
+	references    bool
+	objectPath    string
+	assocClass    string
+	resultClass   string
+	role          string
+	resultRole    string
+	classDefsOnly bool
+	keysOnly      bool
+}
+
+// AssociatorsOf starts a query for the instances associated to the object at path.
+func AssociatorsOf(objectPath string) *AssociationQuery {
+	return &AssociationQuery{objectPath: objectPath}
+}
+
+// ReferencesOf starts a query for the association instances referring to the object at path.
+func ReferencesOf(objectPath string) *AssociationQuery {
+	return &AssociationQuery{references: true, objectPath: objectPath}
+}
+
+// AssocClass only follows associations of the class or its subclasses. It is ignored by REFERENCES OF,
+// where ResultClass selects the association class.
+func (q *AssociationQuery) AssocClass(className string) *AssociationQuery {
+	q.assocClass = className
+	return q
+}
+
+// ResultClass only returns instances of the class or its subclasses.
+func (q *AssociationQuery) ResultClass(className string) *AssociationQuery {
+	q.resultClass = className
+	return q
+}
+
+// Role only follows associations where the source object is referred to by the property.
+func (q *AssociationQuery) Role(property string) *AssociationQuery {
+	q.role = property
+	return q
+}
+
+// ResultRole only returns instances referred to by the property of the association. It is ignored by
+// REFERENCES OF.
+func (q *AssociationQuery) ResultRole(property string) *AssociationQuery {
+	q.resultRole = property
+	return q
+}
+
+// ClassDefsOnly returns the class definitions instead of the instances.
+func (q *AssociationQuery) ClassDefsOnly() *AssociationQuery {
+	q.classDefsOnly = true
+	return q
+}
+
+// KeysOnly only populates the key properties of the returned instances.
+func (q *AssociationQuery) KeysOnly() *AssociationQuery {
+	q.keysOnly = true
+	return q
+}
+
+// String returns the WQL text of the query.
+func (q *AssociationQuery) String() string {
+	var sb strings.Builder
+	if q.references {
+		sb.WriteString("REFERENCES OF {")
+	} else {
+		sb.WriteString("ASSOCIATORS OF {")
+	}
+	sb.WriteString(q.objectPath)
+	sb.WriteString("}")
+
+	var clauses []string
+	if q.assocClass != "" && !q.references {
+		clauses = append(clauses, "AssocClass = "+q.assocClass)
+	}
+	if q.resultClass != "" {
+		clauses = append(clauses, "ResultClass = "+q.resultClass)
+	}
+	if q.role != "" {
+		clauses = append(clauses, "Role = "+q.role)
+	}
+	if q.resultRole != "" && !q.references {
+		clauses = append(clauses, "ResultRole = "+q.resultRole)
+	}
+	if q.classDefsOnly {
+		clauses = append(clauses, "ClassDefsOnly")
+	}
+	if q.keysOnly {
+		clauses = append(clauses, "KeysOnly")
+	}
+
+	if len(clauses) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(clauses, " "))
+	}
+	return sb.String()
+}
+
+// Intrinsic event classes reported by WMI for changes of instances.
+const (
+	InstanceCreationEvent     = "__InstanceCreationEvent"
+	InstanceModificationEvent = "__InstanceModificationEvent"
+	InstanceDeletionEvent     = "__InstanceDeletionEvent"
+	// InstanceOperationEvent is the superclass of the creation, modification and deletion events.
+	InstanceOperationEvent = "__InstanceOperationEvent"
+)
+
+// EventQuery is a WQL event query for the intrinsic events of the instances of a class,
+// SELECT * FROM event WITHIN interval WHERE TargetInstance ISA class [AND ...].
+type EventQuery struct {
+	eventClass string
+	className  string
+	within     time.Duration
+	where      Condition
+}
+
+// InstanceEvents starts a query for the events of the event class, such as
+// InstanceModificationEvent, concerning the instances of the class or its subclasses.
+func InstanceEvents(eventClass string, className string) *EventQuery {
+	return &EventQuery{eventClass: eventClass, className: className}
+}
+
+// Within sets the interval at which WMI polls the providers without native events for changes, which
+// is the case of Hyper-V. It is rounded up to the second.
+func (q *EventQuery) Within(interval time.Duration) *EventQuery {
+	q.within = interval
+	return q
+}
+
+// Where restricts the query to the events matching the condition, with properties qualified by the
+// TargetInstance or PreviousInstance embedded object, e.g. TargetInstance.Name. Calling Where
+// multiple times requires all conditions to match.
+func (q *EventQuery) Where(condition Condition) *EventQuery {
+	q.where = And(q.where, condition)
+	return q
+}
+
+// String returns the WQL text of the query.
+func (q *EventQuery) String() string {
+	wql := "SELECT * FROM " + q.eventClass
+	if q.within > 0 {
+		seconds := (q.within + time.Second - 1) / time.Second
+		wql += " WITHIN " + strconv.FormatInt(int64(seconds), 10)
+	}
+	wql += " WHERE " + And(IsA("TargetInstance", q.className), q.where).String()
+	return wql
+}
+
+// Query runs a query and decodes every resulting instance into a new T, following the conventions
+// of Instance.GetAll.
+func Query[T any](s *Service, query fmt.Stringer) ([]*T, error) {
+	var results []*T
+	if err := s.FindObjects(query.String(), &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// QueryFirst runs a query and decodes the first resulting instance into a new T. It returns NotFound
+// when the query has no results.
+func QueryFirst[T any](s *Service, query fmt.Stringer) (*T, error) {
+	result := new(T)
+	if err := s.FindFirstObject(query.String(), result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
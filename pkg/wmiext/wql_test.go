@@ -0,0 +1,86 @@
+package wmiext
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectQuery(t *testing.T) {
+	tests := []struct {
+		query fmt.Stringer
+		wql   string
+	}{
+		{Select("Msvm_ComputerSystem"), `SELECT * FROM Msvm_ComputerSystem`},
+		{Select("Msvm_ComputerSystem", "Name", "ElementName"), `SELECT Name, ElementName FROM Msvm_ComputerSystem`},
+		{
+			Select("Msvm_ComputerSystem").Where(Equal("ElementName", `vm's \ "x"`)),
+			`SELECT * FROM Msvm_ComputerSystem WHERE ElementName = 'vm\'s \\ "x"'`,
+		},
+		{
+			Select("Msvm_ComputerSystem").Where(NotEqual("Caption", "Hosting Computer System")).Where(Equal("EnabledState", uint16(2))),
+			`SELECT * FROM Msvm_ComputerSystem WHERE Caption <> 'Hosting Computer System' AND EnabledState = 2`,
+		},
+		{
+			Select("Msvm_ComputerSystem").Where(And(Or(Equal("EnabledState", 2), Greater("EnabledState", 3)), Not(Equal("Virtual", false)))),
+			`SELECT * FROM Msvm_ComputerSystem WHERE (EnabledState = 2 OR EnabledState > 3) AND NOT (Virtual = FALSE)`,
+		},
+		{
+			Select("Msvm_ComputerSystem").Where(Or(Equal("Description", nil), And(nil, LessOrEqual("OnTimeInMilliseconds", uint64(1<<40))))),
+			`SELECT * FROM Msvm_ComputerSystem WHERE Description IS NULL OR OnTimeInMilliseconds <= 1099511627776`,
+		},
+		{
+			Select("Msvm_ComputerSystem").Where(Like("ElementName", EscapeLike("100%_[a]")+"%")),
+			`SELECT * FROM Msvm_ComputerSystem WHERE ElementName LIKE '100[%][_][[]a]%'`,
+		},
+		{Select("Msvm_ComputerSystem").Where(And()), `SELECT * FROM Msvm_ComputerSystem`},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.wql, test.query.String())
+	}
+}
+
+func TestAssociationQuery(t *testing.T) {
+	const path = `Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="A0B1"`
+
+	assert.Equal(t, `ASSOCIATORS OF {`+path+`}`, AssociatorsOf(path).String())
+	assert.Equal(t,
+		`ASSOCIATORS OF {`+path+`} WHERE AssocClass = Msvm_SettingsDefineState ResultClass = Msvm_VirtualSystemSettingData Role = ManagedElement ResultRole = SettingData`,
+		AssociatorsOf(path).AssocClass("Msvm_SettingsDefineState").ResultClass("Msvm_VirtualSystemSettingData").
+			Role("ManagedElement").ResultRole("SettingData").String())
+	assert.Equal(t,
+		`REFERENCES OF {`+path+`} WHERE ResultClass = Msvm_SettingsDefineState KeysOnly`,
+		ReferencesOf(path).AssocClass("ignored").ResultClass("Msvm_SettingsDefineState").ResultRole("ignored").KeysOnly().String())
+	assert.Equal(t, `ASSOCIATORS OF {`+path+`} WHERE ClassDefsOnly`, AssociatorsOf(path).ClassDefsOnly().String())
+}
+
+func TestQuery(t *testing.T) {
+	service := newTestRepository(t).Service()
+	defer service.Close()
+
+	systems, err := Query[testSystem](service, Select("Msvm_ComputerSystem").Where(Equal("ElementName", `vm's "two"`)))
+	require.NoError(t, err)
+	require.Len(t, systems, 1)
+	assert.Equal(t, "C2D3", systems[0].Name)
+
+	system, err := QueryFirst[testSystem](service, Select("CIM_ComputerSystem").Where(Equal("EnabledState", 2)))
+	require.NoError(t, err)
+	assert.Equal(t, "A0B1", system.Name)
+
+	_, err = QueryFirst[testSystem](service, Select("Msvm_ComputerSystem").Where(Equal("ElementName", "missing")))
+	assert.ErrorIs(t, err, NotFound)
+}
+
+func TestEventQuery(t *testing.T) {
+	assert.Equal(t,
+		`SELECT * FROM __InstanceOperationEvent WHERE TargetInstance ISA 'Msvm_ComputerSystem'`,
+		InstanceEvents(InstanceOperationEvent, "Msvm_ComputerSystem").String())
+	assert.Equal(t,
+		`SELECT * FROM __InstanceModificationEvent WITHIN 2 WHERE TargetInstance ISA 'Msvm_ConcreteJob' AND TargetInstance.JobState >= 7 AND PreviousInstance.JobState < 7`,
+		InstanceEvents(InstanceModificationEvent, "Msvm_ConcreteJob").Within(1500*time.Millisecond).
+			Where(GreaterOrEqual("TargetInstance.JobState", 7)).Where(Less("PreviousInstance.JobState", 7)).String())
+}
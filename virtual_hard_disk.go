@@ -29,6 +29,8 @@ type VirtualHardDisk struct {
 	Path        string              `json:"path"`
 	Attached    bool                `json:"attached"`
 	*disk.VirtualHardDisk
+	// client performs the operations of the virtual hard disk, the default client when nil.
+	client *Client
 }
 
 func fileName(path string) string {
@@ -53,10 +55,10 @@ func existsVirtualHardDiskByPath(path string) (exists bool) {
 	return true
 }
 
-func getVirtualHardDiskMaxSize(path string) (maxSizeGiB uint64, err error) {
+func getVirtualHardDiskMaxSize(c *Client, path string) (maxSizeGiB uint64, err error) {
 	var ims *storage.ImageManagementService
 	var vhdSettingData *storage.VirtualHardDiskSettingData
-	if ims, err = storage.LocalImageManagementService(); err != nil {
+	if ims, err = c.ImageManagementService(); err != nil {
 		return
 	}
 	if vhdSettingData, err = ims.GetVirtualHardDiskSettingData(path); err != nil {
@@ -69,7 +71,7 @@ func (vhd *VirtualHardDisk) Detach() (err error) {
 	if !vhd.Attached {
 		return errors.New("vhd not attached")
 	}
-	vmms, err := virtual_system.LocalVirtualSystemManagementService()
+	vmms, err := virtualSystemManagementService(vhd.client)
 	if err != nil {
 		return
 	}
@@ -83,6 +85,10 @@ func (vhd *VirtualHardDisk) Detach() (err error) {
 
 func (vm *VirtualMachine) GetVirtualHardDisks() ([]*VirtualHardDisk, error) {
 	var virtualHardDisks []*VirtualHardDisk
+	c, err := clientOr(vm.client)
+	if err != nil {
+		return nil, err
+	}
 	settingData, err := vm.computerSystem.GetVirtualSystemSettingData()
 	if err != nil {
 		return nil, err
@@ -94,7 +100,7 @@ func (vm *VirtualMachine) GetVirtualHardDisks() ([]*VirtualHardDisk, error) {
 	for _, storageAllocationSettingData := range storageAllocationSettingDatas {
 		hardDisk := disk.VirtualHardDisk{StorageAllocationSettingData: storageAllocationSettingData}
 		path := hardDisk.HostResource[0]
-		virtualHardDisk, err := GetVirtualHardDiskByPath(path)
+		virtualHardDisk, err := c.GetVirtualHardDiskByPath(path)
 		if err != nil {
 			return nil, err
 		}
@@ -125,14 +131,14 @@ func (vhd *VirtualHardDisk) Create() (err error) {
 	if existsVirtualHardDiskByPath(vhd.Path) {
 		return errors.New("VirtualHardDisk exists")
 	}
-	if mgmt, err = storage.LocalImageManagementService(); err != nil {
+	if mgmt, err = imageManagementService(vhd.client); err != nil {
 		return
 	}
 	vhdSettingData, err := mgmt.NewVirtualHardDiskSettingData(vhd.Path, 512, 512, 0, 1024*1024*1024*uint64(vhd.TotalSizeGB), true, storage.VirtualHardDiskFormat_2)
 	if err != nil {
 		return
 	}
-	if err = mgmt.CreateVirtualHardDisk(vhdSettingData); err != nil {
+	if err = wmiext.AwaitJob(mgmt.CreateVirtualHardDisk(vhdSettingData)); err != nil {
 		return
 	}
 	vhd.Name = fileName(vhd.Path)
@@ -154,7 +160,11 @@ func (vhd *VirtualHardDisk) Create() (err error) {
 //   bool: 是否挂载成功
 //   error: 错误
 func (vhd *VirtualHardDisk) AttachToByName(vmName string) (ok bool, err error) {
-	virtualMachine, err := FirstVirtualMachineByName(vmName)
+	c, err := clientOr(vhd.client)
+	if err != nil {
+		return false, err
+	}
+	virtualMachine, err := c.FirstVirtualMachineByName(vmName, FieldsBasic)
 	if err != nil {
 		return false, err
 	}
@@ -165,19 +175,21 @@ func (vhd *VirtualHardDisk) AttachTo(virtualMachine *VirtualMachine) (ok bool, e
 	return vhd.AttachAsDataDisk(virtualMachine)
 }
 
+// ensureSCSIController adds a SCSI controller to the virtual machine unless it has one.
+func ensureSCSIController(vmms *virtual_system.VirtualSystemManagementService, virtualMachine *VirtualMachine) error {
+	controllers, err := virtualMachine.computerSystem.GetSCSIControllers()
+	if errors.Is(err, wmiext.NotFound) || (err == nil && len(controllers) == 0) {
+		return vmms.AddSCSIController(virtualMachine.computerSystem)
+	}
+	return err
+}
+
 func (vhd *VirtualHardDisk) AttachAsDataDisk(virtualMachine *VirtualMachine) (ok bool, err error) {
-	var (
-		controllers []*resource.ResourceAllocationSettingData
-	)
-	vmms, err := virtual_system.LocalVirtualSystemManagementService()
+	vmms, err := virtualSystemManagementService(vhd.client)
 	if err != nil {
 		return false, err
 	}
-	if controllers, err = virtualMachine.computerSystem.GetSCSIControllers(); errors.Is(err, wmiext.NotFound) || len(controllers) == 0 {
-		if err = vmms.AddSCSIController(virtualMachine.computerSystem); err != nil {
-			return false, err
-		}
-	} else if err != nil {
+	if err = ensureSCSIController(vmms, virtualMachine); err != nil {
 		return false, err
 	}
 	vhd.VirtualHardDisk, _, err = vmms.AttachVirtualHardDisk(virtualMachine.computerSystem, vhd.Path, virtual_system.VirtualHardDiskType_DATADISK_VIRTUALHARDDISK)
@@ -185,48 +197,74 @@ func (vhd *VirtualHardDisk) AttachAsDataDisk(virtualMachine *VirtualMachine) (ok
 		return
 	}
 	vhd.Attached = true
+	virtualMachine.invalidate(FieldsDisks)
 	return true, nil
 }
 
+// AttachAsSystemDisk 挂载为系统盘, 第一代虚拟机挂载到 IDE 控制器, 第二代虚拟机挂载到 SCSI 控制器
 func (vhd *VirtualHardDisk) AttachAsSystemDisk(virtualMachine *VirtualMachine) (ok bool, err error) {
-	vmms, err := virtual_system.LocalVirtualSystemManagementService()
+	vmms, err := virtualSystemManagementService(vhd.client)
+	if err != nil {
+		return false, err
+	}
+	generation, err := virtualMachine.computerSystem.GetVirtualMachineGeneration()
 	if err != nil {
 		return false, err
 	}
+	// Generation 2 systems have no IDE controller
+	if generation == virtual_system.HyperVGeneration_V2 {
+		if err = ensureSCSIController(vmms, virtualMachine); err != nil {
+			return false, err
+		}
+	}
 	vhd.VirtualHardDisk, _, err = vmms.AttachVirtualHardDisk(virtualMachine.computerSystem, vhd.Path, virtual_system.VirtualHardDiskType_OS_VIRTUALHARDDISK)
 	if err != nil {
 		return
 	}
 	vhd.Attached = true
+	virtualMachine.invalidate(FieldsDisks)
 	return true, nil
 }
 
 // Resize 调整虚拟硬盘大小
-// 
+//
+// 属于检查点差异磁盘链的虚拟硬盘, 即差异磁盘 (avhdx) 及其父磁盘, 无法调整大小, 返回 ErrVirtualHardDiskChain,
+// 需要先删除检查点
+//
 // 参数:
 //   newSizeGiB: 新的虚拟硬盘大小 (GiB)
 // 返回:
 //   bool: 是否调整成功
 //   error: 错误
 func (vhd *VirtualHardDisk) Resize(newSizeGiB float64) (ok bool, err error) {
-	ims, err := storage.LocalImageManagementService()
+	ims, err := imageManagementService(vhd.client)
 	if err != nil {
 		return false, err
 	}
-	// TODO: check if there are snapshots, if there are, then will resize the snapshot
-	//if snapshots, err := ims.GetSnapshotVirtualHardDisks(vhd.VirtualHardDisk); err != nil {
-	//	return false, err
-	//} else if len(snapshots) > 0 {
-	//	if len(snapshots) == 1 {
-	//
-	//	}
-	//	return false, errors.New("cannot resize a virtual hard disk that has many snapshots")
-	//}
+	// The differencing disks of the checkpoints record the size of their parent, resizing any disk
+	// of a chain breaks it
+	chain, err := ims.GetVirtualHardDiskChain(vhd.Path)
+	if err != nil {
+		return false, err
+	}
+	if len(chain) > 1 {
+		return false, errors.Wrapf(ErrVirtualHardDiskChain, "%s is a differencing disk of %s", vhd.Path, chain[1].Path)
+	}
+	descendants, err := ims.GetDescendantVirtualHardDisks(vhd.Path)
+	if err != nil {
+		return false, err
+	}
+	for _, descendant := range descendants {
+		descendant.Close()
+	}
+	if len(descendants) > 0 {
+		return false, errors.Wrapf(ErrVirtualHardDiskChain, "%s is the parent of %s", vhd.Path, descendants[0].GetPath())
+	}
 
 	if newSizeGiB <= vhd.UsedSizeGB {
 		return false, errors.New("new size must be greater than used size")
 	}
-	err = ims.ResizeVirtualHardDisk(vhd.Path, uint64(newSizeGiB)*1024*1024*1024)
+	err = wmiext.AwaitJob(ims.ResizeVirtualHardDisk(vhd.Path, uint64(newSizeGiB)*1024*1024*1024))
 	if err != nil {
 		return false, err
 	}
@@ -241,13 +279,13 @@ func (vhd *VirtualHardDisk) Resize(newSizeGiB float64) (ok bool, err error) {
 // 返回:
 //   *VirtualHardDisk: 虚拟硬盘
 //   error: 错误
-func GetVirtualHardDiskByPath(path string) (*VirtualHardDisk, error) {
+func (c *Client) GetVirtualHardDiskByPath(path string) (*VirtualHardDisk, error) {
 	if !existsVirtualHardDiskByPath(path) {
 		return nil, errors.New("vhd not exists")
 	}
 	var virtualHardDisk *disk.VirtualHardDisk
 
-	vsms, err := virtual_system.LocalVirtualSystemManagementService()
+	vsms, err := c.VirtualSystemManagementService()
 	if err != nil {
 		return nil, err
 	}
@@ -258,7 +296,7 @@ func GetVirtualHardDiskByPath(path string) (*VirtualHardDisk, error) {
 		return nil, err
 	}
 
-	maxSizeGB, err := getVirtualHardDiskMaxSize(path)
+	maxSizeGB, err := getVirtualHardDiskMaxSize(c, path)
 	if err != nil {
 		return nil, err
 	}
@@ -276,9 +314,19 @@ func GetVirtualHardDiskByPath(path string) (*VirtualHardDisk, error) {
 		TotalSizeGB:     float64(maxSizeGB),
 		Attached:        virtualHardDisk != nil,
 		VirtualHardDisk: virtualHardDisk,
+		client:          c,
 	}, nil
 }
 
+// GetVirtualHardDiskByPath is Client.GetVirtualHardDiskByPath on the default client.
+func GetVirtualHardDiskByPath(path string) (*VirtualHardDisk, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.GetVirtualHardDiskByPath(path)
+}
+
 // CreateVirtualHardDisk 创建虚拟硬盘
 // 
 // 参数:
@@ -287,10 +335,11 @@ func GetVirtualHardDiskByPath(path string) (*VirtualHardDisk, error) {
 // 返回:
 //   *VirtualHardDisk: 虚拟硬盘
 //   error: 错误
-func CreateVirtualHardDisk(path string, sizeGiB float64) (vhd *VirtualHardDisk, err error) {
+func (c *Client) CreateVirtualHardDisk(path string, sizeGiB float64) (vhd *VirtualHardDisk, err error) {
 	vhd = &VirtualHardDisk{
 		Path:        path,
 		TotalSizeGB: sizeGiB,
+		client:      c,
 	}
 	if err = vhd.Create(); err != nil {
 		return nil, err
@@ -298,6 +347,15 @@ func CreateVirtualHardDisk(path string, sizeGiB float64) (vhd *VirtualHardDisk,
 	return vhd, nil
 }
 
+// CreateVirtualHardDisk is Client.CreateVirtualHardDisk on the default client.
+func CreateVirtualHardDisk(path string, sizeGiB float64) (*VirtualHardDisk, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.CreateVirtualHardDisk(path, sizeGiB)
+}
+
 // DeleteVirtualHardDiskByPath 根据路径删除虚拟硬盘
 // 
 // 参数:
@@ -314,3 +372,9 @@ func DeleteVirtualHardDiskByPath(path string) (ok bool, err error) {
 	}
 	return true, nil
 }
+
+// DeleteVirtualHardDiskByPath removes the virtual hard disk file at path, like the package-level
+// function which does not need a WMI session.
+func (c *Client) DeleteVirtualHardDiskByPath(path string) (bool, error) {
+	return DeleteVirtualHardDiskByPath(path)
+}
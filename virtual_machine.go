@@ -1,10 +1,10 @@
 package hyperv
 
 import (
-	"log"
 	"os"
 
 	"github.com/pkg/errors"
+	hverrors "github.com/rokukoo/hyperv/pkg/hypervsdk/errors"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/memory"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/processor"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
@@ -17,9 +17,13 @@ const (
 	StateRunning VirtualMachineState = virtual_system.Running
 	StateStopped VirtualMachineState = virtual_system.Off
 	StateSuspend VirtualMachineState = virtual_system.Saved
+	StateUnknown VirtualMachineState = virtual_system.Unknown
 )
 
 // https://learn.microsoft.com/zh-cn/windows/win32/hyperv_v2/msvm-computersystem
+//
+// Description、SavePath、CpuCoreCount 与 MemorySizeMB 仅在加载 FieldsHardware 后有效, 未加载时为零值,
+// 不确定是否已加载时使用 Hardware
 type VirtualMachine struct {
 	Name           string `json:"name"`
 	Description    string `json:"description"`
@@ -27,6 +31,44 @@ type VirtualMachine struct {
 	CpuCoreCount   int    `json:"cpu_core_count"`
 	MemorySizeMB   int    `json:"memory_size"`
 	computerSystem *virtual_system.ComputerSystem
+	// client performs the operations of the virtual machine, the default client when nil.
+	client *Client
+
+	// loaded are the fields loaded since the last Refresh
+	loaded                 VirtualMachineFields
+	virtualHardDisks       []*VirtualHardDisk
+	virtualNetworkAdapters []*VirtualNetworkAdapter
+}
+
+// VirtualMachineFields 虚拟机字段选择器, 指定查找虚拟机时加载的字段, 可按位组合
+//
+// 名称总是加载, 状态总是实时获取. 未加载的字段可以通过 VirtualMachine.Load 或对应的方法按需加载
+type VirtualMachineFields uint
+
+const (
+	// FieldsHardware 描述、保存路径、CPU 核心数与内存大小
+	FieldsHardware VirtualMachineFields = 1 << iota
+	// FieldsDisks 虚拟硬盘, 见 VirtualMachine.VirtualHardDisks
+	FieldsDisks
+	// FieldsNetworkAdapters 网络适配器, 见 VirtualMachine.VirtualNetworkAdapters
+	FieldsNetworkAdapters
+
+	// FieldsBasic 仅名称
+	FieldsBasic VirtualMachineFields = 0
+	// FieldsAll 全部字段
+	FieldsAll = FieldsHardware | FieldsDisks | FieldsNetworkAdapters
+)
+
+// selectedFields returns the fields selected by a lookup, FieldsHardware when none are.
+func selectedFields(fields []VirtualMachineFields) VirtualMachineFields {
+	if len(fields) == 0 {
+		return FieldsHardware
+	}
+	var selected VirtualMachineFields
+	for _, f := range fields {
+		selected |= f
+	}
+	return selected
 }
 
 // Start 启动虚拟机
@@ -64,15 +106,19 @@ func (vm *VirtualMachine) Resume() error {
 	return vm.computerSystem.Resume()
 }
 
-// State 获取虚拟机实时状态
-func (vm *VirtualMachine) State() VirtualMachineState {
-	var err error
-	var state VirtualMachineState
+// GetState 获取虚拟机实时状态
+func (vm *VirtualMachine) GetState() (VirtualMachineState, error) {
+	return vm.computerSystem.GetState()
+}
 
-	if state, err = vm.computerSystem.GetState(); err != nil {
-		log.Fatalf("failed to get vm state: %v", err)
+// State 获取虚拟机实时状态, 获取失败时记录日志并返回 StateUnknown, 需要处理错误时使用 GetState
+func (vm *VirtualMachine) State() VirtualMachineState {
+	state, err := vm.GetState()
+	if err != nil {
+		vm.computerSystem.GetService().Telemetry().Log().Error("Unable to get the virtual machine state",
+			"name", vm.Name, "error", err)
+		return StateUnknown
 	}
-
 	return state
 }
 
@@ -83,30 +129,144 @@ func (vm *VirtualMachine) Suspend() error {
 	return vm.computerSystem.Save()
 }
 
-func (vm *VirtualMachine) update(cs *virtual_system.ComputerSystem) error {
-	var err error
-	var virtualSystemSettingData *virtual_system.VirtualSystemSettingData
-
+func (vm *VirtualMachine) update(cs *virtual_system.ComputerSystem, fields VirtualMachineFields) error {
 	vm.computerSystem = cs
+	vm.Name = cs.ElementName
+	vm.loaded = FieldsBasic
+	vm.Description, vm.SavePath, vm.CpuCoreCount, vm.MemorySizeMB = "", "", 0, 0
+	vm.virtualHardDisks, vm.virtualNetworkAdapters = nil, nil
+	return vm.Load(fields)
+}
 
-	if virtualSystemSettingData, err = vm.computerSystem.GetVirtualSystemSettingData(); err != nil {
+// Load 加载尚未加载的字段, 已加载的字段保持不变, 需要重新获取时使用 Refresh
+func (vm *VirtualMachine) Load(fields VirtualMachineFields) (err error) {
+	missing := fields &^ vm.loaded
+	if missing&FieldsHardware != 0 {
+		if err = vm.loadHardware(); err != nil {
+			return
+		}
+	}
+	if missing&FieldsDisks != 0 {
+		if vm.virtualHardDisks, err = vm.GetVirtualHardDisks(); err != nil {
+			return
+		}
+		vm.loaded |= FieldsDisks
+	}
+	if missing&FieldsNetworkAdapters != 0 {
+		if vm.virtualNetworkAdapters, err = vm.GetVirtualNetworkAdapters(); err != nil {
+			return
+		}
+		vm.loaded |= FieldsNetworkAdapters
+	}
+	return nil
+}
+
+// Loaded 判断字段是否均已加载
+func (vm *VirtualMachine) Loaded(fields VirtualMachineFields) bool {
+	return fields&^vm.loaded == 0
+}
+
+// Refresh 重新获取虚拟机, 并重新加载已加载的字段
+func (vm *VirtualMachine) Refresh() error {
+	if err := vm.computerSystem.Refresh(); err != nil {
 		return err
 	}
+	return vm.update(vm.computerSystem, vm.loaded)
+}
 
-	vm.Name = cs.ElementName
-	vm.Description = virtualSystemSettingData.Notes[0]
-	vm.SavePath = virtualSystemSettingData.ConfigurationDataRoot
+// invalidate drops cached fields changed by an operation, so that they are loaded again when next used.
+func (vm *VirtualMachine) invalidate(fields VirtualMachineFields) {
+	vm.loaded &^= fields
+	if fields&FieldsDisks != 0 {
+		vm.virtualHardDisks = nil
+	}
+	if fields&FieldsNetworkAdapters != 0 {
+		vm.virtualNetworkAdapters = nil
+	}
+}
 
-	vm.CpuCoreCount = int(vm.computerSystem.MustGetProcessorSettingData().VirtualQuantity)
-	vm.MemorySizeMB = int(vm.computerSystem.MustGetMemorySettingData().VirtualQuantity)
+// VirtualHardDisks 获取虚拟机的虚拟硬盘, 首次调用时加载并缓存, 需要实时获取时使用 GetVirtualHardDisks
+func (vm *VirtualMachine) VirtualHardDisks() ([]*VirtualHardDisk, error) {
+	if err := vm.Load(FieldsDisks); err != nil {
+		return nil, err
+	}
+	return vm.virtualHardDisks, nil
+}
 
+// VirtualNetworkAdapters 获取虚拟机的网络适配器, 首次调用时加载并缓存, 需要实时获取时使用 GetVirtualNetworkAdapters
+func (vm *VirtualMachine) VirtualNetworkAdapters() ([]*VirtualNetworkAdapter, error) {
+	if err := vm.Load(FieldsNetworkAdapters); err != nil {
+		return nil, err
+	}
+	return vm.virtualNetworkAdapters, nil
+}
+
+// VirtualMachineHardware 虚拟机的硬件字段, 即 FieldsHardware 加载的字段
+type VirtualMachineHardware struct {
+	Description  string
+	SavePath     string
+	CpuCoreCount int
+	MemorySizeMB int
+}
+
+// Hardware 获取虚拟机的描述、保存路径、CPU 核心数与内存大小, 首次调用时加载并缓存, 需要重新获取时使用 Refresh
+func (vm *VirtualMachine) Hardware() (VirtualMachineHardware, error) {
+	if err := vm.Load(FieldsHardware); err != nil {
+		return VirtualMachineHardware{}, err
+	}
+	return VirtualMachineHardware{
+		Description:  vm.Description,
+		SavePath:     vm.SavePath,
+		CpuCoreCount: vm.CpuCoreCount,
+		MemorySizeMB: vm.MemorySizeMB,
+	}, nil
+}
+
+func (vm *VirtualMachine) loadHardware() error {
+	virtualSystemSettingData, err := vm.computerSystem.GetVirtualSystemSettingData()
+	if err != nil {
+		return err
+	}
+	processorSettingData, err := vm.computerSystem.GetProcessorSettingData()
+	if err != nil {
+		return err
+	}
+	memorySettingData, err := vm.computerSystem.GetMemorySettingData()
+	if err != nil {
+		return err
+	}
+	vm.populate(vm.computerSystem, virtualSystemSettingData, processorSettingData, memorySettingData)
 	return nil
 }
 
-func NewVirtualMachine(cs *virtual_system.ComputerSystem) (*VirtualMachine, error) {
+// populate sets the fields of the virtual machine from its settings, however they were loaded.
+func (vm *VirtualMachine) populate(
+	cs *virtual_system.ComputerSystem,
+	virtualSystemSettingData *virtual_system.VirtualSystemSettingData,
+	processorSettingData *processor.ProcessorSettingData,
+	memorySettingData *memory.MemorySettingsData,
+) {
+	vm.computerSystem = cs
+	vm.Name = cs.ElementName
+	vm.Description = ""
+	if len(virtualSystemSettingData.Notes) > 0 {
+		vm.Description = virtualSystemSettingData.Notes[0]
+	}
+	vm.SavePath = virtualSystemSettingData.ConfigurationDataRoot
+	vm.CpuCoreCount = int(processorSettingData.VirtualQuantity)
+	vm.MemorySizeMB = int(memorySettingData.VirtualQuantity)
+	vm.loaded |= FieldsHardware
+}
+
+// NewVirtualMachine 根据 ComputerSystem 创建虚拟机, 未指定字段时加载 FieldsHardware
+func NewVirtualMachine(cs *virtual_system.ComputerSystem, fields ...VirtualMachineFields) (*VirtualMachine, error) {
+	return newVirtualMachine(nil, cs, selectedFields(fields))
+}
+
+func newVirtualMachine(c *Client, cs *virtual_system.ComputerSystem, fields VirtualMachineFields) (*VirtualMachine, error) {
 	var err error
-	vm := &VirtualMachine{}
-	if err = vm.update(cs); err != nil {
+	vm := &VirtualMachine{client: c}
+	if err = vm.update(cs, fields); err != nil {
 		return nil, err
 	}
 	return vm, nil
@@ -114,11 +274,7 @@ func NewVirtualMachine(cs *virtual_system.ComputerSystem) (*VirtualMachine, erro
 
 func (vm *VirtualMachine) Create() (err error) {
 	var buildVM *VirtualMachine
-	var builder *VirtualMachineBuilder
-
-	if builder, err = NewVirtualMachineBuilder(); err != nil {
-		return err
-	}
+	builder := &VirtualMachineBuilder{client: vm.client}
 
 	builder.PrepareSystemSettings(vm.Name, func(systemSettingsData *virtual_system.VirtualSystemSettingData) {
 		systemSettingsData.ConfigurationDataRoot = vm.SavePath
@@ -137,14 +293,14 @@ func (vm *VirtualMachine) Create() (err error) {
 	})
 
 	if buildVM, err = builder.Build(); err != nil {
-		if errors.Unwrap(err).(*wmiext.JobError).ErrorCode == 32769 {
+		if errors.Is(err, hverrors.ErrAccessDenied) {
 			// 创建失败, 当前目录下已存在同名的虚拟机
 			return ErrorVirtualMachineAlreadyExists
 		}
 		return err
 	}
 
-	if err = vm.update(buildVM.computerSystem); err != nil {
+	if err = vm.update(buildVM.computerSystem, FieldsHardware); err != nil {
 		return err
 	}
 
@@ -180,69 +336,55 @@ func WithStop(confirmStop bool) Option {
 
 // Modify 修改虚拟机规格
 func (vm *VirtualMachine) Modify(options ...Option) (ok bool, err error) {
-	var originalState = vm.State()
+	originalState, err := vm.GetState()
+	if err != nil {
+		return false, err
+	}
 	opts := new(ModifySpecOptions)
 	for _, option := range options {
 		option(opts)
 	}
-	if opts.confirmStop && vm.State() != StateStopped {
+	if opts.confirmStop && originalState != StateStopped {
 		if err = vm.computerSystem.ForceStop(); err != nil {
 			return
 		}
 	}
-	vmms, err := virtual_system.LocalVirtualSystemManagementService()
-	if err != nil {
-		return false, err
-	}
-	// 修改 CPU 核心数
+	// 修改 CPU 核心数与内存大小, 合并为一次 ModifyResourceSettings 调用
+	changes := vm.Changes()
 	if opts.cpuCoreCount > 0 {
 		// HyperV 要求如果虚拟机未关闭, 则不允许修改 CPU 核心数
-		if vm.State() != StateStopped {
-			return false, errors.New("vm must be stopped before modifying spec")
-		}
-
-		processorSettingData := vm.computerSystem.MustGetProcessorSettingData()
-		processorSettingData.VirtualQuantity = uint64(opts.cpuCoreCount)
-
-		if err = processorSettingData.Put("VirtualQuantity", processorSettingData.VirtualQuantity); err != nil {
+		state, err := vm.GetState()
+		if err != nil {
 			return false, err
 		}
-
-		if err = vmms.ModifyProcessorSettings(processorSettingData); err != nil {
-			return false, err
+		if state != StateStopped {
+			return false, errors.New("vm must be stopped before modifying spec")
 		}
-
-		vm.CpuCoreCount = opts.cpuCoreCount
+		changes.SetCpuCoreCount(opts.cpuCoreCount)
 	}
 	if opts.memorySizeMB > 0 {
 		// HyperV 允许虚拟机运行状态下, 修改内存大小
-		memorySettingData := vm.computerSystem.MustGetMemorySettingData()
-
-		memorySettingData.VirtualQuantity = uint64(opts.memorySizeMB)
-
-		if err = memorySettingData.Put("VirtualQuantity", memorySettingData.VirtualQuantity); err != nil {
+		changes.SetMemorySizeMB(opts.memorySizeMB)
+	}
+	if _, err = changes.Apply(); err != nil {
+		if opts.memorySizeMB <= 0 || !hverrors.HasCode(err, hverrors.ReturnMethodFailed) {
 			return false, err
 		}
-
-		if err = vmms.ModifyMemorySettings(memorySettingData); err != nil {
-			if errors.Unwrap(err).(*wmiext.JobError).ErrorCode == 32768 {
-				// Error code 32768: The operation cannot be performed while the virtual machine is in its current state.
-				// Maybe the virtual machine does not support resizing memory dynamically (with not stop), try to stop the virtual machine and try again
-				if err = vm.computerSystem.ForceStop(); err != nil {
-					return false, err
-				}
-				if err = vmms.ModifyMemorySettings(memorySettingData); err != nil {
-					return false, err
-				}
-			} else {
-				return false, err
-			}
+		// Error code 32768: The operation cannot be performed while the virtual machine is in its current state.
+		// Maybe the virtual machine does not support resizing memory dynamically (with not stop), try to stop the virtual machine and try again
+		if err = vm.computerSystem.ForceStop(); err != nil {
+			return false, err
+		}
+		if _, err = changes.Apply(); err != nil {
+			return false, err
 		}
-
-		vm.MemorySizeMB = opts.memorySizeMB
 	}
 
-	if vm.State() != originalState {
+	state, err := vm.GetState()
+	if err != nil {
+		return false, err
+	}
+	if state != originalState {
 		if err = vm.computerSystem.ChangeState(originalState); err != nil {
 			return false, err
 		}
@@ -255,11 +397,12 @@ func (vm *VirtualMachine) Modify(options ...Option) (ok bool, err error) {
 // 
 // 参数:
 //   vmName: 虚拟机名称
+//   fields: 加载的字段, 未指定时加载 FieldsHardware
 // 返回:
 //   []*VirtualMachine: 虚拟机列表
 //   error: 错误
-func FindVirtualMachineByName(vmName string) ([]*VirtualMachine, error) {
-	service, err := virtual_system.LocalVirtualSystemManagementService()
+func (c *Client) FindVirtualMachineByName(vmName string, fields ...VirtualMachineFields) ([]*VirtualMachine, error) {
+	service, err := c.VirtualSystemManagementService()
 	if err != nil {
 		return nil, err
 	}
@@ -269,7 +412,7 @@ func FindVirtualMachineByName(vmName string) ([]*VirtualMachine, error) {
 	}
 	var virtualMachines []*VirtualMachine
 	for _, vm := range vms {
-		virtualMachine, err := NewVirtualMachine(vm)
+		virtualMachine, err := newVirtualMachine(c, vm, selectedFields(fields))
 		if err != nil {
 			return nil, err
 		}
@@ -278,9 +421,18 @@ func FindVirtualMachineByName(vmName string) ([]*VirtualMachine, error) {
 	return virtualMachines, nil
 }
 
+// FindVirtualMachineByName is Client.FindVirtualMachineByName on the default client.
+func FindVirtualMachineByName(vmName string, fields ...VirtualMachineFields) ([]*VirtualMachine, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.FindVirtualMachineByName(vmName, fields...)
+}
+
 // FirstVirtualMachineByName 根据虚拟机名称获取第一个虚拟机
-func FirstVirtualMachineByName(vmName string) (*VirtualMachine, error) {
-	vms, err := FindVirtualMachineByName(vmName)
+func (c *Client) FirstVirtualMachineByName(vmName string, fields ...VirtualMachineFields) (*VirtualMachine, error) {
+	vms, err := c.FindVirtualMachineByName(vmName, fields...)
 	if err != nil {
 		return nil, err
 	}
@@ -290,22 +442,35 @@ func FirstVirtualMachineByName(vmName string) (*VirtualMachine, error) {
 	return vms[0], nil
 }
 
-func MustFirstVirtualMachineByName(vmName string) *VirtualMachine {
-	vm, err := FirstVirtualMachineByName(vmName)
+// FirstVirtualMachineByName is Client.FirstVirtualMachineByName on the default client.
+func FirstVirtualMachineByName(vmName string, fields ...VirtualMachineFields) (*VirtualMachine, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.FirstVirtualMachineByName(vmName, fields...)
+}
+
+// MustFirstVirtualMachineByName is FirstVirtualMachineByName panicking on error.
+//
+// Deprecated: use FirstVirtualMachineByName or Client.FirstVirtualMachineByName, which return the error.
+func MustFirstVirtualMachineByName(vmName string, fields ...VirtualMachineFields) *VirtualMachine {
+	vm, err := FirstVirtualMachineByName(vmName, fields...)
 	if err != nil {
-		log.Fatalf("failed to find virtual machine: %v", err)
+		panic(err)
 	}
 	return vm
 }
 
 // CreateVirtualMachine 创建虚拟机
-func CreateVirtualMachine(name string, savePath string, cpuCoreCount int, memorySize int) (*VirtualMachine, error) {
+func (c *Client) CreateVirtualMachine(name string, savePath string, cpuCoreCount int, memorySize int) (*VirtualMachine, error) {
 	var err error
 	virtualMachine := &VirtualMachine{
 		Name:         name,
 		SavePath:     savePath,
 		CpuCoreCount: cpuCoreCount,
 		MemorySizeMB: memorySize,
+		client:       c,
 	}
 	if err = virtualMachine.Create(); err != nil {
 		return nil, err
@@ -313,11 +478,21 @@ func CreateVirtualMachine(name string, savePath string, cpuCoreCount int, memory
 	return virtualMachine, nil
 }
 
-// ListVirtualMachines 获取所有虚拟机
-func ListVirtualMachines() (vms []*VirtualMachine, err error) {
+// CreateVirtualMachine is Client.CreateVirtualMachine on the default client.
+func CreateVirtualMachine(name string, savePath string, cpuCoreCount int, memorySize int) (*VirtualMachine, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.CreateVirtualMachine(name, savePath, cpuCoreCount, memorySize)
+}
+
+// ListVirtualMachines 获取所有虚拟机, fields 为加载的字段, 未指定时加载 FieldsHardware.
+// 列出大量虚拟机时可以指定 FieldsBasic, 其余字段按需加载
+func (c *Client) ListVirtualMachines(fields ...VirtualMachineFields) (vms []*VirtualMachine, err error) {
 	var vsms *virtual_system.VirtualSystemManagementService
 	var vm *VirtualMachine
-	vsms, err = virtual_system.LocalVirtualSystemManagementService()
+	vsms, err = c.VirtualSystemManagementService()
 	if err != nil {
 		return nil, err
 	}
@@ -326,7 +501,7 @@ func ListVirtualMachines() (vms []*VirtualMachine, err error) {
 		return nil, err
 	}
 	for _, cs := range computerSystems {
-		if vm, err = NewVirtualMachine(cs); err != nil {
+		if vm, err = newVirtualMachine(c, cs, selectedFields(fields)); err != nil {
 			return nil, err
 		}
 		vms = append(vms, vm)
@@ -334,17 +509,34 @@ func ListVirtualMachines() (vms []*VirtualMachine, err error) {
 	return
 }
 
+// ListVirtualMachines is Client.ListVirtualMachines on the default client.
+func ListVirtualMachines(fields ...VirtualMachineFields) ([]*VirtualMachine, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.ListVirtualMachines(fields...)
+}
+
 // DestroyVirtualMachineByName 根据名称删除虚拟机
-func DestroyVirtualMachineByName(name string, del bool) (ok bool, err error) {
-	vmms, err := virtual_system.LocalVirtualSystemManagementService()
+func (c *Client) DestroyVirtualMachineByName(name string, del bool) (ok bool, err error) {
+	vmms, err := c.VirtualSystemManagementService()
+	if err != nil {
+		return false, err
+	}
+	vm, err := c.FirstVirtualMachineByName(name, FieldsBasic)
+	if err != nil {
+		return false, err
+	}
+	hardware, err := vm.Hardware()
 	if err != nil {
 		return false, err
 	}
-	vm, err := FirstVirtualMachineByName(name)
+	state, err := vm.GetState()
 	if err != nil {
 		return false, err
 	}
-	if vm.State() != StateStopped {
+	if state != StateStopped {
 		return false, errors.New("vm must be stopped before deleting")
 	}
 
@@ -353,15 +545,29 @@ func DestroyVirtualMachineByName(name string, del bool) (ok bool, err error) {
 	}
 	if del {
 		// RemoveAll 可以删除非空文件夹
-		if err = os.RemoveAll(vm.SavePath); err != nil {
+		if err = os.RemoveAll(hardware.SavePath); err != nil {
 			return false, err
 		}
 	}
 	return true, nil
 }
 
+// DestroyVirtualMachineByName is Client.DestroyVirtualMachineByName on the default client.
+func DestroyVirtualMachineByName(name string, del bool) (bool, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return false, err
+	}
+	return c.DestroyVirtualMachineByName(name, del)
+}
+
 // DeleteVirtualMachineByName 根据名称删除虚拟机
-func DeleteVirtualMachineByName(name string) (ok bool, err error) {
+func (c *Client) DeleteVirtualMachineByName(name string) (ok bool, err error) {
+	return c.DestroyVirtualMachineByName(name, true)
+}
+
+// DeleteVirtualMachineByName is Client.DeleteVirtualMachineByName on the default client.
+func DeleteVirtualMachineByName(name string) (bool, error) {
 	return DestroyVirtualMachineByName(name, true)
 }
 
@@ -381,10 +587,19 @@ func (vm *VirtualMachine) ModifySpec(cpuCoreCount, memorySize int) (ok bool, err
 }
 
 // ModifyVirtualMachineSpecByName 根据虚拟机名称修改虚拟机规格
-func ModifyVirtualMachineSpecByName(name string, cpuCoreCount int, memorySize int) (ok bool, err error) {
-	vm, err := FirstVirtualMachineByName(name)
+func (c *Client) ModifyVirtualMachineSpecByName(name string, cpuCoreCount int, memorySize int) (ok bool, err error) {
+	vm, err := c.FirstVirtualMachineByName(name, FieldsBasic)
 	if err != nil {
 		return false, err
 	}
 	return vm.ModifySpec(cpuCoreCount, memorySize)
 }
+
+// ModifyVirtualMachineSpecByName is Client.ModifyVirtualMachineSpecByName on the default client.
+func ModifyVirtualMachineSpecByName(name string, cpuCoreCount int, memorySize int) (bool, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return false, err
+	}
+	return c.ModifyVirtualMachineSpecByName(name, cpuCoreCount, memorySize)
+}
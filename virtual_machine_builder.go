@@ -1,11 +1,61 @@
 package hyperv
 
 import (
+	"github.com/pkg/errors"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/memory"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/processor"
 	virtualsystem "github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
 )
 
+// Generation 虚拟机代数
+type Generation int
+
+const (
+	// Generation1 第一代虚拟机, BIOS 固件, 系统盘挂载到 IDE 控制器
+	Generation1 Generation = 1
+	// Generation2 第二代虚拟机, UEFI 固件, 没有 IDE 控制器, 系统盘挂载到 SCSI 控制器
+	Generation2 Generation = 2
+)
+
+// SecureBootTemplate 安全启动模板
+type SecureBootTemplate string
+
+const (
+	// SecureBootTemplateMicrosoftWindows Windows 客户机
+	SecureBootTemplateMicrosoftWindows SecureBootTemplate = virtualsystem.SecureBootTemplate_MicrosoftWindows
+	// SecureBootTemplateMicrosoftUEFICertificateAuthority Microsoft UEFI CA 签名的客户机, 如多数 Linux 发行版
+	SecureBootTemplateMicrosoftUEFICertificateAuthority SecureBootTemplate = virtualsystem.SecureBootTemplate_MicrosoftUEFICertificateAuthority
+	// SecureBootTemplateOpenSourceShieldedVM 开源受防护虚拟机
+	SecureBootTemplateOpenSourceShieldedVM SecureBootTemplate = virtualsystem.SecureBootTemplate_OpenSourceShieldedVM
+)
+
+// NetworkBootProtocol 网络启动首选协议
+type NetworkBootProtocol uint16
+
+const (
+	NetworkBootIPv4 NetworkBootProtocol = NetworkBootProtocol(virtualsystem.NetworkBootPreferredProtocol_IPv4)
+	NetworkBootIPv6 NetworkBootProtocol = NetworkBootProtocol(virtualsystem.NetworkBootPreferredProtocol_IPv6)
+)
+
+// FirmwareSettings 第二代虚拟机的 UEFI 固件设置
+type FirmwareSettings struct {
+	SecureBoot         bool
+	SecureBootTemplate SecureBootTemplate
+	// PauseAfterBootFailure 启动失败后暂停虚拟机, 而不是重试启动
+	PauseAfterBootFailure bool
+	NetworkBootProtocol   NetworkBootProtocol
+}
+
+// DefaultFirmwareSettings 默认固件设置, 启用 Windows 模板的安全启动, 网络启动使用 IPv4
+func DefaultFirmwareSettings() FirmwareSettings {
+	return FirmwareSettings{
+		SecureBoot:          true,
+		SecureBootTemplate:  SecureBootTemplateMicrosoftWindows,
+		NetworkBootProtocol: NetworkBootIPv4,
+	}
+}
+
 type VirtualMachineBuilder struct {
 	// name                string
 	// cpuCoreCount        int
@@ -17,14 +67,33 @@ type VirtualMachineBuilder struct {
 	systemSettingsData *virtualsystem.VirtualSystemSettingData
 	memorySettingData  *memory.MemorySettingsData
 	processorSettings  *processor.ProcessorSettingData
+	// generation, firmware and version are applied to the system settings by Build.
+	generation Generation
+	firmware   *FirmwareSettings
+	version    string
 
 	svc *virtualsystem.VirtualSystemManagementService
+	// client defines the virtual machine, the default client when nil.
+	client *Client
 }
 
 func NewVirtualMachineBuilder() (*VirtualMachineBuilder, error) {
 	return &VirtualMachineBuilder{}, nil
 }
 
+// NewVirtualMachineBuilder returns a builder defining the virtual machine with the client.
+func (c *Client) NewVirtualMachineBuilder() *VirtualMachineBuilder {
+	return &VirtualMachineBuilder{client: c}
+}
+
+func (builder *VirtualMachineBuilder) session() (*wmiext.Service, error) {
+	c, err := clientOr(builder.client)
+	if err != nil {
+		return nil, err
+	}
+	return c.service()
+}
+
 func (builder *VirtualMachineBuilder) PrepareSystemSettings(name string, beforeAdd func(systemSettingsData *virtualsystem.VirtualSystemSettingData)) *VirtualMachineBuilder {
 	if builder.Err != nil {
 		return builder
@@ -50,7 +119,11 @@ func (builder *VirtualMachineBuilder) PrepareProcessorSettings(beforeAdd func(pr
 	}
 
 	if builder.processorSettings == nil {
-		if builder.processorSettings, builder.Err = processor.GetDefaultProcessorSettingData(); builder.Err != nil {
+		var session *wmiext.Service
+		if session, builder.Err = builder.session(); builder.Err != nil {
+			return builder
+		}
+		if builder.processorSettings, builder.Err = processor.GetDefaultProcessorSettingDataWith(session); builder.Err != nil {
 			return builder
 		}
 	}
@@ -68,7 +141,11 @@ func (builder *VirtualMachineBuilder) PrepareMemorySettings(beforeAdd func(data
 	}
 
 	if builder.memorySettingData == nil {
-		if builder.memorySettingData, builder.Err = memory.GetDefaultMemorySettingsData(); builder.Err != nil {
+		var session *wmiext.Service
+		if session, builder.Err = builder.session(); builder.Err != nil {
+			return builder
+		}
+		if builder.memorySettingData, builder.Err = memory.GetDefaultMemorySettingsDataWith(session); builder.Err != nil {
 			return builder
 		}
 	}
@@ -80,24 +157,105 @@ func (builder *VirtualMachineBuilder) PrepareMemorySettings(beforeAdd func(data
 	return builder
 }
 
+// PrepareGeneration 设置虚拟机代数, 默认为第一代
+func (builder *VirtualMachineBuilder) PrepareGeneration(generation Generation) *VirtualMachineBuilder {
+	if builder.Err != nil {
+		return builder
+	}
+	if generation != Generation1 && generation != Generation2 {
+		builder.Err = errors.Errorf("unknown virtual machine generation %d", generation)
+		return builder
+	}
+	builder.generation = generation
+	return builder
+}
+
+// PrepareFirmwareSettings 设置第二代虚拟机的 UEFI 固件, 在 DefaultFirmwareSettings 的基础上修改.
+// 未设置时第二代虚拟机使用 DefaultFirmwareSettings, 第一代虚拟机设置固件时 Build 返回错误
+func (builder *VirtualMachineBuilder) PrepareFirmwareSettings(beforeAdd func(firmware *FirmwareSettings)) *VirtualMachineBuilder {
+	if builder.Err != nil {
+		return builder
+	}
+
+	if builder.firmware == nil {
+		firmware := DefaultFirmwareSettings()
+		builder.firmware = &firmware
+	}
+
+	if beforeAdd != nil {
+		beforeAdd(builder.firmware)
+	}
+
+	return builder
+}
+
+// PrepareVersion 设置虚拟机配置版本, 如 "9.0", 默认为主机支持的最新版本
+func (builder *VirtualMachineBuilder) PrepareVersion(version string) *VirtualMachineBuilder {
+	if builder.Err != nil {
+		return builder
+	}
+	builder.version = version
+	return builder
+}
+
+// applySystemSettings applies the generation, firmware and version to the system settings. Generation 2
+// systems get the default firmware unless prepared, generation 1 systems cannot have one.
+func (builder *VirtualMachineBuilder) applySystemSettings() error {
+	settings := builder.systemSettingsData
+	if settings == nil {
+		return errors.New("system settings are not prepared")
+	}
+
+	switch builder.generation {
+	case Generation1:
+		settings.VirtualSystemSubType = virtualsystem.HyperVGeneration_V1
+	case Generation2:
+		settings.VirtualSystemSubType = virtualsystem.HyperVGeneration_V2
+	}
+
+	if settings.VirtualSystemSubType == virtualsystem.HyperVGeneration_V2 {
+		firmware := DefaultFirmwareSettings()
+		if builder.firmware != nil {
+			firmware = *builder.firmware
+		}
+		settings.SecureBootEnabled = firmware.SecureBoot
+		settings.SecureBootTemplateId = string(firmware.SecureBootTemplate)
+		settings.PauseAfterBootFailure = firmware.PauseAfterBootFailure
+		settings.NetworkBootPreferredProtocol = uint16(firmware.NetworkBootProtocol)
+	} else if builder.firmware != nil {
+		return errors.New("firmware settings require a generation 2 virtual machine")
+	}
+
+	if builder.version != "" {
+		settings.Version = builder.version
+	}
+	return nil
+}
+
 func (builder *VirtualMachineBuilder) Build() (*VirtualMachine, error) {
 	var err error
 	var vmms *virtualsystem.VirtualSystemManagementService
 	var cs *virtualsystem.ComputerSystem
 
-	if vmms, err = virtualsystem.LocalVirtualSystemManagementService(); err != nil {
+	if builder.Err != nil {
+		return nil, builder.Err
+	}
+	if vmms, err = virtualSystemManagementService(builder.client); err != nil {
 		return nil, err
 	}
 	builder.svc = vmms
+	if err = builder.applySystemSettings(); err != nil {
+		return nil, err
+	}
 	// defer vmms.Close()
-	cs, err = vmms.DefineSystem(builder.systemSettingsData, builder.processorSettings, builder.memorySettingData)
+	cs, err = wmiext.Await(vmms.DefineSystem(builder.systemSettingsData, builder.processorSettings, builder.memorySettingData))
 	if err != nil {
 		return nil, err
 	}
 
-	// 提前创建SCSI控制器, 以支持热插拔硬盘
+	// 提前创建SCSI控制器, 以支持热插拔硬盘, 第二代虚拟机的系统盘同样挂载到SCSI控制器
 	if err = vmms.AddSCSIController(cs); err != nil {
 		return nil, err
 	}
-	return NewVirtualMachine(cs)
+	return newVirtualMachine(builder.client, cs, FieldsHardware)
 }
@@ -0,0 +1,192 @@
+package hyperv
+
+import (
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/memory"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/processor"
+	virtualsystem "github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVirtualMachineBuilder_applySystemSettings(t *testing.T) {
+	t.Run("generation 1 by default", func(t *testing.T) {
+		builder := (&VirtualMachineBuilder{}).PrepareSystemSettings("vm-1", nil)
+		require.NoError(t, builder.applySystemSettings())
+		assert.Equal(t, virtualsystem.HyperVGeneration_V1, builder.systemSettingsData.VirtualSystemSubType)
+		assert.False(t, builder.systemSettingsData.SecureBootEnabled)
+	})
+
+	t.Run("generation 2 with default firmware", func(t *testing.T) {
+		builder := (&VirtualMachineBuilder{}).PrepareGeneration(Generation2).PrepareSystemSettings("vm-1", nil).PrepareVersion("9.0")
+		require.NoError(t, builder.applySystemSettings())
+		settings := builder.systemSettingsData
+		assert.Equal(t, virtualsystem.HyperVGeneration_V2, settings.VirtualSystemSubType)
+		assert.True(t, settings.SecureBootEnabled)
+		assert.Equal(t, virtualsystem.SecureBootTemplate_MicrosoftWindows, settings.SecureBootTemplateId)
+		assert.Equal(t, virtualsystem.NetworkBootPreferredProtocol_IPv4, settings.NetworkBootPreferredProtocol)
+		assert.Equal(t, "9.0", settings.Version)
+	})
+
+	t.Run("generation 2 with prepared firmware", func(t *testing.T) {
+		builder := (&VirtualMachineBuilder{}).
+			PrepareSystemSettings("vm-1", nil).
+			PrepareGeneration(Generation2).
+			PrepareFirmwareSettings(func(firmware *FirmwareSettings) {
+				firmware.SecureBootTemplate = SecureBootTemplateMicrosoftUEFICertificateAuthority
+				firmware.PauseAfterBootFailure = true
+				firmware.NetworkBootProtocol = NetworkBootIPv6
+			})
+		require.NoError(t, builder.applySystemSettings())
+		settings := builder.systemSettingsData
+		assert.True(t, settings.SecureBootEnabled)
+		assert.Equal(t, virtualsystem.SecureBootTemplate_MicrosoftUEFICertificateAuthority, settings.SecureBootTemplateId)
+		assert.True(t, settings.PauseAfterBootFailure)
+		assert.Equal(t, virtualsystem.NetworkBootPreferredProtocol_IPv6, settings.NetworkBootPreferredProtocol)
+	})
+
+	t.Run("firmware requires generation 2", func(t *testing.T) {
+		builder := (&VirtualMachineBuilder{}).PrepareSystemSettings("vm-1", nil).PrepareFirmwareSettings(nil)
+		assert.ErrorContains(t, builder.applySystemSettings(), "generation 2")
+	})
+
+	t.Run("unknown generation", func(t *testing.T) {
+		builder := (&VirtualMachineBuilder{}).PrepareGeneration(Generation(3))
+		assert.ErrorContains(t, builder.Err, "unknown virtual machine generation 3")
+	})
+
+	t.Run("system settings not prepared", func(t *testing.T) {
+		assert.Error(t, (&VirtualMachineBuilder{}).PrepareGeneration(Generation2).applySystemSettings())
+	})
+}
+
+// newDefineSystemHost returns a client whose DefineSystem realizes the system settings it is given as
+// an Msvm_VirtualSystemSettingData, whose path it returns once called.
+func newDefineSystemHost(t *testing.T) (*Client, *wmiext.MemoryRepository, *string) {
+	c, repo := newTestClient(t)
+	defineSettingAssociations(repo)
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: "Msvm_VirtualSystemManagementService",
+		Keys: []string{"CreationClassName", "Name"},
+		Methods: map[string]map[string]wmiext.CIMTYPE_ENUMERATION{
+			"DefineSystem": {
+				"SystemSettings": wmiext.CIM_STRING, "ResourceSettings": wmiext.CIM_STRING | wmiext.CIM_FLAG_ARRAY,
+				"ReferenceConfiguration": wmiext.CIM_REFERENCE, "ResultingSystem": wmiext.CIM_REFERENCE, "Job": wmiext.CIM_REFERENCE,
+			},
+		},
+	})
+	firmware := []string{"SecureBootEnabled", "SecureBootTemplateId", "PauseAfterBootFailure", "NetworkBootPreferredProtocol"}
+	repo.DefineClass(wmiext.MemoryClass{
+		Name: virtualsystem.Msvm_VirtualSystemSettingData,
+		Keys: []string{"InstanceID"},
+		Properties: map[string]wmiext.CIMTYPE_ENUMERATION{
+			"InstanceID":                   wmiext.CIM_STRING,
+			"ElementName":                  wmiext.CIM_STRING,
+			"VirtualSystemSubType":         wmiext.CIM_STRING,
+			"SecureBootEnabled":            wmiext.CIM_BOOLEAN,
+			"SecureBootTemplateId":         wmiext.CIM_STRING,
+			"PauseAfterBootFailure":        wmiext.CIM_BOOLEAN,
+			"NetworkBootPreferredProtocol": wmiext.CIM_UINT16,
+		},
+	})
+
+	add := func(class string, properties map[string]interface{}) string {
+		path, err := repo.AddInstance(class, properties)
+		require.NoError(t, err)
+		return path
+	}
+	// The default processor and memory settings DefineSystem is given along with the system settings
+	for _, defaults := range []struct{ class, subType string }{
+		{"Msvm_ProcessorSettingData", processor.ProcessorResourceType},
+		{"Msvm_MemorySettingData", memory.MemoryResourceType},
+	} {
+		capabilities := add("Msvm_AllocationCapabilities", map[string]interface{}{"InstanceID": defaults.subType, "ResourceSubType": defaults.subType})
+		setting := add(defaults.class, map[string]interface{}{"InstanceID": defaults.subType + `\Default`, "ResourceSubType": defaults.subType})
+		add("Msvm_SettingsDefineCapabilities", map[string]interface{}{
+			"GroupComponent": wmiext.Reference(capabilities), "PartComponent": wmiext.Reference(setting), "ValueRole": uint16(0),
+		})
+	}
+
+	var settingPath string
+	repo.HandleMethod("Msvm_VirtualSystemManagementService", "DefineSystem", func(call *wmiext.MethodCall) error {
+		settings, err := wmiext.DecodeCimXml(call.InString("SystemSettings"))
+		if err != nil {
+			return err
+		}
+		properties := map[string]interface{}{"InstanceID": "Microsoft:A0B1"}
+		for _, name := range append([]string{"ElementName", "VirtualSystemSubType"}, firmware...) {
+			if value, _, _, err := settings.Get(name); err == nil && value != nil {
+				properties[name] = value
+			}
+		}
+		if settingPath, err = repo.AddInstance(virtualsystem.Msvm_VirtualSystemSettingData, properties); err != nil {
+			return err
+		}
+		systemPath, err := repo.AddInstance("Msvm_ComputerSystem", map[string]interface{}{
+			"CreationClassName": "Msvm_ComputerSystem", "Name": "A0B1", "ElementName": properties["ElementName"],
+		})
+		if err != nil {
+			return err
+		}
+		call.Out("ResultingSystem", systemPath)
+		call.Return(0)
+		return nil
+	})
+	return c, repo, &settingPath
+}
+
+func TestVirtualMachineBuilder_DefineSystem_Firmware(t *testing.T) {
+	defineSystem := func(t *testing.T, builder *VirtualMachineBuilder) map[string]interface{} {
+		c, repo, settingPath := newDefineSystemHost(t)
+		builder.client = c
+		builder.PrepareProcessorSettings(nil).PrepareMemorySettings(nil)
+		require.NoError(t, builder.Err)
+		require.NoError(t, builder.applySystemSettings())
+
+		vsms, err := c.VirtualSystemManagementService()
+		require.NoError(t, err)
+		system, err := wmiext.Await(vsms.DefineSystem(builder.systemSettingsData, builder.processorSettings, builder.memorySettingData))
+		require.NoError(t, err)
+		defer system.Close()
+
+		settings, err := repo.Get(*settingPath)
+		require.NoError(t, err)
+		written := map[string]interface{}{}
+		for _, name := range []string{"VirtualSystemSubType", "SecureBootTemplateId", "SecureBootEnabled", "NetworkBootPreferredProtocol"} {
+			written[name], _, _, err = settings.Get(name)
+			require.NoError(t, err)
+		}
+		return written
+	}
+
+	t.Run("default firmware", func(t *testing.T) {
+		written := defineSystem(t, (&VirtualMachineBuilder{}).PrepareGeneration(Generation2).PrepareSystemSettings("vm-1", nil))
+		assert.Equal(t, virtualsystem.HyperVGeneration_V2, written["VirtualSystemSubType"])
+		assert.Equal(t, virtualsystem.SecureBootTemplate_MicrosoftWindows, written["SecureBootTemplateId"])
+		assert.Equal(t, true, written["SecureBootEnabled"])
+		assert.EqualValues(t, virtualsystem.NetworkBootPreferredProtocol_IPv4, written["NetworkBootPreferredProtocol"])
+	})
+
+	t.Run("prepared firmware", func(t *testing.T) {
+		written := defineSystem(t, (&VirtualMachineBuilder{}).
+			PrepareGeneration(Generation2).
+			PrepareSystemSettings("vm-1", nil).
+			PrepareFirmwareSettings(func(firmware *FirmwareSettings) {
+				firmware.SecureBoot = false
+				firmware.SecureBootTemplate = SecureBootTemplateOpenSourceShieldedVM
+				firmware.NetworkBootProtocol = NetworkBootIPv6
+			}))
+		assert.Equal(t, virtualsystem.SecureBootTemplate_OpenSourceShieldedVM, written["SecureBootTemplateId"])
+		assert.Equal(t, false, written["SecureBootEnabled"])
+		assert.EqualValues(t, virtualsystem.NetworkBootPreferredProtocol_IPv6, written["NetworkBootPreferredProtocol"])
+	})
+
+	t.Run("generation 1 has no firmware", func(t *testing.T) {
+		written := defineSystem(t, (&VirtualMachineBuilder{}).PrepareSystemSettings("vm-1", nil))
+		assert.Equal(t, virtualsystem.HyperVGeneration_V1, written["VirtualSystemSubType"])
+		assert.Nil(t, written["SecureBootTemplateId"])
+		assert.Nil(t, written["NetworkBootPreferredProtocol"])
+	})
+}
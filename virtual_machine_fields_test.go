@@ -0,0 +1,113 @@
+package hyperv
+
+import (
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addAssociatedMachine adds a virtual machine whose settings are reachable through associations,
+// and returns the paths of its computer system and processor settings.
+func addAssociatedMachine(t *testing.T, repo *wmiext.MemoryRepository, name string, guid string) (string, string) {
+	add := func(class string, properties map[string]interface{}) string {
+		path, err := repo.AddInstance(class, properties)
+		require.NoError(t, err)
+		return path
+	}
+	systemPath := add("Msvm_ComputerSystem", map[string]interface{}{
+		"CreationClassName": "Msvm_ComputerSystem", "Name": guid, "ElementName": name, "Caption": "Virtual Machine",
+	})
+	settingPath := add("Msvm_VirtualSystemSettingData", map[string]interface{}{
+		"InstanceID": "Microsoft:" + guid, "VirtualSystemIdentifier": guid, "VirtualSystemType": "Microsoft:Hyper-V:System:Realized",
+		"ConfigurationDataRoot": `C:\vms\` + name,
+	})
+	add("Msvm_SettingsDefineState", map[string]interface{}{
+		"ManagedElement": wmiext.Reference(systemPath), "SettingData": wmiext.Reference(settingPath),
+	})
+	processorPath := add("Msvm_ProcessorSettingData", map[string]interface{}{"InstanceID": "Microsoft:" + guid + `\processor`, "VirtualQuantity": uint64(2)})
+	memoryPath := add("Msvm_MemorySettingData", map[string]interface{}{"InstanceID": "Microsoft:" + guid + `\memory`, "VirtualQuantity": uint64(1024)})
+	for _, part := range []string{processorPath, memoryPath} {
+		add("Msvm_VirtualSystemSettingDataComponent", map[string]interface{}{
+			"GroupComponent": wmiext.Reference(settingPath), "PartComponent": wmiext.Reference(part),
+		})
+	}
+	return systemPath, processorPath
+}
+
+// defineSettingAssociations defines the classes addAssociatedMachine relies on.
+func defineSettingAssociations(repo *wmiext.MemoryRepository) {
+	repo.DefineClass(wmiext.MemoryClass{Name: "Msvm_ComputerSystem", Keys: []string{"CreationClassName", "Name"}})
+	for _, association := range []struct{ name, antecedent, dependent string }{
+		{"Msvm_SettingsDefineState", "ManagedElement", "SettingData"},
+		{"Msvm_VirtualSystemSettingDataComponent", "GroupComponent", "PartComponent"},
+	} {
+		repo.DefineClass(wmiext.MemoryClass{
+			Name: association.name,
+			Properties: map[string]wmiext.CIMTYPE_ENUMERATION{
+				association.antecedent: wmiext.CIM_REFERENCE,
+				association.dependent:  wmiext.CIM_REFERENCE,
+			},
+		})
+	}
+}
+
+func TestVirtualMachine_Fields(t *testing.T) {
+	c, repo := newTestClient(t)
+	defineSettingAssociations(repo)
+	systemPath, processorPath := addAssociatedMachine(t, repo, "vm-1", "A0B1")
+
+	// The basic fields only need the computer system
+	vm, err := c.FirstVirtualMachineByName("vm-1", FieldsBasic)
+	require.NoError(t, err)
+	assert.Equal(t, "vm-1", vm.Name)
+	assert.Zero(t, vm.CpuCoreCount)
+	assert.True(t, vm.Loaded(FieldsBasic))
+	assert.False(t, vm.Loaded(FieldsHardware))
+
+	// Hardware loads the fields on first access instead of reporting zero values
+	hardware, err := vm.Hardware()
+	require.NoError(t, err)
+	assert.Equal(t, VirtualMachineHardware{SavePath: `C:\vms\vm-1`, CpuCoreCount: 2, MemorySizeMB: 1024}, hardware)
+	assert.True(t, vm.Loaded(FieldsHardware))
+
+	require.NoError(t, vm.Load(FieldsHardware))
+	assert.True(t, vm.Loaded(FieldsHardware))
+	assert.Equal(t, 2, vm.CpuCoreCount)
+	assert.Equal(t, 1024, vm.MemorySizeMB)
+	assert.Equal(t, `C:\vms\vm-1`, vm.SavePath)
+	// A machine without notes has no description
+	assert.Empty(t, vm.Description)
+
+	// Loaded fields are cached until refreshed
+	require.NoError(t, repo.Update(processorPath, map[string]interface{}{"VirtualQuantity": uint64(8)}))
+	require.NoError(t, repo.Update(systemPath, map[string]interface{}{"ElementName": "vm-2"}))
+	require.NoError(t, vm.Load(FieldsHardware))
+	assert.Equal(t, 2, vm.CpuCoreCount)
+	require.NoError(t, vm.Refresh())
+	assert.Equal(t, "vm-2", vm.Name)
+	assert.Equal(t, 8, vm.CpuCoreCount)
+
+	// Lookups load the hardware unless told otherwise
+	vm, err = c.FirstVirtualMachineByName("vm-2")
+	require.NoError(t, err)
+	assert.True(t, vm.Loaded(FieldsHardware))
+	assert.Equal(t, 8, vm.CpuCoreCount)
+	vms, err := c.FindVirtualMachineByName("vm-2", FieldsBasic, FieldsHardware)
+	require.NoError(t, err)
+	require.Len(t, vms, 1)
+	assert.Equal(t, 8, vms[0].CpuCoreCount)
+
+	// Missing settings surface as errors
+	require.NoError(t, repo.Delete(processorPath))
+	vm, err = c.FirstVirtualMachineByName("vm-2", FieldsBasic)
+	require.NoError(t, err)
+	err = vm.Load(FieldsHardware)
+	assert.ErrorIs(t, err, wmiext.NotFound)
+	assert.False(t, vm.Loaded(FieldsHardware))
+	_, err = vm.Hardware()
+	assert.ErrorIs(t, err, wmiext.NotFound)
+	_, err = c.FirstVirtualMachineByName("vm-2")
+	assert.ErrorIs(t, err, wmiext.NotFound)
+}
@@ -1,11 +1,10 @@
 package hyperv
 
 import (
-	"fmt"
-
 	"github.com/pkg/errors"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/network_adapter"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking"
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/networking/switch_extension"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
 	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system/host"
 	"github.com/rokukoo/hyperv/pkg/wmiext"
@@ -30,6 +29,8 @@ type VirtualNetworkAdapter struct {
 	VlanId       int
 
 	virtualNetworkAdapter *network_adapter.VirtualNetworkAdapter
+	// client performs the operations of the adapter, the default client when nil.
+	client *Client
 }
 
 func (vm *VirtualMachine) GetVirtualNetworkAdapters() ([]*VirtualNetworkAdapter, error) {
@@ -44,7 +45,7 @@ func (vm *VirtualMachine) GetVirtualNetworkAdapters() ([]*VirtualNetworkAdapter,
 	}
 	for _, syntheticVirtualNetworkAdapter := range syntheticVirtualNetworkAdapters {
 		var vna *VirtualNetworkAdapter
-		if vna, err = NewVirtualNetworkAdapter(syntheticVirtualNetworkAdapter); err != nil {
+		if vna, err = newVirtualNetworkAdapter(vm.client, syntheticVirtualNetworkAdapter); err != nil {
 			return nil, err
 		}
 		virtualNetworkAdapters = append(virtualNetworkAdapters, vna)
@@ -57,18 +58,14 @@ func (vna *VirtualNetworkAdapter) GetVirtualMachine() (*VirtualMachine, error) {
 	if err != nil {
 		return nil, err
 	}
-	return NewVirtualMachine(cs)
+	return newVirtualMachine(vna.client, cs, FieldsHardware)
 }
 
 func (vna *VirtualNetworkAdapter) update(virtualNetworkAdapter *network_adapter.VirtualNetworkAdapter) (err error) {
-	vna.virtualNetworkAdapter = virtualNetworkAdapter
-	vna.Name = virtualNetworkAdapter.ElementName
 	syntheticNetworkAdapter, err := network_adapter.NewSyntheticNetworkAdapterFromInstance(virtualNetworkAdapter.Instance)
 	if err != nil {
 		return
 	}
-	vna.StaticMacAddress = syntheticNetworkAdapter.StaticMacAddress
-	vna.MacAddress = syntheticNetworkAdapter.Address
 
 	ethernetPortAllocationSettingData, err := syntheticNetworkAdapter.GetEthernetPortAllocationSettingData()
 	if err != nil {
@@ -79,35 +76,59 @@ func (vna *VirtualNetworkAdapter) update(virtualNetworkAdapter *network_adapter.
 	if err != nil && !errors.Is(err, wmiext.NotFound) {
 		return
 	}
-	if ethernetSwitchPortBandwidthSettingData != nil {
-		vna.IsEnableBandwidth = true
-		vna.MaxBandwidth = float64(ethernetSwitchPortBandwidthSettingData.Limit) / 1000000
-		vna.MinBandwidth = float64(ethernetSwitchPortBandwidthSettingData.Reservation) / 1000000
-	}
-
 	// Vlan setting data
 	ethernetSwitchPortVlanSettingData, err := ethernetPortAllocationSettingData.GetEthernetSwitchPortVlanSettingData()
 	if err != nil && !errors.Is(err, wmiext.NotFound) {
 		return
 	}
+
+	configuration, err := virtualNetworkAdapter.GetGuestNetworkAdapterConfiguration()
+	if err != nil {
+		return
+	}
+	vna.populate(virtualNetworkAdapter, syntheticNetworkAdapter.SyntheticEthernetPortSettingData,
+		ethernetSwitchPortBandwidthSettingData, ethernetSwitchPortVlanSettingData, configuration)
+	return nil
+}
+
+// populate sets the fields of the adapter from its settings, however they were loaded. The
+// bandwidth, VLAN and guest configuration are nil when absent.
+func (vna *VirtualNetworkAdapter) populate(
+	virtualNetworkAdapter *network_adapter.VirtualNetworkAdapter,
+	syntheticEthernetPortSettingData *networking.SyntheticEthernetPortSettingData,
+	ethernetSwitchPortBandwidthSettingData *switch_extension.EthernetSwitchPortBandwidthSettingData,
+	ethernetSwitchPortVlanSettingData *switch_extension.EthernetSwitchPortVlanSettingData,
+	configuration *network_adapter.GuestNetworkAdapterConfiguration,
+) {
+	vna.virtualNetworkAdapter = virtualNetworkAdapter
+	vna.Name = virtualNetworkAdapter.ElementName
+	vna.StaticMacAddress = syntheticEthernetPortSettingData.StaticMacAddress
+	vna.MacAddress = syntheticEthernetPortSettingData.Address
+
+	if ethernetSwitchPortBandwidthSettingData != nil {
+		vna.IsEnableBandwidth = true
+		vna.MaxBandwidth = float64(ethernetSwitchPortBandwidthSettingData.Limit) / 1000000
+		vna.MinBandwidth = float64(ethernetSwitchPortBandwidthSettingData.Reservation) / 1000000
+	}
 	if ethernetSwitchPortVlanSettingData != nil {
 		vna.IsEnableVlan = true
 		vna.VlanId = int(ethernetSwitchPortVlanSettingData.AccessVlanId)
 	}
 
-	configuration, err := vna.virtualNetworkAdapter.GetGuestNetworkAdapterConfiguration()
-	if err != nil {
-		return
+	if configuration != nil {
+		vna.IPAddress = configuration.IPAddresses
+		vna.DefaultGateway = configuration.DefaultGateways
+		vna.SubnetMask = configuration.Subnets
+		vna.DNSServers = configuration.DNSServers
 	}
-	vna.IPAddress = configuration.IPAddresses
-	vna.DefaultGateway = configuration.DefaultGateways
-	vna.SubnetMask = configuration.Subnets
-	vna.DNSServers = configuration.DNSServers
-	return nil
 }
 
 func NewVirtualNetworkAdapter(networkAdapter *network_adapter.VirtualNetworkAdapter) (*VirtualNetworkAdapter, error) {
-	vna := &VirtualNetworkAdapter{}
+	return newVirtualNetworkAdapter(nil, networkAdapter)
+}
+
+func newVirtualNetworkAdapter(c *Client, networkAdapter *network_adapter.VirtualNetworkAdapter) (*VirtualNetworkAdapter, error) {
+	vna := &VirtualNetworkAdapter{client: c}
 	return vna, vna.update(networkAdapter)
 }
 
@@ -126,7 +147,7 @@ func (vm *VirtualMachine) FirstVirtualNetworkAdapterByName(name string) (*Virtua
 			return nil, err
 		}
 		var vna *VirtualNetworkAdapter
-		vna, err = NewVirtualNetworkAdapter(networkAdapter)
+		vna, err = newVirtualNetworkAdapter(vm.client, networkAdapter)
 		if err != nil {
 			return nil, err
 		}
@@ -138,6 +159,9 @@ func (vm *VirtualMachine) FirstVirtualNetworkAdapterByName(name string) (*Virtua
 	return nil, wmiext.NotFound
 }
 
+// MustFirstVirtualNetworkAdapterByName is FirstVirtualNetworkAdapterByName panicking on error.
+//
+// Deprecated: use VirtualMachine.FirstVirtualNetworkAdapterByName, which returns the error.
 func (vm *VirtualMachine) MustFirstVirtualNetworkAdapterByName(name string) *VirtualNetworkAdapter {
 	vna, err := vm.FirstVirtualNetworkAdapterByName(name)
 	if err != nil {
@@ -162,7 +186,7 @@ func (vm *VirtualMachine) FindVirtualNetworkAdapterByName(name string) ([]*Virtu
 			return nil, err
 		}
 		var vna *VirtualNetworkAdapter
-		vna, err = NewVirtualNetworkAdapter(networkAdapter)
+		vna, err = newVirtualNetworkAdapter(vm.client, networkAdapter)
 		if err != nil {
 			return nil, err
 		}
@@ -185,11 +209,15 @@ func (vm *VirtualMachine) AddVirtualNetworkAdapter(vna *VirtualNetworkAdapter) (
 	if syntheticNetworkAdapter, err = vm.computerSystem.NewSyntheticNetworkAdapter(vna.Name); err != nil {
 		return
 	}
-	vmms, err := virtual_system.LocalVirtualSystemManagementService()
+	vmms, err := virtualSystemManagementService(vm.client)
 	if err != nil {
 		return err
 	}
-	resourceSettings, err := vmms.AddResourceSettings(vm.computerSystem.MustGetVirtualSystemSettingData(), []string{syntheticNetworkAdapter.GetCimText()})
+	virtualSystemSettingData, err := vm.computerSystem.GetVirtualSystemSettingData()
+	if err != nil {
+		return err
+	}
+	resourceSettings, err := wmiext.Await(vmms.AddResourceSettings(virtualSystemSettingData, []string{syntheticNetworkAdapter.GetCimText()}))
 	if err != nil {
 		return
 	}
@@ -201,6 +229,8 @@ func (vm *VirtualMachine) AddVirtualNetworkAdapter(vna *VirtualNetworkAdapter) (
 	}
 
 	vna.virtualNetworkAdapter = syntheticNetworkAdapter
+	vna.client = vm.client
+	vm.invalidate(FieldsNetworkAdapters)
 
 	if vna.IsEnableBandwidth {
 		if err = vna.SetBandwidth(vna.MaxBandwidth, vna.MinBandwidth); err != nil {
@@ -218,7 +248,7 @@ func (vna *VirtualNetworkAdapter) Detach() error {
 	if vna.virtualNetworkAdapter == nil {
 		return errors.New("vna not attached")
 	}
-	vmms, err := virtual_system.LocalVirtualSystemManagementService()
+	vmms, err := virtualSystemManagementService(vna.client)
 	if err != nil {
 		return err
 	}
@@ -240,6 +270,7 @@ func (vm *VirtualMachine) RemoveVirtualNetworkAdapter(name string) (err error) {
 	if err != nil {
 		return
 	}
+	defer vm.invalidate(FieldsNetworkAdapters)
 	for _, networkAdapter := range networkAdapters {
 		if err = networkAdapter.Detach(); err != nil {
 			return
@@ -255,7 +286,7 @@ func (vm *VirtualMachine) RemoveVirtualNetworkAdapter(name string) (err error) {
 // 返回:
 //   error: 错误
 func (vna *VirtualNetworkAdapter) DisableBandwidthLimit() (err error) {
-	vsms, err := virtual_system.LocalVirtualSystemManagementService()
+	vsms, err := virtualSystemManagementService(vna.client)
 	if err != nil {
 		return
 	}
@@ -299,7 +330,7 @@ func (vna *VirtualNetworkAdapter) SetBandwidth(limitBandwidthMbps, reserveBandwi
 	if limitBandwidthMbps == 0 && reserveBandwidthMbps == 0 {
 		return wmiext.NotSupported
 	}
-	vsms, err := virtual_system.LocalVirtualSystemManagementService()
+	vsms, err := virtualSystemManagementService(vna.client)
 	if err != nil {
 		return err
 	}
@@ -347,7 +378,7 @@ func (vna *VirtualNetworkAdapter) SetBandwidth(limitBandwidthMbps, reserveBandwi
 	// If the virtual network adapter bandwidth setting data does not exist, create a new one
 	if ethernetSwitchPortBandwidthSettingData == nil {
 		// Build a new virtual network adapter bandwidth setting data
-		if ethernetSwitchPortBandwidthSettingData, err = host.DefaultEthernetSwitchPortBandwidthSettingData(); err != nil {
+		if ethernetSwitchPortBandwidthSettingData, err = host.DefaultEthernetSwitchPortBandwidthSettingDataWith(vsms.Session); err != nil {
 			return err
 		}
 		if err = modifyBandwidthSettingData(); err != nil {
@@ -392,7 +423,7 @@ func (vna *VirtualNetworkAdapter) GetVirtualSwitch() (*VirtualSwitch, error) {
 	if err = syntheticAdapter.GetService().GetObjectAsObject(vswPath, virtualEthernetSwitch); err != nil {
 		return nil, err
 	}
-	return NewVirtualSwitch(virtualEthernetSwitch)
+	return newVirtualSwitch(vna.client, virtualEthernetSwitch)
 }
 
 // ConnectByName 连接虚拟网络适配器到虚拟交换机
@@ -410,7 +441,7 @@ func (vna *VirtualNetworkAdapter) ConnectByName(vswName string) (bool, error) {
 		vsw            *networking.VirtualEthernetSwitch
 	)
 	// Get the virtual system management service
-	if vsms, err = virtual_system.LocalVirtualSystemManagementService(); err != nil {
+	if vsms, err = virtualSystemManagementService(vna.client); err != nil {
 		return false, err
 	}
 
@@ -436,7 +467,11 @@ func (vna *VirtualNetworkAdapter) ConnectByName(vswName string) (bool, error) {
 // 返回:
 //   error: 错误
 func (vna *VirtualNetworkAdapter) DisConnect() (err error) {
-	if err = virtual_system.MustLocalVirtualSystemManagementService().DisConnectAdapterToVirtualSwitch(vna.Name); err != nil {
+	vsms, err := virtualSystemManagementService(vna.client)
+	if err != nil {
+		return
+	}
+	if err = vsms.DisConnectAdapterToVirtualSwitch(vna.Name); err != nil {
 		return
 	}
 	return
@@ -483,7 +518,7 @@ func (vna *VirtualNetworkAdapter) ModifyConfiguration(
 	if err != nil {
 		return
 	}
-	vmms, err := virtual_system.LocalVirtualSystemManagementService()
+	vmms, err := virtualSystemManagementService(vna.client)
 	if err != nil {
 		return
 	}
@@ -498,13 +533,33 @@ func (vna *VirtualNetworkAdapter) ModifyConfiguration(
 // 返回:
 //   virtualNetworkAdapters: 虚拟网络适配器列表
 //   error: 错误
-func FindVirtualNetworkAdapterByName(name string) (virtualNetworkAdapters []*VirtualNetworkAdapter, err error) {
-	vsms, err := virtual_system.LocalVirtualSystemManagementService()
+func (c *Client) FindVirtualNetworkAdapterByName(name string) (virtualNetworkAdapters []*VirtualNetworkAdapter, err error) {
+	session, err := c.service()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := wmiext.Query[networking.EthernetPortAllocationSettingData](session,
+		wmiext.Select(networking.Msvm_SyntheticEthernetPortSettingData).Where(wmiext.Equal("ElementName", name)))
 	if err != nil {
 		return nil, err
 	}
-	wquery := fmt.Sprintf("SELECT * FROM Msvm_SyntheticEthernetPortSettingData WHERE ElementName = '%s'", name)
-	return virtualNetworkAdapters, vsms.Session.FindObjects(wquery, virtualNetworkAdapters)
+	for _, setting := range settings {
+		var vna *VirtualNetworkAdapter
+		if vna, err = newVirtualNetworkAdapter(c, &network_adapter.VirtualNetworkAdapter{EthernetPortAllocationSettingData: setting}); err != nil {
+			return nil, err
+		}
+		virtualNetworkAdapters = append(virtualNetworkAdapters, vna)
+	}
+	return virtualNetworkAdapters, nil
+}
+
+// FindVirtualNetworkAdapterByName is Client.FindVirtualNetworkAdapterByName on the default client.
+func FindVirtualNetworkAdapterByName(name string) ([]*VirtualNetworkAdapter, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.FindVirtualNetworkAdapterByName(name)
 }
 
 // FirstVirtualNetworkAdapterByName 根据名称查找第一个虚拟网络适配器
@@ -514,13 +569,15 @@ func FindVirtualNetworkAdapterByName(name string) (virtualNetworkAdapters []*Vir
 // 返回:
 //   virtualNetworkAdapter: 虚拟网络适配器
 //   error: 错误
-func FirstVirtualNetworkAdapterByName(name string) (virtualNetworkAdapter *VirtualNetworkAdapter, err error) {
-	vsms, err := virtual_system.LocalVirtualSystemManagementService()
+func (c *Client) FirstVirtualNetworkAdapterByName(name string) (virtualNetworkAdapter *VirtualNetworkAdapter, err error) {
+	session, err := c.service()
 	if err != nil {
 		return nil, err
 	}
-	wquery := fmt.Sprintf("SELECT * FROM Msvm_SyntheticEthernetPortSettingData WHERE ElementName = '%s'", name)
-	instance, err := vsms.Session.FindFirstInstance(wquery)
+	wquery := wmiext.Select(networking.Msvm_SyntheticEthernetPortSettingData).
+		Where(wmiext.Equal("ElementName", name)).
+		String()
+	instance, err := session.FindFirstInstance(wquery)
 	if err != nil {
 		return nil, err
 	}
@@ -528,9 +585,21 @@ func FirstVirtualNetworkAdapterByName(name string) (virtualNetworkAdapter *Virtu
 	if err != nil {
 		return nil, err
 	}
-	return NewVirtualNetworkAdapter(networkAdapter)
+	return newVirtualNetworkAdapter(c, networkAdapter)
+}
+
+// FirstVirtualNetworkAdapterByName is Client.FirstVirtualNetworkAdapterByName on the default client.
+func FirstVirtualNetworkAdapterByName(name string) (*VirtualNetworkAdapter, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.FirstVirtualNetworkAdapterByName(name)
 }
 
+// MustFirstVirtualNetworkAdapterByName is FirstVirtualNetworkAdapterByName panicking on error.
+//
+// Deprecated: use FirstVirtualNetworkAdapterByName or Client.FirstVirtualNetworkAdapterByName, which return the error.
 func MustFirstVirtualNetworkAdapterByName(name string) *VirtualNetworkAdapter {
 	vna, err := FirstVirtualNetworkAdapterByName(name)
 	if err != nil {
@@ -0,0 +1,54 @@
+package hyperv
+
+import (
+	"testing"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_FindVirtualNetworkAdapterByName(t *testing.T) {
+	c, repo := newTestClient(t)
+	for _, class := range []string{
+		"Msvm_SyntheticEthernetPortSettingData", "Msvm_EthernetPortAllocationSettingData",
+		"Msvm_EthernetSwitchPortBandwidthSettingData", "Msvm_EthernetSwitchPortVlanSettingData",
+		"Msvm_GuestNetworkAdapterConfiguration",
+	} {
+		repo.DefineClass(wmiext.MemoryClass{Name: class, Keys: []string{"InstanceID"}})
+	}
+	repo.DefineClass(wmiext.MemoryClass{
+		Name:       "Msvm_SettingDataComponent",
+		Properties: map[string]wmiext.CIMTYPE_ENUMERATION{"GroupComponent": wmiext.CIM_REFERENCE, "PartComponent": wmiext.CIM_REFERENCE},
+	})
+	add := func(class string, properties map[string]interface{}) string {
+		path, err := repo.AddInstance(class, properties)
+		require.NoError(t, err)
+		return path
+	}
+	for _, vm := range []struct{ guid, adapter string }{{"A0B1", "lan"}, {"C2D3", "lan"}, {"E4F5", "wan"}} {
+		adapter := add("Msvm_SyntheticEthernetPortSettingData", map[string]interface{}{
+			"InstanceID": `Microsoft:` + vm.guid + `\adapter`, "ElementName": vm.adapter,
+		})
+		for class, id := range map[string]string{
+			"Msvm_EthernetPortAllocationSettingData": `\port`,
+			"Msvm_GuestNetworkAdapterConfiguration":  `\guest`,
+		} {
+			part := add(class, map[string]interface{}{"InstanceID": `Microsoft:` + vm.guid + id})
+			add("Msvm_SettingDataComponent", map[string]interface{}{
+				"GroupComponent": wmiext.Reference(adapter), "PartComponent": wmiext.Reference(part),
+			})
+		}
+	}
+
+	adapters, err := c.FindVirtualNetworkAdapterByName("lan")
+	require.NoError(t, err)
+	require.Len(t, adapters, 2)
+	for _, adapter := range adapters {
+		assert.Equal(t, "lan", adapter.Name)
+	}
+
+	adapters, err = c.FindVirtualNetworkAdapterByName("missing")
+	require.NoError(t, err)
+	assert.Empty(t, adapters)
+}
@@ -27,40 +27,65 @@ type VirtualSwitch struct {
 	Type                  VirtualSwitchType `json:"type"`
 	PhysicalAdapter       *string           `json:"physical_adapter"`
 	virtualEthernetSwitch *networking.VirtualEthernetSwitch
+	// client performs the operations of the virtual switch, the default client when nil.
+	client *Client
 }
 
 func NewVirtualSwitch(virtualEthernetSwitch *networking.VirtualEthernetSwitch) (*VirtualSwitch, error) {
-	vsw := &VirtualSwitch{}
+	return newVirtualSwitch(nil, virtualEthernetSwitch)
+}
+
+func newVirtualSwitch(c *Client, virtualEthernetSwitch *networking.VirtualEthernetSwitch) (*VirtualSwitch, error) {
+	vsw := &VirtualSwitch{client: c}
 	vsw.virtualEthernetSwitch = virtualEthernetSwitch
 	return vsw, vsw.update(virtualEthernetSwitch)
 }
 
 func (vsw *VirtualSwitch) update(virtualEthernetSwitch *networking.VirtualEthernetSwitch) (err error) {
-	vsw.virtualEthernetSwitch = virtualEthernetSwitch
-	vsw.Name = virtualEthernetSwitch.ElementName
 	virtualEthernetSwitchSettingData, err := virtualEthernetSwitch.ActiveVirtualEthernetSwitchSettingData()
 	if err != nil {
 		return
 	}
-	vsw.Description = virtualEthernetSwitchSettingData.Notes[0]
-	vsw.Type, err = vsw.GetType()
-	if err != nil {
+	internalPortAllocSettings, err := virtualEthernetSwitch.GetInternalPortAllocSettings()
+	if err != nil && !errors.Is(err, wmiext.NotFound) {
 		return errors.Wrap(err, "failed to get virtual switch type")
 	}
 	externalPortAllocSettings, err := virtualEthernetSwitch.GetExternalPortAllocSettings()
 	if err != nil && !errors.Is(err, wmiext.NotFound) {
 		return errors.Wrap(err, "failed to get external port allocation setting data")
 	}
+	var externalPort *networking.ExternalEthernetPort
 	if externalPortAllocSettings != nil {
-		externalPort := &networking.ExternalEthernetPort{}
+		externalPort = &networking.ExternalEthernetPort{}
 		if err = virtualEthernetSwitch.GetService().GetObjectAsObject(externalPortAllocSettings.HostResource[0], externalPort); err != nil {
 			return errors.Wrap(err, "failed to find external ethernet port")
 		}
-		vsw.PhysicalAdapter = &externalPort.Name
 	}
+	vsw.populate(virtualEthernetSwitch, virtualEthernetSwitchSettingData, internalPortAllocSettings != nil, externalPort)
 	return nil
 }
 
+// populate sets the fields of the virtual switch from its settings, however they were loaded.
+// externalPort is nil unless the switch is connected to a physical adapter.
+func (vsw *VirtualSwitch) populate(
+	virtualEthernetSwitch *networking.VirtualEthernetSwitch,
+	virtualEthernetSwitchSettingData *networking.VirtualEthernetSwitchSettingData,
+	internal bool,
+	externalPort *networking.ExternalEthernetPort,
+) {
+	vsw.virtualEthernetSwitch = virtualEthernetSwitch
+	vsw.Name = virtualEthernetSwitch.ElementName
+	vsw.Description = ""
+	if len(virtualEthernetSwitchSettingData.Notes) > 0 {
+		vsw.Description = virtualEthernetSwitchSettingData.Notes[0]
+	}
+	vsw.Type = switchTypeOf(internal, externalPort != nil)
+	vsw.PhysicalAdapter = nil
+	if externalPort != nil {
+		vsw.PhysicalAdapter = &externalPort.Name
+	}
+}
+
 func (vsw *VirtualSwitch) GetType() (VirtualSwitchType, error) {
 	var (
 		err error
@@ -73,17 +98,23 @@ func (vsw *VirtualSwitch) GetType() (VirtualSwitchType, error) {
 	if externalPortAllocSettings, err = vsw.virtualEthernetSwitch.GetExternalPortAllocSettings(); err != nil && !errors.Is(err, wmiext.NotFound) {
 		return 0, errors.Wrap(err, "failed to get internal port allocation setting data")
 	}
-	if internalPortAllocSettings != nil {
-		if externalPortAllocSettings != nil {
-			return VirtualSwitchTypeExternalBridge, nil
+	return switchTypeOf(internalPortAllocSettings != nil, externalPortAllocSettings != nil), nil
+}
+
+// switchTypeOf returns the type of a switch given whether it has an internal port, for the
+// host, and an external port, to a physical adapter.
+func switchTypeOf(internal, external bool) VirtualSwitchType {
+	if internal {
+		if external {
+			return VirtualSwitchTypeExternalBridge
 		} else {
-			return VirtualSwitchTypeInternal, nil
+			return VirtualSwitchTypeInternal
 		}
 	} else {
-		if externalPortAllocSettings != nil {
-			return VirtualSwitchTypeExternalDirect, nil
+		if external {
+			return VirtualSwitchTypeExternalDirect
 		} else {
-			return VirtualSwitchTypePrivate, nil
+			return VirtualSwitchTypePrivate
 		}
 	}
 }
@@ -92,7 +123,7 @@ func (vsw *VirtualSwitch) ChangeType(switchType VirtualSwitchType, adapter *stri
 	virtualSwitch := vsw.virtualEthernetSwitch
 	var vsms *networking_service.VirtualEthernetSwitchManagementService
 
-	if vsms, err = networking_service.LocalVirtualEthernetSwitchManagementService(); err != nil {
+	if vsms, err = virtualEthernetSwitchManagementService(vsw.client); err != nil {
 		return errors.Wrap(err, "failed to get virtual switch management service")
 	}
 
@@ -213,7 +244,7 @@ func (vsw *VirtualSwitch) ChangeType(switchType VirtualSwitchType, adapter *stri
 //
 //	*VirtualSwitch: 虚拟交换机
 //	error: 错误
-func CreateVirtualSwitch(name string, switchType VirtualSwitchType, adapter *string) (*VirtualSwitch, error) {
+func (c *Client) CreateVirtualSwitch(name string, switchType VirtualSwitchType, adapter *string) (*VirtualSwitch, error) {
 	var (
 		vsw = &networking.VirtualEthernetSwitch{}
 		err error
@@ -221,41 +252,50 @@ func CreateVirtualSwitch(name string, switchType VirtualSwitchType, adapter *str
 	switch switchType {
 	case VirtualSwitchTypePrivate:
 		// Build private virtual switch
-		if vsw, err = CreatePrivateVirtualSwitch(name); err != nil {
+		if vsw, err = c.CreatePrivateVirtualSwitch(name); err != nil {
 			return nil, errors.Wrap(err, "failed to create private virtual switch")
 		}
-		return NewVirtualSwitch(vsw)
+		return newVirtualSwitch(c, vsw)
 	case VirtualSwitchTypeInternal:
 		// Build internal virtual switch
-		if vsw, err = CreateInternalVirtualSwitch(name); err != nil {
+		if vsw, err = c.CreateInternalVirtualSwitch(name); err != nil {
 			return nil, errors.Wrap(err, "failed to create internal virtual")
 		}
-		return NewVirtualSwitch(vsw)
+		return newVirtualSwitch(c, vsw)
 	case VirtualSwitchTypeExternalBridge:
 		// Build external virtual switch
-		if vsw, err = CreateExternalVirtualSwitch(name, *adapter, true); err != nil {
+		if vsw, err = c.CreateExternalVirtualSwitch(name, *adapter, true); err != nil {
 			return nil, errors.Wrap(err, "failed to create external virtual switch")
 		}
-		return NewVirtualSwitch(vsw)
+		return newVirtualSwitch(c, vsw)
 	case VirtualSwitchTypeExternalDirect:
 		// Build external virtual switch directly
-		if vsw, err = CreateExternalVirtualSwitch(name, *adapter, false); err != nil {
+		if vsw, err = c.CreateExternalVirtualSwitch(name, *adapter, false); err != nil {
 			return nil, errors.Wrap(err, "failed to create external virtual switch")
 		}
-		return NewVirtualSwitch(vsw)
+		return newVirtualSwitch(c, vsw)
 	default:
 		return nil, errors.New("invalid virtual switch type")
 	}
 }
 
+// CreateVirtualSwitch is Client.CreateVirtualSwitch on the default client.
+func CreateVirtualSwitch(name string, switchType VirtualSwitchType, adapter *string) (*VirtualSwitch, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.CreateVirtualSwitch(name, switchType, adapter)
+}
+
 // CreatePrivateVirtualSwitch creates a private virtual switch
-func CreatePrivateVirtualSwitch(name string) (*networking.VirtualEthernetSwitch, error) {
+func (c *Client) CreatePrivateVirtualSwitch(name string) (*networking.VirtualEthernetSwitch, error) {
 	var (
 		vsms    *networking_service.VirtualEthernetSwitchManagementService
 		setting *networking.VirtualEthernetSwitchSettingData
 		err     error
 	)
-	if vsms, err = networking_service.LocalVirtualEthernetSwitchManagementService(); err != nil {
+	if vsms, err = c.VirtualEthernetSwitchManagementService(); err != nil {
 		return nil, err
 	}
 	if setting, err = vsms.GetVirtualEthernetSwitchSettingData(name); err != nil {
@@ -264,13 +304,22 @@ func CreatePrivateVirtualSwitch(name string) (*networking.VirtualEthernetSwitch,
 	return vsms.CreatePrivateVirtualSwitch(setting)
 }
 
-func CreateInternalVirtualSwitch(name string) (*networking.VirtualEthernetSwitch, error) {
+// CreatePrivateVirtualSwitch is Client.CreatePrivateVirtualSwitch on the default client.
+func CreatePrivateVirtualSwitch(name string) (*networking.VirtualEthernetSwitch, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.CreatePrivateVirtualSwitch(name)
+}
+
+func (c *Client) CreateInternalVirtualSwitch(name string) (*networking.VirtualEthernetSwitch, error) {
 	var (
 		vsms    *networking_service.VirtualEthernetSwitchManagementService
 		setting *networking.VirtualEthernetSwitchSettingData
 		err     error
 	)
-	if vsms, err = networking_service.LocalVirtualEthernetSwitchManagementService(); err != nil {
+	if vsms, err = c.VirtualEthernetSwitchManagementService(); err != nil {
 		return nil, err
 	}
 	if setting, err = vsms.GetVirtualEthernetSwitchSettingData(name); err != nil {
@@ -279,13 +328,22 @@ func CreateInternalVirtualSwitch(name string) (*networking.VirtualEthernetSwitch
 	return vsms.CreateInternalVirtualSwitch(name, setting)
 }
 
-func CreateExternalVirtualSwitch(name, networkInterfaceDescription string, internalport bool) (*networking.VirtualEthernetSwitch, error) {
+// CreateInternalVirtualSwitch is Client.CreateInternalVirtualSwitch on the default client.
+func CreateInternalVirtualSwitch(name string) (*networking.VirtualEthernetSwitch, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.CreateInternalVirtualSwitch(name)
+}
+
+func (c *Client) CreateExternalVirtualSwitch(name, networkInterfaceDescription string, internalport bool) (*networking.VirtualEthernetSwitch, error) {
 	var (
 		vsms *networking_service.VirtualEthernetSwitchManagementService
 		err  error
 	)
 
-	if vsms, err = networking_service.LocalVirtualEthernetSwitchManagementService(); err != nil {
+	if vsms, err = c.VirtualEthernetSwitchManagementService(); err != nil {
 		return nil, err
 	}
 	switchSettingData, err := vsms.GetVirtualEthernetSwitchSettingData(name)
@@ -300,6 +358,15 @@ func CreateExternalVirtualSwitch(name, networkInterfaceDescription string, inter
 	return vSwitch, nil
 }
 
+// CreateExternalVirtualSwitch is Client.CreateExternalVirtualSwitch on the default client.
+func CreateExternalVirtualSwitch(name, networkInterfaceDescription string, internalport bool) (*networking.VirtualEthernetSwitch, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.CreateExternalVirtualSwitch(name, networkInterfaceDescription, internalport)
+}
+
 // FirstVirtualSwitchByName 根据名称获取第一个虚拟交换机
 //
 // 参数:
@@ -310,21 +377,33 @@ func CreateExternalVirtualSwitch(name, networkInterfaceDescription string, inter
 //
 //	*VirtualSwitch: 虚拟交换机
 //	error: 错误
-func FirstVirtualSwitchByName(name string) (*VirtualSwitch, error) {
+func (c *Client) FirstVirtualSwitchByName(name string) (*VirtualSwitch, error) {
 	var (
 		vsms *networking_service.VirtualEthernetSwitchManagementService
 		err  error
 	)
-	if vsms, err = networking_service.LocalVirtualEthernetSwitchManagementService(); err != nil {
+	if vsms, err = c.VirtualEthernetSwitchManagementService(); err != nil {
 		return nil, err
 	}
 	virtualSwitch, err := vsms.FirstVirtualSwitchByName(name)
 	if err != nil {
 		return nil, err
 	}
-	return NewVirtualSwitch(virtualSwitch)
+	return newVirtualSwitch(c, virtualSwitch)
+}
+
+// FirstVirtualSwitchByName is Client.FirstVirtualSwitchByName on the default client.
+func FirstVirtualSwitchByName(name string) (*VirtualSwitch, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.FirstVirtualSwitchByName(name)
 }
 
+// MustFirstVirtualSwitchByName is FirstVirtualSwitchByName panicking on error.
+//
+// Deprecated: use FirstVirtualSwitchByName or Client.FirstVirtualSwitchByName, which return the error.
 func MustFirstVirtualSwitchByName(name string) *VirtualSwitch {
 	vsw, err := FirstVirtualSwitchByName(name)
 	if err != nil {
@@ -342,14 +421,23 @@ func MustFirstVirtualSwitchByName(name string) *VirtualSwitch {
 // 返回:
 //
 //	VirtualSwitchType: 虚拟交换机类型
-func GetVirtualSwitchTypeByName(name string) (VirtualSwitchType, error) {
-	vsw, err := FirstVirtualSwitchByName(name)
+func (c *Client) GetVirtualSwitchTypeByName(name string) (VirtualSwitchType, error) {
+	vsw, err := c.FirstVirtualSwitchByName(name)
 	if err != nil {
 		return 0, err
 	}
 	return vsw.Type, nil
 }
 
+// GetVirtualSwitchTypeByName is Client.GetVirtualSwitchTypeByName on the default client.
+func GetVirtualSwitchTypeByName(name string) (VirtualSwitchType, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return 0, err
+	}
+	return c.GetVirtualSwitchTypeByName(name)
+}
+
 // ChangeVirtualSwitchTypeByName 根据名称修改虚拟交换机类型
 //
 // 参数:
@@ -361,14 +449,23 @@ func GetVirtualSwitchTypeByName(name string) (VirtualSwitchType, error) {
 // 返回:
 //
 //	error: 错误
-func ChangeVirtualSwitchTypeByName(name string, switchType VirtualSwitchType, adapter *string) error {
-	vsw, err := FirstVirtualSwitchByName(name)
+func (c *Client) ChangeVirtualSwitchTypeByName(name string, switchType VirtualSwitchType, adapter *string) error {
+	vsw, err := c.FirstVirtualSwitchByName(name)
 	if err != nil {
 		return err
 	}
 	return vsw.ChangeType(switchType, adapter)
 }
 
+// ChangeVirtualSwitchTypeByName is Client.ChangeVirtualSwitchTypeByName on the default client.
+func ChangeVirtualSwitchTypeByName(name string, switchType VirtualSwitchType, adapter *string) error {
+	c, err := DefaultClient()
+	if err != nil {
+		return err
+	}
+	return c.ChangeVirtualSwitchTypeByName(name, switchType, adapter)
+}
+
 // DeleteVirtualSwitchByName 根据名称删除虚拟交换机
 //
 // 参数:
@@ -378,12 +475,12 @@ func ChangeVirtualSwitchTypeByName(name string, switchType VirtualSwitchType, ad
 // 返回:
 //
 //	error: 错误
-func DeleteVirtualSwitchByName(name string) (err error) {
+func (c *Client) DeleteVirtualSwitchByName(name string) (err error) {
 	var (
 		vsms *networking_service.VirtualEthernetSwitchManagementService
 		vsw  *networking.VirtualEthernetSwitch
 	)
-	if vsms, err = networking_service.LocalVirtualEthernetSwitchManagementService(); err != nil {
+	if vsms, err = c.VirtualEthernetSwitchManagementService(); err != nil {
 		return
 	}
 	if vsw, err = vsms.FirstVirtualSwitchByName(name); err != nil {
@@ -394,3 +491,12 @@ func DeleteVirtualSwitchByName(name string) (err error) {
 	}
 	return
 }
+
+// DeleteVirtualSwitchByName is Client.DeleteVirtualSwitchByName on the default client.
+func DeleteVirtualSwitchByName(name string) error {
+	c, err := DefaultClient()
+	if err != nil {
+		return err
+	}
+	return c.DeleteVirtualSwitchByName(name)
+}
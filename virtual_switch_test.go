@@ -1,3 +1,6 @@
+//go:build windows || hyperv_replay
+// +build windows hyperv_replay
+
 package hyperv
 
 import (
@@ -0,0 +1,303 @@
+package hyperv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rokukoo/hyperv/pkg/hypervsdk/virtual_system"
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+)
+
+// EventKind is the kind of change reported by a Watcher.
+type EventKind int
+
+const (
+	VirtualMachineCreated EventKind = iota + 1
+	VirtualMachineDeleted
+	VirtualMachineStateChanged
+	JobCompleted
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case VirtualMachineCreated:
+		return "VirtualMachineCreated"
+	case VirtualMachineDeleted:
+		return "VirtualMachineDeleted"
+	case VirtualMachineStateChanged:
+		return "VirtualMachineStateChanged"
+	case JobCompleted:
+		return "JobCompleted"
+	default:
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+}
+
+// Event 虚拟机或作业的变更事件
+type Event struct {
+	Kind EventKind
+	// Time 事件发生时间, 未知时为零值
+	Time time.Time
+
+	// VirtualMachineID 虚拟机的唯一标识 (Msvm_ComputerSystem.Name), 仅虚拟机事件
+	VirtualMachineID string
+	// VirtualMachineName 虚拟机名称, 仅虚拟机事件
+	VirtualMachineName string
+	// State 变更后的虚拟机状态, PreviousState 变更前的虚拟机状态, 仅 VirtualMachineStateChanged 设置 PreviousState
+	State         VirtualMachineState
+	PreviousState VirtualMachineState
+
+	// JobID 作业的 InstanceID, 仅 JobCompleted
+	JobID string
+	// JobState 作业的结束状态, 仅 JobCompleted
+	JobState wmiext.JobState
+	// JobErrorCode, JobErrorDescription 作业失败时的错误码与描述, 仅 JobCompleted
+	JobErrorCode        uint16
+	JobErrorDescription string
+}
+
+// WatchOption configures the events streamed by Watch.
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	virtualMachines bool
+	jobs            bool
+	interval        time.Duration
+}
+
+// WatchVirtualMachines streams the creation, deletion and state changes of virtual machines.
+func WatchVirtualMachines() WatchOption {
+	return func(o *watchOptions) {
+		o.virtualMachines = true
+	}
+}
+
+// WatchJobs streams the completion of jobs.
+func WatchJobs() WatchOption {
+	return func(o *watchOptions) {
+		o.jobs = true
+	}
+}
+
+// WithWatchInterval sets the polling interval WMI falls back to for classes without an event
+// provider, one second by default.
+func WithWatchInterval(interval time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.interval = interval
+	}
+}
+
+// Watcher streams the events of virtual machines and jobs until its context is done or it is
+// closed.
+type Watcher struct {
+	events chan Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// Watch 订阅虚拟机与作业的变更事件, 替代轮询; 未指定 WatchVirtualMachines 或 WatchJobs 时订阅全部事件
+func (c *Client) Watch(ctx context.Context, opts ...WatchOption) (*Watcher, error) {
+	session, err := c.service()
+	if err != nil {
+		return nil, err
+	}
+
+	options := watchOptions{interval: time.Second}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if !options.virtualMachines && !options.jobs {
+		options.virtualMachines, options.jobs = true, true
+	}
+
+	var queries []string
+	if options.virtualMachines {
+		vsms, err := c.VirtualSystemManagementService()
+		if err != nil {
+			return nil, err
+		}
+		hostName, err := vsms.HostName()
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, virtualMachineEvents(options.interval, hostName).String())
+	}
+	if options.jobs {
+		queries = append(queries, jobCompletionEvents(options.interval).String())
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var subscriptions []*wmiext.Subscription
+	for _, query := range queries {
+		subscription, err := session.Subscribe(ctx, query)
+		if err != nil {
+			cancel()
+			for _, subscription := range subscriptions {
+				subscription.Close()
+			}
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	w := &Watcher{events: make(chan Event), cancel: cancel, done: make(chan struct{})}
+	var wg sync.WaitGroup
+	for _, subscription := range subscriptions {
+		wg.Add(1)
+		go func(subscription *wmiext.Subscription) {
+			defer wg.Done()
+			w.forward(ctx, subscription)
+		}(subscription)
+	}
+	go func() {
+		wg.Wait()
+		close(w.events)
+		close(w.done)
+	}()
+	return w, nil
+}
+
+// Watch is Client.Watch on the default client.
+func Watch(ctx context.Context, opts ...WatchOption) (*Watcher, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.Watch(ctx, opts...)
+}
+
+// virtualMachineEvents selects the events of the computer systems other than the host, as their
+// Caption is localized.
+func virtualMachineEvents(interval time.Duration, hostName string) *wmiext.EventQuery {
+	return wmiext.InstanceEvents(wmiext.InstanceOperationEvent, virtual_system.Msvm_ComputerSystem).
+		Within(interval).
+		Where(wmiext.NotEqual("TargetInstance.Name", hostName))
+}
+
+func jobCompletionEvents(interval time.Duration) *wmiext.EventQuery {
+	return wmiext.InstanceEvents(wmiext.InstanceModificationEvent, "Msvm_ConcreteJob").
+		Within(interval).
+		Where(wmiext.GreaterOrEqual("TargetInstance.JobState", uint16(wmiext.JobStateCompleted))).
+		Where(wmiext.Less("PreviousInstance.JobState", uint16(wmiext.JobStateCompleted)))
+}
+
+// forward decodes the events of subscription onto the channel of the watcher, and ends the
+// watcher when the subscription fails.
+func (w *Watcher) forward(ctx context.Context, subscription *wmiext.Subscription) {
+	defer subscription.Close()
+
+	for event := range subscription.Events() {
+		decoded, ok, err := decodeEvent(event)
+		event.Close()
+		if err != nil {
+			w.fail(err)
+			return
+		}
+		if !ok {
+			continue
+		}
+
+		select {
+		case w.events <- decoded:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if err := subscription.Err(); err != nil {
+		w.fail(err)
+	}
+}
+
+func (w *Watcher) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.cancel()
+}
+
+// Events returns the channel of events, closed once the watcher ended.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Err returns the error that ended the watcher, nil when it ended with its context or Close.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close ends the watcher and waits until its channel is closed, discarding the pending events.
+func (w *Watcher) Close() {
+	w.cancel()
+	for range w.events {
+	}
+	<-w.done
+}
+
+// decodeEvent decodes a WMI instance event, reporting false for the events the watcher ignores,
+// such as modifications of a virtual machine leaving its state unchanged.
+func decodeEvent(event *wmiext.Event) (Event, bool, error) {
+	className, err := event.Target.GetClassName()
+	if err != nil {
+		return Event{}, false, err
+	}
+	decoded := Event{Time: event.Time}
+
+	if strings.EqualFold(className, virtual_system.Msvm_ComputerSystem) {
+		var target virtual_system.ComputerSystem
+		if err = event.Target.GetAll(&target); err != nil {
+			return Event{}, false, err
+		}
+		decoded.VirtualMachineID = target.Name
+		decoded.VirtualMachineName = target.ElementName
+		decoded.State = VirtualMachineState(target.EnabledState)
+
+		switch {
+		case strings.EqualFold(event.Class, wmiext.InstanceCreationEvent):
+			decoded.Kind = VirtualMachineCreated
+		case strings.EqualFold(event.Class, wmiext.InstanceDeletionEvent):
+			decoded.Kind = VirtualMachineDeleted
+		case event.Previous != nil:
+			previous, err := event.Previous.GetAsUint("EnabledState")
+			if err != nil {
+				return Event{}, false, err
+			}
+			if VirtualMachineState(previous) == decoded.State {
+				return Event{}, false, nil
+			}
+			decoded.Kind = VirtualMachineStateChanged
+			decoded.PreviousState = VirtualMachineState(previous)
+		default:
+			return Event{}, false, nil
+		}
+		return decoded, true, nil
+	}
+
+	if !strings.EqualFold(event.Class, wmiext.InstanceModificationEvent) {
+		return Event{}, false, nil
+	}
+	var job struct {
+		InstanceID       string
+		JobState         uint16
+		ErrorCode        uint16
+		ErrorDescription string
+	}
+	if err = event.Target.GetAll(&job); err != nil {
+		return Event{}, false, err
+	}
+	decoded.Kind = JobCompleted
+	decoded.JobID = job.InstanceID
+	decoded.JobState = wmiext.JobState(job.JobState)
+	decoded.JobErrorCode = job.ErrorCode
+	decoded.JobErrorDescription = job.ErrorDescription
+	return decoded, true, nil
+}
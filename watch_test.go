@@ -0,0 +1,110 @@
+package hyperv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rokukoo/hyperv/pkg/wmiext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nextWatchEvent(t *testing.T, w *Watcher) Event {
+	t.Helper()
+	select {
+	case event, ok := <-w.Events():
+		require.True(t, ok, "watcher ended: %v", w.Err())
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return Event{}
+	}
+}
+
+func TestClient_Watch(t *testing.T) {
+	c, repo := newTestClient(t)
+	repo.DefineClass(wmiext.MemoryClass{Name: "Msvm_ComputerSystem", Keys: []string{"CreationClassName", "Name"}})
+	repo.DefineClass(wmiext.MemoryClass{Name: "Msvm_ConcreteJob", Keys: []string{"InstanceID"}})
+	hostPath, err := repo.AddInstance("Msvm_ComputerSystem", map[string]interface{}{
+		"CreationClassName": "Msvm_ComputerSystem", "Name": "HOST", "Caption": "托管计算机系统", "EnabledState": uint16(StateRunning),
+	})
+	require.NoError(t, err)
+	jobPath, err := repo.AddInstance("Msvm_ConcreteJob", map[string]interface{}{"InstanceID": "job-1", "JobState": uint16(4)})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := c.Watch(ctx)
+	require.NoError(t, err)
+	defer w.Close()
+
+	// The host is ignored, and the machines are found whatever the language of their caption
+	require.NoError(t, repo.Update(hostPath, map[string]interface{}{"EnabledState": uint16(StateStopped)}))
+	vmPath, err := repo.AddInstance("Msvm_ComputerSystem", map[string]interface{}{
+		"CreationClassName": "Msvm_ComputerSystem", "Name": "A0B1", "ElementName": "vm-1",
+		"Caption": "虚拟机", "EnabledState": uint16(StateStopped),
+	})
+	require.NoError(t, err)
+	created := nextWatchEvent(t, w)
+	assert.Equal(t, VirtualMachineCreated, created.Kind)
+	assert.Equal(t, "A0B1", created.VirtualMachineID)
+	assert.Equal(t, "vm-1", created.VirtualMachineName)
+	assert.Equal(t, StateStopped, created.State)
+	assert.False(t, created.Time.IsZero())
+
+	require.NoError(t, repo.Update(vmPath, map[string]interface{}{"ElementName": "renamed"}))
+	require.NoError(t, repo.Update(vmPath, map[string]interface{}{"EnabledState": uint16(StateRunning)}))
+	changed := nextWatchEvent(t, w)
+	assert.Equal(t, VirtualMachineStateChanged, changed.Kind)
+	assert.Equal(t, "renamed", changed.VirtualMachineName)
+	assert.Equal(t, StateRunning, changed.State)
+	assert.Equal(t, StateStopped, changed.PreviousState)
+
+	require.NoError(t, repo.Update(jobPath, map[string]interface{}{"PercentComplete": uint16(50)}))
+	require.NoError(t, repo.Update(jobPath, map[string]interface{}{
+		"JobState": uint16(wmiext.JobStateException), "ErrorCode": uint16(32768), "ErrorDescription": "failed",
+	}))
+	completed := nextWatchEvent(t, w)
+	assert.Equal(t, JobCompleted, completed.Kind)
+	assert.Equal(t, "job-1", completed.JobID)
+	assert.Equal(t, wmiext.JobStateException, completed.JobState)
+	assert.Equal(t, uint16(32768), completed.JobErrorCode)
+	assert.Equal(t, "failed", completed.JobErrorDescription)
+
+	require.NoError(t, repo.Delete(vmPath))
+	deleted := nextWatchEvent(t, w)
+	assert.Equal(t, VirtualMachineDeleted, deleted.Kind)
+	assert.Equal(t, "A0B1", deleted.VirtualMachineID)
+
+	cancel()
+	for range w.Events() {
+	}
+	assert.NoError(t, w.Err())
+}
+
+func TestClient_WatchJobs(t *testing.T) {
+	c, repo := newTestClient(t)
+	repo.DefineClass(wmiext.MemoryClass{Name: "Msvm_ComputerSystem", Keys: []string{"CreationClassName", "Name"}})
+	repo.DefineClass(wmiext.MemoryClass{Name: "Msvm_ConcreteJob", Keys: []string{"InstanceID"}})
+	jobPath, err := repo.AddInstance("Msvm_ConcreteJob", map[string]interface{}{"InstanceID": "job-1", "JobState": uint16(4)})
+	require.NoError(t, err)
+
+	w, err := c.Watch(context.Background(), WatchJobs())
+	require.NoError(t, err)
+	_, err = repo.AddInstance("Msvm_ComputerSystem", map[string]interface{}{
+		"CreationClassName": "Msvm_ComputerSystem", "Name": "A0B1", "Caption": "Virtual Machine",
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.Update(jobPath, map[string]interface{}{"JobState": uint16(wmiext.JobStateCompleted)}))
+	event := nextWatchEvent(t, w)
+	assert.Equal(t, JobCompleted, event.Kind)
+	assert.Equal(t, wmiext.JobStateCompleted, event.JobState)
+	w.Close()
+	_, ok := <-w.Events()
+	assert.False(t, ok)
+
+	require.NoError(t, c.Close())
+	_, err = c.Watch(context.Background())
+	assert.ErrorIs(t, err, ErrClientClosed)
+}